@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"community-chatbot/internal/services"
+)
+
+// runDigest sends notification digests to users due this hour. Run it hourly (e.g. from cron).
+func runDigest(args []string) error {
+	fs := flag.NewFlagSet("digest", flag.ExitOnError)
+	at := fs.String("at", "", "evaluate due digests as of this RFC 3339 time instead of now")
+	fs.Parse(args)
+
+	now := time.Now()
+	if *at != "" {
+		parsed, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			return fmt.Errorf("invalid -at: %w", err)
+		}
+		now = parsed
+	}
+
+	_, db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+
+	digests := services.NewDigestService(db, services.LogDigestSender{})
+	result, err := digests.Run(context.Background(), now)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Scanned %d preferences: %d due, %d sent, %d empty, %d failed\n",
+		result.Scanned, result.Due, result.Sent, result.Empty, result.Failed)
+	return nil
+}
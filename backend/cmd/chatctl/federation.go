@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"community-chatbot/internal/federation"
+)
+
+// runFederation manages federation keys: federation keygen -community NAME
+func runFederation(args []string) error {
+	if len(args) == 0 || args[0] != "keygen" {
+		return fmt.Errorf("expected keygen")
+	}
+
+	fs := flag.NewFlagSet("federation keygen", flag.ExitOnError)
+	community := fs.String("community", "", "name this instance exports bundles under")
+	fs.Parse(args[1:])
+	if *community == "" {
+		return fmt.Errorf("-community is required")
+	}
+
+	seed, err := federation.GenerateSeed()
+	if err != nil {
+		return err
+	}
+	signer, err := federation.NewSigner(*community, seed)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("FEDERATION_COMMUNITY=%s\nFEDERATION_SIGNING_KEY=%s\n\n", *community, seed)
+	fmt.Printf("Peers trust this community with FEDERATION_TRUSTED_KEYS=%s=%s\n", *community, signer.PublicKey())
+	return nil
+}
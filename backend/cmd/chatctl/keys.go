@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"community-chatbot/internal/auth"
+)
+
+// runKeys manages JWT signing keys: keys list | keys rotate -scope S | keys revoke -kid K
+func runKeys(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected list, rotate or revoke")
+	}
+
+	fs := flag.NewFlagSet("keys "+args[0], flag.ExitOnError)
+	scope := fs.String("scope", auth.ScopeAccess, "key scope to rotate (access, refresh or embed)")
+	kid := fs.String("kid", "", "key ID to revoke")
+	fs.Parse(args[1:])
+
+	cfg, db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	keyring := auth.NewKeyring(db, cfg.Auth.KeyGracePeriod)
+	ctx := context.Background()
+
+	switch args[0] {
+	case "list":
+		keys, err := keyring.List(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "KID\tSCOPE\tSTATUS\tCREATED")
+		for _, key := range keys {
+			status := "active"
+			switch {
+			case key.RevokedAt != nil:
+				status = "revoked"
+			case key.RetiredAt != nil && key.AcceptedAt(time.Now(), cfg.Auth.KeyGracePeriod):
+				status = "retired (in grace window)"
+			case key.RetiredAt != nil:
+				status = "retired"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", key.KID, key.Scope, status, key.CreatedAt.Format(time.RFC3339))
+		}
+		return w.Flush()
+	case "rotate":
+		key, err := keyring.Rotate(ctx, *scope)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rotated %s key: new kid %s (previous keys accepted for %s)\n", key.Scope, key.KID, cfg.Auth.KeyGracePeriod)
+		return nil
+	case "revoke":
+		if *kid == "" {
+			return fmt.Errorf("-kid is required")
+		}
+		if err := keyring.Revoke(ctx, *kid); err != nil {
+			return err
+		}
+		fmt.Printf("Revoked key %s\n", *kid)
+		return nil
+	default:
+		return fmt.Errorf("unknown keys command %q", args[0])
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// chatctl is the operator CLI for maintenance tasks that should not be exposed over HTTP
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "replay":
+		err = runReplay(os.Args[2:])
+	case "digest":
+		err = runDigest(os.Args[2:])
+	case "keys":
+		err = runKeys(os.Args[2:])
+	case "federation":
+		err = runFederation(os.Args[2:])
+	case "help", "-h", "--help":
+		usage()
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "chatctl %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: chatctl <command> [flags]
+
+Commands:
+  replay      Replay stored conversations through the chat pipeline and report quality differences
+  digest      Send notification digests to users due this hour (run hourly)
+  keys        Manage JWT signing keys: list, rotate -scope access|refresh|embed, revoke -kid KID
+  federation  Generate a bundle signing key: keygen -community NAME
+
+Run "chatctl <command> -h" for command flags.`)
+}
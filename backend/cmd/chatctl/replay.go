@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/cache"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/config"
+	"community-chatbot/internal/handlers"
+	"community-chatbot/internal/llm"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+	"community-chatbot/internal/stream"
+
+	"gorm.io/gorm"
+)
+
+// replayConversation is a conversation of the replay input: one line of JSONL,
+// an element of a JSON array, or the active branch of a stored conversation
+type replayConversation struct {
+	ID       string          `json:"conversation_id"`
+	Messages []replayMessage `json:"messages"`
+}
+
+type replayMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// answerSignals are cheap quality signals compared between recorded and replayed answers
+type answerSignals struct {
+	Length    int      `json:"length"`
+	Citations int      `json:"citations"`
+	Flags     []string `json:"flags,omitempty"`
+}
+
+type replayTurn struct {
+	ConversationID string        `json:"conversation_id"`
+	Message        string        `json:"message"`
+	Baseline       answerSignals `json:"baseline"`
+	Candidate      answerSignals `json:"candidate"`
+	Answer         string        `json:"answer"`
+	Error          string        `json:"error,omitempty"`
+}
+
+type replaySummary struct {
+	Turns                 int     `json:"turns"`
+	Errors                int     `json:"errors"`
+	AvgLengthDelta        float64 `json:"avg_length_delta"`
+	BaselineCitationRate  float64 `json:"baseline_citation_rate"`
+	CandidateCitationRate float64 `json:"candidate_citation_rate"`
+	BaselineFlagged       int     `json:"baseline_flagged"`
+	CandidateFlagged      int     `json:"candidate_flagged"`
+}
+
+type replayReport struct {
+	GeneratedAt time.Time     `json:"generated_at"`
+	Input       string        `json:"input"`
+	Summary     replaySummary `json:"summary"`
+	Turns       []replayTurn  `json:"turns"`
+}
+
+var (
+	citationPattern = regexp.MustCompile(`activity://\d+|/activities/\d+`)
+	rawHTMLPattern  = regexp.MustCompile(`(?i)</?[a-z][^>]*>`)
+)
+
+// runReplay replays a sample of conversations offline against a candidate
+// prompt and model and writes a comparison report
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	input := fs.String("input", "", "JSONL file or JSON array of conversations ({conversation_id, messages:[{role, content}]}); stored conversations are sampled from the database when empty")
+	output := fs.String("out", "replay-report.json", "path of the JSON comparison report")
+	sample := fs.Int("sample", 50, "maximum number of conversations to replay (0 = all)")
+	seed := fs.Int64("seed", time.Now().UnixNano(), "random seed for sampling")
+	provider := fs.String("provider", "", "candidate model provider: openai, azure or ollama (default LLM_PROVIDER)")
+	model := fs.String("model", "", "candidate model or Azure deployment (default the provider's configured model; canned responses when OpenAI has no API key)")
+	promptFile := fs.String("prompt", "", "file with a candidate system prompt replacing the built-in one")
+	withStages := fs.Bool("stages", true, "run the read-only stages and tools of the server: guardrails, review highlights, activity tools and citation checks (needs the database)")
+	fs.Parse(args)
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if *provider != "" {
+		cfg.LLM.Provider = *provider
+	}
+	client, err := newReplayProvider(cfg, *model)
+	if err != nil {
+		return err
+	}
+
+	var db *gorm.DB
+	if *input == "" || *withStages {
+		if _, db, err = openDatabase(); err != nil {
+			if *input != "" {
+				return fmt.Errorf("%w (use -stages=false to replay without the database)", err)
+			}
+			return err
+		}
+	}
+
+	ctx := context.Background()
+	var conversations []replayConversation
+	if *input != "" {
+		conversations, err = loadConversations(*input)
+	} else {
+		conversations, err = loadStoredConversations(ctx, db, *sample, *seed)
+	}
+	if err != nil {
+		return err
+	}
+	if *sample > 0 && len(conversations) > *sample {
+		rng := rand.New(rand.NewSource(*seed))
+		rng.Shuffle(len(conversations), func(i, j int) {
+			conversations[i], conversations[j] = conversations[j], conversations[i]
+		})
+		conversations = conversations[:*sample]
+	}
+
+	// Replays run the pipeline directly and never open streams
+	chatHandler := handlers.NewChatHandler(client, stream.NewRegistry(), stream.NewReplays(0, 0), nil, nil, 0)
+	if *promptFile != "" {
+		prompt, err := os.ReadFile(*promptFile)
+		if err != nil {
+			return fmt.Errorf("failed to read candidate prompt: %w", err)
+		}
+		chatHandler.SetSystemPrompt(string(prompt))
+	}
+	if err := registerReplayStages(chatHandler, cfg, db, *withStages); err != nil {
+		return err
+	}
+	pipeline := chatHandler.Pipeline()
+
+	source := *input
+	if source == "" {
+		source = "database"
+	}
+	report := replayReport{GeneratedAt: time.Now(), Input: source}
+
+	for _, conv := range conversations {
+		var history []chat.Turn
+		for i := 0; i < len(conv.Messages); i++ {
+			user := conv.Messages[i]
+			if user.Role != "user" || i+1 == len(conv.Messages) || conv.Messages[i+1].Role != "assistant" {
+				history = append(history, chat.Turn{Role: user.Role, Content: user.Content})
+				continue
+			}
+			assistant := conv.Messages[i+1]
+
+			// Each turn is answered with the recorded conversation before it
+			run := chat.NewRun(user.Content, "replay")
+			run.History = slices.Clone(history)
+			turn := replayTurn{
+				ConversationID: conv.ID,
+				Message:        user.Content,
+				Baseline:       measureAnswer(assistant.Content),
+			}
+			if err := pipeline.Execute(ctx, run); err != nil {
+				turn.Error = err.Error()
+			}
+			turn.Answer = run.Response
+			turn.Candidate = measureAnswer(run.Response)
+			report.Turns = append(report.Turns, turn)
+
+			history = append(history,
+				chat.Turn{Role: user.Role, Content: user.Content},
+				chat.Turn{Role: assistant.Role, Content: assistant.Content})
+			i++
+		}
+	}
+
+	report.Summary = summarize(report.Turns)
+	if err := writeReport(*output, report); err != nil {
+		return err
+	}
+
+	s := report.Summary
+	fmt.Printf("Replayed %d turns from %d conversations (%d errors)\n", s.Turns, len(conversations), s.Errors)
+	fmt.Printf("  avg length delta:   %+.1f chars\n", s.AvgLengthDelta)
+	fmt.Printf("  citation coverage:  %.0f%% -> %.0f%%\n", s.BaselineCitationRate*100, s.CandidateCitationRate*100)
+	fmt.Printf("  flagged answers:    %d -> %d\n", s.BaselineFlagged, s.CandidateFlagged)
+	fmt.Printf("Report written to %s\n", *output)
+	return nil
+}
+
+// newReplayProvider creates the candidate model client of the configured
+// provider, or returns nil for canned responses when OpenAI has no API key
+func newReplayProvider(cfg *config.Config, model string) (llm.Provider, error) {
+	options := llm.Options{}
+	if cfg.LLM.Temperature >= 0 {
+		temperature := cfg.LLM.Temperature
+		options.Temperature = &temperature
+	}
+
+	switch cfg.LLM.Provider {
+	case "azure":
+		options.Model = cmp.Or(model, cfg.LLM.AzureDeployment)
+		options.BaseURL = cfg.LLM.AzureEndpoint
+		return llm.NewAzureOpenAIClient(cfg.LLM.AzureAPIKey, cfg.LLM.AzureAPIVersion, options), nil
+	case "ollama":
+		options.Model = cmp.Or(model, cfg.LLM.OllamaModel)
+		options.BaseURL = cfg.LLM.OllamaURL
+		return llm.NewOllamaClient(options), nil
+	case "openai":
+		if cfg.OpenAI.APIKey == "" {
+			log.Println("Warning: OPENAI_API_KEY not set, replaying with canned responses")
+			return nil, nil
+		}
+		options.Model = cmp.Or(model, cfg.OpenAI.Model)
+		options.BaseURL = cfg.OpenAI.BaseURL
+		return llm.NewOpenAIClient(cfg.OpenAI.APIKey, options), nil
+	}
+	return nil, fmt.Errorf("unknown provider %q, use openai, azure or ollama", cfg.LLM.Provider)
+}
+
+// registerReplayStages registers the stages of the server that shape answers.
+// Stages and tools that store or count anything are left out, so replays do
+// not change the database.
+func registerReplayStages(chatHandler *handlers.ChatHandler, cfg *config.Config, db *gorm.DB, withStages bool) error {
+	if !withStages {
+		chatHandler.Pipeline().Register(chat.OrderPostProcess, chat.PostProcessStage(cfg.Server.FrontendURL, nil))
+		return nil
+	}
+
+	// Nearby searches are cached in memory, apart from the server's cache
+	store, err := cache.NewStore("", cfg.Cache.MaxEntries)
+	if err != nil {
+		return fmt.Errorf("failed to create cache store: %w", err)
+	}
+	activityService := services.NewActivityService(db, cache.NewVersioned(store, cache.NamespaceNearby, cfg.Cache.NearbyTTL, 0), nil)
+
+	pipeline := chatHandler.Pipeline()
+	pipeline.Register(chat.OrderModeration, chat.GuardrailStage(services.NewGuardrailService(db).Guardrails))
+	pipeline.Register(chat.OrderRetrieval, chat.ReviewHighlightsStage(services.NewReviewService(db, services.LexiconAnalyzer{}).HighlightNotes))
+	pipeline.Register(chat.OrderPostProcess, chat.PostProcessStage(cfg.Server.FrontendURL, activityService.ExistingIDs))
+
+	for _, tool := range services.NewChatTools(db, activityService, nil, cfg.Content.StaleAfter).Tools() {
+		chatHandler.Tools().Register(tool)
+	}
+	chatHandler.Tools().Register(services.NewCollectionService(db, cfg.Content.StaleAfter).Tool())
+	return nil
+}
+
+// loadConversations reads the replay input, a JSON array or JSONL
+func loadConversations(path string) ([]replayConversation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input: %w", err)
+	}
+
+	var conversations []replayConversation
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &conversations); err != nil {
+			return nil, fmt.Errorf("invalid conversations: %w", err)
+		}
+		return conversations, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var conv replayConversation
+		if err := json.Unmarshal(scanner.Bytes(), &conv); err != nil {
+			return nil, fmt.Errorf("invalid conversation on line %d: %w", line, err)
+		}
+		conversations = append(conversations, conv)
+	}
+	return conversations, scanner.Err()
+}
+
+// loadStoredConversations samples up to sample stored conversations (0 = all)
+// and returns the messages of their active branch, oldest first. The same seed
+// samples the same conversations.
+func loadStoredConversations(ctx context.Context, db *gorm.DB, sample int, seed int64) ([]replayConversation, error) {
+	query := db.WithContext(ctx).
+		Where("current_message_id IS NOT NULL").
+		Order(gorm.Expr("md5(id || ?)", strconv.FormatInt(seed, 10)))
+	if sample > 0 {
+		query = query.Limit(sample)
+	}
+	var stored []models.Conversation
+	if err := query.Find(&stored).Error; err != nil {
+		return nil, fmt.Errorf("failed to sample conversations: %w", err)
+	}
+
+	conversations := make([]replayConversation, 0, len(stored))
+	for _, conversation := range stored {
+		var messages []models.Message
+		if err := db.WithContext(ctx).Where("conversation_id = ?", conversation.ID).Find(&messages).Error; err != nil {
+			return nil, fmt.Errorf("failed to load messages of conversation %s: %w", conversation.ID, err)
+		}
+		byID := make(map[uint]models.Message, len(messages))
+		for _, message := range messages {
+			byID[message.ID] = message
+		}
+
+		// The active branch runs back from the current message through the parents
+		conv := replayConversation{ID: conversation.ID}
+		for id := conversation.CurrentMessageID; id != nil; {
+			message, ok := byID[*id]
+			if !ok {
+				break
+			}
+			conv.Messages = append(conv.Messages, replayMessage{Role: message.Role, Content: message.Content})
+			id = message.ParentID
+		}
+		slices.Reverse(conv.Messages)
+		conversations = append(conversations, conv)
+	}
+	return conversations, nil
+}
+
+// measureAnswer computes the quality signals for one answer
+func measureAnswer(answer string) answerSignals {
+	signals := answerSignals{
+		Length:    len([]rune(answer)),
+		Citations: len(citationPattern.FindAllString(answer, -1)),
+	}
+	if strings.TrimSpace(answer) == "" {
+		signals.Flags = append(signals.Flags, "empty")
+	}
+	if rawHTMLPattern.MatchString(answer) {
+		signals.Flags = append(signals.Flags, "raw_html")
+	}
+	return signals
+}
+
+// summarize aggregates per-turn signals into the report summary
+func summarize(turns []replayTurn) replaySummary {
+	summary := replaySummary{Turns: len(turns)}
+	if len(turns) == 0 {
+		return summary
+	}
+
+	var lengthDelta, baselineCited, candidateCited int
+	for _, t := range turns {
+		if t.Error != "" {
+			summary.Errors++
+		}
+		lengthDelta += t.Candidate.Length - t.Baseline.Length
+		if t.Baseline.Citations > 0 {
+			baselineCited++
+		}
+		if t.Candidate.Citations > 0 {
+			candidateCited++
+		}
+		if len(t.Baseline.Flags) > 0 {
+			summary.BaselineFlagged++
+		}
+		if len(t.Candidate.Flags) > 0 {
+			summary.CandidateFlagged++
+		}
+	}
+
+	n := float64(len(turns))
+	summary.AvgLengthDelta = float64(lengthDelta) / n
+	summary.BaselineCitationRate = float64(baselineCited) / n
+	summary.CandidateCitationRate = float64(candidateCited) / n
+	return summary
+}
+
+func writeReport(path string, report replayReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
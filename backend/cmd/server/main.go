@@ -1,22 +1,55 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"community-chatbot/internal/apidocs"
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/auth"
+	"community-chatbot/internal/auth/oauth"
+	"community-chatbot/internal/cache"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/climate"
 	"community-chatbot/internal/config"
+	"community-chatbot/internal/dbmetrics"
+	"community-chatbot/internal/federation"
+	"community-chatbot/internal/geocode"
 	"community-chatbot/internal/handlers"
+	"community-chatbot/internal/llm"
+	"community-chatbot/internal/lock"
+	"community-chatbot/internal/mailer"
 	"community-chatbot/internal/middleware"
 	"community-chatbot/internal/models"
+	"community-chatbot/internal/moderation"
+	"community-chatbot/internal/ratelimit"
+	"community-chatbot/internal/residency"
+	"community-chatbot/internal/scheduler"
+	"community-chatbot/internal/services"
+	"community-chatbot/internal/storage"
+	"community-chatbot/internal/stream"
+	"community-chatbot/internal/telemetry"
+	"community-chatbot/internal/transit"
+	"community-chatbot/internal/upload"
+	"community-chatbot/internal/weather"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -28,8 +61,11 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Initialize database
-	db, err := initDatabase(cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Initialize database, retrying while Postgres starts up
+	db, err := connectDatabase(ctx, cfg)
 	if err != nil {
 		if cfg.Server.Environment == "development" {
 			log.Printf("Warning: Database connection failed (continuing in dev mode): %v", err)
@@ -39,51 +75,183 @@ func main() {
 		}
 	}
 
-	// Initialize Fiber app
+	// Anonymous usage telemetry (opt-in)
+	var collector *telemetry.Collector
+	if cfg.Telemetry.Enabled {
+		instanceID := cfg.Telemetry.InstanceID
+		if instanceID == "" {
+			instanceID = uuid.New().String()
+		}
+		collector = telemetry.NewCollector(instanceID, cfg.Telemetry.Endpoint)
+		go collector.Start(ctx, cfg.Telemetry.Interval)
+		log.Printf("Anonymous usage telemetry enabled (reporting to %s)", cfg.Telemetry.Endpoint)
+	}
+
+	// Streaming connections are tracked so idle ones are reaped instead of leaking goroutines
+	connections := stream.NewRegistry()
+	go connections.StartReaper(ctx, cfg.Streams.ReapInterval, cfg.Streams.IdleTimeout)
+
+	app := newApp(ctx, db, cfg, collector, connections)
+
+	// In dev mode without a database, requests are handed to an app with all
+	// routes once Postgres comes up
+	if db == nil {
+		var recovered atomic.Value
+		serve := app.Server().Handler
+		app.Server().Handler = func(rctx *fasthttp.RequestCtx) {
+			if handler, ok := recovered.Load().(fasthttp.RequestHandler); ok {
+				handler(rctx)
+				return
+			}
+			serve(rctx)
+		}
+		go func() {
+			db, err := reconnectDatabase(ctx, cfg)
+			if err != nil {
+				return
+			}
+			recovered.Store(newApp(ctx, db, cfg, collector, connections).Handler())
+			log.Println("Database connected, serving all routes")
+		}()
+	}
+
+	// Start server
+	port := fmt.Sprintf(":%d", cfg.Server.Port)
+	log.Printf("Starting server on port %d", cfg.Server.Port)
+
+	// With prefork this process only supervises the workers; it passes the
+	// shutdown signal on to them, since service managers only signal this one
+	var workers sync.Map
+	if cfg.Server.Prefork && !fiber.IsChild() {
+		app.Hooks().OnFork(func(pid int) error {
+			workers.Store(pid, true)
+			return nil
+		})
+	}
+
+	// Graceful shutdown
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-c
+		log.Println("Gracefully shutting down...")
+		workers.Range(func(pid, _ interface{}) bool {
+			syscall.Kill(pid.(int), syscall.SIGTERM)
+			return true
+		})
+		// Open streams would otherwise keep Shutdown waiting
+		connections.CloseAll()
+		app.Shutdown()
+	}()
+
+	if err := app.Listen(port); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
+
+// newApp creates the Fiber app with its middleware and routes
+func newApp(ctx context.Context, db *gorm.DB, cfg *config.Config, collector *telemetry.Collector, connections *stream.Registry) *fiber.App {
 	app := fiber.New(fiber.Config{
-		AppName: "Community Chatbot API",
+		AppName:      "Community Chatbot API",
+		Prefork:      cfg.Server.Prefork,
+		BodyLimit:    cfg.Server.BodyLimit,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		Concurrency:  cfg.Server.Concurrency,
+		// Service errors are mapped by kind; only their message for clients is returned
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
 			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
+				return c.Status(e.Code).JSON(models.CreateErrorResponse(e.Message))
+			}
+			kind := apperr.KindOf(err)
+			if kind == apperr.Internal {
+				log.Printf("[ERROR] %s %s (request %s): %v", c.Method(), c.Path(), middleware.GetRequestID(c), err)
 			}
-			return c.Status(code).JSON(models.CreateErrorResponse(err.Error()))
+			return c.Status(kind.Status()).JSON(models.CreateErrorResponse(apperr.Message(err)))
 		},
 	})
 
+	// Request logs are sampled per route; admins can change the rules at runtime
+	logSampler := middleware.NewLogSampler(models.LogSamplingPolicy{
+		DefaultRate:     cfg.Logging.SampleRate,
+		Rules:           cfg.Logging.SampleRules,
+		SlowThresholdMS: int(cfg.Logging.SlowRequest / time.Millisecond),
+	})
+	requestLogging := middleware.DefaultRequestLoggingConfig
+	requestLogging.Sampler = logSampler
+
 	// Middleware
 	app.Use(recover.New())
-	app.Use(middleware.RequestLogging())
+	app.Use(middleware.RequestID())
+	app.Use(middleware.RequestLogging(requestLogging))
 	app.Use(middleware.EventSourceLogging())
 	app.Use(middleware.RateLimitLogging())
-	app.Use(logger.New())
+	app.Use(logger.New(logger.Config{
+		Next:   func(c *fiber.Ctx) bool { return !middleware.LogSampled(c) },
+		Format: "${time} | ${status} | ${latency} | ${ip} | ${method} | ${path} | ${locals:request_id} | ${error}\n",
+	}))
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     cfg.CORS.AllowOrigins,
 		AllowMethods:     cfg.CORS.AllowMethods,
 		AllowHeaders:     cfg.CORS.AllowHeaders,
 		AllowCredentials: true,
-		ExposeHeaders:    "Content-Type,Cache-Control,Connection",
+		ExposeHeaders:    "Content-Type,Cache-Control,Connection," + middleware.RequestIDHeader + "," + middleware.SessionHeader,
 	}))
+	if collector != nil {
+		app.Use(collector.Middleware())
+	}
 
 	// Setup routes
-	setupRoutes(app, db)
+	setupRoutes(ctx, app, db, cfg, collector, connections, logSampler)
+	return app
+}
 
-	// Start server
-	port := fmt.Sprintf(":%d", cfg.Server.Port)
-	log.Printf("Starting server on port %d", cfg.Server.Port)
+// connectDatabase initializes the database, retrying with exponential backoff
+// up to DB_CONNECT_ATTEMPTS times
+func connectDatabase(ctx context.Context, cfg *config.Config) (*gorm.DB, error) {
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		db, err := initDatabase(cfg)
+		if err == nil || attempt >= cfg.Database.ConnectAttempts {
+			return db, err
+		}
 
-	// Graceful shutdown
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		log.Printf("Database connection attempt %d/%d failed, retrying in %s: %v", attempt, cfg.Database.ConnectAttempts, backoff, err)
+		if err := sleepContext(ctx, backoff); err != nil {
+			return nil, err
+		}
+		backoff = min(backoff*2, cfg.Database.RetryMaxBackoff)
+	}
+}
 
-	go func() {
-		<-c
-		log.Println("Gracefully shutting down...")
-		app.Shutdown()
-	}()
+// reconnectDatabase keeps trying to initialize the database with exponential
+// backoff until it succeeds or ctx is cancelled
+func reconnectDatabase(ctx context.Context, cfg *config.Config) (*gorm.DB, error) {
+	backoff := time.Second
+	for {
+		if err := sleepContext(ctx, backoff); err != nil {
+			return nil, err
+		}
+		db, err := initDatabase(cfg)
+		if err == nil {
+			return db, nil
+		}
+		backoff = min(backoff*2, cfg.Database.RetryMaxBackoff)
+		log.Printf("Database still unavailable, retrying in %s: %v", backoff, err)
+	}
+}
 
-	if err := app.Listen(port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+// sleepContext waits for d, returning early with the context's error when it is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
 }
 
@@ -94,29 +262,570 @@ func initDatabase(cfg *config.Config) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	// Auto-migrate models
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to access connection pool: %w", err)
+	}
+
+	// Communities with data residency requirements are stored in regional
+	// databases; statements go to the region their context is bound to
+	pools := []*sql.DB{sqlDB}
+	closeAll := func() {
+		for _, pool := range pools {
+			pool.Close()
+		}
+	}
+	if len(cfg.Residency.Regions) > 0 {
+		regional := make(map[string]*sql.DB)
+		for _, region := range cfg.Residency.Regions {
+			regionDB, err := gorm.Open(postgres.Open(region.DatabaseURL), &gorm.Config{})
+			if err == nil {
+				regional[region.Name], err = regionDB.DB()
+			}
+			if err != nil {
+				closeAll()
+				return nil, fmt.Errorf("failed to connect to database of region %s: %w", region.Name, err)
+			}
+			pools = append(pools, regional[region.Name])
+		}
+		pool := residency.NewPool(sqlDB, regional)
+		db.ConnPool = pool
+		db.Statement.ConnPool = pool
+	}
+	for _, pool := range pools {
+		pool.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+		pool.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+		pool.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+	}
+
+	// Query counts, rows and latency per model are exported on /metrics
+	if err := db.Use(dbmetrics.New()); err != nil {
+		closeAll()
+		return nil, fmt.Errorf("failed to register database metrics: %w", err)
+	}
+
+	router, err := newResidencyRouter(cfg)
+	if err != nil {
+		closeAll()
+		return nil, err
+	}
+	for _, regionCtx := range regionContexts(context.Background(), router) {
+		if err := migrateDatabase(db.WithContext(regionCtx)); err != nil {
+			closeAll()
+			if region := residency.Region(regionCtx); region != "" {
+				return nil, fmt.Errorf("region %s: %w", region, err)
+			}
+			return nil, err
+		}
+	}
+
+	log.Println("Database connected and migrated successfully")
+	return db, nil
+}
+
+// migrateDatabase migrates the models and backfills derived data
+func migrateDatabase(db *gorm.DB) error {
+	if err := services.MigrateMessageBranches(db); err != nil {
+		return err
+	}
+	if err := services.DedupeReviews(db); err != nil {
+		return err
+	}
 	if err := db.AutoMigrate(
 		&models.Activity{},
+		&models.Category{},
 		&models.Image{},
 		&models.Route{},
+		&models.DifficultyCalibration{},
+		&models.Itinerary{},
+		&models.Review{},
+		&models.Conversation{},
+		&models.Message{},
+		&models.ConditionReport{},
+		&models.Notification{},
+		&models.Comment{},
+		&models.Favorite{},
+		&models.SavedSearch{},
+		&models.Collection{},
+		&models.CollectionItem{},
+		&models.CollectionFollow{},
+		&models.ShortLink{},
+		&models.ActivityScan{},
+		&models.ActivityStat{},
+		&models.ChecklistOverride{},
+		&models.SigningKey{},
+		&models.EmbedKey{},
+		&models.APIKey{},
+		&models.LocationHistory{},
 		&models.User{},
 		&models.UserPreferences{},
+		&models.Consent{},
+		&models.Invitation{},
+		&models.EmailChange{},
+		&models.OAuthIdentity{},
+		&models.EmailVerification{},
+		&models.PasswordReset{},
+		&models.Session{},
+		&models.GuardrailPolicy{},
+		&models.LogSamplingPolicy{},
+		&models.LLMUsage{},
+		&models.AbuseSignal{},
 	); err != nil {
-		return nil, fmt.Errorf("failed to migrate database: %w", err)
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	if err := services.EnsureSearchIndex(db); err != nil {
+		return err
+	}
+	return services.BackfillRatings(db)
+}
+
+// newResidencyRouter creates the router of communities to regions, or returns
+// nil when no regions are configured and all data stays in one database
+func newResidencyRouter(cfg *config.Config) (*residency.Router, error) {
+	if len(cfg.Residency.Regions) == 0 {
+		return nil, nil
+	}
+	var regions []string
+	for _, region := range cfg.Residency.Regions {
+		regions = append(regions, region.Name)
+	}
+	router, err := residency.NewRouter(cfg.Residency.DefaultRegion, regions, cfg.Residency.Communities)
+	if err != nil {
+		return nil, fmt.Errorf("invalid data residency configuration: %w", err)
 	}
+	return router, nil
+}
 
-	log.Println("Database connected and migrated successfully")
-	return db, nil
+// regionContexts returns ctx bound to each region for background jobs, or ctx
+// alone without data residency
+func regionContexts(ctx context.Context, router *residency.Router) []context.Context {
+	if router == nil {
+		return []context.Context{ctx}
+	}
+	return router.Contexts(ctx)
 }
 
 // setupRoutes configures all API routes
-func setupRoutes(app *fiber.App, db *gorm.DB) {
-	// Chat handler (works without database)
-	chatHandler := handlers.NewChatHandler()
+func setupRoutes(ctx context.Context, app *fiber.App, db *gorm.DB, cfg *config.Config, collector *telemetry.Collector, connections *stream.Registry, logSampler *middleware.LogSampler) {
+	// Configuration is validated at startup, so this only fails on programming errors
+	router, err := newResidencyRouter(cfg)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	// Chat handler (works without database; canned responses without a model provider)
+	llmProvider, llmModel := newLLMProvider(cfg)
+	// Recent stream events are buffered so clients that lose the connection can resume
+	replays := stream.NewReplays(cfg.Streams.ReplayEvents, cfg.Streams.ResumeWindow)
+	// Maintenance jobs take turns across replicas through the scheduler lock backend
+	locker, err := lock.New(cfg.Scheduler.LockBackend, db, cfg.Cache.RedisURL, cfg.Scheduler.LockTTL)
+	if err != nil {
+		log.Fatalf("Failed to create scheduler lock: %v", err)
+	}
+	jobs := scheduler.New(locker)
+	jobs.Add(scheduler.Job{
+		Name:     "stream_replay_cleanup",
+		Interval: cfg.Streams.ResumeWindow,
+		Local:    true,
+		Run: func(context.Context) error {
+			replays.Expire()
+			return nil
+		},
+	})
+	// Concurrent generations are capped to stay within the provider's limits; see the llm_generations_* metrics
+	limiter := chat.NewLimiter(cfg.OpenAI.MaxConcurrent, cfg.OpenAI.MaxQueued, cfg.OpenAI.QueueTimeout)
+	// Runs created for EventSource clients wait for their stream in the shared cache store
+	runStore, err := cache.NewTaker(cfg.Cache.RedisURL, cfg.Cache.MaxEntries)
+	if err != nil {
+		log.Fatalf("Failed to create cache store: %v", err)
+	}
+	runs, err := stream.NewRunTokens(runStore, cfg.Streams.RunTokenSecret, cfg.Streams.RunTokenTTL)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	chatHandler := handlers.NewChatHandler(llmProvider, connections, replays, limiter, runs, cfg.Server.StreamKeepalive)
+
+	// Nearby searches are cached until an activity changes, so all users of activities share one service.
+	// Submissions of trusted contributors are published without pre-moderation.
+	var activityService *services.ActivityService
+	var activityStats *services.ActivityStatsService
+	var trustService *services.TrustService
+	var interestClaims cache.Claimer
+	if db != nil {
+		trustService = services.NewTrustService(db, services.TrustThresholds{
+			Member:     cfg.Trust.MemberContributions,
+			Trusted:    cfg.Trust.TrustedContributions,
+			Violations: cfg.Trust.DemotionViolations,
+		})
+		nearbyStore, err := cache.NewStore(cfg.Cache.RedisURL, cfg.Cache.MaxEntries)
+		if err != nil {
+			log.Fatalf("Failed to create cache store: %v", err)
+		}
+		activityService = services.NewActivityService(db,
+			cache.NewVersioned(nearbyStore, cache.NamespaceNearby, cfg.Cache.NearbyTTL, 0), trustService)
+		// Views and short link scans are counted once per client and day, so they cannot be driven up
+		interestClaims, err = cache.NewClaimer(cfg.Cache.RedisURL, cfg.Cache.MaxEntries)
+		if err != nil {
+			log.Fatalf("Failed to create interest dedupe store: %v", err)
+		}
+		// Views, favorites, chat mentions, scans and reviews of the last days rank
+		// trending activities, listings sorted by popular and the forecasts refreshed below
+		activityStats = services.NewActivityStatsService(db, interestClaims, cfg.Content.StaleAfter)
+		jobs.Add(scheduler.Job{
+			Name:     "activity_popularity",
+			Interval: cfg.Scheduler.PopularityInterval,
+			Run: func(ctx context.Context) error {
+				for _, regionCtx := range regionContexts(ctx, router) {
+					if _, err := activityStats.RecomputePopularity(regionCtx); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		})
+		chatHandler.Pipeline().Register(chat.OrderLogging, activityStats.Stage())
+	}
+
+	// Answer post-processing: cited activities can only be validated with a database
+	var validateActivities chat.ActivityValidator
+	if db != nil {
+		validateActivities = activityService.ExistingIDs
+	}
+	chatHandler.Pipeline().Register(chat.OrderPostProcess, chat.PostProcessStage(cfg.Server.FrontendURL, validateActivities))
+	if collector != nil {
+		chatHandler.Pipeline().Register(chat.OrderLogging, collector.Stage())
+	}
+	// Token usage is reported to clients and stored for cost monitoring
+	usageService := services.NewUsageService(db, llmModel, cfg.OpenAI.PromptPrice, cfg.OpenAI.CompletionPrice)
+	if llmProvider != nil {
+		chatHandler.Pipeline().Register(chat.OrderLogging, usageService.Stage())
+	}
+
+	// Authentication: JWT signing keys are shared by all replicas through the database.
+	// Without a database, every request is anonymous.
+	var keyring *auth.Keyring
+	var tokens *auth.TokenIssuer
+	identify := func(c *fiber.Ctx) error { return c.Next() }
+	if db != nil {
+		keyring = auth.NewKeyring(db, cfg.Auth.KeyGracePeriod)
+		if err := keyring.Init(ctx); err != nil {
+			log.Fatalf("Failed to initialize signing keys: %v", err)
+		}
+		go keyring.Start(ctx, cfg.Auth.KeyReloadInterval)
+		tokens = auth.NewTokenIssuer(keyring, cfg.Auth.Issuer, cfg.Auth.AccessTokenTTL, cfg.Auth.RefreshTokenTTL)
+		identify = middleware.OptionalAuth(tokens, services.TokenRevocations(db))
+	}
+
+	// Log sampling changed by admins is shared by all replicas through the database
+	logSamplingService := services.NewLogSamplingService(db, logSampler)
+	if db != nil {
+		if err := logSamplingService.Reload(ctx); err != nil {
+			log.Printf("[LOGGING] %v", err)
+		}
+		go logSamplingService.StartReload(ctx, cfg.Logging.SamplingReloadInterval)
+	}
+
+	// Daily message quotas; guests over theirs are asked to sign in when accounts exist
+	if cfg.Chat.GuestDailyMessages > 0 || cfg.Chat.UserDailyMessages > 0 {
+		counter, err := ratelimit.NewCounter(cfg.Cache.RedisURL)
+		if err != nil {
+			log.Fatalf("Failed to create quota counter: %v", err)
+		}
+		quotas := services.NewQuotaService(counter, cfg.Chat.GuestDailyMessages, cfg.Chat.UserDailyMessages, tokens != nil)
+		chatHandler.Pipeline().Register(chat.OrderQuota, quotas.Stage())
+	}
+
+	// A message its client sent moments ago, e.g. by double-clicking send, is not answered twice
+	if cfg.Chat.DedupeWindow > 0 {
+		claims, err := cache.NewClaimer(cfg.Cache.RedisURL, cfg.Chat.DedupeMaxEntries)
+		if err != nil {
+			log.Fatalf("Failed to create dedupe store: %v", err)
+		}
+		chatHandler.Pipeline().Register(chat.OrderDedupe, services.NewDedupeGuard(claims, cfg.Chat.DedupeWindow).Stage())
+	}
+
+	// Clients sending messages faster than people type are slowed down, then blocked;
+	// penalties are recorded as abuse signals for admins
+	if cfg.Chat.FloodSlowdownPerMinute > 0 || cfg.Chat.FloodBlockPerMinute > 0 {
+		counter, err := ratelimit.NewCounter(cfg.Cache.RedisURL)
+		if err != nil {
+			log.Fatalf("Failed to create flood counter: %v", err)
+		}
+		blocks, err := cache.NewStore(cfg.Cache.RedisURL, cfg.Cache.MaxEntries)
+		if err != nil {
+			log.Fatalf("Failed to create cache store: %v", err)
+		}
+		var signals services.AbuseSignalRecorder
+		if db != nil {
+			signals = services.NewAbuseSignalService(db)
+		}
+		flood := services.NewFloodGuard(counter, blocks, services.FloodLimits{
+			SlowdownAfter: cfg.Chat.FloodSlowdownPerMinute,
+			MaxDelay:      cfg.Chat.FloodMaxDelay,
+			BlockAfter:    cfg.Chat.FloodBlockPerMinute,
+			BlockDuration: cfg.Chat.FloodBlockDuration,
+		}, signals)
+		chatHandler.Pipeline().Register(chat.OrderDedupe, flood.Stage())
+	}
+
+	// Conversation memory is stored with the messages, or kept in memory without a database
+	var summarize chat.Summarizer
+	if llmProvider != nil {
+		summarize = services.NewConversationSummarizer(llmProvider).Summarize
+	}
+	compression := chat.CompressionConfig{
+		VerbatimTurns: cfg.Chat.VerbatimTurns,
+		TokenBudget:   cfg.Chat.HistoryTokenBudget,
+	}
+	if db == nil {
+		memory := chat.NewMemoryBuffer(cfg.Chat.MemoryMaxTurns, cfg.Chat.MemoryMaxConversations, cfg.Chat.MemoryTTL)
+		chatHandler.Pipeline().Register(chat.OrderPersistence, chat.CompressionStage(memory, summarize, compression))
+		chatHandler.Pipeline().Register(chat.OrderPersistence, chat.PersistenceStage(memory.Record, memory.Complete, nil))
+		log.Printf("Conversation memory is kept in process memory (no database)")
+	}
+
+	var sessions *services.SessionService
+	if db != nil {
+		conversations := services.NewConversationService(db)
+		// History is loaded before persistence stores the current message
+		chatHandler.Pipeline().Register(chat.OrderPersistence, chat.CompressionStage(conversations, summarize, compression))
+		chatHandler.Pipeline().Register(chat.OrderPersistence, chat.PersistenceStage(conversations.AddMessage, conversations.CompleteMessage, conversations.RecordEdit))
+		// Anonymous users resume their conversation and chat context through sessions
+		sessions = services.NewSessionService(db, cfg.Session.TTL)
+		jobs.Add(scheduler.Job{
+			Name:     "session_cleanup",
+			Interval: cfg.Scheduler.SessionCleanupInterval,
+			Run: func(ctx context.Context) error {
+				for _, regionCtx := range regionContexts(ctx, router) {
+					deleted, err := sessions.DeleteExpired(regionCtx)
+					if err != nil {
+						return err
+					}
+					if deleted > 0 {
+						log.Printf("[SESSION] Deleted %d expired sessions", deleted)
+					}
+				}
+				return nil
+			},
+		})
+		// Guest conversations are only pruned when a retention is configured
+		if retention := cfg.Scheduler.GuestConversationRetention; retention > 0 {
+			jobs.Add(scheduler.Job{
+				Name:     "conversation_prune",
+				Interval: cfg.Scheduler.ConversationPruneInterval,
+				Run: func(ctx context.Context) error {
+					for _, regionCtx := range regionContexts(ctx, router) {
+						deleted, err := conversations.PruneAnonymous(regionCtx, time.Now().Add(-retention))
+						if err != nil {
+							return err
+						}
+						if deleted > 0 {
+							log.Printf("[CONVERSATION] Pruned %d guest conversations", deleted)
+						}
+					}
+					return nil
+				},
+			})
+		}
+		// Operator topic rules; blocked messages are refused before personalization and retrieval
+		chatHandler.Pipeline().Register(chat.OrderModeration, chat.GuardrailStage(services.NewGuardrailService(db).Guardrails))
+		chatHandler.Pipeline().Register(chat.OrderPersonalize, sessions.Stage())
+		// Location history records request locations, so it is registered before personalization adds saved ones
+		chatHandler.Pipeline().Register(chat.OrderPersonalize, chat.LocationHistoryStage(services.NewLocationHistoryService(db).Record))
+		chatHandler.Pipeline().Register(chat.OrderPersonalize, chat.PersonalizationStage(services.NewPreferencesService(db).ChatContext))
+		chatHandler.Pipeline().Register(chat.OrderPersonalize, chat.ProfilingStage(services.NewPreferencesService(db).NextProfileQuestion))
+	}
+
+	// Place names are geocoded for searches near a named place; lookups are cached
+	var geocodeService *services.GeocodeService
+	if cfg.Geocode.Provider != "none" {
+		geocodeStore, err := cache.NewStore(cfg.Cache.RedisURL, cfg.Cache.MaxEntries)
+		if err != nil {
+			log.Fatalf("Failed to create cache store: %v", err)
+		}
+		geocodeService = services.NewGeocodeService(
+			geocode.NewNominatimClient(cfg.Geocode.URL, cfg.Geocode.UserAgent, cfg.Geocode.MinInterval),
+			cache.New(geocodeStore, cache.NamespaceGeocode, cfg.Cache.GeocodeTTL, cfg.Cache.NegativeTTL))
+	}
+
+	// Database lookups the model can call while answering
+	var similarity *services.SimilarityService
+	if db != nil {
+		for _, tool := range services.NewChatTools(db, activityService, geocodeService, cfg.Content.StaleAfter).Tools() {
+			chatHandler.Tools().Register(tool)
+		}
+		// Curated collections are recommended before searching all activities
+		chatHandler.Tools().Register(services.NewCollectionService(db, cfg.Content.StaleAfter).Tool())
+		chatHandler.Tools().Register(activityStats.Tool())
+		// Alternatives to an activity leave out the ones the user already saved
+		similarity = services.NewSimilarityService(db, services.SimilarityWeights{
+			Content:       cfg.Similar.ContentWeight,
+			Proximity:     cfg.Similar.ProximityWeight,
+			MaxDistanceKM: cfg.Similar.MaxDistanceKM,
+		}, cfg.Content.StaleAfter)
+		chatHandler.Tools().Register(similarity.Tool())
+	}
+
+	// Semantic search embeds activities in the background with the provider's embedding model
+	var semanticSearch *services.SemanticSearchService
+	if db != nil && cfg.Semantic.Enabled {
+		if llmProvider != nil {
+			semanticSearch = newSemanticSearch(ctx, db, router, cfg, llmProvider)
+			if semanticSearch != nil {
+				chatHandler.Tools().Register(semanticSearch.Tool())
+				// Activities closest to each message are given to the model and shown as cards
+				if cfg.Semantic.RetrievalLimit > 0 {
+					chatHandler.Pipeline().Register(chat.OrderRetrieval, semanticSearch.Stage(cfg.Semantic.RetrievalLimit, cfg.Semantic.MinSimilarity))
+				}
+			}
+		} else {
+			log.Println("Warning: SEMANTIC_SEARCH_ENABLED requires a language model provider, semantic search is disabled")
+		}
+	}
+
+	// Printable itineraries are rendered in the background; the chat tool delivers the download link as an event
+	var itineraries *services.ItineraryService
+	if db != nil {
+		itineraries = services.NewItineraryService(db, services.NewRouteService(db), cfg.Server.PublicURL)
+		for _, regionCtx := range regionContexts(ctx, router) {
+			go itineraries.StartRendering(regionCtx, time.Minute)
+		}
+		chatHandler.Tools().Register(itineraries.Tool())
+	}
+
+	// Review analysis runs in the background; the chat quotes the resulting highlights
+	var reviewService *services.ReviewService
+	if db != nil {
+		var analyzer services.ReviewAnalyzer = services.LexiconAnalyzer{}
+		if cfg.Reviews.Analyzer == "llm" {
+			if llmProvider != nil {
+				analyzer = services.NewLLMReviewAnalyzer(llmProvider)
+			} else {
+				log.Println("Warning: REVIEW_ANALYZER=llm requires OPENAI_API_KEY, using lexicon analyzer")
+			}
+		}
+		reviewService = services.NewReviewService(db, analyzer)
+		for _, regionCtx := range regionContexts(ctx, router) {
+			go reviewService.StartAnalysis(regionCtx, cfg.Reviews.AnalysisInterval)
+		}
+		chatHandler.Pipeline().Register(chat.OrderRetrieval, chat.ReviewHighlightsStage(reviewService.HighlightNotes))
+	}
+
+	// Condition reports: messages reporting an activity's condition are offered to the user to file
+	var conditionService *services.ConditionService
+	if db != nil {
+		conditionService = services.NewConditionService(db, activityService, services.NewNotificationService(db, services.LogPushSender{}))
+		chatHandler.Pipeline().Register(chat.OrderRetrieval, conditionService.Stage())
+	}
+
+	// Community statistics are recounted in the background; the chat answers "how many trails" from them.
+	// Summaries of areas are cached instead, and answer "how much singletrack is around here".
+	var statsService *services.StatsService
+	if db != nil {
+		areaStore, err := cache.NewStore(cfg.Cache.RedisURL, cfg.Cache.MaxEntries)
+		if err != nil {
+			log.Fatalf("Failed to create cache store: %v", err)
+		}
+		statsService = services.NewStatsService(db, cache.New(areaStore, cache.NamespaceAreaStats, cfg.Cache.AreaStatsTTL, 0))
+		for _, regionCtx := range regionContexts(ctx, router) {
+			go statsService.StartRefresh(regionCtx, cfg.Content.StatsRefreshInterval)
+		}
+		chatHandler.Tools().Register(statsService.Tool())
+		chatHandler.Tools().Register(statsService.AreaTool())
+	}
+
+	// Public transit directions through OpenTripPlanner, cached briefly because departures move on
+	var transitService *services.TransitService
+	if db != nil {
+		var planner transit.Planner
+		if cfg.Transit.OTPURL != "" {
+			planner = transit.NewOTPClient(cfg.Transit.OTPURL)
+		} else {
+			log.Println("Warning: TRANSIT_OTP_URL not set, transit directions are disabled")
+		}
+		store, err := cache.NewStore(cfg.Cache.RedisURL, cfg.Cache.MaxEntries)
+		if err != nil {
+			log.Fatalf("Failed to create cache store: %v", err)
+		}
+		plans := cache.New(store, cache.NamespaceTransit, cfg.Cache.TransitTTL, cfg.Cache.NegativeTTL)
+		transitService = services.NewTransitService(db, planner, plans)
+		if planner != nil {
+			chatHandler.Pipeline().Register(chat.OrderRetrieval, chat.TransitStage(transitService.TransitNotes))
+			chatHandler.Tools().Register(transitService.Tool())
+		}
+	}
+
+	// Climate normals from downloaded weather history, cached for long since they change once a year
+	climateStore, err := cache.NewStore(cfg.Cache.RedisURL, cfg.Cache.MaxEntries)
+	if err != nil {
+		log.Fatalf("Failed to create cache store: %v", err)
+	}
+	climateService := services.NewClimateService(climate.NewOpenMeteoClient(cfg.Climate.ArchiveURL),
+		cache.New(climateStore, cache.NamespaceClimate, cfg.Cache.ClimateTTL, cfg.Cache.NegativeTTL), cfg.Climate.Years)
+	chatHandler.Tools().Register(climateService.Tool())
+
+	// Forecasts for the weather tool and nearby activities, cached briefly per grid cell
+	var weatherService *services.WeatherService
+	if cfg.Weather.Provider != "none" {
+		weatherStore, err := cache.NewStore(cfg.Cache.RedisURL, cfg.Cache.MaxEntries)
+		if err != nil {
+			log.Fatalf("Failed to create cache store: %v", err)
+		}
+		weatherService = services.NewWeatherService(weather.NewOpenMeteoClient(cfg.Weather.ForecastURL),
+			cache.New(weatherStore, cache.NamespaceWeather, cfg.Cache.WeatherTTL, cfg.Cache.NegativeTTL), db)
+		chatHandler.Tools().Register(weatherService.Tool())
+		if db != nil {
+			// Forecasts are warmed around popular activities and where users
+			// who opted in to location history search from
+			locationHistory := services.NewLocationHistoryService(db)
+			jobs.Add(scheduler.Job{
+				Name:     "weather_refresh",
+				Interval: cfg.Scheduler.WeatherRefreshInterval,
+				Run: func(ctx context.Context) error {
+					for _, regionCtx := range regionContexts(ctx, router) {
+						locations, err := weatherService.PopularLocations(regionCtx, cfg.Scheduler.WeatherRefreshActivities)
+						if err != nil {
+							return err
+						}
+						areas, err := locationHistory.SearchedAreas(regionCtx, cfg.Scheduler.WeatherRefreshAreas)
+						if err != nil {
+							return err
+						}
+						refreshed, err := weatherService.Refresh(regionCtx, append(locations, areas...))
+						if err != nil {
+							return err
+						}
+						log.Printf("[WEATHER] Refreshed %d forecasts around popular activities and searched areas", refreshed)
+					}
+					return nil
+				},
+			})
+		}
+	}
+
+	// Packing checklists from the activity and season, cached per season and adjusted to the forecast
+	var checklistService *services.ChecklistService
+	if db != nil {
+		checklistStore, err := cache.NewStore(cfg.Cache.RedisURL, cfg.Cache.MaxEntries)
+		if err != nil {
+			log.Fatalf("Failed to create cache store: %v", err)
+		}
+		checklistService = services.NewChecklistService(db, weatherService,
+			cache.New(checklistStore, cache.NamespaceChecklist, cfg.Cache.ChecklistTTL, cfg.Cache.NegativeTTL))
+		chatHandler.Tools().Register(checklistService.Tool())
+	}
+
+	// Daylight is calculated locally; activities are only looked up with a database
+	daylightService := services.NewDaylightService(db)
+	chatHandler.Tools().Register(daylightService.Tool())
+
+	// Health checks are cached briefly in memory, so frequent probes do not each ping the database;
+	// the result is this instance's, so it is not shared through Redis
+	healthChecks := cache.New(cache.NewMemoryStore(10), cache.NamespaceHealth, cfg.Cache.HealthTTL, 0)
 
 	// Health check (may fail if no database)
 	if db != nil {
-		healthHandler := handlers.NewHealthHandler(db)
+		healthHandler := handlers.NewHealthHandler(db, healthChecks)
 		app.Get("/health", healthHandler.GetHealth)
 	} else {
 		// Simple health check without database
@@ -129,12 +838,56 @@ func setupRoutes(app *fiber.App, db *gorm.DB) {
 		})
 	}
 
+	// Prometheus metrics
+	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
+
 	// API v1 routes
-	v1 := app.Group("/api/v1")
+	// Requests are bound to the region of their community before anything is looked up
+	bindRegion := func(c *fiber.Ctx) error { return c.Next() }
+	if router != nil {
+		bindRegion = middleware.Residency(router)
+	}
+	// Every API request is identified first so authenticated users get their own rate limit
+	v1 := app.Group("/api/v1", bindRegion, identify)
+	if cfg.Auth.AdminToken != "" {
+		// Lets public endpoints honor moderator options such as include_pending
+		v1.Use(middleware.IdentifyAdmin(cfg.Auth.AdminToken))
+	}
+	// Scripts authenticate with personal API keys and are rate limited per key
+	var apiKeys *services.APIKeyService
+	if db != nil {
+		apiKeys = services.NewAPIKeyService(db)
+		// Unknown keys are counted per IP, so keys cannot be guessed
+		apiKeyFailures, err := ratelimit.NewCounter(cfg.Cache.RedisURL)
+		if err != nil {
+			log.Fatalf("Failed to create API key failure counter: %v", err)
+		}
+		apiKeyBlocks, err := cache.NewStore(cfg.Cache.RedisURL, cfg.Cache.MaxEntries)
+		if err != nil {
+			log.Fatalf("Failed to create API key block store: %v", err)
+		}
+		v1.Use(middleware.APIKeyAuth(apiKeys.Authenticate, apiKeyFailures, apiKeyBlocks))
+		// Lets public endpoints honor moderator options for users whose role allows them
+		v1.Use(middleware.IdentifyRole(services.NewRoleService(db).Role))
+	}
+	var limits ratelimit.Store
+	rateLimit := func(c *fiber.Ctx) error { return c.Next() }
+	if cfg.RateLimit.Enabled {
+		var err error
+		if limits, err = ratelimit.NewStore(cfg.Cache.RedisURL); err != nil {
+			log.Fatalf("Failed to create rate limit store: %v", err)
+		}
+		rateLimit = middleware.RateLimit(limits, middleware.RateLimitConfig{
+			PerIP:     ratelimit.Limit{Rate: cfg.RateLimit.IPRate, Burst: cfg.RateLimit.IPBurst},
+			PerUser:   ratelimit.Limit{Rate: cfg.RateLimit.UserRate, Burst: cfg.RateLimit.UserBurst},
+			PerAPIKey: ratelimit.Limit{Rate: cfg.RateLimit.APIKeyRate, Burst: cfg.RateLimit.APIKeyBurst},
+		})
+		v1.Use(rateLimit)
+	}
 	
 	// Health check for API
 	if db != nil {
-		healthHandler := handlers.NewHealthHandler(db)
+		healthHandler := handlers.NewHealthHandler(db, healthChecks)
 		v1.Get("/health", healthHandler.GetHealth)
 	} else {
 		v1.Get("/health", func(c *fiber.Ctx) error {
@@ -146,6 +899,495 @@ func setupRoutes(app *fiber.App, db *gorm.DB) {
 		})
 	}
 	
-	// Chat streaming endpoint
-	v1.Get("/chat/stream", chatHandler.StreamChat)
+	v1.Get("/climate", handlers.NewClimateHandler(climateService).GetClimate)
+	v1.Get("/daylight", handlers.NewDaylightHandler(daylightService).GetDaylight)
+	if geocodeService != nil {
+		v1.Get("/geocode", handlers.NewGeocodeHandler(geocodeService).Geocode)
+	}
+
+	// Chat protocol and output contract for frontends
+	v1.Get("/capabilities", handlers.NewCapabilitiesHandler(tokens != nil).GetCapabilities)
+
+	// OpenAPI document of the registered routes, and Swagger UI to browse it
+	docsHandler := handlers.NewDocsHandler(app, apidocs.Info{
+		Title:       "Community Chatbot API",
+		Version:     "v1",
+		Description: "Outdoor activities, users and chat of the community",
+	})
+	v1.Get("/openapi.json", docsHandler.GetSpec)
+	v1.Get("/docs", docsHandler.GetDocs)
+	v1.Get("/docs/:file", docsHandler.GetDocsAsset)
+
+	// Chat streaming endpoint; chat widgets on other sites authenticate with embed tokens
+	embedAuth := func(c *fiber.Ctx) error { return c.Next() }
+	var embeds *services.EmbedService
+	if db != nil {
+		embeds = services.NewEmbedService(db, tokens, cfg.Embed.TokenTTL)
+		embedAuth = middleware.EmbedAuth(tokens, embeds.ActiveKey, limits)
+	}
+	issueSession := func(c *fiber.Ctx) error { return c.Next() }
+	if sessions != nil {
+		issueSession = middleware.Session(sessions, middleware.SessionConfig{Issue: true, SecureCookie: cfg.Session.CookieSecure})
+	}
+	v1.Get("/chat/stream", embedAuth, issueSession, chatHandler.StreamChat)
+	v1.Post("/chat/stream", embedAuth, issueSession, chatHandler.StreamChatPost)
+	v1.Post("/chat/:conversationID/stop", embedAuth, issueSession, chatHandler.StopChat)
+	// Two-step flow for EventSource clients: the run is posted, then its token opens the stream
+	v1.Post("/chat/runs", embedAuth, issueSession, chatHandler.CreateRun)
+	v1.Get("/chat/runs/:token/stream", chatHandler.StreamRun)
+	// OpenAI-compatible clients use /api/v1 as their base URL
+	v1.Post("/chat/completions", embedAuth, chatHandler.ChatCompletions)
+	// Backend developers check streaming here without running the frontend
+	if cfg.Server.DebugChat {
+		app.Get("/debug/chat", chatHandler.GetDebugPage)
+	}
+
+	// Activity routes (require database)
+	if db != nil {
+		requireAuth := middleware.RequireAuth(tokens, services.TokenRevocations(db))
+
+		// Emails are queued so requests do not wait for the mail server
+		mailQueue := mailer.NewQueue(newMailProvider(cfg), cfg.Mail.QueueSize)
+		go mailQueue.Start(ctx, cfg.Mail.Workers)
+		emails := services.NewEmailSender(mailQueue, cfg.Server.FrontendURL)
+
+		// Verification and reset links open the frontend, which calls /auth/email/verify and /auth/password/reset
+		authService := services.NewAuthService(db, tokens, emails, cfg.Server.FrontendURL, cfg.Auth.VerificationTTL, cfg.Auth.PasswordResetTTL)
+		authHandler := handlers.NewAuthHandler(authService)
+		authRoutes := v1.Group("/auth")
+		authRoutes.Post("/register", authHandler.Register)
+		authRoutes.Post("/login", authHandler.Login)
+		authRoutes.Post("/refresh", authHandler.Refresh)
+		authRoutes.Post("/invitations/accept", authHandler.AcceptInvitation)
+		authRoutes.Post("/email/verify", authHandler.VerifyEmail)
+		authRoutes.Post("/password/forgot", authHandler.RequestPasswordReset)
+		authRoutes.Post("/password/reset", authHandler.ResetPassword)
+		// Sign-in with external providers, next to the email and password flow
+		var oauthProviders []*oauth.Provider
+		if cfg.Auth.Google.ClientID != "" {
+			oauthProviders = append(oauthProviders, oauth.Google(oauth.Config(cfg.Auth.Google)))
+		}
+		if cfg.Auth.GitHub.ClientID != "" {
+			oauthProviders = append(oauthProviders, oauth.GitHub(oauth.Config(cfg.Auth.GitHub)))
+		}
+		oauthHandler := handlers.NewOAuthHandler(authService, oauthProviders, cfg.Server.PublicURL, cfg.Server.FrontendURL)
+		authRoutes.Get("/providers", oauthHandler.ListProviders)
+		authRoutes.Get("/:provider/login", oauthHandler.Login)
+		authRoutes.Get("/:provider/callback", oauthHandler.Callback)
+		// New addresses are confirmed on the frontend, which calls /auth/email/confirm
+		accountHandler := handlers.NewAccountHandler(services.NewAccountService(db, emails,
+			strings.TrimRight(cfg.Server.FrontendURL, "/")+"/email/confirm", cfg.Auth.EmailChangeTTL))
+		authRoutes.Post("/email/confirm", accountHandler.ConfirmEmailChange)
+		account := v1.Group("/users/me", requireAuth)
+		account.Get("/", accountHandler.GetAccount)
+		account.Put("/", accountHandler.UpdateAccount)
+		account.Delete("/", middleware.DenyAPIKeys(), accountHandler.DeleteAccount)
+		account.Post("/email", middleware.DenyAPIKeys(), accountHandler.RequestEmailChange)
+		account.Post("/verification", authHandler.RequestVerification)
+
+		embedHandler := handlers.NewEmbedHandler(embeds, cfg.Embed.Rate, cfg.Embed.Burst)
+		v1.Post("/embed/token", embedHandler.IssueToken)
+
+		preferencesHandler := handlers.NewPreferencesHandler(services.NewPreferencesService(db))
+		me := v1.Group("/me", requireAuth)
+		me.Get("/", authHandler.Me)
+		me.Get("/preferences", preferencesHandler.GetPreferences)
+		me.Put("/preferences", preferencesHandler.UpdatePreferences)
+
+		// Purposes services check before storing or using the user's data
+		consentHandler := handlers.NewConsentHandler(services.NewConsentService(db))
+		me.Get("/consents", consentHandler.ListConsents)
+		me.Put("/consents/:purpose", consentHandler.GrantConsent)
+		me.Delete("/consents/:purpose", consentHandler.RevokeConsent)
+
+		apiKeyHandler := handlers.NewAPIKeyHandler(apiKeys)
+		me.Get("/api-keys", apiKeyHandler.ListKeys)
+		me.Post("/api-keys", apiKeyHandler.CreateKey)
+		me.Delete("/api-keys/:id", apiKeyHandler.RevokeKey)
+
+		locationHistory := services.NewLocationHistoryService(db)
+		favoriteService := services.NewFavoriteService(db)
+		activityHandler := handlers.NewActivityHandler(activityService, similarity, semanticSearch, reviewService, locationHistory, favoriteService, weatherService, activityStats, cfg.Content.StaleAfter)
+		imageTypes, err := upload.NewPolicy(cfg.Storage.ImageTypes)
+		if err != nil {
+			log.Fatalf("Invalid UPLOAD_IMAGE_TYPES: %v", err)
+		}
+		routeTypes, err := upload.NewPolicy(cfg.Storage.RouteTypes)
+		if err != nil {
+			log.Fatalf("Invalid UPLOAD_ROUTE_TYPES: %v", err)
+		}
+		scanner := newUploadScanner(cfg)
+		routeHandler := handlers.NewRouteHandler(services.NewRouteService(db), routeTypes, scanner,
+			int64(cfg.Storage.MaxRouteBytes), cfg.Storage.MaxRoutePoints)
+		reviewHandler := handlers.NewReviewHandler(reviewService)
+		notifications := services.NewNotificationService(db, services.LogPushSender{})
+		conditionHandler := handlers.NewConditionHandler(conditionService)
+		transitHandler := handlers.NewTransitHandler(transitService)
+
+		// Categories replace free-text activity categories; defaults are seeded on first start
+		categoryService := services.NewCategoryService(db)
+		if err := categoryService.Seed(ctx); err != nil {
+			log.Printf("Warning: Failed to seed categories: %v", err)
+		}
+		categoryHandler := handlers.NewCategoryHandler(categoryService)
+		v1.Get("/categories", categoryHandler.ListCategories)
+		v1.Get("/categories/:id", categoryHandler.GetCategory)
+
+		statsHandler := handlers.NewStatsHandler(statsService)
+		v1.Get("/stats", statsHandler.GetStats)
+		v1.Get("/stats/area", statsHandler.GetAreaStats)
+
+		// Printed QR codes encode short links, which resolve outside /api/v1
+		shortLinkHandler := handlers.NewShortLinkHandler(services.NewShortLinkService(db, interestClaims, cfg.Server.PublicURL, cfg.Server.FrontendURL), cfg.Content.StaleAfter)
+		app.Get("/a/:code", bindRegion, rateLimit, shortLinkHandler.ResolveShortLink)
+
+		activities := v1.Group("/activities")
+		activities.Get("/", activityHandler.ListActivities)
+		activities.Get("/trending", activityHandler.GetTrending)
+		activities.Post("/", requireAuth, activityHandler.CreateActivity)
+		activities.Get("/nearby", activityHandler.GetNearby)
+		activities.Get("/search", activityHandler.SearchActivities)
+		activities.Get("/export", activityHandler.ExportActivities)
+		if semanticSearch != nil {
+			activities.Get("/semantic-search", activityHandler.SemanticSearch)
+		}
+		activities.Get("/:id", activityHandler.GetActivity)
+		activities.Put("/:id", requireAuth, activityHandler.UpdateActivity)
+		activities.Delete("/:id", requireAuth, activityHandler.DeleteActivity)
+		activities.Post("/:id/variants", requireAuth, activityHandler.CreateVariant)
+		activities.Get("/:id/similar", activityHandler.GetSimilar)
+		activities.Get("/:id/short-link", shortLinkHandler.GetShortLink)
+		activities.Get("/:id/qrcode.png", shortLinkHandler.GetQRCode)
+		activities.Post("/:id/routes", requireAuth, routeHandler.UploadRoute)
+		v1.Get("/routes/:id/export", routeHandler.ExportRoute)
+		v1.Get("/routes/:id/elevation-profile", routeHandler.GetElevationProfile)
+		activities.Get("/:id/reviews", reviewHandler.ListReviews)
+		activities.Post("/:id/reviews", requireAuth, reviewHandler.CreateReview)
+		activities.Put("/:id/reviews/:review_id", requireAuth, reviewHandler.UpdateReview)
+		activities.Delete("/:id/reviews/:review_id", requireAuth, reviewHandler.DeleteReview)
+		activities.Post("/:id/reviews/:review_id/flag", requireAuth, reviewHandler.FlagReview)
+		commentHandler := handlers.NewCommentHandler(services.NewCommentService(db, notifications))
+		activities.Get("/:id/comments", commentHandler.ListComments)
+		activities.Post("/:id/comments", requireAuth, commentHandler.CreateComment)
+		activities.Delete("/:id/comments/:comment_id", requireAuth, commentHandler.DeleteComment)
+		activities.Get("/:id/conditions", conditionHandler.ListConditions)
+		activities.Post("/:id/conditions", requireAuth, conditionHandler.ReportCondition)
+		activities.Get("/:id/transit", transitHandler.GetTransit)
+		checklistHandler := handlers.NewChecklistHandler(checklistService)
+		activities.Get("/:id/checklist", checklistHandler.GetChecklist)
+
+		// Images are spooled and uploaded in the background, so image host outages only delay them
+		if cfg.Storage.CloudinaryURL != "" || cfg.Storage.CloudName != "" {
+			var uploader storage.Uploader
+			uploader, err := storage.NewCloudinary(cfg.Storage.CloudinaryURL, cfg.Storage.CloudName,
+				cfg.Storage.APIKey, cfg.Storage.APISecret, cfg.Storage.Folder)
+			if err != nil {
+				log.Fatalf("Invalid Cloudinary configuration: %v", err)
+			}
+			// Each region stores its images with its own Cloudinary account
+			if router != nil {
+				regional := make(map[string]storage.Uploader)
+				for _, region := range cfg.Residency.Regions {
+					if regional[region.Name], err = storage.NewCloudinary(region.CloudinaryURL, "", "", "", cfg.Storage.Folder); err != nil {
+						log.Fatalf("Invalid Cloudinary configuration of region %s: %v", region.Name, err)
+					}
+				}
+				uploader = residency.NewUploader(uploader, regional)
+			}
+			spool, err := storage.NewSpool(cfg.Storage.SpoolDir)
+			if err != nil {
+				log.Fatalf("Failed to create upload spool: %v", err)
+			}
+			images := services.NewImageService(db, spool, uploader, notifications, services.UploadRetryConfig{
+				MaxAttempts: cfg.Storage.UploadMaxAttempts,
+				BaseDelay:   cfg.Storage.UploadRetryBackoff,
+				MaxDelay:    cfg.Storage.UploadRetryMaxWait,
+			}, newImageScreening(cfg), trustService)
+			for _, regionCtx := range regionContexts(ctx, router) {
+				go images.StartUploads(regionCtx, cfg.Storage.UploadInterval)
+			}
+			imageHandler := handlers.NewImageHandler(images, imageTypes, scanner,
+				int64(cfg.Storage.MaxImageBytes), cfg.Storage.MaxImageDimension)
+			activities.Post("/:id/images", requireAuth, imageHandler.UploadImage)
+		} else {
+			log.Println("Warning: CLOUDINARY_URL not set, image uploads are disabled")
+		}
+
+		itineraryHandler := handlers.NewItineraryHandler(itineraries)
+		v1.Post("/itineraries", itineraryHandler.CreateItinerary)
+		v1.Get("/itineraries/:id", itineraryHandler.GetItinerary)
+		v1.Get("/itineraries/:id/pdf", itineraryHandler.DownloadItinerary)
+
+		locationHistoryHandler := handlers.NewLocationHistoryHandler(locationHistory)
+		me.Get("/location-history", locationHistoryHandler.GetLocationHistory)
+		me.Delete("/location-history", locationHistoryHandler.PurgeLocationHistory)
+
+		favoriteHandler := handlers.NewFavoriteHandler(favoriteService)
+		me.Get("/favorites", favoriteHandler.ListFavorites)
+		me.Post("/favorites/:id", favoriteHandler.AddFavorite)
+		me.Delete("/favorites/:id", favoriteHandler.RemoveFavorite)
+
+		savedSearchHandler := handlers.NewSavedSearchHandler(services.NewSavedSearchService(db))
+		me.Get("/saved-searches", savedSearchHandler.ListSavedSearches)
+		me.Post("/saved-searches", savedSearchHandler.CreateSavedSearch)
+		me.Delete("/saved-searches/:id", savedSearchHandler.DeleteSavedSearch)
+
+		collectionHandler := handlers.NewCollectionHandler(services.NewCollectionService(db, cfg.Content.StaleAfter))
+		collections := v1.Group("/collections")
+		collections.Get("/", collectionHandler.ListCollections)
+		collections.Post("/", requireAuth, collectionHandler.CreateCollection)
+		collections.Get("/:id", collectionHandler.GetCollection)
+		collections.Put("/:id", requireAuth, collectionHandler.UpdateCollection)
+		collections.Delete("/:id", requireAuth, collectionHandler.DeleteCollection)
+		collections.Post("/:id/follow", requireAuth, collectionHandler.FollowCollection)
+		collections.Delete("/:id/follow", requireAuth, collectionHandler.UnfollowCollection)
+		me.Get("/collections", collectionHandler.ListMyCollections)
+		me.Get("/collections/followed", collectionHandler.ListFollowedCollections)
+
+		notificationHandler := handlers.NewNotificationHandler(notifications)
+		me.Get("/notifications", notificationHandler.ListNotifications)
+		me.Post("/notifications/:id/read", notificationHandler.MarkNotificationRead)
+
+		sessionHandler := handlers.NewSessionHandler(sessions)
+		resolveSession := middleware.Session(sessions, middleware.SessionConfig{SecureCookie: cfg.Session.CookieSecure})
+		v1.Get("/session", resolveSession, sessionHandler.GetSession)
+
+		conversationHandler := handlers.NewConversationHandler(services.NewConversationService(db), chatHandler)
+		// Anonymous conversations are only readable and editable in their session
+		conversations := v1.Group("/conversations")
+		conversations.Get("/", requireAuth, conversationHandler.ListConversations)
+		conversations.Get("/:id", resolveSession, middleware.RequireSession(), conversationHandler.GetConversation)
+		conversations.Get("/:id/messages", resolveSession, middleware.RequireSession(), conversationHandler.ListMessages)
+		// Editing a message streams the answer on a new branch, like the chat stream
+		v1.Post("/messages/:id/branch", embedAuth, issueSession, middleware.RequireSession(), conversationHandler.BranchMessage)
+
+		keyHandler := handlers.NewKeyHandler(keyring)
+		app.Get("/.well-known/jwks.json", keyHandler.GetJWKS)
+
+		federationHandler := handlers.NewFederationHandler(newFederationService(db, cfg.Federation))
+		v1.Get("/federation/community", federationHandler.GetCommunity)
+
+		// Moderators review content; admins also manage users and operate the
+		// community. The admin token stands in for an admin. Moderation and user
+		// management are registered before the rest of /admin, whose guard would
+		// otherwise also run for their paths.
+		roles := services.NewRoleService(db)
+		if cfg.Auth.SeedAdminEmail != "" {
+			if err := roles.SeedAdmin(ctx, cfg.Auth.SeedAdminEmail, cfg.Auth.SeedAdminPassword); err != nil {
+				log.Fatalf("Failed to seed admin: %v", err)
+			}
+		}
+		requirePermission := func(permission string) fiber.Handler {
+			return middleware.RequirePermission(cfg.Auth.AdminToken, roles.Role, permission)
+		}
+
+		moderation := v1.Group("/admin/moderation", requirePermission(models.PermissionModerate))
+		moderationHandler := handlers.NewModerationHandler(services.NewModerationService(db, activityService, notifications, emails, trustService))
+		moderation.Get("/activities", moderationHandler.ListPendingActivities)
+		moderation.Post("/activities/:id/approve", moderationHandler.ApproveActivity)
+		moderation.Post("/activities/:id/reject", moderationHandler.RejectActivity)
+		moderation.Get("/images", moderationHandler.ListPendingImages)
+		moderation.Post("/images/:id/approve", moderationHandler.ApproveImage)
+		moderation.Post("/images/:id/reject", moderationHandler.RejectImage)
+		moderation.Get("/auto-approved/activities", moderationHandler.ListAutoApprovedActivities)
+		moderation.Post("/activities/:id/revoke", moderationHandler.RevokeActivity)
+		moderation.Get("/auto-approved/images", moderationHandler.ListAutoApprovedImages)
+		moderation.Post("/images/:id/revoke", moderationHandler.RevokeImage)
+		moderation.Get("/reviews", moderationHandler.ListFlaggedReviews)
+		moderation.Post("/reviews/:id/hide", moderationHandler.HideReview)
+		moderation.Post("/reviews/:id/restore", moderationHandler.RestoreReview)
+
+		users := v1.Group("/admin/users", requirePermission(models.PermissionManageUsers))
+		users.Put("/:id/trust", handlers.NewTrustHandler(trustService).SetTrustLevel)
+		users.Put("/:id/role", handlers.NewRoleHandler(roles).SetRole)
+		// Invited users set their password on the frontend, which calls /auth/invitations/accept
+		userImports := services.NewUserImportService(db, emails,
+			strings.TrimRight(cfg.Server.FrontendURL, "/")+"/invitations/accept", cfg.Auth.InvitationTTL)
+		users.Post("/import", handlers.NewUserImportHandler(userImports).ImportUsers)
+
+		admin := v1.Group("/admin", requirePermission(models.PermissionOperate))
+		admin.Get("/keys", keyHandler.ListKeys)
+		admin.Post("/keys/rotate", keyHandler.RotateKey)
+		admin.Delete("/keys/:kid", keyHandler.RevokeKey)
+
+		admin.Get("/embed-keys", embedHandler.ListKeys)
+		admin.Post("/embed-keys", embedHandler.CreateKey)
+		admin.Delete("/embed-keys/:id", embedHandler.RevokeKey)
+
+		admin.Get("/activities/:id/checklist-overrides", checklistHandler.ListOverrides)
+		admin.Post("/activities/:id/checklist-overrides", checklistHandler.SetOverride)
+		admin.Delete("/activities/:id/checklist-overrides/:override_id", checklistHandler.DeleteOverride)
+
+		admin.Post("/categories", categoryHandler.CreateCategory)
+		admin.Put("/categories/:id", categoryHandler.UpdateCategory)
+		admin.Delete("/categories/:id", categoryHandler.DeleteCategory)
+
+		difficultyHandler := handlers.NewDifficultyHandler(services.NewDifficultyService(db))
+		admin.Get("/difficulty-calibrations", difficultyHandler.ListCalibrations)
+		admin.Put("/difficulty-calibrations", difficultyHandler.SetCalibration)
+		admin.Post("/routes/recompute-stats", routeHandler.RecomputeStats)
+
+		guardrailHandler := handlers.NewGuardrailHandler(services.NewGuardrailService(db))
+		admin.Get("/guardrails", guardrailHandler.GetGuardrails)
+		admin.Put("/guardrails", guardrailHandler.SetGuardrails)
+		admin.Post("/guardrails/dry-run", guardrailHandler.DryRun)
+		// Runs the chat pipeline up to the model, for debugging bad answers
+		admin.Post("/chat/dry-run", chatHandler.DryRun)
+
+		logSamplingHandler := handlers.NewLogSamplingHandler(logSamplingService)
+		admin.Get("/log-sampling", logSamplingHandler.GetLogSampling)
+		admin.Put("/log-sampling", logSamplingHandler.SetLogSampling)
+		admin.Delete("/log-sampling", logSamplingHandler.ResetLogSampling)
+
+		admin.Get("/federation/export", federationHandler.ExportBundle)
+		admin.Post("/federation/import", federationHandler.ImportBundle)
+
+		admin.Post("/activities/import", handlers.NewActivityImportHandler(
+			services.NewActivityImportService(db, activityService)).ImportActivities)
+
+		admin.Get("/db-stats", handlers.NewDBStatsHandler(services.NewDBStatsService(db)).GetDBStats)
+		admin.Get("/usage", handlers.NewUsageHandler(usageService).GetUsage)
+		admin.Get("/abuse-signals", handlers.NewAbuseSignalHandler(services.NewAbuseSignalService(db)).ListAbuseSignals)
+	}
+
+	go jobs.Start(ctx)
+}
+
+// newLLMProvider creates the language model provider selected by LLM_PROVIDER
+// and returns it with its chat model. It returns a nil provider when OpenAI is
+// selected without an API key, and chat answers with canned responses.
+func newLLMProvider(cfg *config.Config) (llm.Provider, string) {
+	options := llm.Options{}
+	if cfg.LLM.Temperature >= 0 {
+		temperature := cfg.LLM.Temperature
+		options.Temperature = &temperature
+	}
+
+	switch cfg.LLM.Provider {
+	case "azure":
+		options.Model = cfg.LLM.AzureDeployment
+		options.EmbeddingModel = embeddingModel(cfg)
+		options.BaseURL = cfg.LLM.AzureEndpoint
+		log.Printf("Using Azure OpenAI deployment %s at %s", options.Model, options.BaseURL)
+		return llm.NewAzureOpenAIClient(cfg.LLM.AzureAPIKey, cfg.LLM.AzureAPIVersion, options), options.Model
+	case "ollama":
+		options.Model = cfg.LLM.OllamaModel
+		options.EmbeddingModel = embeddingModel(cfg)
+		options.BaseURL = cfg.LLM.OllamaURL
+		log.Printf("Using Ollama model %s at %s", options.Model, options.BaseURL)
+		return llm.NewOllamaClient(options), options.Model
+	}
+
+	if cfg.OpenAI.APIKey == "" {
+		log.Println("Warning: OPENAI_API_KEY not set, chat will use canned responses")
+		return nil, cfg.OpenAI.Model
+	}
+	options.Model = cfg.OpenAI.Model
+	options.EmbeddingModel = embeddingModel(cfg)
+	options.BaseURL = cfg.OpenAI.BaseURL
+	return llm.NewOpenAIClient(cfg.OpenAI.APIKey, options), options.Model
+}
+
+// newSemanticSearch prepares the embeddings table of each region, starts
+// embedding activities and returns the semantic search service. It returns nil
+// when pgvector is unavailable.
+func newSemanticSearch(ctx context.Context, db *gorm.DB, router *residency.Router, cfg *config.Config, provider llm.Provider) *services.SemanticSearchService {
+	regionCtxs := regionContexts(ctx, router)
+	for _, regionCtx := range regionCtxs {
+		if err := services.EnsureEmbeddings(db.WithContext(regionCtx), cfg.Semantic.Dimensions); err != nil {
+			log.Printf("Warning: semantic search is disabled: %v", err)
+			return nil
+		}
+	}
+
+	semanticSearch := services.NewSemanticSearchService(db, provider, embeddingModel(cfg), cfg.Content.StaleAfter)
+	for _, regionCtx := range regionCtxs {
+		go semanticSearch.StartEmbedding(regionCtx, cfg.Semantic.EmbedInterval)
+	}
+	return semanticSearch
+}
+
+// embeddingModel returns the embedding model of the provider selected by LLM_PROVIDER
+func embeddingModel(cfg *config.Config) string {
+	switch cfg.LLM.Provider {
+	case "azure":
+		return cfg.LLM.AzureEmbeddingDeployment
+	case "ollama":
+		return cfg.LLM.OllamaEmbeddingModel
+	}
+	return cfg.OpenAI.EmbeddingModel
+}
+
+// newImageScreening configures automatic screening of uploaded images, which is
+// disabled with MODERATION_PROVIDER=none
+func newImageScreening(cfg *config.Config) services.ImageScreening {
+	screening := services.ImageScreening{
+		Thresholds: moderation.Thresholds{
+			Review: cfg.Moderation.ReviewThreshold,
+			Reject: cfg.Moderation.RejectThreshold,
+		},
+		AutoApprove: cfg.Moderation.AutoApprove,
+	}
+	switch cfg.Moderation.Provider {
+	case "openai":
+		screening.Screener = moderation.NewOpenAIScreener(cfg.OpenAI.APIKey, cfg.Moderation.Model, cfg.OpenAI.BaseURL)
+	case "stub":
+		screening.Screener = moderation.StubScreener{Score: cfg.Moderation.StubScore}
+	}
+	return screening
+}
+
+// newUploadScanner creates the malware scanner uploads are checked with before
+// they are stored, or returns nil with UPLOAD_SCANNER=none
+func newUploadScanner(cfg *config.Config) upload.Scanner {
+	switch cfg.Storage.Scanner {
+	case "clamav":
+		return upload.NewClamAVScanner(cfg.Storage.ScannerAddress, cfg.Storage.ScanTimeout)
+	case "icap":
+		scanner, err := upload.NewICAPScanner(cfg.Storage.ScannerAddress, cfg.Storage.ScanTimeout)
+		if err != nil {
+			log.Fatalf("Invalid UPLOAD_SCANNER_ADDRESS: %v", err)
+		}
+		return scanner
+	}
+	return nil
+}
+
+// newMailProvider creates the provider delivering emails
+func newMailProvider(cfg *config.Config) mailer.Provider {
+	if cfg.Mail.Provider != "smtp" {
+		return mailer.LogProvider{}
+	}
+	provider, err := mailer.NewSMTPProvider(mailer.SMTPConfig{
+		Host:     cfg.Mail.SMTPHost,
+		Port:     cfg.Mail.SMTPPort,
+		Username: cfg.Mail.SMTPUsername,
+		Password: cfg.Mail.SMTPPassword,
+		From:     cfg.Mail.From,
+	})
+	if err != nil {
+		log.Fatalf("Invalid MAIL_FROM: %v", err)
+	}
+	return provider
+}
+
+// newFederationService creates the bundle exchange service; export needs a signing
+// key and import needs at least one trusted community
+func newFederationService(db *gorm.DB, cfg config.FederationConfig) *services.FederationService {
+	var signer *federation.Signer
+	if cfg.SigningKey != "" {
+		var err error
+		if signer, err = federation.NewSigner(cfg.Community, cfg.SigningKey); err != nil {
+			log.Fatalf("Invalid FEDERATION_SIGNING_KEY: %v", err)
+		}
+	}
+
+	verifier, err := federation.NewVerifier(cfg.TrustedKeys)
+	if err != nil {
+		log.Fatalf("Invalid FEDERATION_TRUSTED_KEYS: %v", err)
+	}
+	if verifier.Len() == 0 {
+		verifier = nil
+	}
+	return services.NewFederationService(db, signer, verifier)
 }
@@ -0,0 +1,178 @@
+// Package agui is the catalog of AG-UI events sent on chat streams, at catalog
+// version Version. Every event is sent in an Envelope.
+package agui
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event represents an AG-UI compatible event for streaming
+type Event struct {
+	Type      string      `json:"type"`
+	ID        string      `json:"id"`
+	Data      interface{} `json:"data,omitempty"`
+	Timestamp int64       `json:"timestamp"`
+}
+
+// AG-UI Event Types as defined in the documentation
+const (
+	EventTextMessageStart        = "TEXT_MESSAGE_CONTENT_START"
+	EventTextMessageContent      = "TEXT_MESSAGE_CONTENT"
+	EventTextMessageComplete     = "TEXT_MESSAGE_CONTENT_COMPLETE"
+	EventToolCallStart           = "TOOL_CALL_START"
+	EventToolCallComplete        = "TOOL_CALL_COMPLETE"
+	EventStateUpdate             = "STATE_UPDATE"
+	EventError                   = "ERROR"
+	EventActivitiesFound         = "ACTIVITIES_FOUND"
+	EventImagesLoaded            = "IMAGES_LOADED"
+	EventMapDataReady            = "MAP_DATA_READY"
+	EventItineraryReady          = "ITINERARY_READY"
+	EventConditionReportProposed = "CONDITION_REPORT_PROPOSED"
+	EventQueued                  = "QUEUED"
+	EventThrottled               = "THROTTLED"
+	EventUsageReport             = "USAGE_REPORT"
+)
+
+type ToolCallData struct {
+	ToolCallID string                 `json:"tool_call_id,omitempty"`
+	Name       string                 `json:"name"`
+	Args       map[string]interface{} `json:"args"`
+	Result     interface{}            `json:"result,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+type StateUpdateData struct {
+	Activities []interface{} `json:"activities,omitempty"`
+	Images     []string      `json:"images,omitempty"`
+	MapData    interface{}   `json:"map_data,omitempty"`
+}
+
+// ActivitiesFoundData carries activities relevant to the user's message, for
+// the client to render as cards alongside the answer
+type ActivitiesFoundData struct {
+	Activities  []interface{} `json:"activities"`
+	TotalCount  int           `json:"total_count"`
+	SearchQuery string        `json:"search_query,omitempty"`
+}
+
+// MapData is the geometry of an activity's route for the client to draw: a
+// GeoJSON LineString and its bounding box [min lng, min lat, max lng, max lat]
+type MapData struct {
+	ActivityID     uint        `json:"activity_id"`
+	RouteID        uint        `json:"route_id"`
+	Name           string      `json:"name,omitempty"`
+	DistanceKM     float64     `json:"distance_km"`
+	ElevationGainM int         `json:"elevation_gain_m"`
+	Geometry       interface{} `json:"geometry"`
+	BBox           []float64   `json:"bbox"`
+}
+
+type ItineraryData struct {
+	ItineraryID string `json:"itinerary_id"`
+	Status      string `json:"status"`
+	DownloadURL string `json:"download_url,omitempty"`
+	StatusURL   string `json:"status_url"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ConditionReportProposalData is a condition report detected in a chat message,
+// for the user to confirm. Confirming posts status and note to ReportURL, the
+// regular condition report endpoint, which requires signing in.
+type ConditionReportProposalData struct {
+	ActivityID   uint   `json:"activity_id"`
+	ActivityName string `json:"activity_name"`
+	Status       string `json:"status"`
+	Note         string `json:"note"`
+	ReportURL    string `json:"report_url"`
+	// SignIn is set for guests, who have to sign in to file the report
+	SignIn bool `json:"sign_in"`
+}
+
+// QueuedData tells the client its run waits for a free generation slot
+type QueuedData struct {
+	// Position is the run's place in the queue, 1 being next
+	Position int `json:"position"`
+	// MaxWaitSeconds is how long the run waits before it fails as RATE_LIMITED
+	MaxWaitSeconds int `json:"max_wait_seconds"`
+}
+
+// UsageReportData is the token usage and estimated cost of a run's answer
+type UsageReportData struct {
+	Model            string  `json:"model"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	EstimatedCostUSD float64 `json:"estimated_cost_usd"`
+}
+
+// ErrorCodeQuotaExceeded is the code of ERROR events sent when a guest or user
+// has used up their daily messages
+const ErrorCodeQuotaExceeded = "QUOTA_EXCEEDED"
+
+// QuotaExceededData is the data of an ERROR event with code QUOTA_EXCEEDED
+type QuotaExceededData struct {
+	Message string    `json:"message"`
+	Code    string    `json:"code"`
+	Limit   int       `json:"limit"`
+	ResetAt time.Time `json:"reset_at"`
+	// SignIn is set for guests, who get a higher limit after signing in
+	SignIn    bool `json:"sign_in"`
+	UserLimit int  `json:"user_limit,omitempty"`
+}
+
+// ThrottledData tells the client its run is held back because it sends
+// messages too quickly
+type ThrottledData struct {
+	Message      string `json:"message"`
+	DelaySeconds int    `json:"delay_seconds"`
+}
+
+// ErrorCodeMessageFlood is the code of ERROR events sent when a client is
+// blocked from chatting for sending messages too quickly
+const ErrorCodeMessageFlood = "MESSAGE_FLOOD"
+
+// MessageFloodData is the data of an ERROR event with code MESSAGE_FLOOD
+type MessageFloodData struct {
+	Message      string    `json:"message"`
+	Code         string    `json:"code"`
+	BlockedUntil time.Time `json:"blocked_until"`
+	// RetryAfterSeconds is how long until the client may send messages again
+	RetryAfterSeconds int `json:"retry_after_seconds"`
+}
+
+// ErrorCodeDuplicateMessage is the code of ERROR events sent when a client
+// sends the same message again shortly after, e.g. by double-clicking send
+const ErrorCodeDuplicateMessage = "DUPLICATE_MESSAGE"
+
+// DuplicateMessageData is the data of an ERROR event with code DUPLICATE_MESSAGE
+type DuplicateMessageData struct {
+	Message string `json:"message"`
+	Code    string `json:"code"`
+	// RetryAfterSeconds is the dedupe window; the same message is answered again after it at the latest
+	RetryAfterSeconds int `json:"retry_after_seconds"`
+}
+
+// NewEvent creates a new AG-UI event with auto-generated ID and timestamp
+func NewEvent(eventType string, data interface{}) Event {
+	return Event{
+		Type:      eventType,
+		ID:        generateEventID(),
+		Data:      data,
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+// ToSSE converts the event to Server-Sent Event format
+func (e Event) ToSSE() []byte {
+	data, _ := json.Marshal(e)
+	return encodeSSE("", data)
+}
+
+// generateEventID creates a unique ID for events
+func generateEventID() string {
+	return fmt.Sprintf("evt_%s", uuid.New().String()[:8])
+}
@@ -0,0 +1,129 @@
+package agui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Version is the version of the AG-UI event catalog, sent with every event
+// of a chat stream. It changes when events are renamed or their fields change
+// incompatibly; new events and fields do not change it.
+const Version = 1
+
+// Events framing a chat stream
+const (
+	EventStreamingStart = "STREAMING_START"
+	EventStreamingEnd   = "STREAMING_END"
+)
+
+// StreamingStartEvent is the first event of a chat stream
+type StreamingStartEvent struct {
+	Type           string `json:"type"`
+	MessageID      string `json:"messageId"`
+	ConversationID string `json:"conversationId"`
+}
+
+// TextMessageEvent is a chunk of the answer's text; IsComplete is set on the last one
+type TextMessageEvent struct {
+	Type       string `json:"type"`
+	Content    string `json:"content"`
+	IsComplete bool   `json:"isComplete"`
+}
+
+// StreamEndCancelled is the reason of STREAMING_END events of answers stopped by their client
+const StreamEndCancelled = "cancelled"
+
+// StreamingEndEvent is the last event of a chat stream, also after errors.
+// Reason is empty unless the answer was cut short.
+type StreamingEndEvent struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ErrorEvent ends a failed run; Code is the error's AG-UI code
+type ErrorEvent struct {
+	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// Envelope is an event as it is sent on a chat stream: the event's own
+// fields with the catalog version, the event's position in the stream, the
+// request it belongs to and, for events that have none, an ID and timestamp.
+// The envelope's fields are added to the event's object rather than wrapping
+// it, so clients reading only the event's fields are not affected.
+type Envelope struct {
+	Version int
+	// Sequence numbers the events of a stream from 1
+	Sequence  int64
+	RequestID string
+	ID        string
+	Timestamp int64
+	Event     interface{}
+}
+
+// NewEnvelope puts the event at position sequence of a stream
+func NewEnvelope(event interface{}, sequence int64, requestID string) Envelope {
+	return Envelope{
+		Version:   Version,
+		Sequence:  sequence,
+		RequestID: requestID,
+		ID:        generateEventID(),
+		Timestamp: time.Now().Unix(),
+		Event:     event,
+	}
+}
+
+// MarshalJSON encodes the event's object with the envelope's fields; the
+// event's own id and timestamp are kept
+func (e Envelope) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(e.Event)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("event is not an object: %w", err)
+	}
+
+	fields["v"] = json.RawMessage(strconv.Itoa(e.Version))
+	fields["seq"] = json.RawMessage(strconv.FormatInt(e.Sequence, 10))
+	if e.RequestID != "" {
+		id, _ := json.Marshal(e.RequestID)
+		fields["requestId"] = id
+	}
+	if _, ok := fields["id"]; !ok && e.ID != "" {
+		id, _ := json.Marshal(e.ID)
+		fields["id"] = id
+	}
+	if _, ok := fields["timestamp"]; !ok {
+		fields["timestamp"] = json.RawMessage(strconv.FormatInt(e.Timestamp, 10))
+	}
+	return json.Marshal(fields)
+}
+
+// EncodeSSE encodes the event as a Server-Sent Event; the event ID is omitted
+// when empty, and lets clients resume the stream after it
+func (e Envelope) EncodeSSE(eventID string) ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event: %w", err)
+	}
+	return encodeSSE(eventID, data), nil
+}
+
+// encodeSSE frames an encoded event as a Server-Sent Event. Events are encoded
+// on one line, so data needs no further escaping.
+func encodeSSE(eventID string, data []byte) []byte {
+	var buf bytes.Buffer
+	if eventID != "" {
+		buf.WriteString("id: " + eventID + "\n")
+	}
+	buf.WriteString("data: ")
+	buf.Write(data)
+	buf.WriteString("\n\n")
+	return buf.Bytes()
+}
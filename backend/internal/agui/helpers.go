@@ -0,0 +1,122 @@
+package agui
+
+import (
+	"math"
+	"time"
+)
+
+// Helper functions for creating common AG-UI events
+
+// CreateTextEvent creates a chunk of the answer's text
+func CreateTextEvent(content string, final bool) TextMessageEvent {
+	return TextMessageEvent{
+		Type:       EventTextMessageContent,
+		Content:    content,
+		IsComplete: final,
+	}
+}
+
+// CreateErrorEvent creates the error event ending a failed run
+func CreateErrorEvent(message, code string) ErrorEvent {
+	return ErrorEvent{
+		Type:    EventError,
+		Code:    code,
+		Message: message,
+	}
+}
+
+// CreateStateUpdateEvent creates a state update event
+func CreateStateUpdateEvent(activities []interface{}, images []string, mapData interface{}) Event {
+	return NewEvent(EventStateUpdate, StateUpdateData{
+		Activities: activities,
+		Images:     images,
+		MapData:    mapData,
+	})
+}
+
+// CreateToolCallStartEvent creates a tool call start event
+func CreateToolCallStartEvent(callID, name string, args map[string]interface{}) Event {
+	return NewEvent(EventToolCallStart, ToolCallData{
+		ToolCallID: callID,
+		Name:       name,
+		Args:       args,
+	})
+}
+
+// CreateToolCallCompleteEvent creates a tool call complete event with the tool's
+// result, or its error message when the call failed
+func CreateToolCallCompleteEvent(callID, name string, args map[string]interface{}, result interface{}, err error) Event {
+	data := ToolCallData{
+		ToolCallID: callID,
+		Name:       name,
+		Args:       args,
+		Result:     result,
+	}
+	if err != nil {
+		data.Error = err.Error()
+	}
+	return NewEvent(EventToolCallComplete, data)
+}
+
+// CreateItineraryEvent creates an itinerary event with the download link of a
+// rendered itinerary, or the link to poll while it is still rendering
+func CreateItineraryEvent(data ItineraryData) Event {
+	return NewEvent(EventItineraryReady, data)
+}
+
+// CreateActivitiesFoundEvent creates an event with activities to show as cards
+func CreateActivitiesFoundEvent(data ActivitiesFoundData) Event {
+	data.TotalCount = len(data.Activities)
+	return NewEvent(EventActivitiesFound, data)
+}
+
+// CreateMapDataEvent creates an event with a route to draw on a map
+func CreateMapDataEvent(data MapData) Event {
+	return NewEvent(EventMapDataReady, data)
+}
+
+// CreateQuotaExceededEvent creates an error event with code QUOTA_EXCEEDED
+func CreateQuotaExceededEvent(data QuotaExceededData) Event {
+	data.Code = ErrorCodeQuotaExceeded
+	return NewEvent(EventError, data)
+}
+
+// CreateThrottledEvent creates an event telling the client its run is delayed
+func CreateThrottledEvent(message string, delay time.Duration) Event {
+	return NewEvent(EventThrottled, ThrottledData{
+		Message:      message,
+		DelaySeconds: int(math.Ceil(delay.Seconds())),
+	})
+}
+
+// CreateMessageFloodEvent creates an error event with code MESSAGE_FLOOD
+func CreateMessageFloodEvent(data MessageFloodData) Event {
+	data.Code = ErrorCodeMessageFlood
+	return NewEvent(EventError, data)
+}
+
+// CreateDuplicateMessageEvent creates an error event with code DUPLICATE_MESSAGE
+func CreateDuplicateMessageEvent(data DuplicateMessageData) Event {
+	data.Code = ErrorCodeDuplicateMessage
+	return NewEvent(EventError, data)
+}
+
+// CreateConditionReportProposedEvent creates an event asking the user to confirm
+// a condition report detected in their message
+func CreateConditionReportProposedEvent(data ConditionReportProposalData) Event {
+	return NewEvent(EventConditionReportProposed, data)
+}
+
+// CreateQueuedEvent creates an event telling the client its run is queued
+func CreateQueuedEvent(position int, maxWait time.Duration) Event {
+	return NewEvent(EventQueued, QueuedData{
+		Position:       position,
+		MaxWaitSeconds: int(maxWait.Seconds()),
+	})
+}
+
+// CreateUsageReportEvent creates an event reporting a run's token usage and cost
+func CreateUsageReportEvent(data UsageReportData) Event {
+	data.TotalTokens = data.PromptTokens + data.CompletionTokens
+	return NewEvent(EventUsageReport, data)
+}
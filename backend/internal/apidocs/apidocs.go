@@ -0,0 +1,329 @@
+// Package apidocs describes the HTTP API as an OpenAPI 3 document. Paths and
+// methods come from the routes registered on the app, so the document never
+// lists endpoints that do not exist; summaries, parameters and responses come
+// from the doc comments of the handlers, collected by go generate.
+package apidocs
+
+//go:generate go run gen.go
+
+import (
+	"encoding/json"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// handlerDoc is what the doc comment of a handler says about its endpoint
+type handlerDoc struct {
+	Summary     string
+	Description string
+	Query       []queryParam
+	// Body and Form describe the JSON or multipart request body
+	Body      string
+	Form      string
+	Responses []docResponse
+}
+
+// queryParam is a query parameter named in a handler's doc comment
+type queryParam struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// docResponse is a status a handler's doc comment lists under Returns
+type docResponse struct {
+	Status      string
+	Description string
+}
+
+// Document is an OpenAPI 3 document
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Servers    []Server                         `json:"servers,omitempty"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server is a base URL of the API
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Operation is an endpoint: a method on a path
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path or query parameter of an operation
+type Parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Schema      Schema `json:"schema"`
+}
+
+// Schema is the type of a parameter or body
+type Schema struct {
+	Type string `json:"type,omitempty"`
+}
+
+// RequestBody is the body of an operation
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+// MediaType is a content type of a body, with an example when the doc comment gives one
+type MediaType struct {
+	Schema  *Schema         `json:"schema,omitempty"`
+	Example json.RawMessage `json:"example,omitempty"`
+}
+
+// Response is a status an operation answers with
+type Response struct {
+	Description string `json:"description"`
+}
+
+// Components holds the security schemes operations refer to
+type Components struct {
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+// SecurityScheme is a way of authenticating requests
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// securitySchemes are the ways clients authenticate; see internal/middleware
+var securitySchemes = map[string]SecurityScheme{
+	"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT", Description: "Access token from login, or a personal API key"},
+	"apiKey":     {Type: "apiKey", In: "header", Name: "X-API-Key", Description: "Personal API key"},
+	"adminToken": {Type: "http", Scheme: "bearer", Description: "Operator token (ADMIN_TOKEN)"},
+}
+
+// Middleware requiring authentication, by the name of the function returning it
+var (
+	userAuth  = []map[string][]string{{"bearerAuth": {}}, {"apiKey": {}}}
+	adminAuth = []map[string][]string{{"bearerAuth": {}}, {"apiKey": {}}, {"adminToken": {}}}
+	guards    = map[string][]map[string][]string{
+		"internal/middleware.RequireAuth":       userAuth,
+		"internal/middleware.RequirePermission": adminAuth,
+	}
+)
+
+// Build returns the document of the app's routes. Call it after all routes
+// are registered.
+func Build(app *fiber.App, info Info, servers ...Server) *Document {
+	document := &Document{
+		OpenAPI:    "3.0.3",
+		Info:       info,
+		Servers:    servers,
+		Paths:      map[string]map[string]*Operation{},
+		Components: Components{SecuritySchemes: securitySchemes},
+	}
+
+	// Group middleware guards every route under its prefix
+	guardedPrefixes := map[string][]map[string][]string{}
+	for _, route := range middlewareRoutes(app) {
+		if security := guard(route.Handlers); security != nil {
+			guardedPrefixes[strings.TrimSuffix(route.Path, "/")] = security
+		}
+	}
+
+	used := map[string]int{}
+	for _, route := range app.GetRoutes(true) {
+		if route.Method == fiber.MethodHead || len(route.Handlers) == 0 {
+			continue
+		}
+		path, params := openAPIPath(route.Path)
+		name := handlerName(route.Handlers[len(route.Handlers)-1])
+		doc := handlerDocs[name]
+
+		operation := &Operation{
+			Tags:        []string{tag(route.Path)},
+			Summary:     doc.Summary,
+			Description: doc.Description,
+			OperationID: operationID(name, route, used),
+			Parameters:  params,
+			Responses:   map[string]Response{},
+			Security:    guard(route.Handlers[:len(route.Handlers)-1]),
+		}
+		if operation.Security == nil {
+			operation.Security = guardOf(route.Path, guardedPrefixes)
+		}
+		for _, q := range doc.Query {
+			operation.Parameters = append(operation.Parameters, Parameter{
+				Name: q.Name, In: "query", Description: q.Description, Required: q.Required, Schema: Schema{Type: "string"},
+			})
+		}
+		operation.RequestBody = requestBody(doc)
+		for _, r := range doc.Responses {
+			operation.Responses[r.Status] = Response{Description: r.Description}
+		}
+		if len(operation.Responses) == 0 {
+			operation.Responses["default"] = Response{Description: "Response"}
+		}
+
+		if document.Paths[path] == nil {
+			document.Paths[path] = map[string]*Operation{}
+		}
+		document.Paths[path][strings.ToLower(route.Method)] = operation
+	}
+	return document
+}
+
+// middlewareRoutes returns the routes registered with Use or Group. Fiber lists
+// them under the methods they match, like endpoints, but endpoints share no
+// handlers with them.
+func middlewareRoutes(app *fiber.App) []fiber.Route {
+	endpoints := map[*fiber.Handler]bool{}
+	for _, route := range app.GetRoutes(true) {
+		if len(route.Handlers) > 0 {
+			endpoints[&route.Handlers[0]] = true
+		}
+	}
+	var middleware []fiber.Route
+	for _, route := range app.GetRoutes() {
+		if len(route.Handlers) > 0 && !endpoints[&route.Handlers[0]] {
+			middleware = append(middleware, route)
+		}
+	}
+	return middleware
+}
+
+// openAPIPath converts a Fiber path such as /activities/:id to /activities/{id}
+// and returns its path parameters
+func openAPIPath(path string) (string, []Parameter) {
+	var params []Parameter
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		name, ok := strings.CutPrefix(segment, ":")
+		if !ok {
+			continue
+		}
+		name = strings.TrimRight(name, "?+*")
+		segments[i] = "{" + name + "}"
+		schema := Schema{Type: "string"}
+		if name == "id" || strings.HasSuffix(name, "_id") {
+			schema.Type = "integer"
+		}
+		params = append(params, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// requestBody describes the body a handler's doc comment documents
+func requestBody(doc handlerDoc) *RequestBody {
+	switch {
+	case doc.Form != "":
+		return &RequestBody{
+			Description: doc.Form,
+			Content:     map[string]MediaType{"multipart/form-data": {Schema: &Schema{Type: "object"}}},
+		}
+	case doc.Body != "":
+		media := MediaType{Schema: &Schema{Type: "object"}}
+		if json.Valid([]byte(doc.Body)) {
+			media.Example = json.RawMessage(doc.Body)
+		}
+		return &RequestBody{Description: doc.Body, Content: map[string]MediaType{fiber.MIMEApplicationJSON: media}}
+	}
+	return nil
+}
+
+// guard returns the security of the first authenticating middleware in handlers
+func guard(handlers []fiber.Handler) []map[string][]string {
+	for _, handler := range handlers {
+		name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+		for function, security := range guards {
+			if strings.Contains(name, function+".") {
+				return security
+			}
+		}
+	}
+	return nil
+}
+
+// guardOf returns the security of the longest guarded prefix of path
+func guardOf(path string, prefixes map[string][]map[string][]string) []map[string][]string {
+	var longest string
+	for prefix := range prefixes {
+		if (path == prefix || strings.HasPrefix(path, prefix+"/")) && len(prefix) > len(longest) {
+			longest = prefix
+		}
+	}
+	return prefixes[longest]
+}
+
+// handlerName returns a handler's name as handler docs are keyed: Type.Method
+// for methods, the function name otherwise
+func handlerName(handler fiber.Handler) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	name = strings.TrimSuffix(name, "-fm")
+	name = name[strings.LastIndex(name, "/")+1:]
+	// handlers.(*ChatHandler).StreamChat
+	parts := strings.Split(name, ".")
+	if len(parts) >= 3 {
+		return strings.Trim(parts[1], "(*)") + "." + parts[2]
+	}
+	return parts[len(parts)-1]
+}
+
+// tag groups operations by the first segment after /api/v1, e.g. activities
+func tag(path string) string {
+	rest := strings.TrimPrefix(path, "/api/v1")
+	segments := strings.Split(strings.Trim(rest, "/"), "/")
+	if segments[0] == "admin" && len(segments) > 1 {
+		return "admin " + segments[1]
+	}
+	if segments[0] == "" {
+		return "root"
+	}
+	return segments[0]
+}
+
+// operationID is the handler's method or function name, numbered for handlers
+// serving several routes. Closures, which have no name of their own, are named
+// after their route: getApiV1Health.
+func operationID(name string, route fiber.Route, used map[string]int) string {
+	id := name[strings.LastIndex(name, ".")+1:]
+	if _, ok := handlerDocs[name]; !ok && (id == "" || strings.HasPrefix(id, "func")) {
+		id = strings.ToLower(route.Method)
+		for _, segment := range strings.FieldsFunc(route.Path, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			id += strings.ToUpper(segment[:1]) + segment[1:]
+		}
+	}
+	used[id]++
+	if n := used[id]; n > 1 {
+		return id + strconv.Itoa(n)
+	}
+	return id
+}
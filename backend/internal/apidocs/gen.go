@@ -0,0 +1,295 @@
+//go:build ignore
+
+// gen reads the doc comments of the handlers in internal/handlers and writes
+// them to handlers_gen.go, where Build finds them by handler name. Run it with
+// go generate after changing a handler's comment.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Sections of handler doc comments, ending at the next blank line
+const (
+	sectionQuery   = "Query parameters:"
+	sectionBody    = "Request body:"
+	sectionForm    = "Form fields:"
+	sectionReturns = "Returns:"
+)
+
+var (
+	responsePattern = regexp.MustCompile(`^- (\d{3}): (.*)$`)
+	paramPattern    = regexp.MustCompile(`^[a-z][a-z0-9_.]*$`)
+)
+
+// doc is the parsed doc comment of a handler
+type doc struct {
+	summary     string
+	description string
+	query       []param
+	body        string
+	form        string
+	responses   [][2]string
+}
+
+// param is a query parameter named in a doc comment
+type param struct {
+	name        string
+	description string
+	required    bool
+}
+
+func main() {
+	files, err := filepath.Glob("../handlers/*.go")
+	if err != nil {
+		log.Fatal(err)
+	}
+	docs := map[string]doc{}
+	fset := token.NewFileSet()
+	for _, path := range files {
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Doc == nil || !fn.Name.IsExported() || !isHandler(fn) {
+				continue
+			}
+			docs[handlerKey(fn)] = parseDoc(fn.Name.Name, fn.Doc.Text())
+		}
+	}
+
+	out, err := format.Source(render(docs))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("handlers_gen.go", out, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// isHandler reports whether a function is a Fiber handler, taking a *fiber.Ctx
+func isHandler(fn *ast.FuncDecl) bool {
+	params := fn.Type.Params.List
+	if len(params) != 1 || len(params[0].Names) > 1 {
+		return false
+	}
+	star, ok := params[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := star.X.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == "Ctx"
+}
+
+// handlerKey is the name of a handler as Build sees it: Type.Method or Func
+func handlerKey(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	recv := fn.Recv.List[0].Type
+	if star, ok := recv.(*ast.StarExpr); ok {
+		recv = star.X
+	}
+	return recv.(*ast.Ident).Name + "." + fn.Name.Name
+}
+
+// parseDoc splits a doc comment into its description and sections
+func parseDoc(name, text string) doc {
+	var d doc
+	var description []string
+	for _, paragraph := range strings.Split(strings.TrimSpace(text), "\n\n") {
+		lines := strings.Split(paragraph, "\n")
+		head := lines[0]
+		rest := strings.Join(trimLines(append([]string{after(head)}, lines[1:]...)), " ")
+		switch {
+		case strings.HasPrefix(head, sectionQuery):
+			d.query = parseParams(rest)
+		case strings.HasPrefix(head, sectionBody):
+			d.body = rest
+		case strings.HasPrefix(head, sectionForm):
+			d.form = rest
+		case strings.HasPrefix(head, sectionReturns):
+			d.responses = parseResponses(lines[1:])
+		default:
+			description = append(description, strings.Join(trimLines(lines), " "))
+		}
+	}
+
+	// Comments start with the handler's name: "ListActivities returns ..."
+	d.description = strings.Join(description, "\n\n")
+	if rest, ok := strings.CutPrefix(d.description, name+" "); ok {
+		r, size := utf8.DecodeRuneInString(rest)
+		d.description = string(unicode.ToUpper(r)) + rest[size:]
+	}
+	// The first sentence is the summary, and the description only repeats it
+	// when there is more
+	d.summary = firstSentence(d.description)
+	if strings.TrimSuffix(d.description, ".") == d.summary {
+		d.description = ""
+	}
+	return d
+}
+
+// firstSentence returns the first sentence of text without its period
+func firstSentence(text string) string {
+	if i := strings.Index(text, "\n"); i >= 0 {
+		text = text[:i]
+	}
+	for i := 0; ; {
+		end := strings.Index(text[i:], ". ")
+		if end < 0 {
+			return strings.TrimSuffix(text, ".")
+		}
+		end += i
+		if !strings.HasSuffix(text[:end], "e.g") && !strings.HasSuffix(text[:end], "i.e") {
+			return text[:end]
+		}
+		i = end + 2
+	}
+}
+
+// after returns the text after the colon of a section's first line
+func after(line string) string {
+	_, rest, _ := strings.Cut(line, ":")
+	return rest
+}
+
+// trimLines trims the lines of a paragraph and drops empty ones
+func trimLines(lines []string) []string {
+	var trimmed []string
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			trimmed = append(trimmed, line)
+		}
+	}
+	return trimmed
+}
+
+// parseParams reads "a, b (default 10), c and d with e (required)": a
+// parenthesis describes the name before it, and a coordinate pair written
+// "lat, lng (required)" shares the description of lng
+func parseParams(text string) []param {
+	var params []param
+	for _, item := range splitTopLevel(text) {
+		names, description, _ := strings.Cut(item, "(")
+		description = strings.TrimSuffix(strings.TrimSpace(description), ")")
+		var found []param
+		for _, name := range strings.Fields(names) {
+			if name == "and" || name == "with" || name == "or" || !paramPattern.MatchString(name) {
+				continue
+			}
+			found = append(found, param{name: name})
+		}
+		if len(found) == 0 {
+			continue
+		}
+		last := &found[len(found)-1]
+		last.description, last.required = description, strings.HasPrefix(description, "required")
+		if n := len(params); n > 0 && len(found) == 1 && last.name == "lng" && params[n-1].name == "lat" && params[n-1].description == "" {
+			params[n-1].description, params[n-1].required = last.description, last.required
+		}
+		params = append(params, found...)
+	}
+	return params
+}
+
+// splitTopLevel splits text at commas outside parentheses
+func splitTopLevel(text string) []string {
+	var items []string
+	depth, start := 0, 0
+	for i, r := range text {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, strings.TrimSpace(text[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(items, strings.TrimSpace(text[start:]))
+}
+
+// parseResponses reads "- 200: description" lines, continued on the lines below
+func parseResponses(lines []string) [][2]string {
+	var responses [][2]string
+	for _, line := range trimLines(lines) {
+		if m := responsePattern.FindStringSubmatch(line); m != nil {
+			responses = append(responses, [2]string{m[1], m[2]})
+		} else if len(responses) > 0 {
+			responses[len(responses)-1][1] += " " + line
+		}
+	}
+	return responses
+}
+
+// render writes the docs as the handlerDocs map
+func render(docs map[string]doc) []byte {
+	keys := make([]string, 0, len(docs))
+	for key := range docs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by gen.go from the doc comments of internal/handlers; DO NOT EDIT.\n\n")
+	b.WriteString("package apidocs\n\n")
+	b.WriteString("var handlerDocs = map[string]handlerDoc{\n")
+	for _, key := range keys {
+		d := docs[key]
+		fmt.Fprintf(&b, "%q: {\n", key)
+		fmt.Fprintf(&b, "Summary: %s,\n", strconv.Quote(d.summary))
+		if d.description != "" {
+			fmt.Fprintf(&b, "Description: %s,\n", strconv.Quote(d.description))
+		}
+		if len(d.query) > 0 {
+			b.WriteString("Query: []queryParam{\n")
+			for _, p := range d.query {
+				fmt.Fprintf(&b, "{Name: %q", p.name)
+				if p.description != "" {
+					fmt.Fprintf(&b, ", Description: %s", strconv.Quote(p.description))
+				}
+				if p.required {
+					b.WriteString(", Required: true")
+				}
+				b.WriteString("},\n")
+			}
+			b.WriteString("},\n")
+		}
+		if d.body != "" {
+			fmt.Fprintf(&b, "Body: %s,\n", strconv.Quote(d.body))
+		}
+		if d.form != "" {
+			fmt.Fprintf(&b, "Form: %s,\n", strconv.Quote(d.form))
+		}
+		if len(d.responses) > 0 {
+			b.WriteString("Responses: []docResponse{\n")
+			for _, r := range d.responses {
+				fmt.Fprintf(&b, "{Status: %q, Description: %s},\n", r[0], strconv.Quote(r[1]))
+			}
+			b.WriteString("},\n")
+		}
+		b.WriteString("},\n")
+	}
+	b.WriteString("}\n")
+	return b.Bytes()
+}
@@ -0,0 +1,1475 @@
+// Code generated by gen.go from the doc comments of internal/handlers; DO NOT EDIT.
+
+package apidocs
+
+var handlerDocs = map[string]handlerDoc{
+	"APIKeyHandler.CreateKey": {
+		Summary:     "Creates an API key scripts send in the X-API-Key header to act as the user",
+		Description: "Creates an API key scripts send in the X-API-Key header to act as the user. Keys cannot create further keys, so a leaked key can be revoked without leaving others behind.",
+		Body:        "{\"name\": \"...\"}",
+		Responses: []docResponse{
+			{Status: "201", Description: "The key record and the API key, which is only shown once"},
+			{Status: "400", Description: "Invalid input data"},
+			{Status: "403", Description: "Request authenticated with an API key"},
+			{Status: "409", Description: "Too many active keys"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"APIKeyHandler.ListKeys": {
+		Summary: "Returns the user's API keys without their secrets",
+		Responses: []docResponse{
+			{Status: "200", Description: "API keys, newest first"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"APIKeyHandler.RevokeKey": {
+		Summary: "Revokes one of the user's API keys; it stops working at once",
+		Responses: []docResponse{
+			{Status: "200", Description: "Key revoked"},
+			{Status: "400", Description: "Invalid key ID"},
+			{Status: "404", Description: "Key not found or already revoked"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"AbuseSignalHandler.ListAbuseSignals": {
+		Summary: "Returns a paginated list of abuse signals, newest first, such as clients slowed down or blocked for flooding the chat (admin only)",
+		Query: []queryParam{
+			{Name: "kind", Description: "message_flood or message_flood_block"},
+			{Name: "page"},
+			{Name: "page_size"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Abuse signals with pagination metadata"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"AccountHandler.ConfirmEmailChange": {
+		Summary: "Changes a user's email to the address a confirmation token was sent to",
+		Body:    "{\"token\": \"...\"}",
+		Responses: []docResponse{
+			{Status: "200", Description: "Updated user"},
+			{Status: "400", Description: "Invalid, expired or already used token"},
+			{Status: "409", Description: "Email registered by another account in the meantime"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"AccountHandler.DeleteAccount": {
+		Summary:     "Deletes the authenticated user's account",
+		Description: "Deletes the authenticated user's account. Conversations are kept anonymized and submitted activities stay without a submitter; personal data, favorites, notifications and API keys are removed. It cannot be used with an API key.",
+		Responses: []docResponse{
+			{Status: "200", Description: "Account deleted"},
+			{Status: "403", Description: "API key used"},
+			{Status: "404", Description: "Account already deleted"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"AccountHandler.GetAccount": {
+		Summary: "Returns the authenticated user's account",
+		Responses: []docResponse{
+			{Status: "200", Description: "User"},
+			{Status: "404", Description: "Account deleted"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"AccountHandler.RequestEmailChange": {
+		Summary:     "Sends a confirmation link to a new email address and a notice to the current one; the email changes once the link is followed",
+		Description: "Sends a confirmation link to a new email address and a notice to the current one; the email changes once the link is followed. It needs the current password and cannot be used with an API key.",
+		Body:        "{\"email\": \"new@example.com\", \"current_password\": \"...\"}",
+		Responses: []docResponse{
+			{Status: "202", Description: "New address and when the link expires"},
+			{Status: "400", Description: "Invalid email or the current one"},
+			{Status: "403", Description: "Wrong current password, account without a password or API key"},
+			{Status: "409", Description: "Email already registered"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"AccountHandler.UpdateAccount": {
+		Summary: "Updates the authenticated user's profile",
+		Body:    "{\"name\": \"Alex\"}",
+		Responses: []docResponse{
+			{Status: "200", Description: "Updated user"},
+			{Status: "400", Description: "Invalid input data"},
+			{Status: "404", Description: "Account deleted"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ActivityHandler.CreateActivity": {
+		Summary: "Creates a new activity owned by the authenticated user, pending approval unless the user is trusted",
+		Responses: []docResponse{
+			{Status: "201", Description: "Successfully created activity"},
+			{Status: "400", Description: "Invalid input data"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ActivityHandler.CreateVariant": {
+		Summary:     "Creates a seasonal variant of an activity owned by the authenticated user, e.g. the winter route of a summer trail, at the activity's location",
+		Description: "Creates a seasonal variant of an activity owned by the authenticated user, e.g. the winter route of a summer trail, at the activity's location. The body is an activity with its seasons; the location is ignored.",
+		Responses: []docResponse{
+			{Status: "201", Description: "Successfully created variant"},
+			{Status: "400", Description: "Invalid ID or input data, or seasons missing"},
+			{Status: "403", Description: "Activity submitted by another user"},
+			{Status: "404", Description: "Activity not found"},
+			{Status: "409", Description: "Another variant is offered in one of the seasons"},
+		},
+	},
+	"ActivityHandler.DeleteActivity": {
+		Summary: "Soft-deletes an activity; moderators can delete any activity",
+		Responses: []docResponse{
+			{Status: "200", Description: "Activity deleted"},
+			{Status: "400", Description: "Invalid activity ID"},
+			{Status: "403", Description: "Activity submitted by another user"},
+			{Status: "404", Description: "Activity not found"},
+		},
+	},
+	"ActivityHandler.ExportActivities": {
+		Summary:     "Streams approved activities as a download for other community tools and mapping apps",
+		Description: "Streams approved activities as a download for other community tools and mapping apps. The export is written as it is read, so a failure partway through cuts it off.",
+		Query: []queryParam{
+			{Name: "format", Description: "geojson, the default, or csv"},
+			{Name: "category"},
+			{Name: "difficulty"},
+			{Name: "include_pending", Description: "moderators only"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "GeoJSON FeatureCollection or CSV with a header row"},
+			{Status: "400", Description: "Unsupported format"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ActivityHandler.GetActivity": {
+		Summary:     "Returns a single activity with its approved images, routes and review highlights",
+		Description: "Returns a single activity with its approved images, routes and review highlights. Activities pending moderation are only shown to moderators and to their submitter. Moderators may add include_pending=true to see unapproved images too.",
+		Responses: []docResponse{
+			{Status: "200", Description: "Activity details"},
+			{Status: "400", Description: "Invalid activity ID"},
+			{Status: "404", Description: "Activity not found or not approved"},
+		},
+	},
+	"ActivityHandler.GetNearby": {
+		Summary:     "Returns approved activities near a point, closest first",
+		Description: "Returns approved activities near a point, closest first. Searches by users who opted in to location history are recorded as coarse areas.",
+		Query: []queryParam{
+			{Name: "lat", Description: "required", Required: true},
+			{Name: "lng", Description: "required", Required: true},
+			{Name: "radius_km", Description: "default 10, max 200"},
+			{Name: "category"},
+			{Name: "difficulty"},
+			{Name: "limit"},
+			{Name: "weather", Description: "true adds the current weather at each activity when forecasts are enabled"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Activities with their distance in kilometers"},
+			{Status: "400", Description: "Missing or invalid coordinates"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ActivityHandler.GetSimilar": {
+		Summary: "Returns activities similar to the given one (\"you might also like\"), leaving out the activities a signed-in user already saved",
+		Query: []queryParam{
+			{Name: "limit", Description: "default 5, max 20"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "List of similar activities ordered by score"},
+			{Status: "400", Description: "Invalid activity ID"},
+			{Status: "404", Description: "Activity not found"},
+		},
+	},
+	"ActivityHandler.GetTrending": {
+		Summary: "Returns the activities trending this week by their page views, new favorites, chat mentions and short link scans, recent days weighing most",
+		Query: []queryParam{
+			{Name: "category", Description: "slug"},
+			{Name: "limit", Description: "default 10, max 50"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Activities with their popularity_score and their views, favorites, mentions and scans of the last 7 days, highest score first"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ActivityHandler.ListActivities": {
+		Summary: "Returns a paginated list of approved activities",
+		Query: []queryParam{
+			{Name: "category", Description: "ID, slug or name; includes subcategories"},
+			{Name: "difficulty"},
+			{Name: "sort", Description: "newest, the default, or popular"},
+			{Name: "page"},
+			{Name: "page_size"},
+			{Name: "include_pending", Description: "moderators only"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Activities with pagination metadata"},
+			{Status: "400", Description: "Unsupported sort order"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ActivityHandler.SearchActivities": {
+		Summary: "Returns approved activities matching a full-text search, best matches first, with matching words in bold in the headline and snippet",
+		Query: []queryParam{
+			{Name: "q", Description: "required; supports \"quoted phrases\", or and -word", Required: true},
+			{Name: "category"},
+			{Name: "difficulty"},
+			{Name: "lat"},
+			{Name: "lng"},
+			{Name: "radius_km", Description: "default 25, max 200"},
+			{Name: "page"},
+			{Name: "page_size"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Search results with pagination metadata"},
+			{Status: "400", Description: "Missing query or invalid coordinates"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ActivityHandler.SemanticSearch": {
+		Summary: "Returns approved activities closest in meaning to a description such as \"somewhere quiet with waterfalls\", most similar first",
+		Query: []queryParam{
+			{Name: "q", Description: "required", Required: true},
+			{Name: "category"},
+			{Name: "difficulty"},
+			{Name: "limit", Description: "default 10, max 50"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Activities with their similarity to the query"},
+			{Status: "400", Description: "Missing query or invalid limit"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ActivityHandler.UpdateActivity": {
+		Summary: "Replaces the editable fields of an activity; moderators can edit any activity",
+		Responses: []docResponse{
+			{Status: "200", Description: "Updated activity"},
+			{Status: "400", Description: "Invalid ID or input data"},
+			{Status: "403", Description: "Activity submitted by another user"},
+			{Status: "404", Description: "Activity not found"},
+		},
+	},
+	"ActivityImportHandler.ImportActivities": {
+		Summary: "Imports and publishes activities, skipping invalid and duplicate rows; the valid rows are inserted together (admin only)",
+		Query: []queryParam{
+			{Name: "dry_run", Description: "validate without importing"},
+			{Name: "mapping", Description: "column=field pairs separated by commas, e.g. \"Trail=name,Type=category\""},
+		},
+		Body: "CSV with a header row (Content-Type: text/csv) or a GeoJSON FeatureCollection (Content-Type: application/geo+json or application/json)",
+		Responses: []docResponse{
+			{Status: "200", Description: "Import counts and the rows that were skipped"},
+			{Status: "400", Description: "Unreadable body, invalid mapping or too many rows"},
+			{Status: "415", Description: "Unsupported content type"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"AuthHandler.AcceptInvitation": {
+		Summary: "Sets the password of a user invited by an import and returns tokens",
+		Body:    "{\"token\": \"...\", \"password\": \"...\"}",
+		Responses: []docResponse{
+			{Status: "200", Description: "User and token pair"},
+			{Status: "400", Description: "Invalid input data"},
+			{Status: "401", Description: "Invalid, expired or already accepted invitation"},
+		},
+	},
+	"AuthHandler.Login": {
+		Summary: "Exchanges an email and password for tokens",
+		Responses: []docResponse{
+			{Status: "200", Description: "User and token pair"},
+			{Status: "400", Description: "Invalid input data"},
+			{Status: "401", Description: "Invalid email or password"},
+		},
+	},
+	"AuthHandler.Me": {
+		Summary: "Returns the authenticated user",
+		Responses: []docResponse{
+			{Status: "200", Description: "User"},
+			{Status: "401", Description: "Not authenticated"},
+		},
+	},
+	"AuthHandler.Refresh": {
+		Summary: "Exchanges a refresh token for a new token pair",
+		Body:    "{\"refresh_token\": \"...\"}",
+		Responses: []docResponse{
+			{Status: "200", Description: "New token pair"},
+			{Status: "401", Description: "Invalid or expired refresh token"},
+		},
+	},
+	"AuthHandler.Register": {
+		Summary: "Creates an account and returns tokens for it",
+		Responses: []docResponse{
+			{Status: "201", Description: "User and token pair"},
+			{Status: "400", Description: "Invalid input data"},
+			{Status: "409", Description: "Email already registered"},
+		},
+	},
+	"AuthHandler.RequestPasswordReset": {
+		Summary:     "Emails a link to choose a new password",
+		Description: "Emails a link to choose a new password. The response is the same whether or not the address has an account.",
+		Body:        "{\"email\": \"user@example.com\"}",
+		Responses: []docResponse{
+			{Status: "202", Description: "Link sent if the address has an account"},
+			{Status: "400", Description: "Invalid input data"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"AuthHandler.RequestVerification": {
+		Summary: "Sends the authenticated user another link to verify their email address",
+		Responses: []docResponse{
+			{Status: "202", Description: "When the link expires"},
+			{Status: "409", Description: "Email already verified"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"AuthHandler.ResetPassword": {
+		Summary: "Sets a new password with a reset token and returns tokens",
+		Body:    "{\"token\": \"...\", \"password\": \"...\"}",
+		Responses: []docResponse{
+			{Status: "200", Description: "User and token pair"},
+			{Status: "400", Description: "Invalid input data, or invalid, expired or used token"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"AuthHandler.VerifyEmail": {
+		Summary: "Verifies the email address a verification token was sent to",
+		Body:    "{\"token\": \"...\"}",
+		Responses: []docResponse{
+			{Status: "200", Description: "Verified user"},
+			{Status: "400", Description: "Invalid or expired token"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"CapabilitiesHandler.GetCapabilities": {
+		Summary: "Returns the chat protocol and output contract",
+		Responses: []docResponse{
+			{Status: "200", Description: "Capabilities document"},
+		},
+	},
+	"CategoryHandler.CreateCategory": {
+		Summary: "Creates a category (admin only)",
+		Body:    "{\"name\": \"Trail running\", \"slug\": \"trail-running\", \"parent_id\": 1}",
+		Responses: []docResponse{
+			{Status: "201", Description: "Created category"},
+			{Status: "400", Description: "Invalid input, slug or parent"},
+			{Status: "409", Description: "Slug already taken"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"CategoryHandler.DeleteCategory": {
+		Summary: "Deletes a category without subcategories or activities (admin only)",
+		Responses: []docResponse{
+			{Status: "200", Description: "Category deleted"},
+			{Status: "400", Description: "Invalid category ID"},
+			{Status: "404", Description: "Category not found"},
+			{Status: "409", Description: "Category has subcategories or activities"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"CategoryHandler.GetCategory": {
+		Summary: "Returns a category",
+		Responses: []docResponse{
+			{Status: "200", Description: "Category"},
+			{Status: "400", Description: "Invalid category ID"},
+			{Status: "404", Description: "Category not found"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"CategoryHandler.ListCategories": {
+		Summary: "Returns the category hierarchy: top-level categories with their subcategories nested in children",
+		Responses: []docResponse{
+			{Status: "200", Description: "Categories"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"CategoryHandler.UpdateCategory": {
+		Summary: "Replaces the name, slug and parent of a category (admin only)",
+		Responses: []docResponse{
+			{Status: "200", Description: "Updated category"},
+			{Status: "400", Description: "Invalid ID, input, slug or parent"},
+			{Status: "404", Description: "Category not found"},
+			{Status: "409", Description: "Slug already taken"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ChatHandler.ChatCompletions": {
+		Summary:     "Answers OpenAI chat completion requests through the chat pipeline, so clients that only speak the OpenAI API get the same retrieval, tools and moderation as the chat stream",
+		Description: "Answers OpenAI chat completion requests through the chat pipeline, so clients that only speak the OpenAI API get the same retrieval, tools and moderation as the chat stream. Requests are stateless: the earlier messages are the conversation, and client system messages are ignored. Tool calls and other chat events are not sent; with stream set the answer text streams as chat.completion.chunk events ending in [DONE]. Personal API keys are accepted as bearer tokens. Runs over the daily quota fail with 429. Answers are in the language of lang, the user's preferences or the Accept-Language header, see chat.Run.AnswerLanguage.",
+		Query: []queryParam{
+			{Name: "lang", Description: "en, de, fr, es or it"},
+		},
+		Body: "model, messages ending in a user message, stream and stream_options.include_usage",
+		Responses: []docResponse{
+			{Status: "200", Description: "chat.completion, or text/event-stream of chat.completion.chunk"},
+			{Status: "400", Description: "Invalid body or lang, or last message not from the user"},
+			{Status: "429", Description: "Rate limit or quota exceeded"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ChatHandler.CreateRun": {
+		Summary:     "Creates a chat run from a JSON body, like StreamChatPost, and returns a short-lived token whose stream EventSource clients open with StreamRun",
+		Description: "Creates a chat run from a JSON body, like StreamChatPost, and returns a short-lived token whose stream EventSource clients open with StreamRun. The message stays out of URLs, and the run keeps the user, session and community of this request, which EventSource cannot send.",
+		Responses: []docResponse{
+			{Status: "201", Description: "Run token and the URL of its stream"},
+			{Status: "400", Description: "Invalid JSON body or language, or missing message or conversation ID to continue"},
+			{Status: "429", Description: "Rate limit exceeded"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ChatHandler.DryRun": {
+		Summary:     "Runs a message through the chat pipeline without calling the model or storing anything, and returns the prompt the model would get, the activities retrieved for it and the tools it could call",
+		Description: "Runs a message through the chat pipeline without calling the model or storing anything, and returns the prompt the model would get, the activities retrieved for it and the tools it could call. With user_id and conversation_id the run is personalized and continues that conversation (admin only).",
+		Body:        "{\"message\": \"Easy hikes near the lake?\", \"user_id\": 42, \"conversation_id\": \"...\", \"context\": {...}}",
+		Responses: []docResponse{
+			{Status: "200", Description: "Prompt, retrieved activities, offered tools and emitted events"},
+			{Status: "400", Description: "Invalid input data"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ChatHandler.GetDebugPage": {
+		Summary: "Returns a built-in chat page for checking the stream without the frontend; it is only served in development with DEBUG_CHAT set",
+		Responses: []docResponse{
+			{Status: "200", Description: "HTML page"},
+		},
+	},
+	"ChatHandler.StopChat": {
+		Summary:     "Stops generating the answer streamed for a conversation",
+		Description: "Stops generating the answer streamed for a conversation. The stream ends with a STREAMING_END event with reason \"cancelled\"; the part of the answer sent so far is kept and can be continued. Users stop their own answers and guests those of their session. Answers are stopped on the replica streaming them.",
+		Responses: []docResponse{
+			{Status: "200", Description: "Generation stopped"},
+			{Status: "404", Description: "No answer being generated for the conversation"},
+		},
+	},
+	"ChatHandler.StreamChat": {
+		Summary:     "Handles the AG-UI streaming chat endpoint for EventSource clients, which can only send the message as a query parameter",
+		Description: "Handles the AG-UI streaming chat endpoint for EventSource clients, which can only send the message as a query parameter. continue=true with a conversation_id finishes the conversation's interrupted answer instead. Answers are in the language of lang, the user's preferences or the Accept-Language header, see chat.Run.AnswerLanguage.",
+		Query: []queryParam{
+			{Name: "message"},
+			{Name: "conversation_id"},
+			{Name: "continue"},
+			{Name: "lang", Description: "en, de, fr, es or it"},
+		},
+	},
+	"ChatHandler.StreamChatPost": {
+		Summary: "Handles the AG-UI streaming chat endpoint with a JSON body, which keeps messages out of URLs and proxy logs and has no query-string length limit",
+		Responses: []docResponse{
+			{Status: "200", Description: "text/event-stream of AG-UI events"},
+			{Status: "400", Description: "Invalid JSON body or language, or missing message or conversation ID to continue"},
+			{Status: "429", Description: "Rate limit exceeded"},
+		},
+	},
+	"ChatHandler.StreamRun": {
+		Summary:     "Streams the run of a token from CreateRun",
+		Description: "Streams the run of a token from CreateRun. Each token starts one stream; reconnections with Last-Event-ID resume it for as long as the stream can be resumed.",
+		Responses: []docResponse{
+			{Status: "200", Description: "text/event-stream of AG-UI events"},
+			{Status: "204", Description: "Reconnection to a stream that cannot be resumed"},
+			{Status: "404", Description: "Unknown, expired or already used run token"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ChecklistHandler.DeleteOverride": {
+		Summary: "Removes a checklist override, restoring the generated item (admin only)",
+		Responses: []docResponse{
+			{Status: "200", Description: "Override deleted"},
+			{Status: "400", Description: "Invalid ID"},
+			{Status: "404", Description: "Override not found"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ChecklistHandler.GetChecklist": {
+		Summary: "Returns the packing checklist of an activity",
+		Query: []queryParam{
+			{Name: "date", Description: "YYYY-MM-DD, default today"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Items with quantities and reasons, and the forecast they were adjusted to"},
+			{Status: "400", Description: "Invalid activity ID or date"},
+			{Status: "404", Description: "Activity not found"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ChecklistHandler.ListOverrides": {
+		Summary: "Returns the checklist overrides of an activity (admin only)",
+		Responses: []docResponse{
+			{Status: "200", Description: "Overrides, all-season ones first"},
+			{Status: "400", Description: "Invalid activity ID"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ChecklistHandler.SetOverride": {
+		Summary:     "Adds or replaces an item of an activity's checklist, or removes one with remove=true, in one season or all seasons when season is empty",
+		Description: "Adds or replaces an item of an activity's checklist, or removes one with remove=true, in one season or all seasons when season is empty. An override replaces the previous one for the same item and season (admin only).",
+		Body:        "{\"season\": \"winter\", \"item\": \"Microspikes\", \"note\": \"the north side stays icy\"}",
+		Responses: []docResponse{
+			{Status: "201", Description: "The stored override"},
+			{Status: "400", Description: "Invalid activity ID, item or season"},
+			{Status: "404", Description: "Activity not found"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ClimateHandler.GetClimate": {
+		Summary:     "Returns the climate normals of a location: average temperatures, precipitation and rainy days per month over recent years",
+		Description: "Returns the climate normals of a location: average temperatures, precipitation and rainy days per month over recent years. Normals are computed for a grid cell of about 25 km around the location.",
+		Query: []queryParam{
+			{Name: "lat", Description: "required", Required: true},
+			{Name: "lng", Description: "required", Required: true},
+			{Name: "month", Description: "1-12, default all months"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Normals of the location's grid cell"},
+			{Status: "400", Description: "Invalid location or month"},
+			{Status: "502", Description: "Weather history download failed"},
+		},
+	},
+	"CollectionHandler.CreateCollection": {
+		Summary:     "Creates a collection of the signed-in user",
+		Description: "Creates a collection of the signed-in user. Collections of moderators are marked curated.",
+		Body:        "{\"title\": \"Rainy day options\", \"description\": \"...\", \"visibility\": \"public\", \"items\": [{\"activity_id\": 1, \"note\": \"Free on Sundays\"}]}",
+		Responses: []docResponse{
+			{Status: "201", Description: "Created collection"},
+			{Status: "400", Description: "Invalid input or activities"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"CollectionHandler.DeleteCollection": {
+		Summary: "Deletes a collection (owner or moderators)",
+		Responses: []docResponse{
+			{Status: "200", Description: "Collection deleted"},
+			{Status: "400", Description: "Invalid collection ID"},
+			{Status: "403", Description: "Collection belongs to another user"},
+			{Status: "404", Description: "Collection not found"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"CollectionHandler.FollowCollection": {
+		Summary: "Follows a public collection; following it again is not an error",
+		Responses: []docResponse{
+			{Status: "200", Description: "The collection with its follower count"},
+			{Status: "400", Description: "Invalid collection ID or private collection"},
+			{Status: "404", Description: "Collection not found"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"CollectionHandler.GetCollection": {
+		Summary: "Returns a collection with its activities in order; private collections are only found for their owner",
+		Responses: []docResponse{
+			{Status: "200", Description: "Collection with its items and their activity"},
+			{Status: "400", Description: "Invalid collection ID"},
+			{Status: "404", Description: "Collection not found"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"CollectionHandler.ListCollections": {
+		Summary: "Returns public collections, curated and most followed first",
+		Query: []queryParam{
+			{Name: "q", Description: "words in titles and descriptions"},
+			{Name: "user_id"},
+			{Name: "page"},
+			{Name: "page_size"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Collections without their items, with pagination metadata"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"CollectionHandler.ListFollowedCollections": {
+		Summary: "Returns the public collections the signed-in user follows",
+		Query: []queryParam{
+			{Name: "page"},
+			{Name: "page_size"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Collections without their items, with pagination metadata"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"CollectionHandler.ListMyCollections": {
+		Summary: "Returns the signed-in user's collections, including private ones",
+		Query: []queryParam{
+			{Name: "page"},
+			{Name: "page_size"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Collections without their items, with pagination metadata"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"CollectionHandler.UnfollowCollection": {
+		Summary: "Stops following a collection; unfollowing one that is not followed is not an error",
+		Responses: []docResponse{
+			{Status: "200", Description: "Collection unfollowed"},
+			{Status: "400", Description: "Invalid collection ID"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"CollectionHandler.UpdateCollection": {
+		Summary: "Replaces the title, description, visibility and items of a collection (owner or moderators)",
+		Responses: []docResponse{
+			{Status: "200", Description: "Updated collection"},
+			{Status: "400", Description: "Invalid ID, input or activities"},
+			{Status: "403", Description: "Collection belongs to another user"},
+			{Status: "404", Description: "Collection not found"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"CommentHandler.CreateComment": {
+		Summary:     "Adds a comment to an activity",
+		Description: "Adds a comment to an activity. Users mentioned with @[Name](user:<id>) markup are notified; the response lists them under mentions with their offsets in the body.",
+		Responses: []docResponse{
+			{Status: "201", Description: "Comment stored"},
+			{Status: "400", Description: "Invalid activity ID, input data or mentions"},
+			{Status: "404", Description: "Activity not found or not approved"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"CommentHandler.DeleteComment": {
+		Summary: "Deletes the user's comment; moderators can delete any comment",
+		Responses: []docResponse{
+			{Status: "200", Description: "Comment deleted"},
+			{Status: "400", Description: "Invalid ID"},
+			{Status: "403", Description: "Comment written by another user"},
+			{Status: "404", Description: "Comment not found"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"CommentHandler.ListComments": {
+		Summary: "Returns a paginated list of an activity's comments, oldest first",
+		Query: []queryParam{
+			{Name: "page"},
+			{Name: "page_size"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Comments with their mentions and pagination metadata"},
+			{Status: "400", Description: "Invalid activity ID"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ConditionHandler.ListConditions": {
+		Summary: "Returns the latest condition reports for an activity",
+		Responses: []docResponse{
+			{Status: "200", Description: "Condition reports, newest first"},
+			{Status: "400", Description: "Invalid activity ID"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ConditionHandler.ReportCondition": {
+		Summary:     "Records the current condition of an activity",
+		Description: "Records the current condition of an activity. Closed reports alert users whose home search radius includes the activity.",
+		Responses: []docResponse{
+			{Status: "201", Description: "Report stored"},
+			{Status: "400", Description: "Invalid activity ID or input data"},
+			{Status: "404", Description: "Activity not found"},
+		},
+	},
+	"ConsentHandler.GrantConsent": {
+		Summary: "Records the user's consent to a purpose",
+		Body:    "{\"version\": 1} (optional, default the current version)",
+		Responses: []docResponse{
+			{Status: "200", Description: "Updated consent"},
+			{Status: "400", Description: "Invalid body or unknown version"},
+			{Status: "404", Description: "Unknown purpose"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ConsentHandler.ListConsents": {
+		Summary: "Returns the user's consent to each purpose with the purpose's description and current version; outdated consents should be asked again",
+		Responses: []docResponse{
+			{Status: "200", Description: "Consent per purpose"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ConsentHandler.RevokeConsent": {
+		Summary:     "Withdraws the user's consent to a purpose",
+		Description: "Withdraws the user's consent to a purpose. Data is no longer stored or used for it; revoking inferred_preferences also deletes the location history.",
+		Responses: []docResponse{
+			{Status: "200", Description: "Updated consent"},
+			{Status: "404", Description: "Unknown purpose"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ConversationHandler.BranchMessage": {
+		Summary:     "Edits an earlier user message and streams the answer like POST /chat/stream",
+		Description: "Edits an earlier user message and streams the answer like POST /chat/stream. The edited text follows the message's parent on a new branch, which becomes the conversation's active one once the answer is stored; the original message and its answers stay in the conversation as an alternative.",
+		Body:        "{\"message\": \"What about easier trails?\", \"context\": {...}}",
+		Responses: []docResponse{
+			{Status: "200", Description: "text/event-stream of AG-UI events"},
+			{Status: "400", Description: "Invalid message ID or body, or not a user message"},
+			{Status: "401", Description: "Neither authenticated nor in a session"},
+			{Status: "404", Description: "Message not found"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ConversationHandler.GetConversation": {
+		Summary:     "Returns a conversation with the messages of all its branches as a tree, for frontends to show the alternatives of edited messages",
+		Description: "Returns a conversation with the messages of all its branches as a tree, for frontends to show the alternatives of edited messages. Anonymous conversations are readable by the session they were started in (X-Session-Token header or session cookie); owned ones only by their owner.",
+		Responses: []docResponse{
+			{Status: "200", Description: "The conversation, its current_message_id and its messages, each with its children"},
+			{Status: "401", Description: "Neither authenticated nor in a session"},
+			{Status: "404", Description: "Conversation not found"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ConversationHandler.ListConversations": {
+		Summary: "Returns a paginated list of the user's conversations, most recent first",
+		Query: []queryParam{
+			{Name: "page"},
+			{Name: "page_size"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Conversations with pagination metadata"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ConversationHandler.ListMessages": {
+		Summary:     "Returns a paginated list of the messages of a conversation's active branch in chronological order",
+		Description: "Returns a paginated list of the messages of a conversation's active branch in chronological order. Anonymous conversations are readable by the session they were started in; owned ones only by their owner.",
+		Query: []queryParam{
+			{Name: "page"},
+			{Name: "page_size"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Messages with pagination metadata"},
+			{Status: "401", Description: "Neither authenticated nor in a session"},
+			{Status: "404", Description: "Conversation not found"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"DBStatsHandler.GetDBStats": {
+		Summary: "Reports row counts and sizes per table, the most bloated indexes and when tables were last vacuumed and analyzed, so runaway growth of e.g. conversations shows without psql access (admin only)",
+		Query: []queryParam{
+			{Name: "exact", Description: "true counts rows with COUNT(*) instead of estimating"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Database statistics"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"DaylightHandler.GetDaylight": {
+		Summary:     "Returns sunrise, sunset, civil twilight and golden hour of a date at a location, in local time",
+		Description: "Returns sunrise, sunset, civil twilight and golden hour of a date at a location, in local time. With a duration it also returns the latest start to finish an outing before dark.",
+		Query: []queryParam{
+			{Name: "lat", Description: "required", Required: true},
+			{Name: "lng", Description: "required", Required: true},
+			{Name: "date", Description: "YYYY-MM-DD, default today"},
+			{Name: "timezone", Description: "IANA name, estimated from the longitude when omitted"},
+			{Name: "duration_minutes"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Daylight of the date"},
+			{Status: "400", Description: "Invalid location, date, time zone or duration"},
+		},
+	},
+	"DifficultyHandler.ListCalibrations": {
+		Summary: "Returns the stored difficulty calibrations and the default used for communities without one (admin only)",
+		Responses: []docResponse{
+			{Status: "200", Description: "Calibrations and the default thresholds"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"DifficultyHandler.SetCalibration": {
+		Summary:     "Sets the route score thresholds of a community's difficulty levels",
+		Description: "Sets the route score thresholds of a community's difficulty levels. Routes scoring below easy_max are easy, below moderate_max moderate and the rest hard; scores are effort kilometers (distance plus 1 km per 100 m of climbing). Changing this community's calibration re-buckets routes whose difficulty was derived from their score (admin only).",
+		Body:        "{\"community\": \"\", \"easy_max\": 10, \"moderate_max\": 20}",
+		Responses: []docResponse{
+			{Status: "200", Description: "The stored calibration and the number of re-bucketed routes"},
+			{Status: "400", Description: "Invalid thresholds"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"DocsHandler.GetDocs": {
+		Summary:     "Returns Swagger UI for browsing and trying the API",
+		Description: "Returns Swagger UI for browsing and trying the API. Its files are served by GetDocsAsset from the vendored copy, not loaded from a CDN. Builds without the vendored copy list the endpoints on a plain page instead.",
+		Responses: []docResponse{
+			{Status: "200", Description: "HTML page"},
+		},
+	},
+	"DocsHandler.GetDocsAsset": {
+		Summary: "Returns a file of Swagger UI (swagger-ui.css or swagger-ui-bundle.js)",
+		Responses: []docResponse{
+			{Status: "200", Description: "File contents"},
+			{Status: "404", Description: "Unknown or missing file"},
+		},
+	},
+	"DocsHandler.GetSpec": {
+		Summary: "Returns the OpenAPI 3 document of the API",
+		Responses: []docResponse{
+			{Status: "200", Description: "OpenAPI document"},
+		},
+	},
+	"EmbedHandler.CreateKey": {
+		Summary: "Creates an API key for a site embedding the chat widget (admin only)",
+		Body:    "{\"name\": \"...\", \"allowed_origins\": [\"https://example.com\"], \"rate\": 1, \"burst\": 20}",
+		Responses: []docResponse{
+			{Status: "201", Description: "The key record and the API key, which is only shown once"},
+			{Status: "400", Description: "Invalid input data"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"EmbedHandler.IssueToken": {
+		Summary:     "Exchanges an embed API key for a short-lived widget token bound to one origin",
+		Description: "Exchanges an embed API key for a short-lived widget token bound to one origin. The key is sent in the X-API-Key header, ideally by the embedding site's backend so it never reaches browsers.",
+		Body:        "{\"origin\": \"https://example.com\"} (defaults to the Origin header)",
+		Responses: []docResponse{
+			{Status: "200", Description: "Token, its type and lifetime in seconds"},
+			{Status: "400", Description: "Invalid origin"},
+			{Status: "401", Description: "Missing, unknown or revoked API key"},
+			{Status: "403", Description: "Origin not allowed for the key"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"EmbedHandler.ListKeys": {
+		Summary: "Returns all embed keys without their secrets (admin only)",
+		Responses: []docResponse{
+			{Status: "200", Description: "Embed keys, newest first"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"EmbedHandler.RevokeKey": {
+		Summary: "Revokes an embed key; widget tokens issued for it stop working at once (admin only)",
+		Responses: []docResponse{
+			{Status: "200", Description: "Key revoked"},
+			{Status: "400", Description: "Invalid key ID"},
+			{Status: "404", Description: "Key not found or already revoked"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"FavoriteHandler.AddFavorite": {
+		Summary: "Saves an activity; saving it again is not an error",
+		Responses: []docResponse{
+			{Status: "201", Description: "The favorite with its activity"},
+			{Status: "400", Description: "Invalid activity ID"},
+			{Status: "404", Description: "Activity not found"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"FavoriteHandler.ListFavorites": {
+		Summary: "Returns the user's saved activities, most recently saved first",
+		Query: []queryParam{
+			{Name: "page"},
+			{Name: "page_size"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Favorites with their activity and pagination metadata"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"FavoriteHandler.RemoveFavorite": {
+		Summary: "Unsaves an activity; removing one that is not saved is not an error",
+		Responses: []docResponse{
+			{Status: "200", Description: "Favorite removed"},
+			{Status: "400", Description: "Invalid activity ID"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"FederationHandler.ExportBundle": {
+		Summary: "Returns a signed bundle of approved activities with their media manifests (admin only)",
+		Query: []queryParam{
+			{Name: "category"},
+			{Name: "ids", Description: "comma separated activity IDs"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Signed bundle"},
+			{Status: "400", Description: "Invalid ids"},
+			{Status: "503", Description: "Export not configured"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"FederationHandler.GetCommunity": {
+		Summary: "Returns the community name and public key peers need to trust this instance's bundles",
+		Responses: []docResponse{
+			{Status: "200", Description: "Community name and base64 Ed25519 public key"},
+			{Status: "503", Description: "Export not configured"},
+		},
+	},
+	"FederationHandler.ImportBundle": {
+		Summary: "Imports a signed bundle from a trusted community (admin only)",
+		Body:    "a bundle as returned by the export endpoint",
+		Responses: []docResponse{
+			{Status: "200", Description: "Import counts and the mapping of source to local activity IDs"},
+			{Status: "400", Description: "Invalid bundle"},
+			{Status: "403", Description: "Untrusted source or invalid signature"},
+			{Status: "503", Description: "Import not configured"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"GeocodeHandler.Geocode": {
+		Summary: "Returns the places matching a name, best matches first, so clients can turn \"near Boulder\" into coordinates for the activity endpoints",
+		Query: []queryParam{
+			{Name: "q", Description: "required", Required: true},
+			{Name: "limit", Description: "default and max 5"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Matching places with their coordinates; empty when nothing matches"},
+			{Status: "400", Description: "Missing or overly long q"},
+			{Status: "429", Description: "Too many lookups queued for the geocoder, retry in a few seconds"},
+			{Status: "502", Description: "Geocoder request failed"},
+		},
+	},
+	"GuardrailHandler.DryRun": {
+		Summary:     "Shows how the guardrails treat a message and a sample answer without calling the model: the compiled prompt, whether each is on a blocked topic and what the user would see",
+		Description: "Shows how the guardrails treat a message and a sample answer without calling the model: the compiled prompt, whether each is on a blocked topic and what the user would see. A policy in the body is tried instead of the stored one (admin only).",
+		Body:        "{\"message\": \"Can I take ibuprofen before a hike?\", \"response\": \"...\", \"policy\": {...}}",
+		Responses: []docResponse{
+			{Status: "200", Description: "Verdicts, compiled prompt and the answer as the user would see it"},
+			{Status: "400", Description: "Invalid body or policy"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"GuardrailHandler.GetGuardrails": {
+		Summary: "Returns the guardrail policy and the instruction it adds to the system prompt (admin only)",
+		Responses: []docResponse{
+			{Status: "200", Description: "The policy and its compiled prompt"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"GuardrailHandler.SetGuardrails": {
+		Summary:     "Replaces the guardrail policy",
+		Description: "Replaces the guardrail policy. Blocked topics medical, legal, financial and political are recognized by built-in word lists; other topics by their name. Empty topic lists lift the guardrails (admin only).",
+		Body:        "{\"allowed_topics\": [\"hiking\", \"cycling\"], \"blocked_topics\": [\"medical\"], \"refusal_style\": \"redirect\", \"refusal_message\": \"I can only help with outdoor plans.\"}",
+		Responses: []docResponse{
+			{Status: "200", Description: "The stored policy"},
+			{Status: "400", Description: "Invalid policy"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"HealthHandler.GetHealth": {
+		Summary: "Returns the health status of the application",
+	},
+	"ImageHandler.UploadImage": {
+		Summary:     "Accepts an image for an activity, by default JPEG, PNG, GIF or WebP",
+		Description: "Accepts an image for an activity, by default JPEG, PNG, GIF or WebP. The type is sniffed from the content and must match the file name extension. The image is uploaded to the image host in the background and stays processing until its URL is available; the uploader is notified when processing completes.",
+		Form:        "file, caption",
+		Responses: []docResponse{
+			{Status: "202", Description: "Image accepted and processing"},
+			{Status: "400", Description: "Invalid activity ID, missing file or caption too long"},
+			{Status: "404", Description: "Activity not found"},
+			{Status: "413", Description: "File or image dimensions too large"},
+			{Status: "415", Description: "File is not an allowed image type or its extension does not match"},
+			{Status: "422", Description: "File failed the malware scan"},
+			{Status: "500", Description: "Internal server error"},
+			{Status: "503", Description: "Malware scanner unavailable"},
+		},
+	},
+	"ItineraryHandler.CreateItinerary": {
+		Summary:     "Requests a printable PDF itinerary of activities, in visiting order",
+		Description: "Requests a printable PDF itinerary of activities, in visiting order. The PDF is rendered in the background; poll the itinerary until it is ready and has a download link.",
+		Body:        "{\"activity_ids\": [1, 2], \"title\": \"...\"}",
+		Responses: []docResponse{
+			{Status: "202", Description: "Itinerary accepted and pending"},
+			{Status: "400", Description: "Invalid input data or activities that do not exist"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ItineraryHandler.DownloadItinerary": {
+		Summary: "Returns the rendered PDF of an itinerary",
+		Responses: []docResponse{
+			{Status: "200", Description: "PDF document"},
+			{Status: "404", Description: "Itinerary not found or not rendered yet"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ItineraryHandler.GetItinerary": {
+		Summary: "Returns the status of an itinerary and, once rendered, its download link",
+		Responses: []docResponse{
+			{Status: "200", Description: "Itinerary"},
+			{Status: "404", Description: "Itinerary not found"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"KeyHandler.GetJWKS": {
+		Summary: "Returns the public keys currently accepted for token verification",
+		Responses: []docResponse{
+			{Status: "200", Description: "JSON Web Key Set"},
+		},
+	},
+	"KeyHandler.ListKeys": {
+		Summary: "Returns all signing keys with their status (admin only)",
+		Responses: []docResponse{
+			{Status: "200", Description: "Signing keys, newest first"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"KeyHandler.RevokeKey": {
+		Summary: "Stops accepting a signing key immediately, without a grace window (admin only)",
+		Responses: []docResponse{
+			{Status: "200", Description: "Key revoked"},
+			{Status: "404", Description: "Key not found or already revoked"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"KeyHandler.RotateKey": {
+		Summary: "Creates a new signing key for a scope and retires the previous ones (admin only)",
+		Body:    "{\"scope\": \"access\" | \"refresh\" | \"embed\"}",
+		Responses: []docResponse{
+			{Status: "201", Description: "The new signing key"},
+			{Status: "400", Description: "Invalid scope"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"LocationHistoryHandler.GetLocationHistory": {
+		Summary: "Returns the areas the user searched from, most frequent first",
+		Responses: []docResponse{
+			{Status: "200", Description: "Coarse areas with search counts"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"LocationHistoryHandler.PurgeLocationHistory": {
+		Summary:     "Deletes the user's location history",
+		Description: "Deletes the user's location history. Recording continues while consent is given; disable location_history in preferences to stop it.",
+		Responses: []docResponse{
+			{Status: "200", Description: "History deleted"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"LogSamplingHandler.GetLogSampling": {
+		Summary: "Returns the sampling request logs currently follow (admin only)",
+		Responses: []docResponse{
+			{Status: "200", Description: "The log sampling policy"},
+		},
+	},
+	"LogSamplingHandler.ResetLogSampling": {
+		Summary: "Returns to the sampling configured with LOG_SAMPLE_* (admin only)",
+		Responses: []docResponse{
+			{Status: "200", Description: "The configured policy"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"LogSamplingHandler.SetLogSampling": {
+		Summary:     "Replaces the sampling of request logs",
+		Description: "Replaces the sampling of request logs. Requests are logged with the rate of the longest matching rule, or the default rate; rule paths ending in * match by prefix. Failed requests and requests slower than slow_threshold_ms are always logged (admin only).",
+		Body:        "{\"default_rate\": 1, \"rules\": {\"/api/v1/chat/stream\": 0.01}, \"slow_threshold_ms\": 2000}",
+		Responses: []docResponse{
+			{Status: "200", Description: "The stored policy"},
+			{Status: "400", Description: "Invalid policy"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ModerationHandler.ApproveActivity": {
+		Summary: "Publishes a pending activity and notifies its submitter (moderators only)",
+		Responses: []docResponse{
+			{Status: "200", Description: "The approved activity"},
+			{Status: "400", Description: "Invalid activity ID"},
+			{Status: "404", Description: "Activity not found"},
+			{Status: "409", Description: "Activity already moderated"},
+		},
+	},
+	"ModerationHandler.ApproveImage": {
+		Summary: "Publishes a pending image (moderators only)",
+		Responses: []docResponse{
+			{Status: "200", Description: "The approved image"},
+			{Status: "400", Description: "Invalid image ID"},
+			{Status: "404", Description: "Image not found"},
+			{Status: "409", Description: "Image already moderated or its upload has not completed"},
+		},
+	},
+	"ModerationHandler.HideReview": {
+		Summary: "Removes a review from listings and ratings (moderators only)",
+		Responses: []docResponse{
+			{Status: "200", Description: "The hidden review"},
+			{Status: "400", Description: "Invalid review ID"},
+			{Status: "404", Description: "Review not found"},
+		},
+	},
+	"ModerationHandler.ListAutoApprovedActivities": {
+		Summary: "Returns published activities of trusted submitters, newest first, for auditing (moderators only)",
+		Query: []queryParam{
+			{Name: "page"},
+			{Name: "page_size"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Auto-approved activities with pagination metadata"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ModerationHandler.ListAutoApprovedImages": {
+		Summary: "Returns images published without a moderator, newest first, for auditing (moderators only)",
+		Query: []queryParam{
+			{Name: "page"},
+			{Name: "page_size"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Auto-approved images with pagination metadata"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ModerationHandler.ListFlaggedReviews": {
+		Summary: "Returns reviews users reported, oldest first (moderators only)",
+		Query: []queryParam{
+			{Name: "page"},
+			{Name: "page_size"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Flagged reviews with pagination metadata"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ModerationHandler.ListPendingActivities": {
+		Summary: "Returns activities awaiting review, oldest first (moderators only)",
+		Query: []queryParam{
+			{Name: "page"},
+			{Name: "page_size"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Pending activities with pagination metadata"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ModerationHandler.ListPendingImages": {
+		Summary: "Returns images awaiting review, those automatic screening found borderline first, then oldest first (moderators only)",
+		Query: []queryParam{
+			{Name: "screening_status", Description: "clear or review"},
+			{Name: "page"},
+			{Name: "page_size"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Pending images with pagination metadata"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ModerationHandler.RejectActivity": {
+		Summary: "Rejects a pending activity with a reason shown to its submitter (moderators only)",
+		Body:    "{\"reason\": \"...\"}",
+		Responses: []docResponse{
+			{Status: "200", Description: "The rejected activity"},
+			{Status: "400", Description: "Invalid activity ID or missing reason"},
+			{Status: "404", Description: "Activity not found"},
+			{Status: "409", Description: "Activity already moderated"},
+		},
+	},
+	"ModerationHandler.RejectImage": {
+		Summary: "Rejects a pending image with a reason (moderators only)",
+		Body:    "{\"reason\": \"...\"}",
+		Responses: []docResponse{
+			{Status: "200", Description: "The rejected image"},
+			{Status: "400", Description: "Invalid image ID or missing reason"},
+			{Status: "404", Description: "Image not found"},
+			{Status: "409", Description: "Image already moderated or its upload has not completed"},
+		},
+	},
+	"ModerationHandler.RestoreReview": {
+		Summary: "Dismisses the reports of a review and shows it again if it was hidden (moderators only)",
+		Responses: []docResponse{
+			{Status: "200", Description: "The restored review"},
+			{Status: "400", Description: "Invalid review ID"},
+			{Status: "404", Description: "Review not found"},
+		},
+	},
+	"ModerationHandler.RevokeActivity": {
+		Summary: "Takes down an auto-approved activity with a reason shown to its submitter; it counts as a violation of the submitter (moderators only)",
+		Body:    "{\"reason\": \"...\"}",
+		Responses: []docResponse{
+			{Status: "200", Description: "The revoked activity"},
+			{Status: "400", Description: "Invalid activity ID or missing reason"},
+			{Status: "404", Description: "Activity not found"},
+			{Status: "409", Description: "Activity is not auto-approved"},
+		},
+	},
+	"ModerationHandler.RevokeImage": {
+		Summary: "Takes down an auto-approved image; it counts as a violation of the uploader (moderators only)",
+		Body:    "{\"reason\": \"...\"}",
+		Responses: []docResponse{
+			{Status: "200", Description: "The revoked image"},
+			{Status: "400", Description: "Invalid image ID or missing reason"},
+			{Status: "404", Description: "Image not found"},
+			{Status: "409", Description: "Image is not auto-approved"},
+		},
+	},
+	"NotificationHandler.ListNotifications": {
+		Summary: "Returns a paginated list of the user's notifications, newest first",
+		Query: []queryParam{
+			{Name: "unread"},
+			{Name: "page"},
+			{Name: "page_size"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Notifications with pagination metadata"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"NotificationHandler.MarkNotificationRead": {
+		Summary: "Marks a notification as read",
+		Responses: []docResponse{
+			{Status: "200", Description: "Notification marked read"},
+			{Status: "400", Description: "Invalid notification ID"},
+			{Status: "404", Description: "Notification not found"},
+		},
+	},
+	"OAuthHandler.Callback": {
+		Summary: "Completes a login when the provider redirects back, and redirects to the frontend's /auth/callback page with the token pair in the fragment (access_token, refresh_token, token_type, expires_in), or with an error (provider_denied, invalid_state, provider_failed, email_unverified, server_error)",
+		Query: []queryParam{
+			{Name: "code"},
+			{Name: "state", Description: "set by the provider"},
+		},
+		Responses: []docResponse{
+			{Status: "302", Description: "Redirect to the frontend"},
+			{Status: "404", Description: "Unknown or unconfigured provider"},
+		},
+	},
+	"OAuthHandler.ListProviders": {
+		Summary: "Returns the names of the providers users can sign in with",
+		Responses: []docResponse{
+			{Status: "200", Description: "Provider names, e.g. [\"github\", \"google\"]"},
+		},
+	},
+	"OAuthHandler.Login": {
+		Summary: "Redirects to a provider's consent page",
+		Responses: []docResponse{
+			{Status: "302", Description: "Redirect to the provider"},
+			{Status: "404", Description: "Unknown or unconfigured provider"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"PreferencesHandler.GetPreferences": {
+		Summary: "Returns the user's preferences, including digest settings",
+		Responses: []docResponse{
+			{Status: "200", Description: "Preferences"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"PreferencesHandler.UpdatePreferences": {
+		Summary:     "Replaces the user's editable preferences",
+		Description: "Replaces the user's editable preferences. Setting location_history to false withdraws consent and deletes the recorded history.",
+		Responses: []docResponse{
+			{Status: "200", Description: "Updated preferences"},
+			{Status: "400", Description: "Invalid input data"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ReviewHandler.CreateReview": {
+		Summary:     "Adds a review to an activity and updates its rating",
+		Description: "Adds a review to an activity and updates its rating. Sentiment and highlights are extracted asynchronously and appear on the review once analyzed.",
+		Responses: []docResponse{
+			{Status: "201", Description: "Review stored, pending analysis"},
+			{Status: "400", Description: "Invalid activity ID or input data"},
+			{Status: "404", Description: "Activity not found"},
+			{Status: "409", Description: "The user already reviewed the activity"},
+		},
+	},
+	"ReviewHandler.DeleteReview": {
+		Summary: "Deletes the user's review and updates the activity's rating",
+		Responses: []docResponse{
+			{Status: "200", Description: "Review deleted"},
+			{Status: "400", Description: "Invalid ID"},
+			{Status: "403", Description: "Review written by another user"},
+			{Status: "404", Description: "Review not found"},
+		},
+	},
+	"ReviewHandler.FlagReview": {
+		Summary: "Reports a review to moderators",
+		Body:    "{\"reason\": \"...\"} (optional)",
+		Responses: []docResponse{
+			{Status: "200", Description: "Review reported"},
+			{Status: "400", Description: "Invalid ID or reason too long"},
+			{Status: "404", Description: "Review not found"},
+		},
+	},
+	"ReviewHandler.ListReviews": {
+		Summary: "Returns a paginated list of an activity's reviews",
+		Query: []queryParam{
+			{Name: "page"},
+			{Name: "page_size"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Reviews with pagination metadata"},
+			{Status: "400", Description: "Invalid activity ID"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ReviewHandler.UpdateReview": {
+		Summary: "Replaces the rating and text of the user's review; it is analyzed again",
+		Responses: []docResponse{
+			{Status: "200", Description: "The updated review"},
+			{Status: "400", Description: "Invalid ID or input data"},
+			{Status: "403", Description: "Review written by another user"},
+			{Status: "404", Description: "Review not found"},
+		},
+	},
+	"RoleHandler.SetRole": {
+		Summary: "Sets the role of a user: moderators review content, admins also manage users and operate the community (admin only)",
+		Body:    "{\"role\": \"moderator\"}",
+		Responses: []docResponse{
+			{Status: "200", Description: "The updated user"},
+			{Status: "400", Description: "Invalid user ID or role"},
+			{Status: "404", Description: "User not found"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"RouteHandler.ExportRoute": {
+		Summary: "Returns the track of a route of an approved activity as a file for mapping apps and GPS devices",
+		Query: []queryParam{
+			{Name: "format", Description: "gpx, the default"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "GPX document"},
+			{Status: "400", Description: "Invalid route id or unsupported format"},
+			{Status: "404", Description: "Route not found or without a track"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"RouteHandler.GetElevationProfile": {
+		Summary: "Returns the elevation profile of a route of an approved activity for drawing a chart: elevations at distances along the track, at most 500 points",
+		Responses: []docResponse{
+			{Status: "200", Description: "The route's distance, elevation gain and loss, and profile points"},
+			{Status: "400", Description: "Invalid route id"},
+			{Status: "404", Description: "Route not found, without a track or without elevation data"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"RouteHandler.RecomputeStats": {
+		Summary: "Recomputes the distance, elevation gain and loss, estimated duration and difficulty of stored routes from their tracks (admin only)",
+		Query: []queryParam{
+			{Name: "activity_id", Description: "only this activity's routes"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "The numbers of updated, unchanged and skipped routes"},
+			{Status: "400", Description: "Invalid activity id"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"RouteHandler.UploadRoute": {
+		Summary:     "Accepts a route file for an activity, by default GPX, TCX, KML or FIT",
+		Description: "Accepts a route file for an activity, by default GPX, TCX, KML or FIT. The format is sniffed from the content and must match the file name extension.",
+		Responses: []docResponse{
+			{Status: "201", Description: "Route created with computed distance, elevation change and duration"},
+			{Status: "400", Description: "Missing file, unparseable route data or too many track points"},
+			{Status: "404", Description: "Activity not found"},
+			{Status: "413", Description: "File too large"},
+			{Status: "415", Description: "File is not an allowed route type or its extension does not match"},
+			{Status: "422", Description: "File failed the malware scan"},
+			{Status: "503", Description: "Malware scanner unavailable"},
+		},
+	},
+	"SavedSearchHandler.CreateSavedSearch": {
+		Summary: "Saves a search; closures of matching activities are notified to the user",
+		Body:    "{\"name\": \"...\", \"category\": \"...\", \"difficulty\": \"...\", \"location\": {\"lat\": 0, \"lng\": 0}, \"radius_km\": 25}",
+		Responses: []docResponse{
+			{Status: "201", Description: "The saved search"},
+			{Status: "400", Description: "Invalid input data"},
+			{Status: "409", Description: "Too many saved searches"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"SavedSearchHandler.DeleteSavedSearch": {
+		Summary: "Removes one of the user's saved searches",
+		Responses: []docResponse{
+			{Status: "200", Description: "Saved search deleted"},
+			{Status: "400", Description: "Invalid saved search ID"},
+			{Status: "404", Description: "Saved search not found"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"SavedSearchHandler.ListSavedSearches": {
+		Summary: "Returns the user's saved searches",
+		Responses: []docResponse{
+			{Status: "200", Description: "Saved searches, newest first"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"SessionHandler.GetSession": {
+		Summary:     "Returns the state an anonymous chat user needs to restore the chat after a page reload: the current conversation, recent conversations and the saved chat context",
+		Description: "Returns the state an anonymous chat user needs to restore the chat after a page reload: the current conversation, recent conversations and the saved chat context. The session token is sent in the X-Session-Token header or the session cookie.",
+		Responses: []docResponse{
+			{Status: "200", Description: "Session and its recent conversations"},
+			{Status: "404", Description: "No live session"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ShortLinkHandler.GetQRCode": {
+		Summary:     "Returns a PNG QR code of an activity's short link, for printing on trailhead signs",
+		Description: "Returns a PNG QR code of an activity's short link, for printing on trailhead signs\n\nCodes are only rendered with a configured public URL: the request's host header is not trusted for codes that are cached and printed.",
+		Query: []queryParam{
+			{Name: "size", Description: "width in pixels, default 512, 64 to 2048"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "PNG image"},
+			{Status: "400", Description: "Invalid activity ID"},
+			{Status: "404", Description: "Activity not found"},
+			{Status: "500", Description: "Internal server error"},
+			{Status: "503", Description: "No public URL configured"},
+		},
+	},
+	"ShortLinkHandler.GetShortLink": {
+		Summary: "Returns the short link of an activity with its scan count",
+		Responses: []docResponse{
+			{Status: "200", Description: "Short link"},
+			{Status: "400", Description: "Invalid activity ID"},
+			{Status: "404", Description: "Activity not found"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"ShortLinkHandler.ResolveShortLink": {
+		Summary: "Counts a scan of a short link, once per client IP and day, and redirects to the activity's page on the frontend",
+		Responses: []docResponse{
+			{Status: "302", Description: "Redirect to the activity"},
+			{Status: "404", Description: "Unknown short link or activity no longer published"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"StatsHandler.GetAreaStats": {
+		Summary:     "Summarizes the approved activities and routes within an area for community dashboards: activities per category, route length per route type, routes per difficulty and per elevation gain range",
+		Description: "Summarizes the approved activities and routes within an area for community dashboards: activities per category, route length per route type, routes per difficulty and per elevation gain range. Routes are placed at their activity's location, and summaries are cached for a while.",
+		Query: []queryParam{
+			{Name: "bbox", Description: "min_lng,min_lat,max_lng,max_lat, at most 450 km high and wide"},
+			{Name: "lat"},
+			{Name: "lng"},
+			{Name: "radius_km", Description: "default 25, max 200"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Area statistics"},
+			{Status: "400", Description: "Missing or invalid area, or area too large"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"StatsHandler.GetStats": {
+		Summary:     "Returns counts of the community's approved activities, routes, photos and contributors and the total route length, for landing pages",
+		Description: "Returns counts of the community's approved activities, routes, photos and contributors and the total route length, for landing pages. Counts are refreshed periodically, so they may lag recent changes.",
+		Responses: []docResponse{
+			{Status: "200", Description: "Community statistics"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"TransitHandler.GetTransit": {
+		Summary: "Returns public transit itineraries to an activity",
+		Query: []queryParam{
+			{Name: "from", Description: "\"lat,lng\", required"},
+			{Name: "depart_at", Description: "RFC 3339, default now"},
+			{Name: "limit", Description: "default 3, max 5"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Itineraries with departure and arrival times; empty when there is no connection"},
+			{Status: "400", Description: "Invalid activity ID, origin or departure time"},
+			{Status: "404", Description: "Activity not found"},
+			{Status: "502", Description: "Transit planner failed"},
+			{Status: "503", Description: "Transit directions are not configured"},
+		},
+	},
+	"TrustHandler.SetTrustLevel": {
+		Summary:     "Sets the trust level of a user",
+		Description: "Sets the trust level of a user. Trusted users and moderators publish without pre-moderation; moderator levels are always pinned (admin only).",
+		Body:        "{\"level\": \"trusted\", \"pinned\": false}",
+		Responses: []docResponse{
+			{Status: "200", Description: "The updated user"},
+			{Status: "400", Description: "Invalid user ID or trust level"},
+			{Status: "404", Description: "User not found"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"UsageHandler.GetUsage": {
+		Summary: "Reports the token usage and estimated cost of chat answers (admin only)",
+		Query: []queryParam{
+			{Name: "from"},
+			{Name: "to", Description: "YYYY-MM-DD, UTC, both inclusive; default the last 30 days"},
+			{Name: "group_by", Description: "day, user, conversation or model"},
+		},
+		Responses: []docResponse{
+			{Status: "200", Description: "Totals and, with group_by, the most expensive groups"},
+			{Status: "400", Description: "Invalid dates or grouping"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+	"UserImportHandler.ImportUsers": {
+		Summary: "Imports users and their preferences, matched by email; unknown emails get an account and an invitation to set a password (admin only)",
+		Query: []queryParam{
+			{Name: "dry_run", Description: "validate and match without importing"},
+		},
+		Body: "a JSON array of users, or CSV (Content-Type: text/csv) with a header row of the same field names and preferred_activities separated by \";\"",
+		Responses: []docResponse{
+			{Status: "200", Description: "Import counts and the rows that were skipped"},
+			{Status: "400", Description: "Unreadable body or too many users"},
+			{Status: "500", Description: "Internal server error"},
+		},
+	},
+}
@@ -0,0 +1,113 @@
+// Package apperr defines the kinds of errors services return, so they are
+// mapped to HTTP status codes and AG-UI error codes in one place instead of by
+// every handler.
+package apperr
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// Kind classifies an error by how the client should treat it
+type Kind int
+
+// Error kinds. Errors without a kind are Internal.
+const (
+	Internal Kind = iota
+	Invalid
+	NotFound
+	Conflict
+	Unauthorized
+	Forbidden
+	RateLimited
+	UpstreamTimeout
+)
+
+// kinds maps error kinds to HTTP status codes, AG-UI error codes and the message
+// clients get for errors without their own
+var kinds = map[Kind]struct {
+	status  int
+	code    string
+	message string
+}{
+	Internal:        {http.StatusInternalServerError, "INTERNAL", "internal server error"},
+	Invalid:         {http.StatusBadRequest, "INVALID", "invalid request"},
+	NotFound:        {http.StatusNotFound, "NOT_FOUND", "not found"},
+	Conflict:        {http.StatusConflict, "CONFLICT", "conflict"},
+	Unauthorized:    {http.StatusUnauthorized, "UNAUTHORIZED", "unauthorized"},
+	Forbidden:       {http.StatusForbidden, "FORBIDDEN", "forbidden"},
+	RateLimited:     {http.StatusTooManyRequests, "RATE_LIMITED", "too many requests"},
+	UpstreamTimeout: {http.StatusGatewayTimeout, "UPSTREAM_TIMEOUT", "upstream service timed out"},
+}
+
+// Status returns the HTTP status code of the kind
+func (k Kind) Status() int {
+	return kinds[k].status
+}
+
+// Code returns the AG-UI error code of the kind
+func (k Kind) Code() string {
+	return kinds[k].code
+}
+
+// Error is an error of a known kind. Message is safe to show to clients; the
+// wrapped error carries the details for logs.
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+// New creates an error of a kind, typically a service's sentinel error
+func New(kind Kind, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// Wrap gives err a kind and a message for clients
+func Wrap(kind Kind, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+// Error returns the message followed by the wrapped error
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return e.Message + ": " + e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// KindOf returns the kind of err. Records not found by GORM are NotFound and
+// deadlines and network timeouts are UpstreamTimeout; other errors are Internal.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return NotFound
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return UpstreamTimeout
+	}
+	return Internal
+}
+
+// Message returns the message of err for clients: its own for errors of a
+// known kind, otherwise one describing its kind without internal details
+func Message(err error) string {
+	var e *Error
+	if errors.As(err, &e) && e.Message != "" {
+		return e.Message
+	}
+	return kinds[KindOf(err)].message
+}
@@ -0,0 +1,311 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"community-chatbot/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Key scopes. Each scope has its own signing keys so that, for example, a leaked
+// access token key cannot mint refresh tokens.
+const (
+	ScopeAccess  = "access"
+	ScopeRefresh = "refresh"
+	// ScopeEmbed tokens let a chat widget on a third-party site make chat requests
+	ScopeEmbed = "embed"
+)
+
+// Scopes lists every valid key scope
+var Scopes = []string{ScopeAccess, ScopeRefresh, ScopeEmbed}
+
+// AlgorithmRS256 is the only signing algorithm used for keys
+const AlgorithmRS256 = "RS256"
+
+// rsaKeyBits is the size of generated RSA keys
+const rsaKeyBits = 2048
+
+var (
+	// ErrUnknownKey is returned for kids that do not exist, are revoked or left their grace window
+	ErrUnknownKey = errors.New("unknown or expired signing key")
+	// ErrInvalidScope is returned for scopes not listed in Scopes
+	ErrInvalidScope = errors.New("invalid key scope")
+)
+
+// loadedKey is a signing key with its parsed key material
+type loadedKey struct {
+	record  models.SigningKey
+	private *rsa.PrivateKey
+}
+
+// Keyring holds the JWT signing keys of every scope. Keys are stored in the database
+// so all replicas share them; each replica caches them in memory and reloads
+// periodically to pick up rotations made elsewhere.
+type Keyring struct {
+	db    *gorm.DB
+	grace time.Duration
+
+	keys  map[string]*loadedKey // by kid
+	mutex sync.RWMutex
+}
+
+// NewKeyring creates a keyring. Retired keys remain valid for verification for grace,
+// which should be at least the lifetime of the longest-lived token.
+func NewKeyring(db *gorm.DB, grace time.Duration) *Keyring {
+	return &Keyring{
+		db:    db,
+		grace: grace,
+		keys:  make(map[string]*loadedKey),
+	}
+}
+
+// Init loads keys and creates a first key for every scope that has no active key
+func (k *Keyring) Init(ctx context.Context) error {
+	if err := k.Reload(ctx); err != nil {
+		return err
+	}
+
+	for _, scope := range Scopes {
+		if _, _, err := k.SigningKey(scope); err == nil {
+			continue
+		}
+		if _, err := k.create(ctx, scope); err != nil {
+			return err
+		}
+		log.Printf("[AUTH] Created initial %s signing key", scope)
+	}
+	return k.Reload(ctx)
+}
+
+// Reload replaces the cached keys with the keys currently accepted in the database
+func (k *Keyring) Reload(ctx context.Context) error {
+	var records []models.SigningKey
+	if err := k.db.WithContext(ctx).
+		Where("revoked_at IS NULL AND (retired_at IS NULL OR retired_at > ?)", time.Now().Add(-k.grace)).
+		Order("created_at").
+		Find(&records).Error; err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+
+	keys := make(map[string]*loadedKey, len(records))
+	for _, record := range records {
+		private, err := parsePrivateKey(record.PrivateKey)
+		if err != nil {
+			log.Printf("[AUTH] Skipping signing key %s: %v", record.KID, err)
+			continue
+		}
+		keys[record.KID] = &loadedKey{record: record, private: private}
+	}
+
+	k.mutex.Lock()
+	k.keys = keys
+	k.mutex.Unlock()
+	return nil
+}
+
+// Start reloads keys every interval until ctx is cancelled
+func (k *Keyring) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.Reload(ctx); err != nil {
+				log.Printf("[AUTH] %v", err)
+			}
+		}
+	}
+}
+
+// SigningKey returns the kid and private key new tokens of scope are signed with:
+// the newest active key of the scope
+func (k *Keyring) SigningKey(scope string) (string, *rsa.PrivateKey, error) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	var newest *loadedKey
+	for _, key := range k.keys {
+		if key.record.Scope != scope || !key.record.Active() {
+			continue
+		}
+		if newest == nil || key.record.CreatedAt.After(newest.record.CreatedAt) {
+			newest = key
+		}
+	}
+	if newest == nil {
+		return "", nil, fmt.Errorf("no active %s signing key: %w", scope, ErrUnknownKey)
+	}
+	return newest.record.KID, newest.private, nil
+}
+
+// VerificationKey returns the public key for kid if tokens of scope signed with it are accepted now
+func (k *Keyring) VerificationKey(kid, scope string) (*rsa.PublicKey, error) {
+	k.mutex.RLock()
+	key, ok := k.keys[kid]
+	k.mutex.RUnlock()
+
+	if !ok || key.record.Scope != scope || !key.record.AcceptedAt(time.Now(), k.grace) {
+		return nil, ErrUnknownKey
+	}
+	return &key.private.PublicKey, nil
+}
+
+// Rotate creates a new signing key for scope and retires the scope's previous
+// keys. Retired keys keep verifying tokens for the grace window.
+func (k *Keyring) Rotate(ctx context.Context, scope string) (*models.SigningKey, error) {
+	if !validScope(scope) {
+		return nil, ErrInvalidScope
+	}
+
+	record, err := k.create(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := k.db.WithContext(ctx).Model(&models.SigningKey{}).
+		Where("scope = ? AND kid <> ? AND retired_at IS NULL AND revoked_at IS NULL", scope, record.KID).
+		Update("retired_at", time.Now()).Error; err != nil {
+		return nil, fmt.Errorf("failed to retire previous %s keys: %w", scope, err)
+	}
+
+	if err := k.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Revoke stops accepting kid immediately, without a grace window. Use it for
+// compromised keys; tokens signed with the key are rejected from now on.
+func (k *Keyring) Revoke(ctx context.Context, kid string) error {
+	result := k.db.WithContext(ctx).Model(&models.SigningKey{}).
+		Where("kid = ? AND revoked_at IS NULL", kid).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke signing key %s: %w", kid, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to revoke signing key %s: %w", kid, gorm.ErrRecordNotFound)
+	}
+	return k.Reload(ctx)
+}
+
+// List returns every signing key, newest first. Private key material is never serialized.
+func (k *Keyring) List(ctx context.Context) ([]models.SigningKey, error) {
+	var records []models.SigningKey
+	if err := k.db.WithContext(ctx).Order("created_at DESC").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	return records, nil
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	KTY string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	KID string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of all keys currently accepted for verification
+// so other services can verify tokens without sharing secrets
+func (k *Keyring) JWKS() JWKS {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	now := time.Now()
+	for _, key := range k.keys {
+		if !key.record.AcceptedAt(now, k.grace) {
+			continue
+		}
+		public := key.private.PublicKey
+		set.Keys = append(set.Keys, JWK{
+			KTY: "RSA",
+			Use: "sig",
+			Alg: key.record.Algorithm,
+			KID: key.record.KID,
+			N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		})
+	}
+	return set
+}
+
+// create generates and stores a new key for scope
+func (k *Keyring) create(ctx context.Context, scope string) (*models.SigningKey, error) {
+	private, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signing key: %w", err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(&private.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+
+	record := &models.SigningKey{
+		KID:        uuid.New().String(),
+		Scope:      scope,
+		Algorithm:  AlgorithmRS256,
+		PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}),
+		PublicKey:  pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}),
+	}
+	if err := k.db.WithContext(ctx).Create(record).Error; err != nil {
+		return nil, fmt.Errorf("failed to store signing key: %w", err)
+	}
+	return record, nil
+}
+
+// parsePrivateKey decodes a PKCS#8 PEM RSA private key
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA key")
+	}
+	return private, nil
+}
+
+// validScope reports whether scope is one of Scopes
+func validScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
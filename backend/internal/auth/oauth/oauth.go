@@ -0,0 +1,164 @@
+// Package oauth signs users in with external identity providers such as Google
+// and GitHub, using the OAuth 2.0 authorization code flow: users are sent to
+// the provider's consent page, which redirects back with a code that is
+// exchanged for an access token and then for the user's identity.
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrExchange is wrapped by failures to exchange a code or fetch the identity
+var ErrExchange = errors.New("oauth exchange failed")
+
+// Identity is a user as known to a provider
+type Identity struct {
+	Provider string
+	// Subject is the provider's stable ID of the user; emails can change
+	Subject string
+	Email   string
+	// EmailVerified is whether the provider verified the user owns Email
+	EmailVerified bool
+	Name          string
+}
+
+// Config holds the credentials of an application registered with a provider
+type Config struct {
+	ClientID     string
+	ClientSecret string
+}
+
+// identifier fetches the identity of the user an access token was issued to
+type identifier func(ctx context.Context, p *Provider, accessToken string) (*Identity, error)
+
+// Provider is an identity provider users can sign in with
+type Provider struct {
+	name       string
+	config     Config
+	authURL    string
+	tokenURL   string
+	scopes     []string
+	identify   identifier
+	httpClient *http.Client
+}
+
+// newProvider creates a provider with the default HTTP client
+func newProvider(name string, config Config, authURL, tokenURL string, scopes []string, identify identifier) *Provider {
+	return &Provider{
+		name:       name,
+		config:     config,
+		authURL:    authURL,
+		tokenURL:   tokenURL,
+		scopes:     scopes,
+		identify:   identify,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name returns the provider's name as used in routes, e.g. google
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// AuthCodeURL returns the provider's consent page, which redirects to
+// redirectURL with a code and state once the user agrees
+func (p *Provider) AuthCodeURL(redirectURL, state string) string {
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.config.ClientID)
+	params.Set("redirect_uri", redirectURL)
+	params.Set("scope", strings.Join(p.scopes, " "))
+	params.Set("state", state)
+	return p.authURL + "?" + params.Encode()
+}
+
+// Exchange trades the code from the redirect for the user's identity.
+// redirectURL must be the one the code was requested with.
+func (p *Provider) Exchange(ctx context.Context, redirectURL, code string) (*Identity, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("client_secret", p.config.ClientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// GitHub answers in form encoding unless JSON is asked for
+	req.Header.Set("Accept", "application/json")
+
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := p.do(req, &token); err != nil {
+		return nil, err
+	}
+	// GitHub reports errors such as an expired code with status 200
+	if token.Error != "" || token.AccessToken == "" {
+		return nil, fmt.Errorf("%w: %s token endpoint: %s", ErrExchange, p.name,
+			strings.TrimSpace(token.Error+" "+token.ErrorDescription))
+	}
+
+	identity, err := p.identify(ctx, p, token.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	identity.Provider = p.name
+	return identity, nil
+}
+
+// get fetches a JSON resource of the provider's API with an access token
+func (p *Provider) get(ctx context.Context, endpoint, accessToken string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	return p.do(req, out)
+}
+
+// do sends a request and decodes the JSON response into out
+func (p *Provider) do(req *http.Request, out interface{}) error {
+	// GitHub's API rejects requests without a User-Agent
+	req.Header.Set("User-Agent", "community-chatbot")
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s request failed: %v", ErrExchange, p.name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%w: %s returned %d: %s", ErrExchange, p.name, resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%w: failed to decode %s response: %v", ErrExchange, p.name, err)
+	}
+	return nil
+}
+
+// NewState returns a random value tying a callback to the login that started
+// it, so an attacker cannot complete a login in someone else's browser
+func NewState() (string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate oauth state: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
@@ -0,0 +1,70 @@
+package oauth
+
+import (
+	"context"
+	"strconv"
+)
+
+// Google signs users in with their Google account through OpenID Connect
+func Google(config Config) *Provider {
+	return newProvider("google", config,
+		"https://accounts.google.com/o/oauth2/v2/auth",
+		"https://oauth2.googleapis.com/token",
+		[]string{"openid", "email", "profile"},
+		identifyGoogle)
+}
+
+// identifyGoogle reads the OpenID Connect user info
+func identifyGoogle(ctx context.Context, p *Provider, accessToken string) (*Identity, error) {
+	var info struct {
+		Subject       string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := p.get(ctx, "https://openidconnect.googleapis.com/v1/userinfo", accessToken, &info); err != nil {
+		return nil, err
+	}
+	return &Identity{Subject: info.Subject, Email: info.Email, EmailVerified: info.EmailVerified, Name: info.Name}, nil
+}
+
+// GitHub signs users in with their GitHub account
+func GitHub(config Config) *Provider {
+	return newProvider("github", config,
+		"https://github.com/login/oauth/authorize",
+		"https://github.com/login/oauth/access_token",
+		[]string{"read:user", "user:email"},
+		identifyGitHub)
+}
+
+// identifyGitHub reads the user's profile and their primary verified email,
+// which the profile omits when the user keeps it private
+func identifyGitHub(ctx context.Context, p *Provider, accessToken string) (*Identity, error) {
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.get(ctx, "https://api.github.com/user", accessToken, &user); err != nil {
+		return nil, err
+	}
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.get(ctx, "https://api.github.com/user/emails", accessToken, &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{Subject: strconv.FormatInt(user.ID, 10), Name: user.Name}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email, identity.EmailVerified = email.Email, email.Verified
+		}
+	}
+	return identity, nil
+}
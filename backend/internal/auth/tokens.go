@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"community-chatbot/internal/residency"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed, expired, of the wrong
+// scope or signed with a key that is not accepted
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims are the JWT claims issued to users and chat widgets
+type Claims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope"`
+	// Origin is the site an embed token is bound to
+	Origin string `json:"origin,omitempty"`
+	// Region is the data residency region the subject is stored in; tokens
+	// are only accepted by requests bound to the same region
+	Region string `json:"region,omitempty"`
+}
+
+// UserID returns the user ID from the subject claim
+func (c *Claims) UserID() (uint, error) {
+	return c.subjectID()
+}
+
+// EmbedKeyID returns the ID of the embed key an embed token was issued for
+func (c *Claims) EmbedKeyID() (uint, error) {
+	return c.subjectID()
+}
+
+// subjectID parses the numeric subject claim
+func (c *Claims) subjectID() (uint, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 64)
+	if err != nil || id == 0 {
+		return 0, ErrInvalidToken
+	}
+	return uint(id), nil
+}
+
+// TokenPair is the response to a successful login, registration or refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // access token lifetime in seconds
+}
+
+// IssuedBefore reports whether a token was issued before revokedAt, to the
+// second; tokens without an issue time count as issued before. A nil
+// revokedAt revokes nothing.
+func IssuedBefore(claims *Claims, revokedAt *time.Time) bool {
+	return revokedAt != nil && (claims.IssuedAt == nil || claims.IssuedAt.Time.Before(*revokedAt))
+}
+
+// TokenIssuer signs and verifies user tokens with the keyring's scoped keys
+type TokenIssuer struct {
+	keyring    *Keyring
+	issuer     string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+// NewTokenIssuer creates a token issuer
+func NewTokenIssuer(keyring *Keyring, issuer string, accessTTL, refreshTTL time.Duration) *TokenIssuer {
+	return &TokenIssuer{
+		keyring:    keyring,
+		issuer:     issuer,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+	}
+}
+
+// Issue creates an access and refresh token pair for a user of the region ctx is bound to
+func (t *TokenIssuer) Issue(ctx context.Context, userID uint) (*TokenPair, error) {
+	access, err := t.sign(ctx, userID, ScopeAccess, "", t.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := t.sign(ctx, userID, ScopeRefresh, "", t.refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(t.accessTTL.Seconds()),
+	}, nil
+}
+
+// IssueEmbed creates a token for an embed key that is only valid for requests from origin
+func (t *TokenIssuer) IssueEmbed(ctx context.Context, embedKeyID uint, origin string, ttl time.Duration) (string, error) {
+	return t.sign(ctx, embedKeyID, ScopeEmbed, origin, ttl)
+}
+
+// Verify parses a token and checks its signature, expiry, issuer and scope, and
+// that it was issued in the region ctx is bound to
+func (t *TokenIssuer) Verify(ctx context.Context, token, scope string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(parsed *jwt.Token) (interface{}, error) {
+		kid, _ := parsed.Header["kid"].(string)
+		return t.keyring.VerificationKey(kid, scope)
+	},
+		jwt.WithValidMethods([]string{AlgorithmRS256}),
+		jwt.WithIssuer(t.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	// The key lookup already checks scope; the claim guards against keys shared across scopes
+	if claims.Scope != scope {
+		return nil, ErrInvalidToken
+	}
+	// Subjects are IDs in their region's database, which name others elsewhere
+	if claims.Region != residency.Region(ctx) {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// sign creates a token of scope for a subject, signed with the scope's current key
+func (t *TokenIssuer) sign(ctx context.Context, subject uint, scope, origin string, ttl time.Duration) (string, error) {
+	kid, key, err := t.keyring.SigningKey(scope)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    t.issuer,
+			Subject:   strconv.FormatUint(uint64(subject), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Scope:  scope,
+		Origin: origin,
+		Region: residency.Region(ctx),
+	})
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign %s token: %w", scope, err)
+	}
+	return signed, nil
+}
+
+// ClaimedScope returns the scope a token claims without verifying it, or "" for
+// anything that is not a JWT. It only tells which verification a token needs;
+// its result must never be trusted on its own.
+func ClaimedScope(token string) string {
+	claims := &Claims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return ""
+	}
+	return claims.Scope
+}
@@ -0,0 +1,256 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"community-chatbot/internal/apperr"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Store is the backing key/value store used by Cache
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Claimer is a store whose keys can be claimed atomically, e.g. to recognize
+// repeated requests across replicas
+type Claimer interface {
+	// SetIfAbsent stores value under key unless it is already set, and reports whether it did
+	SetIfAbsent(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Taker is a store whose values can be taken atomically, e.g. to hand a value
+// to exactly one of several concurrent requests across replicas
+type Taker interface {
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Take returns the value of key and removes it in one step, so only one
+	// caller gets it
+	Take(ctx context.Context, key string) ([]byte, bool, error)
+}
+
+// Namespaces for the external integrations and generated content wrapped by the cache
+const (
+	NamespaceGeocode   = "geocode"
+	NamespaceWeather   = "weather"
+	NamespaceRouting   = "routing"
+	NamespaceTransit   = "transit"
+	NamespaceClimate   = "climate"
+	NamespaceChecklist = "checklist"
+	NamespaceNearby    = "nearby"
+	NamespaceAreaStats = "area_stats"
+	NamespaceHealth    = "health"
+)
+
+// generationTTL is how long the generation of a versioned cache is kept at
+// least; when it expires a new one starts, which only costs misses
+const generationTTL = 24 * time.Hour
+
+// ErrCachedFailure is returned when a lookup hits a negatively cached entry
+var ErrCachedFailure = errors.New("lookup failed recently")
+
+var lookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "cache_lookups_total",
+	Help: "Cache lookups by namespace and result (hit, miss, negative_hit).",
+}, []string{"namespace", "result"})
+
+var storeErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "cache_store_errors_total",
+	Help: "Errors returned by the cache backing store.",
+}, []string{"namespace"})
+
+// Cache wraps a Store for a single integration with its own TTLs
+type Cache struct {
+	store       Store
+	namespace   string
+	ttl         time.Duration
+	negativeTTL time.Duration
+	// versioned caches prefix keys with the current generation, see InvalidateAll
+	versioned bool
+}
+
+// entry is the serialized form of a cached value or a cached failure
+type entry struct {
+	Value json.RawMessage `json:"v,omitempty"`
+	Error string          `json:"e,omitempty"`
+}
+
+// New creates a cache for the given namespace. A negativeTTL of zero
+// disables caching of failed lookups.
+func New(store Store, namespace string, ttl, negativeTTL time.Duration) *Cache {
+	return &Cache{
+		store:       store,
+		namespace:   namespace,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+	}
+}
+
+// NewVersioned creates a cache whose entries can all be dropped at once with
+// InvalidateAll, for results that depend on data changing anywhere, such as
+// searches. Each lookup also reads the current generation from the store.
+func NewVersioned(store Store, namespace string, ttl, negativeTTL time.Duration) *Cache {
+	c := New(store, namespace, ttl, negativeTTL)
+	c.versioned = true
+	return c
+}
+
+// Lookup returns the cached value for key or calls fetch and caches its result.
+// Failed fetches are cached for the negative TTL so a broken upstream is not
+// hammered with identical requests; rate limited fetches are not, since they
+// succeed when retried shortly. A nil cache simply calls fetch.
+func Lookup[T any](ctx context.Context, c *Cache, key string, fetch func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	if c == nil || c.store == nil || c.ttl <= 0 {
+		return fetch(ctx)
+	}
+
+	storeKey := c.storeKey(ctx, key)
+	if raw, ok, err := c.store.Get(ctx, storeKey); err != nil {
+		storeErrors.WithLabelValues(c.namespace).Inc()
+		log.Printf("[CACHE] %s: get %q failed: %v", c.namespace, key, err)
+	} else if ok {
+		var e entry
+		if err := json.Unmarshal(raw, &e); err == nil {
+			if e.Error != "" {
+				lookups.WithLabelValues(c.namespace, "negative_hit").Inc()
+				return zero, errors.Join(ErrCachedFailure, errors.New(e.Error))
+			}
+			var value T
+			if err := json.Unmarshal(e.Value, &value); err == nil {
+				lookups.WithLabelValues(c.namespace, "hit").Inc()
+				return value, nil
+			}
+		}
+	}
+
+	lookups.WithLabelValues(c.namespace, "miss").Inc()
+	value, err := fetch(ctx)
+	if err != nil {
+		if c.negativeTTL > 0 && ctx.Err() == nil && apperr.KindOf(err) != apperr.RateLimited {
+			c.put(ctx, storeKey, entry{Error: err.Error()}, c.negativeTTL)
+		}
+		return zero, err
+	}
+
+	data, err := json.Marshal(value)
+	if err == nil {
+		c.put(ctx, storeKey, entry{Value: data}, c.ttl)
+	}
+	return value, nil
+}
+
+// Refresh calls fetch and caches its result for key, replacing any cached
+// value, so entries that are looked up often can be renewed before they
+// expire. Failures leave the cached value alone. A nil cache simply calls fetch.
+func Refresh[T any](ctx context.Context, c *Cache, key string, fetch func(ctx context.Context) (T, error)) (T, error) {
+	value, err := fetch(ctx)
+	if err != nil || c == nil || c.store == nil || c.ttl <= 0 {
+		return value, err
+	}
+	if data, err := json.Marshal(value); err == nil {
+		c.put(ctx, c.storeKey(ctx, key), entry{Value: data}, c.ttl)
+	}
+	return value, nil
+}
+
+// Invalidate removes a key from the cache
+func (c *Cache) Invalidate(ctx context.Context, key string) error {
+	if c == nil || c.store == nil {
+		return nil
+	}
+	return c.store.Delete(ctx, c.storeKey(ctx, key))
+}
+
+// InvalidateAll drops every entry of a versioned cache by starting a new
+// generation; the old entries are no longer looked up and expire. It does
+// nothing for other caches.
+func (c *Cache) InvalidateAll(ctx context.Context) error {
+	if c == nil || c.store == nil || !c.versioned {
+		return nil
+	}
+	_, err := c.newGeneration(ctx)
+	return err
+}
+
+// storeKey returns the key of an entry in the store
+func (c *Cache) storeKey(ctx context.Context, key string) string {
+	if !c.versioned {
+		return c.namespace + ":" + key
+	}
+	return c.namespace + ":" + c.generation(ctx) + ":" + key
+}
+
+// generation returns the current generation of a versioned cache, starting
+// one when there is none yet or it expired
+func (c *Cache) generation(ctx context.Context) string {
+	raw, ok, err := c.store.Get(ctx, c.namespace+":generation")
+	if err != nil {
+		storeErrors.WithLabelValues(c.namespace).Inc()
+		log.Printf("[CACHE] %s: get generation failed: %v", c.namespace, err)
+	} else if ok {
+		return string(raw)
+	}
+	generation, _ := c.newGeneration(ctx)
+	return generation
+}
+
+// newGeneration starts and returns a new generation; it is unique, so entries
+// of earlier generations are never looked up again
+func (c *Cache) newGeneration(ctx context.Context) (string, error) {
+	generation := strconv.FormatInt(time.Now().UnixNano(), 36)
+	if err := c.store.Set(ctx, c.namespace+":generation", []byte(generation), max(c.ttl, generationTTL)); err != nil {
+		storeErrors.WithLabelValues(c.namespace).Inc()
+		log.Printf("[CACHE] %s: set generation failed: %v", c.namespace, err)
+		return generation, err
+	}
+	return generation, nil
+}
+
+// put stores an entry, logging rather than failing the lookup on errors
+func (c *Cache) put(ctx context.Context, key string, e entry, ttl time.Duration) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if err := c.store.Set(ctx, key, data, ttl); err != nil {
+		storeErrors.WithLabelValues(c.namespace).Inc()
+		log.Printf("[CACHE] %s: set %q failed: %v", c.namespace, key, err)
+	}
+}
+
+// NewStore returns a Redis store when redisURL is set, otherwise an
+// in-memory LRU store bounded to maxEntries
+func NewStore(redisURL string, maxEntries int) (Store, error) {
+	if redisURL != "" {
+		return NewRedisStore(redisURL)
+	}
+	return NewMemoryStore(maxEntries), nil
+}
+
+// NewTaker returns a Redis store when redisURL is set, so replicas share
+// values, otherwise an in-memory LRU store bounded to maxEntries
+func NewTaker(redisURL string, maxEntries int) (Taker, error) {
+	if redisURL != "" {
+		return NewRedisStore(redisURL)
+	}
+	return NewMemoryStore(maxEntries), nil
+}
+
+// NewClaimer returns a Redis store when redisURL is set, so replicas share
+// claims, otherwise an in-memory LRU store bounded to maxEntries
+func NewClaimer(redisURL string, maxEntries int) (Claimer, error) {
+	if redisURL != "" {
+		return NewRedisStore(redisURL)
+	}
+	return NewMemoryStore(maxEntries), nil
+}
@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process LRU store with per-entry expiry
+type MemoryStore struct {
+	maxEntries int
+	items      map[string]*list.Element
+	order      *list.List
+	mutex      sync.Mutex
+}
+
+type memoryItem struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// NewMemoryStore creates an LRU store holding at most maxEntries items
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	if maxEntries <= 0 {
+		maxEntries = 1000
+	}
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		items:      make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get returns the value for key if present and not expired
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	elem, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	item := elem.Value.(*memoryItem)
+	if time.Now().After(item.expiresAt) {
+		s.removeElement(elem)
+		return nil, false, nil
+	}
+
+	s.order.MoveToFront(elem)
+	return item.value, true, nil
+}
+
+// Set stores value under key, evicting the least recently used entry when full
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+	if elem, ok := s.items[key]; ok {
+		item := elem.Value.(*memoryItem)
+		item.value = value
+		item.expiresAt = expiresAt
+		s.order.MoveToFront(elem)
+		return nil
+	}
+
+	s.items[key] = s.order.PushFront(&memoryItem{key: key, value: value, expiresAt: expiresAt})
+	for s.order.Len() > s.maxEntries {
+		s.removeElement(s.order.Back())
+	}
+	return nil
+}
+
+// SetIfAbsent stores value under key unless it holds an unexpired value, and
+// reports whether it did
+func (s *MemoryStore) SetIfAbsent(_ context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if elem, ok := s.items[key]; ok {
+		if time.Now().Before(elem.Value.(*memoryItem).expiresAt) {
+			s.order.MoveToFront(elem)
+			return false, nil
+		}
+		s.removeElement(elem)
+	}
+	s.items[key] = s.order.PushFront(&memoryItem{key: key, value: value, expiresAt: time.Now().Add(ttl)})
+	for s.order.Len() > s.maxEntries {
+		s.removeElement(s.order.Back())
+	}
+	return true, nil
+}
+
+// Take returns the value for key if present and not expired, and removes it
+func (s *MemoryStore) Take(_ context.Context, key string) ([]byte, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	elem, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	s.removeElement(elem)
+
+	item := elem.Value.(*memoryItem)
+	if time.Now().After(item.expiresAt) {
+		return nil, false, nil
+	}
+	return item.value, true, nil
+}
+
+// Delete removes key from the store
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if elem, ok := s.items[key]; ok {
+		s.removeElement(elem)
+	}
+	return nil
+}
+
+// Len returns the number of entries currently held
+func (s *MemoryStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.order.Len()
+}
+
+func (s *MemoryStore) removeElement(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.items, elem.Value.(*memoryItem).key)
+}
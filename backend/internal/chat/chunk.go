@@ -0,0 +1,68 @@
+package chat
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+// SplitWords splits text into chunks for streaming word by word. Chunks end at
+// word boundaries (Unicode UAX #29) with the spaces and punctuation that follow
+// a word kept on its chunk, so emoji sequences, accented letters and right to
+// left words are never split. Scripts without spaces, such as Chinese and
+// Japanese, are split into single characters.
+func SplitWords(text string) []string {
+	var chunks []string
+	chunk, hasWord := "", false
+	state := -1
+	for text != "" {
+		var segment string
+		segment, text, state = uniseg.FirstWordInString(text, state)
+		if isWordSegment(segment) {
+			if hasWord {
+				chunks = append(chunks, chunk)
+				chunk = ""
+			}
+			hasWord = true
+		}
+		chunk += segment
+	}
+	if chunk != "" {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// isWordSegment reports whether a word boundary segment is a word or symbol
+// (e.g. an emoji) rather than spaces or punctuation
+func isWordSegment(segment string) bool {
+	r, _ := utf8.DecodeRuneInString(segment)
+	return !unicode.IsSpace(r) && !unicode.IsPunct(r) && !unicode.Is(unicode.Cf, r)
+}
+
+// splitLastGrapheme splits text before its last grapheme cluster, which the
+// next chunk of a stream may still extend, e.g. with a skin tone modifier,
+// a zero width joiner or a combining accent. A rune cut off at the end of the
+// text is held back with that cluster.
+func splitLastGrapheme(text string) (string, string) {
+	complete := len(text)
+	for i := len(text) - 1; i >= 0 && i >= len(text)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(text[i]) {
+			if !utf8.FullRuneInString(text[i:]) {
+				complete = i
+			}
+			break
+		}
+	}
+
+	last := 0
+	for rest, state := text[:complete], -1; rest != ""; {
+		var cluster string
+		cluster, rest, _, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		if rest != "" {
+			last += len(cluster)
+		}
+	}
+	return text[:last], text[last:]
+}
@@ -0,0 +1,113 @@
+package chat
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"ascii", "Hello, world!", []string{"Hello, ", "world!"}},
+		{"leading spaces", "  lead", []string{"  lead"}},
+		{"accented letters", "Café crème", []string{"Café ", "crème"}},
+		{"combining accent", "Cafe\u0301 ok", []string{"Cafe\u0301 ", "ok"}},
+		{"skin tone modifier", "👍🏽 great", []string{"👍🏽 ", "great"}},
+		{"zero width joiner sequence", "👨‍👩‍👧 family", []string{"👨‍👩‍👧 ", "family"}},
+		{"flag", "🇩🇪 flag", []string{"🇩🇪 ", "flag"}},
+		{"chinese", "我喜欢徒步。", []string{"我", "喜", "欢", "徒", "步。"}},
+		{"japanese and latin", "日本語とEnglish", []string{"日", "本", "語", "と", "English"}},
+		{"hebrew", "שלום עולם", []string{"שלום ", "עולם"}},
+		{"arabic", "مرحبا بالعالم!", []string{"مرحبا ", "بالعالم!"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitWords(tt.text)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitWords(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+			if joined := strings.Join(got, ""); joined != tt.text {
+				t.Errorf("SplitWords(%q) joined = %q", tt.text, joined)
+			}
+		})
+	}
+}
+
+func TestSplitLastGrapheme(t *testing.T) {
+	tests := []struct {
+		text, head, last string
+	}{
+		{"", "", ""},
+		{"x", "", "x"},
+		{"abc", "ab", "c"},
+		{"é", "", "é"},
+		{"Cafe\u0301", "Caf", "e\u0301"},
+		{"caf\xc3", "ca", "f\xc3"},
+		{"ok \xf0\x9f\x91", "ok", " \xf0\x9f\x91"},
+		{"ok 👍🏽", "ok ", "👍🏽"},
+		{"a👨‍👩‍👧", "a", "👨‍👩‍👧"},
+		{"🇩🇪🇫🇷", "🇩🇪", "🇫🇷"},
+		{"徒步", "徒", "步"},
+		{"שלום", "שלו", "ם"},
+	}
+	for _, tt := range tests {
+		head, last := splitLastGrapheme(tt.text)
+		if head != tt.head || last != tt.last {
+			t.Errorf("splitLastGrapheme(%q) = %q, %q, want %q, %q", tt.text, head, last, tt.head, tt.last)
+		}
+	}
+}
+
+// TestSendTextKeepsGraphemes streams answers in chunks ending anywhere, even
+// inside a rune, and checks that every frame is valid UTF-8 made of whole
+// grapheme clusters
+func TestSendTextKeepsGraphemes(t *testing.T) {
+	texts := []string{
+		"Café crème",
+		"Cafe\u0301 ok",
+		"Great 👍🏽 for the 👨‍👩‍👧 and 🇩🇪!",
+		"我喜欢徒步。日本語とEnglish",
+		"שלום עולם, مرحبا بالعالم!",
+	}
+	for _, text := range texts {
+		for size := 1; size <= 4; size++ {
+			var frames []string
+			run := NewRun("", "")
+			run.SetTextEmitter(func(content string, final bool) error {
+				frames = append(frames, content)
+				return nil
+			})
+			for start := 0; start < len(text); start += size {
+				if err := run.EmitText(context.Background(), text[start:min(start+size, len(text))]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := run.FinishText(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := strings.Join(frames, ""); got != text || run.Response != text {
+				t.Fatalf("%q in chunks of %d bytes: streamed %q, response %q", text, size, got, run.Response)
+			}
+			clusters := 0
+			for _, frame := range frames {
+				if !utf8.ValidString(frame) {
+					t.Errorf("%q in chunks of %d bytes: frame %q is not valid UTF-8", text, size, frame)
+				}
+				clusters += uniseg.GraphemeClusterCount(frame)
+			}
+			if want := uniseg.GraphemeClusterCount(text); clusters != want {
+				t.Errorf("%q in chunks of %d bytes: frames %q split grapheme clusters", text, size, frames)
+			}
+		}
+	}
+}
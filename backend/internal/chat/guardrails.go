@@ -0,0 +1,357 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Refusal styles of Guardrails
+const (
+	// RefusalBrief declines in one sentence
+	RefusalBrief = "brief"
+	// RefusalRedirect declines and points to the topics the assistant does cover
+	RefusalRedirect = "redirect"
+)
+
+// DefaultRefusal is the refusal used when an operator has not written their own
+const DefaultRefusal = "Sorry, I can't help with that here."
+
+var guardrailRefusals = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "chat_guardrail_refusals_total",
+	Help: "Chat runs refused by guardrails, by where the blocked topic was found (input or output).",
+}, []string{"source"})
+
+// topicLexicon holds the words marking the built-in topics. Other topics are
+// matched by their own name.
+var topicLexicon = map[string][]string{
+	"medical": {"medical advice", "diagnosis", "diagnose", "prescription", "prescribe", "dosage", "medication",
+		"antibiotic", "antibiotics", "painkiller", "painkillers", "symptom", "symptoms"},
+	"legal": {"legal advice", "lawyer", "attorney", "lawsuit", "sue", "liability", "liable", "court case"},
+	"financial": {"financial advice", "investment advice", "invest in", "stock market", "cryptocurrency",
+		"bitcoin", "tax advice", "mortgage"},
+	"political": {"election", "elections", "vote for", "political party", "politician", "politicians"},
+}
+
+// allowedLexicon holds the words marking topics operators commonly allow.
+// Other allowed topics are matched by their own name.
+var allowedLexicon = map[string][]string{
+	"hiking":       {"hike", "hikes", "hiking", "hiker", "trek", "trekking", "walk", "walking", "summit", "peak", "mountain", "mountains"},
+	"cycling":      {"bike", "bikes", "biking", "cycle", "cycling", "cyclist", "mtb", "mountain bike", "gravel", "bike path"},
+	"climbing":     {"climb", "climbing", "climber", "boulder", "bouldering", "crag", "via ferrata"},
+	"camping":      {"camp", "camping", "campsite", "campground", "tent", "bivouac", "hut"},
+	"running":      {"run", "running", "runner", "jog", "jogging", "trail run", "trail running"},
+	"skiing":       {"ski", "skiing", "skier", "snowshoe", "snowshoeing", "piste", "cross-country"},
+	"water sports": {"kayak", "kayaking", "canoe", "canoeing", "paddle", "paddling", "swim", "swimming", "lake", "river", "sup"},
+	"outdoor":      {"outdoor", "outdoors", "nature", "park", "forest", "hike", "bike", "climb", "camp"},
+}
+
+// assistantLexicon holds the word stems of what the assistant covers whatever
+// the allowed topics: finding and planning activities, where they are, the
+// weather and getting there, in the languages the chat answers in
+var assistantLexicon = []string{
+	"activit", "trail", "route", "tour", "outdoor", "weather", "forecast", "rain", "near", "around here",
+	"weekend", "recommend", "suggest", "difficult", "distance", "elevation", "map", "direction", "transit",
+	"bus", "train", "parking", "review", "favorite", "collection", "community", "event",
+	// German
+	"aktivität", "wander", "rad", "weg", "wetter", "nähe", "ausflug", "berg", "see", "wochenende", "empfehl", "draußen",
+	// French
+	"randonn", "vélo", "sentier", "itinéraire", "météo", "près", "proche", "balade", "montagne", "lac", "recommand",
+	// Spanish
+	"actividad", "senderis", "ruta", "bici", "tiempo", "cerca", "excursi", "montaña", "lago", "fin de semana", "recomiend",
+	// Italian
+	"attività", "escursion", "percors", "meteo", "vicino", "montagna", "fine settimana", "consigli",
+}
+
+// followUpWords is the length in words up to which messages are not classified
+// against the allowed topics: follow-ups such as "and tomorrow?" and greetings
+// name no topic
+const followUpWords = 4
+
+// OffTopic is the topic of verdicts refusing a message on none of the allowed topics
+const OffTopic = "other"
+
+// Guardrails are an operator's topic rules. They are compiled into the system
+// prompt and enforced by a keyword classifier on both the user message and the
+// answer, since a prompt alone does not keep every answer on topic. With
+// allowed topics, messages must be on one of them or on what the assistant
+// always covers; answers are only checked for refused topics, since they
+// follow the message they answer.
+type Guardrails struct {
+	AllowedTopics []string
+	BlockedTopics []string
+	RefusalStyle  string
+	Refusal       string
+
+	// refused are the blocked topics followed by the built-in topics outside
+	// the allowed ones, in the order they are checked
+	refused  []string
+	offTopic map[string]bool
+	matchers map[string]*regexp.Regexp
+	// allowed matches the words of the allowed topics and of what the
+	// assistant covers, nil without allowed topics
+	allowed *regexp.Regexp
+	// longest is the length in runes of the longest refused word or phrase
+	longest int
+}
+
+// GuardrailVerdict is the outcome of classifying a text
+type GuardrailVerdict struct {
+	Allowed bool   `json:"allowed"`
+	Topic   string `json:"topic,omitempty"` // the refused topic found, OffTopic for messages on none of the allowed ones
+	// OffTopic is set when the topic is refused for not being an allowed one
+	OffTopic bool `json:"off_topic,omitempty"`
+}
+
+// NewGuardrails creates guardrails. Topics are matched case-insensitively as
+// whole words in any script; an empty refusal uses DefaultRefusal. With
+// allowed topics, the built-in topics not among them are refused as well, and
+// messages on none of the allowed topics are refused by CheckMessage.
+func NewGuardrails(allowed, blocked []string, style, refusal string) *Guardrails {
+	g := &Guardrails{
+		AllowedTopics: allowed,
+		BlockedTopics: blocked,
+		RefusalStyle:  style,
+		Refusal:       refusal,
+		refused:       append([]string(nil), blocked...),
+		offTopic:      make(map[string]bool),
+		matchers:      make(map[string]*regexp.Regexp, len(blocked)),
+	}
+	if len(allowed) > 0 {
+		listed := make(map[string]bool, len(allowed)+len(blocked))
+		for _, topic := range append(append([]string(nil), allowed...), blocked...) {
+			listed[strings.ToLower(topic)] = true
+		}
+		var outside []string
+		for topic := range topicLexicon {
+			if !listed[topic] {
+				outside = append(outside, topic)
+			}
+		}
+		sort.Strings(outside)
+		for _, topic := range outside {
+			g.refused = append(g.refused, topic)
+			g.offTopic[topic] = true
+		}
+
+		words := append([]string(nil), assistantLexicon...)
+		for _, topic := range allowed {
+			words = append(words, topic)
+			words = append(words, allowedLexicon[strings.ToLower(topic)]...)
+		}
+		// Allowed words match as stems, so inflections and compounds count as on topic
+		g.allowed = wordMatcher(words, true)
+	}
+
+	for _, topic := range g.refused {
+		words := topicLexicon[strings.ToLower(topic)]
+		if words == nil {
+			words = []string{topic}
+		}
+		for _, word := range words {
+			g.longest = max(g.longest, utf8.RuneCountInString(word))
+		}
+		g.matchers[topic] = wordMatcher(words, false)
+	}
+	return g
+}
+
+// wordMatcher returns a regexp matching any of words in lower case text as a
+// whole word, or with prefix as the start of a word. \b only knows ASCII word
+// characters, so boundaries are any non-letter, non-digit.
+func wordMatcher(words []string, prefix bool) *regexp.Regexp {
+	quoted := make([]string, len(words))
+	for i, word := range words {
+		quoted[i] = regexp.QuoteMeta(strings.ToLower(word))
+	}
+	end := `(?:$|[^\p{L}\p{N}])`
+	if prefix {
+		end = ""
+	}
+	return regexp.MustCompile(`(?:^|[^\p{L}\p{N}])(?:` + strings.Join(quoted, "|") + `)` + end)
+}
+
+// Prompt compiles the rules into instructions for the model, or returns "" without rules
+func (g *Guardrails) Prompt() string {
+	var parts []string
+	if len(g.AllowedTopics) > 0 {
+		parts = append(parts, fmt.Sprintf("Only discuss these topics: %s. Politely decline anything else.",
+			strings.Join(g.AllowedTopics, ", ")))
+	}
+	if len(g.BlockedTopics) > 0 {
+		parts = append(parts, fmt.Sprintf("Never give %s advice or information, even when asked directly or in passing.",
+			strings.Join(g.BlockedTopics, ", ")))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	parts = append(parts, fmt.Sprintf("When declining, answer with: %q", g.RefusalMessage()))
+	return "Operator rules: " + strings.Join(parts, " ")
+}
+
+// RefusalMessage returns the text sent instead of an answer on a blocked topic
+func (g *Guardrails) RefusalMessage() string {
+	refusal := g.Refusal
+	if refusal == "" {
+		refusal = DefaultRefusal
+	}
+	if g.RefusalStyle == RefusalRedirect && len(g.AllowedTopics) > 0 {
+		refusal += fmt.Sprintf(" I'm happy to help with %s, though.", strings.Join(g.AllowedTopics, ", "))
+	}
+	return refusal
+}
+
+// Check classifies a text against the blocked topics and the built-in topics
+// outside the allowed ones
+func (g *Guardrails) Check(text string) GuardrailVerdict {
+	text = strings.ToLower(text)
+	for _, topic := range g.refused {
+		if g.matchers[topic].MatchString(text) {
+			return GuardrailVerdict{Topic: topic, OffTopic: g.offTopic[topic]}
+		}
+	}
+	return GuardrailVerdict{Allowed: true}
+}
+
+// CheckMessage classifies a user message like Check and, with allowed topics,
+// refuses messages longer than a follow-up that are on none of them nor on
+// what the assistant covers
+func (g *Guardrails) CheckMessage(message string) GuardrailVerdict {
+	verdict := g.Check(message)
+	if !verdict.Allowed || g.allowed == nil || len(strings.Fields(message)) <= followUpWords {
+		return verdict
+	}
+	if !g.allowed.MatchString(strings.ToLower(message)) {
+		return GuardrailVerdict{Topic: OffTopic, OffTopic: true}
+	}
+	return verdict
+}
+
+// GuardrailLoader returns the current guardrails, or nil when none are configured
+type GuardrailLoader func(ctx context.Context) (*Guardrails, error)
+
+// GuardrailStage applies the operator's guardrails: messages on a refused topic
+// or on none of the allowed ones are refused without calling the model, other runs get the rules in their
+// instructions and their answer filtered by a GuardrailFilter. Load failures
+// are logged and the run continues without guardrails.
+func GuardrailStage(load GuardrailLoader) Stage {
+	return StageFunc{
+		StageName: "guardrails",
+		Fn: func(ctx context.Context, run *Run, next Handler) error {
+			guardrails, err := load(ctx)
+			if err != nil {
+				log.Printf("[CHAT] Run %s: failed to load guardrails: %v", run.ID, err)
+			}
+			if guardrails == nil {
+				return next(ctx, run)
+			}
+
+			if verdict := guardrails.CheckMessage(run.Message); !verdict.Allowed {
+				guardrailRefusals.WithLabelValues("input").Inc()
+				log.Printf("[GUARDRAIL] Run %s: message refused (topic: %s)", run.ID, verdict.Topic)
+				if err := run.EmitText(ctx, guardrails.RefusalMessage()); err != nil {
+					return err
+				}
+				return run.FinishText(ctx)
+			}
+
+			if prompt := guardrails.Prompt(); prompt != "" {
+				run.AddInstruction(prompt)
+			}
+			run.AddTextFilter(NewGuardrailFilter(guardrails, run.ID))
+			return next(ctx, run)
+		},
+	}
+}
+
+// GuardrailFilter is a streaming TextFilter that classifies the answer as it
+// arrives. Once a blocked topic shows up, the rest of the answer is dropped and
+// the refusal sent in its place. Text is only released up to the last
+// whitespace boundary so a blocked word split across chunks is caught whole.
+type GuardrailFilter struct {
+	guardrails *Guardrails
+	runID      string
+	// tail is the end of the released text a topic phrase ending in the next
+	// text could start in
+	tail     string
+	pending  string
+	released bool
+	blocked  bool
+}
+
+// NewGuardrailFilter creates a guardrail filter for a run
+func NewGuardrailFilter(guardrails *Guardrails, runID string) *GuardrailFilter {
+	return &GuardrailFilter{
+		guardrails: guardrails,
+		runID:      runID,
+	}
+}
+
+// Filter releases the complete words of the buffered text unless they are on a blocked topic
+func (f *GuardrailFilter) Filter(ctx context.Context, chunk string) (string, error) {
+	if f.blocked {
+		return "", nil
+	}
+	f.pending += chunk
+
+	cut := strings.LastIndexFunc(f.pending, unicode.IsSpace) + 1
+	if cut == 0 {
+		return "", nil
+	}
+	ready := f.pending[:cut]
+	f.pending = f.pending[cut:]
+	return f.release(ready), nil
+}
+
+// Flush releases the held back text, or the refusal when the answer was blocked
+func (f *GuardrailFilter) Flush(ctx context.Context) (string, error) {
+	ready := f.pending
+	f.pending = ""
+	if !f.blocked {
+		if ready = f.release(ready); !f.blocked {
+			return ready, nil
+		}
+	}
+	if f.released {
+		return "\n\n" + f.guardrails.RefusalMessage(), nil
+	}
+	return f.guardrails.RefusalMessage(), nil
+}
+
+// release returns text unless the answer so far is on a blocked topic
+func (f *GuardrailFilter) release(text string) string {
+	// Topics are phrases that may start in text released before. Phrases
+	// ending there were already checked, so the new text is checked with the
+	// tail that fits the longest phrase and the character before it, and the
+	// work per chunk does not grow with the answer.
+	window := f.tail + text
+	f.tail = lastRunes(window, f.guardrails.longest+1)
+	if verdict := f.guardrails.Check(window); !verdict.Allowed {
+		f.blocked = true
+		guardrailRefusals.WithLabelValues("output").Inc()
+		log.Printf("[GUARDRAIL] Run %s: answer cut off (topic: %s)", f.runID, verdict.Topic)
+		return ""
+	}
+	f.released = f.released || text != ""
+	return text
+}
+
+// lastRunes returns the last n runes of text
+func lastRunes(text string, n int) string {
+	for i := len(text); i > 0; n-- {
+		if n == 0 {
+			return text[i:]
+		}
+		_, size := utf8.DecodeLastRuneInString(text[:i])
+		i -= size
+	}
+	return text
+}
@@ -0,0 +1,39 @@
+package chat
+
+import "testing"
+
+func TestGuardrailsCheckMessage(t *testing.T) {
+	guardrails := NewGuardrails([]string{"hiking", "birdwatching"}, []string{"gambling"}, RefusalBrief, "")
+	tests := []struct {
+		message string
+		allowed bool
+		topic   string
+	}{
+		{"Which hikes near Munich are good for kids?", true, ""},
+		{"Is there a quiet spot for birdwatching by the lake?", true, ""},
+		{"What is the weather like for the trail on Saturday?", true, ""},
+		{"and tomorrow?", true, ""},
+		{"Write me a cover letter for a job application please", false, OffTopic},
+		{"Which casino is best for gambling after the hike?", false, "gambling"},
+		{"Can you recommend a dosage of painkillers for my knee?", false, "medical"},
+		{"Welche Wanderwege gibt es in der Nähe von München?", true, ""},
+		{"Quelles randonnées faciles près de Grenoble pour dimanche ?", true, ""},
+		{"Wie hoch ist die Einkommensteuer in Bayern dieses Jahr genau?", false, OffTopic},
+	}
+	for _, tt := range tests {
+		verdict := guardrails.CheckMessage(tt.message)
+		if verdict.Allowed != tt.allowed || verdict.Topic != tt.topic {
+			t.Errorf("CheckMessage(%q) = %+v, want allowed %v, topic %q", tt.message, verdict, tt.allowed, tt.topic)
+		}
+	}
+}
+
+func TestGuardrailsWithoutAllowedTopics(t *testing.T) {
+	guardrails := NewGuardrails(nil, []string{"gambling"}, RefusalBrief, "")
+	if verdict := guardrails.CheckMessage("Write me a cover letter for a job application please"); !verdict.Allowed {
+		t.Errorf("message refused without allowed topics: %+v", verdict)
+	}
+	if verdict := guardrails.CheckMessage("Where can I go gambling?"); verdict.Allowed || verdict.Topic != "gambling" {
+		t.Errorf("blocked topic not refused: %+v", verdict)
+	}
+}
@@ -0,0 +1,157 @@
+package chat
+
+import (
+	"context"
+	"log"
+	"unicode/utf8"
+
+	"community-chatbot/internal/apperr"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ErrNothingToContinue is returned for runs continuing a conversation whose last
+// message is not an interrupted answer
+var ErrNothingToContinue = apperr.New(apperr.Conflict, "no interrupted answer to continue")
+
+var (
+	historyTokens = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "chat_history_tokens",
+		Help:    "Estimated tokens of conversation summary and history sent with each run.",
+		Buckets: prometheus.ExponentialBuckets(64, 2, 8),
+	})
+	historyCompressions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "chat_history_compressions_total",
+		Help: "Times older conversation turns were folded into the rolling summary, by result.",
+	}, []string{"result"})
+	historyTokensCompressed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "chat_history_tokens_compressed_total",
+		Help: "Estimated tokens of conversation turns replaced by the rolling summary.",
+	})
+)
+
+// Turn is an earlier message of the conversation
+type Turn struct {
+	ID          uint
+	Role        string
+	Content     string
+	Interrupted bool // the answer was cut off before it was finished
+}
+
+// Memory is what the model is given of a conversation: a rolling summary of
+// older turns and the turns since, oldest first
+type Memory struct {
+	Summary string
+	Turns   []Turn
+}
+
+// MemoryStore loads conversation memory and stores updated summaries
+type MemoryStore interface {
+	// Memory returns the summary and the turns not yet summarized, for edits
+	// (editOf is not 0) those before the edited message. sessionID is the
+	// session of anonymous users who have one.
+	Memory(ctx context.Context, conversationID, sessionID string, userID, editOf uint) (*Memory, error)
+	// SaveSummary replaces the summary, which now covers turns up to and including through
+	SaveSummary(ctx context.Context, conversationID, summary string, through uint) error
+}
+
+// Summarizer folds turns into a previous summary and returns the new summary
+type Summarizer func(ctx context.Context, previous string, turns []Turn) (string, error)
+
+// CompressionConfig controls how much conversation history is sent verbatim
+type CompressionConfig struct {
+	// VerbatimTurns is how many of the latest turns are always kept word for word
+	VerbatimTurns int
+	// TokenBudget caps the estimated tokens of summary and history in the prompt
+	TokenBudget int
+}
+
+// EstimateTokens approximates the token count of text (about four characters per token)
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+// memoryTokens estimates the tokens of a summary and turns
+func memoryTokens(summary string, turns []Turn) int {
+	tokens := EstimateTokens(summary)
+	for _, turn := range turns {
+		tokens += EstimateTokens(turn.Content)
+	}
+	return tokens
+}
+
+// CompressionStage gives the run the conversation's summary and recent turns.
+// Once the turns outside the verbatim window add up to another window, or the
+// history exceeds the token budget, they are summarized into the stored rolling
+// summary. Without a summarizer older turns are only dropped. It must run before
+// PersistenceStage so the current message is not part of the history.
+//
+// Runs continuing an interrupted answer get it as Continued instead of as part
+// of the history, and fail with ErrNothingToContinue when the conversation does
+// not end in one.
+func CompressionStage(store MemoryStore, summarize Summarizer, cfg CompressionConfig) Stage {
+	return StageFunc{
+		StageName: "compression",
+		Fn: func(ctx context.Context, run *Run, next Handler) error {
+			if run.ConversationID == "" {
+				return next(ctx, run)
+			}
+
+			memory, err := store.Memory(ctx, run.ConversationID, run.SessionID, run.UserID, run.EditOf)
+			if err != nil {
+				log.Printf("[CHAT] Run %s: failed to load conversation history: %v", run.ID, err)
+				if run.Continue {
+					return err
+				}
+				return next(ctx, run)
+			}
+
+			summary, turns := memory.Summary, memory.Turns
+			if run.Continue {
+				if len(turns) == 0 || !turns[len(turns)-1].Interrupted {
+					return ErrNothingToContinue
+				}
+				last := turns[len(turns)-1]
+				run.Continued, turns = &last, turns[:len(turns)-1]
+			}
+			if len(turns) > cfg.VerbatimTurns &&
+				(len(turns) >= 2*cfg.VerbatimTurns || memoryTokens(summary, turns) > cfg.TokenBudget) {
+				older, recent := turns[:len(turns)-cfg.VerbatimTurns], turns[len(turns)-cfg.VerbatimTurns:]
+				// Edits are answered off the active branch, whose summary is kept
+				if summarize != nil && !run.DryRun && run.EditOf == 0 {
+					summary = compress(ctx, run, store, summarize, summary, older)
+				}
+				// Turns that could not be summarized are retried on the next run
+				turns = recent
+			}
+
+			// Long recent turns can exceed the budget on their own; drop the oldest
+			for len(turns) > 1 && memoryTokens(summary, turns) > cfg.TokenBudget {
+				turns = turns[1:]
+			}
+
+			run.Summary, run.History = summary, turns
+			historyTokens.Observe(float64(memoryTokens(summary, turns)))
+			return next(ctx, run)
+		},
+	}
+}
+
+// compress folds older turns into the summary and stores it, returning the
+// previous summary when summarizing fails
+func compress(ctx context.Context, run *Run, store MemoryStore, summarize Summarizer, previous string, older []Turn) string {
+	summary, err := summarize(ctx, previous, older)
+	if err == nil {
+		err = store.SaveSummary(ctx, run.ConversationID, summary, older[len(older)-1].ID)
+	}
+	if err != nil {
+		log.Printf("[CHAT] Run %s: failed to summarize %d turns: %v", run.ID, len(older), err)
+		historyCompressions.WithLabelValues("failed").Inc()
+		return previous
+	}
+
+	historyCompressions.WithLabelValues("ok").Inc()
+	historyTokensCompressed.Add(float64(memoryTokens("", older)))
+	return summary
+}
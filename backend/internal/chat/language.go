@@ -0,0 +1,69 @@
+package chat
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"community-chatbot/internal/models"
+)
+
+// AnswerLanguage returns the language the run is answered in: the one the
+// client asked for, else the user's preference, else the first supported
+// language of the Accept-Language header, else models.DefaultLanguage
+func (r *Run) AnswerLanguage() string {
+	if models.IsLanguage(r.Language) {
+		return r.Language
+	}
+	if r.UserContext != nil && models.IsLanguage(r.UserContext.Language) {
+		return r.UserContext.Language
+	}
+	if language := ParseAcceptLanguage(r.AcceptLanguage); language != "" {
+		return language
+	}
+	return models.DefaultLanguage
+}
+
+// ParseAcceptLanguage returns the supported language an Accept-Language
+// header prefers most, or "" when it names none. Regional variants count as
+// their language, e.g. de-CH as de.
+func ParseAcceptLanguage(header string) string {
+	type weighted struct {
+		code    string
+		quality float64
+	}
+	var languages []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		code, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if !models.IsLanguage(code) {
+			continue
+		}
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil || parsed <= 0 {
+				continue
+			}
+			quality = parsed
+		}
+		languages = append(languages, weighted{code: code, quality: quality})
+	}
+	if len(languages) == 0 {
+		return ""
+	}
+	// Stable, so equally weighted languages keep the header's order
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].quality > languages[j].quality })
+	return languages[0].code
+}
+
+// LanguagePrompt tells the model which language to answer in, or returns ""
+// for the default language, which needs no instruction
+func LanguagePrompt(language string) string {
+	name, ok := models.Languages[language]
+	if !ok || language == models.DefaultLanguage {
+		return ""
+	}
+	return fmt.Sprintf("Always answer in %s, whatever language the community data is in. Keep the names of activities and places as they are.", name)
+}
@@ -0,0 +1,156 @@
+package chat
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"community-chatbot/internal/apperr"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// ErrGenerationQueueFull is returned when every generation slot is taken and
+	// the queue is full
+	ErrGenerationQueueFull = apperr.New(apperr.RateLimited, "the assistant is busy")
+	// ErrGenerationQueueTimeout is returned for runs that waited the maximum
+	// time for a generation slot
+	ErrGenerationQueueTimeout = apperr.New(apperr.RateLimited, "the assistant is busy")
+)
+
+var (
+	generationsLimit = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "llm_generations_limit",
+		Help: "Maximum concurrent LLM generations of this replica; 0 when unlimited.",
+	})
+	generationsActive = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "llm_generations_active",
+		Help: "LLM generations currently running.",
+	})
+	generationsQueued = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "llm_generations_queued",
+		Help: "Chat runs waiting for a free generation slot.",
+	})
+	generationQueueWait = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "llm_generation_queue_wait_seconds",
+		Help:    "Time runs waited for a generation slot, including runs that gave up.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	})
+	generationsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "llm_generations_rejected_total",
+		Help: "Runs refused a generation slot, by reason (queue_full, timeout).",
+	}, []string{"reason"})
+)
+
+// Limiter caps the concurrent LLM generations of a replica to protect the
+// provider quota. Runs beyond the limit wait in a first-come, first-served
+// queue for a bounded time.
+type Limiter struct {
+	limit    int
+	maxQueue int
+	maxWait  time.Duration
+
+	mutex  sync.Mutex
+	active int
+	// waiters are the channels of queued runs, closed when a slot is handed over
+	waiters list.List
+}
+
+// NewLimiter creates a limiter allowing limit concurrent generations, with at
+// most maxQueue runs waiting up to maxWait each. A limit of 0 disables it.
+func NewLimiter(limit, maxQueue int, maxWait time.Duration) *Limiter {
+	generationsLimit.Set(float64(limit))
+	return &Limiter{
+		limit:    limit,
+		maxQueue: maxQueue,
+		maxWait:  maxWait,
+	}
+}
+
+// MaxWait returns how long runs wait for a slot at most
+func (l *Limiter) MaxWait() time.Duration {
+	return l.maxWait
+}
+
+// Acquire takes a generation slot and returns the function releasing it. When
+// all slots are taken, the run is queued and onQueued is called with its
+// position, 1 being next; it fails with ErrGenerationQueueFull when the queue
+// is full and ErrGenerationQueueTimeout when no slot freed up in time. A nil
+// limiter never waits.
+func (l *Limiter) Acquire(ctx context.Context, onQueued func(position int) error) (func(), error) {
+	if l == nil || l.limit <= 0 {
+		return func() {}, nil
+	}
+
+	l.mutex.Lock()
+	if l.active < l.limit && l.waiters.Len() == 0 {
+		l.active++
+		l.mutex.Unlock()
+		generationsActive.Inc()
+		return l.releaseFunc(), nil
+	}
+	if l.waiters.Len() >= l.maxQueue {
+		l.mutex.Unlock()
+		generationsRejected.WithLabelValues("queue_full").Inc()
+		return nil, ErrGenerationQueueFull
+	}
+	ready := make(chan struct{})
+	waiter := l.waiters.PushBack(ready)
+	position := l.waiters.Len()
+	l.mutex.Unlock()
+
+	generationsQueued.Inc()
+	start := time.Now()
+	defer func() {
+		generationsQueued.Dec()
+		generationQueueWait.Observe(time.Since(start).Seconds())
+	}()
+
+	err := onQueued(position)
+	if err == nil {
+		timer := time.NewTimer(l.maxWait)
+		defer timer.Stop()
+		select {
+		case <-ready:
+			return l.releaseFunc(), nil
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-timer.C:
+			err = ErrGenerationQueueTimeout
+		}
+	}
+
+	l.mutex.Lock()
+	select {
+	case <-ready:
+		// The slot was handed over while giving up, so pass it on
+		l.mutex.Unlock()
+		l.releaseFunc()()
+	default:
+		l.waiters.Remove(waiter)
+		l.mutex.Unlock()
+	}
+	if err == ErrGenerationQueueTimeout {
+		generationsRejected.WithLabelValues("timeout").Inc()
+	}
+	return nil, err
+}
+
+// releaseFunc returns the function releasing a slot once. The slot is handed
+// straight to the first queued run, so runs arriving meanwhile cannot overtake it.
+func (l *Limiter) releaseFunc() func() {
+	return sync.OnceFunc(func() {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		if next := l.waiters.Front(); next != nil {
+			l.waiters.Remove(next)
+			close(next.Value.(chan struct{}))
+			return
+		}
+		l.active--
+		generationsActive.Dec()
+	})
+}
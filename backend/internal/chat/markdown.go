@@ -0,0 +1,215 @@
+package chat
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// OutputContract describes the Markdown subset answers are guaranteed to use, so
+// every frontend can render them the same way. It is published by the capabilities endpoint.
+type OutputContract struct {
+	Version     int      `json:"version"`
+	Format      string   `json:"format"`
+	Elements    []string `json:"elements"`
+	LinkSchemes []string `json:"link_schemes"`
+	RawHTML     bool     `json:"raw_html"`
+	Images      bool     `json:"images"`
+	CodeBlocks  string   `json:"code_blocks"`
+	Annotations string   `json:"annotations"`
+}
+
+// MarkdownContract is the output contract enforced by PostProcessStage
+var MarkdownContract = OutputContract{
+	Version: 1,
+	Format:  "commonmark+gfm-tables",
+	Elements: []string{
+		"paragraph", "heading", "emphasis", "strong", "strikethrough", "inline_code",
+		"code_block", "blockquote", "list", "table", "thematic_break", "link",
+	},
+	LinkSchemes: []string{"https", "http", "mailto"},
+	RawHTML:     false,
+	Images:      false,
+	CodeBlocks:  "fenced with a language tag; unterminated blocks are closed",
+	Annotations: "answers with code blocks or tables are followed by a CONTENT_ANNOTATIONS event listing them in order",
+}
+
+// BlockAnnotation describes a code block or table in an answer
+type BlockAnnotation struct {
+	Type     string `json:"type"` // code or table
+	Index    int    `json:"index"`
+	Language string `json:"language,omitempty"`
+	Lines    int    `json:"lines,omitempty"`
+	Columns  int    `json:"columns,omitempty"`
+	Rows     int    `json:"rows,omitempty"`
+}
+
+// AnnotationsEvent lists the code blocks and tables of a completed answer
+type AnnotationsEvent struct {
+	Type   string            `json:"type"`
+	Blocks []BlockAnnotation `json:"blocks"`
+}
+
+var (
+	markdownImage = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	markdownLink  = regexp.MustCompile(`\[([^\]]*)\]\(\s*([^()\s]*(?:\([^()]*\)[^()\s]*)*)(?:\s+"[^"]*")?\s*\)`)
+	tableDivider  = regexp.MustCompile(`^\s*\|?\s*:?-{3,}:?\s*(\|\s*:?-{3,}:?\s*)*\|?\s*$`)
+)
+
+// sanitizeLinks reduces images and links with schemes outside the contract to their text
+func sanitizeLinks(text string) string {
+	text = markdownImage.ReplaceAllString(text, "$1")
+	return markdownLink.ReplaceAllStringFunc(text, func(match string) string {
+		parts := markdownLink.FindStringSubmatch(match)
+		if allowedLinkTarget(parts[2]) {
+			return match
+		}
+		return parts[1]
+	})
+}
+
+// allowedLinkTarget reports whether a link target uses an allowed scheme. activity://
+// links are allowed here because they are rewritten to frontend links afterwards.
+func allowedLinkTarget(target string) bool {
+	lower := strings.ToLower(target)
+	if strings.HasPrefix(lower, "activity://") {
+		return true
+	}
+	for _, scheme := range MarkdownContract.LinkSchemes {
+		if strings.HasPrefix(lower, scheme+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// MarkdownAnnotator is a streaming TextFilter that enforces the code block rules of
+// the output contract and records code blocks and tables for the annotations event.
+// Text is passed through immediately except for lines that may open or close a fence.
+type MarkdownAnnotator struct {
+	line     strings.Builder // the current, incomplete line
+	held     string          // held-back text of the current line
+	prevLine string
+	fence    string // the open fence marker, "" outside code blocks
+	blocks   []BlockAnnotation
+}
+
+// NewMarkdownAnnotator creates a Markdown annotating filter
+func NewMarkdownAnnotator() *MarkdownAnnotator {
+	return &MarkdownAnnotator{}
+}
+
+// Filter passes text through, holding back lines that may be code fences
+func (m *MarkdownAnnotator) Filter(_ context.Context, chunk string) (string, error) {
+	var out strings.Builder
+	for chunk != "" {
+		newline := strings.IndexByte(chunk, '\n')
+		if newline < 0 {
+			m.line.WriteString(chunk)
+			m.held += chunk
+			chunk = ""
+		} else {
+			m.line.WriteString(chunk[:newline])
+			m.held += chunk[:newline+1]
+			chunk = chunk[newline+1:]
+
+			out.WriteString(m.endLine())
+			continue
+		}
+
+		if !mayBeFence(m.line.String()) {
+			out.WriteString(m.held)
+			m.held = ""
+		}
+	}
+	return out.String(), nil
+}
+
+// Flush completes the last line and closes an unterminated code block
+func (m *MarkdownAnnotator) Flush(_ context.Context) (string, error) {
+	out := ""
+	midLine := m.line.Len() > 0
+	if midLine {
+		out = m.endLine()
+	}
+	if m.fence != "" {
+		if midLine {
+			out += "\n"
+		}
+		out += m.fence
+		m.fence = ""
+	}
+	return out, nil
+}
+
+// Annotations returns the code blocks and tables seen so far
+func (m *MarkdownAnnotator) Annotations() []BlockAnnotation {
+	return m.blocks
+}
+
+// endLine classifies the completed line and returns its held-back text,
+// adding a language to fences that open without one
+func (m *MarkdownAnnotator) endLine() string {
+	line := m.line.String()
+	held := m.held
+	m.line.Reset()
+	m.held = ""
+	defer func() { m.prevLine = line }()
+
+	trimmed := strings.TrimSpace(line)
+	if m.fence != "" {
+		if strings.HasPrefix(trimmed, m.fence) && strings.Trim(trimmed, m.fence[:1]) == "" {
+			m.fence = ""
+			return held
+		}
+		m.blocks[len(m.blocks)-1].Lines++
+		return held
+	}
+
+	if marker, language, ok := parseFence(trimmed); ok {
+		m.fence = marker
+		if language == "" {
+			language = "text"
+			held = strings.Replace(held, marker, marker+language, 1)
+		}
+		m.blocks = append(m.blocks, BlockAnnotation{Type: "code", Index: len(m.blocks), Language: language})
+		return held
+	}
+
+	if tableDivider.MatchString(line) && strings.Contains(m.prevLine, "|") {
+		m.blocks = append(m.blocks, BlockAnnotation{
+			Type:    "table",
+			Index:   len(m.blocks),
+			Columns: len(strings.Split(strings.Trim(strings.TrimSpace(m.prevLine), "|"), "|")),
+		})
+		return held
+	}
+	if n := len(m.blocks); n > 0 && m.blocks[n-1].Type == "table" && strings.Contains(line, "|") &&
+		strings.Contains(m.prevLine, "|") {
+		m.blocks[n-1].Rows++
+	}
+	return held
+}
+
+// parseFence recognizes an opening code fence and its language
+func parseFence(trimmed string) (marker, language string, ok bool) {
+	for _, c := range []string{"`", "~"} {
+		if strings.HasPrefix(trimmed, strings.Repeat(c, 3)) {
+			n := len(trimmed) - len(strings.TrimLeft(trimmed, c))
+			return trimmed[:n], strings.TrimSpace(trimmed[n:]), true
+		}
+	}
+	return "", "", false
+}
+
+// mayBeFence reports whether an incomplete line could still become a code fence
+func mayBeFence(partial string) bool {
+	trimmed := strings.TrimLeft(partial, " ")
+	if len(partial)-len(trimmed) > 3 {
+		return false
+	}
+	if trimmed == "" {
+		return true
+	}
+	return trimmed[0] == '`' || trimmed[0] == '~'
+}
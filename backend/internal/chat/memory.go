@@ -0,0 +1,164 @@
+package chat
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errConversationOwner is returned when a user continues another user's conversation
+var errConversationOwner = errors.New("conversation belongs to another user")
+
+// MemoryBuffer keeps recent conversation turns in process memory. It is the
+// conversation memory of instances without a database: history is per replica
+// and lost on restart. Each conversation keeps at most maxTurns turns besides its
+// summary, and the least recently used conversations are evicted beyond
+// maxConversations or after ttl without messages.
+type MemoryBuffer struct {
+	maxTurns         int
+	maxConversations int
+	ttl              time.Duration
+	conversations    map[string]*list.Element
+	order            *list.List
+	nextID           uint
+	mutex            sync.Mutex
+}
+
+// bufferedConversation is the memory of one conversation
+type bufferedConversation struct {
+	id        string
+	userID    uint
+	summary   string
+	turns     []Turn
+	expiresAt time.Time
+}
+
+// NewMemoryBuffer creates an empty conversation memory
+func NewMemoryBuffer(maxTurns, maxConversations int, ttl time.Duration) *MemoryBuffer {
+	if maxTurns <= 0 {
+		maxTurns = 50
+	}
+	if maxConversations <= 0 {
+		maxConversations = 1000
+	}
+	return &MemoryBuffer{
+		maxTurns:         maxTurns,
+		maxConversations: maxConversations,
+		ttl:              ttl,
+		conversations:    make(map[string]*list.Element),
+		order:            list.New(),
+	}
+}
+
+// Memory returns the conversation's summary and buffered turns, oldest first.
+// Unknown and expired conversations have an empty memory. Instances without a
+// database have no sessions, and buffered conversations do not branch, so
+// sessionID and editOf are ignored.
+func (b *MemoryBuffer) Memory(_ context.Context, conversationID, _ string, userID, _ uint) (*Memory, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	conversation := b.get(conversationID)
+	if conversation == nil {
+		return &Memory{}, nil
+	}
+	if conversation.userID != 0 && conversation.userID != userID {
+		return nil, errConversationOwner
+	}
+	return &Memory{
+		Summary: conversation.summary,
+		Turns:   append([]Turn(nil), conversation.turns...),
+	}, nil
+}
+
+// SaveSummary replaces the summary and drops the turns it covers
+func (b *MemoryBuffer) SaveSummary(_ context.Context, conversationID, summary string, through uint) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	conversation := b.get(conversationID)
+	if conversation == nil {
+		return nil
+	}
+	conversation.summary = summary
+	for len(conversation.turns) > 0 && conversation.turns[0].ID <= through {
+		conversation.turns = conversation.turns[1:]
+	}
+	return nil
+}
+
+// Record adds a message to the conversation, dropping the oldest turn when the
+// conversation is full. It matches MessageRecorder for use with PersistenceStage.
+func (b *MemoryBuffer) Record(_ context.Context, conversationID, _, _ string, userID uint, role, content string, interrupted bool) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	conversation := b.get(conversationID)
+	if conversation == nil {
+		conversation = &bufferedConversation{id: conversationID, userID: userID}
+		b.conversations[conversationID] = b.order.PushFront(conversation)
+		for b.order.Len() > b.maxConversations {
+			b.remove(b.order.Back())
+		}
+	} else if conversation.userID != 0 && conversation.userID != userID {
+		return errConversationOwner
+	}
+
+	b.nextID++
+	conversation.turns = append(conversation.turns, Turn{ID: b.nextID, Role: role, Content: content, Interrupted: interrupted})
+	if len(conversation.turns) > b.maxTurns {
+		conversation.turns = conversation.turns[len(conversation.turns)-b.maxTurns:]
+	}
+	conversation.expiresAt = time.Now().Add(b.ttl)
+	return nil
+}
+
+// Complete replaces the content of a buffered turn. It matches MessageCompleter
+// for use with PersistenceStage.
+func (b *MemoryBuffer) Complete(_ context.Context, conversationID string, turnID uint, content string, interrupted bool) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	conversation := b.get(conversationID)
+	if conversation == nil {
+		return nil
+	}
+	for i := range conversation.turns {
+		if conversation.turns[i].ID == turnID {
+			conversation.turns[i].Content = content
+			conversation.turns[i].Interrupted = interrupted
+		}
+	}
+	conversation.expiresAt = time.Now().Add(b.ttl)
+	return nil
+}
+
+// Len returns the number of conversations currently held
+func (b *MemoryBuffer) Len() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.order.Len()
+}
+
+// get returns a live conversation and marks it as recently used. The caller
+// must hold the mutex.
+func (b *MemoryBuffer) get(conversationID string) *bufferedConversation {
+	elem, ok := b.conversations[conversationID]
+	if !ok {
+		return nil
+	}
+	conversation := elem.Value.(*bufferedConversation)
+	if b.ttl > 0 && time.Now().After(conversation.expiresAt) {
+		b.remove(elem)
+		return nil
+	}
+	b.order.MoveToFront(elem)
+	return conversation
+}
+
+func (b *MemoryBuffer) remove(elem *list.Element) {
+	b.order.Remove(elem)
+	delete(b.conversations, elem.Value.(*bufferedConversation).id)
+}
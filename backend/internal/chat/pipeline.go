@@ -0,0 +1,137 @@
+package chat
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Handler processes a run
+type Handler func(ctx context.Context, run *Run) error
+
+// Stage is an interceptor in the chat pipeline. It may inspect or modify the run,
+// short-circuit by returning without calling next, or post-process after next returns.
+type Stage interface {
+	Name() string
+	Handle(ctx context.Context, run *Run, next Handler) error
+}
+
+// StageFunc adapts a function to the Stage interface
+type StageFunc struct {
+	StageName string
+	Fn        func(ctx context.Context, run *Run, next Handler) error
+}
+
+// Name returns the stage name
+func (s StageFunc) Name() string { return s.StageName }
+
+// Handle calls the wrapped function
+func (s StageFunc) Handle(ctx context.Context, run *Run, next Handler) error {
+	return s.Fn(ctx, run, next)
+}
+
+// Default stage ordering. Lower values run first; deployments can register
+// custom stages between these. Persistence runs after the stages that refuse
+// messages, so duplicate, over-quota and blocked messages are not stored as
+// history.
+const (
+	OrderLogging     = 100
+	OrderDedupe      = 200
+	OrderQuota       = 300
+	OrderModeration  = 400
+	OrderPersistence = 420
+	OrderPersonalize = 450
+	OrderRetrieval   = 500
+	OrderPostProcess = 600
+)
+
+var stageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "chat_stage_duration_seconds",
+	Help:    "Time spent in each chat pipeline stage, excluding downstream stages.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"stage"})
+
+type registeredStage struct {
+	order int
+	stage Stage
+}
+
+// Pipeline runs chat runs through an ordered chain of stages ending in a final handler
+type Pipeline struct {
+	stages []registeredStage
+	final  Handler
+	mutex  sync.RWMutex
+}
+
+// NewPipeline creates a pipeline that ends in final (typically response generation)
+func NewPipeline(final Handler) *Pipeline {
+	return &Pipeline{final: final}
+}
+
+// Register adds a stage at the given order. Stages with equal order run in registration order.
+func (p *Pipeline) Register(order int, stage Stage) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.stages = append(p.stages, registeredStage{order: order, stage: stage})
+	sort.SliceStable(p.stages, func(i, j int) bool {
+		return p.stages[i].order < p.stages[j].order
+	})
+}
+
+// Stages returns the names of the registered stages in execution order
+func (p *Pipeline) Stages() []string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	names := make([]string, len(p.stages))
+	for i, s := range p.stages {
+		names[i] = s.stage.Name()
+	}
+	return names
+}
+
+// Execute runs the run through all stages and the final handler
+func (p *Pipeline) Execute(ctx context.Context, run *Run) error {
+	p.mutex.RLock()
+	stages := make([]registeredStage, len(p.stages))
+	copy(stages, p.stages)
+	p.mutex.RUnlock()
+
+	return p.chain(stages, 0)(ctx, run)
+}
+
+// chain builds the handler for stage i, timing each stage exclusive of downstream work
+func (p *Pipeline) chain(stages []registeredStage, i int) Handler {
+	if i == len(stages) {
+		return func(ctx context.Context, run *Run) error {
+			start := time.Now()
+			err := p.final(ctx, run)
+			if err == nil {
+				err = run.FinishText(ctx)
+			}
+			stageDuration.WithLabelValues("final").Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+
+	stage := stages[i].stage
+	return func(ctx context.Context, run *Run) error {
+		var downstream time.Duration
+		next := func(ctx context.Context, run *Run) error {
+			start := time.Now()
+			err := p.chain(stages, i+1)(ctx, run)
+			downstream += time.Since(start)
+			return err
+		}
+
+		start := time.Now()
+		err := stage.Handle(ctx, run, next)
+		stageDuration.WithLabelValues(stage.Name()).Observe((time.Since(start) - downstream).Seconds())
+		return err
+	}
+}
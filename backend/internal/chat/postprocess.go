@@ -0,0 +1,205 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ActivityValidator reports which of the given activity IDs exist
+type ActivityValidator func(ctx context.Context, ids []uint) (map[uint]bool, error)
+
+// CitedActivitiesKey is the run metadata key of the IDs of the activities the
+// answer links to, set by PostProcessStage
+const CitedActivitiesKey = "cited_activities"
+
+var (
+	markdownActivityLink = regexp.MustCompile(`\[([^\]]*)\]\(activity://(\d+)\)`)
+	bareActivityLink     = regexp.MustCompile(`activity://(\d+)`)
+	unsafeBlock          = regexp.MustCompile(`(?is)<(script|style|iframe|object)\b.*?</(script|style|iframe|object)\s*>`)
+	htmlTag              = regexp.MustCompile(`(?s)</?[a-zA-Z][^>]*>`)
+	unsafeMarkdownLink   = regexp.MustCompile(`(?i)\[([^\]]*)\]\(\s*(?:javascript|vbscript|data):[^()]*(?:\([^()]*\)[^()]*)*\)`)
+	unsafeScheme         = regexp.MustCompile(`(?i)\b(?:javascript|vbscript):`)
+	openUnsafeBlock      = regexp.MustCompile(`(?i)<(script|style|iframe|object)\b`)
+)
+
+// PostProcessStage enforces MarkdownContract: it rewrites activity:// references into
+// frontend deep links, strips HTML, scripts, images and links with disallowed schemes,
+// drops citations of activities that do not exist and annotates code blocks and tables.
+// The activities the answer links to are left in the run's metadata under
+// CitedActivitiesKey.
+func PostProcessStage(frontendURL string, validate ActivityValidator) Stage {
+	return StageFunc{
+		StageName: "postprocess",
+		Fn: func(ctx context.Context, run *Run, next Handler) error {
+			annotator := NewMarkdownAnnotator()
+			links := NewLinkRewriter(frontendURL, validate)
+			run.AddTextFilter(links)
+			run.AddTextFilter(annotator)
+
+			err := next(ctx, run)
+			run.Metadata[CitedActivitiesKey] = links.Cited()
+			if err != nil {
+				return err
+			}
+
+			if blocks := annotator.Annotations(); len(blocks) > 0 {
+				return run.Emit(AnnotationsEvent{Type: "CONTENT_ANNOTATIONS", Blocks: blocks})
+			}
+			return nil
+		},
+	}
+}
+
+// LinkRewriter is a streaming TextFilter. It only processes text up to the last
+// whitespace boundary so that links and tags split across chunks are handled whole.
+type LinkRewriter struct {
+	frontendURL string
+	validate    ActivityValidator
+	pending     string
+	// cited are the activities linked to so far, in order of their first link
+	cited []uint
+}
+
+// NewLinkRewriter creates a link rewriting filter. A nil validate keeps all
+// citations.
+func NewLinkRewriter(frontendURL string, validate ActivityValidator) *LinkRewriter {
+	return &LinkRewriter{
+		frontendURL: strings.TrimRight(frontendURL, "/"),
+		validate:    validate,
+	}
+}
+
+// Filter processes complete segments of the buffered text
+func (l *LinkRewriter) Filter(ctx context.Context, chunk string) (string, error) {
+	l.pending += chunk
+
+	cut := safeCut(l.pending)
+	if cut == 0 {
+		return "", nil
+	}
+
+	ready := l.pending[:cut]
+	l.pending = l.pending[cut:]
+	return l.process(ctx, ready), nil
+}
+
+// Flush processes whatever text is still held back
+func (l *LinkRewriter) Flush(ctx context.Context) (string, error) {
+	ready := l.pending
+	l.pending = ""
+
+	// Drop an unterminated script/style block rather than leaking it
+	if loc := openUnsafeBlock.FindStringIndex(ready); loc != nil {
+		ready = ready[:loc[0]]
+	}
+	return l.process(ctx, ready), nil
+}
+
+// safeCut returns how much of text can be processed without splitting a word,
+// an HTML tag or an unsafe block
+func safeCut(text string) int {
+	cut := strings.LastIndexFunc(text, unicode.IsSpace) + 1
+
+	if loc := lastUnclosedBlock(text); loc >= 0 && loc < cut {
+		cut = loc
+	}
+	if open := strings.LastIndex(text[:cut], "<"); open >= 0 && !strings.Contains(text[open:cut], ">") {
+		cut = open
+	}
+	return cut
+}
+
+// lastUnclosedBlock returns the start of an unsafe block whose closing tag has not arrived yet
+func lastUnclosedBlock(text string) int {
+	matches := openUnsafeBlock.FindAllStringSubmatchIndex(text, -1)
+	for i := len(matches) - 1; i >= 0; i-- {
+		start := matches[i][0]
+		name := strings.ToLower(text[matches[i][2]:matches[i][3]])
+		if !strings.Contains(strings.ToLower(text[start:]), "</"+name) {
+			return start
+		}
+	}
+	return -1
+}
+
+// process sanitizes a complete segment and rewrites its activity references
+func (l *LinkRewriter) process(ctx context.Context, text string) string {
+	text = unsafeBlock.ReplaceAllString(text, "")
+	text = htmlTag.ReplaceAllString(text, "")
+	text = unsafeMarkdownLink.ReplaceAllString(text, "$1")
+	text = sanitizeLinks(text)
+	text = unsafeScheme.ReplaceAllString(text, "")
+
+	valid := l.validIDs(ctx, text)
+
+	text = markdownActivityLink.ReplaceAllStringFunc(text, func(match string) string {
+		parts := markdownActivityLink.FindStringSubmatch(match)
+		id, _ := strconv.ParseUint(parts[2], 10, 64)
+		if !valid[uint(id)] {
+			return parts[1]
+		}
+		return fmt.Sprintf("[%s](%s)", parts[1], l.activityURL(uint(id)))
+	})
+
+	return bareActivityLink.ReplaceAllStringFunc(text, func(match string) string {
+		id, _ := strconv.ParseUint(bareActivityLink.FindStringSubmatch(match)[1], 10, 64)
+		if !valid[uint(id)] {
+			return ""
+		}
+		return l.activityURL(uint(id))
+	})
+}
+
+// validIDs checks every activity referenced in text against the validator.
+// Without a validator, e.g. when there is no database, every reference is kept.
+func (l *LinkRewriter) validIDs(ctx context.Context, text string) map[uint]bool {
+	matches := bareActivityLink.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(matches))
+	for _, m := range matches {
+		if id, err := strconv.ParseUint(m[1], 10, 64); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+	if l.validate == nil {
+		valid := make(map[uint]bool, len(ids))
+		for _, id := range ids {
+			valid[id] = true
+		}
+		return valid
+	}
+
+	valid, err := l.validate(ctx, ids)
+	if err != nil {
+		log.Printf("[POSTPROCESS] Failed to validate cited activities %v: %v", ids, err)
+		return nil
+	}
+	for _, id := range ids {
+		if !valid[id] {
+			log.Printf("[POSTPROCESS] Dropping citation of unknown activity %d", id)
+		}
+	}
+	return valid
+}
+
+// activityURL returns the frontend deep link for an activity, which is cited from then on
+func (l *LinkRewriter) activityURL(id uint) string {
+	if !slices.Contains(l.cited, id) {
+		l.cited = append(l.cited, id)
+	}
+	return fmt.Sprintf("%s/activities/%d", l.frontendURL, id)
+}
+
+// Cited returns the IDs of the activities linked to in the text processed so far
+func (l *LinkRewriter) Cited() []uint {
+	return slices.Clone(l.cited)
+}
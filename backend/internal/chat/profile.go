@@ -0,0 +1,72 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// Preferences the chat can ask about and save with the save_preference tool
+const (
+	ProfileDifficulty = "difficulty_level"
+	ProfileActivities = "preferred_activities"
+)
+
+// profileQuestions describe each preference to the model
+var profileQuestions = map[string]string{
+	ProfileDifficulty: "which trail difficulty they prefer (easy, moderate or hard)",
+	ProfileActivities: "which kinds of activities they enjoy (e.g. hiking, cycling, climbing)",
+}
+
+// ProfileQuestion tells the model which preference to ask about in this answer, and
+// which one it asked about in its previous answer (the user may be replying to it)
+type ProfileQuestion struct {
+	Ask     string
+	Pending string
+}
+
+// ProfileQuestioner returns the profile question for a user's run. It applies the
+// frequency cap, so Ask is usually empty.
+type ProfileQuestioner func(ctx context.Context, userID uint) (ProfileQuestion, error)
+
+// ProfilingStage lets the model build up signed-in users' preferences over time by
+// occasionally asking one question. It must run after PersonalizationStage, which
+// creates the user context. Lookup failures are logged and the run continues.
+func ProfilingStage(next ProfileQuestioner) Stage {
+	return StageFunc{
+		StageName: "profiling",
+		Fn: func(ctx context.Context, run *Run, handler Handler) error {
+			// Looking up the question records that it was asked
+			if run.UserID == 0 || run.DryRun {
+				return handler(ctx, run)
+			}
+
+			question, err := next(ctx, run.UserID)
+			if err != nil {
+				log.Printf("[CHAT] Run %s: profile question lookup failed: %v", run.ID, err)
+			} else if question.Ask != "" || question.Pending != "" {
+				if run.UserContext == nil {
+					run.UserContext = &UserContext{}
+				}
+				run.UserContext.Profile = &question
+			}
+			return handler(ctx, run)
+		},
+	}
+}
+
+// ProfilePrompt instructs the model about the profile question, or returns "" when there is none
+func ProfilePrompt(q *ProfileQuestion) string {
+	if q == nil {
+		return ""
+	}
+
+	prompt := ""
+	if description, ok := profileQuestions[q.Pending]; ok {
+		prompt += fmt.Sprintf("In your previous answer you asked the user %s. If this message answers that, save it with the save_preference tool. ", description)
+	}
+	if description, ok := profileQuestions[q.Ask]; ok {
+		prompt += fmt.Sprintf("We don't know %s. After answering, you may ask them about that in one short, optional question. Ask nothing else about their preferences.", description)
+	}
+	return prompt
+}
@@ -0,0 +1,87 @@
+package chat
+
+import (
+	"fmt"
+	"strings"
+
+	"community-chatbot/internal/models"
+)
+
+// SystemPrompt sets the assistant's role for every conversation
+const SystemPrompt = `You are a friendly local guide for an outdoor community. You help people discover hiking trails, cycling routes, restaurants and other local activities.
+Keep answers concise and practical. When you mention an activity from the community database, link it as [Name](activity://<id>).
+When tools are available, use them to look up activities, routes and images instead of guessing.
+When review highlights are provided you may quote them, attributed to reviewers rather than stated as fact.
+When an activity has ratings you may cite them, e.g. "rated 4.5 by 23 users".
+If you do not know something, say so instead of guessing.`
+
+// ContextPrompt describes the user's context for the model, or returns "" when
+// there is none. Context sent by clients must have been Sanitized.
+func ContextPrompt(uc *UserContext) string {
+	if uc == nil {
+		return ""
+	}
+
+	var parts []string
+	if uc.Location != nil {
+		parts = append(parts, fmt.Sprintf("The user is near latitude %.4f, longitude %.4f.", uc.Location.Lat, uc.Location.Lng))
+	}
+	if uc.SearchRadiusKM > 0 {
+		parts = append(parts, fmt.Sprintf("They are willing to travel up to %d km.", uc.SearchRadiusKM))
+	}
+	if len(uc.PreferredActivities) > 0 {
+		parts = append(parts, fmt.Sprintf("They enjoy: %s.", strings.Join(uc.PreferredActivities, ", ")))
+	}
+	if uc.DifficultyLevel != "" {
+		parts = append(parts, fmt.Sprintf("Preferred difficulty: %s.", uc.DifficultyLevel))
+	}
+	if uc.TransportMode == models.TransportPublic {
+		parts = append(parts, "They travel by public transport, so favor activities reachable by bus or train.")
+	}
+	if len(uc.FrequentAreas) > 0 {
+		areas := make([]string, len(uc.FrequentAreas))
+		for i, area := range uc.FrequentAreas {
+			areas[i] = fmt.Sprintf("%.2f, %.2f", area.Lat, area.Lng)
+		}
+		parts = append(parts, fmt.Sprintf("They often search around: %s; favor activities near these areas.", strings.Join(areas, "; ")))
+	}
+	if profile := ProfilePrompt(uc.Profile); profile != "" {
+		parts = append(parts, profile)
+	}
+	return strings.Join(parts, " ")
+}
+
+// InstructionsPrompt presents the run's operator rules to the model, or returns "" when there are none
+func InstructionsPrompt(instructions []string) string {
+	return strings.Join(instructions, "\n")
+}
+
+// NotesPrompt presents the run's community data to the model, or returns "" when there is none
+func NotesPrompt(notes []string) string {
+	if len(notes) == 0 {
+		return ""
+	}
+	return "Community data:\n- " + strings.Join(notes, "\n- ")
+}
+
+// ContinuePrompt asks the model to finish the interrupted answer given before it
+const ContinuePrompt = "Your previous answer was interrupted. Continue it exactly where it stopped, without repeating anything already said."
+
+// interruptedNote follows the content of interrupted answers in the history
+const interruptedNote = "\n\n[This answer was interrupted before it was finished.]"
+
+// TurnContent returns the content of a history turn as presented to the model
+func TurnContent(turn Turn) string {
+	if turn.Interrupted {
+		return turn.Content + interruptedNote
+	}
+	return turn.Content
+}
+
+// SummaryPrompt presents the summary of earlier turns to the model, or returns "" when there is none
+func SummaryPrompt(summary string) string {
+	if summary == "" {
+		return ""
+	}
+	return "Summary of the earlier conversation:\n" + summary
+}
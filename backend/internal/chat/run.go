@@ -0,0 +1,184 @@
+package chat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/models"
+)
+
+// UserContext is optional client-supplied context used to personalize answers
+type UserContext struct {
+	Location            *models.Location `json:"location,omitempty"`
+	SearchRadiusKM      int              `json:"search_radius_km,omitempty"`
+	PreferredActivities []string         `json:"preferred_activities,omitempty"`
+	DifficultyLevel     string           `json:"difficulty_level,omitempty"`
+	TransportMode       string           `json:"transport_mode,omitempty"`
+	// Timezone is the user's IANA time zone, e.g. Europe/Berlin
+	Timezone string `json:"timezone,omitempty"`
+	// Language is the user's preferred answer language, see models.Languages
+	Language string `json:"language,omitempty"`
+	// FrequentAreas are the user's most searched areas (opt-in location history)
+	FrequentAreas []models.Location `json:"-"`
+	// Profile is set by ProfilingStage for signed-in users with missing preferences
+	Profile *ProfileQuestion `json:"-"`
+}
+
+// TextFilter transforms answer text as it streams. Filters may hold back text
+// (e.g. a partially received link) and must return it from Flush.
+type TextFilter interface {
+	Filter(ctx context.Context, chunk string) (string, error)
+	Flush(ctx context.Context) (string, error)
+}
+
+// Run carries the state of a single chat run through the pipeline
+type Run struct {
+	ID             string
+	RequestID      string // X-Request-ID of the HTTP request that started the run
+	ClientIP       string
+	Fingerprint    string // identifies anonymous clients, e.g. for guest quotas
+	ConversationID string
+	UserID         uint   // 0 for anonymous users
+	SessionID      string // session of anonymous users who have one
+	Message        string
+	Response       string
+	StartedAt      time.Time
+	UserContext    *UserContext
+	// Language is the answer language the client asked for, and AcceptLanguage
+	// its Accept-Language header; see AnswerLanguage
+	Language       string
+	AcceptLanguage string
+	// Continue makes the run finish the conversation's interrupted answer instead
+	// of answering Message; Continued is that answer, set by CompressionStage
+	Continue  bool
+	Continued *Turn
+	// EditOf is the earlier user message the run answers an edit of, 0 for new
+	// messages. The edit is answered with the conversation up to that message
+	// and stored with its answer as the message's sibling.
+	EditOf uint
+	// Instructions are operator rules (e.g. guardrails) the model must follow
+	Instructions []string
+	// Notes is community data (e.g. review highlights) gathered for the model to draw on
+	Notes []string
+	// Summary and History are the earlier conversation, set by CompressionStage
+	Summary string
+	History []Turn
+	// PromptTokens and CompletionTokens sum the token usage the model reported
+	// for the run's completions; both are 0 when the model was not called
+	PromptTokens     int
+	CompletionTokens int
+
+	// DryRun runs go through the stages without side effects: nothing is stored,
+	// counted or summarized, and the final handler does not call the model
+	DryRun bool
+
+	// Metadata lets stages pass values to later stages
+	Metadata map[string]interface{}
+
+	// emit writes an event to the client stream
+	emit func(event interface{}) error
+	// emitText writes an answer text chunk to the client stream
+	emitText func(content string, final bool) error
+	filters  []TextFilter
+	response strings.Builder
+	// pending is the last grapheme cluster sent, held back until the next chunk
+	pending string
+}
+
+// NewRun creates a run for a user message
+func NewRun(message, clientIP string) *Run {
+	return &Run{
+		ID:        fmt.Sprintf("run-%d", time.Now().UnixNano()),
+		ClientIP:  clientIP,
+		Message:   message,
+		StartedAt: time.Now(),
+		Metadata:  make(map[string]interface{}),
+	}
+}
+
+// AddInstruction adds a rule for the model to follow when answering
+func (r *Run) AddInstruction(instruction string) {
+	r.Instructions = append(r.Instructions, instruction)
+}
+
+// AddNote adds community data for the model to draw on when answering
+func (r *Run) AddNote(note string) {
+	r.Notes = append(r.Notes, note)
+}
+
+// AddUsage adds the token usage of a completion to the run
+func (r *Run) AddUsage(promptTokens, completionTokens int) {
+	r.PromptTokens += promptTokens
+	r.CompletionTokens += completionTokens
+}
+
+// SetEmitter sets the function used to deliver events to the client
+func (r *Run) SetEmitter(emit func(event interface{}) error) {
+	r.emit = emit
+}
+
+// Emit sends an event to the client. Runs without an emitter (e.g. dry runs) discard events.
+func (r *Run) Emit(event interface{}) error {
+	if r.emit == nil {
+		return nil
+	}
+	return r.emit(event)
+}
+
+// SetTextEmitter sets the function used to deliver answer text chunks to the client
+func (r *Run) SetTextEmitter(emitText func(content string, final bool) error) {
+	r.emitText = emitText
+}
+
+// AddTextFilter appends a filter applied to all answer text emitted after this call
+func (r *Run) AddTextFilter(filter TextFilter) {
+	r.filters = append(r.filters, filter)
+}
+
+// EmitText passes an answer chunk through the text filters and sends the result
+func (r *Run) EmitText(ctx context.Context, chunk string) error {
+	out := chunk
+	for _, filter := range r.filters {
+		var err error
+		if out, err = filter.Filter(ctx, out); err != nil {
+			return err
+		}
+	}
+	return r.sendText(out, false)
+}
+
+// FinishText flushes text held back by filters and sends the final chunk
+func (r *Run) FinishText(ctx context.Context) error {
+	out := ""
+	for _, filter := range r.filters {
+		filtered, err := filter.Filter(ctx, out)
+		if err != nil {
+			return err
+		}
+		flushed, err := filter.Flush(ctx)
+		if err != nil {
+			return err
+		}
+		out = filtered + flushed
+	}
+	return r.sendText(out, true)
+}
+
+// sendText records the text in the response and delivers it to the client.
+// Model tokens may end inside a grapheme cluster, so each chunk but the final
+// one holds back its last cluster and frames never split an emoji or letter.
+func (r *Run) sendText(content string, final bool) error {
+	content = r.pending + content
+	r.pending = ""
+	if !final {
+		content, r.pending = splitLastGrapheme(content)
+	}
+	r.response.WriteString(content)
+	r.Response = r.response.String()
+	if r.emitText == nil || (content == "" && !final) {
+		return nil
+	}
+	return r.emitText(content, final)
+}
@@ -0,0 +1,202 @@
+package chat
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"community-chatbot/internal/models"
+)
+
+// LoggingStage logs the start and outcome of each run
+func LoggingStage() Stage {
+	return StageFunc{
+		StageName: "logging",
+		Fn: func(ctx context.Context, run *Run, next Handler) error {
+			log.Printf("[RUN_START] %s | Request: %s | Client: %s | Message: %s", run.ID, run.RequestID, run.ClientIP, run.Message)
+
+			err := next(ctx, run)
+
+			log.Printf("[RUN_END] %s | Request: %s | Client: %s | Duration: %v | Response Size: %d | Error: %v",
+				run.ID, run.RequestID, run.ClientIP, time.Since(run.StartedAt), len(run.Response), err)
+			return err
+		},
+	}
+}
+
+// NoteLookup returns community data relevant to a message
+type NoteLookup func(ctx context.Context, message string) ([]string, error)
+
+// ReviewHighlightsStage adds review highlights of activities mentioned in the
+// message so answers can quote what reviewers say. Lookup failures are logged
+// and the run continues without highlights.
+func ReviewHighlightsStage(lookup NoteLookup) Stage {
+	return StageFunc{
+		StageName: "review_highlights",
+		Fn: func(ctx context.Context, run *Run, next Handler) error {
+			notes, err := lookup(ctx, run.Message)
+			if err != nil {
+				log.Printf("[CHAT] Run %s: review highlight lookup failed: %v", run.ID, err)
+			}
+			for _, note := range notes {
+				run.AddNote(note)
+			}
+			return next(ctx, run)
+		},
+	}
+}
+
+// MessageRecorder stores a message in a conversation. sessionID is the session
+// of anonymous users who have one. interrupted marks an answer that was cut off
+// before it was finished.
+type MessageRecorder func(ctx context.Context, conversationID, clientIP, sessionID string, userID uint, role, content string, interrupted bool) error
+
+// MessageCompleter replaces the content of an interrupted answer that was continued
+type MessageCompleter func(ctx context.Context, conversationID string, messageID uint, content string, interrupted bool) error
+
+// EditRecorder stores the edit of an earlier user message with its answer as
+// the message's sibling, and makes their branch the conversation's active one
+type EditRecorder func(ctx context.Context, conversationID, sessionID string, userID, editOf uint, message, response string, interrupted bool) error
+
+// PersistenceStage records the user message and the assistant response of every
+// run that has a conversation ID. Runs continuing an interrupted answer append
+// their response to it instead. Edits are only stored once answered, so a failed
+// edit leaves the conversation as it was; without an edit recorder they are
+// recorded like new messages. Storage failures are logged and never fail the run.
+func PersistenceStage(record MessageRecorder, complete MessageCompleter, edit EditRecorder) Stage {
+	return StageFunc{
+		StageName: "persistence",
+		Fn: func(ctx context.Context, run *Run, next Handler) error {
+			if run.ConversationID == "" || run.DryRun {
+				return next(ctx, run)
+			}
+
+			editing := run.EditOf != 0 && edit != nil
+			if !run.Continue && !editing {
+				if err := record(ctx, run.ConversationID, run.ClientIP, run.SessionID, run.UserID, models.MessageRoleUser, run.Message, false); err != nil {
+					log.Printf("[CHAT] Run %s: failed to store user message: %v", run.ID, err)
+				}
+			}
+
+			err := next(ctx, run)
+			if run.Response == "" {
+				return err
+			}
+
+			// Partial responses from failed or disconnected runs are kept, marked as
+			// interrupted; they are what the user saw and can be continued later.
+			// The run's context may be cancelled by a disconnect, so it is not used for storing.
+			interrupted := err != nil || ctx.Err() != nil
+			if interrupted {
+				log.Printf("[CHAT] Run %s: storing interrupted response (%d bytes)", run.ID, len(run.Response))
+			}
+			storeCtx := context.WithoutCancel(ctx)
+			var storeErr error
+			switch {
+			case run.Continued != nil:
+				storeErr = complete(storeCtx, run.ConversationID, run.Continued.ID, run.Continued.Content+run.Response, interrupted)
+			case editing:
+				storeErr = edit(storeCtx, run.ConversationID, run.SessionID, run.UserID, run.EditOf, run.Message, run.Response, interrupted)
+			default:
+				storeErr = record(storeCtx, run.ConversationID, run.ClientIP, run.SessionID, run.UserID, models.MessageRoleAssistant, run.Response, interrupted)
+			}
+			if storeErr != nil {
+				log.Printf("[CHAT] Run %s: failed to store response: %v", run.ID, storeErr)
+			}
+			return err
+		},
+	}
+}
+
+// UserContextLoader returns the stored context (location, interests) of a user
+type UserContextLoader func(ctx context.Context, userID uint) (*UserContext, error)
+
+// PersonalizationStage fills in the user context of authenticated runs from the
+// user's saved preferences. Context sent with the request takes precedence, but
+// clients that send context without a language still get the saved one.
+func PersonalizationStage(load UserContextLoader) Stage {
+	return StageFunc{
+		StageName: "personalization",
+		Fn: func(ctx context.Context, run *Run, next Handler) error {
+			if run.UserID != 0 && (run.UserContext == nil || run.UserContext.Language == "") {
+				uc, err := load(ctx, run.UserID)
+				switch {
+				case err != nil:
+					log.Printf("[CHAT] Run %s: failed to load user context: %v", run.ID, err)
+				case run.UserContext == nil:
+					run.UserContext = uc
+				case uc != nil:
+					merged := *run.UserContext
+					merged.Language = uc.Language
+					run.UserContext = &merged
+				}
+			}
+			return next(ctx, run)
+		},
+	}
+}
+
+// LocationRecorder records a location a user searched from, subject to their consent
+type LocationRecorder func(ctx context.Context, userID uint, loc models.Location) (bool, error)
+
+// LocationHistoryStage records the location sent with authenticated runs. It must
+// run before PersonalizationStage so saved home locations are not recorded as searches.
+func LocationHistoryStage(record LocationRecorder) Stage {
+	return StageFunc{
+		StageName: "location_history",
+		Fn: func(ctx context.Context, run *Run, next Handler) error {
+			if run.UserID != 0 && run.UserContext != nil && run.UserContext.Location != nil && !run.DryRun {
+				if _, err := record(ctx, run.UserID, *run.UserContext.Location); err != nil {
+					log.Printf("[CHAT] Run %s: failed to record location history: %v", run.ID, err)
+				}
+			}
+			return next(ctx, run)
+		},
+	}
+}
+
+// TransitLookup returns public transit directions from a location to activities
+// mentioned in a message, with times in timezone
+type TransitLookup func(ctx context.Context, message string, from models.Location, timezone string) ([]string, error)
+
+// transitWords mark a question about getting somewhere by public transport
+var transitWords = []string{"bus", "buses", "train", "trains", "tram", "metro", "subway", "transit", "ferry"}
+
+// TransitStage adds transit directions to activities mentioned in the message when
+// the run has a location and the user asks about public transport or prefers it.
+// Lookup failures are logged and the run continues without directions.
+func TransitStage(lookup TransitLookup) Stage {
+	return StageFunc{
+		StageName: "transit",
+		Fn: func(ctx context.Context, run *Run, next Handler) error {
+			uc := run.UserContext
+			if uc != nil && uc.Location != nil && (uc.TransportMode == models.TransportPublic || asksAboutTransit(run.Message)) {
+				notes, err := lookup(ctx, run.Message, *uc.Location, uc.Timezone)
+				if err != nil {
+					log.Printf("[CHAT] Run %s: transit lookup failed: %v", run.ID, err)
+				}
+				for _, note := range notes {
+					run.AddNote(note)
+				}
+			}
+			return next(ctx, run)
+		},
+	}
+}
+
+// asksAboutTransit reports whether message mentions public transport
+func asksAboutTransit(message string) bool {
+	lower := strings.ToLower(message)
+	if strings.Contains(lower, "public transport") {
+		return true
+	}
+	for _, word := range strings.FieldsFunc(lower, func(r rune) bool { return !unicode.IsLetter(r) }) {
+		if slices.Contains(transitWords, word) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"community-chatbot/internal/apperr"
+)
+
+var (
+	// ErrUnknownTool is returned when the model calls a tool that is not registered
+	ErrUnknownTool = errors.New("unknown tool")
+	// ErrInvalidToolArguments is wrapped by tools whose arguments are malformed, so the
+	// model is told what was wrong instead of a generic failure
+	ErrInvalidToolArguments = errors.New("invalid tool arguments")
+	// ErrToolSignInRequired is returned by tools that only work for signed-in users
+	ErrToolSignInRequired = apperr.New(apperr.Unauthorized, "the user must sign in to use this tool")
+)
+
+// ToolFunc executes a tool call with the model's JSON arguments. The result is
+// encoded as JSON and returned to the model.
+type ToolFunc func(ctx context.Context, run *Run, args json.RawMessage) (interface{}, error)
+
+// Tool is a lookup the model can call while answering
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON Schema of the arguments
+	Parameters json.RawMessage
+	Call       ToolFunc
+}
+
+// ToolRegistry holds the tools offered to the model
+type ToolRegistry struct {
+	tools []Tool
+	mutex sync.RWMutex
+}
+
+// NewToolRegistry creates an empty tool registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{}
+}
+
+// Register adds a tool, replacing a registered tool of the same name
+func (r *ToolRegistry) Register(tool Tool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, existing := range r.tools {
+		if existing.Name == tool.Name {
+			r.tools[i] = tool
+			return
+		}
+	}
+	r.tools = append(r.tools, tool)
+}
+
+// Tools returns the registered tools in registration order
+func (r *ToolRegistry) Tools() []Tool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	tools := make([]Tool, len(r.tools))
+	copy(tools, r.tools)
+	return tools
+}
+
+// Call runs the named tool
+func (r *ToolRegistry) Call(ctx context.Context, run *Run, name string, args json.RawMessage) (interface{}, error) {
+	r.mutex.RLock()
+	var call ToolFunc
+	for _, tool := range r.tools {
+		if tool.Name == name {
+			call = tool.Call
+			break
+		}
+	}
+	r.mutex.RUnlock()
+
+	if call == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTool, name)
+	}
+	return call(ctx, run, args)
+}
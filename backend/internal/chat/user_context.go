@@ -0,0 +1,78 @@
+package chat
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/models"
+)
+
+// Limits of client-supplied context, which ends up in the system prompt
+const (
+	maxContextRadiusKM   = 500
+	maxContextActivities = 10
+	maxContextNameLength = 40
+	maxContextTimezone   = 64
+)
+
+// contextActivityName matches preferred activities safe to put in a prompt:
+// short names of letters, digits, spaces and hyphens
+var contextActivityName = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N} _-]*$`)
+
+// contextDifficulties and contextTransportModes are the values clients may send
+var (
+	contextDifficulties   = []string{models.DifficultyEasy, models.DifficultyModerate, models.DifficultyHard}
+	contextTransportModes = []string{models.TransportCar, models.TransportBike, models.TransportWalk,
+		models.TransportPublic, models.TransportTransit}
+)
+
+// Sanitized returns a copy of client-supplied context that is safe to put in
+// the system prompt: enums outside their values, out of range coordinates,
+// unknown time zones and languages, and preferred activities that are not
+// short names are dropped; the search radius is clamped. It returns nil for
+// nil context.
+func (uc *UserContext) Sanitized() *UserContext {
+	if uc == nil {
+		return nil
+	}
+
+	clean := &UserContext{
+		SearchRadiusKM: max(0, min(uc.SearchRadiusKM, maxContextRadiusKM)),
+	}
+	if loc := uc.Location; loc != nil && validCoordinate(loc.Lat, 90) && validCoordinate(loc.Lng, 180) {
+		clean.Location = &models.Location{Lat: loc.Lat, Lng: loc.Lng}
+	}
+	for _, name := range uc.PreferredActivities {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(clean.PreferredActivities) == maxContextActivities {
+			break
+		}
+		if len(name) <= maxContextNameLength && contextActivityName.MatchString(name) &&
+			!slices.Contains(clean.PreferredActivities, name) {
+			clean.PreferredActivities = append(clean.PreferredActivities, name)
+		}
+	}
+	if difficulty := strings.ToLower(uc.DifficultyLevel); slices.Contains(contextDifficulties, difficulty) {
+		clean.DifficultyLevel = difficulty
+	}
+	if mode := strings.ToLower(uc.TransportMode); slices.Contains(contextTransportModes, mode) {
+		clean.TransportMode = mode
+	}
+	if uc.Timezone != "" && len(uc.Timezone) <= maxContextTimezone {
+		if _, err := time.LoadLocation(uc.Timezone); err == nil {
+			clean.Timezone = uc.Timezone
+		}
+	}
+	if language := strings.ToLower(uc.Language); models.IsLanguage(language) {
+		clean.Language = language
+	}
+	return clean
+}
+
+// validCoordinate reports whether a latitude or longitude is a number within ±limit
+func validCoordinate(value, limit float64) bool {
+	return !math.IsNaN(value) && value >= -limit && value <= limit
+}
@@ -0,0 +1,88 @@
+package climate
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// RainyDayMM is the daily precipitation from which a day counts as rainy
+const RainyDayMM = 1.0
+
+// Point is a coordinate pair
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// Day is the observed weather of one day
+type Day struct {
+	Date            time.Time
+	TempMaxC        float64
+	TempMinC        float64
+	PrecipitationMM float64
+}
+
+// Month holds the normals of a calendar month: averages over the years observed
+type Month struct {
+	Month           int     `json:"month"` // 1 for January
+	AvgHighC        float64 `json:"avg_high_c"`
+	AvgLowC         float64 `json:"avg_low_c"`
+	PrecipitationMM float64 `json:"precipitation_mm"` // average monthly total
+	RainyDays       float64 `json:"rainy_days"`       // average days with at least RainyDayMM
+	DryDayShare     float64 `json:"dry_day_share"`    // share of days below RainyDayMM, 0-1
+	Years           int     `json:"years"`            // years the averages cover
+}
+
+// History downloads observed daily weather
+type History interface {
+	Daily(ctx context.Context, at Point, from, to time.Time) ([]Day, error)
+}
+
+// Summarize computes the monthly normals of daily observations. Months without
+// observations are left out.
+func Summarize(days []Day) []Month {
+	type totals struct {
+		days, rainy       int
+		high, low, precip float64
+		years             map[int]bool
+	}
+	var months [12]totals
+	for _, day := range days {
+		t := &months[day.Date.Month()-1]
+		if t.years == nil {
+			t.years = make(map[int]bool)
+		}
+		t.days++
+		t.high += day.TempMaxC
+		t.low += day.TempMinC
+		t.precip += day.PrecipitationMM
+		if day.PrecipitationMM >= RainyDayMM {
+			t.rainy++
+		}
+		t.years[day.Date.Year()] = true
+	}
+
+	var normals []Month
+	for i, t := range months {
+		if t.days == 0 {
+			continue
+		}
+		years := float64(len(t.years))
+		normals = append(normals, Month{
+			Month:           i + 1,
+			AvgHighC:        round1(t.high / float64(t.days)),
+			AvgLowC:         round1(t.low / float64(t.days)),
+			PrecipitationMM: round1(t.precip / years),
+			RainyDays:       round1(float64(t.rainy) / years),
+			DryDayShare:     math.Round(float64(t.days-t.rainy)/float64(t.days)*100) / 100,
+			Years:           len(t.years),
+		})
+	}
+	return normals
+}
+
+// round1 rounds to one decimal
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
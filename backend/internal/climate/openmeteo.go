@@ -0,0 +1,90 @@
+package climate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OpenMeteoClient downloads observed daily weather from the Open-Meteo
+// historical weather API, which needs no key
+type OpenMeteoClient struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+// NewOpenMeteoClient creates a client for the archive endpoint at baseURL,
+// e.g. https://archive-api.open-meteo.com/v1/archive
+func NewOpenMeteoClient(baseURL string) *OpenMeteoClient {
+	return &OpenMeteoClient{
+		endpoint: strings.TrimRight(baseURL, "/"),
+		// Years of daily data take a while to assemble upstream
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+type openMeteoResponse struct {
+	Daily struct {
+		Time             []string   `json:"time"`
+		Temperature2mMax []*float64 `json:"temperature_2m_max"`
+		Temperature2mMin []*float64 `json:"temperature_2m_min"`
+		PrecipitationSum []*float64 `json:"precipitation_sum"`
+	} `json:"daily"`
+}
+
+// Daily returns the weather of each day from from to to. Days with missing
+// values are skipped.
+func (c *OpenMeteoClient) Daily(ctx context.Context, at Point, from, to time.Time) ([]Day, error) {
+	query := url.Values{}
+	query.Set("latitude", fmt.Sprintf("%.4f", at.Lat))
+	query.Set("longitude", fmt.Sprintf("%.4f", at.Lng))
+	query.Set("start_date", from.Format(time.DateOnly))
+	query.Set("end_date", to.Format(time.DateOnly))
+	query.Set("daily", "temperature_2m_max,temperature_2m_min,precipitation_sum")
+	query.Set("timezone", "auto")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("open-meteo request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("open-meteo returned %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var body openMeteoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode open-meteo response: %w", err)
+	}
+
+	daily := body.Daily
+	if len(daily.Temperature2mMax) != len(daily.Time) || len(daily.Temperature2mMin) != len(daily.Time) ||
+		len(daily.PrecipitationSum) != len(daily.Time) {
+		return nil, fmt.Errorf("open-meteo returned mismatched daily series")
+	}
+	days := make([]Day, 0, len(daily.Time))
+	for i, date := range daily.Time {
+		high, low, precip := daily.Temperature2mMax[i], daily.Temperature2mMin[i], daily.PrecipitationSum[i]
+		if high == nil || low == nil || precip == nil {
+			continue
+		}
+		day, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			return nil, fmt.Errorf("open-meteo returned invalid date %q: %w", date, err)
+		}
+		days = append(days, Day{Date: day, TempMaxC: *high, TempMinC: *low, PrecipitationMM: *precip})
+	}
+	return days, nil
+}
@@ -3,7 +3,11 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	log "github.com/sirupsen/logrus"
@@ -11,11 +15,35 @@ import (
 
 // Config holds all configuration values for the application
 type Config struct {
-	Database DatabaseConfig
-	Server   ServerConfig
-	OpenAI   OpenAIConfig
-	Storage  StorageConfig
-	CORS     CORSConfig
+	Database   DatabaseConfig
+	Server     ServerConfig
+	Logging    LoggingConfig
+	LLM        LLMConfig
+	OpenAI     OpenAIConfig
+	Storage    StorageConfig
+	CORS       CORSConfig
+	Cache      CacheConfig
+	Similar    SimilarityConfig
+	Semantic   SemanticSearchConfig
+	Content    ContentConfig
+	Scheduler  SchedulerConfig
+	Telemetry  TelemetryConfig
+	Reviews    ReviewsConfig
+	Auth       AuthConfig
+	Transit    TransitConfig
+	Climate    ClimateConfig
+	Weather    WeatherConfig
+	Geocode    GeocodeConfig
+	Streams    StreamConfig
+	RateLimit  RateLimitConfig
+	Federation FederationConfig
+	Chat       ChatConfig
+	Embed      EmbedConfig
+	Session    SessionConfig
+	Moderation ModerationConfig
+	Trust      TrustConfig
+	Residency  ResidencyConfig
+	Mail       MailConfig
 }
 
 // DatabaseConfig contains database connection settings
@@ -27,6 +55,15 @@ type DatabaseConfig struct {
 	Password string
 	Name     string
 	SSLMode  string
+
+	// Connection pool limits
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	// ConnectAttempts is how often connecting is tried at startup, with
+	// exponential backoff capped at RetryMaxBackoff between attempts
+	ConnectAttempts int
+	RetryMaxBackoff time.Duration
 }
 
 // ServerConfig contains server configuration
@@ -34,20 +71,108 @@ type ServerConfig struct {
 	Port        int
 	Environment string
 	LogLevel    string
+	FrontendURL string
+	// PublicURL is where clients reach this API; links handed out are relative without it
+	PublicURL string
+	// Prefork starts a worker process per CPU sharing the port; each works like
+	// a replica, with its own memory, database pool and background jobs
+	Prefork bool
+	// BodyLimit is the largest request body in bytes; it must fit uploads
+	BodyLimit int
+	// ReadTimeout limits reading a request with its body, WriteTimeout writing a
+	// response, chat streams included, and IdleTimeout keep-alive connections
+	// waiting for the next request; 0 disables each
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// Concurrency is the maximum number of open connections per process
+	Concurrency int
+	// StreamKeepalive is how often a comment is written to chat streams while
+	// the answer is generated, so proxies do not drop them as idle
+	StreamKeepalive time.Duration
+	// DebugChat serves a built-in chat page at /debug/chat for checking the
+	// stream without the frontend; development only
+	DebugChat bool
+}
+
+// LoggingConfig contains the default sampling of request logs; admins can
+// change it at runtime
+type LoggingConfig struct {
+	// SampleRate is the share of requests logged on paths without a rule, 0-1
+	SampleRate float64
+	// SampleRules map paths, or path prefixes ending in *, to their sample rate
+	SampleRules map[string]float64
+	// SlowRequest is the duration from which requests are always logged, like
+	// failed requests; 0 disables it
+	SlowRequest time.Duration
+	// SamplingReloadInterval is how often replicas load sampling changed by admins
+	SamplingReloadInterval time.Duration
+}
+
+// LLMConfig selects the language model provider and contains the settings of
+// Azure OpenAI and Ollama; OpenAI's are in OpenAIConfig
+type LLMConfig struct {
+	// Provider is openai, azure or ollama
+	Provider string
+	// Temperature is the sampling temperature; negative leaves the model's default
+	Temperature float64
+
+	// Azure OpenAI addresses models by the names of their deployments
+	AzureEndpoint            string
+	AzureAPIKey              string
+	AzureAPIVersion          string
+	AzureDeployment          string
+	AzureEmbeddingDeployment string
+
+	OllamaURL            string
+	OllamaModel          string
+	OllamaEmbeddingModel string
 }
 
-// OpenAIConfig contains OpenAI API settings
+// OpenAIConfig contains OpenAI API settings. The generation limits and prices
+// apply to whichever provider is used.
 type OpenAIConfig struct {
-	APIKey string
-	Model  string
+	APIKey         string
+	Model          string
+	EmbeddingModel string
+	BaseURL        string
+	// MaxConcurrent caps the chat runs generating at once per replica (0 is
+	// unlimited); up to MaxQueued more wait for a slot for at most QueueTimeout
+	MaxConcurrent int
+	MaxQueued     int
+	QueueTimeout  time.Duration
+	// PromptPrice and CompletionPrice are the model's prices in USD per million
+	// tokens, used to estimate the cost of chat runs
+	PromptPrice     float64
+	CompletionPrice float64
 }
 
-// StorageConfig contains storage settings (Cloudinary)
+// StorageConfig contains storage settings (Cloudinary and the local upload spool)
 type StorageConfig struct {
 	CloudinaryURL string
 	CloudName     string
 	APIKey        string
 	APISecret     string
+	Folder        string
+	// SpoolDir holds accepted images until they are uploaded; share it between replicas
+	SpoolDir           string
+	MaxImageBytes      int
+	UploadInterval     time.Duration
+	UploadMaxAttempts  int
+	UploadRetryBackoff time.Duration
+	UploadRetryMaxWait time.Duration
+	// ImageTypes and RouteTypes are the allowed content types, comma separated;
+	// types are sniffed from the content and must match the file extension
+	ImageTypes        string
+	MaxImageDimension int
+	RouteTypes        string
+	MaxRouteBytes     int
+	MaxRoutePoints    int
+	// Scanner is none, clamav (ScannerAddress is host:port of clamd) or icap
+	// (ScannerAddress is the service URL, e.g. icap://localhost:1344/avscan)
+	Scanner        string
+	ScannerAddress string
+	ScanTimeout    time.Duration
 }
 
 // CORSConfig contains CORS settings
@@ -57,6 +182,303 @@ type CORSConfig struct {
 	AllowHeaders string
 }
 
+// CacheConfig contains settings for caching external lookups
+type CacheConfig struct {
+	RedisURL     string
+	MaxEntries   int
+	GeocodeTTL   time.Duration
+	WeatherTTL   time.Duration
+	RoutingTTL   time.Duration
+	TransitTTL   time.Duration
+	ClimateTTL   time.Duration
+	ChecklistTTL time.Duration
+	NearbyTTL    time.Duration
+	AreaStatsTTL time.Duration
+	HealthTTL    time.Duration
+	NegativeTTL  time.Duration
+}
+
+// SimilarityConfig contains weights for "you might also like" suggestions
+type SimilarityConfig struct {
+	ContentWeight   float64
+	ProximityWeight float64
+	MaxDistanceKM   float64
+}
+
+// SemanticSearchConfig contains search of activities by meaning, which embeds
+// activities with the LLM provider's embedding model and stores them with pgvector
+type SemanticSearchConfig struct {
+	Enabled bool
+	// Dimensions must match the embedding model, e.g. 1536 for text-embedding-3-small
+	Dimensions int
+	// EmbedInterval is how often new and changed activities are embedded
+	EmbedInterval time.Duration
+	// RetrievalLimit is how many activities closest to each chat message are
+	// given to the model and sent as cards, 0 to disable retrieval
+	RetrievalLimit int
+	// MinSimilarity keeps activities unrelated to the message out of the prompt
+	MinSimilarity float64
+}
+
+// ContentConfig contains settings for community content
+type ContentConfig struct {
+	// StaleAfter is how long activity data stays fresh without verification
+	StaleAfter time.Duration
+	// StatsRefreshInterval is how often the public community statistics are recounted
+	StatsRefreshInterval time.Duration
+}
+
+// SchedulerConfig contains settings for scheduled jobs
+type SchedulerConfig struct {
+	// LockBackend is local, postgres or redis; replicas must share a non-local backend
+	LockBackend string
+	LockTTL     time.Duration
+	// Each job runs every interval; zero disables it
+	SessionCleanupInterval    time.Duration
+	ConversationPruneInterval time.Duration
+	// GuestConversationRetention is how long conversations of guests are kept
+	// after their last message; zero keeps them
+	GuestConversationRetention time.Duration
+	PopularityInterval         time.Duration
+	// WeatherRefreshInterval renews the cached forecasts around the
+	// WeatherRefreshActivities most popular activities and the
+	// WeatherRefreshAreas areas most searched from in the location history
+	WeatherRefreshInterval   time.Duration
+	WeatherRefreshActivities int
+	WeatherRefreshAreas      int
+}
+
+// TelemetryConfig contains anonymous usage telemetry settings (disabled by default)
+type TelemetryConfig struct {
+	Enabled    bool
+	Endpoint   string
+	Interval   time.Duration
+	InstanceID string
+}
+
+// ReviewsConfig contains review analysis settings
+type ReviewsConfig struct {
+	// Analyzer is lexicon (built-in word lists) or llm (uses the OpenAI settings)
+	Analyzer         string
+	AnalysisInterval time.Duration
+}
+
+// AuthConfig contains authentication settings
+type AuthConfig struct {
+	// AdminToken is an operator token with the permissions of an admin; without
+	// it only users with a role reach the admin endpoints
+	AdminToken string
+	// SeedAdminEmail is made an admin on startup, created with SeedAdminPassword
+	// if no user has the address
+	SeedAdminEmail    string
+	SeedAdminPassword string
+	// KeyGracePeriod is how long retired signing keys still verify tokens
+	KeyGracePeriod    time.Duration
+	KeyReloadInterval time.Duration
+	Issuer            string
+	AccessTokenTTL    time.Duration
+	RefreshTokenTTL   time.Duration
+	// InvitationTTL is how long users created by an import can accept their invitation
+	InvitationTTL time.Duration
+	// EmailChangeTTL is how long the link confirming a new email address is valid
+	EmailChangeTTL time.Duration
+	// VerificationTTL is how long the link verifying a new account's email is valid
+	VerificationTTL time.Duration
+	// PasswordResetTTL is how long the link to choose a new password is valid
+	PasswordResetTTL time.Duration
+	// Google and GitHub are the OAuth applications users can sign in with;
+	// each provider is disabled without a client ID
+	Google OAuthProviderConfig
+	GitHub OAuthProviderConfig
+}
+
+// OAuthProviderConfig contains the credentials of an application registered with an OAuth provider
+type OAuthProviderConfig struct {
+	ClientID     string
+	ClientSecret string
+}
+
+// TransitConfig contains public transit routing settings
+type TransitConfig struct {
+	// OTPURL is an OpenTripPlanner GraphQL endpoint; transit directions are disabled when empty
+	OTPURL string
+}
+
+// ClimateConfig contains the source of historical weather statistics
+type ClimateConfig struct {
+	// ArchiveURL is an Open-Meteo historical weather API endpoint
+	ArchiveURL string
+	// Years is how many recent complete years the normals average over
+	Years int
+}
+
+// WeatherConfig contains the source of weather forecasts
+type WeatherConfig struct {
+	// Provider is openmeteo or none, which disables the weather tool and annotations
+	Provider string
+	// ForecastURL is an Open-Meteo forecast API endpoint
+	ForecastURL string
+}
+
+// GeocodeConfig contains the geocoder resolving place names
+type GeocodeConfig struct {
+	// Provider is nominatim or none, which disables place name lookups
+	Provider string
+	// URL is the base URL of a Nominatim server
+	URL string
+	// UserAgent identifies this application, as Nominatim's usage policy requires
+	UserAgent string
+	// MinInterval spaces requests; the public Nominatim allows one per second
+	MinInterval time.Duration
+}
+
+// MailConfig contains how emails are delivered
+type MailConfig struct {
+	// Provider is smtp, or log, which writes emails to the log instead
+	Provider string
+	// From is the sender, e.g. "Community <no-reply@example.org>"
+	From         string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	// Workers deliver queued emails; QueueSize emails wait at most
+	Workers   int
+	QueueSize int
+}
+
+// ResidencyConfig routes communities to regional databases and image storage
+type ResidencyConfig struct {
+	// DefaultRegion names the region of DATABASE_URL and CLOUDINARY_URL, which
+	// serves requests without a community
+	DefaultRegion string
+	// Regions are the further regions; residency is disabled without any
+	Regions []RegionConfig
+	// Communities maps the communities sent in the X-Community header to regions
+	Communities map[string]string
+}
+
+// RegionConfig contains the database and image storage of a region
+type RegionConfig struct {
+	Name          string
+	DatabaseURL   string
+	CloudinaryURL string
+}
+
+// StreamConfig contains settings for long-lived streaming connections
+type StreamConfig struct {
+	// IdleTimeout closes streams that have written nothing for this long
+	IdleTimeout  time.Duration
+	ReapInterval time.Duration
+	// ReplayEvents is how many recent events of each stream are buffered for
+	// clients reconnecting with Last-Event-ID
+	ReplayEvents int
+	// ResumeWindow is how long a disconnected stream keeps generating, and a
+	// completed one stays buffered, for its client to reconnect
+	ResumeWindow time.Duration
+	// RunTokenTTL is how long EventSource clients have to open the stream of a
+	// run created with POST /chat/runs. Replicas share pending runs through
+	// Redis and must share RunTokenSecret, which is random per process when empty.
+	RunTokenTTL    time.Duration
+	RunTokenSecret string
+}
+
+// RateLimitConfig contains the token buckets applied to API requests. Limits are
+// shared across replicas through Redis when REDIS_URL is set.
+type RateLimitConfig struct {
+	Enabled   bool
+	IPRate    float64 // requests per second per anonymous client IP
+	IPBurst   int
+	UserRate  float64 // requests per second per authenticated user
+	UserBurst int
+	// APIKeyRate and APIKeyBurst limit each personal API key separately from its user
+	APIKeyRate  float64
+	APIKeyBurst int
+}
+
+// FederationConfig contains settings for exchanging activity bundles with other communities
+type FederationConfig struct {
+	// Community is the name this instance exports bundles under
+	Community string
+	// SigningKey is a base64 Ed25519 seed; export is disabled when empty
+	SigningKey string
+	// TrustedKeys lists "community=base64 public key" pairs to import from, comma separated
+	TrustedKeys string
+}
+
+// ChatConfig contains settings for chat conversations
+type ChatConfig struct {
+	// VerbatimTurns is how many of the latest messages the model sees word for word;
+	// older ones are folded into a rolling summary
+	VerbatimTurns int
+	// HistoryTokenBudget caps the estimated tokens of summary and history per prompt
+	HistoryTokenBudget int
+	// Without a database, conversations are kept in memory: at most MemoryMaxTurns
+	// messages each, MemoryMaxConversations in total, each for MemoryTTL after its
+	// last message
+	MemoryMaxTurns         int
+	MemoryMaxConversations int
+	MemoryTTL              time.Duration
+	// GuestDailyMessages and UserDailyMessages are the daily message quotas of
+	// anonymous guests and signed-in users; 0 disables a quota
+	GuestDailyMessages int
+	UserDailyMessages  int
+	// Clients sending more than FloodSlowdownPerMinute messages a minute have
+	// them delayed by up to FloodMaxDelay, and more than FloodBlockPerMinute are
+	// blocked for FloodBlockDuration, doubled for repeat blocks; 0 disables either
+	FloodSlowdownPerMinute int
+	FloodMaxDelay          time.Duration
+	FloodBlockPerMinute    int
+	FloodBlockDuration     time.Duration
+	// A client sending the same message again within DedupeWindow is refused;
+	// at most DedupeMaxEntries recent messages are remembered without Redis
+	DedupeWindow     time.Duration
+	DedupeMaxEntries int
+}
+
+// EmbedConfig contains settings for the chat widget embedded on third-party sites
+type EmbedConfig struct {
+	// TokenTTL is the lifetime of origin-bound widget tokens
+	TokenTTL time.Duration
+	// Rate and Burst are the default chat rate limit of a new embed key, shared by all its visitors
+	Rate  float64
+	Burst int
+}
+
+// SessionConfig contains settings for the sessions of anonymous chat users
+type SessionConfig struct {
+	// TTL is how long a session lasts without use
+	TTL time.Duration
+	// CookieSecure sends the session cookie only over HTTPS and also to frontends
+	// on another site (SameSite=None)
+	CookieSecure bool
+}
+
+// ModerationConfig contains automatic screening of uploaded images
+type ModerationConfig struct {
+	// Provider is none, stub or openai (uses the OpenAI API key and base URL)
+	Provider string
+	Model    string
+	// Images scoring at least ReviewThreshold are queued for moderators first;
+	// those scoring at least RejectThreshold are rejected without upload
+	ReviewThreshold float64
+	RejectThreshold float64
+	// AutoApprove approves images scoring below ReviewThreshold without a moderator
+	AutoApprove bool
+	// StubScore is the score the stub provider gives every image
+	StubScore float64
+}
+
+// TrustConfig contains how contributors earn and lose trust levels
+type TrustConfig struct {
+	// Approved activities and images needed to become a member, then trusted
+	MemberContributions  int
+	TrustedContributions int
+	// DemotionViolations are the rejected or removed contributions after which
+	// a contributor drops one level
+	DemotionViolations int
+}
+
 // Load reads configuration from environment variables and .env file
 func Load() (*Config, error) {
 	// Try to load .env file from different locations
@@ -89,26 +511,244 @@ func Load() (*Config, error) {
 			Password: getEnv("DB_PASSWORD", ""),
 			Name:     getEnv("DB_NAME", "community_chatbot"),
 			SSLMode:  getEnv("DB_SSL_MODE", "disable"),
+
+			MaxOpenConns:    getEnvAsInt("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:    getEnvAsInt("DB_MAX_IDLE_CONNS", 10),
+			ConnMaxLifetime: getEnvAsDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+			ConnectAttempts: getEnvAsInt("DB_CONNECT_ATTEMPTS", 5),
+			RetryMaxBackoff: getEnvAsDuration("DB_RETRY_MAX_BACKOFF", 30*time.Second),
 		},
 		Server: ServerConfig{
 			Port:        getEnvAsInt("PORT", 8080),
 			Environment: getEnv("ENVIRONMENT", "development"),
 			LogLevel:    getEnv("LOG_LEVEL", "info"),
+			FrontendURL: getEnv("FRONTEND_URL", "http://localhost:3000"),
+			PublicURL:   getEnv("PUBLIC_URL", ""),
+
+			Prefork:         getEnvAsBool("SERVER_PREFORK", false),
+			BodyLimit:       getEnvAsInt("SERVER_BODY_LIMIT", 8*1024*1024),
+			ReadTimeout:     getEnvAsDuration("SERVER_READ_TIMEOUT", time.Minute),
+			WriteTimeout:    getEnvAsDuration("SERVER_WRITE_TIMEOUT", 0),
+			IdleTimeout:     getEnvAsDuration("SERVER_IDLE_TIMEOUT", 2*time.Minute),
+			Concurrency:     getEnvAsInt("SERVER_CONCURRENCY", 256*1024),
+			StreamKeepalive: getEnvAsDuration("SERVER_STREAM_KEEPALIVE", 5*time.Second),
+			DebugChat:       getEnvAsBool("DEBUG_CHAT", false),
+		},
+		Logging: LoggingConfig{
+			SampleRate:             getEnvAsFloat("LOG_SAMPLE_RATE", 1),
+			SampleRules:            getEnvAsRates("LOG_SAMPLE_RULES"),
+			SlowRequest:            getEnvAsDuration("LOG_SLOW_REQUEST", 2*time.Second),
+			SamplingReloadInterval: getEnvAsDuration("LOG_SAMPLING_RELOAD_INTERVAL", 30*time.Second),
+		},
+		LLM: LLMConfig{
+			Provider:                 getEnv("LLM_PROVIDER", "openai"),
+			Temperature:              getEnvAsFloat("LLM_TEMPERATURE", -1),
+			AzureEndpoint:            getEnv("AZURE_OPENAI_ENDPOINT", ""),
+			AzureAPIKey:              getEnv("AZURE_OPENAI_API_KEY", ""),
+			AzureAPIVersion:          getEnv("AZURE_OPENAI_API_VERSION", "2024-06-01"),
+			AzureDeployment:          getEnv("AZURE_OPENAI_DEPLOYMENT", ""),
+			AzureEmbeddingDeployment: getEnv("AZURE_OPENAI_EMBEDDING_DEPLOYMENT", ""),
+			OllamaURL:                getEnv("OLLAMA_URL", "http://localhost:11434"),
+			OllamaModel:              getEnv("OLLAMA_MODEL", "llama3.1"),
+			OllamaEmbeddingModel:     getEnv("OLLAMA_EMBEDDING_MODEL", "nomic-embed-text"),
 		},
 		OpenAI: OpenAIConfig{
-			APIKey: getEnv("OPENAI_API_KEY", ""),
-			Model:  getEnv("OPENAI_MODEL", "gpt-4"),
+			APIKey:          getEnv("OPENAI_API_KEY", ""),
+			Model:           getEnv("OPENAI_MODEL", "gpt-4"),
+			EmbeddingModel:  getEnv("OPENAI_EMBEDDING_MODEL", "text-embedding-3-small"),
+			BaseURL:         getEnv("OPENAI_BASE_URL", "https://api.openai.com/v1"),
+			MaxConcurrent:   getEnvAsInt("OPENAI_MAX_CONCURRENT", 20),
+			MaxQueued:       getEnvAsInt("OPENAI_MAX_QUEUED", 100),
+			QueueTimeout:    getEnvAsDuration("OPENAI_QUEUE_TIMEOUT", 10*time.Second),
+			PromptPrice:     getEnvAsFloat("OPENAI_PROMPT_PRICE", 30),
+			CompletionPrice: getEnvAsFloat("OPENAI_COMPLETION_PRICE", 60),
 		},
 		Storage: StorageConfig{
-			CloudinaryURL: getEnv("CLOUDINARY_URL", ""),
-			CloudName:     getEnv("CLOUDINARY_CLOUD_NAME", ""),
-			APIKey:        getEnv("CLOUDINARY_API_KEY", ""),
-			APISecret:     getEnv("CLOUDINARY_API_SECRET", ""),
+			CloudinaryURL:      getEnv("CLOUDINARY_URL", ""),
+			CloudName:          getEnv("CLOUDINARY_CLOUD_NAME", ""),
+			APIKey:             getEnv("CLOUDINARY_API_KEY", ""),
+			APISecret:          getEnv("CLOUDINARY_API_SECRET", ""),
+			Folder:             getEnv("CLOUDINARY_FOLDER", "community-chatbot"),
+			SpoolDir:           getEnv("UPLOAD_SPOOL_DIR", filepath.Join(os.TempDir(), "community-chatbot-uploads")),
+			MaxImageBytes:      getEnvAsInt("UPLOAD_MAX_IMAGE_BYTES", 4*1024*1024),
+			UploadInterval:     getEnvAsDuration("UPLOAD_RETRY_INTERVAL", 30*time.Second),
+			UploadMaxAttempts:  getEnvAsInt("UPLOAD_MAX_ATTEMPTS", 10),
+			UploadRetryBackoff: getEnvAsDuration("UPLOAD_RETRY_BACKOFF", 30*time.Second),
+			UploadRetryMaxWait: getEnvAsDuration("UPLOAD_RETRY_MAX_WAIT", time.Hour),
+			ImageTypes:         getEnv("UPLOAD_IMAGE_TYPES", "image/jpeg,image/png,image/gif,image/webp"),
+			MaxImageDimension:  getEnvAsInt("UPLOAD_MAX_IMAGE_DIMENSION", 8000),
+			RouteTypes:         getEnv("UPLOAD_ROUTE_TYPES", "application/gpx+xml,application/vnd.garmin.tcx+xml,application/vnd.google-earth.kml+xml,application/vnd.ant.fit"),
+			MaxRouteBytes:      getEnvAsInt("UPLOAD_MAX_ROUTE_BYTES", 4*1024*1024),
+			MaxRoutePoints:     getEnvAsInt("UPLOAD_MAX_ROUTE_POINTS", 50000),
+			Scanner:            getEnv("UPLOAD_SCANNER", "none"),
+			ScannerAddress:     getEnv("UPLOAD_SCANNER_ADDRESS", ""),
+			ScanTimeout:        getEnvAsDuration("UPLOAD_SCAN_TIMEOUT", 30*time.Second),
 		},
 		CORS: CORSConfig{
 			AllowOrigins: getEnv("CORS_ALLOW_ORIGINS", "*"),
 			AllowMethods: getEnv("CORS_ALLOW_METHODS", "GET,POST,PUT,DELETE,OPTIONS"),
-			AllowHeaders: getEnv("CORS_ALLOW_HEADERS", "Origin,Content-Type,Accept,Authorization"),
+			AllowHeaders: getEnv("CORS_ALLOW_HEADERS", "Origin,Content-Type,Accept,Authorization,X-Request-ID,X-Session-Token,Last-Event-ID,X-Community"),
+		},
+		Cache: CacheConfig{
+			RedisURL:     getEnv("REDIS_URL", ""),
+			MaxEntries:   getEnvAsInt("CACHE_MAX_ENTRIES", 10000),
+			GeocodeTTL:   getEnvAsDuration("CACHE_GEOCODE_TTL", 24*time.Hour),
+			WeatherTTL:   getEnvAsDuration("CACHE_WEATHER_TTL", 15*time.Minute),
+			RoutingTTL:   getEnvAsDuration("CACHE_ROUTING_TTL", time.Hour),
+			TransitTTL:   getEnvAsDuration("CACHE_TRANSIT_TTL", 5*time.Minute),
+			ClimateTTL:   getEnvAsDuration("CACHE_CLIMATE_TTL", 30*24*time.Hour),
+			ChecklistTTL: getEnvAsDuration("CACHE_CHECKLIST_TTL", 24*time.Hour),
+			NearbyTTL:    getEnvAsDuration("CACHE_NEARBY_TTL", time.Minute),
+			AreaStatsTTL: getEnvAsDuration("CACHE_AREA_STATS_TTL", 10*time.Minute),
+			HealthTTL:    getEnvAsDuration("CACHE_HEALTH_TTL", 5*time.Second),
+			NegativeTTL:  getEnvAsDuration("CACHE_NEGATIVE_TTL", time.Minute),
+		},
+		Similar: SimilarityConfig{
+			ContentWeight:   getEnvAsFloat("SIMILAR_CONTENT_WEIGHT", 0.7),
+			ProximityWeight: getEnvAsFloat("SIMILAR_PROXIMITY_WEIGHT", 0.3),
+			MaxDistanceKM:   getEnvAsFloat("SIMILAR_MAX_DISTANCE_KM", 50),
+		},
+		Semantic: SemanticSearchConfig{
+			Enabled:        getEnvAsBool("SEMANTIC_SEARCH_ENABLED", false),
+			Dimensions:     getEnvAsInt("EMBEDDING_DIMENSIONS", 1536),
+			EmbedInterval:  getEnvAsDuration("EMBEDDING_INTERVAL", 5*time.Minute),
+			RetrievalLimit: getEnvAsInt("RAG_TOP_K", 3),
+			MinSimilarity:  getEnvAsFloat("RAG_MIN_SIMILARITY", 0.3),
+		},
+		Content: ContentConfig{
+			StaleAfter:           getEnvAsDuration("CONTENT_STALE_AFTER", 180*24*time.Hour),
+			StatsRefreshInterval: getEnvAsDuration("STATS_REFRESH_INTERVAL", 10*time.Minute),
+		},
+		Scheduler: SchedulerConfig{
+			LockBackend: getEnv("SCHEDULER_LOCK_BACKEND", "local"),
+			LockTTL:     getEnvAsDuration("SCHEDULER_LOCK_TTL", 30*time.Second),
+
+			SessionCleanupInterval:     getEnvAsDuration("SCHEDULER_SESSION_CLEANUP_INTERVAL", time.Hour),
+			ConversationPruneInterval:  getEnvAsDuration("SCHEDULER_CONVERSATION_PRUNE_INTERVAL", 24*time.Hour),
+			GuestConversationRetention: getEnvAsDuration("SCHEDULER_GUEST_CONVERSATION_RETENTION", 0),
+			PopularityInterval:         getEnvAsDuration("SCHEDULER_POPULARITY_INTERVAL", 15*time.Minute),
+			WeatherRefreshInterval:     getEnvAsDuration("SCHEDULER_WEATHER_REFRESH_INTERVAL", 0),
+			WeatherRefreshActivities:   getEnvAsInt("SCHEDULER_WEATHER_REFRESH_ACTIVITIES", 50),
+			WeatherRefreshAreas:        getEnvAsInt("SCHEDULER_WEATHER_REFRESH_AREAS", 50),
+		},
+		Telemetry: TelemetryConfig{
+			Enabled:    getEnvAsBool("TELEMETRY_ENABLED", false),
+			Endpoint:   getEnv("TELEMETRY_ENDPOINT", ""),
+			Interval:   getEnvAsDuration("TELEMETRY_INTERVAL", 24*time.Hour),
+			InstanceID: getEnv("TELEMETRY_INSTANCE_ID", ""),
+		},
+		Reviews: ReviewsConfig{
+			Analyzer:         getEnv("REVIEW_ANALYZER", "lexicon"),
+			AnalysisInterval: getEnvAsDuration("REVIEW_ANALYSIS_INTERVAL", time.Minute),
+		},
+		Auth: AuthConfig{
+			AdminToken:        getEnv("ADMIN_TOKEN", ""),
+			SeedAdminEmail:    getEnv("ADMIN_EMAIL", ""),
+			SeedAdminPassword: getEnv("ADMIN_PASSWORD", ""),
+			KeyGracePeriod:    getEnvAsDuration("AUTH_KEY_GRACE_PERIOD", 7*24*time.Hour),
+			KeyReloadInterval: getEnvAsDuration("AUTH_KEY_RELOAD_INTERVAL", time.Minute),
+			Issuer:            getEnv("AUTH_ISSUER", "community-chatbot"),
+			AccessTokenTTL:    getEnvAsDuration("AUTH_ACCESS_TOKEN_TTL", 15*time.Minute),
+			RefreshTokenTTL:   getEnvAsDuration("AUTH_REFRESH_TOKEN_TTL", 7*24*time.Hour),
+			InvitationTTL:     getEnvAsDuration("AUTH_INVITATION_TTL", 14*24*time.Hour),
+			EmailChangeTTL:    getEnvAsDuration("AUTH_EMAIL_CHANGE_TTL", 24*time.Hour),
+			VerificationTTL:   getEnvAsDuration("AUTH_VERIFICATION_TTL", 48*time.Hour),
+			PasswordResetTTL:  getEnvAsDuration("AUTH_PASSWORD_RESET_TTL", time.Hour),
+			Google: OAuthProviderConfig{
+				ClientID:     getEnv("OAUTH_GOOGLE_CLIENT_ID", ""),
+				ClientSecret: getEnv("OAUTH_GOOGLE_CLIENT_SECRET", ""),
+			},
+			GitHub: OAuthProviderConfig{
+				ClientID:     getEnv("OAUTH_GITHUB_CLIENT_ID", ""),
+				ClientSecret: getEnv("OAUTH_GITHUB_CLIENT_SECRET", ""),
+			},
+		},
+		Transit: TransitConfig{
+			OTPURL: getEnv("TRANSIT_OTP_URL", ""),
+		},
+		Climate: ClimateConfig{
+			ArchiveURL: getEnv("CLIMATE_ARCHIVE_URL", "https://archive-api.open-meteo.com/v1/archive"),
+			Years:      getEnvAsInt("CLIMATE_YEARS", 10),
+		},
+		Weather: WeatherConfig{
+			Provider:    getEnv("WEATHER_PROVIDER", "openmeteo"),
+			ForecastURL: getEnv("WEATHER_FORECAST_URL", "https://api.open-meteo.com/v1/forecast"),
+		},
+		Geocode: GeocodeConfig{
+			Provider:    getEnv("GEOCODE_PROVIDER", "nominatim"),
+			URL:         getEnv("GEOCODE_URL", "https://nominatim.openstreetmap.org"),
+			UserAgent:   getEnv("GEOCODE_USER_AGENT", "community-chatbot/1.0"),
+			MinInterval: getEnvAsDuration("GEOCODE_MIN_INTERVAL", time.Second),
+		},
+		Streams: StreamConfig{
+			IdleTimeout:    getEnvAsDuration("STREAM_IDLE_TIMEOUT", 2*time.Minute),
+			ReapInterval:   getEnvAsDuration("STREAM_REAP_INTERVAL", 15*time.Second),
+			ReplayEvents:   getEnvAsInt("STREAM_REPLAY_EVENTS", 2000),
+			ResumeWindow:   getEnvAsDuration("STREAM_RESUME_WINDOW", 30*time.Second),
+			RunTokenTTL:    getEnvAsDuration("STREAM_RUN_TOKEN_TTL", time.Minute),
+			RunTokenSecret: getEnv("STREAM_RUN_TOKEN_SECRET", ""),
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:     getEnvAsBool("RATE_LIMIT_ENABLED", true),
+			IPRate:      getEnvAsFloat("RATE_LIMIT_IP_RATE", 2),
+			IPBurst:     getEnvAsInt("RATE_LIMIT_IP_BURST", 30),
+			UserRate:    getEnvAsFloat("RATE_LIMIT_USER_RATE", 5),
+			UserBurst:   getEnvAsInt("RATE_LIMIT_USER_BURST", 60),
+			APIKeyRate:  getEnvAsFloat("RATE_LIMIT_API_KEY_RATE", 2),
+			APIKeyBurst: getEnvAsInt("RATE_LIMIT_API_KEY_BURST", 100),
+		},
+		Federation: FederationConfig{
+			Community:   getEnv("FEDERATION_COMMUNITY", ""),
+			SigningKey:  getEnv("FEDERATION_SIGNING_KEY", ""),
+			TrustedKeys: getEnv("FEDERATION_TRUSTED_KEYS", ""),
+		},
+		Chat: ChatConfig{
+			VerbatimTurns:          getEnvAsInt("CHAT_VERBATIM_TURNS", 6),
+			HistoryTokenBudget:     getEnvAsInt("CHAT_HISTORY_TOKEN_BUDGET", 2000),
+			MemoryMaxTurns:         getEnvAsInt("CHAT_MEMORY_MAX_TURNS", 50),
+			MemoryMaxConversations: getEnvAsInt("CHAT_MEMORY_MAX_CONVERSATIONS", 1000),
+			MemoryTTL:              getEnvAsDuration("CHAT_MEMORY_TTL", 24*time.Hour),
+			GuestDailyMessages:     getEnvAsInt("CHAT_GUEST_DAILY_MESSAGES", 20),
+			UserDailyMessages:      getEnvAsInt("CHAT_USER_DAILY_MESSAGES", 200),
+			FloodSlowdownPerMinute: getEnvAsInt("CHAT_FLOOD_SLOWDOWN_PER_MINUTE", 10),
+			FloodMaxDelay:          getEnvAsDuration("CHAT_FLOOD_MAX_DELAY", 10*time.Second),
+			FloodBlockPerMinute:    getEnvAsInt("CHAT_FLOOD_BLOCK_PER_MINUTE", 30),
+			FloodBlockDuration:     getEnvAsDuration("CHAT_FLOOD_BLOCK_DURATION", 5*time.Minute),
+			DedupeWindow:           getEnvAsDuration("CHAT_DEDUPE_WINDOW", 10*time.Second),
+			DedupeMaxEntries:       getEnvAsInt("CHAT_DEDUPE_MAX_ENTRIES", 10000),
+		},
+		Embed: EmbedConfig{
+			TokenTTL: getEnvAsDuration("EMBED_TOKEN_TTL", 10*time.Minute),
+			Rate:     getEnvAsFloat("EMBED_RATE", 1),
+			Burst:    getEnvAsInt("EMBED_BURST", 20),
+		},
+		Session: SessionConfig{
+			TTL:          getEnvAsDuration("SESSION_TTL", 30*24*time.Hour),
+			CookieSecure: getEnvAsBool("SESSION_COOKIE_SECURE", false),
+		},
+		Moderation: ModerationConfig{
+			Provider:        getEnv("MODERATION_PROVIDER", "none"),
+			Model:           getEnv("MODERATION_MODEL", "omni-moderation-latest"),
+			ReviewThreshold: getEnvAsFloat("MODERATION_REVIEW_THRESHOLD", 0.4),
+			RejectThreshold: getEnvAsFloat("MODERATION_REJECT_THRESHOLD", 0.8),
+			AutoApprove:     getEnvAsBool("MODERATION_AUTO_APPROVE", false),
+			StubScore:       getEnvAsFloat("MODERATION_STUB_SCORE", 0),
+		},
+		Trust: TrustConfig{
+			MemberContributions:  getEnvAsInt("TRUST_MEMBER_CONTRIBUTIONS", 3),
+			TrustedContributions: getEnvAsInt("TRUST_TRUSTED_CONTRIBUTIONS", 10),
+			DemotionViolations:   getEnvAsInt("TRUST_DEMOTION_VIOLATIONS", 2),
+		},
+		Residency: loadResidency(),
+		Mail: MailConfig{
+			Provider:     getEnv("MAIL_PROVIDER", "log"),
+			From:         getEnv("MAIL_FROM", "Community Chatbot <no-reply@localhost>"),
+			SMTPHost:     getEnv("SMTP_HOST", ""),
+			SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			Workers:      getEnvAsInt("MAIL_WORKERS", 2),
+			QueueSize:    getEnvAsInt("MAIL_QUEUE_SIZE", 1000),
 		},
 	}
 
@@ -122,13 +762,211 @@ func Load() (*Config, error) {
 
 // validate checks that required configuration values are present
 func (c *Config) validate() error {
+	if c.Telemetry.Enabled && c.Telemetry.Endpoint == "" {
+		return fmt.Errorf("TELEMETRY_ENDPOINT is required when telemetry is enabled")
+	}
+
+	if c.Reviews.Analyzer != "lexicon" && c.Reviews.Analyzer != "llm" {
+		return fmt.Errorf("REVIEW_ANALYZER must be lexicon or llm, got %q", c.Reviews.Analyzer)
+	}
+
+	if c.Logging.SampleRate < 0 || c.Logging.SampleRate > 1 {
+		return fmt.Errorf("LOG_SAMPLE_RATE must be between 0 and 1")
+	}
+	for path, rate := range c.Logging.SampleRules {
+		if !strings.HasPrefix(path, "/") || rate < 0 || rate > 1 {
+			return fmt.Errorf("LOG_SAMPLE_RULES must be path=rate pairs with rates between 0 and 1, got %s=%v", path, rate)
+		}
+	}
+	if c.Logging.SlowRequest < 0 || c.Logging.SamplingReloadInterval <= 0 {
+		return fmt.Errorf("LOG_SLOW_REQUEST must not be negative and LOG_SAMPLING_RELOAD_INTERVAL must be positive")
+	}
+
+	if c.RateLimit.Enabled && (c.RateLimit.IPRate <= 0 || c.RateLimit.UserRate <= 0 || c.RateLimit.APIKeyRate <= 0 ||
+		c.RateLimit.IPBurst < 1 || c.RateLimit.UserBurst < 1 || c.RateLimit.APIKeyBurst < 1) {
+		return fmt.Errorf("rate limits must have a positive rate and a burst of at least 1")
+	}
+
+	if c.Embed.Rate <= 0 || c.Embed.Burst < 1 {
+		return fmt.Errorf("EMBED_RATE must be positive and EMBED_BURST at least 1")
+	}
+
+	switch c.LLM.Provider {
+	case "openai", "ollama":
+	case "azure":
+		if c.LLM.AzureEndpoint == "" || c.LLM.AzureAPIKey == "" || c.LLM.AzureDeployment == "" {
+			return fmt.Errorf("LLM_PROVIDER=azure requires AZURE_OPENAI_ENDPOINT, AZURE_OPENAI_API_KEY and AZURE_OPENAI_DEPLOYMENT")
+		}
+	default:
+		return fmt.Errorf("LLM_PROVIDER must be openai, azure or ollama, got %q", c.LLM.Provider)
+	}
+	if c.LLM.Temperature > 2 {
+		return fmt.Errorf("LLM_TEMPERATURE must be at most 2")
+	}
+
+	if c.Streams.RunTokenTTL <= 0 {
+		return fmt.Errorf("STREAM_RUN_TOKEN_TTL must be positive")
+	}
+
+	if c.OpenAI.MaxConcurrent < 0 || c.OpenAI.MaxQueued < 0 || c.OpenAI.QueueTimeout <= 0 {
+		return fmt.Errorf("OPENAI_MAX_CONCURRENT and OPENAI_MAX_QUEUED must not be negative and OPENAI_QUEUE_TIMEOUT must be positive")
+	}
+	if c.OpenAI.PromptPrice < 0 || c.OpenAI.CompletionPrice < 0 {
+		return fmt.Errorf("OPENAI_PROMPT_PRICE and OPENAI_COMPLETION_PRICE must not be negative")
+	}
+
+	if c.Chat.VerbatimTurns < 1 || c.Chat.HistoryTokenBudget < 1 {
+		return fmt.Errorf("CHAT_VERBATIM_TURNS and CHAT_HISTORY_TOKEN_BUDGET must be at least 1")
+	}
+
+	if c.Chat.MemoryMaxTurns < c.Chat.VerbatimTurns || c.Chat.MemoryMaxConversations < 1 || c.Chat.MemoryTTL <= 0 {
+		return fmt.Errorf("CHAT_MEMORY_MAX_TURNS must be at least CHAT_VERBATIM_TURNS, CHAT_MEMORY_MAX_CONVERSATIONS and CHAT_MEMORY_TTL must be positive")
+	}
+
+	if c.Session.TTL <= 0 {
+		return fmt.Errorf("SESSION_TTL must be positive")
+	}
+
+	if c.Chat.GuestDailyMessages < 0 || c.Chat.UserDailyMessages < 0 {
+		return fmt.Errorf("CHAT_GUEST_DAILY_MESSAGES and CHAT_USER_DAILY_MESSAGES must not be negative")
+	}
+	if c.Chat.FloodSlowdownPerMinute < 0 || c.Chat.FloodBlockPerMinute < 0 || c.Chat.FloodMaxDelay <= 0 || c.Chat.FloodBlockDuration <= 0 {
+		return fmt.Errorf("CHAT_FLOOD_SLOWDOWN_PER_MINUTE and CHAT_FLOOD_BLOCK_PER_MINUTE must not be negative, CHAT_FLOOD_MAX_DELAY and CHAT_FLOOD_BLOCK_DURATION must be positive")
+	}
+	if c.Chat.FloodSlowdownPerMinute > 0 && c.Chat.FloodBlockPerMinute > 0 && c.Chat.FloodBlockPerMinute <= c.Chat.FloodSlowdownPerMinute {
+		return fmt.Errorf("CHAT_FLOOD_BLOCK_PER_MINUTE must be above CHAT_FLOOD_SLOWDOWN_PER_MINUTE")
+	}
+	if c.Chat.DedupeWindow < 0 || c.Chat.DedupeMaxEntries < 1 {
+		return fmt.Errorf("CHAT_DEDUPE_WINDOW must not be negative and CHAT_DEDUPE_MAX_ENTRIES must be positive")
+	}
+
+	if c.Scheduler.SessionCleanupInterval < 0 || c.Scheduler.ConversationPruneInterval < 0 || c.Scheduler.GuestConversationRetention < 0 ||
+		c.Scheduler.PopularityInterval < 0 || c.Scheduler.WeatherRefreshInterval < 0 || c.Scheduler.WeatherRefreshActivities < 0 || c.Scheduler.WeatherRefreshAreas < 0 {
+		return fmt.Errorf("SCHEDULER_* intervals, SCHEDULER_GUEST_CONVERSATION_RETENTION, SCHEDULER_WEATHER_REFRESH_ACTIVITIES and SCHEDULER_WEATHER_REFRESH_AREAS must not be negative")
+	}
+
+	if c.Storage.MaxImageBytes < 1 || c.Storage.UploadMaxAttempts < 1 || c.Storage.UploadRetryBackoff <= 0 {
+		return fmt.Errorf("UPLOAD_MAX_IMAGE_BYTES, UPLOAD_MAX_ATTEMPTS and UPLOAD_RETRY_BACKOFF must be positive")
+	}
+
+	if c.Server.ReadTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.IdleTimeout < 0 || c.Server.Concurrency < 1 {
+		return fmt.Errorf("SERVER_READ_TIMEOUT, SERVER_WRITE_TIMEOUT and SERVER_IDLE_TIMEOUT must not be negative and SERVER_CONCURRENCY must be positive")
+	}
+	if c.Server.StreamKeepalive <= 0 {
+		return fmt.Errorf("SERVER_STREAM_KEEPALIVE must be positive")
+	}
+	// Keepalives only keep streams open if they come before the idle timeout
+	if c.Streams.IdleTimeout > 0 && c.Server.StreamKeepalive >= c.Streams.IdleTimeout {
+		return fmt.Errorf("SERVER_STREAM_KEEPALIVE must be shorter than STREAM_IDLE_TIMEOUT")
+	}
+	// Multipart forms need room for their boundaries and fields besides the file
+	if c.Server.BodyLimit <= c.Storage.MaxImageBytes || c.Server.BodyLimit <= c.Storage.MaxRouteBytes {
+		return fmt.Errorf("SERVER_BODY_LIMIT must be larger than UPLOAD_MAX_IMAGE_BYTES and UPLOAD_MAX_ROUTE_BYTES")
+	}
+
+	if c.Storage.MaxImageDimension < 1 || c.Storage.MaxRouteBytes < 1 || c.Storage.MaxRoutePoints < 1 || c.Storage.ScanTimeout <= 0 {
+		return fmt.Errorf("UPLOAD_MAX_IMAGE_DIMENSION, UPLOAD_MAX_ROUTE_BYTES, UPLOAD_MAX_ROUTE_POINTS and UPLOAD_SCAN_TIMEOUT must be positive")
+	}
+	switch c.Storage.Scanner {
+	case "none":
+	case "clamav", "icap":
+		if c.Storage.ScannerAddress == "" {
+			return fmt.Errorf("UPLOAD_SCANNER_ADDRESS is required when UPLOAD_SCANNER is %s", c.Storage.Scanner)
+		}
+	default:
+		return fmt.Errorf("UPLOAD_SCANNER must be none, clamav or icap, got %q", c.Storage.Scanner)
+	}
+
+	switch c.Mail.Provider {
+	case "log":
+	case "smtp":
+		if c.Mail.SMTPHost == "" {
+			return fmt.Errorf("SMTP_HOST is required when MAIL_PROVIDER is smtp")
+		}
+	default:
+		return fmt.Errorf("MAIL_PROVIDER must be smtp or log, got %q", c.Mail.Provider)
+	}
+	if c.Mail.Workers < 1 || c.Mail.QueueSize < 1 {
+		return fmt.Errorf("MAIL_WORKERS and MAIL_QUEUE_SIZE must be positive")
+	}
+
+	if c.Weather.Provider != "openmeteo" && c.Weather.Provider != "none" {
+		return fmt.Errorf("WEATHER_PROVIDER must be openmeteo or none, got %q", c.Weather.Provider)
+	}
+
+	if c.Geocode.Provider != "nominatim" && c.Geocode.Provider != "none" {
+		return fmt.Errorf("GEOCODE_PROVIDER must be nominatim or none, got %q", c.Geocode.Provider)
+	}
+	if c.Geocode.Provider == "nominatim" && (c.Geocode.UserAgent == "" || c.Geocode.MinInterval < 0) {
+		return fmt.Errorf("GEOCODE_USER_AGENT is required and GEOCODE_MIN_INTERVAL must not be negative")
+	}
+
+	switch c.Moderation.Provider {
+	case "none", "stub":
+	case "openai":
+		if c.OpenAI.APIKey == "" {
+			return fmt.Errorf("MODERATION_PROVIDER=openai requires OPENAI_API_KEY")
+		}
+	default:
+		return fmt.Errorf("MODERATION_PROVIDER must be none, stub or openai, got %q", c.Moderation.Provider)
+	}
+	if c.Moderation.ReviewThreshold <= 0 || c.Moderation.ReviewThreshold > c.Moderation.RejectThreshold || c.Moderation.RejectThreshold > 1 {
+		return fmt.Errorf("MODERATION_REVIEW_THRESHOLD and MODERATION_REJECT_THRESHOLD must satisfy 0 < review <= reject <= 1")
+	}
+	if c.Trust.MemberContributions < 1 || c.Trust.MemberContributions > c.Trust.TrustedContributions {
+		return fmt.Errorf("TRUST_MEMBER_CONTRIBUTIONS and TRUST_TRUSTED_CONTRIBUTIONS must satisfy 1 <= member <= trusted")
+	}
+	if c.Trust.DemotionViolations < 1 {
+		return fmt.Errorf("TRUST_DEMOTION_VIOLATIONS must be at least 1")
+	}
+
+	if c.Semantic.Enabled {
+		// HNSW indexes of pgvector support up to 2000 dimensions
+		if c.Semantic.Dimensions < 1 || c.Semantic.Dimensions > 2000 || c.Semantic.EmbedInterval <= 0 {
+			return fmt.Errorf("EMBEDDING_DIMENSIONS must be between 1 and 2000 and EMBEDDING_INTERVAL positive")
+		}
+		if c.LLM.Provider == "azure" && c.LLM.AzureEmbeddingDeployment == "" {
+			return fmt.Errorf("SEMANTIC_SEARCH_ENABLED with LLM_PROVIDER=azure requires AZURE_OPENAI_EMBEDDING_DEPLOYMENT")
+		}
+		if c.Semantic.RetrievalLimit < 0 || c.Semantic.RetrievalLimit > 10 || c.Semantic.MinSimilarity < 0 || c.Semantic.MinSimilarity > 1 {
+			return fmt.Errorf("RAG_TOP_K must be between 0 and 10 and RAG_MIN_SIMILARITY between 0 and 1")
+		}
+	}
+
+	if c.Federation.SigningKey != "" && c.Federation.Community == "" {
+		return fmt.Errorf("FEDERATION_COMMUNITY is required when FEDERATION_SIGNING_KEY is set")
+	}
+
+	if c.Auth.SeedAdminPassword != "" && (len(c.Auth.SeedAdminPassword) < 8 || len(c.Auth.SeedAdminPassword) > 72) {
+		return fmt.Errorf("ADMIN_PASSWORD must be between 8 and 72 characters")
+	}
+
+	if (c.Auth.Google.ClientID == "") != (c.Auth.Google.ClientSecret == "") {
+		return fmt.Errorf("OAUTH_GOOGLE_CLIENT_ID and OAUTH_GOOGLE_CLIENT_SECRET must be set together")
+	}
+	if (c.Auth.GitHub.ClientID == "") != (c.Auth.GitHub.ClientSecret == "") {
+		return fmt.Errorf("OAUTH_GITHUB_CLIENT_ID and OAUTH_GITHUB_CLIENT_SECRET must be set together")
+	}
+
+	if c.Auth.KeyGracePeriod < c.Auth.RefreshTokenTTL {
+		return fmt.Errorf("AUTH_KEY_GRACE_PERIOD must be at least AUTH_REFRESH_TOKEN_TTL so rotation does not invalidate issued tokens")
+	}
+
 	// For development, allow running without database initially
+	if err := c.validateResidency(); err != nil {
+		return err
+	}
+
+	if c.Server.DebugChat && c.Server.Environment != "development" {
+		return fmt.Errorf("DEBUG_CHAT is only allowed in development")
+	}
+
 	if c.Server.Environment == "production" {
 		if c.Database.URL == "" && c.Database.Password == "" {
 			return fmt.Errorf("database URL or password is required in production")
 		}
 		
-		if c.OpenAI.APIKey == "" {
+		if c.LLM.Provider == "openai" && c.OpenAI.APIKey == "" {
 			return fmt.Errorf("OpenAI API key is required in production")
 		}
 	}
@@ -136,6 +974,44 @@ func (c *Config) validate() error {
 	return nil
 }
 
+// validateResidency checks that every community is routed to a region with its
+// own database and, when images are stored, its own Cloudinary account
+func (c *Config) validateResidency() error {
+	r := c.Residency
+	if !regionName.MatchString(r.DefaultRegion) {
+		return fmt.Errorf("RESIDENCY_DEFAULT_REGION must be lowercase letters, digits and dashes, got %q", r.DefaultRegion)
+	}
+
+	regions := map[string]bool{r.DefaultRegion: true}
+	databases := map[string]string{c.GetDatabaseDSN(): r.DefaultRegion}
+	storesImages := c.Storage.CloudinaryURL != "" || c.Storage.CloudName != ""
+	for _, region := range r.Regions {
+		if !regionName.MatchString(region.Name) || regions[region.Name] {
+			return fmt.Errorf("RESIDENCY_REGIONS must be distinct lowercase names other than the default region, got %q", region.Name)
+		}
+		regions[region.Name] = true
+
+		env := regionEnvPrefix(region.Name)
+		if region.DatabaseURL == "" {
+			return fmt.Errorf("%sDATABASE_URL is required for region %s", env, region.Name)
+		}
+		if other, ok := databases[region.DatabaseURL]; ok {
+			return fmt.Errorf("regions %s and %s must not share a database", other, region.Name)
+		}
+		databases[region.DatabaseURL] = region.Name
+		if storesImages && region.CloudinaryURL == "" {
+			return fmt.Errorf("%sCLOUDINARY_URL is required for region %s while image uploads are enabled", env, region.Name)
+		}
+	}
+
+	for community, region := range r.Communities {
+		if community == "" || !regions[region] {
+			return fmt.Errorf("RESIDENCY_COMMUNITIES must map communities to configured regions, got %q=%q", community, region)
+		}
+	}
+	return nil
+}
+
 // GetDatabaseDSN returns the database connection string
 func (c *Config) GetDatabaseDSN() string {
 	if c.Database.URL != "" {
@@ -153,6 +1029,43 @@ func (c *Config) GetDatabaseDSN() string {
 	)
 }
 
+// regionName matches valid region names
+var regionName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// regionEnvPrefix returns the prefix of a region's variables, e.g. RESIDENCY_EU_WEST_
+func regionEnvPrefix(region string) string {
+	return "RESIDENCY_" + strings.ToUpper(strings.ReplaceAll(region, "-", "_")) + "_"
+}
+
+// loadResidency reads the regions from RESIDENCY_REGIONS and their variables,
+// and the communities from RESIDENCY_COMMUNITIES ("community=region" pairs).
+// Malformed pairs are kept with an empty region for validate to report.
+func loadResidency() ResidencyConfig {
+	residency := ResidencyConfig{
+		DefaultRegion: getEnv("RESIDENCY_DEFAULT_REGION", "default"),
+		Communities:   make(map[string]string),
+	}
+	for _, name := range strings.Split(getEnv("RESIDENCY_REGIONS", ""), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		env := regionEnvPrefix(name)
+		residency.Regions = append(residency.Regions, RegionConfig{
+			Name:          name,
+			DatabaseURL:   getEnv(env+"DATABASE_URL", ""),
+			CloudinaryURL: getEnv(env+"CLOUDINARY_URL", ""),
+		})
+	}
+	for _, pair := range strings.Split(getEnv("RESIDENCY_COMMUNITIES", ""), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		community, region, _ := strings.Cut(pair, "=")
+		residency.Communities[strings.TrimSpace(community)] = strings.TrimSpace(region)
+	}
+	return residency
+}
+
 // getEnv gets an environment variable with a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -161,6 +1074,24 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvAsRates gets an environment variable of comma separated key=rate pairs.
+// Rates that are not numbers are returned as -1, which validation rejects.
+func getEnvAsRates(key string) map[string]float64 {
+	rates := make(map[string]float64)
+	for _, pair := range strings.Split(getEnv(key, ""), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(pair, "=")
+		rate, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			rate = -1
+		}
+		rates[strings.TrimSpace(name)] = rate
+	}
+	return rates
+}
+
 // getEnvAsInt gets an environment variable as integer with a default value
 func getEnvAsInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
@@ -170,3 +1101,33 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+// getEnvAsBool gets an environment variable as boolean with a default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsFloat gets an environment variable as float with a default value
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsDuration gets an environment variable as duration (e.g. "15m") with a default value
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
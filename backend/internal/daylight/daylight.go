@@ -0,0 +1,142 @@
+// Package daylight computes sunrise, sunset, twilight and golden hour from the
+// sun's position, without an external service. Times are accurate to about a
+// minute at sea level, which is plenty for planning when to start an outing.
+package daylight
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Sun elevations in degrees the events are defined by
+const (
+	// sunriseElevation accounts for refraction and the radius of the sun's disk
+	sunriseElevation = -0.833
+	civilElevation   = -6.0
+	// Golden hour is the soft light while the sun is between these elevations
+	goldenLowElevation  = -4.0
+	goldenHighElevation = 6.0
+)
+
+// j2000 is Julian day 2451545.0, the epoch of the solar position formulas
+var j2000 = time.Date(2000, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// Period is a stretch of time
+type Period struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Day holds the daylight events of a date at a location, in the location's
+// time zone. Events that do not happen, e.g. sunset during the midnight sun,
+// are nil.
+type Day struct {
+	Date      string     `json:"date"` // YYYY-MM-DD
+	SolarNoon time.Time  `json:"solar_noon"`
+	Sunrise   *time.Time `json:"sunrise,omitempty"`
+	Sunset    *time.Time `json:"sunset,omitempty"`
+	// CivilDawn and CivilDusk bound the time there is enough light outdoors
+	// without a lamp
+	CivilDawn         *time.Time `json:"civil_dawn,omitempty"`
+	CivilDusk         *time.Time `json:"civil_dusk,omitempty"`
+	MorningGoldenHour *Period    `json:"morning_golden_hour,omitempty"`
+	EveningGoldenHour *Period    `json:"evening_golden_hour,omitempty"`
+	DaylightMinutes   int        `json:"daylight_minutes"`
+	PolarDay          bool       `json:"polar_day,omitempty"`   // the sun does not set
+	PolarNight        bool       `json:"polar_night,omitempty"` // the sun does not rise
+}
+
+// Compute returns the daylight events at a location on the calendar date of
+// date in loc
+func Compute(lat, lng float64, date time.Time, loc *time.Location) Day {
+	date = date.In(loc)
+	day := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, time.UTC)
+	n := math.Round(day.Sub(j2000).Hours() / 24)
+
+	// Mean solar noon, solar mean anomaly, equation of the center and ecliptic
+	// longitude give the solar transit and the sun's declination
+	jStar := n - lng/360
+	m := math.Mod(357.5291+0.98560028*jStar, 360)
+	c := 1.9148*sin(m) + 0.0200*sin(2*m) + 0.0003*sin(3*m)
+	lambda := math.Mod(m+c+180+102.9372, 360)
+	transit := jStar + 0.0053*sin(m) - 0.0069*sin(2*lambda)
+	sinDecl := sin(lambda) * sin(23.4397)
+	cosDecl := math.Sqrt(1 - sinDecl*sinDecl)
+
+	// hourAngle returns the fraction of a day between transit and the sun
+	// crossing elevation, and 1 or -1 when it stays above or below it all day
+	hourAngle := func(elevation float64) (float64, int) {
+		cosOmega := (sin(elevation) - sin(lat)*sinDecl) / (cos(lat) * cosDecl)
+		switch {
+		case cosOmega < -1:
+			return 0, 1
+		case cosOmega > 1:
+			return 0, -1
+		}
+		return math.Acos(cosOmega) * 180 / math.Pi / 360, 0
+	}
+	at := func(julian float64) time.Time {
+		return j2000.Add(time.Duration(julian * 24 * float64(time.Hour))).Round(time.Second).In(loc)
+	}
+	crossings := func(elevation float64) (rise, set *time.Time) {
+		omega, always := hourAngle(elevation)
+		if always != 0 {
+			return nil, nil
+		}
+		r, s := at(transit-omega), at(transit+omega)
+		return &r, &s
+	}
+
+	result := Day{
+		Date:      date.Format("2006-01-02"),
+		SolarNoon: at(transit),
+	}
+	omega, always := hourAngle(sunriseElevation)
+	switch always {
+	case 1:
+		result.PolarDay = true
+		result.DaylightMinutes = 24 * 60
+	case -1:
+		result.PolarNight = true
+	default:
+		result.Sunrise, result.Sunset = crossings(sunriseElevation)
+		result.DaylightMinutes = int(math.Round(omega * 2 * 24 * 60))
+	}
+	result.CivilDawn, result.CivilDusk = crossings(civilElevation)
+
+	lowRise, lowSet := crossings(goldenLowElevation)
+	highRise, highSet := crossings(goldenHighElevation)
+	switch {
+	case lowRise != nil && highRise != nil:
+		result.MorningGoldenHour = &Period{Start: *lowRise, End: *highRise}
+		result.EveningGoldenHour = &Period{Start: *highSet, End: *lowSet}
+	case lowRise != nil:
+		// The sun stays low all day, so the light is golden from rise to set
+		result.MorningGoldenHour = &Period{Start: *lowRise, End: *lowSet}
+	}
+	return result
+}
+
+// Zone returns the time zone with an IANA name such as "Europe/Berlin". When
+// name is empty, it returns a fixed zone estimated from the longitude, which
+// can be an hour or more off the official time, and estimated is true.
+func Zone(name string, lng float64) (loc *time.Location, estimated bool, err error) {
+	if name != "" {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, false, fmt.Errorf("unknown time zone %q: %w", name, err)
+		}
+		return loc, false, nil
+	}
+	hours := int(math.Round(lng / 15))
+	return time.FixedZone(fmt.Sprintf("UTC%+03d:00", hours), hours*3600), true, nil
+}
+
+func sin(deg float64) float64 {
+	return math.Sin(deg * math.Pi / 180)
+}
+
+func cos(deg float64) float64 {
+	return math.Cos(deg * math.Pi / 180)
+}
@@ -0,0 +1,108 @@
+package dbmetrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+// startKey is the statement instance key holding the query start time
+const startKey = "dbmetrics:start"
+
+var queries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gorm_queries_total",
+	Help: "Database queries by model and operation.",
+}, []string{"model", "operation"})
+
+var rowsAffected = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gorm_rows_affected_total",
+	Help: "Rows returned or affected by database queries, by model and operation.",
+}, []string{"model", "operation"})
+
+var queryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "gorm_query_errors_total",
+	Help: "Failed database queries by model and operation, excluding record not found.",
+}, []string{"model", "operation"})
+
+var queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "gorm_query_duration_seconds",
+	Help:    "Database query latency by model and operation.",
+	Buckets: prometheus.DefBuckets,
+}, []string{"model", "operation"})
+
+// Plugin is a GORM plugin exporting Prometheus metrics for every query
+type Plugin struct{}
+
+// New creates the metrics plugin; register it with db.Use
+func New() *Plugin {
+	return &Plugin{}
+}
+
+// Name returns the plugin name
+func (p *Plugin) Name() string {
+	return "dbmetrics"
+}
+
+// Initialize registers timing callbacks around each GORM operation
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	register := []struct {
+		operation string
+		before    func(name string, fn func(*gorm.DB)) error
+		after     func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", callbacks.Create().Before("*").Register, callbacks.Create().After("*").Register},
+		{"query", callbacks.Query().Before("*").Register, callbacks.Query().After("*").Register},
+		{"update", callbacks.Update().Before("*").Register, callbacks.Update().After("*").Register},
+		{"delete", callbacks.Delete().Before("*").Register, callbacks.Delete().After("*").Register},
+		{"row", callbacks.Row().Before("*").Register, callbacks.Row().After("*").Register},
+		{"raw", callbacks.Raw().Before("*").Register, callbacks.Raw().After("*").Register},
+	}
+
+	for _, r := range register {
+		if err := r.before("dbmetrics:before_"+r.operation, start); err != nil {
+			return err
+		}
+		if err := r.after("dbmetrics:after_"+r.operation, observe(r.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// start records when the statement began
+func start(db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+// observe returns a callback recording the outcome of an operation
+func observe(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		model := modelLabel(db)
+		queries.WithLabelValues(model, operation).Inc()
+		if db.RowsAffected > 0 {
+			rowsAffected.WithLabelValues(model, operation).Add(float64(db.RowsAffected))
+		}
+		if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			queryErrors.WithLabelValues(model, operation).Inc()
+		}
+		if started, ok := db.InstanceGet(startKey); ok {
+			queryDuration.WithLabelValues(model, operation).Observe(time.Since(started.(time.Time)).Seconds())
+		}
+	}
+}
+
+// modelLabel names the model a statement operates on: the schema name for model
+// queries, the table for table queries and "raw" for raw SQL
+func modelLabel(db *gorm.DB) string {
+	switch {
+	case db.Statement.Schema != nil:
+		return db.Statement.Schema.Name
+	case db.Statement.Table != "":
+		return db.Statement.Table
+	}
+	return "raw"
+}
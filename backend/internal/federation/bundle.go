@@ -0,0 +1,175 @@
+// Package federation exchanges curated activity sets between community instances.
+// Bundles are signed with the exporting community's Ed25519 key and only imported
+// from communities whose public key is trusted.
+package federation
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// BundleVersion is the bundle format written by this instance
+const BundleVersion = 1
+
+var (
+	// ErrUntrustedSource is returned for bundles from a community without a trusted key
+	ErrUntrustedSource = errors.New("bundle source is not trusted")
+	// ErrInvalidSignature is returned when a bundle was not signed by its source's key
+	ErrInvalidSignature = errors.New("bundle signature is invalid")
+	// ErrInvalidBundle is returned for bundles that cannot be decoded
+	ErrInvalidBundle = errors.New("invalid bundle")
+	// ErrUnsupportedVersion is returned for bundles in an unknown format
+	ErrUnsupportedVersion = errors.New("unsupported bundle version")
+)
+
+// Bundle is a set of activities exported by one community
+type Bundle struct {
+	Version    int        `json:"version"`
+	Community  string     `json:"community"` // exporting community
+	ExportedAt time.Time  `json:"exported_at"`
+	Activities []Activity `json:"activities"`
+}
+
+// Activity is an exported activity. OriginCommunity and OriginID identify where the
+// activity was first submitted, which differs from the exporting community for re-exports.
+type Activity struct {
+	ID              uint    `json:"id"` // ID in the exporting community
+	OriginCommunity string  `json:"origin_community"`
+	OriginID        uint    `json:"origin_id"`
+	Name            string  `json:"name"`
+	Description     string  `json:"description"`
+	Category        string  `json:"category"`
+	Latitude        float64 `json:"latitude"`
+	Longitude       float64 `json:"longitude"`
+	Difficulty      string  `json:"difficulty"`
+	Duration        int     `json:"duration"`
+	BestSeason      string  `json:"best_season"`
+	OpeningHours    string  `json:"opening_hours,omitempty"`
+	Media           []Media `json:"media"`
+}
+
+// Media is a manifest entry for an approved image; the files stay where they are hosted
+type Media struct {
+	URL     string `json:"url"`
+	Caption string `json:"caption"`
+}
+
+// Hosted reports whether the media has an http(s) URL, the only kind that is
+// imported: other schemes such as javascript: or data: must never be rendered
+func (m Media) Hosted() bool {
+	u, err := url.Parse(m.URL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Envelope is a signed bundle as exchanged between instances. The signature
+// covers the exact bundle bytes, so the bundle is kept raw.
+type Envelope struct {
+	Bundle    json.RawMessage `json:"bundle"`
+	Signature string          `json:"signature"` // base64 Ed25519 signature
+}
+
+// Signer signs bundles on behalf of this community
+type Signer struct {
+	community string
+	key       ed25519.PrivateKey
+}
+
+// NewSigner creates a signer from a base64 encoded Ed25519 seed
+func NewSigner(community, seed string) (*Signer, error) {
+	raw, err := base64.StdEncoding.DecodeString(seed)
+	if err != nil || len(raw) != ed25519.SeedSize {
+		return nil, fmt.Errorf("signing key must be a base64 encoded %d byte Ed25519 seed", ed25519.SeedSize)
+	}
+	return &Signer{
+		community: community,
+		key:       ed25519.NewKeyFromSeed(raw),
+	}, nil
+}
+
+// Community returns the name bundles are exported under
+func (s *Signer) Community() string {
+	return s.community
+}
+
+// PublicKey returns the base64 public key peers configure to trust this community
+func (s *Signer) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey))
+}
+
+// Sign stamps the bundle with this community and signs it
+func (s *Signer) Sign(bundle *Bundle) (*Envelope, error) {
+	bundle.Version = BundleVersion
+	bundle.Community = s.community
+	raw, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode bundle: %w", err)
+	}
+	return &Envelope{
+		Bundle:    raw,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, raw)),
+	}, nil
+}
+
+// Verifier checks bundles against the public keys of trusted communities
+type Verifier struct {
+	trusted map[string]ed25519.PublicKey
+}
+
+// NewVerifier parses trusted keys given as "community=base64key" pairs separated by commas
+func NewVerifier(trustedKeys string) (*Verifier, error) {
+	v := &Verifier{trusted: make(map[string]ed25519.PublicKey)}
+	for _, pair := range strings.Split(trustedKeys, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		community, key, ok := strings.Cut(pair, "=")
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+		if !ok || err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("trusted key %q must be community=base64 Ed25519 public key", pair)
+		}
+		v.trusted[strings.TrimSpace(community)] = ed25519.PublicKey(raw)
+	}
+	return v, nil
+}
+
+// Len returns the number of trusted communities
+func (v *Verifier) Len() int {
+	return len(v.trusted)
+}
+
+// Open verifies the envelope and returns its bundle
+func (v *Verifier) Open(envelope *Envelope) (*Bundle, error) {
+	var bundle Bundle
+	if err := json.Unmarshal(envelope.Bundle, &bundle); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	if bundle.Version != BundleVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, bundle.Version)
+	}
+
+	key, ok := v.trusted[bundle.Community]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUntrustedSource, bundle.Community)
+	}
+	signature, err := base64.StdEncoding.DecodeString(envelope.Signature)
+	if err != nil || !ed25519.Verify(key, envelope.Bundle, signature) {
+		return nil, ErrInvalidSignature
+	}
+	return &bundle, nil
+}
+
+// GenerateSeed returns a new base64 encoded Ed25519 seed for NewSigner
+func GenerateSeed() (string, error) {
+	_, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(private.Seed()), nil
+}
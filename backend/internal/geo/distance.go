@@ -0,0 +1,40 @@
+package geo
+
+import "math"
+
+// EarthRadiusKM is the mean Earth radius used for distance calculations
+const EarthRadiusKM = 6371.0
+
+// DistanceKM returns the great-circle (Haversine) distance between two points in kilometers
+func DistanceKM(lat1, lng1, lat2, lng2 float64) float64 {
+	dLat := toRadians(lat2 - lat1)
+	dLng := toRadians(lng2 - lng1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return EarthRadiusKM * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// BoundingBox returns the min/max latitude and longitude enclosing a radius around a point.
+// It is used to pre-filter candidates with an index-friendly query before exact distances.
+func BoundingBox(lat, lng, radiusKM float64) (minLat, maxLat, minLng, maxLng float64) {
+	latDelta := radiusKM / EarthRadiusKM * 180 / math.Pi
+	lngDelta := latDelta / math.Max(math.Cos(toRadians(lat)), 0.01)
+
+	return lat - latDelta, lat + latDelta, lng - lngDelta, lng + lngDelta
+}
+
+// BearingDegrees returns the initial compass bearing from the first point to the
+// second, from 0 (north) clockwise to 360
+func BearingDegrees(lat1, lng1, lat2, lng2 float64) float64 {
+	dLng := toRadians(lng2 - lng1)
+	y := math.Sin(dLng) * math.Cos(toRadians(lat2))
+	x := math.Cos(toRadians(lat1))*math.Sin(toRadians(lat2)) -
+		math.Sin(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Cos(dLng)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
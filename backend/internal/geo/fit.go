@@ -0,0 +1,213 @@
+package geo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// FIT protocol constants for the subset needed to extract GPS records
+const (
+	fitRecordMessage     = 20
+	fitFieldTimestamp    = 253
+	fitFieldPositionLat  = 0
+	fitFieldPositionLong = 1
+	fitFieldAltitude     = 2
+	fitFieldEnhancedAlt  = 78
+	fitSemicirclesToDeg  = 180.0 / (1 << 31)
+	fitInvalidSint32     = 0x7FFFFFFF
+	fitInvalidUint16     = 0xFFFF
+	fitInvalidUint32     = 0xFFFFFFFF
+)
+
+// fitEpoch is the FIT timestamp origin (1989-12-31T00:00:00Z)
+var fitEpoch = time.Date(1989, 12, 31, 0, 0, 0, 0, time.UTC)
+
+var errFITTruncated = errors.New("truncated FIT file")
+
+type fitField struct {
+	num  byte
+	size int
+}
+
+type fitDefinition struct {
+	global    uint16
+	order     binary.ByteOrder
+	fields    []fitField
+	devFields int // total size of developer fields in bytes
+}
+
+// ParseFIT extracts GPS record messages from a Garmin FIT activity or course file.
+// Only the fields needed for the track (position, altitude, timestamp) are decoded.
+func ParseFIT(data []byte) (*Track, error) {
+	if len(data) < 12 {
+		return nil, errFITTruncated
+	}
+
+	headerSize := int(data[0])
+	if headerSize < 12 || len(data) < headerSize || string(data[8:12]) != ".FIT" {
+		return nil, errors.New("invalid FIT header")
+	}
+	dataSize := int(binary.LittleEndian.Uint32(data[4:8]))
+	end := headerSize + dataSize
+	if end > len(data) {
+		return nil, errFITTruncated
+	}
+
+	track := &Track{}
+	definitions := make(map[byte]*fitDefinition)
+	var lastTimestamp uint32
+	pos := headerSize
+
+	for pos < end {
+		header := data[pos]
+		pos++
+
+		var local byte
+		var compressedOffset int = -1
+		if header&0x80 != 0 {
+			// Compressed timestamp header: data message with a 5-bit time offset
+			local = (header >> 5) & 0x03
+			compressedOffset = int(header & 0x1F)
+		} else {
+			local = header & 0x0F
+			if header&0x40 != 0 {
+				def, n, err := parseFITDefinition(data[pos:end], header&0x20 != 0)
+				if err != nil {
+					return nil, err
+				}
+				definitions[local] = def
+				pos += n
+				continue
+			}
+		}
+
+		def, ok := definitions[local]
+		if !ok {
+			return nil, fmt.Errorf("FIT data message references undefined local type %d", local)
+		}
+
+		point, timestamp, hasPosition, n, err := parseFITRecord(data[pos:end], def)
+		if err != nil {
+			return nil, err
+		}
+		pos += n
+
+		if def.global != fitRecordMessage {
+			if timestamp != 0 {
+				lastTimestamp = timestamp
+			}
+			continue
+		}
+
+		if compressedOffset >= 0 {
+			timestamp = (lastTimestamp &^ 0x1F) + uint32(compressedOffset)
+			if uint32(compressedOffset) < lastTimestamp&0x1F {
+				timestamp += 0x20
+			}
+		}
+		if timestamp != 0 {
+			lastTimestamp = timestamp
+			t := fitEpoch.Add(time.Duration(timestamp) * time.Second)
+			point.Time = &t
+		}
+
+		if hasPosition {
+			track.Points = append(track.Points, point)
+		}
+	}
+
+	return track, nil
+}
+
+// parseFITDefinition parses a definition message and returns it with the number of bytes consumed
+func parseFITDefinition(data []byte, hasDevFields bool) (*fitDefinition, int, error) {
+	if len(data) < 5 {
+		return nil, 0, errFITTruncated
+	}
+
+	def := &fitDefinition{order: binary.LittleEndian}
+	if data[1] == 1 {
+		def.order = binary.BigEndian
+	}
+	def.global = def.order.Uint16(data[2:4])
+	count := int(data[4])
+	pos := 5
+
+	if len(data) < pos+count*3 {
+		return nil, 0, errFITTruncated
+	}
+	for i := 0; i < count; i++ {
+		def.fields = append(def.fields, fitField{num: data[pos], size: int(data[pos+1])})
+		pos += 3
+	}
+
+	if hasDevFields {
+		if len(data) < pos+1 {
+			return nil, 0, errFITTruncated
+		}
+		devCount := int(data[pos])
+		pos++
+		if len(data) < pos+devCount*3 {
+			return nil, 0, errFITTruncated
+		}
+		for i := 0; i < devCount; i++ {
+			def.devFields += int(data[pos+1])
+			pos += 3
+		}
+	}
+
+	return def, pos, nil
+}
+
+// parseFITRecord decodes the fields of a data message relevant to track points
+func parseFITRecord(data []byte, def *fitDefinition) (Point, uint32, bool, int, error) {
+	var point Point
+	var timestamp uint32
+	var lat, lng int32
+	hasLat, hasLng := false, false
+	pos := 0
+
+	for _, field := range def.fields {
+		if len(data) < pos+field.size {
+			return point, 0, false, 0, errFITTruncated
+		}
+		value := data[pos : pos+field.size]
+		pos += field.size
+
+		switch {
+		case field.num == fitFieldTimestamp && field.size == 4:
+			if v := def.order.Uint32(value); v != fitInvalidUint32 {
+				timestamp = v
+			}
+		case field.num == fitFieldPositionLat && field.size == 4 && def.global == fitRecordMessage:
+			if v := def.order.Uint32(value); v != fitInvalidSint32 {
+				lat, hasLat = int32(v), true
+			}
+		case field.num == fitFieldPositionLong && field.size == 4 && def.global == fitRecordMessage:
+			if v := def.order.Uint32(value); v != fitInvalidSint32 {
+				lng, hasLng = int32(v), true
+			}
+		case field.num == fitFieldAltitude && field.size == 2 && def.global == fitRecordMessage:
+			if v := def.order.Uint16(value); v != fitInvalidUint16 && point.Elevation == nil {
+				alt := float64(v)/5 - 500
+				point.Elevation = &alt
+			}
+		case field.num == fitFieldEnhancedAlt && field.size == 4 && def.global == fitRecordMessage:
+			if v := def.order.Uint32(value); v != fitInvalidUint32 {
+				alt := float64(v)/5 - 500
+				point.Elevation = &alt
+			}
+		}
+	}
+
+	if len(data) < pos+def.devFields {
+		return point, 0, false, 0, errFITTruncated
+	}
+	pos += def.devFields
+
+	point.Lat = float64(lat) * fitSemicirclesToDeg
+	point.Lng = float64(lng) * fitSemicirclesToDeg
+	return point, timestamp, hasLat && hasLng, pos, nil
+}
@@ -0,0 +1,47 @@
+package geo
+
+import "math"
+
+// LineString is a GeoJSON LineString geometry; positions are [lng, lat]
+type LineString struct {
+	Type        string      `json:"type"`
+	Coordinates [][]float64 `json:"coordinates"`
+}
+
+// LineString returns the track as a GeoJSON LineString of at most maxPoints
+// positions, keeping every nth point of longer tracks and always the last one.
+// Coordinates are rounded to 6 decimals, about 10 cm.
+func (t *Track) LineString(maxPoints int) LineString {
+	step := 1
+	if maxPoints > 1 && len(t.Points) > maxPoints {
+		step = (len(t.Points) + maxPoints - 2) / (maxPoints - 1)
+	}
+
+	line := LineString{Type: "LineString", Coordinates: [][]float64{}}
+	for i := 0; i < len(t.Points); i += step {
+		line.Coordinates = append(line.Coordinates, position(t.Points[i]))
+	}
+	if last := len(t.Points) - 1; last > 0 && last%step != 0 {
+		line.Coordinates = append(line.Coordinates, position(t.Points[last]))
+	}
+	return line
+}
+
+// BBox returns the GeoJSON bounding box of the track: [min lng, min lat, max
+// lng, max lat]. Empty tracks have no bounding box.
+func (t *Track) BBox() []float64 {
+	if len(t.Points) == 0 {
+		return nil
+	}
+	box := []float64{t.Points[0].Lng, t.Points[0].Lat, t.Points[0].Lng, t.Points[0].Lat}
+	for _, p := range t.Points[1:] {
+		box[0], box[1] = math.Min(box[0], p.Lng), math.Min(box[1], p.Lat)
+		box[2], box[3] = math.Max(box[2], p.Lng), math.Max(box[3], p.Lat)
+	}
+	return box
+}
+
+// position returns the GeoJSON position of a point
+func position(p Point) []float64 {
+	return []float64{math.Round(p.Lng*1e6) / 1e6, math.Round(p.Lat*1e6) / 1e6}
+}
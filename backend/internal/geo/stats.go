@@ -0,0 +1,197 @@
+package geo
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Pace is how fast a route is covered: a flat speed plus the time for climbing,
+// as in Naismith's rule
+type Pace struct {
+	SpeedKMH float64
+	// ClimbMPerHour is the ascent that adds an hour; zero ignores climbing
+	ClimbMPerHour float64
+}
+
+// Paces of the route types
+var (
+	// PaceHiking is Naismith's rule: 5 km/h plus an hour per 600 m of ascent
+	PaceHiking  = Pace{SpeedKMH: 5, ClimbMPerHour: 600}
+	PaceCycling = Pace{SpeedKMH: 18, ClimbMPerHour: 1000}
+	PaceDriving = Pace{SpeedKMH: 50}
+)
+
+// Stats are the figures of a track
+type Stats struct {
+	DistanceKM     float64
+	ElevationGainM float64
+	ElevationLossM float64
+	Duration       time.Duration
+}
+
+// Stats returns the distance, elevation change and the duration at pace of the track
+func (t *Track) Stats(pace Pace) Stats {
+	stats := Stats{DistanceKM: t.DistanceKM()}
+	stats.ElevationGainM, stats.ElevationLossM = t.elevationChange()
+	stats.Duration = pace.Duration(stats.DistanceKM, stats.ElevationGainM)
+	return stats
+}
+
+// Duration estimates the time to cover a distance with an ascent
+func (p Pace) Duration(distanceKM, gainM float64) time.Duration {
+	if p.SpeedKMH <= 0 {
+		return 0
+	}
+	hours := distanceKM / p.SpeedKMH
+	if p.ClimbMPerHour > 0 {
+		hours += gainM / p.ClimbMPerHour
+	}
+	return time.Duration(hours * float64(time.Hour)).Round(time.Minute)
+}
+
+// CumulativeDistancesKM returns the distance along the track to each point in
+// kilometers; the first point is at 0
+func (t *Track) CumulativeDistancesKM() []float64 {
+	distances := make([]float64, len(t.Points))
+	for i := 1; i < len(t.Points); i++ {
+		prev, cur := t.Points[i-1], t.Points[i]
+		distances[i] = distances[i-1] + DistanceKM(prev.Lat, prev.Lng, cur.Lat, cur.Lng)
+	}
+	return distances
+}
+
+// ElevationLossM returns the summed negative elevation change in meters
+func (t *Track) ElevationLossM() float64 {
+	_, loss := t.elevationChange()
+	return loss
+}
+
+// elevationChange returns the summed positive and negative elevation changes
+// in meters, skipping points without elevation
+func (t *Track) elevationChange() (gain, loss float64) {
+	var last *float64
+	for _, p := range t.Points {
+		if p.Elevation == nil {
+			continue
+		}
+		if last != nil {
+			if delta := *p.Elevation - *last; delta > 0 {
+				gain += delta
+			} else {
+				loss -= delta
+			}
+		}
+		last = p.Elevation
+	}
+	return gain, loss
+}
+
+// Simplify returns the track with the points the Ramer-Douglas-Peucker
+// algorithm keeps: no dropped point is farther than toleranceM meters from the
+// simplified line. The first and last points are always kept.
+func (t *Track) Simplify(toleranceM float64) *Track {
+	if len(t.Points) < 3 || toleranceM <= 0 {
+		return t
+	}
+
+	// Points are projected to meters on a plane through the first point, which
+	// is accurate enough at route scale
+	kmPerDegree := EarthRadiusKM * math.Pi / 180
+	scaleX := kmPerDegree * 1000 * math.Cos(toRadians(t.Points[0].Lat))
+	scaleY := kmPerDegree * 1000
+	xy := make([][2]float64, len(t.Points))
+	for i, p := range t.Points {
+		xy[i] = [2]float64{(p.Lng - t.Points[0].Lng) * scaleX, (p.Lat - t.Points[0].Lat) * scaleY}
+	}
+
+	keep := make([]bool, len(t.Points))
+	keep[0], keep[len(keep)-1] = true, true
+	// Segments still to simplify, as pairs of indexes of kept points
+	stack := [][2]int{{0, len(t.Points) - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+
+		farthest, maxDistance := 0, 0.0
+		for i := first + 1; i < last; i++ {
+			if d := segmentDistance(xy[i], xy[first], xy[last]); d > maxDistance {
+				farthest, maxDistance = i, d
+			}
+		}
+		if maxDistance > toleranceM {
+			keep[farthest] = true
+			stack = append(stack, [2]int{first, farthest}, [2]int{farthest, last})
+		}
+	}
+
+	simplified := &Track{Name: t.Name}
+	for i, p := range t.Points {
+		if keep[i] {
+			simplified.Points = append(simplified.Points, p)
+		}
+	}
+	return simplified
+}
+
+// segmentDistance returns the distance of point p from the segment a-b
+func segmentDistance(p, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	if dx == 0 && dy == 0 {
+		return math.Hypot(p[0]-a[0], p[1]-a[1])
+	}
+	along := ((p[0]-a[0])*dx + (p[1]-a[1])*dy) / (dx*dx + dy*dy)
+	along = math.Max(0, math.Min(1, along))
+	return math.Hypot(p[0]-a[0]-along*dx, p[1]-a[1]-along*dy)
+}
+
+// ErrNoElevation is returned when a track has no points with an elevation
+var ErrNoElevation = errors.New("track has no elevation data")
+
+// ProfilePoint is a point of an elevation profile: the elevation at a distance along the track
+type ProfilePoint struct {
+	DistanceKM float64 `json:"distance_km"`
+	ElevationM float64 `json:"elevation_m"`
+}
+
+// ElevationProfile returns the elevation along the track for drawing a chart.
+// Tracks with more than maxPoints points with an elevation are resampled at
+// maxPoints evenly spaced distances, interpolating between their neighbours.
+// Distances are rounded to meters, elevations to decimeters.
+func (t *Track) ElevationProfile(maxPoints int) ([]ProfilePoint, error) {
+	distances := t.CumulativeDistancesKM()
+	var profile []ProfilePoint
+	for i, p := range t.Points {
+		if p.Elevation != nil {
+			profile = append(profile, ProfilePoint{DistanceKM: distances[i], ElevationM: *p.Elevation})
+		}
+	}
+	if len(profile) == 0 {
+		return nil, ErrNoElevation
+	}
+
+	if maxPoints > 1 && len(profile) > maxPoints {
+		first, last := profile[0].DistanceKM, profile[len(profile)-1].DistanceKM
+		resampled := make([]ProfilePoint, maxPoints)
+		next := 1
+		for i := range resampled {
+			distance := first + (last-first)*float64(i)/float64(maxPoints-1)
+			for next < len(profile)-1 && profile[next].DistanceKM < distance {
+				next++
+			}
+			prev, cur := profile[next-1], profile[next]
+			elevation := cur.ElevationM
+			if span := cur.DistanceKM - prev.DistanceKM; span > 0 {
+				elevation = prev.ElevationM + (cur.ElevationM-prev.ElevationM)*(distance-prev.DistanceKM)/span
+			}
+			resampled[i] = ProfilePoint{DistanceKM: distance, ElevationM: elevation}
+		}
+		profile = resampled
+	}
+
+	for i := range profile {
+		profile[i].DistanceKM = math.Round(profile[i].DistanceKM*1000) / 1000
+		profile[i].ElevationM = math.Round(profile[i].ElevationM*10) / 10
+	}
+	return profile, nil
+}
@@ -0,0 +1,117 @@
+package geo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Point is a single track point in the internal representation shared by all route formats
+type Point struct {
+	Lat       float64    `json:"lat"`
+	Lng       float64    `json:"lng"`
+	Elevation *float64   `json:"ele,omitempty"`
+	Time      *time.Time `json:"time,omitempty"`
+}
+
+// Track is a normalized route track parsed from GPX, TCX, KML or FIT
+type Track struct {
+	Name   string  `json:"name,omitempty"`
+	Points []Point `json:"points"`
+}
+
+// Supported route file formats
+const (
+	FormatGPX = "gpx"
+	FormatTCX = "tcx"
+	FormatKML = "kml"
+	FormatFIT = "fit"
+)
+
+// ErrUnsupportedFormat is returned when a route file format cannot be determined
+var ErrUnsupportedFormat = errors.New("unsupported route file format")
+
+// ErrEmptyTrack is returned when a route file contains no usable points
+var ErrEmptyTrack = errors.New("route file contains no track points")
+
+// ErrTooManyPoints is returned when a track exceeds the point cap of an import
+var ErrTooManyPoints = errors.New("route file contains too many track points")
+
+// DetectFormat determines the route format from the file extension, falling back to content sniffing
+func DetectFormat(filename string, data []byte) (string, error) {
+	switch ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), ".")); ext {
+	case FormatGPX, FormatTCX, FormatKML, FormatFIT:
+		return ext, nil
+	}
+	return SniffFormat(data)
+}
+
+// SniffFormat determines the route format from the content only: the FIT
+// header signature or the root element of the XML formats
+func SniffFormat(data []byte) (string, error) {
+	if len(data) >= 12 && string(data[8:12]) == ".FIT" {
+		return FormatFIT, nil
+	}
+
+	head := data
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	switch {
+	case bytes.Contains(head, []byte("<gpx")):
+		return FormatGPX, nil
+	case bytes.Contains(head, []byte("<TrainingCenterDatabase")):
+		return FormatTCX, nil
+	case bytes.Contains(head, []byte("<kml")):
+		return FormatKML, nil
+	}
+
+	return "", ErrUnsupportedFormat
+}
+
+// ParseTrack parses a route file in any supported format into a Track
+func ParseTrack(filename string, data []byte) (*Track, string, error) {
+	format, err := DetectFormat(filename, data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var track *Track
+	switch format {
+	case FormatGPX:
+		track, err = ParseGPX(data)
+	case FormatTCX:
+		track, err = ParseTCX(data)
+	case FormatKML:
+		track, err = ParseKML(data)
+	case FormatFIT:
+		track, err = ParseFIT(data)
+	}
+	if err != nil {
+		return nil, format, fmt.Errorf("failed to parse %s: %w", format, err)
+	}
+	if len(track.Points) == 0 {
+		return nil, format, ErrEmptyTrack
+	}
+
+	return track, format, nil
+}
+
+// DistanceKM returns the total length of the track in kilometers
+func (t *Track) DistanceKM() float64 {
+	var total float64
+	for i := 1; i < len(t.Points); i++ {
+		prev, cur := t.Points[i-1], t.Points[i]
+		total += DistanceKM(prev.Lat, prev.Lng, cur.Lat, cur.Lng)
+	}
+	return total
+}
+
+// ElevationGainM returns the summed positive elevation change in meters
+func (t *Track) ElevationGainM() float64 {
+	gain, _ := t.elevationChange()
+	return gain
+}
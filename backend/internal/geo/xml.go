@@ -0,0 +1,283 @@
+package geo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type gpxFile struct {
+	Name   string `xml:"metadata>name"`
+	Tracks []struct {
+		Name     string `xml:"name"`
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+	Routes []struct {
+		Name   string     `xml:"name"`
+		Points []gpxPoint `xml:"rtept"`
+	} `xml:"rte"`
+}
+
+type gpxPoint struct {
+	Lat  float64  `xml:"lat,attr"`
+	Lon  float64  `xml:"lon,attr"`
+	Ele  *float64 `xml:"ele"`
+	Time string   `xml:"time"`
+}
+
+// ParseGPX parses GPX 1.0/1.1 tracks and routes
+func ParseGPX(data []byte) (*Track, error) {
+	var doc gpxFile
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	track := &Track{Name: doc.Name}
+	for _, trk := range doc.Tracks {
+		if track.Name == "" {
+			track.Name = trk.Name
+		}
+		for _, seg := range trk.Segments {
+			for _, p := range seg.Points {
+				track.Points = append(track.Points, Point{Lat: p.Lat, Lng: p.Lon, Elevation: p.Ele, Time: parseTime(p.Time)})
+			}
+		}
+	}
+	for _, rte := range doc.Routes {
+		if track.Name == "" {
+			track.Name = rte.Name
+		}
+		for _, p := range rte.Points {
+			track.Points = append(track.Points, Point{Lat: p.Lat, Lng: p.Lon, Elevation: p.Ele, Time: parseTime(p.Time)})
+		}
+	}
+
+	return track, nil
+}
+
+type tcxFile struct {
+	Activities []struct {
+		ID   string `xml:"Id"`
+		Laps []struct {
+			Points []tcxPoint `xml:"Track>Trackpoint"`
+		} `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+	Courses []struct {
+		Name   string     `xml:"Name"`
+		Points []tcxPoint `xml:"Track>Trackpoint"`
+	} `xml:"Courses>Course"`
+}
+
+type tcxPoint struct {
+	Time     string   `xml:"Time"`
+	Lat      *float64 `xml:"Position>LatitudeDegrees"`
+	Lng      *float64 `xml:"Position>LongitudeDegrees"`
+	Altitude *float64 `xml:"AltitudeMeters"`
+}
+
+// gpxDocument is a GPX 1.1 document with a single track, as written by WriteGPX
+type gpxDocument struct {
+	XMLName xml.Name `xml:"http://www.topografix.com/GPX/1/1 gpx"`
+	Version string   `xml:"version,attr"`
+	Creator string   `xml:"creator,attr"`
+	Name    string   `xml:"metadata>name,omitempty"`
+	Track   struct {
+		Name   string          `xml:"name,omitempty"`
+		Points []gpxTrackPoint `xml:"trkseg>trkpt"`
+	} `xml:"trk"`
+}
+
+type gpxTrackPoint struct {
+	Lat  float64  `xml:"lat,attr"`
+	Lon  float64  `xml:"lon,attr"`
+	Ele  *float64 `xml:"ele,omitempty"`
+	Time string   `xml:"time,omitempty"`
+}
+
+// WriteGPX writes the track as a GPX 1.1 document with one track segment, named
+// name or else the track's name
+func (t *Track) WriteGPX(w io.Writer, name, creator string) error {
+	doc := gpxDocument{Version: "1.1", Creator: creator}
+	doc.Name = name
+	if doc.Name == "" {
+		doc.Name = t.Name
+	}
+	doc.Track.Name = doc.Name
+	doc.Track.Points = make([]gpxTrackPoint, len(t.Points))
+	for i, p := range t.Points {
+		doc.Track.Points[i] = gpxTrackPoint{Lat: p.Lat, Lon: p.Lng, Ele: p.Elevation}
+		if p.Time != nil {
+			doc.Track.Points[i].Time = p.Time.UTC().Format(time.RFC3339)
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return fmt.Errorf("failed to encode GPX: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ParseTCX parses Garmin Training Center activities and courses
+func ParseTCX(data []byte) (*Track, error) {
+	var doc tcxFile
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	track := &Track{}
+	add := func(points []tcxPoint) {
+		for _, p := range points {
+			// Indoor or paused samples have no position
+			if p.Lat == nil || p.Lng == nil {
+				continue
+			}
+			track.Points = append(track.Points, Point{Lat: *p.Lat, Lng: *p.Lng, Elevation: p.Altitude, Time: parseTime(p.Time)})
+		}
+	}
+
+	for _, activity := range doc.Activities {
+		for _, lap := range activity.Laps {
+			add(lap.Points)
+		}
+	}
+	for _, course := range doc.Courses {
+		if track.Name == "" {
+			track.Name = course.Name
+		}
+		add(course.Points)
+	}
+
+	return track, nil
+}
+
+// ParseKML parses LineString and gx:Track geometries from KML placemarks.
+// Malformed XML and invalid coordinates fail the whole file, naming the placemark.
+func ParseKML(data []byte) (*Track, error) {
+	decoder := xml.NewDecoder(strings.NewReader(string(data)))
+	track := &Track{}
+
+	var path []string
+	var whens []string
+	coordIndex := 0
+	// placemark is the number of the current placemark, counted from 1
+	placemark := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if placemark > 0 {
+				return nil, fmt.Errorf("invalid KML in placemark %d: %w", placemark, err)
+			}
+			return nil, fmt.Errorf("invalid KML: %w", err)
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name.Local)
+			// Timestamps of gx:Track belong to the coordinates of their own placemark
+			if t.Name.Local == "Placemark" {
+				placemark++
+				whens, coordIndex = nil, 0
+			}
+		case xml.EndElement:
+			if len(path) > 0 {
+				path = path[:len(path)-1]
+			}
+		case xml.CharData:
+			if len(path) == 0 {
+				continue
+			}
+			text := strings.TrimSpace(string(t))
+			if text == "" {
+				continue
+			}
+
+			switch current := path[len(path)-1]; {
+			case current == "name" && track.Name == "" && len(path) >= 2 && path[len(path)-2] == "Placemark":
+				track.Name = text
+			case current == "coordinates" && contains(path, "LineString"):
+				for _, tuple := range strings.Fields(text) {
+					point, err := parseKMLTuple(strings.Split(tuple, ","))
+					if err != nil {
+						return nil, fmt.Errorf("placemark %d: %w", placemark, err)
+					}
+					track.Points = append(track.Points, point)
+				}
+			case current == "when":
+				whens = append(whens, text)
+			case current == "coord":
+				point, err := parseKMLTuple(strings.Fields(text))
+				if err != nil {
+					return nil, fmt.Errorf("placemark %d: %w", placemark, err)
+				}
+				if coordIndex < len(whens) {
+					point.Time = parseTime(whens[coordIndex])
+				}
+				coordIndex++
+				track.Points = append(track.Points, point)
+			}
+		}
+	}
+
+	return track, nil
+}
+
+// parseKMLTuple parses a "lng,lat[,alt]" tuple
+func parseKMLTuple(parts []string) (Point, error) {
+	if len(parts) < 2 {
+		return Point{}, fmt.Errorf("invalid KML coordinate %q", strings.Join(parts, ","))
+	}
+
+	lng, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("invalid KML longitude: %w", err)
+	}
+	lat, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("invalid KML latitude: %w", err)
+	}
+
+	point := Point{Lat: lat, Lng: lng}
+	if len(parts) > 2 {
+		alt, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return Point{}, fmt.Errorf("invalid KML altitude: %w", err)
+		}
+		point.Elevation = &alt
+	}
+	return point, nil
+}
+
+func contains(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTime parses RFC 3339 timestamps, returning nil when absent or invalid
+func parseTime(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+	if err != nil {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,22 @@
+// Package geocode resolves place names such as "Boulder, CO" to coordinates,
+// so users can search near a place instead of typing latitude and longitude.
+package geocode
+
+import "context"
+
+// Place is a geocoding result
+type Place struct {
+	// Name is the full name of the place, e.g. "Boulder, Boulder County, Colorado, United States"
+	Name string  `json:"name"`
+	Lat  float64 `json:"lat"`
+	Lng  float64 `json:"lng"`
+	// Type is the kind of place, e.g. city, peak or park
+	Type string `json:"type,omitempty"`
+	// BoundingBox is south, north, west and east, when known
+	BoundingBox []float64 `json:"bounding_box,omitempty"`
+}
+
+// Geocoder looks up places by name, best matches first
+type Geocoder interface {
+	Search(ctx context.Context, query string, limit int) ([]Place, error)
+}
@@ -0,0 +1,143 @@
+package geocode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"community-chatbot/internal/apperr"
+)
+
+// nominatimMaxWait is how long a lookup waits for its turn before failing with
+// ErrBusy, so a burst of lookups does not queue for minutes
+const nominatimMaxWait = 5 * time.Second
+
+// ErrBusy is returned when too many lookups are queued; retrying shortly succeeds
+var ErrBusy = apperr.New(apperr.RateLimited, "place lookups are busy, try again in a few seconds")
+
+// NominatimClient looks up places with a Nominatim server, the OpenStreetMap
+// geocoder. The public instance allows one request per second from an
+// application that identifies itself, so requests are spaced by minInterval
+// and carry a User-Agent.
+type NominatimClient struct {
+	endpoint    string
+	userAgent   string
+	minInterval time.Duration
+	httpClient  *http.Client
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// NewNominatimClient creates a client for the Nominatim server at baseURL,
+// e.g. https://nominatim.openstreetmap.org
+func NewNominatimClient(baseURL, userAgent string, minInterval time.Duration) *NominatimClient {
+	return &NominatimClient{
+		endpoint:    strings.TrimRight(baseURL, "/"),
+		userAgent:   userAgent,
+		minInterval: minInterval,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type nominatimPlace struct {
+	DisplayName string   `json:"display_name"`
+	Lat         string   `json:"lat"`
+	Lon         string   `json:"lon"`
+	Type        string   `json:"type"`
+	AddressType string   `json:"addresstype"`
+	BoundingBox []string `json:"boundingbox"`
+}
+
+// Search returns up to limit places matching query
+func (c *NominatimClient) Search(ctx context.Context, query string, limit int) ([]Place, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("format", "jsonv2")
+	params.Set("limit", strconv.Itoa(limit))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("nominatim request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("nominatim returned %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var results []nominatimPlace
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, fmt.Errorf("failed to decode nominatim response: %w", err)
+	}
+
+	places := make([]Place, 0, len(results))
+	for _, result := range results {
+		lat, errLat := strconv.ParseFloat(result.Lat, 64)
+		lng, errLng := strconv.ParseFloat(result.Lon, 64)
+		if errLat != nil || errLng != nil {
+			continue
+		}
+		place := Place{Name: result.DisplayName, Lat: lat, Lng: lng, Type: result.AddressType}
+		if place.Type == "" {
+			place.Type = result.Type
+		}
+		if len(result.BoundingBox) == 4 {
+			box := make([]float64, 4)
+			for i, v := range result.BoundingBox {
+				if box[i], err = strconv.ParseFloat(v, 64); err != nil {
+					box = nil
+					break
+				}
+			}
+			place.BoundingBox = box
+		}
+		places = append(places, place)
+	}
+	return places, nil
+}
+
+// wait blocks until the next request may be sent. It returns ErrBusy without
+// taking a turn when that is more than nominatimMaxWait away.
+func (c *NominatimClient) wait(ctx context.Context) error {
+	c.mu.Lock()
+	now := time.Now()
+	at := c.next
+	if at.Before(now) {
+		at = now
+	}
+	if at.Sub(now) > nominatimMaxWait {
+		c.mu.Unlock()
+		return ErrBusy
+	}
+	c.next = at.Add(c.minInterval)
+	c.mu.Unlock()
+
+	if delay := time.Until(at); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return nil
+}
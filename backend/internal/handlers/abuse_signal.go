@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"log"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AbuseSignalHandler handles the review of suspected abuse
+type AbuseSignalHandler struct {
+	signals *services.AbuseSignalService
+}
+
+// NewAbuseSignalHandler creates a new abuse signal handler
+func NewAbuseSignalHandler(signals *services.AbuseSignalService) *AbuseSignalHandler {
+	return &AbuseSignalHandler{
+		signals: signals,
+	}
+}
+
+// ListAbuseSignals returns a paginated list of abuse signals, newest first,
+// such as clients slowed down or blocked for flooding the chat (admin only)
+//
+// Query parameters: kind (message_flood or message_flood_block), page, page_size
+//
+// Returns:
+//   - 200: Abuse signals with pagination metadata
+//   - 500: Internal server error
+func (h *AbuseSignalHandler) ListAbuseSignals(c *fiber.Ctx) error {
+	page, pageSize := parsePagination(c)
+
+	signals, total, err := h.signals.List(c.UserContext(), c.Query("kind"), page, pageSize)
+	if err != nil {
+		log.Printf("[ERROR] List abuse signals: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list abuse signals"))
+	}
+
+	return c.JSON(models.CreateSuccessResponseWithMeta(signals, &models.MetaData{
+		TotalCount: int(total),
+		Page:       page,
+		PageSize:   pageSize,
+	}))
+}
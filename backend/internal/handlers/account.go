@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"log"
+	"time"
+
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AccountHandler handles the authenticated user's management of their account
+type AccountHandler struct {
+	accounts *services.AccountService
+}
+
+// NewAccountHandler creates a new account handler
+func NewAccountHandler(accounts *services.AccountService) *AccountHandler {
+	return &AccountHandler{
+		accounts: accounts,
+	}
+}
+
+// profileRequest is the body of UpdateProfile
+type profileRequest struct {
+	Name string `json:"name" validate:"max=255"`
+}
+
+// emailChangeRequest is the body of RequestEmailChange
+type emailChangeRequest struct {
+	Email           string `json:"email" validate:"required,email,max=255"`
+	CurrentPassword string `json:"current_password" validate:"required"`
+}
+
+// emailChangeResponse tells until when the confirmation link is valid
+type emailChangeResponse struct {
+	Email     string    `json:"email"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// GetAccount returns the authenticated user's account
+//
+// Returns:
+//   - 200: User
+//   - 404: Account deleted
+//   - 500: Internal server error
+func (h *AccountHandler) GetAccount(c *fiber.Ctx) error {
+	userID, _ := middleware.UserID(c)
+	user, err := h.accounts.User(c.UserContext(), userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(models.CreateSuccessResponse(user))
+}
+
+// UpdateAccount updates the authenticated user's profile
+//
+// Request body: {"name": "Alex"}
+//
+// Returns:
+//   - 200: Updated user
+//   - 400: Invalid input data
+//   - 404: Account deleted
+//   - 500: Internal server error
+func (h *AccountHandler) UpdateAccount(c *fiber.Ctx) error {
+	var body profileRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	userID, _ := middleware.UserID(c)
+	user, err := h.accounts.UpdateProfile(c.UserContext(), userID, body.Name)
+	if err != nil {
+		return err
+	}
+	return c.JSON(models.CreateSuccessResponse(user))
+}
+
+// RequestEmailChange sends a confirmation link to a new email address and a
+// notice to the current one; the email changes once the link is followed. It
+// needs the current password and cannot be used with an API key.
+//
+// Request body: {"email": "new@example.com", "current_password": "..."}
+//
+// Returns:
+//   - 202: New address and when the link expires
+//   - 400: Invalid email or the current one
+//   - 403: Wrong current password, account without a password or API key
+//   - 409: Email already registered
+//   - 500: Internal server error
+func (h *AccountHandler) RequestEmailChange(c *fiber.Ctx) error {
+	var body emailChangeRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	userID, _ := middleware.UserID(c)
+	expiresAt, err := h.accounts.RequestEmailChange(c.UserContext(), userID, body.Email, body.CurrentPassword)
+	if err != nil {
+		return err
+	}
+	log.Printf("[ACCOUNT] User %d requested an email change", userID)
+	return c.Status(fiber.StatusAccepted).JSON(models.CreateSuccessResponse(emailChangeResponse{Email: body.Email, ExpiresAt: expiresAt}))
+}
+
+// ConfirmEmailChange changes a user's email to the address a confirmation
+// token was sent to
+//
+// Request body: {"token": "..."}
+//
+// Returns:
+//   - 200: Updated user
+//   - 400: Invalid, expired or already used token
+//   - 409: Email registered by another account in the meantime
+//   - 500: Internal server error
+func (h *AccountHandler) ConfirmEmailChange(c *fiber.Ctx) error {
+	var body struct {
+		Token string `json:"token" validate:"required"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	user, err := h.accounts.ConfirmEmailChange(c.UserContext(), body.Token)
+	if err != nil {
+		return err
+	}
+	log.Printf("[ACCOUNT] User %d confirmed their new email", user.ID)
+	return c.JSON(models.CreateSuccessResponse(user))
+}
+
+// DeleteAccount deletes the authenticated user's account. Conversations are
+// kept anonymized and submitted activities stay without a submitter; personal
+// data, favorites, notifications and API keys are removed. It cannot be used
+// with an API key.
+//
+// Returns:
+//   - 200: Account deleted
+//   - 403: API key used
+//   - 404: Account already deleted
+//   - 500: Internal server error
+func (h *AccountHandler) DeleteAccount(c *fiber.Ctx) error {
+	userID, _ := middleware.UserID(c)
+	if err := h.accounts.Delete(c.UserContext(), userID); err != nil {
+		return err
+	}
+	log.Printf("[ACCOUNT] User %d deleted their account", userID)
+	return c.JSON(models.CreateMessageResponse("account deleted"))
+}
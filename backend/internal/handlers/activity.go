@@ -0,0 +1,571 @@
+package handlers
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/residency"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ActivityHandler handles activity endpoints
+type ActivityHandler struct {
+	activities *services.ActivityService
+	similarity *services.SimilarityService
+	semantic   *services.SemanticSearchService
+	reviews    *services.ReviewService
+	history    *services.LocationHistoryService
+	favorites  *services.FavoriteService
+	weather    *services.WeatherService
+	stats      *services.ActivityStatsService
+	staleAfter time.Duration
+}
+
+// NewActivityHandler creates a new activity handler; semantic is nil when
+// semantic search is disabled
+func NewActivityHandler(activities *services.ActivityService, similarity *services.SimilarityService, semantic *services.SemanticSearchService, reviews *services.ReviewService, history *services.LocationHistoryService, favorites *services.FavoriteService, weather *services.WeatherService, stats *services.ActivityStatsService, staleAfter time.Duration) *ActivityHandler {
+	return &ActivityHandler{
+		activities: activities,
+		similarity: similarity,
+		semantic:   semantic,
+		reviews:    reviews,
+		history:    history,
+		favorites:  favorites,
+		weather:    weather,
+		stats:      stats,
+		staleAfter: staleAfter,
+	}
+}
+
+// ListActivities returns a paginated list of approved activities
+//
+// Query parameters: category (ID, slug or name; includes subcategories), difficulty,
+// sort (newest, the default, or popular), page, page_size, include_pending (moderators only)
+//
+// Returns:
+//   - 200: Activities with pagination metadata
+//   - 400: Unsupported sort order
+//   - 500: Internal server error
+func (h *ActivityHandler) ListActivities(c *fiber.Ctx) error {
+	page, pageSize := parsePagination(c)
+	filters := services.ActivityFilters{
+		Category:       c.Query("category"),
+		Difficulty:     c.Query("difficulty"),
+		IncludePending: middleware.HasPermission(c, models.PermissionModerate) && c.QueryBool("include_pending"),
+		Sort:           c.Query("sort"),
+	}
+
+	activities, total, err := h.activities.List(c.UserContext(), filters, page, pageSize)
+	if errors.Is(err, services.ErrInvalidActivitySort) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	}
+	if err != nil {
+		log.Printf("[ERROR] List activities: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list activities"))
+	}
+
+	marked := make([]*models.Activity, len(activities))
+	for i := range activities {
+		activities[i].ApplyFreshness(h.staleAfter)
+		marked[i] = &activities[i]
+	}
+	h.markFavorites(c, marked...)
+
+	return c.JSON(models.CreateSuccessResponseWithMeta(activities, &models.MetaData{
+		TotalCount: int(total),
+		Page:       page,
+		PageSize:   pageSize,
+	}))
+}
+
+// exportContentTypes are the content types of activity export formats
+var exportContentTypes = map[string]string{
+	services.ExportGeoJSON: "application/geo+json",
+	services.ExportCSV:     "text/csv; charset=utf-8",
+}
+
+// ExportActivities streams approved activities as a download for other community
+// tools and mapping apps. The export is written as it is read, so a failure
+// partway through cuts it off.
+//
+// Query parameters: format (geojson, the default, or csv), category, difficulty,
+// include_pending (moderators only)
+//
+// Returns:
+//   - 200: GeoJSON FeatureCollection or CSV with a header row
+//   - 400: Unsupported format
+//   - 500: Internal server error
+func (h *ActivityHandler) ExportActivities(c *fiber.Ctx) error {
+	format := c.Query("format", services.ExportGeoJSON)
+	filters := services.ActivityFilters{
+		Category:       c.Query("category"),
+		Difficulty:     c.Query("difficulty"),
+		IncludePending: middleware.HasPermission(c, models.PermissionModerate) && c.QueryBool("include_pending"),
+	}
+
+	// The export is read after the handler returns, so only the data residency region is kept
+	export, err := h.activities.Export(residency.Detach(c.UserContext()), filters, format)
+	if errors.Is(err, services.ErrUnsupportedExportFormat) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	}
+	if err != nil {
+		log.Printf("[ERROR] Export activities: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to export activities"))
+	}
+
+	c.Set(fiber.HeaderContentType, exportContentTypes[format])
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="activities.%s"`, format))
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := export(w); err != nil {
+			log.Printf("[ERROR] Export activities: %v", err)
+		}
+		w.Flush()
+	})
+	return nil
+}
+
+// GetNearby returns approved activities near a point, closest first. Searches by
+// users who opted in to location history are recorded as coarse areas.
+//
+// Query parameters: lat, lng (required), radius_km (default 10, max 200), category, difficulty, limit,
+// weather (true adds the current weather at each activity when forecasts are enabled)
+//
+// Returns:
+//   - 200: Activities with their distance in kilometers
+//   - 400: Missing or invalid coordinates
+//   - 500: Internal server error
+func (h *ActivityHandler) GetNearby(c *fiber.Ctx) error {
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+	if errLat != nil || errLng != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("lat and lng must be valid coordinates"))
+	}
+
+	radius := c.QueryFloat("radius_km", 10)
+	if radius <= 0 || radius > 200 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("radius_km must be between 0 and 200"))
+	}
+
+	limit := c.QueryInt("limit", 20)
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	nearby, err := h.activities.Nearby(c.UserContext(), services.NearbyQuery{
+		Lat:        lat,
+		Lng:        lng,
+		RadiusKM:   radius,
+		Category:   c.Query("category"),
+		Difficulty: c.Query("difficulty"),
+		Limit:      limit,
+	})
+	if err != nil {
+		log.Printf("[ERROR] Nearby activities: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to search nearby activities"))
+	}
+
+	marked := make([]*models.Activity, len(nearby))
+	for i := range nearby {
+		nearby[i].ApplyFreshness(h.staleAfter)
+		marked[i] = &nearby[i].Activity
+	}
+	h.markFavorites(c, marked...)
+
+	// Weather is supplementary; activities it could not be looked up for have none
+	if h.weather != nil && c.QueryBool("weather") {
+		locations := make([]models.Location, len(nearby))
+		for i := range nearby {
+			locations[i] = models.Location{Lat: nearby[i].Latitude, Lng: nearby[i].Longitude}
+		}
+		for i, conditions := range h.weather.Current(c.UserContext(), locations) {
+			nearby[i].Weather = conditions
+		}
+	}
+
+	if userID, ok := middleware.UserID(c); ok {
+		if _, err := h.history.Record(c.UserContext(), userID, models.Location{Lat: lat, Lng: lng}); err != nil {
+			log.Printf("[ERROR] Record location history: %v", err)
+		}
+	}
+
+	return c.JSON(models.CreateSuccessResponse(nearby))
+}
+
+// SearchActivities returns approved activities matching a full-text search,
+// best matches first, with matching words in bold in the headline and snippet
+//
+// Query parameters: q (required; supports "quoted phrases", or and -word), category,
+// difficulty, lat and lng with radius_km (default 25, max 200), page, page_size
+//
+// Returns:
+//   - 200: Search results with pagination metadata
+//   - 400: Missing query or invalid coordinates
+//   - 500: Internal server error
+func (h *ActivityHandler) SearchActivities(c *fiber.Ctx) error {
+	text := c.Query("q")
+	if text == "" || len(text) > 200 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("q is required and must be at most 200 characters"))
+	}
+
+	page, pageSize := parsePagination(c)
+	query := services.SearchQuery{
+		Text:       text,
+		Category:   c.Query("category"),
+		Difficulty: c.Query("difficulty"),
+		Page:       page,
+		PageSize:   pageSize,
+	}
+	if c.Query("lat") != "" || c.Query("lng") != "" {
+		lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+		lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+		if errLat != nil || errLng != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("lat and lng must be valid coordinates"))
+		}
+		query.Near = &models.Location{Lat: lat, Lng: lng}
+		query.RadiusKM = c.QueryFloat("radius_km", 25)
+		if query.RadiusKM <= 0 || query.RadiusKM > 200 {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("radius_km must be between 0 and 200"))
+		}
+	}
+
+	results, total, err := h.activities.Search(c.UserContext(), query)
+	if err != nil {
+		log.Printf("[ERROR] Search activities: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to search activities"))
+	}
+
+	marked := make([]*models.Activity, len(results))
+	for i := range results {
+		results[i].ApplyFreshness(h.staleAfter)
+		marked[i] = &results[i].Activity
+	}
+	h.markFavorites(c, marked...)
+
+	return c.JSON(models.CreateSuccessResponseWithMeta(results, &models.MetaData{
+		TotalCount: int(total),
+		Page:       page,
+		PageSize:   pageSize,
+	}))
+}
+
+// SemanticSearch returns approved activities closest in meaning to a
+// description such as "somewhere quiet with waterfalls", most similar first
+//
+// Query parameters: q (required), category, difficulty, limit (default 10, max 50)
+//
+// Returns:
+//   - 200: Activities with their similarity to the query
+//   - 400: Missing query or invalid limit
+//   - 500: Internal server error
+func (h *ActivityHandler) SemanticSearch(c *fiber.Ctx) error {
+	text := c.Query("q")
+	if text == "" || len(text) > 500 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("q is required and must be at most 500 characters"))
+	}
+	limit := c.QueryInt("limit", 10)
+	if limit < 1 || limit > 50 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("limit must be between 1 and 50"))
+	}
+
+	results, err := h.semantic.Search(c.UserContext(), services.SemanticQuery{
+		Text:       text,
+		Category:   c.Query("category"),
+		Difficulty: c.Query("difficulty"),
+		Limit:      limit,
+	})
+	if err != nil {
+		log.Printf("[ERROR] Semantic search: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to search activities"))
+	}
+
+	marked := make([]*models.Activity, len(results))
+	for i := range results {
+		results[i].ApplyFreshness(h.staleAfter)
+		marked[i] = &results[i].Activity
+	}
+	h.markFavorites(c, marked...)
+
+	return c.JSON(models.CreateSuccessResponse(results))
+}
+
+// GetActivity returns a single activity with its approved images, routes and review
+// highlights. Activities pending moderation are only shown to moderators and to
+// their submitter. Moderators may add include_pending=true to see unapproved images too.
+//
+// Returns:
+//   - 200: Activity details
+//   - 400: Invalid activity ID
+//   - 404: Activity not found or not approved
+func (h *ActivityHandler) GetActivity(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	userID, _ := middleware.UserID(c)
+	viewer := services.ActivityViewer{UserID: userID, Moderator: middleware.HasPermission(c, models.PermissionModerate)}
+	activity, err := h.activities.Get(c.UserContext(), id, viewer, c.QueryBool("include_pending"))
+	if err != nil {
+		return h.activityError(id, err)
+	}
+
+	// Review highlights are supplementary; the activity is still returned without them
+	if activity.Reviews, err = h.reviews.Summary(c.UserContext(), id, 5); err != nil {
+		log.Printf("[ERROR] Review summary for activity %d: %v", id, err)
+	}
+
+	// Views of pending activities by moderators do not make them trend
+	if activity.Approved {
+		if err := h.stats.RecordView(c.UserContext(), id, viewClient(c)); err != nil {
+			log.Printf("[ERROR] Count view of activity %d: %v", id, err)
+		}
+	}
+
+	activity.ApplyFreshness(h.staleAfter)
+	h.markFavorites(c, activity)
+	return c.JSON(models.CreateSuccessResponse(activity))
+}
+
+// GetTrending returns the activities trending this week by their page views,
+// new favorites, chat mentions and short link scans, recent days weighing most
+//
+// Query parameters: category (slug), limit (default 10, max 50)
+//
+// Returns:
+//   - 200: Activities with their popularity_score and their views, favorites,
+//     mentions and scans of the last 7 days, highest score first
+//   - 500: Internal server error
+func (h *ActivityHandler) GetTrending(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 10)
+	if limit <= 0 || limit > 50 {
+		limit = 10
+	}
+
+	trending, err := h.stats.Trending(c.UserContext(), c.Query("category"), limit)
+	if err != nil {
+		return err
+	}
+	for i := range trending {
+		trending[i].ApplyFreshness(h.staleAfter)
+	}
+	return c.JSON(models.CreateSuccessResponse(trending))
+}
+
+// CreateActivity creates a new activity owned by the authenticated user, pending
+// approval unless the user is trusted
+//
+// Returns:
+//   - 201: Successfully created activity
+//   - 400: Invalid input data
+//   - 500: Internal server error
+func (h *ActivityHandler) CreateActivity(c *fiber.Ctx) error {
+	activity, err := parseActivity(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	}
+
+	activity.UserID, _ = middleware.UserID(c)
+	if err := h.activities.Create(c.UserContext(), activity); err != nil {
+		return fmt.Errorf("create activity: %w", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.CreateSuccessResponse(activity))
+}
+
+// CreateVariant creates a seasonal variant of an activity owned by the
+// authenticated user, e.g. the winter route of a summer trail, at the activity's
+// location. The body is an activity with its seasons; the location is ignored.
+//
+// Returns:
+//   - 201: Successfully created variant
+//   - 400: Invalid ID or input data, or seasons missing
+//   - 403: Activity submitted by another user
+//   - 404: Activity not found
+//   - 409: Another variant is offered in one of the seasons
+func (h *ActivityHandler) CreateVariant(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	variant, err := parseActivity(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	}
+
+	variant.UserID, _ = middleware.UserID(c)
+	if err := h.activities.CreateVariant(c.UserContext(), id, variant); err != nil {
+		return h.activityError(id, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.CreateSuccessResponse(variant))
+}
+
+// UpdateActivity replaces the editable fields of an activity; moderators can edit any activity
+//
+// Returns:
+//   - 200: Updated activity
+//   - 400: Invalid ID or input data
+//   - 403: Activity submitted by another user
+//   - 404: Activity not found
+func (h *ActivityHandler) UpdateActivity(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	changes, err := parseActivity(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	}
+
+	userID, _ := middleware.UserID(c)
+	activity, err := h.activities.Update(c.UserContext(), id, userID, changes)
+	if err != nil {
+		return h.activityError(id, err)
+	}
+
+	activity.ApplyFreshness(h.staleAfter)
+	return c.JSON(models.CreateSuccessResponse(activity))
+}
+
+// DeleteActivity soft-deletes an activity; moderators can delete any activity
+//
+// Returns:
+//   - 200: Activity deleted
+//   - 400: Invalid activity ID
+//   - 403: Activity submitted by another user
+//   - 404: Activity not found
+func (h *ActivityHandler) DeleteActivity(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	userID, _ := middleware.UserID(c)
+	if err := h.activities.Delete(c.UserContext(), id, userID); err != nil {
+		return h.activityError(id, err)
+	}
+
+	return c.JSON(models.CreateMessageResponse("activity deleted"))
+}
+
+// GetSimilar returns activities similar to the given one ("you might also like"),
+// leaving out the activities a signed-in user already saved
+//
+// Query parameters: limit (default 5, max 20)
+//
+// Returns:
+//   - 200: List of similar activities ordered by score
+//   - 400: Invalid activity ID
+//   - 404: Activity not found
+func (h *ActivityHandler) GetSimilar(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	limit := c.QueryInt("limit", 5)
+	if limit <= 0 || limit > 20 {
+		limit = 5
+	}
+
+	var saved []uint
+	if userID, ok := middleware.UserID(c); ok {
+		var err error
+		if saved, err = h.favorites.IDs(c.UserContext(), userID); err != nil {
+			return err
+		}
+	}
+
+	similar, err := h.similarity.FindSimilar(c.UserContext(), id, limit, saved)
+	if err != nil {
+		return h.activityError(id, err)
+	}
+
+	marked := make([]*models.Activity, len(similar))
+	for i := range similar {
+		similar[i].ApplyFreshness(h.staleAfter)
+		marked[i] = &similar[i].Activity
+	}
+	h.markFavorites(c, marked...)
+
+	return c.JSON(models.CreateSuccessResponse(similar))
+}
+
+// viewClient identifies the client of a view: the signed-in user, or else the IP
+func viewClient(c *fiber.Ctx) string {
+	if userID, ok := middleware.UserID(c); ok {
+		return fmt.Sprintf("user:%d", userID)
+	}
+	return "ip:" + c.IP()
+}
+
+// markFavorites sets IsFavorited on activities returned to a signed-in user.
+// Favorites are supplementary, so a failure leaves the flag unset.
+func (h *ActivityHandler) markFavorites(c *fiber.Ctx, activities ...*models.Activity) {
+	userID, ok := middleware.UserID(c)
+	if !ok {
+		return
+	}
+	if err := h.favorites.Mark(c.UserContext(), userID, activities); err != nil {
+		log.Printf("[ERROR] Mark favorites for user %d: %v", userID, err)
+	}
+}
+
+// activityID parses the :id route parameter
+func activityID(c *fiber.Ctx) (uint, bool) {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// parseActivity binds the request body to the editable activity fields and
+// validates them against the model's validate tags
+func parseActivity(c *fiber.Ctx) (*models.Activity, error) {
+	var body models.Activity
+	if err := c.BodyParser(&body); err != nil {
+		return nil, errors.New("invalid request body")
+	}
+
+	// Only copy client-editable fields; ID, owner and approval are server-controlled
+	activity := &models.Activity{
+		Name:         body.Name,
+		Description:  body.Description,
+		Category:     body.Category,
+		CategoryID:   body.CategoryID,
+		Latitude:     body.Latitude,
+		Longitude:    body.Longitude,
+		Difficulty:   body.Difficulty,
+		Duration:     body.Duration,
+		BestSeason:   body.BestSeason,
+		OpeningHours: body.OpeningHours,
+		Seasons:      body.Seasons,
+	}
+	if err := validate.Struct(activity); err != nil {
+		return nil, errors.New(validationMessage(err))
+	}
+
+	return activity, nil
+}
+
+// activityError hands service errors to the app's error handler, which maps
+// them to HTTP responses by kind
+func (h *ActivityHandler) activityError(id uint, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperr.Wrap(apperr.NotFound, "activity not found", err)
+	}
+	return fmt.Errorf("activity %d: %w", id, err)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ActivityImportHandler handles bulk imports of activities from moderators' trail lists
+type ActivityImportHandler struct {
+	imports *services.ActivityImportService
+}
+
+// NewActivityImportHandler creates a new activity import handler
+func NewActivityImportHandler(imports *services.ActivityImportService) *ActivityImportHandler {
+	return &ActivityImportHandler{
+		imports: imports,
+	}
+}
+
+// ImportActivities imports and publishes activities, skipping invalid and
+// duplicate rows; the valid rows are inserted together (admin only)
+//
+// Request body: CSV with a header row (Content-Type: text/csv) or a GeoJSON
+// FeatureCollection (Content-Type: application/geo+json or application/json)
+//
+// Query parameters: dry_run (validate without importing), mapping (column=field
+// pairs separated by commas, e.g. "Trail=name,Type=category")
+//
+// Returns:
+//   - 200: Import counts and the rows that were skipped
+//   - 400: Unreadable body, invalid mapping or too many rows
+//   - 415: Unsupported content type
+//   - 500: Internal server error
+func (h *ActivityImportHandler) ImportActivities(c *fiber.Ctx) error {
+	var format string
+	switch contentType := c.Get(fiber.HeaderContentType); {
+	case strings.HasPrefix(contentType, "text/csv"):
+		format = services.ActivityImportCSV
+	case strings.HasPrefix(contentType, "application/geo+json"), strings.HasPrefix(contentType, fiber.MIMEApplicationJSON):
+		format = services.ActivityImportGeoJSON
+	default:
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(models.CreateErrorResponse("request body must be CSV (text/csv) or GeoJSON (application/geo+json)"))
+	}
+
+	mapping, err := services.ParseActivityImportMapping(c.Query("mapping"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	}
+
+	dryRun := c.QueryBool("dry_run")
+	result, err := h.imports.Import(c.UserContext(), format, c.Body(), mapping, dryRun)
+	if errors.Is(err, services.ErrInvalidActivityImport) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	}
+	if err != nil {
+		log.Printf("[ERROR] Import activities: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to import activities"))
+	}
+
+	if !dryRun {
+		log.Printf("[IMPORT] Activities: %d imported, %d invalid", result.Imported, result.Invalid)
+	}
+	return c.JSON(models.CreateSuccessResponse(result))
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// APIKeyHandler handles the personal API keys of the signed-in user
+type APIKeyHandler struct {
+	keys *services.APIKeyService
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(keys *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		keys: keys,
+	}
+}
+
+// createAPIKeyRequest is the body of CreateKey
+type createAPIKeyRequest struct {
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+// CreateKey creates an API key scripts send in the X-API-Key header to act as
+// the user. Keys cannot create further keys, so a leaked key can be revoked
+// without leaving others behind.
+//
+// Request body: {"name": "..."}
+//
+// Returns:
+//   - 201: The key record and the API key, which is only shown once
+//   - 400: Invalid input data
+//   - 403: Request authenticated with an API key
+//   - 409: Too many active keys
+//   - 500: Internal server error
+func (h *APIKeyHandler) CreateKey(c *fiber.Ctx) error {
+	if _, ok := middleware.APIKeyID(c); ok {
+		return c.Status(fiber.StatusForbidden).JSON(models.CreateErrorResponse("API keys can only be created when signed in"))
+	}
+
+	var body createAPIKeyRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	userID, _ := middleware.UserID(c)
+	key, apiKey, err := h.keys.CreateKey(c.UserContext(), userID, body.Name)
+	if err != nil {
+		if errors.Is(err, services.ErrTooManyAPIKeys) {
+			return c.Status(fiber.StatusConflict).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		log.Printf("[ERROR] Create API key for user %d: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to create API key"))
+	}
+
+	log.Printf("[AUTH] Created API key %d (%s) for user %d", key.ID, key.KeyPrefix, userID)
+	return c.Status(fiber.StatusCreated).JSON(models.CreateSuccessResponse(fiber.Map{
+		"key":     key,
+		"api_key": apiKey,
+	}))
+}
+
+// ListKeys returns the user's API keys without their secrets
+//
+// Returns:
+//   - 200: API keys, newest first
+//   - 500: Internal server error
+func (h *APIKeyHandler) ListKeys(c *fiber.Ctx) error {
+	userID, _ := middleware.UserID(c)
+	keys, err := h.keys.ListKeys(c.UserContext(), userID)
+	if err != nil {
+		log.Printf("[ERROR] List API keys for user %d: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list API keys"))
+	}
+	return c.JSON(models.CreateSuccessResponse(keys))
+}
+
+// RevokeKey revokes one of the user's API keys; it stops working at once
+//
+// Returns:
+//   - 200: Key revoked
+//   - 400: Invalid key ID
+//   - 404: Key not found or already revoked
+//   - 500: Internal server error
+func (h *APIKeyHandler) RevokeKey(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid API key id"))
+	}
+
+	userID, _ := middleware.UserID(c)
+	if err := h.keys.RevokeKey(c.UserContext(), userID, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("API key not found"))
+		}
+		log.Printf("[ERROR] Revoke API key %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to revoke API key"))
+	}
+
+	log.Printf("[AUTH] Revoked API key %d of user %d", id, userID)
+	return c.JSON(models.CreateMessageResponse("API key revoked"))
+}
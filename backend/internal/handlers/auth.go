@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/auth"
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AuthHandler handles registration, login and token refresh
+type AuthHandler struct {
+	auth *services.AuthService
+}
+
+// NewAuthHandler creates a new auth handler
+func NewAuthHandler(auth *services.AuthService) *AuthHandler {
+	return &AuthHandler{
+		auth: auth,
+	}
+}
+
+// credentials is the request body of register and login
+type credentials struct {
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+	Name     string `json:"name" validate:"max=255"`
+}
+
+// authResponse is returned by register and login
+type authResponse struct {
+	User *models.User `json:"user"`
+	*auth.TokenPair
+}
+
+// Register creates an account and returns tokens for it
+//
+// Returns:
+//   - 201: User and token pair
+//   - 400: Invalid input data
+//   - 409: Email already registered
+func (h *AuthHandler) Register(c *fiber.Ctx) error {
+	var body credentials
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	user, tokens, err := h.auth.Register(c.UserContext(), body.Email, body.Password, body.Name)
+	if err != nil {
+		if errors.Is(err, services.ErrEmailTaken) {
+			return c.Status(fiber.StatusConflict).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		log.Printf("[ERROR] Register: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to register"))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.CreateSuccessResponse(authResponse{User: user, TokenPair: tokens}))
+}
+
+// Login exchanges an email and password for tokens
+//
+// Returns:
+//   - 200: User and token pair
+//   - 400: Invalid input data
+//   - 401: Invalid email or password
+func (h *AuthHandler) Login(c *fiber.Ctx) error {
+	var body credentials
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+
+	user, tokens, err := h.auth.Login(c.UserContext(), body.Email, body.Password)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			return c.Status(fiber.StatusUnauthorized).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		log.Printf("[ERROR] Login: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to log in"))
+	}
+
+	return c.JSON(models.CreateSuccessResponse(authResponse{User: user, TokenPair: tokens}))
+}
+
+// Refresh exchanges a refresh token for a new token pair
+//
+// Request body: {"refresh_token": "..."}
+//
+// Returns:
+//   - 200: New token pair
+//   - 401: Invalid or expired refresh token
+func (h *AuthHandler) Refresh(c *fiber.Ctx) error {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("refresh_token is required"))
+	}
+
+	tokens, err := h.auth.Refresh(c.UserContext(), body.RefreshToken)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return c.Status(fiber.StatusUnauthorized).JSON(models.CreateErrorResponse("invalid refresh token"))
+		}
+		log.Printf("[ERROR] Refresh: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to refresh token"))
+	}
+
+	return c.JSON(models.CreateSuccessResponse(tokens))
+}
+
+// AcceptInvitation sets the password of a user invited by an import and returns tokens
+//
+// Request body: {"token": "...", "password": "..."}
+//
+// Returns:
+//   - 200: User and token pair
+//   - 400: Invalid input data
+//   - 401: Invalid, expired or already accepted invitation
+func (h *AuthHandler) AcceptInvitation(c *fiber.Ctx) error {
+	var body struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"required,min=8,max=72"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	user, tokens, err := h.auth.AcceptInvitation(c.UserContext(), body.Token, body.Password)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidInvitation) {
+			return c.Status(fiber.StatusUnauthorized).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		log.Printf("[ERROR] Accept invitation: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to accept invitation"))
+	}
+
+	log.Printf("[AUTH] User %d accepted their invitation", user.ID)
+	return c.JSON(models.CreateSuccessResponse(authResponse{User: user, TokenPair: tokens}))
+}
+
+// VerifyEmail verifies the email address a verification token was sent to
+//
+// Request body: {"token": "..."}
+//
+// Returns:
+//   - 200: Verified user
+//   - 400: Invalid or expired token
+//   - 500: Internal server error
+func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
+	var body struct {
+		Token string `json:"token" validate:"required"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	user, err := h.auth.VerifyEmail(c.UserContext(), body.Token)
+	if err != nil {
+		return err
+	}
+	log.Printf("[AUTH] User %d verified their email", user.ID)
+	return c.JSON(models.CreateSuccessResponse(user))
+}
+
+// RequestVerification sends the authenticated user another link to verify
+// their email address
+//
+// Returns:
+//   - 202: When the link expires
+//   - 409: Email already verified
+//   - 500: Internal server error
+func (h *AuthHandler) RequestVerification(c *fiber.Ctx) error {
+	userID, _ := middleware.UserID(c)
+	expiresAt, err := h.auth.RequestVerification(c.UserContext(), userID)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusAccepted).JSON(models.CreateSuccessResponse(fiber.Map{"expires_at": expiresAt}))
+}
+
+// RequestPasswordReset emails a link to choose a new password. The response is
+// the same whether or not the address has an account.
+//
+// Request body: {"email": "user@example.com"}
+//
+// Returns:
+//   - 202: Link sent if the address has an account
+//   - 400: Invalid input data
+//   - 500: Internal server error
+func (h *AuthHandler) RequestPasswordReset(c *fiber.Ctx) error {
+	var body struct {
+		Email string `json:"email" validate:"required,email,max=255"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	if err := h.auth.RequestPasswordReset(c.UserContext(), body.Email); err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusAccepted).JSON(models.CreateMessageResponse("if the address has an account, a link to reset the password was sent to it"))
+}
+
+// ResetPassword sets a new password with a reset token and returns tokens
+//
+// Request body: {"token": "...", "password": "..."}
+//
+// Returns:
+//   - 200: User and token pair
+//   - 400: Invalid input data, or invalid, expired or used token
+//   - 500: Internal server error
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	var body struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"required,min=8,max=72"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	user, tokens, err := h.auth.ResetPassword(c.UserContext(), body.Token, body.Password)
+	if err != nil {
+		return err
+	}
+	log.Printf("[AUTH] User %d reset their password", user.ID)
+	return c.JSON(models.CreateSuccessResponse(authResponse{User: user, TokenPair: tokens}))
+}
+
+// Me returns the authenticated user
+//
+// Returns:
+//   - 200: User
+//   - 401: Not authenticated
+func (h *AuthHandler) Me(c *fiber.Ctx) error {
+	userID, _ := middleware.UserID(c)
+	user, err := h.auth.User(c.UserContext(), userID)
+	if err != nil {
+		log.Printf("[ERROR] Load user %d: %v", userID, err)
+		return c.Status(fiber.StatusUnauthorized).JSON(models.CreateErrorResponse("authentication required"))
+	}
+	return c.JSON(models.CreateSuccessResponse(user))
+}
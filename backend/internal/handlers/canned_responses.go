@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"strings"
+
+	"community-chatbot/internal/models"
+)
+
+// cannedTopic is a canned response, by language, to messages containing one
+// of its keywords in any supported language
+type cannedTopic struct {
+	keywords  []string
+	responses map[string]string
+}
+
+// cannedTopics are checked in order; the first topic a message mentions is answered
+var cannedTopics = []cannedTopic{
+	{
+		keywords: []string{"hiking", "trail", "wander", "randonnée", "sentier", "senderismo", "sendero", "escursion"},
+		responses: map[string]string{
+			"en": "I found some great hiking trails in your area! Here are a few popular options: Bear Mountain Trail (moderate difficulty, 3.2 miles), Sunset Ridge Loop (easy, 1.8 miles), and Eagle Peak Summit (challenging, 5.7 miles). Would you like more details about any of these trails?",
+			"de": "Ich habe einige tolle Wanderwege in deiner Nähe gefunden! Hier ein paar beliebte Optionen: Bear Mountain Trail (mittelschwer, 5,1 km), Sunset Ridge Loop (leicht, 2,9 km) und Eagle Peak Summit (anspruchsvoll, 9,2 km). Möchtest du mehr über einen dieser Wege erfahren?",
+			"fr": "J'ai trouvé de superbes sentiers de randonnée près de chez vous ! Voici quelques options populaires : Bear Mountain Trail (difficulté moyenne, 5,1 km), Sunset Ridge Loop (facile, 2,9 km) et Eagle Peak Summit (difficile, 9,2 km). Voulez-vous plus de détails sur l'un de ces sentiers ?",
+			"es": "¡He encontrado algunas rutas de senderismo estupendas en tu zona! Estas son algunas opciones populares: Bear Mountain Trail (dificultad moderada, 5,1 km), Sunset Ridge Loop (fácil, 2,9 km) y Eagle Peak Summit (exigente, 9,2 km). ¿Quieres más detalles sobre alguna de ellas?",
+			"it": "Ho trovato alcuni bellissimi sentieri escursionistici nella tua zona! Ecco alcune opzioni popolari: Bear Mountain Trail (difficoltà media, 5,1 km), Sunset Ridge Loop (facile, 2,9 km) ed Eagle Peak Summit (impegnativo, 9,2 km). Vuoi maggiori dettagli su uno di questi sentieri?",
+		},
+	},
+	{
+		keywords: []string{"cycling", "bike", "fahrrad", "radtour", "vélo", "cyclisme", "bicicleta", "ciclismo", "bici"},
+		responses: map[string]string{
+			"en": "There are several excellent cycling routes nearby! I recommend the Riverside Path (easy, 8 miles of paved trail), Mountain Loop Road (moderate, 12 miles with scenic views), and the Advanced Hill Circuit (challenging, 15 miles with steep climbs). Which type of cycling experience are you looking for?",
+			"de": "In der Nähe gibt es mehrere ausgezeichnete Radrouten! Ich empfehle den Riverside Path (leicht, 13 km asphaltierter Weg), die Mountain Loop Road (mittelschwer, 19 km mit schönen Ausblicken) und den Advanced Hill Circuit (anspruchsvoll, 24 km mit steilen Anstiegen). Welche Art von Radtour suchst du?",
+			"fr": "Il y a plusieurs excellents itinéraires cyclables à proximité ! Je vous recommande le Riverside Path (facile, 13 km de piste goudronnée), la Mountain Loop Road (moyen, 19 km avec de beaux panoramas) et l'Advanced Hill Circuit (difficile, 24 km avec des montées raides). Quel type de sortie à vélo recherchez-vous ?",
+			"es": "¡Hay varias rutas en bicicleta excelentes cerca! Te recomiendo el Riverside Path (fácil, 13 km de camino asfaltado), la Mountain Loop Road (moderada, 19 km con vistas panorámicas) y el Advanced Hill Circuit (exigente, 24 km con subidas empinadas). ¿Qué tipo de ruta en bici te interesa?",
+			"it": "Ci sono diversi ottimi percorsi in bici nei dintorni! Ti consiglio il Riverside Path (facile, 13 km di pista asfaltata), la Mountain Loop Road (media, 19 km con viste panoramiche) e l'Advanced Hill Circuit (impegnativo, 24 km con salite ripide). Che tipo di giro in bici stai cercando?",
+		},
+	},
+	{
+		keywords: []string{"restaurant", "food", "eat", "essen", "manger", "comida", "comer", "ristorante", "mangiare"},
+		responses: map[string]string{
+			"en": "Here are some great local restaurants: The Mountain View Café (farm-to-table, outdoor seating), Trailhead Grill (burgers and craft beer), and Summit Bistro (fine dining with valley views). What type of cuisine are you in the mood for?",
+			"de": "Hier sind einige tolle Restaurants in der Gegend: The Mountain View Café (regionale Küche, Sitzplätze im Freien), Trailhead Grill (Burger und Craft-Bier) und Summit Bistro (gehobene Küche mit Blick ins Tal). Worauf hast du Appetit?",
+			"fr": "Voici quelques bons restaurants du coin : The Mountain View Café (produits locaux, terrasse), Trailhead Grill (burgers et bières artisanales) et Summit Bistro (gastronomie avec vue sur la vallée). Quel type de cuisine vous ferait plaisir ?",
+			"es": "Estos son algunos restaurantes estupendos de la zona: The Mountain View Café (de la granja a la mesa, terraza), Trailhead Grill (hamburguesas y cerveza artesanal) y Summit Bistro (alta cocina con vistas al valle). ¿Qué tipo de cocina te apetece?",
+			"it": "Ecco alcuni ottimi ristoranti della zona: The Mountain View Café (dal produttore alla tavola, posti all'aperto), Trailhead Grill (hamburger e birre artigianali) e Summit Bistro (alta cucina con vista sulla valle). Che tipo di cucina ti va?",
+		},
+	},
+}
+
+// cannedDefault answers messages that mention no topic
+var cannedDefault = map[string]string{
+	"en": "Thanks for your message! I'm here to help you discover outdoor activities, restaurants, and local attractions. You can ask me about hiking trails, cycling routes, places to eat, or any other activities you're interested in. What would you like to explore today?",
+	"de": "Danke für deine Nachricht! Ich helfe dir, Outdoor-Aktivitäten, Restaurants und Sehenswürdigkeiten in der Umgebung zu entdecken. Frag mich nach Wanderwegen, Radrouten, Restaurants oder anderen Aktivitäten, die dich interessieren. Was möchtest du heute entdecken?",
+	"fr": "Merci pour votre message ! Je suis là pour vous aider à découvrir des activités de plein air, des restaurants et des lieux à visiter dans la région. Vous pouvez me poser des questions sur les sentiers de randonnée, les itinéraires à vélo, les endroits où manger ou toute autre activité qui vous intéresse. Que souhaitez-vous explorer aujourd'hui ?",
+	"es": "¡Gracias por tu mensaje! Estoy aquí para ayudarte a descubrir actividades al aire libre, restaurantes y lugares de interés de la zona. Puedes preguntarme por rutas de senderismo, rutas en bicicleta, sitios para comer o cualquier otra actividad que te interese. ¿Qué te gustaría explorar hoy?",
+	"it": "Grazie per il tuo messaggio! Sono qui per aiutarti a scoprire attività all'aperto, ristoranti e luoghi d'interesse della zona. Puoi chiedermi di sentieri escursionistici, percorsi in bici, posti dove mangiare o qualsiasi altra attività che ti interessa. Cosa ti piacerebbe esplorare oggi?",
+}
+
+// cannedResponse returns the response to a message in language, falling back
+// to the default language
+func cannedResponse(message, language string) string {
+	message = strings.ToLower(message)
+	responses := cannedDefault
+	for _, topic := range cannedTopics {
+		if containsAny(message, topic.keywords) {
+			responses = topic.responses
+			break
+		}
+	}
+	if response, ok := responses[language]; ok {
+		return response
+	}
+	return responses[models.DefaultLanguage]
+}
+
+// containsAny reports whether text contains one of words
+func containsAny(text string, words []string) bool {
+	for _, word := range words {
+		if strings.Contains(text, word) {
+			return true
+		}
+	}
+	return false
+}
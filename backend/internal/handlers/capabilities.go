@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"community-chatbot/internal/agui"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Capabilities tells frontends how to talk to the chat and how to render its answers
+type Capabilities struct {
+	Chat   ChatCapabilities    `json:"chat"`
+	Output chat.OutputContract `json:"output"`
+}
+
+// ChatCapabilities describes the chat streaming protocol
+type ChatCapabilities struct {
+	Endpoints []string `json:"endpoints"`
+	Events    []string `json:"events"`
+	// EventVersion is the AG-UI event catalog version, sent as "v" with every event
+	EventVersion   int  `json:"event_version"`
+	Authentication bool `json:"authentication"`
+}
+
+// CapabilitiesHandler serves the capabilities document
+type CapabilitiesHandler struct {
+	capabilities Capabilities
+}
+
+// NewCapabilitiesHandler creates a capabilities handler. authEnabled reports
+// whether chat accepts bearer tokens for personalized answers.
+func NewCapabilitiesHandler(authEnabled bool) *CapabilitiesHandler {
+	return &CapabilitiesHandler{
+		capabilities: Capabilities{
+			Chat: ChatCapabilities{
+				Endpoints: []string{"GET /api/v1/chat/stream", "POST /api/v1/chat/stream", "POST /api/v1/chat/runs", "GET /api/v1/chat/runs/:token/stream", "POST /api/v1/chat/:conversationID/stop"},
+				Events: []string{
+					"STREAMING_START", "QUEUED", "TEXT_MESSAGE_CONTENT", "CONTENT_ANNOTATIONS",
+					"ACTIVITIES_FOUND", "IMAGES_LOADED", "TOOL_EXECUTION_START", "TOOL_EXECUTION_END",
+					"ITINERARY_READY", "USAGE_REPORT", "ERROR", "STREAMING_END",
+				},
+				EventVersion:   agui.Version,
+				Authentication: authEnabled,
+			},
+			Output: chat.MarkdownContract,
+		},
+	}
+}
+
+// GetCapabilities returns the chat protocol and output contract
+//
+// Returns:
+//   - 200: Capabilities document
+func (h *CapabilitiesHandler) GetCapabilities(c *fiber.Ctx) error {
+	return c.JSON(models.CreateSuccessResponse(h.capabilities))
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// CategoryHandler handles the activity category hierarchy
+type CategoryHandler struct {
+	categories *services.CategoryService
+}
+
+// NewCategoryHandler creates a new category handler
+func NewCategoryHandler(categories *services.CategoryService) *CategoryHandler {
+	return &CategoryHandler{
+		categories: categories,
+	}
+}
+
+// categoryRequest is the body of CreateCategory and UpdateCategory
+type categoryRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+	// Slug is derived from the name when empty
+	Slug     string `json:"slug" validate:"max=100"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+// ListCategories returns the category hierarchy: top-level categories with
+// their subcategories nested in children
+//
+// Returns:
+//   - 200: Categories
+//   - 500: Internal server error
+func (h *CategoryHandler) ListCategories(c *fiber.Ctx) error {
+	categories, err := h.categories.Tree(c.UserContext())
+	if err != nil {
+		log.Printf("[ERROR] List categories: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list categories"))
+	}
+	return c.JSON(models.CreateSuccessResponse(categories))
+}
+
+// GetCategory returns a category
+//
+// Returns:
+//   - 200: Category
+//   - 400: Invalid category ID
+//   - 404: Category not found
+//   - 500: Internal server error
+func (h *CategoryHandler) GetCategory(c *fiber.Ctx) error {
+	id, ok := categoryID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid category id"))
+	}
+
+	category, err := h.categories.Get(c.UserContext(), id)
+	if err != nil {
+		return categoryError(id, err)
+	}
+	return c.JSON(models.CreateSuccessResponse(category))
+}
+
+// CreateCategory creates a category (admin only)
+//
+// Request body: {"name": "Trail running", "slug": "trail-running", "parent_id": 1}
+//
+// Returns:
+//   - 201: Created category
+//   - 400: Invalid input, slug or parent
+//   - 409: Slug already taken
+//   - 500: Internal server error
+func (h *CategoryHandler) CreateCategory(c *fiber.Ctx) error {
+	category, err := parseCategory(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	}
+
+	if err := h.categories.Create(c.UserContext(), category); err != nil {
+		return categoryError(0, err)
+	}
+	log.Printf("[CATEGORY] Created category %d (%s)", category.ID, category.Slug)
+	return c.Status(fiber.StatusCreated).JSON(models.CreateSuccessResponse(category))
+}
+
+// UpdateCategory replaces the name, slug and parent of a category (admin only)
+//
+// Returns:
+//   - 200: Updated category
+//   - 400: Invalid ID, input, slug or parent
+//   - 404: Category not found
+//   - 409: Slug already taken
+//   - 500: Internal server error
+func (h *CategoryHandler) UpdateCategory(c *fiber.Ctx) error {
+	id, ok := categoryID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid category id"))
+	}
+	changes, err := parseCategory(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	}
+
+	category, err := h.categories.Update(c.UserContext(), id, changes)
+	if err != nil {
+		return categoryError(id, err)
+	}
+	return c.JSON(models.CreateSuccessResponse(category))
+}
+
+// DeleteCategory deletes a category without subcategories or activities (admin only)
+//
+// Returns:
+//   - 200: Category deleted
+//   - 400: Invalid category ID
+//   - 404: Category not found
+//   - 409: Category has subcategories or activities
+//   - 500: Internal server error
+func (h *CategoryHandler) DeleteCategory(c *fiber.Ctx) error {
+	id, ok := categoryID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid category id"))
+	}
+
+	if err := h.categories.Delete(c.UserContext(), id); err != nil {
+		return categoryError(id, err)
+	}
+	log.Printf("[CATEGORY] Deleted category %d", id)
+	return c.JSON(models.CreateMessageResponse("category deleted"))
+}
+
+// categoryID parses the :id route parameter
+func categoryID(c *fiber.Ctx) (uint, bool) {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// parseCategory binds and validates a category request
+func parseCategory(c *fiber.Ctx) (*models.Category, error) {
+	var body categoryRequest
+	if err := c.BodyParser(&body); err != nil {
+		return nil, errors.New("invalid request body")
+	}
+	if err := validate.Struct(body); err != nil {
+		return nil, errors.New(validationMessage(err))
+	}
+	return &models.Category{
+		Name:     body.Name,
+		Slug:     body.Slug,
+		ParentID: body.ParentID,
+	}, nil
+}
+
+// categoryError hands service errors to the app's error handler, which maps
+// them to HTTP responses by kind
+func categoryError(id uint, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperr.Wrap(apperr.NotFound, "category not found", err)
+	}
+	return fmt.Errorf("category %d: %w", id, err)
+}
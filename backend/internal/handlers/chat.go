@@ -2,7 +2,11 @@ package handlers
 
 import (
 	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/url"
@@ -10,108 +14,183 @@ import (
 	"sync"
 	"time"
 
+	"community-chatbot/internal/agui"
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/llm"
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/residency"
+	"community-chatbot/internal/stream"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 // ChatHandler handles chat streaming endpoints
 type ChatHandler struct {
-	// Add message deduplication to prevent loops
-	recentMessages map[string]time.Time
-	messagesMutex  sync.RWMutex
+	// pipeline runs each chat message through the registered stages
+	pipeline *chat.Pipeline
+
+	// llm generates answers; canned responses are used when it is nil
+	llm llm.Provider
+
+	// tools are the lookups the model may call while answering
+	tools *chat.ToolRegistry
+
+	// connections tracks open streams so idle ones can be reaped
+	connections *stream.Registry
+
+	// replays buffer stream events for clients that reconnect with Last-Event-ID
+	replays *stream.Replays
+
+	// limiter caps concurrent generations; nil leaves them unlimited
+	limiter *chat.Limiter
+
+	// runs hold the runs created for EventSource clients until their stream starts
+	runs *stream.RunTokens
+
+	// keepalive is how often a comment is written to streams between events
+	keepalive time.Duration
+
+	// generations are the answers being streamed, so their clients can stop them
+	generations *stream.Generations
+
+	// systemPrompt sets the assistant's role, chat.SystemPrompt unless replaced
+	systemPrompt string
 }
 
-// NewChatHandler creates a new chat handler
-func NewChatHandler() *ChatHandler {
+// maxToolRounds bounds how many rounds of tool calls the model may make per answer
+const maxToolRounds = 3
+
+// defaultKeepalive is how often a keepalive comment is written to streams when
+// no interval is configured
+const defaultKeepalive = 5 * time.Second
+
+// errClientDisconnected cancels a stream whose client went away
+var errClientDisconnected = errors.New("client disconnected")
+
+// errUnsupportedLanguage is returned for lang parameters chat cannot answer in
+var errUnsupportedLanguage = apperr.New(apperr.Invalid, "lang must be one of en, de, fr, es, it")
+
+// lastEventIDHeader is sent by EventSource clients reconnecting to a stream
+const lastEventIDHeader = "Last-Event-ID"
+
+var streamResumes = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "stream_resumes_total",
+	Help: "Reconnections with Last-Event-ID by outcome (resumed, completed, expired, unknown).",
+}, []string{"outcome"})
+
+// NewChatHandler creates a new chat handler. Pass a nil client to use the
+// built-in canned responses (e.g. when no OpenAI key is configured), a nil
+// limiter to leave generations unlimited and nil runs when the run token
+// endpoints are not served. keepalive is how often streams get a comment while
+// the answer is generated; 0 uses defaultKeepalive.
+func NewChatHandler(client llm.Provider, connections *stream.Registry, replays *stream.Replays, limiter *chat.Limiter, runs *stream.RunTokens, keepalive time.Duration) *ChatHandler {
+	if keepalive <= 0 {
+		keepalive = defaultKeepalive
+	}
 	handler := &ChatHandler{
-		recentMessages: make(map[string]time.Time),
+		llm:          client,
+		tools:        chat.NewToolRegistry(),
+		connections:  connections,
+		replays:      replays,
+		limiter:      limiter,
+		runs:         runs,
+		keepalive:    keepalive,
+		generations:  stream.NewGenerations(),
+		systemPrompt: chat.SystemPrompt,
 	}
-	
-	// Start cleanup goroutine to remove old messages
-	go handler.cleanupOldMessages()
-	
+	handler.pipeline = chat.NewPipeline(handler.respond)
+	handler.pipeline.Register(chat.OrderLogging, chat.LoggingStage())
 	return handler
 }
 
-// cleanupOldMessages removes messages older than 10 seconds to prevent memory leaks
-func (h *ChatHandler) cleanupOldMessages() {
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
-	
-	for range ticker.C {
-		h.messagesMutex.Lock()
-		cutoff := time.Now().Add(-10 * time.Second)
-		for message, timestamp := range h.recentMessages {
-			if timestamp.Before(cutoff) {
-				delete(h.recentMessages, message)
-			}
-		}
-		h.messagesMutex.Unlock()
-	}
+// Pipeline returns the chat pipeline so deployments can register custom stages
+func (h *ChatHandler) Pipeline() *chat.Pipeline {
+	return h.pipeline
 }
 
-// isRecentMessage checks if the same message was sent recently (within 10 seconds)
-func (h *ChatHandler) isRecentMessage(message string) bool {
-	h.messagesMutex.RLock()
-	defer h.messagesMutex.RUnlock()
-	
-	if lastTime, exists := h.recentMessages[message]; exists {
-		return time.Since(lastTime) < 10*time.Second
-	}
-	return false
+// Tools returns the tool registry so deployments can offer lookups to the model
+func (h *ChatHandler) Tools() *chat.ToolRegistry {
+	return h.tools
 }
 
-// recordMessage records a message with current timestamp
-func (h *ChatHandler) recordMessage(message string) {
-	h.messagesMutex.Lock()
-	defer h.messagesMutex.Unlock()
-	h.recentMessages[message] = time.Now()
+// SetSystemPrompt replaces the system prompt, e.g. with a candidate prompt
+// replayed against stored conversations
+func (h *ChatHandler) SetSystemPrompt(prompt string) {
+	h.systemPrompt = prompt
 }
 
 
 
-// AGUIEvent represents an AG-UI protocol event
-type AGUIEvent struct {
-	Type string `json:"type"`
-}
-
-// StreamingStartEvent represents the start of streaming
-type StreamingStartEvent struct {
-	Type      string `json:"type"`
-	MessageID string `json:"messageId"`
+// chatErrorMessages are shown for failed runs instead of the error's own message
+var chatErrorMessages = map[apperr.Kind]string{
+	apperr.Internal:        "Failed to generate a response. Please try again.",
+	apperr.RateLimited:     "The assistant is busy right now. Please try again in a moment.",
+	apperr.UpstreamTimeout: "The assistant took too long to answer. Please try again.",
 }
 
-// TextMessageEvent represents a text message chunk
-type TextMessageEvent struct {
-	Type       string `json:"type"`
-	Content    string `json:"content"`
-	IsComplete bool   `json:"isComplete"`
+// chatError returns the ERROR event of a failed run, with the AG-UI code of the error's kind
+func chatError(err error) agui.ErrorEvent {
+	kind := apperr.KindOf(err)
+	message, ok := chatErrorMessages[kind]
+	if !ok {
+		message = apperr.Message(err)
+	}
+	return agui.CreateErrorEvent(message, kind.Code())
 }
 
-// StreamingEndEvent represents the end of streaming
-type StreamingEndEvent struct {
-	Type string `json:"type"`
+// ChatRequest is a chat message with optional conversation and user context.
+// With Continue set, the conversation's interrupted answer is finished instead
+// and no message is needed.
+type ChatRequest struct {
+	Message        string            `json:"message" validate:"required_without=Continue,max=4000"`
+	ConversationID string            `json:"conversation_id" validate:"required_with=Continue,max=64"`
+	Continue       bool              `json:"continue"`
+	Context        *chat.UserContext `json:"context"`
+	// Language is the answer language; the lang query parameter is used when empty
+	Language string `json:"language" validate:"omitempty,oneof=en de fr es it"`
+	// EditOf is the message BranchMessage edits, see chat.Run.EditOf
+	EditOf uint `json:"-"`
 }
 
-// ErrorEvent represents an error
-type ErrorEvent struct {
-	Type    string `json:"type"`
-	Message string `json:"message"`
-}
-// StreamChat handles the AG-UI streaming chat endpoint
+// StreamChat handles the AG-UI streaming chat endpoint for EventSource clients,
+// which can only send the message as a query parameter. continue=true with a
+// conversation_id finishes the conversation's interrupted answer instead.
+// Answers are in the language of lang, the user's preferences or the
+// Accept-Language header, see chat.Run.AnswerLanguage.
+//
+// Query parameters: message, conversation_id, continue, lang (en, de, fr, es or it)
 func (h *ChatHandler) StreamChat(c *fiber.Ctx) error {
 	// Extract client information for logging
 	clientIP := c.IP()
+	requestID := middleware.GetRequestID(c)
 	userAgent := c.Get("User-Agent", "Unknown")
 	xForwardedFor := c.Get("X-Forwarded-For")
 	
 	// Log request details with client information
-	log.Printf("[REQUEST] Client: %s (X-Forwarded-For: %s) | User-Agent: %s | Endpoint: %s", 
-		clientIP, xForwardedFor, userAgent, c.OriginalURL())
+	log.Printf("[REQUEST] Client: %s (X-Forwarded-For: %s) | Request: %s | User-Agent: %s | Endpoint: %s", 
+		clientIP, xForwardedFor, requestID, userAgent, c.OriginalURL())
+
+	if c.QueryBool("continue") {
+		if c.Query("conversation_id") == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "conversation_id parameter is required to continue",
+			})
+		}
+		return h.stream(c, ChatRequest{
+			ConversationID: c.Query("conversation_id"),
+			Continue:       true,
+		}, requestOwner(c))
+	}
 
 	// Get message from query parameter and decode it properly
 	message := c.Query("message")
 	if message == "" {
-		log.Printf("[ERROR] Client %s: missing message parameter", clientIP)
+		log.Printf("[ERROR] Client %s (request %s): missing message parameter", clientIP, requestID)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "message parameter is required",
 		})
@@ -120,95 +199,326 @@ func (h *ChatHandler) StreamChat(c *fiber.Ctx) error {
 	// Decode URL-encoded message
 	decodedMessage, err := url.QueryUnescape(message)
 	if err != nil {
-		log.Printf("[ERROR] Client %s: Failed to decode message: %v", clientIP, err)
+		log.Printf("[ERROR] Client %s (request %s): Failed to decode message: %v", clientIP, requestID, err)
 		decodedMessage = message // fallback to original
 	}
 
-	// Check for duplicate messages to prevent loops
-	if h.isRecentMessage(decodedMessage) {
-		log.Printf("[DUPLICATE] Client %s: Duplicate message detected and ignored: %s", clientIP, decodedMessage)
-		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
-			"error": "Duplicate message sent too quickly. Please wait before sending the same message again.",
-		})
+	log.Printf("[CHAT] Client %s (request %s): Received message: %s (decoded: %s)", clientIP, requestID, message, decodedMessage)
+
+	return h.stream(c, ChatRequest{
+		Message:        decodedMessage,
+		ConversationID: c.Query("conversation_id"),
+	}, requestOwner(c))
+}
+
+// StreamChatPost handles the AG-UI streaming chat endpoint with a JSON body, which
+// keeps messages out of URLs and proxy logs and has no query-string length limit
+//
+// Returns:
+//   - 200: text/event-stream of AG-UI events
+//   - 400: Invalid JSON body or language, or missing message or conversation ID to continue
+//   - 429: Rate limit exceeded
+func (h *ChatHandler) StreamChatPost(c *fiber.Ctx) error {
+	req, err := parseChatRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
 	}
 
-	// Record this message
-	h.recordMessage(decodedMessage)
+	log.Printf("[CHAT] Client %s (request %s): Received message via POST (conversation: %s)", c.IP(), middleware.GetRequestID(c), req.ConversationID)
+	return h.stream(c, req, requestOwner(c))
+}
+
+// pendingRun is a run created with CreateRun, waiting for its stream
+type pendingRun struct {
+	Request   ChatRequest `json:"request"`
+	UserID    uint        `json:"user_id,omitempty"`
+	SessionID string      `json:"session_id,omitempty"`
+	Region    string      `json:"region,omitempty"`
+}
 
-	log.Printf("[CHAT] Client %s: Received message: %s (decoded: %s)", clientIP, message, decodedMessage)
+// RunResponse is the answer to CreateRun
+type RunResponse struct {
+	RunToken       string `json:"run_token"`
+	StreamURL      string `json:"stream_url"`
+	ConversationID string `json:"conversation_id"`
+	ExpiresIn      int    `json:"expires_in"` // seconds to open the stream in
+}
 
-	// Set headers for Server-Sent Events with proper CORS
-	c.Set("Content-Type", "text/event-stream")
-	c.Set("Cache-Control", "no-cache")
-	c.Set("Connection", "keep-alive")
-	c.Set("Access-Control-Allow-Origin", "*")
-	c.Set("Access-Control-Allow-Headers", "Cache-Control")
-	c.Set("Access-Control-Expose-Headers", "Content-Type,Cache-Control,Connection")
+// CreateRun creates a chat run from a JSON body, like StreamChatPost, and returns
+// a short-lived token whose stream EventSource clients open with StreamRun. The
+// message stays out of URLs, and the run keeps the user, session and community
+// of this request, which EventSource cannot send.
+//
+// Returns:
+//   - 201: Run token and the URL of its stream
+//   - 400: Invalid JSON body or language, or missing message or conversation ID to continue
+//   - 429: Rate limit exceeded
+//   - 500: Internal server error
+func (h *ChatHandler) CreateRun(c *fiber.Ctx) error {
+	req, err := parseChatRequest(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	}
+	// The conversation is started here so the client knows its ID before streaming
+	if req.ConversationID == "" {
+		req.ConversationID = uuid.New().String()
+	}
+
+	owner := requestOwner(c)
+	run, err := json.Marshal(pendingRun{
+		Request:   req,
+		UserID:    owner.UserID,
+		SessionID: owner.SessionID,
+		Region:    residency.Region(c.UserContext()),
+	})
+	if err == nil {
+		var token string
+		if token, err = h.runs.Issue(c.UserContext(), run); err == nil {
+			log.Printf("[CHAT] Client %s (request %s): Created run (conversation: %s)", c.IP(), middleware.GetRequestID(c), req.ConversationID)
+			return c.Status(fiber.StatusCreated).JSON(models.CreateSuccessResponse(RunResponse{
+				RunToken:       token,
+				StreamURL:      strings.TrimRight(c.Path(), "/") + "/" + token + "/stream",
+				ConversationID: req.ConversationID,
+				ExpiresIn:      int(h.runs.TTL().Seconds()),
+			}))
+		}
+	}
+	log.Printf("[ERROR] Client %s (request %s): Failed to create run: %v", c.IP(), middleware.GetRequestID(c), err)
+	return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to create run"))
+}
+
+// StreamRun streams the run of a token from CreateRun. Each token starts one
+// stream; reconnections with Last-Event-ID resume it for as long as the stream
+// can be resumed.
+//
+// Returns:
+//   - 200: text/event-stream of AG-UI events
+//   - 204: Reconnection to a stream that cannot be resumed
+//   - 404: Unknown, expired or already used run token
+//   - 500: Internal server error
+func (h *ChatHandler) StreamRun(c *fiber.Ctx) error {
+	token := c.Params("token")
+	if lastEventID := c.Get(lastEventIDHeader); lastEventID != "" {
+		runID, err := h.runs.RunID(token)
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		return h.resume(c, lastEventID, streamOwner{RunID: runID})
+	}
+
+	runID, data, err := h.runs.Redeem(c.UserContext(), token)
+	if errors.Is(err, stream.ErrInvalidRunToken) {
+		return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse(err.Error()))
+	}
+	var run pendingRun
+	if err == nil {
+		err = json.Unmarshal(data, &run)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Client %s (request %s): Failed to load run: %v", c.IP(), middleware.GetRequestID(c), err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to load run"))
+	}
+
+	// The run is served from the region of the community it was created for
+	if run.Region != "" {
+		ctx, err := residency.WithRegion(context.Background(), run.Region)
+		if err != nil {
+			return err
+		}
+		c.SetUserContext(ctx)
+	}
+	return h.stream(c, run.Request, streamOwner{UserID: run.UserID, SessionID: run.SessionID, RunID: runID})
+}
+
+// StopChat stops generating the answer streamed for a conversation. The stream
+// ends with a STREAMING_END event with reason "cancelled"; the part of the
+// answer sent so far is kept and can be continued. Users stop their own
+// answers and guests those of their session. Answers are stopped on the
+// replica streaming them.
+//
+// Returns:
+//   - 200: Generation stopped
+//   - 404: No answer being generated for the conversation
+func (h *ChatHandler) StopChat(c *fiber.Ctx) error {
+	conversationID := c.Params("conversationID")
+	owner := requestOwner(c)
+	if !h.generations.Stop(conversationID, owner.UserID, owner.SessionID) {
+		return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("no answer is being generated for this conversation"))
+	}
+	log.Printf("[STREAM] Client %s (request %s): Stopped generation for conversation %s", c.IP(), middleware.GetRequestID(c), conversationID)
+	return c.JSON(models.CreateMessageResponse("generation stopped"))
+}
+
+// parseChatRequest parses and validates a JSON chat request
+func parseChatRequest(c *fiber.Ctx) (ChatRequest, error) {
+	var req ChatRequest
+	if err := c.BodyParser(&req); err != nil {
+		return req, errors.New("invalid request body")
+	}
+	req.Message = strings.TrimSpace(req.Message)
+	if err := validate.Struct(&req); err != nil {
+		return req, errors.New(validationMessage(err))
+	}
+	return req, nil
+}
+
+// answerLanguage returns the answer language a chat request asks for in its
+// body or lang query parameter, or "" when it leaves the choice to the user's
+// preferences and Accept-Language header
+func answerLanguage(c *fiber.Ctx, language string) (string, error) {
+	if language == "" {
+		language = c.Query("lang")
+	}
+	if language != "" && !models.IsLanguage(language) {
+		return "", errUnsupportedLanguage
+	}
+	return language, nil
+}
+
+// streamOwner is who a stream is for: the requesting client, or for streams
+// of run tokens the client that created the run
+type streamOwner struct {
+	UserID    uint
+	SessionID string
+	// RunID is set for streams of run tokens, whose holders may resume them
+	RunID string
+}
+
+// owns reports whether the owner may resume a stream
+func (o streamOwner) owns(replay *stream.Replay) bool {
+	if o.RunID != "" {
+		return replay.RunID == o.RunID
+	}
+	return replay.OwnerID == o.UserID
+}
+
+// requestOwner returns the user and session of a request
+func requestOwner(c *fiber.Ctx) streamOwner {
+	userID, _ := middleware.UserID(c)
+	return streamOwner{UserID: userID, SessionID: middleware.SessionID(c)}
+}
+
+// stream runs the message through the pipeline and streams its AG-UI events.
+// Floods of messages are throttled by the rate limiting middleware. Clients
+// reconnecting with Last-Event-ID continue the stream they lost instead.
+func (h *ChatHandler) stream(c *fiber.Ctx, req ChatRequest, owner streamOwner) error {
+	if lastEventID := c.Get(lastEventIDHeader); lastEventID != "" {
+		return h.resume(c, lastEventID, owner)
+	}
+
+	clientIP := c.IP()
+	requestID := middleware.GetRequestID(c)
+	fingerprint := clientFingerprint(c)
+	userID := owner.UserID
+	sessionID := owner.SessionID
+	decodedMessage := req.Message
+
+	// Start a new conversation unless the client continues one
+	if req.ConversationID == "" {
+		req.ConversationID = uuid.New().String()
+	}
+
+	setStreamHeaders(c)
+
+	language, err := answerLanguage(c, req.Language)
+	if err != nil {
+		return err
+	}
+	// Copied, since the request is released before the stream writer runs
+	acceptLanguage := strings.Clone(c.Get(fiber.HeaderAcceptLanguage))
 
 	// Send immediate response to establish connection
+	path := c.Path()
+	replay := h.replays.Create(userID, owner.RunID)
+	// The stream outlives the request, so only its data residency region is kept
+	regionCtx := residency.Detach(c.UserContext())
 	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
-		clientIP := c.IP() // Capture client IP for logging in stream
-		
+		// The stream context is cancelled when the reaper closes an idle stream, on
+		// server shutdown and when no client has followed the stream for the resume
+		// window after a disconnect, which aborts the pipeline and the LLM request
+		connCtx, conn := h.connections.Open(regionCtx, clientIP, path)
+		defer h.connections.Close(conn)
+		ctx, cancel := context.WithCancelCause(connCtx)
+		defer cancel(nil)
+		defer replay.Finish()
+
+		// A failed write detaches this client; events are still buffered for it to reconnect
+		replay.Attach()
+		detach := sync.OnceFunc(replay.Detach)
+		defer detach()
+		go cancelWhenAbandoned(ctx, replay, h.replays.Window(), cancel)
+
+		out := &eventWriter{w: w, conn: conn, cancel: func(error) { detach() }, requestID: requestID, replay: replay}
+		defer out.close()
+		go out.heartbeat(ctx, h.keepalive)
+
 		defer func() {
 			if r := recover(); r != nil {
-				log.Printf("[PANIC] Client %s: Panic in stream writer: %v", clientIP, r)
+				log.Printf("[PANIC] Client %s (request %s): Panic in stream writer: %v", clientIP, requestID, r)
 			}
-			log.Printf("[STREAM] Client %s: Stream writer ended", clientIP)
+			log.Printf("[STREAM] Client %s (request %s): Stream writer ended", clientIP, requestID)
 		}()
 
 		messageID := fmt.Sprintf("msg-%d", time.Now().UnixNano())
-		log.Printf("[STREAM] Client %s: Starting stream for message ID: %s", clientIP, messageID)
+		log.Printf("[STREAM] Client %s (request %s): Starting stream for message ID: %s", clientIP, requestID, messageID)
 
 		// Send streaming start event
-		if err := h.writeEvent(w, StreamingStartEvent{
-			Type:      "STREAMING_START",
-			MessageID: messageID,
+		if err := out.agui(agui.StreamingStartEvent{
+			Type:           agui.EventStreamingStart,
+			MessageID:      messageID,
+			ConversationID: req.ConversationID,
 		}); err != nil {
-			log.Printf("[ERROR] Client %s: Error writing start event: %v", clientIP, err)
+			log.Printf("[ERROR] Client %s (request %s): Error writing start event: %v", clientIP, requestID, err)
 			return
 		}
-		w.Flush()
 
-		// Small delay to simulate processing
-		time.Sleep(200 * time.Millisecond)
+		run := chat.NewRun(decodedMessage, clientIP)
+		run.ConversationID = req.ConversationID
+		run.Continue = req.Continue
+		run.EditOf = req.EditOf
+		run.RequestID = requestID
+		run.Fingerprint = fingerprint
+		run.UserID = userID
+		run.SessionID = sessionID
+		run.UserContext = req.Context.Sanitized()
+		run.Language = language
+		run.AcceptLanguage = acceptLanguage
+		run.SetEmitter(func(event interface{}) error {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("connection closed: %w", context.Cause(ctx))
+			}
+			return out.agui(event)
+		})
+		run.SetTextEmitter(func(content string, final bool) error {
+			return run.Emit(agui.CreateTextEvent(content, final))
+		})
+
+		generation := h.generations.Start(req.ConversationID, userID, sessionID, cancel)
+		defer h.generations.Finish(generation)
 
-		// Generate response using decoded message
-		response := h.generateResponse(decodedMessage)
-		log.Printf("[RESPONSE] Client %s: Generated response: %s", clientIP, response)
-		
-		// Stream the response word by word with better error handling
-		words := strings.Fields(response)
-		for i, word := range words {
-			content := word
-			if i < len(words)-1 {
-				content += " "
+		end := agui.StreamingEndEvent{Type: agui.EventStreamingEnd}
+		if err := h.pipeline.Execute(ctx, run); errors.Is(context.Cause(ctx), stream.ErrStopped) {
+			log.Printf("[STREAM] Client %s (request %s): Generation stopped by client on stream %s", clientIP, requestID, conn.ID)
+			end.Reason = agui.StreamEndCancelled
+		} else if err != nil {
+			if errors.Is(context.Cause(ctx), errClientDisconnected) {
+				log.Printf("[STREAM] Client %s (request %s): Client disconnected from stream %s, generation stopped", clientIP, requestID, conn.ID)
+				return
 			}
-			
-			if err := h.writeEvent(w, TextMessageEvent{
-				Type:       "TEXT_MESSAGE_CONTENT",
-				Content:    content,
-				IsComplete: i == len(words)-1,
-			}); err != nil {
-				log.Printf("[ERROR] Client %s: Error writing text event: %v", clientIP, err)
-				break
+			if ctx.Err() != nil {
+				log.Printf("[STREAM] Client %s (request %s): Stream %s closed by server: %v", clientIP, requestID, conn.ID, err)
+				return
 			}
-
-			// Flush after each word
-			w.Flush()
-			
-			// Add small delay between words
-			time.Sleep(50 * time.Millisecond)
+			log.Printf("[ERROR] Client %s (request %s): Chat pipeline failed: %v", clientIP, requestID, err)
+			out.agui(chatError(err))
 		}
 
 		// Always send streaming end event to ensure connection closes
-		if err := h.writeEvent(w, StreamingEndEvent{
-			Type: "STREAMING_END",
-		}); err != nil {
-			log.Printf("[ERROR] Client %s: Error writing end event: %v", clientIP, err)
+		if err := out.agui(end); err != nil {
+			log.Printf("[ERROR] Client %s (request %s): Error writing end event: %v", clientIP, requestID, err)
 		}
-		
-		w.Flush()
-		log.Printf("[STREAM] Client %s: Stream completed for message ID: %s", clientIP, messageID)
+
+		log.Printf("[STREAM] Client %s (request %s): Stream completed for message ID: %s", clientIP, requestID, messageID)
 		
 		// Force close the connection by sending a close signal
 		time.Sleep(100 * time.Millisecond)
@@ -217,39 +527,422 @@ func (h *ChatHandler) StreamChat(c *fiber.Ctx) error {
 	return nil
 }
 
-// generateResponse creates a simple response (will be replaced with OpenAI integration)
-func (h *ChatHandler) generateResponse(message string) string {
-	message = strings.ToLower(message)
-	
-	if strings.Contains(message, "hiking") || strings.Contains(message, "trail") {
-		return "I found some great hiking trails in your area! Here are a few popular options: Bear Mountain Trail (moderate difficulty, 3.2 miles), Sunset Ridge Loop (easy, 1.8 miles), and Eagle Peak Summit (challenging, 5.7 miles). Would you like more details about any of these trails?"
+// resume continues a stream for a client that reconnected with Last-Event-ID:
+// the events it missed are replayed, then new ones follow as the answer is
+// generated. Unknown, expired and completed streams, and streams that dropped
+// events the client missed, get 204 No Content, which stops EventSource from
+// reconnecting. Streams of run tokens are resumed by the token's holders.
+func (h *ChatHandler) resume(c *fiber.Ctx, lastEventID string, owner streamOwner) error {
+	clientIP := c.IP()
+	requestID := middleware.GetRequestID(c)
+
+	replay, after := h.replays.Lookup(lastEventID)
+	if replay == nil || !owner.owns(replay) {
+		streamResumes.WithLabelValues("unknown").Inc()
+		log.Printf("[STREAM] Client %s (request %s): No stream to resume for Last-Event-ID %s", clientIP, requestID, lastEventID)
+		return c.SendStatus(fiber.StatusNoContent)
 	}
-	
-	if strings.Contains(message, "cycling") || strings.Contains(message, "bike") {
-		return "There are several excellent cycling routes nearby! I recommend the Riverside Path (easy, 8 miles of paved trail), Mountain Loop Road (moderate, 12 miles with scenic views), and the Advanced Hill Circuit (challenging, 15 miles with steep climbs). Which type of cycling experience are you looking for?"
+	events, done, _, ok := replay.Since(after)
+	if !ok {
+		streamResumes.WithLabelValues("expired").Inc()
+		log.Printf("[STREAM] Client %s (request %s): Events after %s are no longer buffered", clientIP, requestID, lastEventID)
+		return c.SendStatus(fiber.StatusNoContent)
 	}
-	
-	if strings.Contains(message, "restaurant") || strings.Contains(message, "food") || strings.Contains(message, "eat") {
-		return "Here are some great local restaurants: The Mountain View Café (farm-to-table, outdoor seating), Trailhead Grill (burgers and craft beer), and Summit Bistro (fine dining with valley views). What type of cuisine are you in the mood for?"
+	if done && len(events) == 0 {
+		streamResumes.WithLabelValues("completed").Inc()
+		return c.SendStatus(fiber.StatusNoContent)
 	}
-	
-	// Default response
-	return "Thanks for your message! I'm here to help you discover outdoor activities, restaurants, and local attractions. You can ask me about hiking trails, cycling routes, places to eat, or any other activities you're interested in. What would you like to explore today?"
+	streamResumes.WithLabelValues("resumed").Inc()
+
+	setStreamHeaders(c)
+	path := c.Path()
+	regionCtx := residency.Detach(c.UserContext())
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		connCtx, conn := h.connections.Open(regionCtx, clientIP, path)
+		defer h.connections.Close(conn)
+		ctx, cancel := context.WithCancelCause(connCtx)
+		defer cancel(nil)
+
+		replay.Attach()
+		defer replay.Detach()
+
+		out := &eventWriter{w: w, conn: conn, cancel: cancel, requestID: requestID}
+		defer out.close()
+		go out.heartbeat(ctx, h.keepalive)
+
+		log.Printf("[STREAM] Client %s (request %s): Resuming stream %s after event %d", clientIP, requestID, replay.ID, after)
+		for {
+			events, done, changed, ok := replay.Since(after)
+			if !ok {
+				log.Printf("[STREAM] Client %s (request %s): Fell behind stream %s after event %d", clientIP, requestID, replay.ID, after)
+				return
+			}
+			for _, event := range events {
+				if out.replayed(event) != nil {
+					return
+				}
+				after = event.Seq
+			}
+			if done {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+			}
+		}
+	})
+
+	return nil
 }
 
-// writeEvent writes an AG-UI event to the stream
-func (h *ChatHandler) writeEvent(w *bufio.Writer, event interface{}) error {
-	data, err := json.Marshal(event)
+// setStreamHeaders sets the headers of Server-Sent Events with proper CORS
+func setStreamHeaders(c *fiber.Ctx) {
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("Access-Control-Allow-Origin", "*")
+	c.Set("Access-Control-Allow-Headers", "Cache-Control,"+lastEventIDHeader)
+	c.Set("Access-Control-Expose-Headers", "Content-Type,Cache-Control,Connection,"+middleware.RequestIDHeader+","+middleware.SessionHeader)
+}
+
+// cancelWhenAbandoned cancels a stream once no client has followed it for
+// window, so answers nobody reconnects for stop generating
+func cancelWhenAbandoned(ctx context.Context, replay *stream.Replay, window time.Duration, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if replay.Abandoned(window) {
+				cancel(errClientDisconnected)
+				return
+			}
+		}
+	}
+}
+
+// respond is the final pipeline handler: it streams the answer from the LLM,
+// or the canned response word by word when no LLM is configured. Dry runs only
+// record that they got this far.
+func (h *ChatHandler) respond(ctx context.Context, run *chat.Run) error {
+	if run.DryRun {
+		run.Metadata[dryRunAnsweredKey] = true
+		return nil
+	}
+	if h.llm != nil {
+		return h.respondWithLLM(ctx, run)
+	}
+
+	// Small delay to simulate processing
+	if err := sleep(ctx, 200*time.Millisecond); err != nil {
+		return err
+	}
+
+	// Generate response using decoded message
+	response := h.generateResponse(run.Message, run.AnswerLanguage())
+	log.Printf("[RESPONSE] Client %s: Generated response: %s", run.ClientIP, response)
+
+	// Stream the response word by word with better error handling
+	for _, word := range chat.SplitWords(response) {
+		if err := run.EmitText(ctx, word); err != nil {
+			return fmt.Errorf("error writing text event: %w", err)
+		}
+
+		// Add small delay between words
+		if err := sleep(ctx, 50*time.Millisecond); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// respondWithLLM streams model output token by token into the run. When the model
+// calls tools, their results are sent back and it continues the answer with them;
+// after maxToolRounds it has to answer without further calls. The run holds a
+// generation slot of the limiter throughout; while it waits for one, the client
+// is sent a QUEUED event with its position.
+func (h *ChatHandler) respondWithLLM(ctx context.Context, run *chat.Run) error {
+	release, err := h.limiter.Acquire(ctx, func(position int) error {
+		log.Printf("[LLM] Client %s: %s queued at position %d", run.ClientIP, run.ID, position)
+		return run.Emit(agui.CreateQueuedEvent(position, h.limiter.MaxWait()))
+	})
+	if err != nil {
+		return fmt.Errorf("waiting for a generation slot: %w", err)
+	}
+	defer release()
+
+	messages := h.promptMessages(run)
+	tools := h.offeredTools()
+
+	for round := 0; ; round++ {
+		req := llm.CompletionRequest{Messages: messages}
+		if round < maxToolRounds {
+			req.Tools = tools
+		}
+
+		var text strings.Builder
+		completion, err := h.llm.StreamCompletion(ctx, req, func(delta string) error {
+			text.WriteString(delta)
+			return run.EmitText(ctx, delta)
+		})
+		if err != nil {
+			return fmt.Errorf("llm completion failed: %w", err)
+		}
+
+		if completion.Usage != nil {
+			run.AddUsage(completion.Usage.PromptTokens, completion.Usage.CompletionTokens)
+			log.Printf("[LLM] Client %s: %s used %d prompt + %d completion tokens",
+				run.ClientIP, run.ID, completion.Usage.PromptTokens, completion.Usage.CompletionTokens)
+		}
+		if len(completion.ToolCalls) == 0 {
+			return nil
+		}
+
+		messages = append(messages, llm.Message{Role: llm.RoleAssistant, Content: text.String(), ToolCalls: completion.ToolCalls})
+		for _, call := range completion.ToolCalls {
+			result, err := h.callTool(ctx, run, call)
+			if err != nil {
+				return err
+			}
+			messages = append(messages, result)
+		}
+	}
+}
+
+// promptMessages assembles the messages sent to the model for a run: the system
+// prompt, what the stages gathered, the history and the user's message
+func (h *ChatHandler) promptMessages(run *chat.Run) []llm.Message {
+	messages := []llm.Message{{Role: llm.RoleSystem, Content: h.systemPrompt}}
+	if language := chat.LanguagePrompt(run.AnswerLanguage()); language != "" {
+		messages = append(messages, llm.Message{Role: llm.RoleSystem, Content: language})
+	}
+	if instructions := chat.InstructionsPrompt(run.Instructions); instructions != "" {
+		messages = append(messages, llm.Message{Role: llm.RoleSystem, Content: instructions})
+	}
+	if userContext := chat.ContextPrompt(run.UserContext); userContext != "" {
+		messages = append(messages, llm.Message{Role: llm.RoleSystem, Content: userContext})
+	}
+	if notes := chat.NotesPrompt(run.Notes); notes != "" {
+		messages = append(messages, llm.Message{Role: llm.RoleSystem, Content: notes})
+	}
+	if summary := chat.SummaryPrompt(run.Summary); summary != "" {
+		messages = append(messages, llm.Message{Role: llm.RoleSystem, Content: summary})
+	}
+	for _, turn := range run.History {
+		messages = append(messages, llm.Message{Role: turn.Role, Content: chat.TurnContent(turn)})
+	}
+	if run.Continued != nil {
+		messages = append(messages,
+			llm.Message{Role: llm.RoleAssistant, Content: run.Continued.Content},
+			llm.Message{Role: llm.RoleSystem, Content: chat.ContinuePrompt})
+	} else {
+		messages = append(messages, llm.Message{Role: llm.RoleUser, Content: run.Message})
+	}
+	return messages
+}
+
+// offeredTools returns the registered tools as offered to the model
+func (h *ChatHandler) offeredTools() []llm.Tool {
+	var tools []llm.Tool
+	for _, tool := range h.tools.Tools() {
+		tools = append(tools, llm.Tool{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters})
+	}
+	return tools
+}
+
+// callTool runs a tool call between TOOL_CALL_START and TOOL_CALL_COMPLETE events and
+// returns the tool message answering it. Tool failures are reported to the model,
+// which can then answer without the lookup; only failing to emit events aborts the run.
+func (h *ChatHandler) callTool(ctx context.Context, run *chat.Run, call llm.ToolCall) (llm.Message, error) {
+	if call.Arguments == "" {
+		call.Arguments = "{}"
+	}
+	// Arguments are only decoded for the events; tools parse them into their own types
+	var args map[string]interface{}
+	json.Unmarshal([]byte(call.Arguments), &args)
+
+	if err := run.Emit(agui.CreateToolCallStartEvent(call.ID, call.Name, args)); err != nil {
+		return llm.Message{}, err
+	}
+
+	result, err := h.tools.Call(ctx, run, call.Name, json.RawMessage(call.Arguments))
+	if err != nil {
+		log.Printf("[CHAT] Run %s: tool %s failed: %v", run.ID, call.Name, err)
+		if !errors.Is(err, chat.ErrUnknownTool) && !errors.Is(err, chat.ErrInvalidToolArguments) &&
+			!errors.Is(err, chat.ErrToolSignInRequired) {
+			err = errors.New("lookup failed")
+		}
+	}
+
+	if emitErr := run.Emit(agui.CreateToolCallCompleteEvent(call.ID, call.Name, args, result, err)); emitErr != nil {
+		return llm.Message{}, emitErr
+	}
+	if err != nil {
+		result = fiber.Map{"error": err.Error()}
+	}
+
+	content, err := json.Marshal(result)
+	if err != nil {
+		return llm.Message{}, fmt.Errorf("failed to encode %s result: %w", call.Name, err)
+	}
+	return llm.Message{Role: llm.RoleTool, ToolCallID: call.ID, Content: string(content)}, nil
+}
+
+// clientFingerprint identifies an anonymous client by a hash of its IP address,
+// user agent and languages, so guests can be told apart behind a shared IP
+// without storing any of them
+func clientFingerprint(c *fiber.Ctx) string {
+	sum := sha256.Sum256([]byte(c.IP() + "\x00" + c.Get(fiber.HeaderUserAgent) + "\x00" + c.Get(fiber.HeaderAcceptLanguage)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// generateResponse creates a canned keyword-based response in language, used
+// when no LLM is configured
+func (h *ChatHandler) generateResponse(message, language string) string {
+	return cannedResponse(message, language)
+}
+
+// sleep waits for d, returning early with the context's error when it is cancelled
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// eventWriter serializes writes to a stream from the run and the heartbeat. A
+// failed write means the client disconnected and calls cancel.
+type eventWriter struct {
+	w      *bufio.Writer
+	conn   *stream.Conn
+	cancel context.CancelCauseFunc
+	closed bool
+	mutex  sync.Mutex
+	// requestID is added to every AG-UI event so client traces match server logs
+	requestID string
+	// replay buffers the events of a new stream for clients that reconnect; it
+	// is nil when writing a resumed stream
+	replay *stream.Replay
+	// sequence numbers the AG-UI events of the stream; order keeps numbers and
+	// replay buffer in the same order when events are sent concurrently
+	sequence int64
+	order    sync.Mutex
+}
+
+// agui writes an AG-UI event in its envelope, see agui.Envelope, and counts as
+// activity of the connection. Events of a new stream are buffered with their
+// event ID, and keep being buffered after the client disconnected so it can
+// resume.
+func (e *eventWriter) agui(event interface{}) error {
+	e.order.Lock()
+	defer e.order.Unlock()
+	e.sequence++
+	envelope := agui.NewEnvelope(event, e.sequence, e.requestID)
+	if e.replay == nil {
+		frame, err := envelope.EncodeSSE("")
+		if err != nil {
+			return err
+		}
+		return e.send(string(frame))
+	}
+
+	buffered, err := e.replay.Append(func(eventID string) ([]byte, error) {
+		return envelope.EncodeSSE(eventID)
+	})
 	if err != nil {
-		log.Printf("Error marshaling event: %v", err)
 		return err
 	}
+	if err := e.replayed(buffered); err != nil && !e.isClosed() {
+		return err
+	}
+	return nil
+}
 
-	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+// event writes a JSON event of the OpenAI-compatible stream, which has no
+// event IDs, and counts as activity of the connection
+func (e *eventWriter) event(event interface{}) error {
+	data, err := json.Marshal(event)
 	if err != nil {
-		log.Printf("Error writing event: %v", err)
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	return e.send("data: " + string(data) + "\n\n")
+}
+
+// replayed writes a buffered event
+func (e *eventWriter) replayed(event stream.Event) error {
+	return e.send(event.Data)
+}
+
+// send writes an encoded event and counts as activity of the connection
+func (e *eventWriter) send(data string) error {
+	if err := e.write(data); err != nil {
 		return err
 	}
-	
+	e.conn.Touch()
 	return nil
 }
+
+// isClosed reports whether the stream no longer accepts writes
+func (e *eventWriter) isClosed() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.closed
+}
+
+// heartbeat writes keepalive comments until ctx is done, so proxies and load
+// balancers do not drop the connection while the model is thinking, and a
+// failed write detects a disconnected client between events. Heartbeats do not
+// count as activity, so streams stuck generating are still reaped as idle.
+func (e *eventWriter) heartbeat(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if e.write(": keepalive\n\n") != nil {
+				return
+			}
+		}
+	}
+}
+
+// write writes and flushes data, cancelling the stream when the client is gone
+func (e *eventWriter) write(data string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.closed {
+		return errors.New("stream closed")
+	}
+
+	_, err := e.w.WriteString(data)
+	if err == nil {
+		err = e.w.Flush()
+	}
+	if err != nil {
+		e.closed = true
+		e.cancel(errClientDisconnected)
+		return fmt.Errorf("%w: %v", errClientDisconnected, err)
+	}
+	return nil
+}
+
+// close stops further writes; the underlying writer is invalid once the stream writer returns
+func (e *eventWriter) close() {
+	e.mutex.Lock()
+	e.closed = true
+	e.mutex.Unlock()
+}
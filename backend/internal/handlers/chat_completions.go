@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"community-chatbot/internal/agui"
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/residency"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// completionModel is the model name reported to OpenAI clients; answers come
+// from the configured model through the chat pipeline, whatever they ask for
+const completionModel = "community-chatbot"
+
+// maxCompletionHistory is how many earlier user and assistant messages of a
+// chat completion request are given to the model
+const maxCompletionHistory = 20
+
+// completionErrorTypes are the OpenAI error types of error kinds; other kinds are api_error
+var completionErrorTypes = map[apperr.Kind]string{
+	apperr.Invalid:      "invalid_request_error",
+	apperr.NotFound:     "not_found_error",
+	apperr.Unauthorized: "authentication_error",
+	apperr.Forbidden:    "permission_error",
+	apperr.RateLimited:  "rate_limit_error",
+}
+
+// completionContent is the content of a message, sent as a string or as an
+// array of content parts of which the text parts are kept
+type completionContent string
+
+// UnmarshalJSON reads string content, text content parts and null
+func (c *completionContent) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*c = completionContent(text)
+		return nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return errors.New("content must be a string or an array of content parts")
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Type == "text" {
+			texts = append(texts, part.Text)
+		}
+	}
+	*c = completionContent(strings.Join(texts, "\n"))
+	return nil
+}
+
+// ChatCompletionMessage is a message of an OpenAI chat completion request
+type ChatCompletionMessage struct {
+	Role    string            `json:"role" validate:"required,oneof=system developer user assistant tool"`
+	Content completionContent `json:"content"`
+}
+
+// ChatCompletionRequest is an OpenAI chat completion request. The model, tools
+// and sampling options of clients are ignored: answers use the community's
+// model, system prompt, guardrails and tools.
+type ChatCompletionRequest struct {
+	Model         string                  `json:"model" validate:"max=100"`
+	Messages      []ChatCompletionMessage `json:"messages" validate:"required,min=1,max=100,dive"`
+	Stream        bool                    `json:"stream"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+// completionUsage is the token usage of a chat completion
+type completionUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// completionMessage is the answer of a chat completion, or the delta of a chunk
+type completionMessage struct {
+	Role    string  `json:"role,omitempty"`
+	Content *string `json:"content,omitempty"`
+}
+
+// completionChoice is the single choice of a chat completion or chunk
+type completionChoice struct {
+	Index        int                `json:"index"`
+	Message      *completionMessage `json:"message,omitempty"`
+	Delta        *completionMessage `json:"delta,omitempty"`
+	FinishReason *string            `json:"finish_reason"`
+}
+
+// ChatCompletion is an OpenAI chat completion, or a chunk of a streamed one
+type ChatCompletion struct {
+	ID      string             `json:"id"`
+	Object  string             `json:"object"`
+	Created int64              `json:"created"`
+	Model   string             `json:"model"`
+	Choices []completionChoice `json:"choices"`
+	Usage   *completionUsage   `json:"usage,omitempty"`
+}
+
+// completionError is an OpenAI error response
+type completionError struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+	} `json:"error"`
+}
+
+// ChatCompletions answers OpenAI chat completion requests through the chat
+// pipeline, so clients that only speak the OpenAI API get the same retrieval,
+// tools and moderation as the chat stream. Requests are stateless: the earlier
+// messages are the conversation, and client system messages are ignored. Tool
+// calls and other chat events are not sent; with stream set the answer text
+// streams as chat.completion.chunk events ending in [DONE]. Personal API keys
+// are accepted as bearer tokens. Runs over the daily quota fail with 429.
+// Answers are in the language of lang, the user's preferences or the
+// Accept-Language header, see chat.Run.AnswerLanguage.
+//
+// Query parameters: lang (en, de, fr, es or it)
+//
+// Request body: model, messages ending in a user message, stream and
+// stream_options.include_usage
+//
+// Returns:
+//   - 200: chat.completion, or text/event-stream of chat.completion.chunk
+//   - 400: Invalid body or lang, or last message not from the user
+//   - 429: Rate limit or quota exceeded
+//   - 500: Internal server error
+func (h *ChatHandler) ChatCompletions(c *fiber.Ctx) error {
+	var req ChatCompletionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return completionFailed(c, apperr.Wrap(apperr.Invalid, "invalid request body", err))
+	}
+	if err := validate.Struct(&req); err != nil {
+		return completionFailed(c, apperr.New(apperr.Invalid, validationMessage(err)))
+	}
+	run, err := completionRun(req.Messages)
+	if err != nil {
+		return completionFailed(c, err)
+	}
+
+	language, err := answerLanguage(c, "")
+	if err != nil {
+		return completionFailed(c, err)
+	}
+
+	owner := requestOwner(c)
+	run.Language = language
+	run.AcceptLanguage = strings.Clone(c.Get(fiber.HeaderAcceptLanguage))
+	run.ClientIP = c.IP()
+	run.RequestID = middleware.GetRequestID(c)
+	run.Fingerprint = clientFingerprint(c)
+	run.UserID = owner.UserID
+	run.SessionID = owner.SessionID
+	quotaExceeded := captureQuotaExceeded(run)
+	log.Printf("[CHAT] Client %s (request %s): Received chat completion (%d messages, stream: %t)", run.ClientIP, run.RequestID, len(req.Messages), req.Stream)
+
+	completion := ChatCompletion{
+		ID:      "chatcmpl-" + strings.TrimPrefix(run.ID, "run-"),
+		Created: run.StartedAt.Unix(),
+		Model:   completionModel,
+	}
+	if req.Stream {
+		return h.streamCompletion(c, run, completion, quotaExceeded, req.StreamOptions != nil && req.StreamOptions.IncludeUsage)
+	}
+
+	if err := h.pipeline.Execute(c.UserContext(), run); err != nil {
+		log.Printf("[ERROR] Client %s (request %s): Chat pipeline failed: %v", run.ClientIP, run.RequestID, err)
+		return completionFailed(c, err)
+	}
+	if exceeded := quotaExceeded(); exceeded != nil {
+		return c.Status(fiber.StatusTooManyRequests).JSON(exceeded)
+	}
+	stop := "stop"
+	completion.Object = "chat.completion"
+	completion.Choices = []completionChoice{{
+		Message:      &completionMessage{Role: "assistant", Content: &run.Response},
+		FinishReason: &stop,
+	}}
+	completion.Usage = runUsage(run)
+	return c.JSON(completion)
+}
+
+// streamCompletion streams the answer of a run as chat completion chunks. The
+// first chunk carries the role, the last the finish reason, followed by the
+// usage when requested; failed runs and runs over quota end with an error instead.
+func (h *ChatHandler) streamCompletion(c *fiber.Ctx, run *chat.Run, completion ChatCompletion, quotaExceeded func() *completionError, includeUsage bool) error {
+	setStreamHeaders(c)
+	completion.Object = "chat.completion.chunk"
+	path := c.Path()
+	regionCtx := residency.Detach(c.UserContext())
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The stream is cancelled when the client disconnects, which aborts the pipeline and the LLM request
+		connCtx, conn := h.connections.Open(regionCtx, run.ClientIP, path)
+		defer h.connections.Close(conn)
+		ctx, cancel := context.WithCancelCause(connCtx)
+		defer cancel(nil)
+
+		out := &eventWriter{w: w, conn: conn, cancel: cancel}
+		defer out.close()
+		go out.heartbeat(ctx, h.keepalive)
+
+		chunk := func(delta completionMessage, finishReason *string) error {
+			completion.Choices = []completionChoice{{Delta: &delta, FinishReason: finishReason}}
+			return out.event(completion)
+		}
+		empty := ""
+		if err := chunk(completionMessage{Role: "assistant", Content: &empty}, nil); err != nil {
+			return
+		}
+		run.SetTextEmitter(func(content string, final bool) error {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("connection closed: %w", context.Cause(ctx))
+			}
+			if content == "" {
+				return nil
+			}
+			return chunk(completionMessage{Content: &content}, nil)
+		})
+
+		if err := h.pipeline.Execute(ctx, run); err != nil {
+			if ctx.Err() != nil {
+				log.Printf("[STREAM] Client %s (request %s): Chat completion stream %s closed: %v", run.ClientIP, run.RequestID, conn.ID, context.Cause(ctx))
+				return
+			}
+			log.Printf("[ERROR] Client %s (request %s): Chat pipeline failed: %v", run.ClientIP, run.RequestID, err)
+			out.event(newCompletionError(err))
+			return
+		}
+		if exceeded := quotaExceeded(); exceeded != nil {
+			out.event(exceeded)
+			return
+		}
+
+		stop := "stop"
+		if err := chunk(completionMessage{}, &stop); err != nil {
+			return
+		}
+		if includeUsage {
+			completion.Choices, completion.Usage = []completionChoice{}, runUsage(run)
+			if err := out.event(completion); err != nil {
+				return
+			}
+		}
+		out.write("data: [DONE]\n\n")
+	})
+	return nil
+}
+
+// completionRun creates the run of a chat completion request: the last message
+// is the one answered and the earlier user and assistant messages its history
+func completionRun(messages []ChatCompletionMessage) (*chat.Run, error) {
+	last := messages[len(messages)-1]
+	message := strings.TrimSpace(string(last.Content))
+	if last.Role != "user" || message == "" {
+		return nil, apperr.New(apperr.Invalid, "the last message must be a user message with content")
+	}
+	if utf8.RuneCountInString(message) > 4000 {
+		return nil, apperr.New(apperr.Invalid, "the last message must not be longer than 4000 characters")
+	}
+
+	run := chat.NewRun(message, "")
+	for _, m := range messages[:len(messages)-1] {
+		if (m.Role == models.MessageRoleUser || m.Role == models.MessageRoleAssistant) && m.Content != "" {
+			run.History = append(run.History, chat.Turn{Role: m.Role, Content: string(m.Content)})
+		}
+	}
+	if len(run.History) > maxCompletionHistory {
+		run.History = run.History[len(run.History)-maxCompletionHistory:]
+	}
+	return run, nil
+}
+
+// captureQuotaExceeded discards the events of a run, which chat completions
+// have no place for, except QUOTA_EXCEEDED, MESSAGE_FLOOD and DUPLICATE_MESSAGE:
+// the returned function reports them as the error of the request
+func captureQuotaExceeded(run *chat.Run) func() *completionError {
+	var exceeded *completionError
+	run.SetEmitter(func(event interface{}) error {
+		if e, ok := event.(agui.Event); ok {
+			switch data := e.Data.(type) {
+			case agui.QuotaExceededData:
+				exceeded = &completionError{}
+				exceeded.Error.Message = data.Message
+				exceeded.Error.Type = completionErrorTypes[apperr.RateLimited]
+				exceeded.Error.Code = strings.ToLower(agui.ErrorCodeQuotaExceeded)
+			case agui.MessageFloodData:
+				exceeded = &completionError{}
+				exceeded.Error.Message = data.Message
+				exceeded.Error.Type = completionErrorTypes[apperr.RateLimited]
+				exceeded.Error.Code = strings.ToLower(agui.ErrorCodeMessageFlood)
+			case agui.DuplicateMessageData:
+				exceeded = &completionError{}
+				exceeded.Error.Message = data.Message
+				exceeded.Error.Type = completionErrorTypes[apperr.RateLimited]
+				exceeded.Error.Code = strings.ToLower(agui.ErrorCodeDuplicateMessage)
+			}
+		}
+		return nil
+	})
+	return func() *completionError { return exceeded }
+}
+
+// runUsage returns the token usage of a run
+func runUsage(run *chat.Run) *completionUsage {
+	return &completionUsage{
+		PromptTokens:     run.PromptTokens,
+		CompletionTokens: run.CompletionTokens,
+		TotalTokens:      run.PromptTokens + run.CompletionTokens,
+	}
+}
+
+// newCompletionError returns the OpenAI error of a failed request, with the
+// message failed chat streams show
+func newCompletionError(err error) completionError {
+	event := chatError(err)
+	kind := apperr.KindOf(err)
+	errorType, ok := completionErrorTypes[kind]
+	if !ok {
+		errorType = "api_error"
+	}
+
+	var response completionError
+	response.Error.Message = event.Message
+	response.Error.Type = errorType
+	response.Error.Code = strings.ToLower(event.Code)
+	return response
+}
+
+// completionFailed responds with the OpenAI error of err and the status of its kind
+func completionFailed(c *fiber.Ctx, err error) error {
+	return c.Status(apperr.KindOf(err).Status()).JSON(newCompletionError(err))
+}
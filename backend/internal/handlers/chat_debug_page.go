@@ -0,0 +1,144 @@
+package handlers
+
+import "github.com/gofiber/fiber/v2"
+
+// debugChatPage is a minimal chat client of the AG-UI stream. It opens
+// /api/v1/chat/stream with EventSource, shows the answer as it streams and
+// lists every event with its payload.
+const debugChatPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Chat stream debugger</title>
+  <style>
+    body { margin: 0; font: 14px/1.4 system-ui, sans-serif; display: grid; grid-template-columns: 1fr 1fr; height: 100vh; }
+    section { display: flex; flex-direction: column; min-height: 0; border-right: 1px solid #ddd; }
+    h2 { margin: 0; padding: 8px 12px; font-size: 14px; background: #f4f5f7; border-bottom: 1px solid #ddd; }
+    #transcript, #events { flex: 1; overflow: auto; padding: 12px; }
+    .message { margin: 0 0 10px; padding: 8px 10px; border-radius: 6px; white-space: pre-wrap; }
+    .user { background: #e3f0ff; }
+    .assistant { background: #f4f5f7; }
+    .error { background: #fde8e8; color: #9b1c1c; }
+    .meta { color: #7b8794; font-size: 12px; }
+    details { border-bottom: 1px solid #eee; padding: 4px 0; }
+    summary { cursor: pointer; font-family: monospace; }
+    pre { margin: 4px 0; white-space: pre-wrap; word-break: break-all; font-size: 12px; }
+    form { display: flex; gap: 6px; padding: 8px 12px; border-top: 1px solid #ddd; }
+    input { flex: 1; padding: 6px; }
+  </style>
+</head>
+<body>
+  <section>
+    <h2>Chat <span class="meta" id="conversation"></span></h2>
+    <div id="transcript"></div>
+    <form id="form">
+      <input id="message" placeholder="Ask about activities..." autocomplete="off" autofocus>
+      <button>Send</button>
+      <button type="button" id="reset">New conversation</button>
+    </form>
+  </section>
+  <section>
+    <h2>Events <span class="meta" id="status">idle</span></h2>
+    <div id="events"></div>
+  </section>
+  <script>
+    const transcript = document.getElementById("transcript");
+    const events = document.getElementById("events");
+    const status = document.getElementById("status");
+    let conversationId = "";
+    let source = null;
+
+    function append(parent, className, text) {
+      const el = document.createElement("div");
+      el.className = className;
+      el.textContent = text;
+      parent.appendChild(el);
+      parent.scrollTop = parent.scrollHeight;
+      return el;
+    }
+
+    function logEvent(raw, started) {
+      let event;
+      try { event = JSON.parse(raw); } catch (e) { event = { type: "UNPARSEABLE" }; }
+      const details = document.createElement("details");
+      const summary = document.createElement("summary");
+      summary.textContent = "+" + (performance.now() - started).toFixed(0) + "ms " + event.type;
+      const pre = document.createElement("pre");
+      pre.textContent = JSON.stringify(event, null, 2);
+      details.append(summary, pre);
+      events.appendChild(details);
+      events.scrollTop = events.scrollHeight;
+      return event;
+    }
+
+    function send(message) {
+      if (source) source.close();
+      append(transcript, "message user", message);
+      const answer = append(transcript, "message assistant", "");
+      const params = new URLSearchParams({ message });
+      if (conversationId) params.set("conversation_id", conversationId);
+
+      const started = performance.now();
+      status.textContent = "connecting";
+      source = new EventSource("/api/v1/chat/stream?" + params);
+      source.onopen = () => { status.textContent = "streaming"; };
+      source.onmessage = (e) => {
+        const event = logEvent(e.data, started);
+        switch (event.type) {
+        case "STREAMING_START":
+          conversationId = event.conversationId || conversationId;
+          document.getElementById("conversation").textContent = conversationId;
+          break;
+        case "TEXT_MESSAGE_CONTENT":
+          answer.textContent += event.content || "";
+          transcript.scrollTop = transcript.scrollHeight;
+          break;
+        case "ACTIVITIES_FOUND":
+          for (const activity of (event.data && event.data.activities) || []) {
+            append(transcript, "meta", "Activity: " + (activity.name || activity.id));
+          }
+          break;
+        case "ERROR":
+          append(transcript, "message error", (event.code ? event.code + ": " : "") + (event.message || (event.data && event.data.message) || ""));
+          break;
+        case "STREAMING_END":
+          source.close();
+          status.textContent = "done in " + (performance.now() - started).toFixed(0) + "ms";
+          break;
+        }
+      };
+      // The server closes the stream after the answer; EventSource would reconnect
+      source.onerror = () => {
+        source.close();
+        if (status.textContent === "streaming" || status.textContent === "connecting") status.textContent = "closed";
+      };
+    }
+
+    document.getElementById("form").addEventListener("submit", (e) => {
+      e.preventDefault();
+      const input = document.getElementById("message");
+      if (input.value.trim()) send(input.value.trim());
+      input.value = "";
+    });
+    document.getElementById("reset").addEventListener("click", () => {
+      if (source) source.close();
+      conversationId = "";
+      document.getElementById("conversation").textContent = "";
+      transcript.textContent = "";
+      events.textContent = "";
+      status.textContent = "idle";
+    });
+  </script>
+</body>
+</html>
+`
+
+// GetDebugPage returns a built-in chat page for checking the stream without
+// the frontend; it is only served in development with DEBUG_CHAT set
+//
+// Returns:
+//   - 200: HTML page
+func (h *ChatHandler) GetDebugPage(c *fiber.Ctx) error {
+	c.Type("html", "utf-8")
+	return c.SendString(debugChatPage)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+
+	"community-chatbot/internal/agui"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/llm"
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// dryRunAnsweredKey is the run metadata key set when a dry run reaches the final handler
+const dryRunAnsweredKey = "dry_run_answered"
+
+// chatDryRunRequest is the body of DryRun: a message, optionally as a user and
+// in one of their conversations
+type chatDryRunRequest struct {
+	Message        string            `json:"message" validate:"required,max=4000"`
+	ConversationID string            `json:"conversation_id" validate:"max=64"`
+	UserID         uint              `json:"user_id"`
+	Context        *chat.UserContext `json:"context"`
+}
+
+// dryRunTool is a tool as offered to the model
+type dryRunTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// chatDryRunResult is what the model would have been sent for a message
+type chatDryRunResult struct {
+	Stages []string `json:"stages"`
+	// Answered is false when a stage, such as guardrails or the quota, answered
+	// instead of the model; Response is then what the user would have seen
+	Answered bool          `json:"answered"`
+	Response string        `json:"response,omitempty"`
+	Prompt   []llm.Message `json:"prompt,omitempty"`
+	// PromptTokens is estimated from the prompt's length
+	PromptTokens int           `json:"prompt_tokens"`
+	Activities   []interface{} `json:"activities"`
+	Tools        []dryRunTool  `json:"tools"`
+	// Events are everything the stages sent to the client, in order
+	Events []interface{} `json:"events"`
+}
+
+// DryRun runs a message through the chat pipeline without calling the model
+// or storing anything, and returns the prompt the model would get, the
+// activities retrieved for it and the tools it could call. With user_id and
+// conversation_id the run is personalized and continues that conversation
+// (admin only).
+//
+// Request body: {"message": "Easy hikes near the lake?", "user_id": 42, "conversation_id": "...", "context": {...}}
+//
+// Returns:
+//   - 200: Prompt, retrieved activities, offered tools and emitted events
+//   - 400: Invalid input data
+//   - 500: Internal server error
+func (h *ChatHandler) DryRun(c *fiber.Ctx) error {
+	var body chatDryRunRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	run := chat.NewRun(body.Message, c.IP())
+	run.RequestID = middleware.GetRequestID(c)
+	run.DryRun = true
+	run.UserID = body.UserID
+	run.ConversationID = body.ConversationID
+	run.UserContext = body.Context.Sanitized()
+
+	result := chatDryRunResult{Stages: h.pipeline.Stages(), Activities: []interface{}{}, Events: []interface{}{}}
+	run.SetEmitter(func(event interface{}) error {
+		result.Events = append(result.Events, event)
+		if found, ok := event.(agui.Event); ok && found.Type == agui.EventActivitiesFound {
+			if data, ok := found.Data.(agui.ActivitiesFoundData); ok {
+				result.Activities = append(result.Activities, data.Activities...)
+			}
+		}
+		return nil
+	})
+
+	if err := h.pipeline.Execute(c.UserContext(), run); err != nil {
+		return err
+	}
+
+	result.Response = run.Response
+	if answered, _ := run.Metadata[dryRunAnsweredKey].(bool); answered {
+		result.Answered = true
+		result.Prompt = h.promptMessages(run)
+		for _, message := range result.Prompt {
+			result.PromptTokens += chat.EstimateTokens(message.Content)
+		}
+	}
+	result.Tools = []dryRunTool{}
+	for _, tool := range h.offeredTools() {
+		result.Tools = append(result.Tools, dryRunTool{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters})
+	}
+
+	log.Printf("[CHAT] Dry run %s: %d notes, %d activities, answered %t", run.ID, len(run.Notes), len(result.Activities), result.Answered)
+	return c.JSON(models.CreateSuccessResponse(result))
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/llm"
+	"community-chatbot/internal/stream"
+)
+
+// benchAnswer is the text the benchmark model repeats to the requested length;
+// its links and Markdown exercise the text filters of the pipeline
+const benchAnswer = "Try [Eagle Falls](activity://12), a **moderate** 8 km hike with a waterfall at the end. " +
+	"Bring water and layers:\n\n- Start early\n- Check [conditions](activity://12) first\n\n"
+
+// benchConversations are the conversations the runs are spread over, to
+// exercise history compression
+const benchConversations = 100
+
+// benchProvider answers every completion with the same text, word by word,
+// waiting latency before each word like a model generating tokens
+type benchProvider struct {
+	words   []string
+	latency time.Duration
+}
+
+// StreamCompletion streams the answer of the benchmark model
+func (p *benchProvider) StreamCompletion(ctx context.Context, _ llm.CompletionRequest, onDelta func(delta string) error) (*llm.Completion, error) {
+	for _, word := range p.words {
+		if p.latency > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(p.latency):
+			}
+		}
+		if err := onDelta(word); err != nil {
+			return nil, err
+		}
+	}
+	return &llm.Completion{Usage: &llm.Usage{PromptTokens: 500, CompletionTokens: len(p.words), TotalTokens: 500 + len(p.words)}}, nil
+}
+
+// Embed is not used by the benchmarked pipeline
+func (p *benchProvider) Embed(context.Context, []string) ([][]float32, error) {
+	return nil, errors.New("the benchmark model has no embeddings")
+}
+
+// BenchmarkChatPipeline runs the chat pipeline against a model with a fixed
+// answer, so the overhead of stages and text filters is measured without the
+// provider, the database or HTTP. Runs use the stages that need no database:
+// conversation memory, guardrails and link rewriting.
+func BenchmarkChatPipeline(b *testing.B) {
+	cases := []struct {
+		words   int
+		latency time.Duration
+		// parallel is the number of concurrent runs per CPU
+		parallel int
+		// maxConcurrent is OPENAI_MAX_CONCURRENT, 0 for unlimited
+		maxConcurrent int
+	}{
+		{words: 50, parallel: 1},
+		{words: 150, parallel: 1},
+		{words: 300, parallel: 1},
+		{words: 600, parallel: 1},
+		{words: 1200, parallel: 1},
+		{words: 300, parallel: 16},
+		{words: 300, latency: 2 * time.Millisecond, parallel: 64, maxConcurrent: 20},
+	}
+
+	// Every run logs; the benchmark would measure the terminal otherwise
+	logOutput := log.Writer()
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(logOutput) })
+
+	for _, bc := range cases {
+		name := fmt.Sprintf("words=%d/latency=%s/parallel=%d", bc.words, bc.latency, bc.parallel)
+		b.Run(name, func(b *testing.B) {
+			answer := strings.Repeat(benchAnswer, bc.words/len(strings.Fields(benchAnswer))+1)
+			provider := &benchProvider{words: chat.SplitWords(answer)[:bc.words], latency: bc.latency}
+			var limiter *chat.Limiter
+			if bc.maxConcurrent > 0 {
+				limiter = chat.NewLimiter(bc.maxConcurrent, 1<<20, time.Hour)
+			}
+
+			pipeline := NewChatHandler(provider, stream.NewRegistry(), stream.NewReplays(0, 0), limiter, nil, 0).Pipeline()
+			memory := chat.NewMemoryBuffer(50, benchConversations, 24*time.Hour)
+			pipeline.Register(chat.OrderPersistence, chat.CompressionStage(memory, nil, chat.CompressionConfig{VerbatimTurns: 6, TokenBudget: 2000}))
+			pipeline.Register(chat.OrderPersistence, chat.PersistenceStage(memory.Record, memory.Complete, nil))
+			guardrails := chat.NewGuardrails([]string{"hiking", "cycling", "outdoor activities"}, []string{"politics"}, "", "")
+			pipeline.Register(chat.OrderModeration, chat.GuardrailStage(func(context.Context) (*chat.Guardrails, error) {
+				return guardrails, nil
+			}))
+			pipeline.Register(chat.OrderPostProcess, chat.PostProcessStage("https://example.org", nil))
+
+			var runs, failed atomic.Int64
+			b.ReportAllocs()
+			b.SetParallelism(bc.parallel)
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					n := runs.Add(1)
+					run := chat.NewRun("Which waterfall hikes are near Boulder?", "bench")
+					run.ConversationID = fmt.Sprintf("bench-%d", n%benchConversations)
+					run.SetTextEmitter(func(string, bool) error { return nil })
+					if err := pipeline.Execute(context.Background(), run); err != nil {
+						failed.Add(1)
+					}
+				}
+			})
+			if n := failed.Load(); n > 0 {
+				b.Fatalf("%d of %d runs failed", n, runs.Load())
+			}
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "runs/s")
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ChecklistHandler handles packing checklists and their moderator overrides
+type ChecklistHandler struct {
+	checklists *services.ChecklistService
+}
+
+// NewChecklistHandler creates a new checklist handler
+func NewChecklistHandler(checklists *services.ChecklistService) *ChecklistHandler {
+	return &ChecklistHandler{
+		checklists: checklists,
+	}
+}
+
+// checklistOverrideRequest is the body of SetOverride
+type checklistOverrideRequest struct {
+	Season   string `json:"season" validate:"max=20"`
+	Item     string `json:"item" validate:"required,max=100"`
+	Remove   bool   `json:"remove"`
+	Category string `json:"category" validate:"max=50"`
+	Quantity string `json:"quantity" validate:"max=100"`
+	Note     string `json:"note" validate:"max=255"`
+}
+
+// GetChecklist returns the packing checklist of an activity
+//
+// Query parameters: date (YYYY-MM-DD, default today)
+//
+// Returns:
+//   - 200: Items with quantities and reasons, and the forecast they were adjusted to
+//   - 400: Invalid activity ID or date
+//   - 404: Activity not found
+//   - 500: Internal server error
+func (h *ChecklistHandler) GetChecklist(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	checklist, err := h.checklists.Generate(c.UserContext(), id, c.Query("date"))
+	switch {
+	case errors.Is(err, services.ErrInvalidDate):
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("activity not found"))
+	case err != nil:
+		log.Printf("[ERROR] Checklist of activity %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to generate checklist"))
+	}
+	return c.JSON(models.CreateSuccessResponse(checklist))
+}
+
+// ListOverrides returns the checklist overrides of an activity (admin only)
+//
+// Returns:
+//   - 200: Overrides, all-season ones first
+//   - 400: Invalid activity ID
+//   - 500: Internal server error
+func (h *ChecklistHandler) ListOverrides(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	overrides, err := h.checklists.ListOverrides(c.UserContext(), id)
+	if err != nil {
+		log.Printf("[ERROR] List checklist overrides of activity %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list checklist overrides"))
+	}
+	return c.JSON(models.CreateSuccessResponse(overrides))
+}
+
+// SetOverride adds or replaces an item of an activity's checklist, or removes
+// one with remove=true, in one season or all seasons when season is empty.
+// An override replaces the previous one for the same item and season (admin only).
+//
+// Request body: {"season": "winter", "item": "Microspikes", "note": "the north side stays icy"}
+//
+// Returns:
+//   - 201: The stored override
+//   - 400: Invalid activity ID, item or season
+//   - 404: Activity not found
+//   - 500: Internal server error
+func (h *ChecklistHandler) SetOverride(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+	var body checklistOverrideRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	override := &models.ChecklistOverride{
+		ActivityID: id,
+		Season:     body.Season,
+		Item:       body.Item,
+		Remove:     body.Remove,
+		Category:   body.Category,
+		Quantity:   body.Quantity,
+		Note:       body.Note,
+	}
+	err := h.checklists.SetOverride(c.UserContext(), override)
+	switch {
+	case errors.Is(err, services.ErrInvalidSeason), errors.Is(err, services.ErrInvalidChecklistItem):
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("activity not found"))
+	case err != nil:
+		log.Printf("[ERROR] Set checklist override of activity %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to set checklist override"))
+	}
+
+	log.Printf("[CHECKLIST] Override of %q in activity %d set (season %q, remove %t)", override.Item, id, override.Season, override.Remove)
+	return c.Status(fiber.StatusCreated).JSON(models.CreateSuccessResponse(override))
+}
+
+// DeleteOverride removes a checklist override, restoring the generated item (admin only)
+//
+// Returns:
+//   - 200: Override deleted
+//   - 400: Invalid ID
+//   - 404: Override not found
+//   - 500: Internal server error
+func (h *ChecklistHandler) DeleteOverride(c *fiber.Ctx) error {
+	activity, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+	id, err := c.ParamsInt("override_id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid override id"))
+	}
+
+	if err := h.checklists.DeleteOverride(c.UserContext(), activity, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("checklist override not found"))
+		}
+		log.Printf("[ERROR] Delete checklist override %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to delete checklist override"))
+	}
+	return c.JSON(models.CreateMessageResponse("checklist override deleted"))
+}
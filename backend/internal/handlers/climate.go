@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ClimateHandler handles historical weather statistics
+type ClimateHandler struct {
+	climate *services.ClimateService
+}
+
+// NewClimateHandler creates a new climate handler
+func NewClimateHandler(climate *services.ClimateService) *ClimateHandler {
+	return &ClimateHandler{
+		climate: climate,
+	}
+}
+
+// GetClimate returns the climate normals of a location: average temperatures,
+// precipitation and rainy days per month over recent years. Normals are
+// computed for a grid cell of about 25 km around the location.
+//
+// Query parameters: lat, lng (required), month (1-12, default all months)
+//
+// Returns:
+//   - 200: Normals of the location's grid cell
+//   - 400: Invalid location or month
+//   - 502: Weather history download failed
+func (h *ClimateHandler) GetClimate(c *fiber.Ctx) error {
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+	if errLat != nil || errLng != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("lat and lng are required coordinates"))
+	}
+	month := c.QueryInt("month", 0)
+	if month < 0 || month > 12 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(services.ErrInvalidMonth.Error()))
+	}
+
+	normals, err := h.climate.Normals(c.UserContext(), models.Location{Lat: lat, Lng: lng}, month)
+	if err != nil {
+		log.Printf("[ERROR] Climate normals at %.4f,%.4f: %v", lat, lng, err)
+		return c.Status(fiber.StatusBadGateway).JSON(models.CreateErrorResponse("failed to load climate data"))
+	}
+	return c.JSON(models.CreateSuccessResponse(normals))
+}
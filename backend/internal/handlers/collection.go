@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// CollectionHandler handles curated collections of activities
+type CollectionHandler struct {
+	collections *services.CollectionService
+}
+
+// NewCollectionHandler creates a new collection handler
+func NewCollectionHandler(collections *services.CollectionService) *CollectionHandler {
+	return &CollectionHandler{
+		collections: collections,
+	}
+}
+
+// collectionRequest is the body of CreateCollection and UpdateCollection
+type collectionRequest struct {
+	Title       string `json:"title" validate:"required,min=3,max=255"`
+	Description string `json:"description" validate:"max=2000"`
+	// Visibility is private when empty
+	Visibility string                  `json:"visibility" validate:"omitempty,oneof=public private"`
+	Items      []collectionItemRequest `json:"items" validate:"dive"`
+}
+
+// collectionItemRequest is an activity of a collection, in the order of the request
+type collectionItemRequest struct {
+	ActivityID uint   `json:"activity_id" validate:"required"`
+	Note       string `json:"note" validate:"max=1000"`
+}
+
+// ListCollections returns public collections, curated and most followed first
+//
+// Query parameters: q (words in titles and descriptions), user_id, page, page_size
+//
+// Returns:
+//   - 200: Collections without their items, with pagination metadata
+//   - 500: Internal server error
+func (h *CollectionHandler) ListCollections(c *fiber.Ctx) error {
+	filters := services.CollectionFilters{
+		Query:  c.Query("q"),
+		UserID: uint(max(c.QueryInt("user_id"), 0)),
+	}
+	return h.list(c, filters)
+}
+
+// ListMyCollections returns the signed-in user's collections, including private ones
+//
+// Query parameters: page, page_size
+//
+// Returns:
+//   - 200: Collections without their items, with pagination metadata
+//   - 500: Internal server error
+func (h *CollectionHandler) ListMyCollections(c *fiber.Ctx) error {
+	userID, _ := middleware.UserID(c)
+	return h.list(c, services.CollectionFilters{UserID: userID, IncludePrivate: true})
+}
+
+// ListFollowedCollections returns the public collections the signed-in user follows
+//
+// Query parameters: page, page_size
+//
+// Returns:
+//   - 200: Collections without their items, with pagination metadata
+//   - 500: Internal server error
+func (h *CollectionHandler) ListFollowedCollections(c *fiber.Ctx) error {
+	userID, _ := middleware.UserID(c)
+	return h.list(c, services.CollectionFilters{FollowedBy: userID})
+}
+
+// list responds with a page of collections
+func (h *CollectionHandler) list(c *fiber.Ctx, filters services.CollectionFilters) error {
+	userID, _ := middleware.UserID(c)
+	page, pageSize := parsePagination(c)
+	collections, total, err := h.collections.List(c.UserContext(), filters, userID, page, pageSize)
+	if err != nil {
+		return fmt.Errorf("list collections: %w", err)
+	}
+	return c.JSON(models.CreateSuccessResponseWithMeta(collections, &models.MetaData{
+		TotalCount: int(total),
+		Page:       page,
+		PageSize:   pageSize,
+	}))
+}
+
+// GetCollection returns a collection with its activities in order; private
+// collections are only found for their owner
+//
+// Returns:
+//   - 200: Collection with its items and their activity
+//   - 400: Invalid collection ID
+//   - 404: Collection not found
+//   - 500: Internal server error
+func (h *CollectionHandler) GetCollection(c *fiber.Ctx) error {
+	id, ok := collectionID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid collection id"))
+	}
+
+	userID, _ := middleware.UserID(c)
+	collection, err := h.collections.Get(c.UserContext(), id, userID)
+	if err != nil {
+		return collectionError(id, err)
+	}
+	return c.JSON(models.CreateSuccessResponse(collection))
+}
+
+// CreateCollection creates a collection of the signed-in user. Collections of
+// moderators are marked curated.
+//
+// Request body: {"title": "Rainy day options", "description": "...", "visibility": "public",
+// "items": [{"activity_id": 1, "note": "Free on Sundays"}]}
+//
+// Returns:
+//   - 201: Created collection
+//   - 400: Invalid input or activities
+//   - 500: Internal server error
+func (h *CollectionHandler) CreateCollection(c *fiber.Ctx) error {
+	collection, items, err := parseCollection(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	}
+
+	collection.UserID, _ = middleware.UserID(c)
+	if err := h.collections.Create(c.UserContext(), collection, items); err != nil {
+		return collectionError(0, err)
+	}
+	log.Printf("[COLLECTION] User %d created collection %d", collection.UserID, collection.ID)
+	return c.Status(fiber.StatusCreated).JSON(models.CreateSuccessResponse(collection))
+}
+
+// UpdateCollection replaces the title, description, visibility and items of
+// a collection (owner or moderators)
+//
+// Returns:
+//   - 200: Updated collection
+//   - 400: Invalid ID, input or activities
+//   - 403: Collection belongs to another user
+//   - 404: Collection not found
+//   - 500: Internal server error
+func (h *CollectionHandler) UpdateCollection(c *fiber.Ctx) error {
+	id, ok := collectionID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid collection id"))
+	}
+	changes, items, err := parseCollection(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	}
+
+	userID, _ := middleware.UserID(c)
+	collection, err := h.collections.Update(c.UserContext(), id, userID, changes, items)
+	if err != nil {
+		return collectionError(id, err)
+	}
+	return c.JSON(models.CreateSuccessResponse(collection))
+}
+
+// DeleteCollection deletes a collection (owner or moderators)
+//
+// Returns:
+//   - 200: Collection deleted
+//   - 400: Invalid collection ID
+//   - 403: Collection belongs to another user
+//   - 404: Collection not found
+//   - 500: Internal server error
+func (h *CollectionHandler) DeleteCollection(c *fiber.Ctx) error {
+	id, ok := collectionID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid collection id"))
+	}
+
+	userID, _ := middleware.UserID(c)
+	if err := h.collections.Delete(c.UserContext(), id, userID); err != nil {
+		return collectionError(id, err)
+	}
+	log.Printf("[COLLECTION] User %d deleted collection %d", userID, id)
+	return c.JSON(models.CreateMessageResponse("collection deleted"))
+}
+
+// FollowCollection follows a public collection; following it again is not an error
+//
+// Returns:
+//   - 200: The collection with its follower count
+//   - 400: Invalid collection ID or private collection
+//   - 404: Collection not found
+//   - 500: Internal server error
+func (h *CollectionHandler) FollowCollection(c *fiber.Ctx) error {
+	id, ok := collectionID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid collection id"))
+	}
+
+	userID, _ := middleware.UserID(c)
+	collection, err := h.collections.Follow(c.UserContext(), userID, id)
+	if err != nil {
+		return collectionError(id, err)
+	}
+	return c.JSON(models.CreateSuccessResponse(collection))
+}
+
+// UnfollowCollection stops following a collection; unfollowing one that is
+// not followed is not an error
+//
+// Returns:
+//   - 200: Collection unfollowed
+//   - 400: Invalid collection ID
+//   - 500: Internal server error
+func (h *CollectionHandler) UnfollowCollection(c *fiber.Ctx) error {
+	id, ok := collectionID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid collection id"))
+	}
+
+	userID, _ := middleware.UserID(c)
+	if err := h.collections.Unfollow(c.UserContext(), userID, id); err != nil {
+		return collectionError(id, err)
+	}
+	return c.JSON(models.CreateMessageResponse("collection unfollowed"))
+}
+
+// collectionID parses the :id route parameter
+func collectionID(c *fiber.Ctx) (uint, bool) {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// parseCollection binds and validates a collection request
+func parseCollection(c *fiber.Ctx) (*models.Collection, []models.CollectionItem, error) {
+	var body collectionRequest
+	if err := c.BodyParser(&body); err != nil {
+		return nil, nil, errors.New("invalid request body")
+	}
+	if err := validate.Struct(body); err != nil {
+		return nil, nil, errors.New(validationMessage(err))
+	}
+
+	collection := &models.Collection{
+		Title:       body.Title,
+		Description: body.Description,
+		Visibility:  body.Visibility,
+	}
+	if collection.Visibility == "" {
+		collection.Visibility = models.CollectionPrivate
+	}
+	items := make([]models.CollectionItem, len(body.Items))
+	for i, item := range body.Items {
+		items[i] = models.CollectionItem{ActivityID: item.ActivityID, Note: item.Note}
+	}
+	return collection, items, nil
+}
+
+// collectionError hands service errors to the app's error handler, which maps
+// them to HTTP responses by kind
+func collectionError(id uint, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperr.Wrap(apperr.NotFound, "collection not found", err)
+	}
+	return fmt.Errorf("collection %d: %w", id, err)
+}
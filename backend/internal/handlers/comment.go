@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// CommentHandler handles activity comment endpoints
+type CommentHandler struct {
+	comments *services.CommentService
+}
+
+// NewCommentHandler creates a new comment handler
+func NewCommentHandler(comments *services.CommentService) *CommentHandler {
+	return &CommentHandler{
+		comments: comments,
+	}
+}
+
+// commentRequest is the client-editable part of a comment
+type commentRequest struct {
+	Body string `json:"body" validate:"required,max=2000"`
+}
+
+// CreateComment adds a comment to an activity. Users mentioned with
+// @[Name](user:<id>) markup are notified; the response lists them under
+// mentions with their offsets in the body.
+//
+// Returns:
+//   - 201: Comment stored
+//   - 400: Invalid activity ID, input data or mentions
+//   - 404: Activity not found or not approved
+//   - 500: Internal server error
+func (h *CommentHandler) CreateComment(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	var body commentRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	userID, _ := middleware.UserID(c)
+	comment := &models.Comment{
+		ActivityID: id,
+		UserID:     userID,
+		Body:       body.Body,
+	}
+	if err := h.comments.Create(c.UserContext(), comment); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("activity not found"))
+		case errors.Is(err, services.ErrUnknownMention), errors.Is(err, services.ErrTooManyMentions):
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		log.Printf("[ERROR] Create comment for activity %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to create comment"))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.CreateSuccessResponse(comment))
+}
+
+// ListComments returns a paginated list of an activity's comments, oldest first
+//
+// Query parameters: page, page_size
+//
+// Returns:
+//   - 200: Comments with their mentions and pagination metadata
+//   - 400: Invalid activity ID
+//   - 500: Internal server error
+func (h *CommentHandler) ListComments(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	page, pageSize := parsePagination(c)
+	comments, total, err := h.comments.List(c.UserContext(), id, page, pageSize)
+	if err != nil {
+		log.Printf("[ERROR] List comments for activity %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list comments"))
+	}
+
+	return c.JSON(models.CreateSuccessResponseWithMeta(comments, &models.MetaData{
+		TotalCount: int(total),
+		Page:       page,
+		PageSize:   pageSize,
+	}))
+}
+
+// DeleteComment deletes the user's comment; moderators can delete any comment
+//
+// Returns:
+//   - 200: Comment deleted
+//   - 400: Invalid ID
+//   - 403: Comment written by another user
+//   - 404: Comment not found
+//   - 500: Internal server error
+func (h *CommentHandler) DeleteComment(c *fiber.Ctx) error {
+	activity, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+	id, err := c.ParamsInt("comment_id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid comment id"))
+	}
+
+	userID, _ := middleware.UserID(c)
+	if err := h.comments.Delete(c.UserContext(), activity, uint(id), userID); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("comment not found"))
+		case errors.Is(err, services.ErrNotCommentAuthor):
+			return c.Status(fiber.StatusForbidden).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		log.Printf("[ERROR] Delete comment %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to delete comment"))
+	}
+	return c.JSON(models.CreateMessageResponse("comment deleted"))
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ConditionHandler handles trail condition report endpoints
+type ConditionHandler struct {
+	conditions *services.ConditionService
+}
+
+// NewConditionHandler creates a new condition handler
+func NewConditionHandler(conditions *services.ConditionService) *ConditionHandler {
+	return &ConditionHandler{
+		conditions: conditions,
+	}
+}
+
+// ReportCondition records the current condition of an activity. Closed reports
+// alert users whose home search radius includes the activity.
+//
+// Returns:
+//   - 201: Report stored
+//   - 400: Invalid activity ID or input data
+//   - 404: Activity not found
+func (h *ConditionHandler) ReportCondition(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	var body models.ConditionReport
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+
+	userID, _ := middleware.UserID(c)
+	report := &models.ConditionReport{
+		ActivityID: id,
+		UserID:     userID,
+		Status:     body.Status,
+		Note:       body.Note,
+	}
+	if err := validate.Struct(report); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	if err := h.conditions.Report(c.UserContext(), report); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("activity not found"))
+		}
+		log.Printf("[ERROR] Condition report for activity %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to store condition report"))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.CreateSuccessResponse(report))
+}
+
+// ListConditions returns the latest condition reports for an activity
+//
+// Returns:
+//   - 200: Condition reports, newest first
+//   - 400: Invalid activity ID
+//   - 500: Internal server error
+func (h *ConditionHandler) ListConditions(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	reports, err := h.conditions.Recent(c.UserContext(), id, 20)
+	if err != nil {
+		log.Printf("[ERROR] List condition reports for activity %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list condition reports"))
+	}
+
+	return c.JSON(models.CreateSuccessResponse(reports))
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"log"
+
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ConsentHandler handles the authenticated user's consent to uses of their data
+type ConsentHandler struct {
+	consents *services.ConsentService
+}
+
+// NewConsentHandler creates a new consent handler
+func NewConsentHandler(consents *services.ConsentService) *ConsentHandler {
+	return &ConsentHandler{
+		consents: consents,
+	}
+}
+
+// grantConsentRequest names the version of the consent text the user agreed to
+type grantConsentRequest struct {
+	Version int `json:"version" validate:"gte=0"`
+}
+
+// ListConsents returns the user's consent to each purpose with the purpose's
+// description and current version; outdated consents should be asked again
+//
+// Returns:
+//   - 200: Consent per purpose
+//   - 500: Internal server error
+func (h *ConsentHandler) ListConsents(c *fiber.Ctx) error {
+	userID, _ := middleware.UserID(c)
+	consents, err := h.consents.List(c.UserContext(), userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(models.CreateSuccessResponse(consents))
+}
+
+// GrantConsent records the user's consent to a purpose
+//
+// Request body: {"version": 1} (optional, default the current version)
+//
+// Returns:
+//   - 200: Updated consent
+//   - 400: Invalid body or unknown version
+//   - 404: Unknown purpose
+//   - 500: Internal server error
+func (h *ConsentHandler) GrantConsent(c *fiber.Ctx) error {
+	var body grantConsentRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+		}
+		if err := validate.Struct(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+		}
+	}
+	return h.set(c, true, body.Version)
+}
+
+// RevokeConsent withdraws the user's consent to a purpose. Data is no longer
+// stored or used for it; revoking inferred_preferences also deletes the
+// location history.
+//
+// Returns:
+//   - 200: Updated consent
+//   - 404: Unknown purpose
+//   - 500: Internal server error
+func (h *ConsentHandler) RevokeConsent(c *fiber.Ctx) error {
+	return h.set(c, false, 0)
+}
+
+// set records a decision about the purpose in the path
+func (h *ConsentHandler) set(c *fiber.Ctx, granted bool, version int) error {
+	userID, _ := middleware.UserID(c)
+	purpose := c.Params("purpose")
+	consent, err := h.consents.Set(c.UserContext(), userID, purpose, granted, version)
+	if err != nil {
+		return err
+	}
+	log.Printf("[CONSENT] User %d set %s consent to %t (version %d)", userID, purpose, granted, consent.DecidedVersion)
+	return c.JSON(models.CreateSuccessResponse(consent))
+}
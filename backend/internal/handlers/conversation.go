@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ConversationHandler handles conversation history endpoints
+type ConversationHandler struct {
+	conversations *services.ConversationService
+	// chat streams the answers of edited messages
+	chat *ChatHandler
+}
+
+// NewConversationHandler creates a new conversation handler
+func NewConversationHandler(conversations *services.ConversationService, chatHandler *ChatHandler) *ConversationHandler {
+	return &ConversationHandler{
+		conversations: conversations,
+		chat:          chatHandler,
+	}
+}
+
+// ListConversations returns a paginated list of the user's conversations, most recent first
+//
+// Query parameters: page, page_size
+//
+// Returns:
+//   - 200: Conversations with pagination metadata
+//   - 500: Internal server error
+func (h *ConversationHandler) ListConversations(c *fiber.Ctx) error {
+	userID, _ := middleware.UserID(c)
+	page, pageSize := parsePagination(c)
+
+	conversations, total, err := h.conversations.List(c.UserContext(), userID, page, pageSize)
+	if err != nil {
+		log.Printf("[ERROR] List conversations: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list conversations"))
+	}
+
+	return c.JSON(models.CreateSuccessResponseWithMeta(conversations, &models.MetaData{
+		TotalCount: int(total),
+		Page:       page,
+		PageSize:   pageSize,
+	}))
+}
+
+// GetConversation returns a conversation with the messages of all its branches
+// as a tree, for frontends to show the alternatives of edited messages.
+// Anonymous conversations are readable by the session they were started in
+// (X-Session-Token header or session cookie); owned ones only by their owner.
+//
+// Returns:
+//   - 200: The conversation, its current_message_id and its messages, each with its children
+//   - 401: Neither authenticated nor in a session
+//   - 404: Conversation not found
+//   - 500: Internal server error
+func (h *ConversationHandler) GetConversation(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID, _ := middleware.UserID(c)
+
+	tree, err := h.conversations.Tree(c.UserContext(), id, middleware.SessionID(c), userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("conversation not found"))
+		}
+		log.Printf("[ERROR] Get conversation %s: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to get conversation"))
+	}
+	return c.JSON(models.CreateSuccessResponse(tree))
+}
+
+// branchRequest is the body of BranchMessage
+type branchRequest struct {
+	Message string            `json:"message" validate:"required,max=4000"`
+	Context *chat.UserContext `json:"context"`
+}
+
+// BranchMessage edits an earlier user message and streams the answer like
+// POST /chat/stream. The edited text follows the message's parent on a new
+// branch, which becomes the conversation's active one once the answer is
+// stored; the original message and its answers stay in the conversation as an
+// alternative.
+//
+// Request body: {"message": "What about easier trails?", "context": {...}}
+//
+// Returns:
+//   - 200: text/event-stream of AG-UI events
+//   - 400: Invalid message ID or body, or not a user message
+//   - 401: Neither authenticated nor in a session
+//   - 404: Message not found
+//   - 500: Internal server error
+func (h *ConversationHandler) BranchMessage(c *fiber.Ctx) error {
+	// Reconnections resume the stream of the branch that was already started
+	if c.Get(lastEventIDHeader) != "" {
+		return h.chat.stream(c, ChatRequest{}, requestOwner(c))
+	}
+
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid message id"))
+	}
+	var body branchRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	body.Message = strings.TrimSpace(body.Message)
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	owner := requestOwner(c)
+	conversationID, err := h.conversations.Branch(c.UserContext(), uint(id), owner.UserID, owner.SessionID)
+	if err != nil {
+		if errors.Is(err, services.ErrNotUserMessage) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("message not found"))
+		}
+		log.Printf("[ERROR] Branch from message %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to branch conversation"))
+	}
+
+	log.Printf("[CHAT] Client %s (request %s): Branching conversation %s from message %d", c.IP(), middleware.GetRequestID(c), conversationID, id)
+	return h.chat.stream(c, ChatRequest{
+		Message:        body.Message,
+		ConversationID: conversationID,
+		Context:        body.Context,
+		EditOf:         uint(id),
+	}, owner)
+}
+
+// ListMessages returns a paginated list of the messages of a conversation's
+// active branch in chronological order. Anonymous conversations are readable
+// by the session they were started in; owned ones only by their owner.
+//
+// Query parameters: page, page_size
+//
+// Returns:
+//   - 200: Messages with pagination metadata
+//   - 401: Neither authenticated nor in a session
+//   - 404: Conversation not found
+//   - 500: Internal server error
+func (h *ConversationHandler) ListMessages(c *fiber.Ctx) error {
+	id := c.Params("id")
+	userID, _ := middleware.UserID(c)
+	page, pageSize := parsePagination(c)
+
+	messages, total, err := h.conversations.Messages(c.UserContext(), id, middleware.SessionID(c), userID, page, pageSize)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("conversation not found"))
+		}
+		log.Printf("[ERROR] List messages of conversation %s: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list messages"))
+	}
+
+	return c.JSON(models.CreateSuccessResponseWithMeta(messages, &models.MetaData{
+		TotalCount: int(total),
+		Page:       page,
+		PageSize:   pageSize,
+	}))
+}
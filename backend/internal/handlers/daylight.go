@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DaylightHandler handles sunrise, sunset and golden hour lookups
+type DaylightHandler struct {
+	daylight *services.DaylightService
+}
+
+// NewDaylightHandler creates a new daylight handler
+func NewDaylightHandler(daylight *services.DaylightService) *DaylightHandler {
+	return &DaylightHandler{
+		daylight: daylight,
+	}
+}
+
+// GetDaylight returns sunrise, sunset, civil twilight and golden hour of a
+// date at a location, in local time. With a duration it also returns the
+// latest start to finish an outing before dark.
+//
+// Query parameters: lat, lng (required), date (YYYY-MM-DD, default today),
+// timezone (IANA name, estimated from the longitude when omitted), duration_minutes
+//
+// Returns:
+//   - 200: Daylight of the date
+//   - 400: Invalid location, date, time zone or duration
+func (h *DaylightHandler) GetDaylight(c *fiber.Ctx) error {
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+	if errLat != nil || errLng != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("lat and lng are required coordinates"))
+	}
+	duration := c.QueryInt("duration_minutes", 0)
+	if duration < 0 || duration > 24*60 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("duration_minutes must be between 0 and 1440"))
+	}
+
+	result, err := h.daylight.Daylight(services.DaylightQuery{
+		Location: models.Location{Lat: lat, Lng: lng},
+		Date:     c.Query("date"),
+		Timezone: c.Query("timezone"),
+		Duration: time.Duration(duration) * time.Minute,
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(models.CreateSuccessResponse(result))
+}
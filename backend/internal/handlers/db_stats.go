@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"log"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DBStatsHandler handles database diagnostics
+type DBStatsHandler struct {
+	stats *services.DBStatsService
+}
+
+// NewDBStatsHandler creates a new DB stats handler
+func NewDBStatsHandler(stats *services.DBStatsService) *DBStatsHandler {
+	return &DBStatsHandler{
+		stats: stats,
+	}
+}
+
+// GetDBStats reports row counts and sizes per table, the most bloated indexes
+// and when tables were last vacuumed and analyzed, so runaway growth of e.g.
+// conversations shows without psql access (admin only)
+//
+// Query parameters: exact (true counts rows with COUNT(*) instead of estimating)
+//
+// Returns:
+//   - 200: Database statistics
+//   - 500: Internal server error
+func (h *DBStatsHandler) GetDBStats(c *fiber.Ctx) error {
+	stats, err := h.stats.Stats(c.UserContext(), c.QueryBool("exact"))
+	if err != nil {
+		log.Printf("[ERROR] DB stats: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to collect database statistics"))
+	}
+	return c.JSON(models.CreateSuccessResponse(stats))
+}
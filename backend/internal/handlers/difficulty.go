@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// DifficultyHandler handles per-community difficulty calibration
+type DifficultyHandler struct {
+	difficulty *services.DifficultyService
+}
+
+// NewDifficultyHandler creates a new difficulty handler
+func NewDifficultyHandler(difficulty *services.DifficultyService) *DifficultyHandler {
+	return &DifficultyHandler{
+		difficulty: difficulty,
+	}
+}
+
+// calibrationRequest is the body of SetCalibration
+type calibrationRequest struct {
+	// Community is empty for this community and otherwise a federation origin
+	Community   string  `json:"community" validate:"max=255"`
+	EasyMax     float64 `json:"easy_max" validate:"gt=0"`
+	ModerateMax float64 `json:"moderate_max" validate:"gtfield=EasyMax"`
+}
+
+// ListCalibrations returns the stored difficulty calibrations and the default
+// used for communities without one (admin only)
+//
+// Returns:
+//   - 200: Calibrations and the default thresholds
+//   - 500: Internal server error
+func (h *DifficultyHandler) ListCalibrations(c *fiber.Ctx) error {
+	calibrations, err := h.difficulty.List(c.UserContext())
+	if err != nil {
+		log.Printf("[ERROR] List difficulty calibrations: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list difficulty calibrations"))
+	}
+	return c.JSON(models.CreateSuccessResponse(fiber.Map{
+		"calibrations": calibrations,
+		"default":      models.DefaultDifficultyCalibration(""),
+	}))
+}
+
+// SetCalibration sets the route score thresholds of a community's difficulty
+// levels. Routes scoring below easy_max are easy, below moderate_max moderate
+// and the rest hard; scores are effort kilometers (distance plus 1 km per 100 m
+// of climbing). Changing this community's calibration re-buckets routes whose
+// difficulty was derived from their score (admin only).
+//
+// Request body: {"community": "", "easy_max": 10, "moderate_max": 20}
+//
+// Returns:
+//   - 200: The stored calibration and the number of re-bucketed routes
+//   - 400: Invalid thresholds
+//   - 500: Internal server error
+func (h *DifficultyHandler) SetCalibration(c *fiber.Ctx) error {
+	var body calibrationRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	calibration, rebucketed, err := h.difficulty.SetCalibration(c.UserContext(), body.Community, body.EasyMax, body.ModerateMax)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCalibration) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		log.Printf("[ERROR] Set difficulty calibration of %q: %v", body.Community, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to set difficulty calibration"))
+	}
+
+	log.Printf("[DIFFICULTY] Calibration of %q set to %.2f/%.2f, %d routes re-bucketed",
+		body.Community, body.EasyMax, body.ModerateMax, rebucketed)
+	return c.JSON(models.CreateSuccessResponse(fiber.Map{
+		"calibration":       calibration,
+		"routes_rebucketed": rebucketed,
+	}))
+}
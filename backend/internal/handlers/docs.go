@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"community-chatbot/internal/apidocs"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/swaggerui"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// docsPage is Swagger UI reading the spec, loaded from the vendored copy; %s
+// is the Swagger UI version, which keeps cached files of older releases from
+// being used
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Community Chatbot API</title>
+  <link rel="stylesheet" href="/api/v1/docs/swagger-ui.css?v=%[1]s">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="/api/v1/docs/swagger-ui-bundle.js?v=%[1]s"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/api/v1/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// referencePage lists the endpoints of the spec without scripts. It is served
+// when Swagger UI was not vendored into the build.
+var referencePage = template.Must(template.New("reference").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>{{.Info.Title}}</title>
+  <style>body{font-family:sans-serif;max-width:60em;margin:auto}code{font-weight:bold}li{margin:.5em 0}</style>
+</head>
+<body>
+  <h1>{{.Info.Title}} {{.Info.Version}}</h1>
+  <p>{{.Info.Description}} The OpenAPI document is at <a href="/api/v1/openapi.json">/api/v1/openapi.json</a>.</p>
+  <ul>
+  {{- range .Endpoints}}
+    <li><code>{{.Method}} {{.Path}}</code>{{with .Operation.Summary}} - {{.}}{{end}}
+      {{- if .Operation.Responses}}<br><small>{{range $status, $response := .Operation.Responses}}{{$status}}: {{$response.Description}}. {{end}}</small>{{end}}</li>
+  {{- end}}
+  </ul>
+</body>
+</html>
+`))
+
+// referenceEndpoint is an operation of the reference page
+type referenceEndpoint struct {
+	Method    string
+	Path      string
+	Operation *apidocs.Operation
+}
+
+// docsAssetTypes are the content types of the Swagger UI files
+var docsAssetTypes = map[string]string{
+	".css": "text/css; charset=utf-8",
+	".js":  "text/javascript; charset=utf-8",
+}
+
+// DocsHandler serves the OpenAPI document of the app's routes and Swagger UI
+type DocsHandler struct {
+	app  *fiber.App
+	info apidocs.Info
+
+	once     sync.Once
+	document *apidocs.Document
+}
+
+// NewDocsHandler creates a docs handler. The document is built on the first
+// request, when every route of app is registered.
+func NewDocsHandler(app *fiber.App, info apidocs.Info) *DocsHandler {
+	return &DocsHandler{app: app, info: info}
+}
+
+// GetSpec returns the OpenAPI 3 document of the API
+//
+// Returns:
+//   - 200: OpenAPI document
+func (h *DocsHandler) GetSpec(c *fiber.Ctx) error {
+	return c.JSON(h.spec())
+}
+
+// GetDocs returns Swagger UI for browsing and trying the API. Its files are
+// served by GetDocsAsset from the vendored copy, not loaded from a CDN. Builds
+// without the vendored copy list the endpoints on a plain page instead.
+//
+// Returns:
+//   - 200: HTML page
+func (h *DocsHandler) GetDocs(c *fiber.Ctx) error {
+	c.Type("html", "utf-8")
+	if swaggerui.Vendored() {
+		return c.SendString(fmt.Sprintf(docsPage, swaggerui.Version()))
+	}
+
+	document := h.spec()
+	var endpoints []referenceEndpoint
+	for p, operations := range document.Paths {
+		for method, operation := range operations {
+			endpoints = append(endpoints, referenceEndpoint{Method: strings.ToUpper(method), Path: p, Operation: operation})
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Path != endpoints[j].Path {
+			return endpoints[i].Path < endpoints[j].Path
+		}
+		return endpoints[i].Method < endpoints[j].Method
+	})
+	var page strings.Builder
+	if err := referencePage.Execute(&page, struct {
+		Info      apidocs.Info
+		Endpoints []referenceEndpoint
+	}{document.Info, endpoints}); err != nil {
+		return fmt.Errorf("failed to render API reference: %w", err)
+	}
+	return c.SendString(page.String())
+}
+
+// spec returns the OpenAPI document, built on first use
+func (h *DocsHandler) spec() *apidocs.Document {
+	h.once.Do(func() {
+		h.document = apidocs.Build(h.app, h.info)
+	})
+	return h.document
+}
+
+// GetDocsAsset returns a file of Swagger UI (swagger-ui.css or
+// swagger-ui-bundle.js)
+//
+// Returns:
+//   - 200: File contents
+//   - 404: Unknown or missing file
+func (h *DocsHandler) GetDocsAsset(c *fiber.Ctx) error {
+	name := c.Params("file")
+	data, ok := swaggerui.File(name)
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("file not found"))
+	}
+	c.Set(fiber.HeaderContentType, docsAssetTypes[path.Ext(name)])
+	c.Set(fiber.HeaderCacheControl, "public, max-age=86400")
+	return c.Send(data)
+}
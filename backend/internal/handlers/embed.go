@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// EmbedHandler handles chat widget tokens and the API keys of embedding sites
+type EmbedHandler struct {
+	embeds       *services.EmbedService
+	defaultRate  float64
+	defaultBurst int
+}
+
+// NewEmbedHandler creates a new embed handler. New keys get the default rate
+// limit unless the request sets one.
+func NewEmbedHandler(embeds *services.EmbedService, defaultRate float64, defaultBurst int) *EmbedHandler {
+	return &EmbedHandler{
+		embeds:       embeds,
+		defaultRate:  defaultRate,
+		defaultBurst: defaultBurst,
+	}
+}
+
+// createEmbedKeyRequest is the body of CreateKey
+type createEmbedKeyRequest struct {
+	Name           string   `json:"name" validate:"required,max=255"`
+	AllowedOrigins []string `json:"allowed_origins" validate:"required,min=1,dive,required"`
+	Rate           float64  `json:"rate" validate:"gte=0"`
+	Burst          int      `json:"burst" validate:"gte=0"`
+}
+
+// IssueToken exchanges an embed API key for a short-lived widget token bound to
+// one origin. The key is sent in the X-API-Key header, ideally by the embedding
+// site's backend so it never reaches browsers.
+//
+// Request body: {"origin": "https://example.com"} (defaults to the Origin header)
+//
+// Returns:
+//   - 200: Token, its type and lifetime in seconds
+//   - 400: Invalid origin
+//   - 401: Missing, unknown or revoked API key
+//   - 403: Origin not allowed for the key
+//   - 500: Internal server error
+func (h *EmbedHandler) IssueToken(c *fiber.Ctx) error {
+	apiKey := c.Get("X-API-Key")
+	if apiKey == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(models.CreateErrorResponse("X-API-Key header required"))
+	}
+
+	var body struct {
+		Origin string `json:"origin"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+		}
+	}
+	if body.Origin == "" {
+		body.Origin = c.Get(fiber.HeaderOrigin)
+	}
+
+	token, err := h.embeds.IssueToken(c.UserContext(), apiKey, body.Origin)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidEmbedKey):
+			return c.Status(fiber.StatusUnauthorized).JSON(models.CreateErrorResponse(err.Error()))
+		case errors.Is(err, services.ErrInvalidOrigin):
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+		case errors.Is(err, services.ErrOriginNotAllowed):
+			return c.Status(fiber.StatusForbidden).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		log.Printf("[ERROR] Issue embed token: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to issue embed token"))
+	}
+
+	return c.JSON(models.CreateSuccessResponse(fiber.Map{
+		"token":      token,
+		"token_type": "Bearer",
+		"expires_in": int(h.embeds.TokenTTL().Seconds()),
+	}))
+}
+
+// CreateKey creates an API key for a site embedding the chat widget (admin only)
+//
+// Request body: {"name": "...", "allowed_origins": ["https://example.com"], "rate": 1, "burst": 20}
+//
+// Returns:
+//   - 201: The key record and the API key, which is only shown once
+//   - 400: Invalid input data
+//   - 500: Internal server error
+func (h *EmbedHandler) CreateKey(c *fiber.Ctx) error {
+	var body createEmbedKeyRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+	if body.Rate == 0 {
+		body.Rate = h.defaultRate
+	}
+	if body.Burst == 0 {
+		body.Burst = h.defaultBurst
+	}
+
+	key, apiKey, err := h.embeds.CreateKey(c.UserContext(), body.Name, body.AllowedOrigins, body.Rate, body.Burst)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidOrigin) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		log.Printf("[ERROR] Create embed key: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to create embed key"))
+	}
+
+	log.Printf("[AUTH] Created embed key %d (%s) for %v", key.ID, key.KeyPrefix, key.AllowedOrigins)
+	return c.Status(fiber.StatusCreated).JSON(models.CreateSuccessResponse(fiber.Map{
+		"key":     key,
+		"api_key": apiKey,
+	}))
+}
+
+// ListKeys returns all embed keys without their secrets (admin only)
+//
+// Returns:
+//   - 200: Embed keys, newest first
+//   - 500: Internal server error
+func (h *EmbedHandler) ListKeys(c *fiber.Ctx) error {
+	keys, err := h.embeds.ListKeys(c.UserContext())
+	if err != nil {
+		log.Printf("[ERROR] List embed keys: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list embed keys"))
+	}
+	return c.JSON(models.CreateSuccessResponse(keys))
+}
+
+// RevokeKey revokes an embed key; widget tokens issued for it stop working at once (admin only)
+//
+// Returns:
+//   - 200: Key revoked
+//   - 400: Invalid key ID
+//   - 404: Key not found or already revoked
+//   - 500: Internal server error
+func (h *EmbedHandler) RevokeKey(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid embed key id"))
+	}
+
+	if err := h.embeds.RevokeKey(c.UserContext(), uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("embed key not found"))
+		}
+		log.Printf("[ERROR] Revoke embed key %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to revoke embed key"))
+	}
+
+	log.Printf("[AUTH] Revoked embed key %d", id)
+	return c.JSON(models.CreateMessageResponse("embed key revoked"))
+}
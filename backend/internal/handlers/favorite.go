@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// FavoriteHandler handles the saved activities of the signed-in user
+type FavoriteHandler struct {
+	favorites *services.FavoriteService
+}
+
+// NewFavoriteHandler creates a new favorite handler
+func NewFavoriteHandler(favorites *services.FavoriteService) *FavoriteHandler {
+	return &FavoriteHandler{
+		favorites: favorites,
+	}
+}
+
+// ListFavorites returns the user's saved activities, most recently saved first
+//
+// Query parameters: page, page_size
+//
+// Returns:
+//   - 200: Favorites with their activity and pagination metadata
+//   - 500: Internal server error
+func (h *FavoriteHandler) ListFavorites(c *fiber.Ctx) error {
+	userID, _ := middleware.UserID(c)
+	page, pageSize := parsePagination(c)
+	favorites, total, err := h.favorites.List(c.UserContext(), userID, page, pageSize)
+	if err != nil {
+		log.Printf("[ERROR] List favorites of user %d: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list favorites"))
+	}
+
+	return c.JSON(models.CreateSuccessResponseWithMeta(favorites, &models.MetaData{
+		TotalCount: int(total),
+		Page:       page,
+		PageSize:   pageSize,
+	}))
+}
+
+// AddFavorite saves an activity; saving it again is not an error
+//
+// Returns:
+//   - 201: The favorite with its activity
+//   - 400: Invalid activity ID
+//   - 404: Activity not found
+//   - 500: Internal server error
+func (h *FavoriteHandler) AddFavorite(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	userID, _ := middleware.UserID(c)
+	favorite, err := h.favorites.Add(c.UserContext(), userID, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("activity not found"))
+		}
+		log.Printf("[ERROR] Add favorite %d for user %d: %v", id, userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to save favorite"))
+	}
+	return c.Status(fiber.StatusCreated).JSON(models.CreateSuccessResponse(favorite))
+}
+
+// RemoveFavorite unsaves an activity; removing one that is not saved is not an error
+//
+// Returns:
+//   - 200: Favorite removed
+//   - 400: Invalid activity ID
+//   - 500: Internal server error
+func (h *FavoriteHandler) RemoveFavorite(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	userID, _ := middleware.UserID(c)
+	if err := h.favorites.Remove(c.UserContext(), userID, id); err != nil {
+		log.Printf("[ERROR] Remove favorite %d for user %d: %v", id, userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to remove favorite"))
+	}
+	return c.JSON(models.CreateMessageResponse("favorite removed"))
+}
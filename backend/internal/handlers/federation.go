@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"community-chatbot/internal/federation"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// FederationHandler handles activity bundle exchange with other communities
+type FederationHandler struct {
+	federation *services.FederationService
+}
+
+// NewFederationHandler creates a new federation handler
+func NewFederationHandler(federation *services.FederationService) *FederationHandler {
+	return &FederationHandler{
+		federation: federation,
+	}
+}
+
+// GetCommunity returns the community name and public key peers need to trust this instance's bundles
+//
+// Returns:
+//   - 200: Community name and base64 Ed25519 public key
+//   - 503: Export not configured
+func (h *FederationHandler) GetCommunity(c *fiber.Ctx) error {
+	name, publicKey, err := h.federation.Community()
+	if err != nil {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(models.CreateErrorResponse("federation export is not configured"))
+	}
+	return c.JSON(models.CreateSuccessResponse(fiber.Map{
+		"community":  name,
+		"public_key": publicKey,
+	}))
+}
+
+// ExportBundle returns a signed bundle of approved activities with their media manifests (admin only)
+//
+// Query parameters: category, ids (comma separated activity IDs)
+//
+// Returns:
+//   - 200: Signed bundle
+//   - 400: Invalid ids
+//   - 503: Export not configured
+//   - 500: Internal server error
+func (h *FederationHandler) ExportBundle(c *fiber.Ctx) error {
+	filter := services.ExportFilter{Category: c.Query("category")}
+	if raw := c.Query("ids"); raw != "" {
+		for _, part := range strings.Split(raw, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+			if err != nil || id == 0 {
+				return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("ids must be comma separated activity IDs"))
+			}
+			filter.IDs = append(filter.IDs, uint(id))
+		}
+	}
+
+	envelope, err := h.federation.Export(c.UserContext(), filter)
+	if err != nil {
+		if errors.Is(err, services.ErrFederationDisabled) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(models.CreateErrorResponse("federation export is not configured"))
+		}
+		log.Printf("[ERROR] Export bundle: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to export bundle"))
+	}
+	return c.JSON(envelope)
+}
+
+// ImportBundle imports a signed bundle from a trusted community (admin only)
+//
+// Request body: a bundle as returned by the export endpoint
+//
+// Returns:
+//   - 200: Import counts and the mapping of source to local activity IDs
+//   - 400: Invalid bundle
+//   - 403: Untrusted source or invalid signature
+//   - 503: Import not configured
+//   - 500: Internal server error
+func (h *FederationHandler) ImportBundle(c *fiber.Ctx) error {
+	var envelope federation.Envelope
+	if err := c.BodyParser(&envelope); err != nil || len(envelope.Bundle) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+
+	result, err := h.federation.Import(c.UserContext(), &envelope)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrFederationDisabled):
+			return c.Status(fiber.StatusServiceUnavailable).JSON(models.CreateErrorResponse("federation import is not configured"))
+		case errors.Is(err, federation.ErrUntrustedSource), errors.Is(err, federation.ErrInvalidSignature):
+			return c.Status(fiber.StatusForbidden).JSON(models.CreateErrorResponse(err.Error()))
+		case errors.Is(err, federation.ErrInvalidBundle), errors.Is(err, federation.ErrUnsupportedVersion):
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		log.Printf("[ERROR] Import bundle: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to import bundle"))
+	}
+
+	log.Printf("[FEDERATION] Imported %d activities from %s (%d duplicates, %d invalid)",
+		result.Imported, result.Community, result.Duplicates, result.Invalid)
+	return c.JSON(models.CreateSuccessResponse(result))
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/geocode"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GeocodeHandler handles place name lookups
+type GeocodeHandler struct {
+	geocode *services.GeocodeService
+}
+
+// NewGeocodeHandler creates a new geocode handler
+func NewGeocodeHandler(geocode *services.GeocodeService) *GeocodeHandler {
+	return &GeocodeHandler{
+		geocode: geocode,
+	}
+}
+
+// Geocode returns the places matching a name, best matches first, so clients
+// can turn "near Boulder" into coordinates for the activity endpoints
+//
+// Query parameters: q (required), limit (default and max 5)
+//
+// Returns:
+//   - 200: Matching places with their coordinates; empty when nothing matches
+//   - 400: Missing or overly long q
+//   - 429: Too many lookups queued for the geocoder, retry in a few seconds
+//   - 502: Geocoder request failed
+func (h *GeocodeHandler) Geocode(c *fiber.Ctx) error {
+	places, err := h.geocode.Search(c.UserContext(), c.Query("q"), c.QueryInt("limit", 5))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPlace) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		if errors.Is(err, geocode.ErrBusy) {
+			c.Set(fiber.HeaderRetryAfter, "5")
+			return c.Status(fiber.StatusTooManyRequests).JSON(models.CreateErrorResponse(geocode.ErrBusy.Message))
+		}
+		log.Printf("[ERROR] Geocode %q: %v", c.Query("q"), err)
+		return c.Status(fiber.StatusBadGateway).JSON(models.CreateErrorResponse("failed to look up place"))
+	}
+	return c.JSON(models.CreateSuccessResponse(places))
+}
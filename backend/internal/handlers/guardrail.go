@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GuardrailHandler handles the operator's topic rules for the chat
+type GuardrailHandler struct {
+	guardrails *services.GuardrailService
+}
+
+// NewGuardrailHandler creates a new guardrail handler
+func NewGuardrailHandler(guardrails *services.GuardrailService) *GuardrailHandler {
+	return &GuardrailHandler{
+		guardrails: guardrails,
+	}
+}
+
+// guardrailPolicyRequest is the body of SetGuardrails and the policy tried by DryRun
+type guardrailPolicyRequest struct {
+	AllowedTopics  []string `json:"allowed_topics" validate:"max=50,dive,max=100"`
+	BlockedTopics  []string `json:"blocked_topics" validate:"max=50,dive,max=100"`
+	RefusalStyle   string   `json:"refusal_style" validate:"omitempty,oneof=brief redirect"`
+	RefusalMessage string   `json:"refusal_message" validate:"max=500"`
+}
+
+// policy converts the request to a guardrail policy
+func (r *guardrailPolicyRequest) policy() *models.GuardrailPolicy {
+	return &models.GuardrailPolicy{
+		AllowedTopics:  r.AllowedTopics,
+		BlockedTopics:  r.BlockedTopics,
+		RefusalStyle:   r.RefusalStyle,
+		RefusalMessage: r.RefusalMessage,
+	}
+}
+
+// guardrailDryRunRequest is the body of DryRun
+type guardrailDryRunRequest struct {
+	Message  string `json:"message" validate:"required_without=Response,max=4000"`
+	Response string `json:"response" validate:"max=20000"`
+	// Policy is tried instead of the stored policy when set
+	Policy *guardrailPolicyRequest `json:"policy"`
+}
+
+// GetGuardrails returns the guardrail policy and the instruction it adds to the
+// system prompt (admin only)
+//
+// Returns:
+//   - 200: The policy and its compiled prompt
+//   - 500: Internal server error
+func (h *GuardrailHandler) GetGuardrails(c *fiber.Ctx) error {
+	policy, err := h.guardrails.Get(c.UserContext())
+	if err != nil {
+		log.Printf("[ERROR] Get guardrails: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to load guardrails"))
+	}
+	guardrails := h.guardrails.Compile(policy)
+	return c.JSON(models.CreateSuccessResponse(fiber.Map{
+		"policy":  policy,
+		"prompt":  guardrails.Prompt(),
+		"refusal": guardrails.RefusalMessage(),
+	}))
+}
+
+// SetGuardrails replaces the guardrail policy. Blocked topics medical, legal,
+// financial and political are recognized by built-in word lists; other topics
+// by their name. Empty topic lists lift the guardrails (admin only).
+//
+// Request body: {"allowed_topics": ["hiking", "cycling"], "blocked_topics": ["medical"],
+// "refusal_style": "redirect", "refusal_message": "I can only help with outdoor plans."}
+//
+// Returns:
+//   - 200: The stored policy
+//   - 400: Invalid policy
+//   - 500: Internal server error
+func (h *GuardrailHandler) SetGuardrails(c *fiber.Ctx) error {
+	var body guardrailPolicyRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	policy, err := h.guardrails.Set(c.UserContext(), body.policy())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidGuardrails) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		log.Printf("[ERROR] Set guardrails: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to set guardrails"))
+	}
+
+	log.Printf("[GUARDRAIL] Policy set: %d allowed topics, %d blocked topics, %s refusals",
+		len(policy.AllowedTopics), len(policy.BlockedTopics), policy.RefusalStyle)
+	return c.JSON(models.CreateSuccessResponse(policy))
+}
+
+// DryRun shows how the guardrails treat a message and a sample answer without
+// calling the model: the compiled prompt, whether each is on a blocked topic
+// and what the user would see. A policy in the body is tried instead of the
+// stored one (admin only).
+//
+// Request body: {"message": "Can I take ibuprofen before a hike?", "response": "...", "policy": {...}}
+//
+// Returns:
+//   - 200: Verdicts, compiled prompt and the answer as the user would see it
+//   - 400: Invalid body or policy
+//   - 500: Internal server error
+func (h *GuardrailHandler) DryRun(c *fiber.Ctx) error {
+	var body guardrailDryRunRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	var policy *models.GuardrailPolicy
+	if body.Policy != nil {
+		policy = body.Policy.policy()
+	}
+	result, err := h.guardrails.DryRun(c.UserContext(), policy, body.Message, body.Response)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidGuardrails) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		log.Printf("[ERROR] Guardrail dry run: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to run guardrails"))
+	}
+	return c.JSON(models.CreateSuccessResponse(result))
+}
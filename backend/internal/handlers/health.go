@@ -1,21 +1,27 @@
 package handlers
 
 import (
+	"context"
 	"time"
 
+	"community-chatbot/internal/cache"
+
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
 
 // HealthHandler handles health check endpoints
 type HealthHandler struct {
-	db *gorm.DB
+	db     *gorm.DB
+	checks *cache.Cache
 }
 
-// NewHealthHandler creates a new health handler
-func NewHealthHandler(db *gorm.DB) *HealthHandler {
+// NewHealthHandler creates a new health handler; checks caches the results of
+// the checks and may be nil to run them on every request
+func NewHealthHandler(db *gorm.DB, checks *cache.Cache) *HealthHandler {
 	return &HealthHandler{
-		db: db,
+		db:     db,
+		checks: checks,
 	}
 }
 
@@ -29,12 +35,15 @@ type HealthStatus struct {
 
 // GetHealth returns the health status of the application
 func (h *HealthHandler) GetHealth(c *fiber.Ctx) error {
+	database, _ := cache.Lookup(c.UserContext(), h.checks, "database", func(context.Context) (map[string]interface{}, error) {
+		return h.checkDatabase(), nil
+	})
 	health := HealthStatus{
 		Status:    "healthy",
 		Timestamp: time.Now(),
 		Version:   "1.0.0", // TODO: Get from build info
 		Checks: map[string]interface{}{
-			"database": h.checkDatabase(),
+			"database": database,
 		},
 	}
 
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"log"
+
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+	"community-chatbot/internal/upload"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ImageHandler handles activity image uploads
+type ImageHandler struct {
+	images       *services.ImageService
+	policy       upload.Policy
+	scanner      upload.Scanner
+	maxBytes     int64
+	maxDimension int
+}
+
+// NewImageHandler creates a new image handler accepting files of the policy's
+// types up to maxBytes and maxDimension pixels wide and high; scanner may be nil
+func NewImageHandler(images *services.ImageService, policy upload.Policy, scanner upload.Scanner, maxBytes int64, maxDimension int) *ImageHandler {
+	return &ImageHandler{
+		images:       images,
+		policy:       policy,
+		scanner:      scanner,
+		maxBytes:     maxBytes,
+		maxDimension: maxDimension,
+	}
+}
+
+// UploadImage accepts an image for an activity, by default JPEG, PNG, GIF or
+// WebP. The type is sniffed from the content and must match the file name
+// extension. The image is uploaded to the image host in the background and
+// stays processing until its URL is available; the uploader is notified when
+// processing completes.
+//
+// Form fields: file, caption
+//
+// Returns:
+//   - 202: Image accepted and processing
+//   - 400: Invalid activity ID, missing file or caption too long
+//   - 404: Activity not found
+//   - 413: File or image dimensions too large
+//   - 415: File is not an allowed image type or its extension does not match
+//   - 422: File failed the malware scan
+//   - 500: Internal server error
+//   - 503: Malware scanner unavailable
+func (h *ImageHandler) UploadImage(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("file is required"))
+	}
+	caption := c.FormValue("caption")
+	if len([]rune(caption)) > 255 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("caption must be at most 255 characters"))
+	}
+
+	data, err := readUpload(fileHeader, h.maxBytes)
+	if err != nil {
+		return err
+	}
+	// The type is sniffed from the content; the client-supplied type is not trusted
+	contentType, err := checkUploadType(h.policy, fileHeader.Filename, data)
+	if err != nil {
+		return err
+	}
+	if err := upload.CheckImageSize(contentType, data, h.maxDimension); err != nil {
+		if errors.Is(err, upload.ErrImageTooLarge) {
+			return c.Status(fiber.StatusRequestEntityTooLarge).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(models.CreateErrorResponse("file is not a readable image"))
+	}
+	if err := scanUpload(c, h.scanner, fileHeader.Filename, data); err != nil {
+		return err
+	}
+
+	userID, _ := middleware.UserID(c)
+	image, err := h.images.Upload(c.UserContext(), id, userID, caption, bytes.NewReader(data))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("activity not found"))
+		}
+		log.Printf("[ERROR] Image upload for activity %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to accept image"))
+	}
+
+	log.Printf("[IMAGES] Accepted image %d for activity %d, upload queued", image.ID, id)
+	return c.Status(fiber.StatusAccepted).JSON(models.CreateSuccessResponse(image))
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// unsafeFilename matches characters replaced in download file names
+var unsafeFilename = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// ItineraryHandler handles printable itinerary endpoints
+type ItineraryHandler struct {
+	itineraries *services.ItineraryService
+}
+
+// NewItineraryHandler creates a new itinerary handler
+func NewItineraryHandler(itineraries *services.ItineraryService) *ItineraryHandler {
+	return &ItineraryHandler{
+		itineraries: itineraries,
+	}
+}
+
+// createItineraryRequest is the body of CreateItinerary
+type createItineraryRequest struct {
+	ActivityIDs []uint `json:"activity_ids" validate:"required,min=1,max=10"`
+	Title       string `json:"title" validate:"max=255"`
+}
+
+// CreateItinerary requests a printable PDF itinerary of activities, in visiting
+// order. The PDF is rendered in the background; poll the itinerary until it is
+// ready and has a download link.
+//
+// Request body: {"activity_ids": [1, 2], "title": "..."}
+//
+// Returns:
+//   - 202: Itinerary accepted and pending
+//   - 400: Invalid input data or activities that do not exist
+//   - 500: Internal server error
+func (h *ItineraryHandler) CreateItinerary(c *fiber.Ctx) error {
+	var body createItineraryRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	var userID *uint
+	if id, ok := middleware.UserID(c); ok {
+		userID = &id
+	}
+	itinerary, err := h.itineraries.Create(c.UserContext(), userID, body.Title, body.ActivityIDs)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidItinerary) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		log.Printf("[ERROR] Create itinerary: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to create itinerary"))
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(models.CreateSuccessResponse(itinerary))
+}
+
+// GetItinerary returns the status of an itinerary and, once rendered, its download link
+//
+// Returns:
+//   - 200: Itinerary
+//   - 404: Itinerary not found
+//   - 500: Internal server error
+func (h *ItineraryHandler) GetItinerary(c *fiber.Ctx) error {
+	itinerary, err := h.itineraries.Get(c.UserContext(), c.Params("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("itinerary not found"))
+		}
+		log.Printf("[ERROR] Get itinerary %s: %v", c.Params("id"), err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to load itinerary"))
+	}
+	return c.JSON(models.CreateSuccessResponse(itinerary))
+}
+
+// DownloadItinerary returns the rendered PDF of an itinerary
+//
+// Returns:
+//   - 200: PDF document
+//   - 404: Itinerary not found or not rendered yet
+//   - 500: Internal server error
+func (h *ItineraryHandler) DownloadItinerary(c *fiber.Ctx) error {
+	itinerary, err := h.itineraries.PDF(c.UserContext(), c.Params("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("itinerary not found or not ready"))
+		}
+		log.Printf("[ERROR] Download itinerary %s: %v", c.Params("id"), err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to load itinerary"))
+	}
+
+	filename := strings.Trim(unsafeFilename.ReplaceAllString(strings.ToLower(itinerary.Title), "-"), "-")
+	if filename == "" {
+		filename = "itinerary"
+	}
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.pdf"`, filename))
+	c.Set(fiber.HeaderCacheControl, "private, max-age=3600")
+	return c.Send(itinerary.PDF)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/auth"
+	"community-chatbot/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// KeyHandler handles JWT signing key management and JWKS endpoints
+type KeyHandler struct {
+	keyring *auth.Keyring
+}
+
+// NewKeyHandler creates a new key handler
+func NewKeyHandler(keyring *auth.Keyring) *KeyHandler {
+	return &KeyHandler{
+		keyring: keyring,
+	}
+}
+
+// GetJWKS returns the public keys currently accepted for token verification
+//
+// Returns:
+//   - 200: JSON Web Key Set
+func (h *KeyHandler) GetJWKS(c *fiber.Ctx) error {
+	// Short cache so rotations propagate to verifiers within minutes
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(h.keyring.JWKS())
+}
+
+// ListKeys returns all signing keys with their status (admin only)
+//
+// Returns:
+//   - 200: Signing keys, newest first
+//   - 500: Internal server error
+func (h *KeyHandler) ListKeys(c *fiber.Ctx) error {
+	keys, err := h.keyring.List(c.UserContext())
+	if err != nil {
+		log.Printf("[ERROR] List signing keys: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list signing keys"))
+	}
+	return c.JSON(models.CreateSuccessResponse(keys))
+}
+
+// RotateKey creates a new signing key for a scope and retires the previous ones (admin only)
+//
+// Request body: {"scope": "access" | "refresh" | "embed"}
+//
+// Returns:
+//   - 201: The new signing key
+//   - 400: Invalid scope
+//   - 500: Internal server error
+func (h *KeyHandler) RotateKey(c *fiber.Ctx) error {
+	var body struct {
+		Scope string `json:"scope"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+
+	key, err := h.keyring.Rotate(c.UserContext(), body.Scope)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidScope) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("scope must be access, refresh or embed"))
+		}
+		log.Printf("[ERROR] Rotate %s signing key: %v", body.Scope, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to rotate signing key"))
+	}
+
+	log.Printf("[AUTH] Rotated %s signing key, new kid %s", key.Scope, key.KID)
+	return c.Status(fiber.StatusCreated).JSON(models.CreateSuccessResponse(key))
+}
+
+// RevokeKey stops accepting a signing key immediately, without a grace window (admin only)
+//
+// Returns:
+//   - 200: Key revoked
+//   - 404: Key not found or already revoked
+//   - 500: Internal server error
+func (h *KeyHandler) RevokeKey(c *fiber.Ctx) error {
+	kid := c.Params("kid")
+	if err := h.keyring.Revoke(c.UserContext(), kid); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("signing key not found"))
+		}
+		log.Printf("[ERROR] Revoke signing key %s: %v", kid, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to revoke signing key"))
+	}
+
+	log.Printf("[AUTH] Revoked signing key %s", kid)
+	return c.JSON(models.CreateMessageResponse("signing key revoked"))
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"log"
+
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LocationHistoryHandler lets users view and purge their opt-in location history
+type LocationHistoryHandler struct {
+	history *services.LocationHistoryService
+}
+
+// NewLocationHistoryHandler creates a new location history handler
+func NewLocationHistoryHandler(history *services.LocationHistoryService) *LocationHistoryHandler {
+	return &LocationHistoryHandler{
+		history: history,
+	}
+}
+
+// GetLocationHistory returns the areas the user searched from, most frequent first
+//
+// Returns:
+//   - 200: Coarse areas with search counts
+//   - 500: Internal server error
+func (h *LocationHistoryHandler) GetLocationHistory(c *fiber.Ctx) error {
+	userID, _ := middleware.UserID(c)
+	areas, err := h.history.FrequentAreas(c.UserContext(), userID, 100)
+	if err != nil {
+		log.Printf("[ERROR] Get location history: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to load location history"))
+	}
+	return c.JSON(models.CreateSuccessResponse(areas))
+}
+
+// PurgeLocationHistory deletes the user's location history. Recording continues
+// while consent is given; disable location_history in preferences to stop it.
+//
+// Returns:
+//   - 200: History deleted
+//   - 500: Internal server error
+func (h *LocationHistoryHandler) PurgeLocationHistory(c *fiber.Ctx) error {
+	userID, _ := middleware.UserID(c)
+	if _, err := h.history.Purge(c.UserContext(), userID); err != nil {
+		log.Printf("[ERROR] Purge location history: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to purge location history"))
+	}
+	return c.JSON(models.CreateMessageResponse("location history deleted"))
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LogSamplingHandler handles the sampling of request logs
+type LogSamplingHandler struct {
+	sampling *services.LogSamplingService
+}
+
+// NewLogSamplingHandler creates a new log sampling handler
+func NewLogSamplingHandler(sampling *services.LogSamplingService) *LogSamplingHandler {
+	return &LogSamplingHandler{
+		sampling: sampling,
+	}
+}
+
+// logSamplingRequest is the body of SetLogSampling
+type logSamplingRequest struct {
+	DefaultRate float64 `json:"default_rate"`
+	// Rules are matched on every request, so their number is capped
+	Rules           map[string]float64 `json:"rules" validate:"max=50"`
+	SlowThresholdMS int                `json:"slow_threshold_ms"`
+}
+
+// GetLogSampling returns the sampling request logs currently follow (admin only)
+//
+// Returns:
+//   - 200: The log sampling policy
+func (h *LogSamplingHandler) GetLogSampling(c *fiber.Ctx) error {
+	return c.JSON(models.CreateSuccessResponse(h.sampling.Get()))
+}
+
+// SetLogSampling replaces the sampling of request logs. Requests are logged
+// with the rate of the longest matching rule, or the default rate; rule paths
+// ending in * match by prefix. Failed requests and requests slower than
+// slow_threshold_ms are always logged (admin only).
+//
+// Request body: {"default_rate": 1, "rules": {"/api/v1/chat/stream": 0.01}, "slow_threshold_ms": 2000}
+//
+// Returns:
+//   - 200: The stored policy
+//   - 400: Invalid policy
+//   - 500: Internal server error
+func (h *LogSamplingHandler) SetLogSampling(c *fiber.Ctx) error {
+	var body logSamplingRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	policy, err := h.sampling.Set(c.UserContext(), &models.LogSamplingPolicy{
+		DefaultRate:     body.DefaultRate,
+		Rules:           body.Rules,
+		SlowThresholdMS: body.SlowThresholdMS,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidLogSampling) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		log.Printf("[ERROR] Set log sampling: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to set log sampling"))
+	}
+
+	log.Printf("[LOGGING] Sampling set: default rate %v, %d rules, slow threshold %dms",
+		policy.DefaultRate, len(policy.Rules), policy.SlowThresholdMS)
+	return c.JSON(models.CreateSuccessResponse(policy))
+}
+
+// ResetLogSampling returns to the sampling configured with LOG_SAMPLE_* (admin only)
+//
+// Returns:
+//   - 200: The configured policy
+//   - 500: Internal server error
+func (h *LogSamplingHandler) ResetLogSampling(c *fiber.Ctx) error {
+	policy, err := h.sampling.Reset(c.UserContext())
+	if err != nil {
+		log.Printf("[ERROR] Reset log sampling: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to reset log sampling"))
+	}
+
+	log.Printf("[LOGGING] Sampling reset to the configured defaults")
+	return c.JSON(models.CreateSuccessResponse(policy))
+}
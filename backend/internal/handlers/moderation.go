@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ModerationHandler handles the admin review queue for activities, images and reported reviews
+type ModerationHandler struct {
+	moderation *services.ModerationService
+}
+
+// NewModerationHandler creates a new moderation handler
+func NewModerationHandler(moderation *services.ModerationService) *ModerationHandler {
+	return &ModerationHandler{
+		moderation: moderation,
+	}
+}
+
+// rejectRequest is the body of a rejection
+type rejectRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// ListPendingActivities returns activities awaiting review, oldest first (moderators only)
+//
+// Query parameters: page, page_size
+//
+// Returns:
+//   - 200: Pending activities with pagination metadata
+//   - 500: Internal server error
+func (h *ModerationHandler) ListPendingActivities(c *fiber.Ctx) error {
+	page, pageSize := parsePagination(c)
+	activities, total, err := h.moderation.PendingActivities(c.UserContext(), page, pageSize)
+	if err != nil {
+		log.Printf("[ERROR] List pending activities: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list pending activities"))
+	}
+
+	return c.JSON(models.CreateSuccessResponseWithMeta(activities, &models.MetaData{
+		TotalCount: int(total),
+		Page:       page,
+		PageSize:   pageSize,
+	}))
+}
+
+// ListPendingImages returns images awaiting review, those automatic screening
+// found borderline first, then oldest first (moderators only)
+//
+// Query parameters: screening_status (clear or review), page, page_size
+//
+// Returns:
+//   - 200: Pending images with pagination metadata
+//   - 500: Internal server error
+func (h *ModerationHandler) ListPendingImages(c *fiber.Ctx) error {
+	page, pageSize := parsePagination(c)
+	images, total, err := h.moderation.PendingImages(c.UserContext(), c.Query("screening_status"), page, pageSize)
+	if err != nil {
+		log.Printf("[ERROR] List pending images: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list pending images"))
+	}
+
+	return c.JSON(models.CreateSuccessResponseWithMeta(images, &models.MetaData{
+		TotalCount: int(total),
+		Page:       page,
+		PageSize:   pageSize,
+	}))
+}
+
+// ListAutoApprovedActivities returns published activities of trusted
+// submitters, newest first, for auditing (moderators only)
+//
+// Query parameters: page, page_size
+//
+// Returns:
+//   - 200: Auto-approved activities with pagination metadata
+//   - 500: Internal server error
+func (h *ModerationHandler) ListAutoApprovedActivities(c *fiber.Ctx) error {
+	page, pageSize := parsePagination(c)
+	activities, total, err := h.moderation.AutoApprovedActivities(c.UserContext(), page, pageSize)
+	if err != nil {
+		log.Printf("[ERROR] List auto-approved activities: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list auto-approved activities"))
+	}
+
+	return c.JSON(models.CreateSuccessResponseWithMeta(activities, &models.MetaData{
+		TotalCount: int(total),
+		Page:       page,
+		PageSize:   pageSize,
+	}))
+}
+
+// ListAutoApprovedImages returns images published without a moderator, newest
+// first, for auditing (moderators only)
+//
+// Query parameters: page, page_size
+//
+// Returns:
+//   - 200: Auto-approved images with pagination metadata
+//   - 500: Internal server error
+func (h *ModerationHandler) ListAutoApprovedImages(c *fiber.Ctx) error {
+	page, pageSize := parsePagination(c)
+	images, total, err := h.moderation.AutoApprovedImages(c.UserContext(), page, pageSize)
+	if err != nil {
+		log.Printf("[ERROR] List auto-approved images: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list auto-approved images"))
+	}
+
+	return c.JSON(models.CreateSuccessResponseWithMeta(images, &models.MetaData{
+		TotalCount: int(total),
+		Page:       page,
+		PageSize:   pageSize,
+	}))
+}
+
+// ListFlaggedReviews returns reviews users reported, oldest first (moderators only)
+//
+// Query parameters: page, page_size
+//
+// Returns:
+//   - 200: Flagged reviews with pagination metadata
+//   - 500: Internal server error
+func (h *ModerationHandler) ListFlaggedReviews(c *fiber.Ctx) error {
+	page, pageSize := parsePagination(c)
+	reviews, total, err := h.moderation.FlaggedReviews(c.UserContext(), page, pageSize)
+	if err != nil {
+		log.Printf("[ERROR] List flagged reviews: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list flagged reviews"))
+	}
+
+	return c.JSON(models.CreateSuccessResponseWithMeta(reviews, &models.MetaData{
+		TotalCount: int(total),
+		Page:       page,
+		PageSize:   pageSize,
+	}))
+}
+
+// ApproveActivity publishes a pending activity and notifies its submitter (moderators only)
+//
+// Returns:
+//   - 200: The approved activity
+//   - 400: Invalid activity ID
+//   - 404: Activity not found
+//   - 409: Activity already moderated
+func (h *ModerationHandler) ApproveActivity(c *fiber.Ctx) error {
+	return h.moderateActivity(c, true)
+}
+
+// RejectActivity rejects a pending activity with a reason shown to its submitter (moderators only)
+//
+// Request body: {"reason": "..."}
+//
+// Returns:
+//   - 200: The rejected activity
+//   - 400: Invalid activity ID or missing reason
+//   - 404: Activity not found
+//   - 409: Activity already moderated
+func (h *ModerationHandler) RejectActivity(c *fiber.Ctx) error {
+	return h.moderateActivity(c, false)
+}
+
+// ApproveImage publishes a pending image (moderators only)
+//
+// Returns:
+//   - 200: The approved image
+//   - 400: Invalid image ID
+//   - 404: Image not found
+//   - 409: Image already moderated or its upload has not completed
+func (h *ModerationHandler) ApproveImage(c *fiber.Ctx) error {
+	return h.moderateImage(c, true)
+}
+
+// RejectImage rejects a pending image with a reason (moderators only)
+//
+// Request body: {"reason": "..."}
+//
+// Returns:
+//   - 200: The rejected image
+//   - 400: Invalid image ID or missing reason
+//   - 404: Image not found
+//   - 409: Image already moderated or its upload has not completed
+func (h *ModerationHandler) RejectImage(c *fiber.Ctx) error {
+	return h.moderateImage(c, false)
+}
+
+// RevokeActivity takes down an auto-approved activity with a reason shown to its
+// submitter; it counts as a violation of the submitter (moderators only)
+//
+// Request body: {"reason": "..."}
+//
+// Returns:
+//   - 200: The revoked activity
+//   - 400: Invalid activity ID or missing reason
+//   - 404: Activity not found
+//   - 409: Activity is not auto-approved
+func (h *ModerationHandler) RevokeActivity(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+	reason, err := rejectionReason(c, false)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	}
+
+	activity, err := h.moderation.RevokeActivity(c.UserContext(), id, reason)
+	if err != nil {
+		return moderationError(c, "activity", id, err)
+	}
+
+	log.Printf("[MODERATION] Activity %d auto-approval revoked", id)
+	return c.JSON(models.CreateSuccessResponse(activity))
+}
+
+// RevokeImage takes down an auto-approved image; it counts as a violation of
+// the uploader (moderators only)
+//
+// Request body: {"reason": "..."}
+//
+// Returns:
+//   - 200: The revoked image
+//   - 400: Invalid image ID or missing reason
+//   - 404: Image not found
+//   - 409: Image is not auto-approved
+func (h *ModerationHandler) RevokeImage(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid image id"))
+	}
+	reason, err := rejectionReason(c, false)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	}
+
+	image, err := h.moderation.RevokeImage(c.UserContext(), uint(id), reason)
+	if err != nil {
+		return moderationError(c, "image", uint(id), err)
+	}
+
+	log.Printf("[MODERATION] Image %d auto-approval revoked", id)
+	return c.JSON(models.CreateSuccessResponse(image))
+}
+
+// HideReview removes a review from listings and ratings (moderators only)
+//
+// Returns:
+//   - 200: The hidden review
+//   - 400: Invalid review ID
+//   - 404: Review not found
+func (h *ModerationHandler) HideReview(c *fiber.Ctx) error {
+	return h.moderateReview(c, true)
+}
+
+// RestoreReview dismisses the reports of a review and shows it again if it was hidden (moderators only)
+//
+// Returns:
+//   - 200: The restored review
+//   - 400: Invalid review ID
+//   - 404: Review not found
+func (h *ModerationHandler) RestoreReview(c *fiber.Ctx) error {
+	return h.moderateReview(c, false)
+}
+
+// moderateActivity records the decision on the :id activity
+func (h *ModerationHandler) moderateActivity(c *fiber.Ctx, approve bool) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+	reason, err := rejectionReason(c, approve)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	}
+
+	activity, err := h.moderation.ModerateActivity(c.UserContext(), id, approve, reason)
+	if err != nil {
+		return moderationError(c, "activity", id, err)
+	}
+
+	log.Printf("[MODERATION] Activity %d approved=%t", id, approve)
+	return c.JSON(models.CreateSuccessResponse(activity))
+}
+
+// moderateImage records the decision on the :id image
+func (h *ModerationHandler) moderateImage(c *fiber.Ctx, approve bool) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid image id"))
+	}
+	reason, err := rejectionReason(c, approve)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	}
+
+	image, err := h.moderation.ModerateImage(c.UserContext(), uint(id), approve, reason)
+	if err != nil {
+		return moderationError(c, "image", uint(id), err)
+	}
+
+	log.Printf("[MODERATION] Image %d approved=%t", id, approve)
+	return c.JSON(models.CreateSuccessResponse(image))
+}
+
+// moderateReview hides or restores the :id review
+func (h *ModerationHandler) moderateReview(c *fiber.Ctx, hide bool) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid review id"))
+	}
+
+	review, err := h.moderation.ModerateReview(c.UserContext(), uint(id), hide)
+	if err != nil {
+		return moderationError(c, "review", uint(id), err)
+	}
+
+	log.Printf("[MODERATION] Review %d hidden=%t", id, hide)
+	return c.JSON(models.CreateSuccessResponse(review))
+}
+
+// rejectionReason parses and validates the rejection body; approvals take no body
+func rejectionReason(c *fiber.Ctx, approve bool) (string, error) {
+	if approve {
+		return "", nil
+	}
+
+	var body rejectRequest
+	if err := c.BodyParser(&body); err != nil {
+		return "", errors.New("invalid request body")
+	}
+	if err := validate.Struct(body); err != nil {
+		return "", errors.New(validationMessage(err))
+	}
+	return body.Reason, nil
+}
+
+// moderationError maps moderation service errors to HTTP responses
+func moderationError(c *fiber.Ctx, kind string, id uint, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse(kind + " not found"))
+	}
+	if errors.Is(err, services.ErrAlreadyModerated) {
+		return c.Status(fiber.StatusConflict).JSON(models.CreateErrorResponse(kind + " was already moderated"))
+	}
+	if errors.Is(err, services.ErrImageNotReady) || errors.Is(err, services.ErrNotAutoApproved) {
+		return c.Status(fiber.StatusConflict).JSON(models.CreateErrorResponse(err.Error()))
+	}
+
+	log.Printf("[ERROR] Moderate %s %d: %v", kind, id, err)
+	return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to moderate " + kind))
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// NotificationHandler handles the authenticated user's notification inbox
+type NotificationHandler struct {
+	notifications *services.NotificationService
+}
+
+// NewNotificationHandler creates a new notification handler
+func NewNotificationHandler(notifications *services.NotificationService) *NotificationHandler {
+	return &NotificationHandler{
+		notifications: notifications,
+	}
+}
+
+// ListNotifications returns a paginated list of the user's notifications, newest first
+//
+// Query parameters: unread, page, page_size
+//
+// Returns:
+//   - 200: Notifications with pagination metadata
+//   - 500: Internal server error
+func (h *NotificationHandler) ListNotifications(c *fiber.Ctx) error {
+	userID, _ := middleware.UserID(c)
+	page, pageSize := parsePagination(c)
+
+	notifications, total, err := h.notifications.List(c.UserContext(), userID, c.QueryBool("unread"), page, pageSize)
+	if err != nil {
+		log.Printf("[ERROR] List notifications: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list notifications"))
+	}
+
+	return c.JSON(models.CreateSuccessResponseWithMeta(notifications, &models.MetaData{
+		TotalCount: int(total),
+		Page:       page,
+		PageSize:   pageSize,
+	}))
+}
+
+// MarkNotificationRead marks a notification as read
+//
+// Returns:
+//   - 200: Notification marked read
+//   - 400: Invalid notification ID
+//   - 404: Notification not found
+func (h *NotificationHandler) MarkNotificationRead(c *fiber.Ctx) error {
+	userID, _ := middleware.UserID(c)
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid notification id"))
+	}
+
+	if err := h.notifications.MarkRead(c.UserContext(), userID, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("notification not found"))
+		}
+		log.Printf("[ERROR] Mark notification %d read: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to update notification"))
+	}
+
+	return c.JSON(models.CreateMessageResponse("notification marked read"))
+}
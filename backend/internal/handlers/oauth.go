@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/auth/oauth"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// oauthStateCookie carries the state of a login until the provider redirects back
+const oauthStateCookie = "oauth_state"
+
+// oauthStateTTL is how long users have to complete a login on the provider's pages
+const oauthStateTTL = 10 * time.Minute
+
+// OAuthHandler signs users in with external identity providers
+type OAuthHandler struct {
+	auth        *services.AuthService
+	providers   map[string]*oauth.Provider
+	publicURL   string
+	frontendURL string
+}
+
+// NewOAuthHandler creates a new OAuth handler. Callbacks are registered with
+// the providers at publicURL, or at the request's base URL without it; users
+// land on frontendURL/auth/callback afterwards.
+func NewOAuthHandler(auth *services.AuthService, providers []*oauth.Provider, publicURL, frontendURL string) *OAuthHandler {
+	byName := make(map[string]*oauth.Provider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+	}
+	return &OAuthHandler{
+		auth:        auth,
+		providers:   byName,
+		publicURL:   strings.TrimRight(publicURL, "/"),
+		frontendURL: strings.TrimRight(frontendURL, "/"),
+	}
+}
+
+// ListProviders returns the names of the providers users can sign in with
+//
+// Returns:
+//   - 200: Provider names, e.g. ["github", "google"]
+func (h *OAuthHandler) ListProviders(c *fiber.Ctx) error {
+	names := make([]string, 0, len(h.providers))
+	for name := range h.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return c.JSON(models.CreateSuccessResponse(names))
+}
+
+// Login redirects to a provider's consent page
+//
+// Returns:
+//   - 302: Redirect to the provider
+//   - 404: Unknown or unconfigured provider
+//   - 500: Internal server error
+func (h *OAuthHandler) Login(c *fiber.Ctx) error {
+	provider, ok := h.providers[c.Params("provider")]
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("unknown provider"))
+	}
+
+	state, err := oauth.NewState()
+	if err != nil {
+		log.Printf("[ERROR] OAuth login with %s: %v", provider.Name(), err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to start login"))
+	}
+	h.setStateCookie(c, state, time.Now().Add(oauthStateTTL))
+	return c.Redirect(provider.AuthCodeURL(h.callbackURL(c, provider), state), fiber.StatusFound)
+}
+
+// Callback completes a login when the provider redirects back, and redirects
+// to the frontend's /auth/callback page with the token pair in the fragment
+// (access_token, refresh_token, token_type, expires_in), or with an error
+// (provider_denied, invalid_state, provider_failed, email_unverified,
+// server_error)
+//
+// Query parameters: code, state (set by the provider)
+//
+// Returns:
+//   - 302: Redirect to the frontend
+//   - 404: Unknown or unconfigured provider
+func (h *OAuthHandler) Callback(c *fiber.Ctx) error {
+	provider, ok := h.providers[c.Params("provider")]
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("unknown provider"))
+	}
+
+	// The state is single use
+	state := c.Cookies(oauthStateCookie)
+	h.setStateCookie(c, "", time.Unix(0, 0))
+
+	if c.Query("error") != "" {
+		return h.finish(c, url.Values{"error": {"provider_denied"}})
+	}
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+		return h.finish(c, url.Values{"error": {"invalid_state"}})
+	}
+
+	identity, err := provider.Exchange(c.UserContext(), h.callbackURL(c, provider), c.Query("code"))
+	if err != nil {
+		log.Printf("[ERROR] OAuth callback from %s: %v", provider.Name(), err)
+		return h.finish(c, url.Values{"error": {"provider_failed"}})
+	}
+	user, tokens, err := h.auth.OAuthLogin(c.UserContext(), identity)
+	if err != nil {
+		if errors.Is(err, services.ErrOAuthEmailUnverified) {
+			return h.finish(c, url.Values{"error": {"email_unverified"}})
+		}
+		log.Printf("[ERROR] OAuth login with %s: %v", provider.Name(), err)
+		return h.finish(c, url.Values{"error": {"server_error"}})
+	}
+
+	log.Printf("[AUTH] User %d signed in with %s", user.ID, provider.Name())
+	return h.finish(c, url.Values{
+		"access_token":  {tokens.AccessToken},
+		"refresh_token": {tokens.RefreshToken},
+		"token_type":    {tokens.TokenType},
+		"expires_in":    {strconv.Itoa(tokens.ExpiresIn)},
+	})
+}
+
+// finish redirects to the frontend with the outcome in the fragment, which
+// browsers do not send to servers or in Referer headers
+func (h *OAuthHandler) finish(c *fiber.Ctx, outcome url.Values) error {
+	return c.Redirect(h.frontendURL+"/auth/callback#"+outcome.Encode(), fiber.StatusFound)
+}
+
+// callbackURL returns where the provider redirects back to
+func (h *OAuthHandler) callbackURL(c *fiber.Ctx, provider *oauth.Provider) string {
+	base := h.publicURL
+	if base == "" {
+		base = c.BaseURL()
+	}
+	return base + "/api/v1/auth/" + provider.Name() + "/callback"
+}
+
+// setStateCookie sets the state cookie, or clears it with an expiry in the
+// past. It must be Lax to be sent on the provider's redirect back.
+func (h *OAuthHandler) setStateCookie(c *fiber.Ctx, state string, expires time.Time) {
+	c.Cookie(&fiber.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/api/v1/auth",
+		Expires:  expires,
+		Secure:   c.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"log"
+
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// PreferencesHandler handles the authenticated user's preferences
+type PreferencesHandler struct {
+	preferences *services.PreferencesService
+}
+
+// NewPreferencesHandler creates a new preferences handler
+func NewPreferencesHandler(preferences *services.PreferencesService) *PreferencesHandler {
+	return &PreferencesHandler{
+		preferences: preferences,
+	}
+}
+
+// preferencesRequest is the client-editable part of user preferences
+type preferencesRequest struct {
+	LocationLat         float64  `json:"location_lat" validate:"latitude"`
+	LocationLng         float64  `json:"location_lng" validate:"longitude"`
+	SearchRadiusKM      int      `json:"search_radius_km" validate:"gte=0,lte=500"`
+	PreferredActivities []string `json:"preferred_activities" validate:"max=20,dive,max=100"`
+	DifficultyLevel     string   `json:"difficulty_level" validate:"max=50"`
+	TransportMode       string   `json:"transport_mode" validate:"omitempty,oneof=car bike walk transit public_transport"`
+	DigestFrequency     string   `json:"digest_frequency" validate:"omitempty,oneof=daily weekly off"`
+	DigestHour          int      `json:"digest_hour" validate:"gte=0,lte=23"`
+	Timezone            string   `json:"timezone" validate:"omitempty,timezone"`
+	Language            string   `json:"language" validate:"omitempty,oneof=en de fr es it"`
+	DigestContent       []string `json:"digest_content" validate:"dive,oneof=new_activities new_routes"`
+	LocationHistory     bool     `json:"location_history"`
+}
+
+// GetPreferences returns the user's preferences, including digest settings
+//
+// Returns:
+//   - 200: Preferences
+//   - 500: Internal server error
+func (h *PreferencesHandler) GetPreferences(c *fiber.Ctx) error {
+	userID, _ := middleware.UserID(c)
+	prefs, err := h.preferences.Get(c.UserContext(), userID)
+	if err != nil {
+		log.Printf("[ERROR] Get preferences: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to load preferences"))
+	}
+	return c.JSON(models.CreateSuccessResponse(prefs))
+}
+
+// UpdatePreferences replaces the user's editable preferences. Setting location_history
+// to false withdraws consent and deletes the recorded history.
+//
+// Returns:
+//   - 200: Updated preferences
+//   - 400: Invalid input data
+//   - 500: Internal server error
+func (h *PreferencesHandler) UpdatePreferences(c *fiber.Ctx) error {
+	userID, _ := middleware.UserID(c)
+
+	var body preferencesRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	changes := &models.UserPreferences{
+		LocationLat:         body.LocationLat,
+		LocationLng:         body.LocationLng,
+		SearchRadiusKM:      body.SearchRadiusKM,
+		PreferredActivities: body.PreferredActivities,
+		DifficultyLevel:     body.DifficultyLevel,
+		TransportMode:       body.TransportMode,
+		DigestFrequency:     body.DigestFrequency,
+		DigestHour:          body.DigestHour,
+		Timezone:            body.Timezone,
+		Language:            body.Language,
+		DigestContent:       body.DigestContent,
+		LocationHistory:     body.LocationHistory,
+	}
+	switch changes.TransportMode {
+	case "":
+		changes.TransportMode = models.TransportCar
+	case models.TransportTransit:
+		changes.TransportMode = models.TransportPublic
+	}
+	if changes.DigestFrequency == "" {
+		changes.DigestFrequency = models.DigestOff
+	}
+	if changes.Timezone == "" {
+		changes.Timezone = "UTC"
+	}
+
+	prefs, err := h.preferences.Update(c.UserContext(), userID, changes)
+	if err != nil {
+		log.Printf("[ERROR] Update preferences: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to update preferences"))
+	}
+	return c.JSON(models.CreateSuccessResponse(prefs))
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// ReviewHandler handles activity review endpoints
+type ReviewHandler struct {
+	reviews *services.ReviewService
+}
+
+// NewReviewHandler creates a new review handler
+func NewReviewHandler(reviews *services.ReviewService) *ReviewHandler {
+	return &ReviewHandler{
+		reviews: reviews,
+	}
+}
+
+// reviewRequest is the client-editable part of a review
+type reviewRequest struct {
+	Rating int    `json:"rating" validate:"min=1,max=5"`
+	Body   string `json:"body" validate:"required,max=5000"`
+}
+
+// flagReviewRequest is the body of FlagReview
+type flagReviewRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// CreateReview adds a review to an activity and updates its rating. Sentiment
+// and highlights are extracted asynchronously and appear on the review once
+// analyzed.
+//
+// Returns:
+//   - 201: Review stored, pending analysis
+//   - 400: Invalid activity ID or input data
+//   - 404: Activity not found
+//   - 409: The user already reviewed the activity
+func (h *ReviewHandler) CreateReview(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	var body reviewRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	userID, _ := middleware.UserID(c)
+	review := &models.Review{
+		ActivityID: id,
+		UserID:     userID,
+		Rating:     body.Rating,
+		Body:       body.Body,
+	}
+	if err := h.reviews.Create(c.UserContext(), review); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("activity not found"))
+		}
+		if errors.Is(err, services.ErrAlreadyReviewed) {
+			return c.Status(fiber.StatusConflict).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		log.Printf("[ERROR] Create review for activity %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to create review"))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.CreateSuccessResponse(review))
+}
+
+// ListReviews returns a paginated list of an activity's reviews
+//
+// Query parameters: page, page_size
+//
+// Returns:
+//   - 200: Reviews with pagination metadata
+//   - 400: Invalid activity ID
+//   - 500: Internal server error
+func (h *ReviewHandler) ListReviews(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	page, pageSize := parsePagination(c)
+	reviews, total, err := h.reviews.List(c.UserContext(), id, page, pageSize)
+	if err != nil {
+		log.Printf("[ERROR] List reviews for activity %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list reviews"))
+	}
+
+	return c.JSON(models.CreateSuccessResponseWithMeta(reviews, &models.MetaData{
+		TotalCount: int(total),
+		Page:       page,
+		PageSize:   pageSize,
+	}))
+}
+
+// UpdateReview replaces the rating and text of the user's review; it is
+// analyzed again
+//
+// Returns:
+//   - 200: The updated review
+//   - 400: Invalid ID or input data
+//   - 403: Review written by another user
+//   - 404: Review not found
+func (h *ReviewHandler) UpdateReview(c *fiber.Ctx) error {
+	activity, id, ok := reviewIDs(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid review id"))
+	}
+
+	var body reviewRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	userID, _ := middleware.UserID(c)
+	review, err := h.reviews.Update(c.UserContext(), activity, id, userID, body.Rating, body.Body)
+	if err != nil {
+		return reviewError(c, id, err)
+	}
+	return c.JSON(models.CreateSuccessResponse(review))
+}
+
+// DeleteReview deletes the user's review and updates the activity's rating
+//
+// Returns:
+//   - 200: Review deleted
+//   - 400: Invalid ID
+//   - 403: Review written by another user
+//   - 404: Review not found
+func (h *ReviewHandler) DeleteReview(c *fiber.Ctx) error {
+	activity, id, ok := reviewIDs(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid review id"))
+	}
+
+	userID, _ := middleware.UserID(c)
+	if err := h.reviews.Delete(c.UserContext(), activity, id, userID); err != nil {
+		return reviewError(c, id, err)
+	}
+	return c.JSON(models.CreateMessageResponse("review deleted"))
+}
+
+// FlagReview reports a review to moderators
+//
+// Request body: {"reason": "..."} (optional)
+//
+// Returns:
+//   - 200: Review reported
+//   - 400: Invalid ID or reason too long
+//   - 404: Review not found
+func (h *ReviewHandler) FlagReview(c *fiber.Ctx) error {
+	activity, id, ok := reviewIDs(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid review id"))
+	}
+
+	var body flagReviewRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+		}
+	}
+	if err := validate.Struct(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	if err := h.reviews.Flag(c.UserContext(), activity, id, body.Reason); err != nil {
+		return reviewError(c, id, err)
+	}
+
+	userID, _ := middleware.UserID(c)
+	log.Printf("[MODERATION] Review %d flagged by user %d", id, userID)
+	return c.JSON(models.CreateMessageResponse("review reported to moderators"))
+}
+
+// reviewIDs parses the :id activity and :review_id review parameters
+func reviewIDs(c *fiber.Ctx) (activity, review uint, ok bool) {
+	activity, ok = activityID(c)
+	if !ok {
+		return 0, 0, false
+	}
+	id, err := c.ParamsInt("review_id")
+	if err != nil || id <= 0 {
+		return 0, 0, false
+	}
+	return activity, uint(id), true
+}
+
+// reviewError maps review service errors to HTTP responses
+func reviewError(c *fiber.Ctx, id uint, err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("review not found"))
+	case errors.Is(err, services.ErrNotReviewAuthor):
+		return c.Status(fiber.StatusForbidden).JSON(models.CreateErrorResponse(err.Error()))
+	}
+	log.Printf("[ERROR] Review %d: %v", id, err)
+	return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to update review"))
+}
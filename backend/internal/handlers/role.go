@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// RoleHandler handles admin changes of user roles
+type RoleHandler struct {
+	roles *services.RoleService
+}
+
+// NewRoleHandler creates a new role handler
+func NewRoleHandler(roles *services.RoleService) *RoleHandler {
+	return &RoleHandler{
+		roles: roles,
+	}
+}
+
+// roleRequest is the body of SetRole
+type roleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+// SetRole sets the role of a user: moderators review content, admins also
+// manage users and operate the community (admin only)
+//
+// Request body: {"role": "moderator"}
+//
+// Returns:
+//   - 200: The updated user
+//   - 400: Invalid user ID or role
+//   - 404: User not found
+//   - 500: Internal server error
+func (h *RoleHandler) SetRole(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid user id"))
+	}
+	var body roleRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	user, err := h.roles.SetRole(c.UserContext(), uint(id), body.Role)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRole) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("user not found"))
+		}
+		log.Printf("[ERROR] Set role of user %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to set role"))
+	}
+	log.Printf("[AUTH] Set role of user %d to %s", id, body.Role)
+	return c.JSON(models.CreateSuccessResponse(user))
+}
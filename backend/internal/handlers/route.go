@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"community-chatbot/internal/geo"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+	"community-chatbot/internal/upload"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// RouteHandler handles route endpoints
+type RouteHandler struct {
+	routes    *services.RouteService
+	policy    upload.Policy
+	scanner   upload.Scanner
+	maxBytes  int64
+	maxPoints int
+}
+
+// NewRouteHandler creates a new route handler accepting files of the policy's
+// types up to maxBytes and maxPoints track points; scanner may be nil
+func NewRouteHandler(routes *services.RouteService, policy upload.Policy, scanner upload.Scanner, maxBytes int64, maxPoints int) *RouteHandler {
+	return &RouteHandler{
+		routes:    routes,
+		policy:    policy,
+		scanner:   scanner,
+		maxBytes:  maxBytes,
+		maxPoints: maxPoints,
+	}
+}
+
+// UploadRoute accepts a route file for an activity, by default GPX, TCX, KML or
+// FIT. The format is sniffed from the content and must match the file name
+// extension.
+//
+// Returns:
+//   - 201: Route created with computed distance, elevation change and duration
+//   - 400: Missing file, unparseable route data or too many track points
+//   - 404: Activity not found
+//   - 413: File too large
+//   - 415: File is not an allowed route type or its extension does not match
+//   - 422: File failed the malware scan
+//   - 503: Malware scanner unavailable
+func (h *RouteHandler) UploadRoute(c *fiber.Ctx) error {
+	activityID, err := c.ParamsInt("id")
+	if err != nil || activityID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("file is required"))
+	}
+
+	data, err := readUpload(fileHeader, h.maxBytes)
+	if err != nil {
+		return err
+	}
+	if _, err := checkUploadType(h.policy, fileHeader.Filename, data); err != nil {
+		return err
+	}
+	if err := scanUpload(c, h.scanner, fileHeader.Filename, data); err != nil {
+		return err
+	}
+
+	route, err := h.routes.Import(c.UserContext(), services.RouteImport{
+		ActivityID: uint(activityID),
+		Filename:   fileHeader.Filename,
+		Data:       data,
+		Name:       c.FormValue("name"),
+		RouteType:  c.FormValue("route_type"),
+		Difficulty: c.FormValue("difficulty"),
+		MaxPoints:  h.maxPoints,
+	})
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("activity not found"))
+	case errors.Is(err, geo.ErrUnsupportedFormat), errors.Is(err, geo.ErrEmptyTrack),
+		errors.Is(err, geo.ErrTooManyPoints):
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+	case err != nil:
+		log.Printf("[ERROR] Route upload for activity %d: %v", activityID, err)
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("failed to parse route file"))
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.CreateSuccessResponse(route))
+}
+
+// GetElevationProfile returns the elevation profile of a route of an approved
+// activity for drawing a chart: elevations at distances along the track, at
+// most 500 points
+//
+// Returns:
+//   - 200: The route's distance, elevation gain and loss, and profile points
+//   - 400: Invalid route id
+//   - 404: Route not found, without a track or without elevation data
+//   - 500: Internal server error
+func (h *RouteHandler) GetElevationProfile(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid route id"))
+	}
+
+	route, profile, err := h.routes.ElevationProfile(c.UserContext(), uint(id))
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("route not found"))
+	case errors.Is(err, geo.ErrNoElevation):
+		return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("route has no elevation data"))
+	case err != nil:
+		log.Printf("[ERROR] Elevation profile of route %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to load elevation profile"))
+	}
+
+	return c.JSON(models.CreateSuccessResponse(fiber.Map{
+		"route_id":         route.ID,
+		"distance_km":      route.DistanceKM,
+		"elevation_gain_m": route.ElevationGainM,
+		"elevation_loss_m": route.ElevationLossM,
+		"points":           profile,
+	}))
+}
+
+// RecomputeStats recomputes the distance, elevation gain and loss, estimated
+// duration and difficulty of stored routes from their tracks (admin only)
+//
+// Query parameters: activity_id (only this activity's routes)
+//
+// Returns:
+//   - 200: The numbers of updated, unchanged and skipped routes
+//   - 400: Invalid activity id
+//   - 500: Internal server error
+func (h *RouteHandler) RecomputeStats(c *fiber.Ctx) error {
+	activityID := c.QueryInt("activity_id", 0)
+	if activityID < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	result, err := h.routes.RecomputeStats(c.UserContext(), uint(activityID))
+	if err != nil {
+		log.Printf("[ERROR] Recompute route stats: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to recompute route stats"))
+	}
+
+	log.Printf("[ROUTE] Stats recomputed: %d updated, %d unchanged, %d skipped",
+		result.Updated, result.Unchanged, result.Skipped)
+	return c.JSON(models.CreateSuccessResponse(result))
+}
+
+// exportCreator is the application named as the creator of exported route files
+const exportCreator = "community-chatbot"
+
+// ExportRoute returns the track of a route of an approved activity as a file
+// for mapping apps and GPS devices
+//
+// Query parameters: format (gpx, the default)
+//
+// Returns:
+//   - 200: GPX document
+//   - 400: Invalid route id or unsupported format
+//   - 404: Route not found or without a track
+//   - 500: Internal server error
+func (h *RouteHandler) ExportRoute(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid route id"))
+	}
+	if c.Query("format", geo.FormatGPX) != geo.FormatGPX {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("format must be gpx"))
+	}
+
+	route, err := h.routes.GetPublished(c.UserContext(), uint(id))
+	var track *geo.Track
+	if err == nil {
+		track, err = h.routes.LoadTrack(route)
+	}
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, geo.ErrEmptyTrack):
+		return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("route not found"))
+	case err != nil:
+		log.Printf("[ERROR] Export route %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to export route"))
+	}
+
+	filename := strings.Trim(unsafeFilename.ReplaceAllString(strings.ToLower(route.Name), "-"), "-")
+	if filename == "" {
+		filename = fmt.Sprintf("route-%d", route.ID)
+	}
+	c.Set(fiber.HeaderContentType, "application/gpx+xml")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.gpx"`, filename))
+	if err := track.WriteGPX(c.Response().BodyWriter(), route.Name, exportCreator); err != nil {
+		log.Printf("[ERROR] Export route %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to export route"))
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// SavedSearchHandler handles the saved searches of the signed-in user
+type SavedSearchHandler struct {
+	searches *services.SavedSearchService
+}
+
+// NewSavedSearchHandler creates a new saved search handler
+func NewSavedSearchHandler(searches *services.SavedSearchService) *SavedSearchHandler {
+	return &SavedSearchHandler{
+		searches: searches,
+	}
+}
+
+// ListSavedSearches returns the user's saved searches
+//
+// Returns:
+//   - 200: Saved searches, newest first
+//   - 500: Internal server error
+func (h *SavedSearchHandler) ListSavedSearches(c *fiber.Ctx) error {
+	userID, _ := middleware.UserID(c)
+	searches, err := h.searches.List(c.UserContext(), userID)
+	if err != nil {
+		log.Printf("[ERROR] List saved searches of user %d: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to list saved searches"))
+	}
+	return c.JSON(models.CreateSuccessResponse(searches))
+}
+
+// CreateSavedSearch saves a search; closures of matching activities are
+// notified to the user
+//
+// Request body: {"name": "...", "category": "...", "difficulty": "...", "location": {"lat": 0, "lng": 0}, "radius_km": 25}
+//
+// Returns:
+//   - 201: The saved search
+//   - 400: Invalid input data
+//   - 409: Too many saved searches
+//   - 500: Internal server error
+func (h *SavedSearchHandler) CreateSavedSearch(c *fiber.Ctx) error {
+	var search models.SavedSearch
+	if err := c.BodyParser(&search); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(search); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	userID, _ := middleware.UserID(c)
+	if err := h.searches.Create(c.UserContext(), userID, &search); err != nil {
+		if errors.Is(err, services.ErrTooManySavedSearches) {
+			return c.Status(fiber.StatusConflict).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		log.Printf("[ERROR] Save search for user %d: %v", userID, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to save search"))
+	}
+	return c.Status(fiber.StatusCreated).JSON(models.CreateSuccessResponse(search))
+}
+
+// DeleteSavedSearch removes one of the user's saved searches
+//
+// Returns:
+//   - 200: Saved search deleted
+//   - 400: Invalid saved search ID
+//   - 404: Saved search not found
+//   - 500: Internal server error
+func (h *SavedSearchHandler) DeleteSavedSearch(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid saved search id"))
+	}
+
+	userID, _ := middleware.UserID(c)
+	if err := h.searches.Delete(c.UserContext(), userID, uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("saved search not found"))
+		}
+		log.Printf("[ERROR] Delete saved search %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to delete saved search"))
+	}
+	return c.JSON(models.CreateMessageResponse("saved search deleted"))
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/middleware"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// SessionHandler handles the sessions of anonymous chat users
+type SessionHandler struct {
+	sessions *services.SessionService
+}
+
+// NewSessionHandler creates a new session handler
+func NewSessionHandler(sessions *services.SessionService) *SessionHandler {
+	return &SessionHandler{
+		sessions: sessions,
+	}
+}
+
+// GetSession returns the state an anonymous chat user needs to restore the chat
+// after a page reload: the current conversation, recent conversations and the
+// saved chat context. The session token is sent in the X-Session-Token header
+// or the session cookie.
+//
+// Returns:
+//   - 200: Session and its recent conversations
+//   - 404: No live session
+//   - 500: Internal server error
+func (h *SessionHandler) GetSession(c *fiber.Ctx) error {
+	id := middleware.SessionID(c)
+	if id == "" {
+		return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("no active session"))
+	}
+
+	session, err := h.sessions.Get(c.UserContext(), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("no active session"))
+		}
+		log.Printf("[ERROR] Get session %s: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to load session"))
+	}
+	conversations, err := h.sessions.Conversations(c.UserContext(), id)
+	if err != nil {
+		log.Printf("[ERROR] List conversations of session %s: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to load session"))
+	}
+
+	return c.JSON(models.CreateSuccessResponse(fiber.Map{
+		"session":       session,
+		"conversations": conversations,
+	}))
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/qrcode"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Sizes of QR code images in pixels
+const (
+	defaultQRCodeSize = 512
+	minQRCodeSize     = 64
+	maxQRCodeSize     = 2048
+)
+
+// ShortLinkHandler handles QR codes and short links of activities
+type ShortLinkHandler struct {
+	links      *services.ShortLinkService
+	staleAfter time.Duration
+}
+
+// NewShortLinkHandler creates a new short link handler
+func NewShortLinkHandler(links *services.ShortLinkService, staleAfter time.Duration) *ShortLinkHandler {
+	return &ShortLinkHandler{
+		links:      links,
+		staleAfter: staleAfter,
+	}
+}
+
+// GetShortLink returns the short link of an activity with its scan count
+//
+// Returns:
+//   - 200: Short link
+//   - 400: Invalid activity ID
+//   - 404: Activity not found
+//   - 500: Internal server error
+func (h *ShortLinkHandler) GetShortLink(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+	link, err := h.link(c, id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(models.CreateSuccessResponse(link))
+}
+
+// GetQRCode returns a PNG QR code of an activity's short link, for printing on
+// trailhead signs
+//
+// Query parameters: size (width in pixels, default 512, 64 to 2048)
+//
+// Codes are only rendered with a configured public URL: the request's host
+// header is not trusted for codes that are cached and printed.
+//
+// Returns:
+//   - 200: PNG image
+//   - 400: Invalid activity ID
+//   - 404: Activity not found
+//   - 500: Internal server error
+//   - 503: No public URL configured
+func (h *ShortLinkHandler) GetQRCode(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+	size := c.QueryInt("size", defaultQRCodeSize)
+	size = max(minQRCodeSize, min(size, maxQRCodeSize))
+
+	link, err := h.links.Link(c.UserContext(), id)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(link.URL, "/") {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(models.CreateErrorResponse("QR codes need PUBLIC_URL to be configured"))
+	}
+	image, err := qrcode.PNG(link.URL, size)
+	if err != nil {
+		log.Printf("[ERROR] Render QR code of activity %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to render QR code"))
+	}
+
+	c.Set(fiber.HeaderContentType, "image/png")
+	// Short links never change, so printed and cached codes stay valid
+	c.Set(fiber.HeaderCacheControl, "public, max-age=86400")
+	return c.Send(image)
+}
+
+// ResolveShortLink counts a scan of a short link, once per client IP and day,
+// and redirects to the activity's page on the frontend
+//
+// Returns:
+//   - 302: Redirect to the activity
+//   - 404: Unknown short link or activity no longer published
+//   - 500: Internal server error
+func (h *ShortLinkHandler) ResolveShortLink(c *fiber.Ctx) error {
+	url, err := h.links.Resolve(c.UserContext(), c.Params("code"), c.IP())
+	if err != nil {
+		return err
+	}
+	return c.Redirect(url, fiber.StatusFound)
+}
+
+// link returns the short link of an activity, made absolute with the request's
+// base URL when no public URL is configured: scanned links need a host
+func (h *ShortLinkHandler) link(c *fiber.Ctx, activityID uint) (*models.ShortLink, error) {
+	link, err := h.links.Link(c.UserContext(), activityID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(link.URL, "/") {
+		link.URL = c.BaseURL() + link.URL
+	}
+	return link, nil
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// StatsHandler handles public community statistics
+type StatsHandler struct {
+	stats *services.StatsService
+}
+
+// NewStatsHandler creates a new stats handler
+func NewStatsHandler(stats *services.StatsService) *StatsHandler {
+	return &StatsHandler{
+		stats: stats,
+	}
+}
+
+// GetStats returns counts of the community's approved activities, routes,
+// photos and contributors and the total route length, for landing pages.
+// Counts are refreshed periodically, so they may lag recent changes.
+//
+// Returns:
+//   - 200: Community statistics
+//   - 500: Internal server error
+func (h *StatsHandler) GetStats(c *fiber.Ctx) error {
+	stats, err := h.stats.Get(c.UserContext())
+	if err != nil {
+		log.Printf("[ERROR] Get stats: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to get statistics"))
+	}
+	c.Set(fiber.HeaderCacheControl, "public, max-age=60")
+	return c.JSON(models.CreateSuccessResponse(stats))
+}
+
+// GetAreaStats summarizes the approved activities and routes within an area
+// for community dashboards: activities per category, route length per route
+// type, routes per difficulty and per elevation gain range. Routes are placed
+// at their activity's location, and summaries are cached for a while.
+//
+// Query parameters: bbox (min_lng,min_lat,max_lng,max_lat, at most 450 km
+// high and wide), or lat, lng and radius_km (default 25, max 200)
+//
+// Returns:
+//   - 200: Area statistics
+//   - 400: Missing or invalid area, or area too large
+//   - 500: Internal server error
+func (h *StatsHandler) GetAreaStats(c *fiber.Ctx) error {
+	var area services.Area
+	if bbox := c.Query("bbox"); bbox != "" {
+		parts := strings.Split(bbox, ",")
+		bounds := make([]float64, len(parts))
+		for i, part := range parts {
+			value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return services.ErrInvalidArea
+			}
+			bounds[i] = value
+		}
+		if len(bounds) != 4 {
+			return services.ErrInvalidArea
+		}
+		area = services.Area{MinLng: bounds[0], MinLat: bounds[1], MaxLng: bounds[2], MaxLat: bounds[3]}
+	} else {
+		lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+		lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+		if errLat != nil || errLng != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("bbox, or lat and lng, must be valid coordinates"))
+		}
+		radius := c.QueryFloat("radius_km", 25)
+		if radius <= 0 || radius > 200 {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("radius_km must be between 0 and 200"))
+		}
+		area = services.AreaAround(lat, lng, radius)
+	}
+
+	stats, err := h.stats.Area(c.UserContext(), area)
+	if err != nil {
+		return err
+	}
+	c.Set(fiber.HeaderCacheControl, "public, max-age=60")
+	return c.JSON(models.CreateSuccessResponse(stats))
+}
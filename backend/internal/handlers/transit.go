@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+	"community-chatbot/internal/transit"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// TransitHandler handles public transit directions
+type TransitHandler struct {
+	transit *services.TransitService
+}
+
+// NewTransitHandler creates a new transit handler
+func NewTransitHandler(transit *services.TransitService) *TransitHandler {
+	return &TransitHandler{
+		transit: transit,
+	}
+}
+
+// GetTransit returns public transit itineraries to an activity
+//
+// Query parameters: from ("lat,lng", required), depart_at (RFC 3339, default now), limit (default 3, max 5)
+//
+// Returns:
+//   - 200: Itineraries with departure and arrival times; empty when there is no connection
+//   - 400: Invalid activity ID, origin or departure time
+//   - 404: Activity not found
+//   - 502: Transit planner failed
+//   - 503: Transit directions are not configured
+func (h *TransitHandler) GetTransit(c *fiber.Ctx) error {
+	id, ok := activityID(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid activity id"))
+	}
+
+	from, ok := parseLatLng(c.Query("from"))
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("from must be \"lat,lng\""))
+	}
+
+	departAt := time.Now()
+	if value := c.Query("depart_at"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("depart_at must be an RFC 3339 timestamp"))
+		}
+		departAt = parsed
+	}
+
+	limit := c.QueryInt("limit", 3)
+	if limit <= 0 || limit > 5 {
+		limit = 3
+	}
+
+	itineraries, err := h.transit.Directions(c.UserContext(), id, from, departAt, limit)
+	switch {
+	case errors.Is(err, transit.ErrNoRoute):
+		return c.JSON(models.CreateSuccessResponse([]transit.Itinerary{}))
+	case errors.Is(err, services.ErrTransitUnavailable):
+		return c.Status(fiber.StatusServiceUnavailable).JSON(models.CreateErrorResponse(err.Error()))
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("activity not found"))
+	case err != nil:
+		log.Printf("[ERROR] Transit directions to activity %d: %v", id, err)
+		return c.Status(fiber.StatusBadGateway).JSON(models.CreateErrorResponse("failed to plan transit trip"))
+	}
+
+	return c.JSON(models.CreateSuccessResponse(itineraries))
+}
+
+// parseLatLng parses a "lat,lng" pair
+func parseLatLng(value string) (models.Location, bool) {
+	latText, lngText, found := strings.Cut(value, ",")
+	if !found {
+		return models.Location{}, false
+	}
+	lat, errLat := strconv.ParseFloat(strings.TrimSpace(latText), 64)
+	lng, errLng := strconv.ParseFloat(strings.TrimSpace(lngText), 64)
+	if errLat != nil || errLng != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return models.Location{}, false
+	}
+	return models.Location{Lat: lat, Lng: lng}, true
+}
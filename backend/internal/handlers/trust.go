@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// TrustHandler handles admin overrides of contributor trust levels
+type TrustHandler struct {
+	trust *services.TrustService
+}
+
+// NewTrustHandler creates a new trust handler
+func NewTrustHandler(trust *services.TrustService) *TrustHandler {
+	return &TrustHandler{
+		trust: trust,
+	}
+}
+
+// trustRequest is the body of SetTrustLevel
+type trustRequest struct {
+	Level string `json:"level" validate:"required"`
+	// Pinned keeps the level from changing with contributions and violations
+	Pinned bool `json:"pinned"`
+}
+
+// SetTrustLevel sets the trust level of a user. Trusted users and moderators
+// publish without pre-moderation; moderator levels are always pinned (admin only).
+//
+// Request body: {"level": "trusted", "pinned": false}
+//
+// Returns:
+//   - 200: The updated user
+//   - 400: Invalid user ID or trust level
+//   - 404: User not found
+//   - 500: Internal server error
+func (h *TrustHandler) SetTrustLevel(c *fiber.Ctx) error {
+	id, err := c.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid user id"))
+	}
+	var body trustRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("invalid request body"))
+	}
+	if err := validate.Struct(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(validationMessage(err)))
+	}
+
+	user, err := h.trust.SetLevel(c.UserContext(), uint(id), body.Level, body.Pinned)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidTrustLevel) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(models.CreateErrorResponse("user not found"))
+		}
+		log.Printf("[ERROR] Set trust level of user %d: %v", id, err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to set trust level"))
+	}
+	return c.JSON(models.CreateSuccessResponse(user))
+}
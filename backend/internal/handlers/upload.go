@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+
+	"community-chatbot/internal/upload"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// readUpload reads an uploaded file, failing with 413 when it exceeds maxBytes
+func readUpload(fileHeader *multipart.FileHeader, maxBytes int64) ([]byte, error) {
+	if fileHeader.Size > maxBytes {
+		return nil, fiber.NewError(fiber.StatusRequestEntityTooLarge, "file is too large")
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "failed to read file")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "failed to read file")
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fiber.NewError(fiber.StatusRequestEntityTooLarge, "file is too large")
+	}
+	return data, nil
+}
+
+// checkUploadType returns the sniffed content type of an uploaded file, failing
+// with 415 when the policy does not allow it or the extension does not match
+func checkUploadType(policy upload.Policy, filename string, data []byte) (string, error) {
+	contentType, err := policy.Check(filename, data)
+	switch {
+	case errors.Is(err, upload.ErrTypeNotAllowed):
+		return "", fiber.NewError(fiber.StatusUnsupportedMediaType, fmt.Sprintf("file must be one of: %s", policy.Describe()))
+	case err != nil:
+		return "", fiber.NewError(fiber.StatusUnsupportedMediaType, err.Error())
+	}
+	return contentType, nil
+}
+
+// scanUpload scans an uploaded file when a scanner is configured. Scanner
+// failures reject the file, so nothing unscanned is stored.
+func scanUpload(c *fiber.Ctx, scanner upload.Scanner, filename string, data []byte) error {
+	if scanner == nil {
+		return nil
+	}
+	err := scanner.Scan(c.UserContext(), data)
+	switch {
+	case errors.Is(err, upload.ErrInfected):
+		log.Printf("[UPLOADS] Rejected %q from %s: %v", filename, c.IP(), err)
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "file failed the malware scan")
+	case err != nil:
+		log.Printf("[ERROR] Scanning upload %q: %v", filename, err)
+		return fiber.NewError(fiber.StatusServiceUnavailable, "file could not be scanned, try again later")
+	}
+	return nil
+}
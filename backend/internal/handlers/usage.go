@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// defaultUsageDays is the range of usage reports without from
+const defaultUsageDays = 30
+
+// UsageHandler handles token usage and cost reports
+type UsageHandler struct {
+	usage *services.UsageService
+}
+
+// NewUsageHandler creates a new usage handler
+func NewUsageHandler(usage *services.UsageService) *UsageHandler {
+	return &UsageHandler{
+		usage: usage,
+	}
+}
+
+// GetUsage reports the token usage and estimated cost of chat answers (admin only)
+//
+// Query parameters: from, to (YYYY-MM-DD, UTC, both inclusive; default the last
+// 30 days), group_by (day, user, conversation or model)
+//
+// Returns:
+//   - 200: Totals and, with group_by, the most expensive groups
+//   - 400: Invalid dates or grouping
+//   - 500: Internal server error
+func (h *UsageHandler) GetUsage(c *fiber.Ctx) error {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	to, err := usageDate(c.Query("to"), today)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("to must be formatted as YYYY-MM-DD"))
+	}
+	from, err := usageDate(c.Query("from"), to.AddDate(0, 0, 1-defaultUsageDays))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("from must be formatted as YYYY-MM-DD"))
+	}
+	if from.After(to) {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("from must not be after to"))
+	}
+
+	report, err := h.usage.Report(c.UserContext(), from, to.AddDate(0, 0, 1), c.Query("group_by"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidUsageGrouping) {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+		}
+		log.Printf("[ERROR] Usage report: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to report usage"))
+	}
+	return c.JSON(models.CreateSuccessResponse(report))
+}
+
+// usageDate parses a YYYY-MM-DD date, returning fallback when it is empty
+func usageDate(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
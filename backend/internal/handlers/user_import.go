@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// UserImportHandler handles bulk imports of users migrating from another platform
+type UserImportHandler struct {
+	imports *services.UserImportService
+}
+
+// NewUserImportHandler creates a new user import handler
+func NewUserImportHandler(imports *services.UserImportService) *UserImportHandler {
+	return &UserImportHandler{
+		imports: imports,
+	}
+}
+
+// ImportUsers imports users and their preferences, matched by email; unknown
+// emails get an account and an invitation to set a password (admin only)
+//
+// Request body: a JSON array of users, or CSV (Content-Type: text/csv) with a
+// header row of the same field names and preferred_activities separated by ";"
+//
+// Query parameters: dry_run (validate and match without importing)
+//
+// Returns:
+//   - 200: Import counts and the rows that were skipped
+//   - 400: Unreadable body or too many users
+//   - 500: Internal server error
+func (h *UserImportHandler) ImportUsers(c *fiber.Ctx) error {
+	var users []services.UserImport
+	if strings.HasPrefix(c.Get(fiber.HeaderContentType), "text/csv") {
+		var err error
+		if users, err = services.ParseUserImportCSV(bytes.NewReader(c.Body())); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(err.Error()))
+		}
+	} else if err := json.Unmarshal(c.Body(), &users); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("request body must be a JSON array of users or CSV"))
+	}
+	if len(users) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse("no users to import"))
+	}
+	if len(users) > services.MaxUserImportRows {
+		return c.Status(fiber.StatusBadRequest).JSON(models.CreateErrorResponse(
+			fmt.Sprintf("at most %d users can be imported at once", services.MaxUserImportRows)))
+	}
+
+	dryRun := c.QueryBool("dry_run")
+	result, err := h.imports.Import(c.UserContext(), users, dryRun)
+	if err != nil {
+		log.Printf("[ERROR] Import users: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to import users"))
+	}
+
+	if !dryRun {
+		log.Printf("[IMPORT] Users: %d matched, %d created, %d invited (%d failed), %d invalid",
+			result.Matched, result.Created, result.Invited, result.InviteFailed, result.Invalid)
+	}
+	return c.JSON(models.CreateSuccessResponse(result))
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+// validate checks struct `validate` tags on request payloads and models
+var validate = validator.New()
+
+// validationMessage turns validator errors into a client-facing message
+func validationMessage(err error) string {
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return err.Error()
+	}
+
+	messages := make([]string, 0, len(fieldErrors))
+	for _, fe := range fieldErrors {
+		if fe.Param() != "" {
+			messages = append(messages, fmt.Sprintf("%s failed %s=%s", fe.Field(), fe.Tag(), fe.Param()))
+		} else {
+			messages = append(messages, fmt.Sprintf("%s failed %s", fe.Field(), fe.Tag()))
+		}
+	}
+	return "validation failed: " + strings.Join(messages, ", ")
+}
+
+// Default and maximum page sizes for paginated endpoints
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// parsePagination reads page and page_size query parameters with sane bounds
+func parsePagination(c *fiber.Ctx) (page, pageSize int) {
+	page = c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+
+	pageSize = c.QueryInt("page_size", defaultPageSize)
+	if pageSize < 1 || pageSize > maxPageSize {
+		pageSize = defaultPageSize
+	}
+	return page, pageSize
+}
@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Message roles
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// Message is a single chat message sent to the model. Assistant messages may carry
+// the tool calls the model made; tool messages answer one call by ToolCallID.
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"-"`
+	ToolCallID string     `json:"-"`
+}
+
+// Tool is a function the model may call, described by a JSON Schema of its arguments
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage
+}
+
+// ToolCall is a function call requested by the model, with its arguments as JSON
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// CompletionRequest describes a chat completion
+type CompletionRequest struct {
+	Messages []Message
+	// Model overrides the provider's default model when set
+	Model string
+	// Tools the model may call instead of answering directly
+	Tools []Tool
+}
+
+// Usage reports token consumption of a completion
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Completion is the outcome of a streamed completion. When ToolCalls is not empty
+// the model expects their results before it continues the answer.
+type Completion struct {
+	Usage     *Usage
+	ToolCalls []ToolCall
+}
+
+// Provider is a language model service: OpenAI, Azure OpenAI or a local Ollama
+type Provider interface {
+	// StreamCompletion calls onDelta for every text fragment as it arrives
+	StreamCompletion(ctx context.Context, req CompletionRequest, onDelta func(delta string) error) (*Completion, error)
+	// Embed returns the embedding vector of each text, in order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Options are the settings shared by all providers
+type Options struct {
+	// Model is the default chat model; on Azure OpenAI it is the deployment name
+	Model string
+	// EmbeddingModel is the model Embed uses; on Azure OpenAI it is the deployment name
+	EmbeddingModel string
+	// BaseURL is the API address; on Azure OpenAI it is the resource endpoint
+	BaseURL string
+	// Temperature is the sampling temperature; nil leaves the model's default
+	Temperature *float64
+}
@@ -0,0 +1,220 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// OllamaClient streams completions from a local Ollama server through its
+// native chat API, so models run without sending conversations to a third party
+type OllamaClient struct {
+	options    Options
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewOllamaClient creates an Ollama client. The base URL defaults to a server
+// on this host.
+func NewOllamaClient(options Options) *OllamaClient {
+	if options.BaseURL == "" {
+		options.BaseURL = "http://localhost:11434"
+	}
+	return &OllamaClient{
+		options:    options,
+		baseURL:    strings.TrimRight(options.BaseURL, "/"),
+		httpClient: newStreamingHTTPClient(),
+	}
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Tools    []openAITool    `json:"tools,omitempty"`
+	Stream   bool            `json:"stream"`
+	Options  *ollamaOptions  `json:"options,omitempty"`
+}
+
+type ollamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	// ToolName names the function a tool message answers
+	ToolName string `json:"tool_name,omitempty"`
+}
+
+// ollamaToolCall is a function call; unlike OpenAI, arguments are a JSON object
+// and calls have no IDs
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaChunk struct {
+	Message struct {
+		Content   string           `json:"content"`
+		ToolCalls []ollamaToolCall `json:"tool_calls"`
+	} `json:"message"`
+	Done            bool   `json:"done"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+type ollamaEmbedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type ollamaEmbedResponse struct {
+	Embeddings [][]float32 `json:"embeddings"`
+}
+
+// toOllamaMessages converts messages to the API's wire format. Tool messages
+// name the function they answer, found through the call ID.
+func toOllamaMessages(messages []Message) []ollamaMessage {
+	names := map[string]string{}
+	converted := make([]ollamaMessage, len(messages))
+	for i, m := range messages {
+		converted[i] = ollamaMessage{Role: m.Role, Content: m.Content, ToolName: names[m.ToolCallID]}
+		for _, call := range m.ToolCalls {
+			names[call.ID] = call.Name
+			var wire ollamaToolCall
+			wire.Function.Name = call.Name
+			wire.Function.Arguments = json.RawMessage(call.Arguments)
+			if !json.Valid(wire.Function.Arguments) {
+				wire.Function.Arguments = json.RawMessage("{}")
+			}
+			converted[i].ToolCalls = append(converted[i].ToolCalls, wire)
+		}
+	}
+	return converted
+}
+
+// StreamCompletion sends the request with stream=true and forwards content
+// deltas. Ollama sends tool calls whole, so they are given IDs here.
+func (c *OllamaClient) StreamCompletion(ctx context.Context, req CompletionRequest, onDelta func(delta string) error) (*Completion, error) {
+	body := ollamaRequest{
+		Model:    c.options.Model,
+		Messages: toOllamaMessages(req.Messages),
+		Tools:    toOpenAITools(req.Tools),
+		Stream:   true,
+	}
+	if req.Model != "" {
+		body.Model = req.Model
+	}
+	if c.options.Temperature != nil {
+		body.Options = &ollamaOptions{Temperature: c.options.Temperature}
+	}
+
+	resp, err := c.post(ctx, "/api/chat", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return readOllamaStream(resp.Body, onDelta)
+}
+
+// readOllamaStream parses the newline-delimited JSON response of a streaming chat
+func readOllamaStream(body io.Reader, onDelta func(delta string) error) (*Completion, error) {
+	completion := &Completion{}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk ollamaChunk
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return completion, fmt.Errorf("invalid stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return completion, fmt.Errorf("ollama failed: %s", chunk.Error)
+		}
+
+		for _, call := range chunk.Message.ToolCalls {
+			completion.ToolCalls = append(completion.ToolCalls, ToolCall{
+				ID:        fmt.Sprintf("call_%d", len(completion.ToolCalls)),
+				Name:      call.Function.Name,
+				Arguments: string(call.Function.Arguments),
+			})
+		}
+		if chunk.Message.Content != "" {
+			if err := onDelta(chunk.Message.Content); err != nil {
+				return completion, err
+			}
+		}
+
+		if chunk.Done {
+			completion.Usage = &Usage{
+				PromptTokens:     chunk.PromptEvalCount,
+				CompletionTokens: chunk.EvalCount,
+				TotalTokens:      chunk.PromptEvalCount + chunk.EvalCount,
+			}
+			return completion, nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return completion, fmt.Errorf("stream read failed: %w", err)
+	}
+	return completion, io.ErrUnexpectedEOF
+}
+
+// Embed returns the embeddings of texts from the embed API
+func (c *OllamaClient) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	resp, err := c.post(ctx, "/api/embed", ollamaEmbedRequest{Model: c.options.EmbeddingModel, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result ollamaEmbedResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid ollama embed response: %w", err)
+	}
+	if len(result.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("invalid ollama embed response: %d embeddings for %d texts", len(result.Embeddings), len(texts))
+	}
+	return result.Embeddings, nil
+}
+
+// post sends a JSON request to an API path and returns the response when it succeeded
+func (c *OllamaClient) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode ollama request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("ollama request failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, statusError("ollama", resp)
+	}
+	return resp, nil
+}
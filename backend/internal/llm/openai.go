@@ -0,0 +1,319 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/apperr"
+)
+
+// openAIAPI sends requests in the OpenAI wire format, which Azure OpenAI shares;
+// the providers differ in their URLs and how they authenticate
+type openAIAPI struct {
+	name       string
+	options    Options
+	httpClient *http.Client
+	// endpoint returns the URL of an operation (chat/completions, embeddings) of a model
+	endpoint func(operation, model string) string
+	// authorize adds the credentials to a request
+	authorize func(req *http.Request)
+}
+
+// OpenAIClient streams completions from the OpenAI chat completions API, or
+// any API compatible with it at options.BaseURL
+type OpenAIClient struct {
+	openAIAPI
+}
+
+// NewOpenAIClient creates an OpenAI client. The base URL defaults to the public API.
+func NewOpenAIClient(apiKey string, options Options) *OpenAIClient {
+	if options.BaseURL == "" {
+		options.BaseURL = "https://api.openai.com/v1"
+	}
+	baseURL := strings.TrimRight(options.BaseURL, "/")
+	return &OpenAIClient{openAIAPI{
+		name:       "openai",
+		options:    options,
+		httpClient: newStreamingHTTPClient(),
+		endpoint: func(operation, _ string) string {
+			return baseURL + "/" + operation
+		},
+		authorize: func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+		},
+	}}
+}
+
+// AzureOpenAIClient streams completions from models deployed to an Azure
+// OpenAI resource, addressing them by deployment name
+type AzureOpenAIClient struct {
+	openAIAPI
+}
+
+// NewAzureOpenAIClient creates an Azure OpenAI client for the resource endpoint
+// in options.BaseURL, e.g. https://my-resource.openai.azure.com
+func NewAzureOpenAIClient(apiKey, apiVersion string, options Options) *AzureOpenAIClient {
+	endpoint := strings.TrimRight(options.BaseURL, "/")
+	return &AzureOpenAIClient{openAIAPI{
+		name:       "azure openai",
+		options:    options,
+		httpClient: newStreamingHTTPClient(),
+		endpoint: func(operation, deployment string) string {
+			return fmt.Sprintf("%s/openai/deployments/%s/%s?api-version=%s",
+				endpoint, url.PathEscape(deployment), operation, url.QueryEscape(apiVersion))
+		},
+		authorize: func(req *http.Request) {
+			req.Header.Set("api-key", apiKey)
+		},
+	}}
+}
+
+// newStreamingHTTPClient creates an HTTP client for model APIs. It has no
+// overall timeout: streams can legitimately run long and are bounded by ctx.
+func newStreamingHTTPClient() *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: 60 * time.Second,
+	}}
+}
+
+type openAIRequest struct {
+	Model         string          `json:"model"`
+	Messages      []openAIMessage `json:"messages"`
+	Tools         []openAITool    `json:"tools,omitempty"`
+	Temperature   *float64        `json:"temperature,omitempty"`
+	Stream        bool            `json:"stream"`
+	StreamOptions struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options"`
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAITool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Parameters  json.RawMessage `json:"parameters"`
+	} `json:"function"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// openAIToolCallDelta is a streamed fragment of the tool call at Index
+type openAIToolCallDelta struct {
+	Index int `json:"index"`
+	openAIToolCall
+}
+
+type openAIChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string                `json:"content"`
+			ToolCalls []openAIToolCallDelta `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+}
+
+type openAIEmbeddingRequest struct {
+	Model string   `json:"model,omitempty"`
+	Input []string `json:"input"`
+}
+
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// toOpenAIMessages converts messages to the API's wire format
+func toOpenAIMessages(messages []Message) []openAIMessage {
+	converted := make([]openAIMessage, len(messages))
+	for i, m := range messages {
+		converted[i] = openAIMessage{Role: m.Role, Content: m.Content, ToolCallID: m.ToolCallID}
+		for _, call := range m.ToolCalls {
+			wire := openAIToolCall{ID: call.ID, Type: "function"}
+			wire.Function.Name = call.Name
+			wire.Function.Arguments = call.Arguments
+			converted[i].ToolCalls = append(converted[i].ToolCalls, wire)
+		}
+	}
+	return converted
+}
+
+// toOpenAITools converts tool definitions to the API's wire format
+func toOpenAITools(tools []Tool) []openAITool {
+	converted := make([]openAITool, len(tools))
+	for i, tool := range tools {
+		converted[i].Type = "function"
+		converted[i].Function.Name = tool.Name
+		converted[i].Function.Description = tool.Description
+		converted[i].Function.Parameters = tool.Parameters
+	}
+	return converted
+}
+
+// StreamCompletion sends the request with stream=true, forwards content deltas and
+// assembles tool calls from their streamed fragments
+func (c *openAIAPI) StreamCompletion(ctx context.Context, req CompletionRequest, onDelta func(delta string) error) (*Completion, error) {
+	body := openAIRequest{
+		Model:       c.options.Model,
+		Messages:    toOpenAIMessages(req.Messages),
+		Tools:       toOpenAITools(req.Tools),
+		Temperature: c.options.Temperature,
+		Stream:      true,
+	}
+	if req.Model != "" {
+		body.Model = req.Model
+	}
+	body.StreamOptions.IncludeUsage = true
+
+	resp, err := c.post(ctx, c.endpoint("chat/completions", body.Model), body, "text/event-stream")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return readOpenAIStream(resp.Body, onDelta)
+}
+
+// Embed returns the embeddings of texts from the embeddings API
+func (c *openAIAPI) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	resp, err := c.post(ctx, c.endpoint("embeddings", c.options.EmbeddingModel),
+		openAIEmbeddingRequest{Model: c.options.EmbeddingModel, Input: texts}, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result openAIEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid %s embeddings response: %w", c.name, err)
+	}
+	embeddings := make([][]float32, len(texts))
+	for _, item := range result.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, fmt.Errorf("invalid %s embeddings response: index %d out of range", c.name, item.Index)
+		}
+		embeddings[item.Index] = item.Embedding
+	}
+	return embeddings, nil
+}
+
+// post sends a JSON request and returns the response when it succeeded. Rate
+// limits and timeouts of the provider map to the matching error kinds.
+func (c *openAIAPI) post(ctx context.Context, address string, body interface{}, accept string) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s request: %w", c.name, err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, address, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", accept)
+	c.authorize(httpReq)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("%s request failed: %w", c.name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, statusError(c.name, resp)
+	}
+	return resp, nil
+}
+
+// statusError describes a failed response of a provider
+func statusError(name string, resp *http.Response) error {
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	err := fmt.Errorf("%s returned %d: %s", name, resp.StatusCode, strings.TrimSpace(string(detail)))
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return apperr.Wrap(apperr.RateLimited, "the model is rate limited", err)
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return apperr.Wrap(apperr.UpstreamTimeout, "the model timed out", err)
+	}
+	return err
+}
+
+// readOpenAIStream parses the SSE response body of a streaming completion
+func readOpenAIStream(body io.Reader, onDelta func(delta string) error) (*Completion, error) {
+	completion := &Completion{}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			return completion, nil
+		}
+
+		var chunk openAIChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return completion, fmt.Errorf("invalid stream chunk: %w", err)
+		}
+		if chunk.Usage != nil {
+			completion.Usage = chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			// Tool calls arrive as fragments keyed by index: the first carries the ID
+			// and name, later ones append to the arguments
+			for _, fragment := range choice.Delta.ToolCalls {
+				for len(completion.ToolCalls) <= fragment.Index {
+					completion.ToolCalls = append(completion.ToolCalls, ToolCall{})
+				}
+				call := &completion.ToolCalls[fragment.Index]
+				if fragment.ID != "" {
+					call.ID = fragment.ID
+				}
+				call.Name += fragment.Function.Name
+				call.Arguments += fragment.Function.Arguments
+			}
+
+			if choice.Delta.Content == "" {
+				continue
+			}
+			if err := onDelta(choice.Delta.Content); err != nil {
+				return completion, err
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return completion, fmt.Errorf("stream read failed: %w", err)
+	}
+	return completion, io.ErrUnexpectedEOF
+}
@@ -0,0 +1,47 @@
+package lock
+
+import (
+	"context"
+	"sync"
+)
+
+// LocalLocker is an in-process locker for single-replica deployments and development
+type LocalLocker struct {
+	held  map[string]bool
+	mutex sync.Mutex
+}
+
+// NewLocalLocker creates an in-process locker
+func NewLocalLocker() *LocalLocker {
+	return &LocalLocker{held: make(map[string]bool)}
+}
+
+// TryAcquire acquires name if no other goroutine holds it
+func (l *LocalLocker) TryAcquire(_ context.Context, name string) (Lease, bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.held[name] {
+		return nil, false, nil
+	}
+	l.held[name] = true
+	return &localLease{locker: l, name: name, lost: make(chan struct{})}, true, nil
+}
+
+type localLease struct {
+	locker *LocalLocker
+	name   string
+	lost   chan struct{}
+	once   sync.Once
+}
+
+func (l *localLease) Lost() <-chan struct{} { return l.lost }
+
+func (l *localLease) Release(_ context.Context) error {
+	l.once.Do(func() {
+		l.locker.mutex.Lock()
+		delete(l.locker.held, l.name)
+		l.locker.mutex.Unlock()
+	})
+	return nil
+}
@@ -0,0 +1,107 @@
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PostgresLocker uses session-level advisory locks. Each lease pins a dedicated
+// connection because advisory locks belong to the session that took them.
+type PostgresLocker struct {
+	db            *gorm.DB
+	checkInterval time.Duration
+}
+
+// NewPostgresLocker creates an advisory-lock based locker. The connection holding a
+// lease is health-checked every ttl/3 so a dropped session is reported as a lost lease.
+func NewPostgresLocker(db *gorm.DB, ttl time.Duration) *PostgresLocker {
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	return &PostgresLocker{db: db, checkInterval: ttl / 3}
+}
+
+// TryAcquire takes pg_try_advisory_lock on a key derived from name
+func (l *PostgresLocker) TryAcquire(ctx context.Context, name string) (Lease, bool, error) {
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return nil, false, err
+	}
+
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to reserve connection: %w", err)
+	}
+
+	key := advisoryKey(name)
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	lease := &postgresLease{conn: conn, key: key, lost: make(chan struct{}), done: make(chan struct{})}
+	go lease.monitor(l.checkInterval)
+	return lease, true, nil
+}
+
+// advisoryKey hashes a lock name into the bigint key space of advisory locks
+func advisoryKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+type postgresLease struct {
+	conn     *sql.Conn
+	key      int64
+	lost     chan struct{}
+	done     chan struct{}
+	lostOnce sync.Once
+	doneOnce sync.Once
+}
+
+func (l *postgresLease) Lost() <-chan struct{} { return l.lost }
+
+// monitor pings the session holding the lock; if it dies, the lock is gone too
+func (l *postgresLease) monitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err := l.conn.PingContext(ctx)
+			cancel()
+			if err != nil {
+				l.lostOnce.Do(func() { close(l.lost) })
+				return
+			}
+		}
+	}
+}
+
+func (l *postgresLease) Release(ctx context.Context) error {
+	var err error
+	l.doneOnce.Do(func() {
+		close(l.done)
+		_, err = l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+		if closeErr := l.conn.Close(); err == nil {
+			err = closeErr
+		}
+	})
+	return err
+}
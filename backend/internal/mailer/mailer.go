@@ -0,0 +1,32 @@
+// Package mailer renders and delivers emails. Providers deliver messages, over
+// SMTP or to the log in development; a Queue sends them in the background so
+// requests do not wait for mail servers.
+package mailer
+
+import (
+	"context"
+	"log"
+)
+
+// Message is an email with a plain text and an HTML body
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Provider delivers emails
+type Provider interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// LogProvider logs emails instead of delivering them; used in development and
+// until a mail server is configured. Logged links are credentials until they expire.
+type LogProvider struct{}
+
+// Send logs the recipient, subject and text body
+func (LogProvider) Send(_ context.Context, message Message) error {
+	log.Printf("[MAIL] To <%s>: %s\n%s", message.To, message.Subject, message.Text)
+	return nil
+}
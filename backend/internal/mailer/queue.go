@@ -0,0 +1,110 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrQueueFull is returned when more emails are waiting than the queue holds
+var ErrQueueFull = errors.New("mail queue is full")
+
+// Failed deliveries are queued again after a doubling backoff before the email
+// is dropped
+const (
+	maxAttempts  = 4
+	retryBackoff = 30 * time.Second
+)
+
+// Queue delivers emails in the background. Emails are held in memory, so those
+// still queued at shutdown are not sent.
+type Queue struct {
+	provider   Provider
+	deliveries chan delivery
+	// retrying counts failed emails waiting out their backoff
+	retrying atomic.Int64
+}
+
+// delivery is a queued email and the number of times it was already attempted
+type delivery struct {
+	message  Message
+	attempts int
+}
+
+// NewQueue creates a queue holding up to size emails
+func NewQueue(provider Provider, size int) *Queue {
+	return &Queue{
+		provider:   provider,
+		deliveries: make(chan delivery, size),
+	}
+}
+
+// Enqueue queues an email for delivery without waiting for it
+func (q *Queue) Enqueue(message Message) error {
+	select {
+	case q.deliveries <- delivery{message: message}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Start delivers queued emails with the given number of workers until the
+// context is cancelled
+func (q *Queue) Start(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for i := 0; i < max(workers, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case d := <-q.deliveries:
+					q.deliver(ctx, d)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if pending := len(q.deliveries) + int(q.retrying.Load()); pending > 0 {
+		log.Printf("[MAIL] Stopped with %d emails not sent", pending)
+	}
+}
+
+// deliver sends an email once. A failure queues it again after a backoff, so
+// the worker is free for other emails in the meantime.
+func (q *Queue) deliver(ctx context.Context, d delivery) {
+	sendCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	err := q.provider.Send(sendCtx, d.message)
+	cancel()
+	if err == nil {
+		return
+	}
+	d.attempts++
+	message := d.message
+	if d.attempts == maxAttempts {
+		log.Printf("[MAIL] Giving up on %q to <%s> after %d attempts: %v", message.Subject, message.To, d.attempts, err)
+		return
+	}
+	backoff := retryBackoff << (d.attempts - 1)
+	log.Printf("[MAIL] Sending %q to <%s> failed, retrying in %s: %v", message.Subject, message.To, backoff, err)
+
+	q.retrying.Add(1)
+	time.AfterFunc(backoff, func() {
+		defer q.retrying.Add(-1)
+		if ctx.Err() != nil {
+			return
+		}
+		select {
+		case q.deliveries <- d:
+		default:
+			log.Printf("[MAIL] Giving up on %q to <%s>: %v", message.Subject, message.To, ErrQueueFull)
+		}
+	})
+}
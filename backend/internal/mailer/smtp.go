@@ -0,0 +1,153 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+// smtpTimeout bounds a delivery when the context has no deadline
+const smtpTimeout = 30 * time.Second
+
+// SMTPConfig contains the mail server to deliver through
+type SMTPConfig struct {
+	Host string
+	// Port 465 uses implicit TLS; other ports upgrade with STARTTLS when offered
+	Port     int
+	Username string
+	Password string
+	// From is the sender, e.g. "Community <no-reply@example.org>"
+	From string
+}
+
+// SMTPProvider delivers emails through a mail server
+type SMTPProvider struct {
+	config SMTPConfig
+	from   *mail.Address
+}
+
+// NewSMTPProvider creates a new SMTP provider
+func NewSMTPProvider(config SMTPConfig) (*SMTPProvider, error) {
+	from, err := mail.ParseAddress(config.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address %q: %w", config.From, err)
+	}
+	return &SMTPProvider{
+		config: config,
+		from:   from,
+	}, nil
+}
+
+// Send delivers a message. Credentials are only sent over TLS, except to a
+// server on localhost.
+func (p *SMTPProvider) Send(ctx context.Context, message Message) error {
+	body, err := p.compose(message)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(p.config.Host, strconv.Itoa(p.config.Port))
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	if p.config.Port == 465 {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: p.config.Host}}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, p.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to greet %s: %w", addr, err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && p.config.Port != 465 {
+		if err := client.StartTLS(&tls.Config{ServerName: p.config.Host}); err != nil {
+			return fmt.Errorf("failed to start TLS with %s: %w", addr, err)
+		}
+	}
+	if p.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", p.config.Username, p.config.Password, p.config.Host)); err != nil {
+			return fmt.Errorf("failed to authenticate with %s: %w", addr, err)
+		}
+	}
+	if err := client.Mail(p.from.Address); err != nil {
+		return fmt.Errorf("sender rejected: %w", err)
+	}
+	if err := client.Rcpt(message.To); err != nil {
+		return fmt.Errorf("recipient rejected: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start message: %w", err)
+	}
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("message rejected: %w", err)
+	}
+	return client.Quit()
+}
+
+// compose builds a multipart/alternative message with the text and HTML bodies
+func (p *SMTPProvider) compose(message Message) ([]byte, error) {
+	var parts bytes.Buffer
+	writer := multipart.NewWriter(&parts)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", message.Text},
+		{"text/html; charset=utf-8", message.HTML},
+	} {
+		if part.content == "" {
+			continue
+		}
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		encoder := quotedprintable.NewWriter(w)
+		if _, err := encoder.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", p.from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", (&mail.Address{Address: message.To}).String())
+	// Subjects can contain user content such as activity names; encoding them
+	// also keeps line breaks from injecting headers
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+	msg.Write(parts.Bytes())
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,88 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Templates of emails. Each defines a subject, a text body and the content of
+// the HTML body, which is wrapped in the layout.
+const (
+	TemplateVerifyEmail      = "verify_email"
+	TemplatePasswordReset    = "password_reset"
+	TemplateEmailChange      = "email_change"
+	TemplateEmailNotice      = "email_change_notice"
+	TemplateInvitation       = "invitation"
+	TemplateActivityApproved = "activity_approved"
+	TemplateActivityRejected = "activity_rejected"
+)
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+// emailTemplate is a template parsed for the text parts and the HTML body
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// templates are parsed once; a broken template fails at startup
+var templates = parseTemplates(TemplateVerifyEmail, TemplatePasswordReset, TemplateEmailChange,
+	TemplateEmailNotice, TemplateInvitation, TemplateActivityApproved, TemplateActivityRejected)
+
+// Data is what templates are rendered with; each template uses some of the fields
+type Data struct {
+	// Name addresses the recipient
+	Name string
+	// URL is the link the email is about
+	URL       string
+	ExpiresAt time.Time
+	// Email is the new address of an email change
+	Email    string
+	Activity string
+	// Reason explains a rejection
+	Reason string
+}
+
+// parseTemplates parses the named templates with the layout
+func parseTemplates(names ...string) map[string]emailTemplate {
+	parsed := make(map[string]emailTemplate, len(names))
+	for _, name := range names {
+		file := "templates/" + name + ".tmpl"
+		parsed[name] = emailTemplate{
+			text: texttemplate.Must(texttemplate.ParseFS(templateFiles, file)),
+			html: htmltemplate.Must(htmltemplate.ParseFS(templateFiles, "templates/layout.tmpl", file)),
+		}
+	}
+	return parsed
+}
+
+// Render renders the named template into an email to an address
+func Render(name, to string, data Data) (Message, error) {
+	tmpl, ok := templates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown email template %q", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := tmpl.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render subject of %s: %w", name, err)
+	}
+	if err := tmpl.text.ExecuteTemplate(&text, "text", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render text of %s: %w", name, err)
+	}
+	if err := tmpl.html.ExecuteTemplate(&html, "html", data); err != nil {
+		return Message{}, fmt.Errorf("failed to render HTML of %s: %w", name, err)
+	}
+	return Message{
+		To:      to,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()) + "\n",
+		HTML:    html.String(),
+	}, nil
+}
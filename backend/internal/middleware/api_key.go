@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/cache"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/ratelimit"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// apiKeyIDKey is the fiber.Ctx locals key holding the ID of the API key a request
+// authenticated with
+const apiKeyIDKey = "apiKeyID"
+
+// Clients guessing API keys are blocked for apiKeyFailureWindow after
+// apiKeyMaxFailures unknown keys within that window
+const (
+	apiKeyMaxFailures   = 10
+	apiKeyFailureWindow = 15 * time.Minute
+)
+
+// APIKeyLookup returns the active API key for a secret key, or nil when it is unknown or revoked
+type APIKeyLookup func(ctx context.Context, apiKey string) (*models.APIKey, error)
+
+// APIKeyAuth returns a middleware authenticating scripts by a personal API key in
+// the X-API-Key header as the key's user, so RequireAuth lets them through. Keys
+// are also accepted as bearer tokens, which is how OpenAI clients send them. It
+// must run before RateLimit, which gives each key its own bucket. Embed keys,
+// sent in the same header, and requests without a key pass through unchanged.
+// Unknown keys are counted per IP in failures, and IPs with too many are
+// refused with 429 before their keys are looked up; a nil counter or block
+// store disables this.
+func APIKeyAuth(lookup APIKeyLookup, failures ratelimit.Counter, blocks cache.Store) fiber.Handler {
+	limited := failures != nil && blocks != nil
+	return func(c *fiber.Ctx) error {
+		apiKey := c.Get("X-API-Key")
+		if bearer, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "); ok && apiKey == "" {
+			apiKey = bearer
+		}
+		if !strings.HasPrefix(apiKey, models.APIKeyPrefix) {
+			return c.Next()
+		}
+
+		blockKey := "apikey:block:" + c.IP()
+		if limited {
+			if blockedUntil, ok := apiKeyBlockedUntil(c.UserContext(), blocks, blockKey); ok {
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(time.Until(blockedUntil))))
+				return c.Status(fiber.StatusTooManyRequests).JSON(models.CreateErrorResponse("too many invalid API keys"))
+			}
+		}
+
+		key, err := lookup(c.UserContext(), apiKey)
+		if err != nil {
+			log.Printf("[ERROR] API key authentication: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("internal server error"))
+		}
+		if key == nil {
+			if limited {
+				recordAPIKeyFailure(c.UserContext(), failures, blocks, c.IP(), blockKey)
+			}
+			return c.Status(fiber.StatusUnauthorized).JSON(models.CreateErrorResponse("invalid API key"))
+		}
+		c.Locals(userIDKey, key.UserID)
+		c.Locals(apiKeyIDKey, key.ID)
+		return c.Next()
+	}
+}
+
+// recordAPIKeyFailure counts an unknown key sent from ip and blocks the IP once
+// it sent too many. Store failures are logged and never block.
+func recordAPIKeyFailure(ctx context.Context, failures ratelimit.Counter, blocks cache.Store, ip, blockKey string) {
+	now := time.Now().UTC()
+	window := now.Truncate(apiKeyFailureWindow)
+	count, err := failures.Increment(ctx, fmt.Sprintf("apikey:failures:%s:%d", ip, window.Unix()), apiKeyFailureWindow)
+	if err != nil {
+		log.Printf("[ERROR] Count invalid API key of %s: %v", ip, err)
+		return
+	}
+	if count < apiKeyMaxFailures {
+		return
+	}
+	blockedUntil, err := now.Add(apiKeyFailureWindow).MarshalText()
+	if err == nil {
+		err = blocks.Set(ctx, blockKey, blockedUntil, apiKeyFailureWindow)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Block %s after invalid API keys: %v", ip, err)
+		return
+	}
+	log.Printf("[AUTH] Blocked %s for %s after %d invalid API keys", ip, apiKeyFailureWindow, count)
+}
+
+// apiKeyBlockedUntil returns when the block of an IP ends, or ok=false when it
+// is not blocked or the store fails
+func apiKeyBlockedUntil(ctx context.Context, blocks cache.Store, blockKey string) (time.Time, bool) {
+	value, ok, err := blocks.Get(ctx, blockKey)
+	if err != nil {
+		log.Printf("[ERROR] API key block lookup: %v", err)
+		return time.Time{}, false
+	}
+	var blockedUntil time.Time
+	if !ok || blockedUntil.UnmarshalText(value) != nil || !blockedUntil.After(time.Now()) {
+		return time.Time{}, false
+	}
+	return blockedUntil, true
+}
+
+// APIKeyID returns the ID of the API key set by APIKeyAuth
+func APIKeyID(c *fiber.Ctx) (uint, bool) {
+	id, ok := c.Locals(apiKeyIDKey).(uint)
+	return id, ok && id != 0
+}
+
+// DenyAPIKeys returns a middleware rejecting requests authenticated with an API
+// key, for account changes that need a signed-in user: a leaked key must not
+// be enough to take over or delete an account
+func DenyAPIKeys() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := APIKeyID(c); ok {
+			return c.Status(fiber.StatusForbidden).JSON(models.CreateErrorResponse("not allowed with an API key"))
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"community-chatbot/internal/auth"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/ratelimit"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// EmbedKeyLookup returns an active embed key, or nil when it does not exist or was revoked
+type EmbedKeyLookup func(ctx context.Context, id uint) (*models.EmbedKey, error)
+
+// EmbedAuth returns a middleware for endpoints the chat widget may call. Requests
+// with an embed token must come from the origin the token was issued for and
+// share the embed key's rate limit across all visitors of the site; limits is
+// nil when rate limiting is disabled. Invalid and expired embed tokens are
+// rejected rather than treated as anonymous; requests with another bearer
+// token or none pass through unchanged.
+func EmbedAuth(tokens *auth.TokenIssuer, lookup EmbedKeyLookup, limits ratelimit.Store) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || token == "" || auth.ClaimedScope(token) != auth.ScopeEmbed {
+			return c.Next()
+		}
+		claims, err := tokens.Verify(c.UserContext(), token, auth.ScopeEmbed)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(models.CreateErrorResponse("invalid embed token"))
+		}
+
+		if c.Get(fiber.HeaderOrigin) != claims.Origin {
+			return c.Status(fiber.StatusForbidden).JSON(models.CreateErrorResponse("embed token is not valid for this origin"))
+		}
+		id, err := claims.EmbedKeyID()
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(models.CreateErrorResponse("invalid embed token"))
+		}
+		key, err := lookup(c.UserContext(), id)
+		if err != nil {
+			log.Printf("[ERROR] Embed key %d: %v", id, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("internal server error"))
+		}
+		if key == nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(models.CreateErrorResponse("embed key was revoked"))
+		}
+
+		if limits != nil && !take(c, limits, fmt.Sprintf("embed:%d", id), ratelimit.Limit{Rate: key.Rate, Burst: key.Burst}) {
+			return c.Status(fiber.StatusTooManyRequests).JSON(models.CreateErrorResponse("rate limit exceeded for this site, please try again later"))
+		}
+		return c.Next()
+	}
+}
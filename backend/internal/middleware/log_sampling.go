@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"community-chatbot/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// logSampledKey is the fiber.Ctx locals key recording whether RequestLogging
+// sampled the request
+const logSampledKey = "log_sampled"
+
+// LogSampler decides which requests are logged, so high-traffic endpoints such
+// as the chat stream do not drown the logs. Its policy can be replaced while
+// the server runs. A nil sampler logs every request.
+type LogSampler struct {
+	mutex  sync.RWMutex
+	policy models.LogSamplingPolicy
+}
+
+// NewLogSampler creates a log sampler with an initial policy
+func NewLogSampler(policy models.LogSamplingPolicy) *LogSampler {
+	return &LogSampler{policy: policy}
+}
+
+// Policy returns the current policy
+func (s *LogSampler) Policy() models.LogSamplingPolicy {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.policy
+}
+
+// SetPolicy replaces the policy for the following requests
+func (s *LogSampler) SetPolicy(policy models.LogSamplingPolicy) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.policy = policy
+}
+
+// sample reports whether a request to path is logged in full
+func (s *LogSampler) sample(path string) bool {
+	if s == nil {
+		return true
+	}
+	rate := s.rate(path)
+	return rate >= 1 || (rate > 0 && rand.Float64() < rate)
+}
+
+// rate returns the sample rate of the longest rule matching path, or the
+// default rate
+func (s *LogSampler) rate(path string) float64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	rate, matched := s.policy.DefaultRate, -1
+	for pattern, patternRate := range s.policy.Rules {
+		prefix, wildcard := strings.CutSuffix(pattern, "*")
+		if (pattern == path || (wildcard && strings.HasPrefix(path, prefix))) && len(pattern) > matched {
+			rate, matched = patternRate, len(pattern)
+		}
+	}
+	return rate
+}
+
+// slow reports whether a request took long enough to be logged regardless of sampling
+func (s *LogSampler) slow(duration time.Duration) bool {
+	if s == nil {
+		return false
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.policy.SlowThresholdMS > 0 && duration >= time.Duration(s.policy.SlowThresholdMS)*time.Millisecond
+}
+
+// LogSampled reports whether RequestLogging sampled the request, for other
+// logging middleware to follow its decision. Requests are sampled without it.
+func LogSampled(c *fiber.Ctx) bool {
+	sampled, ok := c.Locals(logSampledKey).(bool)
+	return sampled || !ok
+}
@@ -13,6 +13,9 @@ type RequestLoggingConfig struct {
 	LogLevel string
 	// SkipPaths are paths to skip logging (useful for health checks)
 	SkipPaths []string
+	// Sampler logs a share of the requests; failed and slow requests are
+	// always logged. Nil logs every request.
+	Sampler *LogSampler
 }
 
 // DefaultRequestLoggingConfig provides default configuration
@@ -54,9 +57,18 @@ func RequestLogging(config ...RequestLoggingConfig) fiber.Handler {
 		cacheControl := c.Get("Cache-Control", "")
 		accept := c.Get("Accept", "")
 
+		requestID := GetRequestID(c)
+
 		// Log request start with detailed client information
-		log.Printf("[REQUEST_START] %s %s | Client: %s | X-Forwarded-For: %s | User-Agent: %s | Origin: %s | Referer: %s | Connection: %s | Cache-Control: %s | Accept: %s",
-			method, path, clientIP, xForwardedFor, userAgent, origin, referer, connectionHeader, cacheControl, accept)
+		logStart := func() {
+			log.Printf("[REQUEST_START] %s %s | Request: %s | Client: %s | X-Forwarded-For: %s | User-Agent: %s | Origin: %s | Referer: %s | Connection: %s | Cache-Control: %s | Accept: %s",
+				method, path, requestID, clientIP, xForwardedFor, userAgent, origin, referer, connectionHeader, cacheControl, accept)
+		}
+		sampled := cfg.Sampler.sample(path)
+		c.Locals(logSampledKey, sampled)
+		if sampled {
+			logStart()
+		}
 
 		// Process the request
 		err := c.Next()
@@ -64,16 +76,29 @@ func RequestLogging(config ...RequestLoggingConfig) fiber.Handler {
 		// Calculate processing time
 		duration := time.Since(start)
 		status := c.Response().StatusCode()
-		responseSize := len(c.Response().Body())
+		// Reading a streamed body would block until the stream ends and keep it
+		// from reaching the client; its size is logged as -1
+		responseSize := -1
+		if !c.Response().IsBodyStream() {
+			responseSize = len(c.Response().Body())
+		}
+
+		// Failed and slow requests are logged even when not sampled, start included
+		if !sampled {
+			if err == nil && status < fiber.StatusBadRequest && !cfg.Sampler.slow(duration) {
+				return nil
+			}
+			logStart()
+		}
 
 		// Log request completion with performance metrics
-		log.Printf("[REQUEST_END] %s %s | Client: %s | Status: %d | Duration: %v | Response Size: %d bytes | Error: %v",
-			method, path, clientIP, status, duration, responseSize, err)
+		log.Printf("[REQUEST_END] %s %s | Request: %s | Client: %s | Status: %d | Duration: %v | Response Size: %d bytes | Error: %v",
+			method, path, requestID, clientIP, status, duration, responseSize, err)
 
 		// For specific problematic endpoints, log additional details
 		if path == "/api/chat/stream" || path == "/chat/stream" {
-			log.Printf("[CHAT_STREAM] Client: %s | Query: %s | Headers: Connection=%s, Cache-Control=%s",
-				clientIP, c.Request().URI().QueryString(), connectionHeader, cacheControl)
+			log.Printf("[CHAT_STREAM] Request: %s | Client: %s | Query: %s | Headers: Connection=%s, Cache-Control=%s",
+				requestID, clientIP, c.Request().URI().QueryString(), connectionHeader, cacheControl)
 		}
 
 		return err
@@ -81,23 +106,24 @@ func RequestLogging(config ...RequestLoggingConfig) fiber.Handler {
 }
 
 // EventSourceLogging specifically logs EventSource/SSE connection details
-// to help debug automatic reconnection issues
+// to help debug automatic reconnection issues. It follows the sampling of
+// RequestLogging.
 func EventSourceLogging() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		// Check if this is an EventSource request
 		accept := c.Get("Accept", "")
-		if accept == "text/event-stream" || c.Get("Cache-Control") == "no-cache" {
+		if (accept == "text/event-stream" || c.Get("Cache-Control") == "no-cache") && LogSampled(c) {
 			clientIP := c.IP()
 			userAgent := c.Get("User-Agent", "Unknown")
 			
-			log.Printf("[EVENTSOURCE] Client: %s connecting to %s %s | User-Agent: %s",
-				clientIP, c.Method(), c.Path(), userAgent)
+			log.Printf("[EVENTSOURCE] Client: %s connecting to %s %s | Request: %s | User-Agent: %s",
+				clientIP, c.Method(), c.Path(), GetRequestID(c), userAgent)
 				
 			// Log if this looks like an automatic reconnection
 			lastEventID := c.Get("Last-Event-ID", "")
 			if lastEventID != "" {
-				log.Printf("[EVENTSOURCE_RECONNECT] Client: %s reconnecting with Last-Event-ID: %s",
-					clientIP, lastEventID)
+				log.Printf("[EVENTSOURCE_RECONNECT] Client: %s reconnecting with Last-Event-ID: %s | Request: %s",
+					clientIP, lastEventID, GetRequestID(c))
 			}
 		}
 
@@ -115,8 +141,8 @@ func RateLimitLogging() fiber.Handler {
 			clientIP := c.IP()
 			userAgent := c.Get("User-Agent", "Unknown")
 			
-			log.Printf("[RATE_LIMIT] Client: %s hit rate limit on %s %s | User-Agent: %s",
-				clientIP, c.Method(), c.Path(), userAgent)
+			log.Printf("[RATE_LIMIT] Client: %s hit rate limit on %s %s | Request: %s | User-Agent: %s",
+				clientIP, c.Method(), c.Path(), GetRequestID(c), userAgent)
 		}
 		
 		return err
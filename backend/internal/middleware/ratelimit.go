@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/ratelimit"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RateLimitConfig sets the token buckets applied by RateLimit
+type RateLimitConfig struct {
+	// PerIP limits anonymous requests by client IP
+	PerIP ratelimit.Limit
+	// PerUser limits authenticated requests by user, so users sharing an IP are not throttled together
+	PerUser ratelimit.Limit
+	// PerAPIKey limits requests authenticated with an API key by key, so scripts
+	// do not eat into their user's browser traffic
+	PerAPIKey ratelimit.Limit
+}
+
+// RateLimit returns a middleware that limits requests with a token bucket per
+// API key, per authenticated user, or per client IP for anonymous requests. It
+// must run after OptionalAuth and APIKeyAuth. Limits are reported in X-RateLimit-* headers; when the store
+// fails, requests are let through rather than taking the API down.
+func RateLimit(store ratelimit.Store, cfg RateLimitConfig) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key, limit := "ip:"+c.IP(), cfg.PerIP
+		if keyID, ok := APIKeyID(c); ok {
+			key, limit = fmt.Sprintf("apikey:%d", keyID), cfg.PerAPIKey
+		} else if userID, ok := UserID(c); ok {
+			key, limit = fmt.Sprintf("user:%d", userID), cfg.PerUser
+		}
+
+		if !take(c, store, key, limit) {
+			return c.Status(fiber.StatusTooManyRequests).JSON(models.CreateErrorResponse("rate limit exceeded, please slow down"))
+		}
+		return c.Next()
+	}
+}
+
+// take draws a token from the bucket under key, sets the X-RateLimit-* headers and
+// reports whether the request may proceed
+func take(c *fiber.Ctx, store ratelimit.Store, key string, limit ratelimit.Limit) bool {
+	result, err := store.Allow(c.UserContext(), key, limit)
+	if err != nil {
+		log.Printf("[RATE_LIMIT] Store failed, allowing %s: %v", key, err)
+		return true
+	}
+
+	c.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+	if !result.Allowed {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+	}
+	return result.Allowed
+}
+
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
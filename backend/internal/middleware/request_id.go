@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the request ID between clients, proxies and the API
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the fiber.Ctx locals key holding the request ID
+const requestIDKey = "request_id"
+
+// maxRequestIDLength caps client-supplied request IDs, which end up in every log line
+const maxRequestIDLength = 128
+
+// RequestID returns a middleware that assigns each request an ID so its log
+// lines and stream events can be correlated. A well-formed X-Request-ID from the
+// client or a proxy in front of the API is kept, otherwise a UUID is generated.
+// The ID is echoed in the response header. Register it before the logging
+// middleware.
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.New().String()
+		}
+		c.Locals(requestIDKey, id)
+		c.Set(RequestIDHeader, id)
+		return c.Next()
+	}
+}
+
+// GetRequestID returns the ID assigned by RequestID, or "" without the middleware
+func GetRequestID(c *fiber.Ctx) string {
+	id, _ := c.Locals(requestIDKey).(string)
+	return id
+}
+
+// validRequestID reports whether a client-supplied ID is safe to log and echo:
+// non-empty, bounded and limited to letters, digits and -_.:
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
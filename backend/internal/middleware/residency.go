@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"community-chatbot/internal/residency"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// CommunityHeader names the community a request is for, which decides the
+// region its data is stored in
+const CommunityHeader = "X-Community"
+
+// Residency binds each request to the region of its community. Requests
+// without the header are served from the default region; unknown communities
+// are rejected rather than served from the wrong region.
+func Residency(router *residency.Router) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, err := router.Bind(c.UserContext(), c.Get(CommunityHeader))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
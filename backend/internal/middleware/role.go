@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"log"
+
+	"community-chatbot/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RoleLookup returns the role of a user
+type RoleLookup func(ctx context.Context, userID uint) (string, error)
+
+// Fiber.Ctx locals keys of IdentifyRole: the lookup, and the role once looked up
+const (
+	roleLookupKey = "roleLookup"
+	roleKey       = "role"
+)
+
+// IdentifyRole returns a middleware that lets HasPermission look up the role of
+// authenticated users. Roles are only looked up when a handler asks, so
+// requests that do not care cost nothing.
+func IdentifyRole(lookup RoleLookup) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Locals(roleLookupKey, lookup)
+		return c.Next()
+	}
+}
+
+// HasPermission reports whether the request was made with the admin token or
+// by a user whose role grants permission. Failed lookups are logged and deny.
+func HasPermission(c *fiber.Ctx, permission string) bool {
+	if admin, _ := c.Locals(adminKey).(bool); admin {
+		return true
+	}
+	userID, ok := UserID(c)
+	if !ok {
+		return false
+	}
+	role, ok := c.Locals(roleKey).(string)
+	if !ok {
+		lookup, found := c.Locals(roleLookupKey).(RoleLookup)
+		if !found {
+			return false
+		}
+		var err error
+		if role, err = lookup(c.UserContext(), userID); err != nil {
+			log.Printf("[ERROR] Look up role of user %d: %v", userID, err)
+			return false
+		}
+		c.Locals(roleKey, role)
+	}
+	return models.RoleHas(role, permission)
+}
+
+// RequirePermission returns a middleware that only lets through users whose
+// role grants permission, and operators with the admin token, who may do
+// everything. Users are identified by OptionalAuth or APIKeyAuth before it;
+// roles are looked up on every request, so changes apply at once.
+func RequirePermission(adminToken string, lookup RoleLookup, permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if hasAdminToken(c, adminToken) {
+			c.Locals(adminKey, true)
+			return c.Next()
+		}
+		userID, ok := UserID(c)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(models.CreateErrorResponse("authentication required"))
+		}
+		role, err := lookup(c.UserContext(), userID)
+		if err != nil {
+			log.Printf("[ERROR] Look up role of user %d: %v", userID, err)
+			return c.Status(fiber.StatusInternalServerError).JSON(models.CreateErrorResponse("failed to check permissions"))
+		}
+		if !models.RoleHas(role, permission) {
+			return c.Status(fiber.StatusForbidden).JSON(models.CreateErrorResponse("insufficient permissions"))
+		}
+		return c.Next()
+	}
+}
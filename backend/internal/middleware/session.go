@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"community-chatbot/internal/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SessionHeader carries the session token of anonymous chat users who cannot use cookies
+const SessionHeader = "X-Session-Token"
+
+// SessionCookie is the cookie carrying the session token
+const SessionCookie = "chat_session"
+
+// sessionIDKey is the fiber.Ctx locals key holding the session ID
+const sessionIDKey = "session_id"
+
+// SessionStore resolves and issues anonymous chat sessions
+type SessionStore interface {
+	// Resolve returns the ID of the live session with token, or "" when there is none
+	Resolve(ctx context.Context, token string) (string, error)
+	// Create issues a new session and returns its ID and token
+	Create(ctx context.Context) (string, string, error)
+	// TTL returns how long sessions last without use
+	TTL() time.Duration
+}
+
+// SessionConfig controls how session tokens are handed out
+type SessionConfig struct {
+	// Issue creates a session for anonymous requests that do not have one
+	Issue bool
+	// SecureCookie marks the cookie Secure and SameSite=None so it is sent to an
+	// API on another site; otherwise it is SameSite=Lax
+	SecureCookie bool
+}
+
+// Session returns a middleware that identifies the session of anonymous
+// requests from the X-Session-Token header or the session cookie. With Issue
+// set, requests without a live session get a new one, returned in the
+// X-Session-Token response header and the cookie. Authenticated requests are
+// left alone, so it must run after OptionalAuth. When the store fails, requests
+// continue without a session.
+func Session(store SessionStore, cfg SessionConfig) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := UserID(c); ok {
+			return c.Next()
+		}
+
+		token := c.Get(SessionHeader)
+		if token == "" {
+			token = c.Cookies(SessionCookie)
+		}
+		if token != "" {
+			id, err := store.Resolve(c.UserContext(), token)
+			if err != nil {
+				log.Printf("[SESSION] Failed to resolve session: %v", err)
+				return c.Next()
+			}
+			if id != "" {
+				c.Locals(sessionIDKey, id)
+				setSessionCookie(c, token, store.TTL(), cfg.SecureCookie)
+				return c.Next()
+			}
+		}
+		if !cfg.Issue {
+			return c.Next()
+		}
+
+		id, token, err := store.Create(c.UserContext())
+		if err != nil {
+			log.Printf("[SESSION] Failed to create session: %v", err)
+			return c.Next()
+		}
+		c.Locals(sessionIDKey, id)
+		c.Set(SessionHeader, token)
+		setSessionCookie(c, token, store.TTL(), cfg.SecureCookie)
+		return c.Next()
+	}
+}
+
+// RequireSession returns a middleware that rejects anonymous requests without a
+// live session. It must run after Session.
+func RequireSession() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := UserID(c); !ok && SessionID(c) == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(models.CreateErrorResponse("session required"))
+		}
+		return c.Next()
+	}
+}
+
+// SessionID returns the session ID set by Session, or "" without a session
+func SessionID(c *fiber.Ctx) string {
+	id, _ := c.Locals(sessionIDKey).(string)
+	return id
+}
+
+// setSessionCookie sets or refreshes the session cookie
+func setSessionCookie(c *fiber.Ctx, token string, ttl time.Duration, secure bool) {
+	sameSite := fiber.CookieSameSiteLaxMode
+	if secure {
+		sameSite = fiber.CookieSameSiteNoneMode
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     SessionCookie,
+		Value:    token,
+		Path:     "/api/v1",
+		MaxAge:   int(ttl.Seconds()),
+		Secure:   secure,
+		HTTPOnly: true,
+		SameSite: sameSite,
+	})
+}
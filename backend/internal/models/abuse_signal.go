@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Kinds of abuse signals
+const (
+	// AbuseMessageFlood is recorded when a client's chat messages are slowed down
+	AbuseMessageFlood = "message_flood"
+	// AbuseMessageFloodBlock is recorded when a client is blocked from chatting
+	AbuseMessageFloodBlock = "message_flood_block"
+)
+
+// AbuseSignal records suspected abuse by a client for admins to review.
+// Anonymous clients are identified by their fingerprint and IP address.
+type AbuseSignal struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Kind        string `gorm:"size:50;not null;index" json:"kind"`
+	UserID      *uint  `gorm:"index" json:"user_id,omitempty"` // nil for anonymous clients
+	Fingerprint string `gorm:"size:64" json:"fingerprint,omitempty"`
+	ClientIP    string `gorm:"size:45" json:"client_ip"`
+	// Detail describes what was detected and the penalty applied
+	Detail    string    `gorm:"size:500" json:"detail"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName returns the table name for AbuseSignal
+func (AbuseSignal) TableName() string {
+	return "abuse_signals"
+}
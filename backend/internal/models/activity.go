@@ -1,6 +1,7 @@
 package models
 
 import (
+	"slices"
 	"time"
 
 	"gorm.io/gorm"
@@ -14,24 +15,59 @@ type Location struct {
 
 // Activity represents an activity in the system
 type Activity struct {
-	ID          uint           `gorm:"primaryKey" json:"id"`
-	Name        string         `gorm:"size:255;not null;index" json:"name" validate:"required,min=3,max=255"`
-	Description string         `gorm:"type:text" json:"description"`
-	Category    string         `gorm:"size:100;index" json:"category"`
-	Latitude    float64        `gorm:"type:decimal(10,8)" json:"latitude" validate:"latitude"`
-	Longitude   float64        `gorm:"type:decimal(11,8)" json:"longitude" validate:"longitude"`
-	Difficulty  string         `gorm:"size:50" json:"difficulty"`
-	Duration    int            `json:"duration"` // minutes
-	BestSeason  string         `gorm:"size:100" json:"best_season"`
-	UserID      uint           `json:"user_id"`
-	Images      []Image        `gorm:"foreignKey:ActivityID" json:"images,omitempty"`
-	Routes      []Route        `gorm:"foreignKey:ActivityID" json:"routes,omitempty"`
-	Approved    bool           `gorm:"default:false" json:"approved"`
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID          uint    `gorm:"primaryKey" json:"id"`
+	Name        string  `gorm:"size:255;not null;index" json:"name" validate:"required,min=3,max=255"`
+	Description string  `gorm:"type:text" json:"description"`
+	Category    string  `gorm:"size:100;index" json:"category"` // slug of the category, kept for older clients
+	CategoryID  *uint   `gorm:"index" json:"category_id,omitempty"`
+	Latitude    float64 `gorm:"type:decimal(10,8)" json:"latitude" validate:"latitude"`
+	Longitude   float64 `gorm:"type:decimal(11,8)" json:"longitude" validate:"longitude"`
+	Difficulty  string  `gorm:"size:50" json:"difficulty"`
+	Duration    int     `json:"duration"` // minutes
+	BestSeason  string  `gorm:"size:100" json:"best_season"`
+	UserID      uint    `json:"user_id"`
+	Images      []Image `gorm:"foreignKey:ActivityID" json:"images,omitempty"`
+	Routes      []Route `gorm:"foreignKey:ActivityID" json:"routes,omitempty"`
+	Approved    bool    `gorm:"default:false" json:"approved"`
+	// OpeningHours is free text such as "Daily 8:00-18:00"; empty when always open or unknown
+	OpeningHours string `gorm:"size:255" json:"opening_hours" validate:"max=255"`
+	// ModeratedAt is set when a moderator approves or rejects the submission;
+	// unapproved activities without it are pending review
+	ModeratedAt     *time.Time `json:"moderated_at,omitempty"`
+	RejectionReason string     `gorm:"size:500" json:"rejection_reason,omitempty"`
+	// AutoApproved activities were published without a moderator because their
+	// submitter is trusted; moderators audit them and can revoke the approval
+	AutoApproved bool `gorm:"not null;default:false;index" json:"auto_approved"`
+	// OriginCommunity and OriginID attribute activities imported from another community
+	OriginCommunity string `gorm:"size:255;uniqueIndex:idx_activities_origin,where:origin_community <> ''" json:"origin_community,omitempty"`
+	OriginID        uint   `gorm:"uniqueIndex:idx_activities_origin,where:origin_community <> ''" json:"origin_id,omitempty"`
+	// VariantOf is the primary activity of a group of seasonal variants sharing
+	// its location, e.g. the snowshoe route of a summer hiking trail
+	VariantOf *uint `gorm:"index" json:"variant_of,omitempty"`
+	// Seasons are the seasons the activity is offered in; empty for all year
+	Seasons []string `gorm:"serializer:json;type:jsonb" json:"seasons,omitempty" validate:"max=4,dive,oneof=spring summer autumn winter"`
+	// Variants are the other activities of the activity's group, set by ActivityService.Get
+	Variants []Activity `gorm:"-" json:"variants,omitempty"`
+	// RatingAverage and RatingCount aggregate the ratings of visible reviews
+	RatingAverage float64 `gorm:"default:0" json:"rating_average"`
+	RatingCount   int     `gorm:"default:0" json:"rating_count"`
+	// PopularityScore ranks activities by recent interest, recent days weighing
+	// most; it is recomputed periodically, see ActivityStatsService.RecomputePopularity
+	PopularityScore float64 `gorm:"not null;default:0;index" json:"popularity_score"`
+	// LastVerifiedAt is set by moderator verification or recent condition reports
+	LastVerifiedAt *time.Time     `gorm:"index" json:"last_verified_at"`
+	Outdated       bool           `gorm:"-" json:"outdated"`
+	FreshnessNote  string         `gorm:"-" json:"freshness_note,omitempty"`
+	Reviews        *ReviewSummary `gorm:"-" json:"reviews,omitempty"`
+	IsFavorited    *bool          `gorm:"-" json:"is_favorited,omitempty"` // set for authenticated requests
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// OutdatedNotice is shown alongside activities whose data has not been verified recently
+const OutdatedNotice = "Information may be outdated"
+
 // TableName returns the table name for Activity
 func (Activity) TableName() string {
 	return "activities"
@@ -55,3 +91,35 @@ func (a *Activity) SetLocation(loc Location) {
 	a.Latitude = loc.Lat
 	a.Longitude = loc.Lng
 }
+
+// VerifiedAt returns when the activity data was last confirmed, falling back to
+// the last update for activities that were never explicitly verified
+func (a *Activity) VerifiedAt() time.Time {
+	if a.LastVerifiedAt != nil {
+		return *a.LastVerifiedAt
+	}
+	return a.UpdatedAt
+}
+
+// GroupID returns the ID of the primary activity of the activity's group of
+// seasonal variants, which is its own ID for activities without variants
+func (a *Activity) GroupID() uint {
+	if a.VariantOf != nil {
+		return *a.VariantOf
+	}
+	return a.ID
+}
+
+// OffersSeason reports whether the activity is offered in a season
+func (a *Activity) OffersSeason(season string) bool {
+	return len(a.Seasons) == 0 || slices.Contains(a.Seasons, season)
+}
+
+// ApplyFreshness flags the activity as outdated when it was verified longer than staleAfter ago
+func (a *Activity) ApplyFreshness(staleAfter time.Duration) {
+	a.Outdated = staleAfter > 0 && time.Since(a.VerifiedAt()) > staleAfter
+	a.FreshnessNote = ""
+	if a.Outdated {
+		a.FreshnessNote = OutdatedNotice
+	}
+}
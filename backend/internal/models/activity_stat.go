@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ActivityStat counts the interest in an activity per day: detail page views,
+// new favorites and links to it in chat answers. With short link scans they
+// rank trending activities.
+type ActivityStat struct {
+	ActivityID uint      `gorm:"primaryKey;autoIncrement:false" json:"activity_id"`
+	Day        time.Time `gorm:"type:date;primaryKey;index" json:"day"`
+	Views      int64     `gorm:"not null;default:0" json:"views"`
+	Favorites  int64     `gorm:"not null;default:0" json:"favorites"`
+	Mentions   int64     `gorm:"not null;default:0" json:"mentions"`
+}
+
+// TableName returns the table name for ActivityStat
+func (ActivityStat) TableName() string {
+	return "activity_stats"
+}
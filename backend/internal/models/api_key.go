@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// APIKeyPrefix marks personal API keys, telling them apart from embed keys sent
+// in the same X-API-Key header and making them recognizable when leaked
+const APIKeyPrefix = "cck_"
+
+// APIKey lets a user's scripts call the API as that user without a browser
+// session. Only a hash of the key is stored; the key itself is shown once on
+// creation.
+type APIKey struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	Name       string     `gorm:"size:255;not null" json:"name"`
+	KeyHash    string     `gorm:"size:64;not null;uniqueIndex" json:"-"` // hex SHA-256
+	KeyPrefix  string     `gorm:"size:16;not null" json:"key_prefix"`    // identifies the key in listings
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// TableName returns the table name for APIKey
+func (APIKey) TableName() string {
+	return "api_keys"
+}
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// Category is an activity category. Categories form a hierarchy through their
+// parent, e.g. Outdoors > Hiking, and filtering by a category includes its
+// subcategories.
+type Category struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	Name      string     `gorm:"size:100;not null" json:"name"`
+	Slug      string     `gorm:"size:100;not null;uniqueIndex" json:"slug"` // stored in Activity.Category
+	ParentID  *uint      `gorm:"index" json:"parent_id,omitempty"`
+	Children  []Category `gorm:"-" json:"children,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// TableName returns the table name for Category
+func (Category) TableName() string {
+	return "categories"
+}
+
+// CategorySeed is a category created on first start, identified by slug
+type CategorySeed struct {
+	Slug   string
+	Name   string
+	Parent string // slug of the parent, empty for top-level categories
+}
+
+// DefaultCategories are seeded into an empty category table, parents first
+var DefaultCategories = []CategorySeed{
+	{Slug: "outdoors", Name: "Outdoors"},
+	{Slug: "hiking", Name: "Hiking", Parent: "outdoors"},
+	{Slug: "cycling", Name: "Cycling", Parent: "outdoors"},
+	{Slug: "climbing", Name: "Climbing", Parent: "outdoors"},
+	{Slug: "water-sports", Name: "Water sports", Parent: "outdoors"},
+	{Slug: "food-drink", Name: "Food & drink"},
+	{Slug: "restaurant", Name: "Restaurants", Parent: "food-drink"},
+	{Slug: "cafe", Name: "Cafés", Parent: "food-drink"},
+	{Slug: "culture", Name: "Culture"},
+	{Slug: "museum", Name: "Museums", Parent: "culture"},
+	{Slug: "landmark", Name: "Landmarks", Parent: "culture"},
+}
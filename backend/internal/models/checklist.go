@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Seasons checklists are generated for
+const (
+	SeasonSpring = "spring"
+	SeasonSummer = "summer"
+	SeasonAutumn = "autumn"
+	SeasonWinter = "winter"
+)
+
+// Seasons lists the seasons in calendar order
+var Seasons = []string{SeasonSpring, SeasonSummer, SeasonAutumn, SeasonWinter}
+
+// SeasonAt returns the meteorological season of a date at a latitude; seasons
+// are reversed in the southern hemisphere
+func SeasonAt(date time.Time, lat float64) string {
+	index := (int(date.Month()) % 12) / 3 // 0 is December to February
+	if lat < 0 {
+		index = (index + 2) % 4
+	}
+	return []string{SeasonWinter, SeasonSpring, SeasonSummer, SeasonAutumn}[index]
+}
+
+// ChecklistItem is one entry of a packing checklist
+type ChecklistItem struct {
+	Name     string `json:"name"`
+	Category string `json:"category"` // e.g. water, clothing, lights
+	Quantity string `json:"quantity,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	// Source is generated, forecast or moderator
+	Source string `json:"source"`
+}
+
+// ChecklistOverride is a moderator's correction of an activity's generated
+// packing checklist: it adds or replaces an item, or removes one
+type ChecklistOverride struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ActivityID uint      `gorm:"not null;index" json:"activity_id"`
+	Season     string    `gorm:"size:20" json:"season"` // empty for all seasons
+	Item       string    `gorm:"size:100;not null" json:"item"`
+	Remove     bool      `json:"remove"`
+	Category   string    `gorm:"size:50" json:"category,omitempty"`
+	Quantity   string    `gorm:"size:100" json:"quantity,omitempty"`
+	Note       string    `gorm:"size:255" json:"note,omitempty"` // shown as the item's reason
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TableName returns the table name for ChecklistOverride
+func (ChecklistOverride) TableName() string {
+	return "checklist_overrides"
+}
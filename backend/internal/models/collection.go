@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Collection visibilities
+const (
+	CollectionPublic  = "public"
+	CollectionPrivate = "private"
+)
+
+// Collection is a curated, ordered list of activities such as "Best autumn
+// hikes" or "Rainy day options". Public collections can be followed and are
+// recommended in chat; private ones are only visible to their owner.
+type Collection struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	UserID      uint   `gorm:"not null;index" json:"user_id"`
+	Title       string `gorm:"size:255;not null" json:"title" validate:"required,min=3,max=255"`
+	Description string `gorm:"type:text" json:"description" validate:"max=2000"`
+	Visibility  string `gorm:"size:20;not null;default:private;index" json:"visibility" validate:"oneof=public private"`
+	// Curated collections were created by a moderator and are recommended first
+	Curated       bool             `gorm:"not null;default:false;index" json:"curated"`
+	FollowerCount int              `gorm:"not null;default:0" json:"follower_count"`
+	Items         []CollectionItem `gorm:"foreignKey:CollectionID" json:"items,omitempty"`
+	IsFollowed    *bool            `gorm:"-" json:"is_followed,omitempty"` // set for authenticated requests
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt   `gorm:"index" json:"-"`
+}
+
+// TableName returns the table name for Collection
+func (Collection) TableName() string {
+	return "collections"
+}
+
+// IsPublic reports whether anyone can see the collection
+func (c *Collection) IsPublic() bool {
+	return c.Visibility == CollectionPublic
+}
+
+// CollectionItem is an activity in a collection, with the curator's note on it
+type CollectionItem struct {
+	CollectionID uint      `gorm:"primaryKey;autoIncrement:false" json:"collection_id"`
+	ActivityID   uint      `gorm:"primaryKey;autoIncrement:false;index" json:"activity_id"`
+	Position     int       `gorm:"not null" json:"position"`
+	Note         string    `gorm:"size:1000" json:"note,omitempty"`
+	Activity     *Activity `gorm:"foreignKey:ActivityID" json:"activity,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName returns the table name for CollectionItem
+func (CollectionItem) TableName() string {
+	return "collection_items"
+}
+
+// CollectionFollow is a user following a public collection
+type CollectionFollow struct {
+	UserID       uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	CollectionID uint      `gorm:"primaryKey;autoIncrement:false;index" json:"collection_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TableName returns the table name for CollectionFollow
+func (CollectionFollow) TableName() string {
+	return "collection_follows"
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Comment is a discussion post on an activity. Users are mentioned with
+// @[Name](user:<id>) markup, which frontends insert from a user picker;
+// mentions are validated and resolved when the comment is written.
+type Comment struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	ActivityID uint           `gorm:"not null;index" json:"activity_id"`
+	UserID     uint           `gorm:"not null" json:"user_id"`
+	Body       string         `gorm:"type:text;not null" json:"body" validate:"required,max=2000"`
+	Mentions   []Mention      `gorm:"serializer:json;type:jsonb" json:"mentions"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName returns the table name for Comment
+func (Comment) TableName() string {
+	return "comments"
+}
+
+// Mention is a user mentioned in a comment. Start and End are the character
+// (not byte) offsets of the mention markup in the body, so frontends can
+// replace it with a link.
+type Mention struct {
+	UserID uint   `json:"user_id"`
+	Name   string `json:"name"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+}
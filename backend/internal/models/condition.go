@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Trail condition statuses
+const (
+	ConditionOpen    = "open"
+	ConditionCaution = "caution"
+	ConditionClosed  = "closed"
+)
+
+// ConditionReport is a community report on the current state of an activity,
+// e.g. a trail closed by a landslide
+type ConditionReport struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ActivityID uint      `gorm:"not null;index:idx_condition_reports_activity_created" json:"activity_id"`
+	UserID     uint      `json:"user_id"`
+	Status     string    `gorm:"size:20;not null" json:"status" validate:"required,oneof=open caution closed"`
+	Note       string    `gorm:"type:text" json:"note" validate:"max=2000"`
+	CreatedAt  time.Time `gorm:"index:idx_condition_reports_activity_created" json:"created_at"`
+}
+
+// TableName returns the table name for ConditionReport
+func (ConditionReport) TableName() string {
+	return "condition_reports"
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Purposes users consent to their data being used for
+const (
+	// ConsentChatStorage covers storing the messages of signed-in users'
+	// conversations, which chat history and conversation memory are built from
+	ConsentChatStorage = "chat_storage"
+	// ConsentInferredPreferences covers preferences learned from use rather
+	// than entered: profile questions in chat and location history
+	ConsentInferredPreferences = "inferred_preferences"
+	// ConsentAnalytics covers attributing usage records to the user
+	ConsentAnalytics = "analytics"
+	// ConsentMarketingEmails covers digest emails of new activities and routes
+	ConsentMarketingEmails = "marketing_emails"
+)
+
+// ConsentPurpose describes a purpose and the version of its consent text.
+// Default applies to users who never decided; it keeps what the service did
+// before consent was recorded, and every purpose can be revoked.
+type ConsentPurpose struct {
+	Name        string `json:"purpose"`
+	Description string `json:"description"`
+	Version     int    `json:"version"`
+	Default     bool   `json:"default"`
+}
+
+// ConsentPurposes lists the purposes in the order they are shown. Bump a
+// purpose's version when its text changes so clients ask again.
+var ConsentPurposes = []ConsentPurpose{
+	{Name: ConsentChatStorage, Description: "Store your chat conversations so you can return to them and the assistant remembers them", Version: 1, Default: true},
+	{Name: ConsentInferredPreferences, Description: "Learn your preferences from your chats and the areas you search from", Version: 1, Default: true},
+	{Name: ConsentAnalytics, Description: "Attribute your usage to your account in usage statistics", Version: 1, Default: true},
+	{Name: ConsentMarketingEmails, Description: "Send the digest emails you subscribe to in your preferences", Version: 1, Default: true},
+}
+
+// FindConsentPurpose returns the purpose with a name
+func FindConsentPurpose(name string) (ConsentPurpose, bool) {
+	for _, purpose := range ConsentPurposes {
+		if purpose.Name == name {
+			return purpose, true
+		}
+	}
+	return ConsentPurpose{}, false
+}
+
+// Consent is a user's decision about one purpose. Version is the version of the
+// purpose's text the user decided on.
+type Consent struct {
+	ID        uint       `gorm:"primaryKey" json:"-"`
+	UserID    uint       `gorm:"not null;uniqueIndex:idx_consents_user_purpose" json:"-"`
+	Purpose   string     `gorm:"size:50;not null;uniqueIndex:idx_consents_user_purpose" json:"purpose"`
+	Granted   bool       `gorm:"not null" json:"granted"`
+	Version   int        `gorm:"not null" json:"version"`
+	GrantedAt *time.Time `json:"granted_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// TableName returns the table name for Consent
+func (Consent) TableName() string {
+	return "consents"
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Message roles
+const (
+	MessageRoleUser      = "user"
+	MessageRoleAssistant = "assistant"
+)
+
+// Conversation groups the messages of one chat session. Editing an earlier
+// message branches the conversation: messages form a tree through their
+// parents, and the active branch runs from the first message to CurrentMessageID.
+type Conversation struct {
+	ID                string         `gorm:"primaryKey;size:64" json:"id"`
+	UserID            *uint          `gorm:"index" json:"user_id,omitempty"` // nil for anonymous conversations
+	SessionID         string         `gorm:"size:36;index" json:"-"`         // session of the anonymous user, if any
+	Title             string         `gorm:"size:255" json:"title"`
+	ClientIP          string         `gorm:"size:64" json:"-"`
+	Summary           string         `gorm:"type:text" json:"-"` // rolling summary sent to the model instead of older messages
+	SummarizedThrough uint           `json:"-"`                  // ID of the last summarized message
+	SummaryTokens     int            `json:"-"`                  // estimated
+	CurrentMessageID  *uint          `json:"current_message_id"` // last message of the active branch, which new messages follow
+	Messages          []Message      `gorm:"foreignKey:ConversationID" json:"messages,omitempty"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `gorm:"index" json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TableName returns the table name for Conversation
+func (Conversation) TableName() string {
+	return "conversations"
+}
+
+// Message is a single user message or assistant response in a conversation
+type Message struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	ConversationID string    `gorm:"size:64;not null;index" json:"conversation_id"`
+	ParentID       *uint     `gorm:"index" json:"parent_id"` // the message this one follows, nil for the first message of a branch from the start
+	Role           string    `gorm:"size:20;not null" json:"role"`
+	Content        string    `gorm:"type:text;not null" json:"content"`
+	Interrupted    bool      `gorm:"not null;default:false" json:"interrupted,omitempty"` // answer cut off by a disconnect or failure
+	CreatedAt      time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName returns the table name for Message
+func (Message) TableName() string {
+	return "messages"
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// Difficulty levels, from easiest
+const (
+	DifficultyEasy     = "easy"
+	DifficultyModerate = "moderate"
+	DifficultyHard     = "hard"
+)
+
+// Default score thresholds of the difficulty levels
+const (
+	DefaultEasyMaxScore     = 10
+	DefaultModerateMaxScore = 20
+)
+
+// DifficultyCalibration maps route difficulty scores to difficulty levels for a
+// community, since "moderate" in the Alps is not "moderate" in flat regions.
+// Community is empty for this instance's own activities; other communities are
+// those activities were imported from.
+type DifficultyCalibration struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Community   string    `gorm:"size:255;not null;uniqueIndex" json:"community"`
+	EasyMax     float64   `gorm:"not null" json:"easy_max"`     // routes scoring below are easy
+	ModerateMax float64   `gorm:"not null" json:"moderate_max"` // routes scoring below are moderate, the rest hard
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName returns the table name for DifficultyCalibration
+func (DifficultyCalibration) TableName() string {
+	return "difficulty_calibrations"
+}
+
+// DefaultDifficultyCalibration returns the calibration used for communities without one
+func DefaultDifficultyCalibration(community string) DifficultyCalibration {
+	return DifficultyCalibration{
+		Community:   community,
+		EasyMax:     DefaultEasyMaxScore,
+		ModerateMax: DefaultModerateMaxScore,
+	}
+}
+
+// Level returns the difficulty level of a route score
+func (c DifficultyCalibration) Level(score float64) string {
+	switch {
+	case score < c.EasyMax:
+		return DifficultyEasy
+	case score < c.ModerateMax:
+		return DifficultyModerate
+	default:
+		return DifficultyHard
+	}
+}
+
+// ScoreRange returns the scores [min, max) of a difficulty level, or ok=false
+// for levels other than easy, moderate and hard
+func (c DifficultyCalibration) ScoreRange(level string) (min, max float64, ok bool) {
+	switch level {
+	case DifficultyEasy:
+		return 0, c.EasyMax, true
+	case DifficultyModerate:
+		return c.EasyMax, c.ModerateMax, true
+	case DifficultyHard:
+		return c.ModerateMax, math.Inf(1), true
+	}
+	return 0, 0, false
+}
+
+// RouteDifficultyScore rates the effort of a route in effort kilometers: its
+// distance plus one kilometer per 100 m of climbing
+func RouteDifficultyScore(distanceKM float64, elevationGainM int) float64 {
+	return math.Round((distanceKM+float64(elevationGainM)/100)*100) / 100
+}
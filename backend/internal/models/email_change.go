@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// EmailChange is a pending change of a user's email address, applied when the
+// link sent to the new address is followed. Only a hash of the token is stored.
+type EmailChange struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Email       string     `gorm:"size:255;not null" json:"email"`        // the new address
+	TokenHash   string     `gorm:"size:64;not null;uniqueIndex" json:"-"` // hex SHA-256
+	ExpiresAt   time.Time  `gorm:"not null" json:"expires_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	User        User       `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// TableName returns the table name for EmailChange
+func (EmailChange) TableName() string {
+	return "email_changes"
+}
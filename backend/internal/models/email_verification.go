@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// EmailVerification is a link sent to a user's address to confirm they own it.
+// Only a hash of the token is stored.
+type EmailVerification struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex" json:"-"` // hex SHA-256
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	User      User      `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// TableName returns the table name for EmailVerification
+func (EmailVerification) TableName() string {
+	return "email_verifications"
+}
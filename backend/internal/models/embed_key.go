@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// EmbedKey is an API key a third-party site uses to obtain chat widget tokens.
+// Only a hash of the key is stored; the key itself is shown once on creation.
+type EmbedKey struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	Name           string     `gorm:"size:255;not null" json:"name"`
+	KeyHash        string     `gorm:"size:64;not null;uniqueIndex" json:"-"` // hex SHA-256
+	KeyPrefix      string     `gorm:"size:16;not null" json:"key_prefix"`    // identifies the key in listings
+	AllowedOrigins StringList `gorm:"type:text[]" json:"allowed_origins"`
+	Rate           float64    `gorm:"not null" json:"rate"` // chat requests per second across all visitors
+	Burst          int        `gorm:"not null" json:"burst"`
+	LastUsedAt     *time.Time `json:"last_used_at"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// TableName returns the table name for EmbedKey
+func (EmbedKey) TableName() string {
+	return "embed_keys"
+}
+
+// AllowsOrigin reports whether widgets may be embedded on origin
+func (k *EmbedKey) AllowsOrigin(origin string) bool {
+	for _, allowed := range k.AllowedOrigins {
+		if allowed == origin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Favorite is an activity a user saved to come back to
+type Favorite struct {
+	UserID     uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	ActivityID uint      `gorm:"primaryKey;autoIncrement:false;index" json:"activity_id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Activity   *Activity `gorm:"foreignKey:ActivityID" json:"activity,omitempty"`
+}
+
+// TableName returns the table name for Favorite
+func (Favorite) TableName() string {
+	return "favorites"
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// GuardrailPolicyID is the ID of the single guardrail policy of this community
+const GuardrailPolicyID = 1
+
+// GuardrailPolicy holds the operator's topic rules for the chat: the topics it
+// may discuss, the topics it must refuse and how it refuses. Built-in blocked
+// topics are medical, legal, financial and political; any other topic is
+// matched by its name.
+type GuardrailPolicy struct {
+	ID             uint       `gorm:"primaryKey" json:"-"`
+	AllowedTopics  StringList `gorm:"type:text[]" json:"allowed_topics"`
+	BlockedTopics  StringList `gorm:"type:text[]" json:"blocked_topics"`
+	RefusalStyle   string     `gorm:"size:20;not null;default:brief" json:"refusal_style"` // brief or redirect
+	RefusalMessage string     `gorm:"type:text" json:"refusal_message"`                    // empty for the default refusal
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// TableName returns the table name for GuardrailPolicy
+func (GuardrailPolicy) TableName() string {
+	return "guardrail_policies"
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Invitation lets a user created by an import set a password and sign in. Only a
+// hash of the token is stored; the token itself is only sent to the user.
+type Invitation struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"` // hex SHA-256
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	User       User       `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// TableName returns the table name for Invitation
+func (Invitation) TableName() string {
+	return "invitations"
+}
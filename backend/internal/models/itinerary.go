@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Itinerary rendering states
+const (
+	ItineraryPending = "pending"
+	ItineraryReady   = "ready"
+	ItineraryFailed  = "failed"
+)
+
+// Itinerary is a printable PDF of a selection of activities. It is rendered in the
+// background; the random ID doubles as the secret of its download link.
+type Itinerary struct {
+	ID          string     `gorm:"primaryKey;size:64" json:"id"`
+	UserID      *uint      `gorm:"index" json:"user_id,omitempty"` // nil when requested anonymously
+	Title       string     `gorm:"size:255;not null" json:"title"`
+	ActivityIDs UintList   `gorm:"type:bigint[];not null" json:"activity_ids"` // in visiting order
+	Status      string     `gorm:"size:20;not null;index" json:"status"`
+	Error       string     `gorm:"size:500" json:"error,omitempty"`
+	PDF         []byte     `json:"-"`
+	DownloadURL string     `gorm:"-" json:"download_url,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RenderedAt  *time.Time `json:"rendered_at,omitempty"`
+}
+
+// TableName returns the table name for Itinerary
+func (Itinerary) TableName() string {
+	return "itineraries"
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// LocationHistoryPrecision is the number of decimal places kept from searched
+// coordinates (about 1 km), so history records areas rather than exact positions
+const LocationHistoryPrecision = 2
+
+// LocationHistory counts how often a user searched from a coarse area. It is only
+// recorded for users who opted in through their preferences.
+type LocationHistory struct {
+	ID          uint      `gorm:"primaryKey" json:"-"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_location_history_user_area" json:"-"`
+	Lat         float64   `gorm:"type:decimal(5,2);not null;uniqueIndex:idx_location_history_user_area" json:"lat"`
+	Lng         float64   `gorm:"type:decimal(6,2);not null;uniqueIndex:idx_location_history_user_area" json:"lng"`
+	Searches    int       `gorm:"not null;default:1" json:"searches"`
+	FirstSeenAt time.Time `json:"first_seen_at"`
+	LastSeenAt  time.Time `json:"last_seen_at"`
+}
+
+// TableName returns the table name for LocationHistory
+func (LocationHistory) TableName() string {
+	return "location_history"
+}
+
+// CoarseLocation rounds a location to LocationHistoryPrecision
+func CoarseLocation(loc Location) Location {
+	scale := math.Pow(10, LocationHistoryPrecision)
+	return Location{
+		Lat: math.Round(loc.Lat*scale) / scale,
+		Lng: math.Round(loc.Lng*scale) / scale,
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// LogSamplingPolicyID is the ID of the single log sampling policy set by admins
+const LogSamplingPolicyID = 1
+
+// LogSamplingPolicy controls which requests are logged. Each request is logged
+// with the rate of the longest rule matching its path, or DefaultRate without
+// one; failed and slow requests are always logged. Rule paths match exactly or,
+// ending in *, by prefix.
+type LogSamplingPolicy struct {
+	ID          uint               `gorm:"primaryKey" json:"-"`
+	DefaultRate float64            `gorm:"not null" json:"default_rate"`
+	Rules       map[string]float64 `gorm:"serializer:json;type:jsonb" json:"rules"`
+	// SlowThresholdMS is the duration from which requests are always logged, 0 to disable
+	SlowThresholdMS int       `gorm:"not null" json:"slow_threshold_ms"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// TableName returns the table name for LogSamplingPolicy
+func (LogSamplingPolicy) TableName() string {
+	return "log_sampling_policies"
+}
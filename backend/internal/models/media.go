@@ -6,16 +6,38 @@ import (
 	"gorm.io/gorm"
 )
 
+// Image processing states. Uploaded images are processing until the file is
+// stored with the image host and URL is set. Images flagged by automatic
+// screening are rejected without being uploaded.
+const (
+	ImageProcessing = "processing"
+	ImageReady      = "ready"
+	ImageFailed     = "failed"
+	ImageRejected   = "rejected"
+)
+
 // Image represents an image associated with an activity
 type Image struct {
-	ID         uint           `gorm:"primaryKey" json:"id"`
-	ActivityID uint           `gorm:"not null;index" json:"activity_id"`
-	URL        string         `gorm:"size:500;not null" json:"url" validate:"required,url"`
-	Caption    string         `gorm:"size:255" json:"caption"`
-	Approved   bool           `gorm:"default:false" json:"approved"`
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	ActivityID      uint           `gorm:"not null;index" json:"activity_id"`
+	UserID          uint           `json:"user_id,omitempty"` // uploader, 0 for imported images
+	URL             string         `gorm:"size:500;not null" json:"url" validate:"required,http_url"`
+	Caption         string         `gorm:"size:255" json:"caption"`
+	Status          string         `gorm:"size:20;not null;default:ready;index" json:"status"`
+	SpoolName       string         `gorm:"size:64" json:"-"` // spooled file awaiting upload
+	UploadAttempts  int            `gorm:"not null;default:0" json:"-"`
+	NextUploadAt    *time.Time     `gorm:"index" json:"-"`
+	UploadError     string         `gorm:"size:500" json:"upload_error,omitempty"`
+	Approved        bool           `gorm:"default:false" json:"approved"`
+	AutoApproved    bool           `gorm:"not null;default:false;index" json:"auto_approved"`
+	ModeratedAt     *time.Time     `json:"moderated_at,omitempty"` // nil while pending review
+	RejectionReason string         `gorm:"size:500" json:"rejection_reason,omitempty"`
+	ScreeningStatus string         `gorm:"size:20;index" json:"screening_status,omitempty"` // automatic screening, empty when not screened
+	ScreeningScore  *float64       `json:"screening_score,omitempty"`                       // highest category score, 0-1
+	ScreeningLabels string         `gorm:"size:255" json:"screening_labels,omitempty"`      // comma separated categories at or above the review threshold
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // TableName returns the table name for Image
@@ -25,17 +47,24 @@ func (Image) TableName() string {
 
 // Route represents a GPX route associated with an activity
 type Route struct {
-	ID             uint           `gorm:"primaryKey" json:"id"`
-	ActivityID     uint           `gorm:"not null;index" json:"activity_id"`
-	GPXFileURL     string         `gorm:"size:500" json:"gpx_file_url"`
-	Name           string         `gorm:"size:255" json:"name"`
-	DistanceKM     float64        `gorm:"type:decimal(8,2)" json:"distance_km"`
-	ElevationGainM int            `json:"elevation_gain_m"`
-	RouteType      string         `gorm:"size:50" json:"route_type"` // hiking, cycling, driving
-	Difficulty     string         `gorm:"size:50" json:"difficulty"`
-	CreatedAt      time.Time      `json:"created_at"`
-	UpdatedAt      time.Time      `json:"updated_at"`
-	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+	ID               uint           `gorm:"primaryKey" json:"id"`
+	ActivityID       uint           `gorm:"not null;index" json:"activity_id"`
+	GPXFileURL       string         `gorm:"size:500" json:"gpx_file_url"`
+	Name             string         `gorm:"size:255" json:"name"`
+	DistanceKM       float64        `gorm:"type:decimal(8,2)" json:"distance_km"`
+	ElevationGainM   int            `json:"elevation_gain_m"`
+	ElevationLossM   int            `json:"elevation_loss_m"`
+	DurationMin      int            `json:"duration_min"`              // estimated from the track at the route type's pace
+	RouteType        string         `gorm:"size:50" json:"route_type"` // hiking, cycling, driving
+	Difficulty       string         `gorm:"size:50" json:"difficulty"`
+	DifficultyAuto   bool           `gorm:"not null;default:false" json:"difficulty_auto"` // bucketed from the score, not set by the submitter
+	DifficultyScore  float64        `gorm:"type:decimal(8,2)" json:"difficulty_score"`     // see RouteDifficultyScore
+	SourceFormat     string         `gorm:"size:10" json:"source_format"`                  // gpx, tcx, kml, fit
+	TrackData        []byte         `gorm:"type:jsonb" json:"-"`                           // normalized geo.Track
+	ElevationProfile []byte         `gorm:"type:jsonb" json:"-"`                           // cached []geo.ProfilePoint, computed on first request
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // TableName returns the table name for Route
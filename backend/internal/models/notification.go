@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Notification kinds
+const (
+	NotificationTrailClosure     = "trail_closure"
+	NotificationActivityApproved = "activity_approved"
+	NotificationActivityRejected = "activity_rejected"
+	NotificationImageReady       = "image_ready"
+	NotificationImageFailed      = "image_failed"
+	NotificationImageRejected    = "image_rejected"
+	NotificationMention          = "mention"
+)
+
+// Notification is an entry in a user's notification inbox. DedupeKey is unique per
+// user so the same event never notifies a user twice.
+type Notification struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	UserID     uint       `gorm:"not null;uniqueIndex:idx_notifications_user_dedupe" json:"user_id"`
+	Kind       string     `gorm:"size:50;not null" json:"kind"`
+	Title      string     `gorm:"size:255;not null" json:"title"`
+	Body       string     `gorm:"type:text" json:"body"`
+	ActivityID *uint      `json:"activity_id,omitempty"`
+	DedupeKey  string     `gorm:"size:255;not null;uniqueIndex:idx_notifications_user_dedupe" json:"-"`
+	ReadAt     *time.Time `json:"read_at"`
+	CreatedAt  time.Time  `gorm:"index" json:"created_at"`
+}
+
+// TableName returns the table name for Notification
+func (Notification) TableName() string {
+	return "notifications"
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// OAuthIdentity links a user to their account with an external identity
+// provider, so they can sign in without a password
+type OAuthIdentity struct {
+	ID       uint   `gorm:"primaryKey" json:"-"`
+	UserID   uint   `gorm:"not null;index" json:"-"`
+	Provider string `gorm:"size:20;not null;uniqueIndex:idx_oauth_identities_provider_subject" json:"provider"`
+	// Subject is the provider's stable ID of the user
+	Subject   string    `gorm:"size:255;not null;uniqueIndex:idx_oauth_identities_provider_subject" json:"-"`
+	Email     string    `gorm:"size:255" json:"email"` // as the provider last reported it
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	User      User      `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// TableName returns the table name for OAuthIdentity
+func (OAuthIdentity) TableName() string {
+	return "oauth_identities"
+}
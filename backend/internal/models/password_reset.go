@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// PasswordReset is a link sent to a user who forgot their password. Only a
+// hash of the token is stored.
+type PasswordReset struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"` // hex SHA-256
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	User      User       `gorm:"foreignKey:UserID" json:"-"`
+}
+
+// TableName returns the table name for PasswordReset
+func (PasswordReset) TableName() string {
+	return "password_resets"
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Review is a user's rating and written review of an activity. Sentiment, Pros and
+// Cons are filled in asynchronously by review analysis after the review is stored.
+type Review struct {
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	ActivityID uint           `gorm:"not null;index;uniqueIndex:idx_reviews_user_activity,priority:2,where:deleted_at IS NULL" json:"activity_id"`
+	UserID     uint           `gorm:"uniqueIndex:idx_reviews_user_activity,priority:1,where:deleted_at IS NULL" json:"user_id"`
+	Rating     int            `gorm:"not null" json:"rating" validate:"min=1,max=5"`
+	Body       string         `gorm:"type:text;not null" json:"body" validate:"required,max=5000"`
+	Sentiment  *float64       `json:"sentiment"` // -1 (negative) to 1 (positive), nil until analyzed
+	Pros       StringList     `gorm:"type:text[]" json:"pros"`
+	Cons       StringList     `gorm:"type:text[]" json:"cons"`
+	AnalyzedAt *time.Time     `gorm:"index" json:"analyzed_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+	// Flagged reviews were reported by a user and await a moderator; Hidden ones
+	// were removed by a moderator and are not listed or counted in ratings
+	Flagged    bool   `gorm:"default:false;index" json:"flagged"`
+	FlagReason string `gorm:"size:500" json:"flag_reason,omitempty"`
+	Hidden     bool   `gorm:"default:false" json:"hidden"`
+}
+
+// TableName returns the table name for Review
+func (Review) TableName() string {
+	return "reviews"
+}
+
+// Highlight is a phrase reviewers commonly mention, e.g. "great views"
+type Highlight struct {
+	Phrase   string `json:"phrase"`
+	Mentions int    `json:"mentions"`
+}
+
+// ReviewSummary aggregates the analyzed reviews of an activity
+type ReviewSummary struct {
+	ReviewCount      int         `json:"review_count"`
+	AverageRating    float64     `json:"average_rating"`
+	AverageSentiment float64     `json:"average_sentiment"`
+	Pros             []Highlight `json:"pros"`
+	Cons             []Highlight `json:"cons"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// SavedSearch is a search a user wants to hear about, e.g. hard hikes within
+// 20 km of a point. Empty fields match every activity.
+type SavedSearch struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"not null;index" json:"user_id"`
+	Name       string    `gorm:"size:255;not null" json:"name" validate:"required,max=255"`
+	Category   string    `gorm:"size:100" json:"category" validate:"max=100"` // category slug
+	Difficulty string    `gorm:"size:50" json:"difficulty" validate:"max=50"`
+	Location   *Location `gorm:"embedded;embeddedPrefix:location_" json:"location,omitempty"`
+	RadiusKM   int       `gorm:"not null;default:0" json:"radius_km" validate:"min=0,max=500"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName returns the table name for SavedSearch
+func (SavedSearch) TableName() string {
+	return "saved_searches"
+}
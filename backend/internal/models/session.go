@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Session lets an anonymous chat user resume their conversation and keep their
+// chat context across page reloads. Only a hash of the session token is stored;
+// the token itself is handed to the client once.
+type Session struct {
+	ID                  string     `gorm:"primaryKey;size:36" json:"id"`
+	TokenHash           string     `gorm:"size:64;not null;uniqueIndex" json:"-"` // hex SHA-256
+	ConversationID      string     `gorm:"size:64" json:"conversation_id"`        // most recent conversation
+	LocationLat         *float64   `json:"location_lat,omitempty"`
+	LocationLng         *float64   `json:"location_lng,omitempty"`
+	SearchRadiusKM      int        `json:"search_radius_km,omitempty"`
+	PreferredActivities StringList `gorm:"type:text[]" json:"preferred_activities,omitempty"`
+	DifficultyLevel     string     `gorm:"size:50" json:"difficulty_level,omitempty"`
+	TransportMode       string     `gorm:"size:50" json:"transport_mode,omitempty"`
+	ExpiresAt           time.Time  `gorm:"not null;index" json:"expires_at"` // extended on every use
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// TableName returns the table name for Session
+func (Session) TableName() string {
+	return "sessions"
+}
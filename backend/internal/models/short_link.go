@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// ShortLink is the short URL of an activity, printed as a QR code on trailhead
+// signs. Each activity has at most one, so printed codes stay valid.
+type ShortLink struct {
+	ID            uint       `gorm:"primaryKey" json:"-"`
+	Code          string     `gorm:"size:16;not null;uniqueIndex" json:"code"`
+	ActivityID    uint       `gorm:"not null;uniqueIndex" json:"activity_id"`
+	Scans         int64      `gorm:"not null;default:0" json:"scans"`
+	LastScannedAt *time.Time `json:"last_scanned_at,omitempty"`
+	URL           string     `gorm:"-" json:"url"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// TableName returns the table name for ShortLink
+func (ShortLink) TableName() string {
+	return "short_links"
+}
+
+// ActivityScan counts the short link resolutions of an activity per day, which
+// rank trending activities
+type ActivityScan struct {
+	ActivityID uint      `gorm:"primaryKey;autoIncrement:false" json:"activity_id"`
+	Day        time.Time `gorm:"type:date;primaryKey;index" json:"day"`
+	Scans      int64     `gorm:"not null;default:0" json:"scans"`
+}
+
+// TableName returns the table name for ActivityScan
+func (ActivityScan) TableName() string {
+	return "activity_scans"
+}
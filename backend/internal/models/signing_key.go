@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// SigningKey is an RSA key used to sign JWTs. A key is active until it is retired
+// by a rotation, accepted for verification during a grace window after retirement,
+// and never accepted once revoked.
+type SigningKey struct {
+	ID         uint       `gorm:"primaryKey" json:"-"`
+	KID        string     `gorm:"column:kid;size:64;not null;uniqueIndex" json:"kid"`
+	Scope      string     `gorm:"size:50;not null;index" json:"scope"`
+	Algorithm  string     `gorm:"size:10;not null" json:"algorithm"`
+	PrivateKey []byte     `gorm:"not null" json:"-"` // PKCS#8 PEM
+	PublicKey  []byte     `gorm:"not null" json:"-"` // PKIX PEM
+	RetiredAt  *time.Time `json:"retired_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// TableName returns the table name for SigningKey
+func (SigningKey) TableName() string {
+	return "signing_keys"
+}
+
+// Active reports whether the key may sign new tokens
+func (k *SigningKey) Active() bool {
+	return k.RetiredAt == nil && k.RevokedAt == nil
+}
+
+// AcceptedAt reports whether tokens signed with the key are accepted at now
+func (k *SigningKey) AcceptedAt(now time.Time, grace time.Duration) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.RetiredAt == nil || now.Before(k.RetiredAt.Add(grace))
+}
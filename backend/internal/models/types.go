@@ -0,0 +1,133 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StringList maps a Go string slice to a Postgres text[] column
+type StringList []string
+
+// Value encodes the list as a Postgres array literal
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+
+	quoted := make([]string, len(l))
+	for i, item := range l {
+		escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(item)
+		quoted[i] = `"` + escaped + `"`
+	}
+	return "{" + strings.Join(quoted, ",") + "}", nil
+}
+
+// Scan decodes a Postgres array literal such as {a,"b c"}
+func (l *StringList) Scan(src interface{}) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into StringList", src)
+	}
+
+	if len(text) < 2 || text[0] != '{' || text[len(text)-1] != '}' {
+		return fmt.Errorf("invalid array literal %q", text)
+	}
+	body := text[1 : len(text)-1]
+
+	items := StringList{}
+	var current strings.Builder
+	inQuotes, escaped, quoted := false, false, false
+	flush := func() {
+		item := current.String()
+		// Unquoted NULL elements have no Go equivalent; keep them as empty strings
+		if item == "NULL" && !quoted {
+			item = ""
+		}
+		items = append(items, item)
+		current.Reset()
+		quoted = false
+	}
+
+	for _, r := range body {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			inQuotes = !inQuotes
+			quoted = true
+		case r == ',' && !inQuotes:
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if body != "" {
+		flush()
+	}
+
+	*l = items
+	return nil
+}
+
+// UintList maps a Go uint slice to a Postgres bigint[] column
+type UintList []uint
+
+// Value encodes the list as a Postgres array literal
+func (l UintList) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+
+	items := make([]string, len(l))
+	for i, item := range l {
+		items[i] = strconv.FormatUint(uint64(item), 10)
+	}
+	return "{" + strings.Join(items, ",") + "}", nil
+}
+
+// Scan decodes a Postgres array literal such as {1,2,3}
+func (l *UintList) Scan(src interface{}) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into UintList", src)
+	}
+
+	if len(text) < 2 || text[0] != '{' || text[len(text)-1] != '}' {
+		return fmt.Errorf("invalid array literal %q", text)
+	}
+
+	items := UintList{}
+	if body := text[1 : len(text)-1]; body != "" {
+		for _, part := range strings.Split(body, ",") {
+			item, err := strconv.ParseUint(part, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid array element %q", part)
+			}
+			items = append(items, uint(item))
+		}
+	}
+
+	*l = items
+	return nil
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// LLMUsage is the token usage and estimated cost of one chat run's answer
+type LLMUsage struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	ConversationID   string    `gorm:"size:64;index" json:"conversation_id"`
+	UserID           *uint     `gorm:"index" json:"user_id,omitempty"` // nil for anonymous runs
+	RunID            string    `gorm:"size:64" json:"run_id"`
+	Model            string    `gorm:"size:100" json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	EstimatedCostUSD float64   `json:"estimated_cost_usd"` // at the prices configured when the run happened
+	CreatedAt        time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName returns the table name for LLMUsage
+func (LLMUsage) TableName() string {
+	return "llm_usage"
+}
@@ -1,19 +1,82 @@
 package models
 
 import (
+	"slices"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// Trust levels of contributors, lowest first. Levels up to trusted are earned
+// with approved contributions; moderators are appointed by admins.
+const (
+	TrustNew       = "new"
+	TrustMember    = "member"
+	TrustTrusted   = "trusted"
+	TrustModerator = "moderator"
+)
+
+// TrustLevels lists the trust levels, lowest first
+var TrustLevels = []string{TrustNew, TrustMember, TrustTrusted, TrustModerator}
+
+// Roles decide what users may do beyond their own submissions. Moderators
+// review content; admins also manage users and operate the community.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Roles lists the roles, least privileged first
+var Roles = []string{RoleUser, RoleModerator, RoleAdmin}
+
+// Permissions granted by roles
+const (
+	// PermissionModerate allows approving, rejecting and editing anyone's content
+	PermissionModerate = "moderate"
+	// PermissionManageUsers allows setting the trust level and role of users and importing them
+	PermissionManageUsers = "manage_users"
+	// PermissionOperate allows the remaining admin endpoints: keys, categories, guardrails and the like
+	PermissionOperate = "operate"
+)
+
+// rolePermissions are the permissions of each role; users have none
+var rolePermissions = map[string][]string{
+	RoleModerator: {PermissionModerate},
+	RoleAdmin:     {PermissionModerate, PermissionManageUsers, PermissionOperate},
+}
+
+// RoleHas reports whether a role grants a permission
+func RoleHas(role, permission string) bool {
+	return slices.Contains(rolePermissions[role], permission)
+}
+
 // User represents a user in the system
 type User struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Email     string         `gorm:"size:255;unique" json:"email" validate:"email"`
-	Name      string         `gorm:"size:255" json:"name"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	Email        string `gorm:"size:255;unique" json:"email" validate:"email"`
+	Name         string `gorm:"size:255" json:"name"`
+	PasswordHash string `gorm:"size:255" json:"-"` // bcrypt; empty for users who cannot log in
+	Role         string `gorm:"size:20;not null;default:user" json:"role"`
+	// EmailVerifiedAt is when the user proved they own Email; nil until then
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// TokensRevokedAt rejects access and refresh tokens issued before it, to the second
+	TokensRevokedAt *time.Time `json:"-"`
+	// TrustLevel advances with approved contributions and drops after violations.
+	// Pinned levels were set by an admin and are not changed automatically.
+	TrustLevel            string         `gorm:"size:20;not null;default:new" json:"trust_level"`
+	TrustPinned           bool           `gorm:"not null;default:false" json:"trust_pinned"`
+	ApprovedContributions int            `gorm:"not null;default:0" json:"approved_contributions"`
+	Violations            int            `gorm:"not null;default:0" json:"violations"` // since the last demotion
+	CreatedAt             time.Time      `json:"created_at"`
+	UpdatedAt             time.Time      `json:"updated_at"`
+	DeletedAt             gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// SkipsModeration reports whether the user's submissions are published without
+// pre-moderation; moderators can still review and revoke them
+func (u *User) SkipsModeration() bool {
+	return u.TrustLevel == TrustTrusted || u.TrustLevel == TrustModerator
 }
 
 // TableName returns the table name for User
@@ -23,17 +86,66 @@ func (User) TableName() string {
 
 // UserPreferences represents user preferences and settings
 type UserPreferences struct {
-	ID                  uint      `gorm:"primaryKey" json:"id"`
-	UserID              uint      `gorm:"unique;not null;index" json:"user_id"`
-	LocationLat         float64   `gorm:"type:decimal(10,8)" json:"location_lat"`
-	LocationLng         float64   `gorm:"type:decimal(11,8)" json:"location_lng"`
-	SearchRadiusKM      int       `gorm:"default:50" json:"search_radius_km"`
-	PreferredActivities []string  `gorm:"type:text[]" json:"preferred_activities"`
-	DifficultyLevel     string    `gorm:"size:50" json:"difficulty_level"`
-	TransportMode       string    `gorm:"size:50;default:car" json:"transport_mode"`
-	CreatedAt           time.Time `json:"created_at"`
-	UpdatedAt           time.Time `json:"updated_at"`
-	User                User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	ID                  uint       `gorm:"primaryKey" json:"id"`
+	UserID              uint       `gorm:"unique;not null;index" json:"user_id"`
+	LocationLat         float64    `gorm:"type:decimal(10,8)" json:"location_lat"`
+	LocationLng         float64    `gorm:"type:decimal(11,8)" json:"location_lng"`
+	SearchRadiusKM      int        `gorm:"default:50" json:"search_radius_km"`
+	PreferredActivities StringList `gorm:"type:text[]" json:"preferred_activities"`
+	DifficultyLevel     string     `gorm:"size:50" json:"difficulty_level"`
+	TransportMode       string     `gorm:"size:50;default:car" json:"transport_mode"`
+	DigestFrequency     string     `gorm:"size:20;default:off;index" json:"digest_frequency" validate:"omitempty,oneof=daily weekly off"`
+	DigestHour          int        `gorm:"default:8" json:"digest_hour" validate:"gte=0,lte=23"`
+	Timezone            string     `gorm:"size:64;default:UTC" json:"timezone" validate:"omitempty,timezone"`
+	Language            string     `gorm:"size:10" json:"language" validate:"omitempty,oneof=en de fr es it"` // chat answers; empty follows the browser
+	DigestContent       StringList `gorm:"type:text[]" json:"digest_content" validate:"dive,oneof=new_activities new_routes"`
+	LastDigestAt        *time.Time `json:"last_digest_at,omitempty"`
+	LocationHistory     bool       `gorm:"default:false" json:"location_history"` // explicit opt-in
+	ProfileQuestion     string     `gorm:"size:50" json:"-"`                      // preference the chat last asked about
+	ProfileQuestionAt   *time.Time `json:"-"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	User                User       `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// Transport modes. TransportTransit is the legacy spelling of TransportPublic.
+const (
+	TransportCar     = "car"
+	TransportBike    = "bike"
+	TransportWalk    = "walk"
+	TransportPublic  = "public_transport"
+	TransportTransit = "transit"
+)
+
+// Digest frequencies
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+	DigestOff    = "off"
+)
+
+// Digest content types
+const (
+	DigestContentActivities = "new_activities"
+	DigestContentRoutes     = "new_routes"
+)
+
+// DefaultLanguage is the language chat answers are given in unless another is asked for
+const DefaultLanguage = "en"
+
+// Languages are the languages chat answers can be given in, by ISO 639-1 code
+var Languages = map[string]string{
+	"en": "English",
+	"de": "German",
+	"fr": "French",
+	"es": "Spanish",
+	"it": "Italian",
+}
+
+// IsLanguage reports whether chat answers can be given in a language
+func IsLanguage(code string) bool {
+	_, ok := Languages[code]
+	return ok
 }
 
 // TableName returns the table name for UserPreferences
@@ -59,3 +171,54 @@ func (up *UserPreferences) SetLocation(loc Location) {
 func (up *UserPreferences) HasValidLocation() bool {
 	return up.LocationLat != 0 && up.LocationLng != 0
 }
+
+// UsesPublicTransport reports whether the user travels by public transport
+func (up *UserPreferences) UsesPublicTransport() bool {
+	return up.TransportMode == TransportPublic || up.TransportMode == TransportTransit
+}
+
+// Location returns the user's timezone, falling back to UTC for unknown zones
+func (up *UserPreferences) Location() *time.Location {
+	if up.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(up.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// DigestDue reports whether a digest should be sent at now: the user's local
+// hour matches the preferred hour and the frequency interval has elapsed
+func (up *UserPreferences) DigestDue(now time.Time) bool {
+	var interval time.Duration
+	switch up.DigestFrequency {
+	case DigestDaily:
+		interval = 24 * time.Hour
+	case DigestWeekly:
+		interval = 7 * 24 * time.Hour
+	default:
+		return false
+	}
+
+	if now.In(up.Location()).Hour() != up.DigestHour {
+		return false
+	}
+	// Allow an hour of slack so a slightly early run does not skip a period
+	return up.LastDigestAt == nil || now.Sub(*up.LastDigestAt) >= interval-time.Hour
+}
+
+// WantsDigestContent reports whether the user included a content type in their digest.
+// An empty selection means all content types.
+func (up *UserPreferences) WantsDigestContent(contentType string) bool {
+	if len(up.DigestContent) == 0 {
+		return true
+	}
+	for _, c := range up.DigestContent {
+		if c == contentType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+// Package moderation screens uploaded images with an automatic moderation
+// provider, so clearly inappropriate content is rejected before it is published
+// and borderline content reaches moderators first.
+package moderation
+
+import (
+	"context"
+	"sort"
+)
+
+// Screening statuses of an image
+const (
+	// StatusClear images scored below the review threshold
+	StatusClear = "clear"
+	// StatusReview images are borderline, or could not be screened
+	StatusReview = "review"
+	// StatusFlagged images scored at least the reject threshold and were rejected
+	StatusFlagged = "flagged"
+)
+
+// Result is a provider's assessment of an image
+type Result struct {
+	// Categories maps the provider's categories, e.g. "violence", to scores from 0 to 1
+	Categories map[string]float64
+}
+
+// Score returns the highest category score
+func (r *Result) Score() float64 {
+	score := 0.0
+	for _, s := range r.Categories {
+		if s > score {
+			score = s
+		}
+	}
+	return score
+}
+
+// Labels returns the categories scoring at least min, highest first
+func (r *Result) Labels(min float64) []string {
+	var labels []string
+	for category, score := range r.Categories {
+		if score >= min {
+			labels = append(labels, category)
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return r.Categories[labels[i]] > r.Categories[labels[j]]
+	})
+	return labels
+}
+
+// Screener assesses images. contentType is the sniffed MIME type of image.
+type Screener interface {
+	ScreenImage(ctx context.Context, contentType string, image []byte) (*Result, error)
+}
+
+// Thresholds decide the screening status from a score
+type Thresholds struct {
+	Review float64
+	Reject float64
+}
+
+// Status returns the screening status of a score
+func (t Thresholds) Status(score float64) string {
+	switch {
+	case score >= t.Reject:
+		return StatusFlagged
+	case score >= t.Review:
+		return StatusReview
+	}
+	return StatusClear
+}
+
+// StubScreener gives every image the same score, for development without a
+// provider and for trying out the thresholds
+type StubScreener struct {
+	Score float64
+}
+
+// ScreenImage returns the stub score
+func (s StubScreener) ScreenImage(_ context.Context, _ string, _ []byte) (*Result, error) {
+	return &Result{Categories: map[string]float64{"stub": s.Score}}, nil
+}
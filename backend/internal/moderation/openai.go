@@ -0,0 +1,96 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/apperr"
+)
+
+// OpenAIScreener screens images with the OpenAI moderation API. Images are
+// sent inline, so they are screened before they are published anywhere.
+type OpenAIScreener struct {
+	apiKey     string
+	model      string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewOpenAIScreener creates an OpenAI screener. baseURL defaults to the public API.
+func NewOpenAIScreener(apiKey, model, baseURL string) *OpenAIScreener {
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+	return &OpenAIScreener{
+		apiKey:     apiKey,
+		model:      model,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type openAIModerationRequest struct {
+	Model string                  `json:"model"`
+	Input []openAIModerationInput `json:"input"`
+}
+
+type openAIModerationInput struct {
+	Type     string `json:"type"`
+	ImageURL struct {
+		URL string `json:"url"`
+	} `json:"image_url"`
+}
+
+type openAIModerationResponse struct {
+	Results []struct {
+		CategoryScores map[string]float64 `json:"category_scores"`
+	} `json:"results"`
+}
+
+// ScreenImage sends the image as a data URL and returns its category scores
+func (s *OpenAIScreener) ScreenImage(ctx context.Context, contentType string, image []byte) (*Result, error) {
+	input := openAIModerationInput{Type: "image_url"}
+	input.ImageURL.URL = "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(image)
+	payload, err := json.Marshal(openAIModerationRequest{Model: s.model, Input: []openAIModerationInput{input}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode moderation request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/moderations", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("moderation request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		err := fmt.Errorf("openai moderation returned %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return nil, apperr.Wrap(apperr.RateLimited, "moderation is rate limited", err)
+		}
+		return nil, err
+	}
+
+	var body openAIModerationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid moderation response: %w", err)
+	}
+	if len(body.Results) == 0 {
+		return nil, fmt.Errorf("moderation response has no results")
+	}
+	return &Result{Categories: body.Results[0].CategoryScores}, nil
+}
@@ -0,0 +1,193 @@
+// Package pdf writes simple PDF documents: text in the standard Helvetica fonts,
+// lines, rectangles and polylines. It needs no font files or external tools,
+// which is enough for printable itineraries.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// A4 page size in points
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Document is a PDF being built page by page
+type Document struct {
+	title string
+	pages []*Page
+}
+
+// Page is a page of a document. Coordinates are in points from the top-left corner.
+type Page struct {
+	content bytes.Buffer
+}
+
+// New creates an empty document with the given title metadata
+func New(title string) *Document {
+	return &Document{title: title}
+}
+
+// AddPage appends a blank page and returns it
+func (d *Document) AddPage() *Page {
+	page := &Page{}
+	d.pages = append(d.pages, page)
+	return page
+}
+
+// Text draws a line of text with its baseline at y. Characters outside
+// Windows-1252 are replaced with '?'.
+func (p *Page) Text(x, y, size float64, bold bool, text string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&p.content, "BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, PageHeight-y, escape(text))
+}
+
+// Color sets the stroke and fill color of subsequent drawing, components from 0 to 1
+func (p *Page) Color(r, g, b float64) {
+	fmt.Fprintf(&p.content, "%.3f %.3f %.3f RG %.3f %.3f %.3f rg\n", r, g, b, r, g, b)
+}
+
+// Line draws a straight line
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, PageHeight-y1, x2, PageHeight-y2)
+}
+
+// Rect draws the outline of a rectangle whose top-left corner is at x, y
+func (p *Page) Rect(x, y, w, h, width float64) {
+	fmt.Fprintf(&p.content, "%.2f w %.2f %.2f %.2f %.2f re S\n", width, x, PageHeight-y-h, w, h)
+}
+
+// FillRect draws a filled rectangle whose top-left corner is at x, y
+func (p *Page) FillRect(x, y, w, h float64) {
+	fmt.Fprintf(&p.content, "%.2f %.2f %.2f %.2f re f\n", x, PageHeight-y-h, w, h)
+}
+
+// Polyline draws connected line segments through points given as x, y pairs
+func (p *Page) Polyline(points [][2]float64, width float64) {
+	if len(points) < 2 {
+		return
+	}
+	fmt.Fprintf(&p.content, "%.2f w 1 J 1 j %.2f %.2f m", width, points[0][0], PageHeight-points[0][1])
+	for _, point := range points[1:] {
+		fmt.Fprintf(&p.content, " %.2f %.2f l", point[0], PageHeight-point[1])
+	}
+	p.content.WriteString(" S\n")
+}
+
+// TextWidth estimates the width of text in points. Helvetica glyphs average about
+// half the font size; bold ones are slightly wider.
+func TextWidth(text string, size float64, bold bool) float64 {
+	factor := 0.5
+	if bold {
+		factor = 0.55
+	}
+	return float64(utf8.RuneCountInString(text)) * size * factor
+}
+
+// Wrap breaks text into lines that fit width, breaking at spaces where possible
+func Wrap(text string, size, width float64, bold bool) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if TextWidth(candidate, size, bold) <= width {
+				line = candidate
+				continue
+			}
+			if line != "" {
+				lines = append(lines, line)
+			}
+			// Words longer than a line are split
+			for TextWidth(word, size, bold) > width {
+				runes := []rune(word)
+				fit := int(width / TextWidth("m", size, bold))
+				if fit < 1 {
+					fit = 1
+				}
+				lines = append(lines, string(runes[:fit]))
+				word = string(runes[fit:])
+			}
+			line = word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Bytes renders the document
+func (d *Document) Bytes() []byte {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-5 are fixed; each page then adds a page and a content object
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 6+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object(fmt.Sprintf("<< /Title (%s) /Producer (community-chatbot) >>", escape(d.title)))
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			PageWidth, PageHeight, 7+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.content.Len(), page.content.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return out.Bytes()
+}
+
+// escape encodes text as the contents of a PDF string in Windows-1252
+func escape(text string) string {
+	var out strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			out.WriteByte('\\')
+			out.WriteRune(r)
+		case r == '\n' || r == '\t':
+			out.WriteByte(' ')
+		case r >= 0x20 && r < 0x7f:
+			out.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&out, "\\%03o", r)
+		default:
+			if b, ok := winAnsi[r]; ok {
+				fmt.Fprintf(&out, "\\%03o", b)
+			} else {
+				out.WriteByte('?')
+			}
+		}
+	}
+	return out.String()
+}
+
+// winAnsi maps the Windows-1252 characters outside Latin-1 that itineraries use
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
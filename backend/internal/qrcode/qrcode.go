@@ -0,0 +1,455 @@
+// Package qrcode encodes short texts such as links as QR codes and renders them
+// as PNG images. It supports byte mode at error correction level M (15%
+// recovery) up to version 10, which fits 213 bytes: plenty for links on
+// printed signs, without an external dependency.
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// quietZone is the light border around a code, in modules
+const quietZone = 4
+
+// ErrTooLong is returned for texts that do not fit the largest supported version
+var ErrTooLong = errors.New("text too long for a QR code")
+
+// version describes the error correction blocks of a version at level M
+type version struct {
+	ecPerBlock int
+	blocks     []int // data codewords of each block
+	alignment  []int // centre coordinates of alignment patterns
+}
+
+// versions lists versions 1 to 10 at error correction level M
+var versions = []version{
+	{10, []int{16}, nil},
+	{16, []int{28}, []int{6, 18}},
+	{26, []int{44}, []int{6, 22}},
+	{18, []int{32, 32}, []int{6, 26}},
+	{24, []int{43, 43}, []int{6, 30}},
+	{16, []int{27, 27, 27, 27}, []int{6, 34}},
+	{18, []int{31, 31, 31, 31}, []int{6, 22, 38}},
+	{22, []int{38, 38, 39, 39}, []int{6, 24, 42}},
+	{22, []int{36, 36, 36, 37, 37}, []int{6, 26, 46}},
+	{26, []int{43, 43, 43, 43, 44}, []int{6, 28, 50}},
+}
+
+// Code is an encoded QR code: a square of dark (true) and light modules,
+// without the quiet zone
+type Code struct {
+	Size    int
+	modules [][]bool
+}
+
+// Dark reports whether the module at column x and row y is dark
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// Encode encodes text in the smallest version it fits
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+	for i, v := range versions {
+		number := i + 1
+		countBits := 8
+		if number >= 10 {
+			countBits = 16
+		}
+		capacity := 0
+		for _, n := range v.blocks {
+			capacity += n
+		}
+		if 4+countBits+8*len(data) > 8*capacity {
+			continue
+		}
+		codewords := interleave(v, encodeData(data, countBits, capacity))
+		return build(number, v, codewords), nil
+	}
+	return nil, ErrTooLong
+}
+
+// PNG renders text as a black on white QR code with a quiet zone, scaled to at
+// most size pixels wide; codes are never scaled below one pixel per module
+func PNG(text string, size int) ([]byte, error) {
+	code, err := Encode(text)
+	if err != nil {
+		return nil, err
+	}
+	total := code.Size + 2*quietZone
+	scale := size / total
+	if scale < 1 {
+		scale = 1
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, total*scale, total*scale), color.Palette{color.White, color.Black})
+	for y := 0; y < code.Size; y++ {
+		for x := 0; x < code.Size; x++ {
+			if !code.Dark(x, y) {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex((x+quietZone)*scale+dx, (y+quietZone)*scale+dy, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeData returns the data codewords of a byte mode segment, padded to capacity
+func encodeData(data []byte, countBits, capacity int) []byte {
+	var bits bitBuffer
+	bits.append(0b0100, 4)
+	bits.append(len(data), countBits)
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	terminator := min(4, 8*capacity-len(bits))
+	bits.append(0, terminator)
+	bits.append(0, (8-len(bits)%8)%8)
+
+	codewords := bits.bytes()
+	for pad := byte(0xEC); len(codewords) < capacity; pad ^= 0xEC ^ 0x11 {
+		codewords = append(codewords, pad)
+	}
+	return codewords
+}
+
+// interleave splits data into the version's blocks, adds error correction to
+// each and interleaves the codewords of all blocks
+func interleave(v version, data []byte) []byte {
+	generator := rsGenerator(v.ecPerBlock)
+	blocks := make([][]byte, len(v.blocks))
+	ecBlocks := make([][]byte, len(v.blocks))
+	offset := 0
+	for i, n := range v.blocks {
+		blocks[i] = data[offset : offset+n]
+		ecBlocks[i] = rsRemainder(blocks[i], generator)
+		offset += n
+	}
+
+	var result []byte
+	longest := v.blocks[len(v.blocks)-1]
+	for i := 0; i < longest; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < v.ecPerBlock; i++ {
+		for _, ec := range ecBlocks {
+			result = append(result, ec[i])
+		}
+	}
+	return result
+}
+
+// build places codewords in a code of the version with the mask that scores best
+func build(number int, v version, codewords []byte) *Code {
+	size := 17 + 4*number
+	m := newMatrix(size)
+	m.drawFunctionPatterns(number, v)
+	m.drawCodewords(codewords)
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		m.applyMask(mask)
+		m.drawFormat(mask)
+		if penalty := m.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		m.applyMask(mask)
+	}
+	m.applyMask(best)
+	m.drawFormat(best)
+	return &Code{Size: size, modules: m.modules}
+}
+
+// matrix is a code being built; function modules are not touched by data or masks
+type matrix struct {
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+func newMatrix(size int) *matrix {
+	m := &matrix{size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for y := range m.modules {
+		m.modules[y] = make([]bool, size)
+		m.function[y] = make([]bool, size)
+	}
+	return m
+}
+
+// set sets a function module
+func (m *matrix) set(x, y int, dark bool) {
+	m.modules[y][x] = dark
+	m.function[y][x] = true
+}
+
+// drawFunctionPatterns draws the finder, timing and alignment patterns and
+// version information, and reserves the format information areas
+func (m *matrix) drawFunctionPatterns(number int, v version) {
+	for i := 0; i < m.size; i++ {
+		m.set(6, i, i%2 == 0)
+		m.set(i, 6, i%2 == 0)
+	}
+
+	m.drawFinder(3, 3)
+	m.drawFinder(m.size-4, 3)
+	m.drawFinder(3, m.size-4)
+
+	last := len(v.alignment) - 1
+	for i, x := range v.alignment {
+		for j, y := range v.alignment {
+			// Alignment patterns would overlap the finders in three corners
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					m.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	m.drawFormat(0)
+	if number >= 7 {
+		bits := number<<12 | bchRemainder(number, 0x1F25, 12)
+		for i := 0; i < 18; i++ {
+			dark := bits>>i&1 == 1
+			a, b := m.size-11+i%3, i/3
+			m.set(a, b, dark)
+			m.set(b, a, dark)
+		}
+	}
+}
+
+// drawFinder draws a finder pattern and its separator centred on x, y
+func (m *matrix) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= m.size || yy < 0 || yy >= m.size {
+				continue
+			}
+			distance := max(abs(dx), abs(dy))
+			m.set(xx, yy, distance != 2 && distance != 4)
+		}
+	}
+}
+
+// drawFormat draws both copies of the format information for level M and a mask
+func (m *matrix) drawFormat(mask int) {
+	// Level M is 00, so the data bits are the mask alone
+	bits := (mask<<10 | bchRemainder(mask, 0x537, 10)) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		m.set(8, i, bit(i))
+	}
+	m.set(8, 7, bit(6))
+	m.set(8, 8, bit(7))
+	m.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		m.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		m.set(m.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		m.set(8, m.size-15+i, bit(i))
+	}
+	m.set(8, m.size-8, true)
+}
+
+// drawCodewords places codewords in the zigzag order of two-module columns
+// from the bottom right; remainder modules stay light
+func (m *matrix) drawCodewords(codewords []byte) {
+	i := 0
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			// Skip the vertical timing pattern
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < m.size; vert++ {
+			y := vert
+			if upward {
+				y = m.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if m.function[y][x] || i >= len(codewords)*8 {
+					continue
+				}
+				m.modules[y][x] = codewords[i>>3]>>(7-i&7)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by a mask; applying it twice undoes it
+func (m *matrix) applyMask(mask int) {
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			if m.function[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				m.modules[y][x] = !m.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code is to read: long runs, blocks, finder-like
+// patterns and an unbalanced share of dark modules
+func (m *matrix) penalty() int {
+	penalty := 0
+	finderLike := []bool{true, false, true, true, true, false, true}
+	for i := 0; i < m.size; i++ {
+		row := make([]bool, m.size)
+		column := make([]bool, m.size)
+		for j := 0; j < m.size; j++ {
+			row[j] = m.modules[i][j]
+			column[j] = m.modules[j][i]
+		}
+		for _, line := range [][]bool{row, column} {
+			penalty += runPenalty(line)
+			penalty += 40 * patternCount(line, finderLike)
+		}
+	}
+
+	dark := 0
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			if m.modules[y][x] {
+				dark++
+			}
+			if x < m.size-1 && y < m.size-1 {
+				c := m.modules[y][x]
+				if c == m.modules[y][x+1] && c == m.modules[y+1][x] && c == m.modules[y+1][x+1] {
+					penalty += 3
+				}
+			}
+		}
+	}
+	percent := dark * 100 / (m.size * m.size)
+	penalty += abs(percent-50) / 5 * 10
+	return penalty
+}
+
+// runPenalty scores runs of five or more modules of the same colour
+func runPenalty(line []bool) int {
+	penalty, run := 0, 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			penalty += run - 2
+		}
+		run = 1
+	}
+	return penalty
+}
+
+// patternCount counts occurrences of pattern with four light modules (or the
+// edge of the code) on at least one side
+func patternCount(line, pattern []bool) int {
+	light := func(from, to int) bool {
+		for i := from; i < to; i++ {
+			if i >= 0 && i < len(line) && line[i] {
+				return false
+			}
+		}
+		return true
+	}
+	count := 0
+	for start := 0; start+len(pattern) <= len(line); start++ {
+		matches := true
+		for i, dark := range pattern {
+			if line[start+i] != dark {
+				matches = false
+				break
+			}
+		}
+		end := start + len(pattern)
+		if matches && (light(start-4, start) || light(end, end+4)) {
+			count++
+		}
+	}
+	return count
+}
+
+// bchRemainder returns the BCH error correction bits of data for a generator
+// polynomial of the given degree
+func bchRemainder(data, generator, degree int) int {
+	rem := data
+	for i := 0; i < degree; i++ {
+		rem = rem<<1 ^ (rem>>(degree-1))*generator
+	}
+	return rem & (1<<degree - 1)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// bitBuffer collects bits most significant first
+type bitBuffer []bool
+
+func (b *bitBuffer) append(value, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*b = append(*b, value>>i&1 == 1)
+	}
+}
+
+func (b bitBuffer) bytes() []byte {
+	result := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			result[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return result
+}
@@ -0,0 +1,45 @@
+package qrcode
+
+// gfMultiply multiplies in GF(256) with the QR code polynomial x^8+x^4+x^3+x^2+1
+func gfMultiply(a, b byte) byte {
+	var product byte
+	for i := 7; i >= 0; i-- {
+		carry := product >> 7
+		product <<= 1
+		product ^= carry * 0x1D
+		product ^= (b >> i & 1) * a
+	}
+	return product
+}
+
+// rsGenerator returns the coefficients of the Reed-Solomon generator polynomial
+// of a degree, highest power first, without the leading 1
+func rsGenerator(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := 0; j < degree; j++ {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < degree {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder returns the error correction codewords of data
+func rsRemainder(data, generator []byte) []byte {
+	result := make([]byte, len(generator))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, coefficient := range generator {
+			result[i] ^= gfMultiply(coefficient, factor)
+		}
+	}
+	return result
+}
@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memorySweepInterval is how often full buckets are dropped from memory
+const memorySweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+type counter struct {
+	count     int64
+	expiresAt time.Time
+}
+
+// MemoryStore keeps buckets and counters in process memory; limits are per replica
+type MemoryStore struct {
+	buckets   map[string]*bucket
+	counters  map[string]*counter
+	lastSweep time.Time
+	mutex     sync.Mutex
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   make(map[string]*bucket),
+		counters:  make(map[string]*counter),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket for key
+func (s *MemoryStore) Allow(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+
+	var result Result
+	b.tokens, result = take(b.tokens, b.last, now, limit)
+	b.last = now
+	b.limit = limit
+	return result, nil
+}
+
+// Increment adds one to the counter for key
+func (s *MemoryStore) Increment(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.expiresAt) {
+		c = &counter{expiresAt: now.Add(ttl)}
+		s.counters[key] = c
+	}
+	c.count++
+	return c.count, nil
+}
+
+// sweep drops buckets that have refilled completely, which behave exactly like
+// new buckets, and expired counters, so idle clients do not accumulate in memory
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate >= float64(b.limit.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+	for key, c := range s.counters {
+		if !now.Before(c.expiresAt) {
+			delete(s.counters, key)
+		}
+	}
+}
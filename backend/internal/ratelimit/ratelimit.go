@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit is a token bucket: Burst requests at once, refilled at Rate per second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until a token is available when the request was denied
+	RetryAfter time.Duration
+	// ResetAfter is how long until the bucket is full again
+	ResetAfter time.Duration
+}
+
+// Store keeps token buckets by key
+type Store interface {
+	// Allow takes one token from the bucket for key if one is available
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Counter keeps fixed-window counters by key, e.g. daily message quotas
+type Counter interface {
+	// Increment adds one to the counter for key and returns the new count. A new
+	// counter expires after ttl.
+	Increment(ctx context.Context, key string, ttl time.Duration) (int64, error)
+}
+
+// NewCounter returns a Redis counter when redisURL is set, so replicas share
+// counts, otherwise an in-memory counter
+func NewCounter(redisURL string) (Counter, error) {
+	if redisURL != "" {
+		return NewRedisStore(redisURL)
+	}
+	return NewMemoryStore(), nil
+}
+
+// NewStore returns a Redis store when redisURL is set, so replicas share limits,
+// otherwise an in-memory store
+func NewStore(redisURL string) (Store, error) {
+	if redisURL != "" {
+		return NewRedisStore(redisURL)
+	}
+	return NewMemoryStore(), nil
+}
+
+// take applies the token bucket algorithm to a bucket holding tokens that was last
+// updated at last, returning the new token count and the result
+func take(tokens float64, last, now time.Time, limit Limit) (float64, Result) {
+	burst := float64(limit.Burst)
+	tokens = math.Min(burst, tokens+now.Sub(last).Seconds()*limit.Rate)
+
+	result := Result{Limit: limit.Burst}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - tokens) / limit.Rate)
+	}
+	result.Remaining = int(tokens)
+	result.ResetAfter = secondsToDuration((burst - tokens) / limit.Rate)
+	return tokens, result
+}
+
+// secondsToDuration converts fractional seconds to a duration
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript atomically refills and takes from a bucket stored as a hash of tokens
+// and last update (milliseconds). It returns the remaining tokens as a string and
+// whether the request was allowed. Buckets expire once they would be full again.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "last")
+local tokens = tonumber(state[1]) or burst
+local last = tonumber(state[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - last) / 1000 * rate)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "last", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+return {tostring(tokens), allowed}`)
+
+// incrementScript atomically increments a counter and sets its expiry (milliseconds)
+// when it is created
+var incrementScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count`)
+
+// RedisStore keeps buckets and counters in Redis so all replicas share the same limits
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore connects to the Redis instance at redisURL
+func NewRedisStore(redisURL string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	return &RedisStore{client: redis.NewClient(opts)}, nil
+}
+
+// Allow takes a token from the bucket for key
+func (s *RedisStore) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	reply, err := takeScript.Run(ctx, s.client, []string{"ratelimit:" + key},
+		limit.Rate, limit.Burst, time.Now().UnixMilli()).Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit script failed: %w", err)
+	}
+	if len(reply) != 2 {
+		return Result{}, fmt.Errorf("unexpected rate limit reply: %v", reply)
+	}
+
+	text, _ := reply[0].(string)
+	tokens, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("invalid token count %q: %w", text, err)
+	}
+	allowed, _ := reply[1].(int64)
+
+	result := Result{
+		Allowed:    allowed == 1,
+		Limit:      limit.Burst,
+		Remaining:  int(math.Floor(tokens)),
+		ResetAfter: secondsToDuration((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !result.Allowed {
+		result.RetryAfter = secondsToDuration((1 - tokens) / limit.Rate)
+	}
+	return result, nil
+}
+
+// Increment adds one to the counter for key
+func (s *RedisStore) Increment(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	count, err := incrementScript.Run(ctx, s.client, []string{"counter:" + key}, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("counter script failed: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,62 @@
+package residency
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Pool is a gorm connection pool sending each statement to the database of
+// the region its context is bound to. Unbound contexts use the default
+// database. Transactions are begun in the region of their context and stay
+// there, so a transaction cannot join data across regions.
+type Pool struct {
+	defaultDB *sql.DB
+	regions   map[string]*sql.DB
+}
+
+// NewPool creates a pool over the default database and the regional ones
+func NewPool(defaultDB *sql.DB, regions map[string]*sql.DB) *Pool {
+	return &Pool{
+		defaultDB: defaultDB,
+		regions:   regions,
+	}
+}
+
+// DB returns the database of the region ctx is bound to
+func (p *Pool) DB(ctx context.Context) *sql.DB {
+	if db, ok := p.regions[Region(ctx)]; ok {
+		return db
+	}
+	return p.defaultDB
+}
+
+// PrepareContext implements gorm.ConnPool
+func (p *Pool) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return p.DB(ctx).PrepareContext(ctx, query)
+}
+
+// ExecContext implements gorm.ConnPool
+func (p *Pool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return p.DB(ctx).ExecContext(ctx, query, args...)
+}
+
+// QueryContext implements gorm.ConnPool
+func (p *Pool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return p.DB(ctx).QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext implements gorm.ConnPool
+func (p *Pool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return p.DB(ctx).QueryRowContext(ctx, query, args...)
+}
+
+// BeginTx implements gorm.TxBeginner
+func (p *Pool) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return p.DB(ctx).BeginTx(ctx, opts)
+}
+
+// GetDBConn implements gorm.GetDBConnector; it returns the default database,
+// e.g. for health checks
+func (p *Pool) GetDBConn() (*sql.DB, error) {
+	return p.defaultDB, nil
+}
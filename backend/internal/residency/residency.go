@@ -0,0 +1,108 @@
+// Package residency keeps the data of each community in the region it has to
+// be stored in. Requests are bound to the region of their community through
+// the context, and the database pool and image storage route by that region,
+// so services keep using a single *gorm.DB and uploader.
+package residency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	// ErrUnknownCommunity is returned for communities that are not routed to a region
+	ErrUnknownCommunity = errors.New("unknown community")
+	// ErrCrossRegion is returned when a context bound to one region is bound to
+	// another, which would join data across regions
+	ErrCrossRegion = errors.New("data of another region cannot be accessed")
+)
+
+// regionKey is the context key of the bound region
+type regionKey struct{}
+
+// Router maps communities to regions
+type Router struct {
+	defaultRegion string
+	regions       []string
+	communities   map[string]string
+}
+
+// NewRouter creates a router. Requests without a community are served from
+// defaultRegion; communities must map to it or one of regions.
+func NewRouter(defaultRegion string, regions []string, communities map[string]string) (*Router, error) {
+	known := map[string]bool{defaultRegion: true}
+	for _, region := range regions {
+		if known[region] {
+			return nil, fmt.Errorf("region %q is configured twice", region)
+		}
+		known[region] = true
+	}
+	for community, region := range communities {
+		if !known[region] {
+			return nil, fmt.Errorf("community %q is routed to unknown region %q", community, region)
+		}
+	}
+
+	sorted := append([]string(nil), regions...)
+	sort.Strings(sorted)
+	return &Router{
+		defaultRegion: defaultRegion,
+		regions:       append([]string{defaultRegion}, sorted...),
+		communities:   communities,
+	}, nil
+}
+
+// Regions returns all regions, the default one first
+func (r *Router) Regions() []string {
+	return r.regions
+}
+
+// Contexts returns ctx bound to each region, the default one first, for
+// background jobs and migrations that process the data of every region
+func (r *Router) Contexts(ctx context.Context) []context.Context {
+	contexts := make([]context.Context, len(r.regions))
+	for i, region := range r.regions {
+		contexts[i] = context.WithValue(ctx, regionKey{}, region)
+	}
+	return contexts
+}
+
+// Bind binds ctx to the region of community, or to the default region when
+// community is empty
+func (r *Router) Bind(ctx context.Context, community string) (context.Context, error) {
+	region := r.defaultRegion
+	if community != "" {
+		var ok bool
+		if region, ok = r.communities[community]; !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownCommunity, community)
+		}
+	}
+	return WithRegion(ctx, region)
+}
+
+// WithRegion binds ctx to region. Contexts already bound to another region
+// return ErrCrossRegion.
+func WithRegion(ctx context.Context, region string) (context.Context, error) {
+	if bound, ok := ctx.Value(regionKey{}).(string); ok && bound != region {
+		return nil, fmt.Errorf("%w: bound to %s, not %s", ErrCrossRegion, bound, region)
+	}
+	return context.WithValue(ctx, regionKey{}, region), nil
+}
+
+// Region returns the region ctx is bound to, or "" for unbound contexts
+func Region(ctx context.Context) string {
+	region, _ := ctx.Value(regionKey{}).(string)
+	return region
+}
+
+// Detach returns a background context bound to the region of ctx, for work
+// that outlives a request, such as streamed chat answers
+func Detach(ctx context.Context) context.Context {
+	background := context.Background()
+	if region := Region(ctx); region != "" {
+		background = context.WithValue(background, regionKey{}, region)
+	}
+	return background
+}
@@ -0,0 +1,31 @@
+package residency
+
+import (
+	"context"
+	"io"
+
+	"community-chatbot/internal/storage"
+)
+
+// Uploader stores files with the uploader of the region the context is bound
+// to, so images stay in their community's region
+type Uploader struct {
+	defaultUploader storage.Uploader
+	regions         map[string]storage.Uploader
+}
+
+// NewUploader creates an uploader over the default uploader and the regional ones
+func NewUploader(defaultUploader storage.Uploader, regions map[string]storage.Uploader) *Uploader {
+	return &Uploader{
+		defaultUploader: defaultUploader,
+		regions:         regions,
+	}
+}
+
+// Upload implements storage.Uploader
+func (u *Uploader) Upload(ctx context.Context, name string, file io.Reader) (string, error) {
+	if uploader, ok := u.regions[Region(ctx)]; ok {
+		return uploader.Upload(ctx, name, file)
+	}
+	return u.defaultUploader.Upload(ctx, name, file)
+}
@@ -0,0 +1,116 @@
+// Package scheduler runs periodic maintenance jobs, such as deleting expired
+// sessions, on one replica at a time.
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"community-chatbot/internal/lock"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var jobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "scheduler_job_runs_total",
+	Help: "Scheduled job runs by job and result (success, error, skipped while another replica ran it).",
+}, []string{"job", "result"})
+
+var jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "scheduler_job_duration_seconds",
+	Help:    "How long scheduled jobs ran.",
+	Buckets: []float64{.01, .1, .5, 1, 5, 15, 60, 300},
+}, []string{"job"})
+
+// Job is a task run every Interval
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// Local jobs maintain in-memory state and run on every replica; the others
+	// hold a lock while running, so replicas take turns
+	Local bool
+	Run   func(ctx context.Context) error
+}
+
+// Scheduler runs jobs periodically
+type Scheduler struct {
+	locker lock.Locker
+	jobs   []Job
+	mutex  sync.Mutex
+}
+
+// New creates a scheduler sharing job locks with other replicas through locker
+func New(locker lock.Locker) *Scheduler {
+	return &Scheduler{
+		locker: locker,
+	}
+}
+
+// Add schedules a job. Jobs without a positive interval are disabled.
+func (s *Scheduler) Add(job Job) {
+	if job.Interval <= 0 {
+		log.Printf("[SCHEDULER] Job %s is disabled", job.Name)
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.jobs = append(s.jobs, job)
+}
+
+// Start runs each job every interval, the first time after one interval, until
+// ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mutex.Lock()
+	jobs := append([]Job(nil), s.jobs...)
+	s.mutex.Unlock()
+
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		log.Printf("[SCHEDULER] Running %s every %s", job.Name, job.Interval)
+		wg.Add(1)
+		go func(job Job) {
+			defer wg.Done()
+			ticker := time.NewTicker(job.Interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					s.run(ctx, job)
+				}
+			}
+		}(job)
+	}
+	wg.Wait()
+}
+
+// run runs a job once, under its lock unless it is local
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	start := time.Now()
+	var err error
+	ran := true
+	if job.Local {
+		err = job.Run(ctx)
+	} else {
+		ran, err = lock.RunExclusive(ctx, s.locker, "scheduler:"+job.Name, job.Run)
+	}
+
+	switch {
+	case err != nil:
+		jobRuns.WithLabelValues(job.Name, "error").Inc()
+		if ctx.Err() == nil {
+			log.Printf("[SCHEDULER] Job %s failed: %v", job.Name, err)
+		}
+	case !ran:
+		jobRuns.WithLabelValues(job.Name, "skipped").Inc()
+		return
+	default:
+		jobRuns.WithLabelValues(job.Name, "success").Inc()
+	}
+	jobDuration.WithLabelValues(job.Name).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// AbuseSignalService records suspected abuse for admins to review
+type AbuseSignalService struct {
+	db *gorm.DB
+}
+
+// NewAbuseSignalService creates a new abuse signal service
+func NewAbuseSignalService(db *gorm.DB) *AbuseSignalService {
+	return &AbuseSignalService{
+		db: db,
+	}
+}
+
+// Record stores an abuse signal
+func (s *AbuseSignalService) Record(ctx context.Context, signal models.AbuseSignal) error {
+	if err := s.db.WithContext(ctx).Create(&signal).Error; err != nil {
+		return fmt.Errorf("failed to record %s signal: %w", signal.Kind, err)
+	}
+	return nil
+}
+
+// List returns a page of abuse signals, newest first, optionally of one kind
+func (s *AbuseSignalService) List(ctx context.Context, kind string, page, pageSize int) ([]models.AbuseSignal, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.AbuseSignal{})
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count abuse signals: %w", err)
+	}
+
+	var signals []models.AbuseSignal
+	if err := query.Order("created_at DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&signals).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list abuse signals: %w", err)
+	}
+	return signals, total, nil
+}
@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrEmailUnchanged is returned when changing the email to the current one
+	ErrEmailUnchanged = apperr.New(apperr.Invalid, "email is already the current one")
+	// ErrInvalidEmailChange is returned for unknown, expired and already confirmed email changes alike
+	ErrInvalidEmailChange = apperr.New(apperr.Invalid, "invalid or expired email confirmation")
+	// ErrWrongPassword is returned when the current password given to confirm a change is wrong
+	ErrWrongPassword = apperr.New(apperr.Forbidden, "current password is wrong")
+	// ErrNoPassword is returned when an account without a password, signed up
+	// with OAuth, asks for a change that needs the current password
+	ErrNoPassword = apperr.New(apperr.Forbidden, "account has no password; set one with a password reset first")
+)
+
+// EmailChangeSender delivers the link confirming a new email address to that
+// address, and warns the current address of the change
+type EmailChangeSender interface {
+	SendEmailChange(ctx context.Context, user models.User, email, confirmURL string, expiresAt time.Time) error
+	SendEmailChangeNotice(ctx context.Context, user models.User, email string) error
+}
+
+// AccountService lets users manage their own account: profile, email address
+// and deletion
+type AccountService struct {
+	db         *gorm.DB
+	sender     EmailChangeSender
+	confirmURL string
+	ttl        time.Duration
+}
+
+// NewAccountService creates a new account service. Confirmation links are
+// confirmURL with the token appended as the token query parameter and expire
+// after ttl.
+func NewAccountService(db *gorm.DB, sender EmailChangeSender, confirmURL string, ttl time.Duration) *AccountService {
+	return &AccountService{
+		db:         db,
+		sender:     sender,
+		confirmURL: confirmURL,
+		ttl:        ttl,
+	}
+}
+
+// User returns the account of a user
+func (s *AccountService) User(ctx context.Context, userID uint) (*models.User, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).First(&user, userID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load user %d: %w", userID, err)
+	}
+	return &user, nil
+}
+
+// UpdateProfile changes the display name of a user
+func (s *AccountService) UpdateProfile(ctx context.Context, userID uint, name string) (*models.User, error) {
+	user, err := s.User(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Model(user).Update("name", name).Error; err != nil {
+		return nil, fmt.Errorf("failed to update profile of user %d: %w", userID, err)
+	}
+	return user, nil
+}
+
+// RequestEmailChange sends a confirmation link to the new address and a notice
+// to the current one; the email only changes when it is confirmed. The current
+// password is required, so a stolen access token alone cannot take over the
+// account. A new request replaces pending ones.
+func (s *AccountService) RequestEmailChange(ctx context.Context, userID uint, email, currentPassword string) (time.Time, error) {
+	email = normalizeEmail(email)
+	user, err := s.User(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if user.PasswordHash == "" {
+		return time.Time{}, ErrNoPassword
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(currentPassword)) != nil {
+		return time.Time{}, ErrWrongPassword
+	}
+	if user.Email == email {
+		return time.Time{}, ErrEmailUnchanged
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return time.Time{}, fmt.Errorf("failed to generate email confirmation token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+	change := models.EmailChange{
+		UserID:    userID,
+		Email:     email,
+		TokenHash: hashInvitationToken(token),
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkEmailFree(tx, email); err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND confirmed_at IS NULL", userID).Delete(&models.EmailChange{}).Error; err != nil {
+			return fmt.Errorf("failed to replace email changes of user %d: %w", userID, err)
+		}
+		if err := tx.Create(&change).Error; err != nil {
+			return fmt.Errorf("failed to create email change for user %d: %w", userID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	confirmURL := s.confirmURL + "?token=" + url.QueryEscape(token)
+	if err := s.sender.SendEmailChange(ctx, *user, email, confirmURL, change.ExpiresAt); err != nil {
+		return time.Time{}, fmt.Errorf("failed to send email confirmation to user %d: %w", userID, err)
+	}
+	if err := s.sender.SendEmailChangeNotice(ctx, *user, email); err != nil {
+		return time.Time{}, fmt.Errorf("failed to send email change notice to user %d: %w", userID, err)
+	}
+	return change.ExpiresAt, nil
+}
+
+// ConfirmEmailChange applies the email change a token was sent for. Each token
+// can be used once; the address must still be free.
+func (s *AccountService) ConfirmEmailChange(ctx context.Context, token string) (*models.User, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var change models.EmailChange
+		err := tx.Where("token_hash = ? AND confirmed_at IS NULL AND expires_at > ?", hashInvitationToken(token), time.Now()).
+			First(&change).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidEmailChange
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load email change: %w", err)
+		}
+
+		if err := tx.First(&user, change.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidEmailChange
+			}
+			return fmt.Errorf("failed to load user %d: %w", change.UserID, err)
+		}
+		if err := checkEmailFree(tx, change.Email); err != nil {
+			return err
+		}
+		// The link reached the new address, so it is verified
+		now := time.Now()
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"email":             change.Email,
+			"email_verified_at": now,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to change email of user %d: %w", user.ID, err)
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.EmailVerification{}).Error; err != nil {
+			return fmt.Errorf("failed to delete verifications of user %d: %w", user.ID, err)
+		}
+		if err := tx.Model(&change).Update("confirmed_at", time.Now()).Error; err != nil {
+			return fmt.Errorf("failed to confirm email change %d: %w", change.ID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Delete soft-deletes a user's account. Their conversations are kept without
+// anything identifying them, activities they submitted stay published without
+// a submitter, and their personal data, favorites, collections, notifications
+// and API keys are removed. The email address is released for new accounts.
+func (s *AccountService) Delete(ctx context.Context, userID uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return fmt.Errorf("failed to load user %d: %w", userID, err)
+		}
+
+		// UpdateColumns keeps updated_at, which orders conversations and marks stale activities
+		if err := tx.Unscoped().Model(&models.Conversation{}).Where("user_id = ?", userID).
+			UpdateColumns(map[string]interface{}{"user_id": nil, "session_id": "", "client_ip": ""}).Error; err != nil {
+			return fmt.Errorf("failed to anonymize conversations of user %d: %w", userID, err)
+		}
+		if err := tx.Model(&models.Itinerary{}).Where("user_id = ?", userID).
+			Update("user_id", nil).Error; err != nil {
+			return fmt.Errorf("failed to anonymize itineraries of user %d: %w", userID, err)
+		}
+		if err := tx.Model(&models.LLMUsage{}).Where("user_id = ?", userID).
+			Update("user_id", nil).Error; err != nil {
+			return fmt.Errorf("failed to anonymize usage of user %d: %w", userID, err)
+		}
+		if err := tx.Unscoped().Model(&models.Activity{}).Where("user_id = ?", userID).
+			UpdateColumn("user_id", 0).Error; err != nil {
+			return fmt.Errorf("failed to unlink activities of user %d: %w", userID, err)
+		}
+		if err := tx.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return fmt.Errorf("failed to revoke API keys of user %d: %w", userID, err)
+		}
+
+		if err := deleteUserCollections(tx, userID); err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{
+			&models.UserPreferences{},
+			&models.LocationHistory{},
+			&models.Consent{},
+			&models.Favorite{},
+			&models.SavedSearch{},
+			&models.Notification{},
+			&models.Invitation{},
+			&models.EmailChange{},
+			&models.OAuthIdentity{},
+			&models.EmailVerification{},
+			&models.PasswordReset{},
+			&models.AbuseSignal{},
+		} {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return fmt.Errorf("failed to delete data of user %d: %w", userID, err)
+			}
+		}
+
+		// The unique email index also covers deleted users
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"email":         fmt.Sprintf("deleted-%d@invalid", userID),
+			"name":          "",
+			"password_hash": "",
+		}).Error; err != nil {
+			return fmt.Errorf("failed to anonymize user %d: %w", userID, err)
+		}
+		if err := tx.Delete(&user).Error; err != nil {
+			return fmt.Errorf("failed to delete user %d: %w", userID, err)
+		}
+		return nil
+	})
+}
+
+// checkEmailFree returns ErrEmailTaken when another account has the email
+func checkEmailFree(tx *gorm.DB, email string) error {
+	var count int64
+	if err := tx.Model(&models.User{}).Where("email = ?", email).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check email: %w", err)
+	}
+	if count > 0 {
+		return ErrEmailTaken
+	}
+	return nil
+}
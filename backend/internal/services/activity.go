@@ -0,0 +1,426 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/cache"
+	"community-chatbot/internal/geo"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/residency"
+	"community-chatbot/internal/weather"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotOwner is returned when a user changes an activity they did not submit
+var ErrNotOwner = apperr.New(apperr.Forbidden, "activity belongs to another user")
+
+// ActivityService contains activity business logic
+type ActivityService struct {
+	db *gorm.DB
+	// nearby caches Nearby results; it is invalidated whenever an activity changes
+	nearby *cache.Cache
+	// trust publishes submissions of trusted users without pre-moderation
+	trust *TrustService
+
+	// postgis is detected on first use of Nearby
+	postgisOnce sync.Once
+	postgis     bool
+}
+
+// NewActivityService creates a new activity service; nearby may be nil to
+// disable caching of nearby searches, trust to pre-moderate every submission
+func NewActivityService(db *gorm.DB, nearby *cache.Cache, trust *TrustService) *ActivityService {
+	return &ActivityService{
+		db:     db,
+		nearby: nearby,
+		trust:  trust,
+	}
+}
+
+// invalidateNearby drops the cached nearby searches after an activity changed.
+// Failures are only logged; the entries expire after the cache TTL.
+func (s *ActivityService) invalidateNearby(ctx context.Context) {
+	if err := s.nearby.InvalidateAll(ctx); err != nil {
+		log.Printf("[ACTIVITIES] Invalidate nearby searches: %v", err)
+	}
+}
+
+// MarkVerified records that the activity data was confirmed now, e.g. by a moderator
+// or by a recent condition report, which clears the outdated flag in responses
+func (s *ActivityService) MarkVerified(ctx context.Context, id uint) error {
+	result := s.db.WithContext(ctx).Model(&models.Activity{}).
+		Where("id = ?", id).
+		Update("last_verified_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to mark activity %d verified: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to mark activity %d verified: %w", id, gorm.ErrRecordNotFound)
+	}
+	// Moderators approve activities through here, which adds them to nearby searches
+	s.invalidateNearby(ctx)
+	return nil
+}
+
+// ExistingIDs reports which of the given IDs belong to approved, non-deleted activities
+func (s *ActivityService) ExistingIDs(ctx context.Context, ids []uint) (map[uint]bool, error) {
+	var found []uint
+	if err := s.db.WithContext(ctx).Model(&models.Activity{}).
+		Where("id IN ? AND approved = ?", ids, true).
+		Pluck("id", &found).Error; err != nil {
+		return nil, fmt.Errorf("failed to check activity ids: %w", err)
+	}
+
+	existing := make(map[uint]bool, len(found))
+	for _, id := range found {
+		existing[id] = true
+	}
+	return existing, nil
+}
+
+// ActivityFilters narrows activity listings
+type ActivityFilters struct {
+	Category   string
+	Difficulty string
+	// IncludePending also lists unapproved activities; for moderators only
+	IncludePending bool
+	// Sort is newest (default) or popular
+	Sort string
+}
+
+// ErrInvalidActivitySort is returned for sort orders other than newest and popular
+var ErrInvalidActivitySort = apperr.New(apperr.Invalid, "sort must be newest or popular")
+
+// List returns a page of activities matching the filters and the total match count
+func (s *ActivityService) List(ctx context.Context, filters ActivityFilters, page, pageSize int) ([]models.Activity, int64, error) {
+	query, err := s.filtered(ctx, filters)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count activities: %w", err)
+	}
+
+	order := "created_at DESC"
+	switch filters.Sort {
+	case "", "newest":
+	case "popular":
+		order = "popularity_score DESC, created_at DESC"
+	default:
+		return nil, 0, ErrInvalidActivitySort
+	}
+
+	var activities []models.Activity
+	if err := query.Order(order).
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&activities).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list activities: %w", err)
+	}
+
+	return activities, total, nil
+}
+
+// filtered returns the query of the activities matching the filters
+func (s *ActivityService) filtered(ctx context.Context, filters ActivityFilters) (*gorm.DB, error) {
+	query := s.db.WithContext(ctx).Model(&models.Activity{})
+	if !filters.IncludePending {
+		query = query.Where("approved = ?", true)
+	}
+	if filters.Category != "" {
+		condition, args, err := categoryFilter(ctx, s.db, filters.Category)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(condition, args...)
+	}
+	if filters.Difficulty != "" {
+		condition, args, err := difficultyFilter(ctx, s.db, filters.Difficulty)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(condition, args...)
+	}
+	return query, nil
+}
+
+// ActivityViewer is who an activity is loaded for. Unapproved activities are
+// only shown to moderators and to their submitter.
+type ActivityViewer struct {
+	UserID    uint
+	Moderator bool
+}
+
+// Get returns an activity with its routes, its seasonal variants and approved
+// images, or all images and variants with includePending, which only applies
+// to moderators. Activities the viewer may not see are not found.
+func (s *ActivityService) Get(ctx context.Context, id uint, viewer ActivityViewer, includePending bool) (*models.Activity, error) {
+	includePending = includePending && viewer.Moderator
+	images := func(db *gorm.DB) *gorm.DB {
+		if includePending {
+			return db
+		}
+		return db.Where("approved = ?", true)
+	}
+
+	query := s.db.WithContext(ctx)
+	switch {
+	case viewer.Moderator:
+	case viewer.UserID != 0:
+		query = query.Where("approved = ? OR user_id = ?", true, viewer.UserID)
+	default:
+		query = query.Where("approved = ?", true)
+	}
+	var activity models.Activity
+	if err := query.
+		Preload("Images", images).
+		Preload("Routes").
+		First(&activity, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to load activity %d: %w", id, err)
+	}
+	variants, err := s.variants(ctx, &activity, includePending)
+	if err != nil {
+		return nil, err
+	}
+	activity.Variants = variants
+	return &activity, nil
+}
+
+// Create stores a new activity. New activities start unapproved unless their
+// submitter is trusted, in which case they are published as auto-approved. The
+// category is given by ID or, for older clients, by slug or name; unknown
+// categories fail with ErrUnknownCategory.
+func (s *ActivityService) Create(ctx context.Context, activity *models.Activity) error {
+	if err := s.prepare(ctx, activity); err != nil {
+		return err
+	}
+	if err := s.db.WithContext(ctx).Create(activity).Error; err != nil {
+		return fmt.Errorf("failed to create activity: %w", err)
+	}
+	s.invalidateNearby(ctx)
+	return nil
+}
+
+// prepare sets the moderation state and category of a new activity
+func (s *ActivityService) prepare(ctx context.Context, activity *models.Activity) error {
+	trusted, err := s.trust.SkipsModeration(ctx, activity.UserID)
+	if err != nil {
+		return err
+	}
+	activity.Approved, activity.AutoApproved, activity.ModeratedAt = false, false, nil
+	if trusted {
+		now := time.Now()
+		activity.Approved, activity.AutoApproved, activity.ModeratedAt = true, true, &now
+	}
+	return assignCategory(ctx, s.db, activity)
+}
+
+// Update replaces the editable fields of an activity submitted by userID, or of
+// any activity for moderators. Editing a rejected activity submits it for
+// review again.
+func (s *ActivityService) Update(ctx context.Context, id, userID uint, changes *models.Activity) (*models.Activity, error) {
+	var activity models.Activity
+	if err := s.db.WithContext(ctx).First(&activity, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to load activity %d: %w", id, err)
+	}
+	if err := s.checkOwner(ctx, activity, userID); err != nil {
+		return nil, err
+	}
+	// Activities with a legacy or unknown category stay editable: the category
+	// is only checked when it changes
+	if categoryChanged(activity, changes) {
+		if err := assignCategory(ctx, s.db, changes); err != nil {
+			return nil, err
+		}
+	} else {
+		changes.Category, changes.CategoryID = activity.Category, activity.CategoryID
+	}
+
+	// Select forces zero values (e.g. an emptied description) to be written too
+	fields := []interface{}{"Description", "Category", "CategoryID", "Latitude", "Longitude", "Difficulty", "Duration", "BestSeason", "OpeningHours"}
+	if changes.Seasons != nil {
+		// Activities offered all year leave no season to the other activities of their group
+		seasons := changes.Seasons
+		if len(seasons) == 0 {
+			seasons = models.Seasons
+		}
+		if err := checkSeasons(s.db.WithContext(ctx), activity.GroupID(), activity.ID, seasons); err != nil {
+			return nil, err
+		}
+		fields = append(fields, "Seasons")
+	}
+	moved := changes.Latitude != activity.Latitude || changes.Longitude != activity.Longitude
+	if err := s.db.WithContext(ctx).Model(&activity).
+		Select("Name", fields...).
+		Updates(changes).Error; err != nil {
+		return nil, fmt.Errorf("failed to update activity %d: %w", id, err)
+	}
+
+	// The variants of a location move with it
+	if moved {
+		group := activity.GroupID()
+		if err := s.db.WithContext(ctx).Model(&models.Activity{}).
+			Where("(id = ? OR variant_of = ?) AND id <> ?", group, group, activity.ID).
+			Updates(map[string]interface{}{"latitude": changes.Latitude, "longitude": changes.Longitude}).Error; err != nil {
+			return nil, fmt.Errorf("failed to move variants of activity %d: %w", id, err)
+		}
+	}
+
+	if !activity.Approved && activity.ModeratedAt != nil {
+		if err := s.db.WithContext(ctx).Model(&activity).
+			Select("ModeratedAt", "RejectionReason").
+			Updates(&models.Activity{}).Error; err != nil {
+			return nil, fmt.Errorf("failed to resubmit activity %d: %w", id, err)
+		}
+	}
+
+	s.invalidateNearby(ctx)
+	return &activity, nil
+}
+
+// categoryChanged reports whether changes name another category than the
+// activity has. Changes without a category keep the current one.
+func categoryChanged(activity models.Activity, changes *models.Activity) bool {
+	if changes.CategoryID != nil {
+		return activity.CategoryID == nil || *changes.CategoryID != *activity.CategoryID
+	}
+	return changes.Category != "" && !strings.EqualFold(changes.Category, activity.Category)
+}
+
+// Delete soft-deletes an activity submitted by userID, or any activity for moderators
+func (s *ActivityService) Delete(ctx context.Context, id, userID uint) error {
+	var activity models.Activity
+	if err := s.db.WithContext(ctx).Select("id", "user_id").First(&activity, id).Error; err != nil {
+		return fmt.Errorf("failed to load activity %d: %w", id, err)
+	}
+	if err := s.checkOwner(ctx, activity, userID); err != nil {
+		return err
+	}
+
+	result := s.db.WithContext(ctx).Delete(&models.Activity{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete activity %d: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to delete activity %d: %w", id, gorm.ErrRecordNotFound)
+	}
+	s.invalidateNearby(ctx)
+	return nil
+}
+
+// checkOwner fails with ErrNotOwner unless userID submitted the activity or
+// may moderate content
+func (s *ActivityService) checkOwner(ctx context.Context, activity models.Activity, userID uint) error {
+	if activity.UserID == userID {
+		return nil
+	}
+	moderator, err := canModerate(ctx, s.db, userID)
+	if err != nil {
+		return err
+	}
+	if !moderator {
+		return ErrNotOwner
+	}
+	return nil
+}
+
+// haversineSQL is the great-circle distance in kilometers between an activity and a
+// point; format it with the Earth radius and bind latitude, latitude, longitude
+const haversineSQL = "%g * 2 * ASIN(SQRT(POWER(SIN(RADIANS(latitude - ?) / 2), 2) + " +
+	"COS(RADIANS(?)) * COS(RADIANS(latitude)) * POWER(SIN(RADIANS(longitude - ?) / 2), 2)))"
+
+// NearbyQuery describes a "what's near me" search
+type NearbyQuery struct {
+	Lat        float64
+	Lng        float64
+	RadiusKM   float64
+	Category   string
+	Difficulty string
+	Limit      int
+}
+
+// NearbyActivity is an activity with its distance from the search point
+type NearbyActivity struct {
+	models.Activity
+	DistanceKM float64 `json:"distance_km"`
+	// Weather is the current weather at the activity, when requested
+	Weather *weather.Conditions `json:"weather,omitempty"`
+}
+
+// Nearby returns approved activities within the query radius that are offered in
+// the current season, closest first. It uses PostGIS when the extension is installed
+// and a Haversine expression otherwise; either way a bounding box pre-filter keeps
+// the distance calculation to nearby rows.
+// The search point is rounded to about 10 m so repeated searches share cached results.
+func (s *ActivityService) Nearby(ctx context.Context, q NearbyQuery) ([]NearbyActivity, error) {
+	q.Lat = math.Round(q.Lat*1e4) / 1e4
+	q.Lng = math.Round(q.Lng*1e4) / 1e4
+	// Regions have their own activities, so their searches are cached apart, and
+	// seasons their own variants of activities
+	now := time.Now()
+	key := fmt.Sprintf("%s:%s:%.4f,%.4f:%g:%s:%s:%d", residency.Region(ctx), models.SeasonAt(now, 1), q.Lat, q.Lng, q.RadiusKM,
+		strings.ToLower(q.Category), strings.ToLower(q.Difficulty), q.Limit)
+	return cache.Lookup(ctx, s.nearby, key, func(ctx context.Context) ([]NearbyActivity, error) {
+		return s.searchNearby(ctx, q, now)
+	})
+}
+
+// searchNearby runs the nearby search of Nearby for activities offered in the season of now
+func (s *ActivityService) searchNearby(ctx context.Context, q NearbyQuery, now time.Time) ([]NearbyActivity, error) {
+	distance, args := s.distanceExpr(ctx, q.Lat, q.Lng)
+	minLat, maxLat, minLng, maxLng := geo.BoundingBox(q.Lat, q.Lng, q.RadiusKM)
+
+	query := s.db.WithContext(ctx).Model(&models.Activity{}).
+		Select("activities.*, "+distance+" AS distance_km", args...).
+		Where("approved = ?", true).
+		Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?", minLat, maxLat, minLng, maxLng).
+		Where(distance+" <= ?", append(args, q.RadiusKM)...)
+	query = inSeason(query, now)
+	if q.Category != "" {
+		condition, args, err := categoryFilter(ctx, s.db, q.Category)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(condition, args...)
+	}
+	if q.Difficulty != "" {
+		condition, args, err := difficultyFilter(ctx, s.db, q.Difficulty)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(condition, args...)
+	}
+
+	var nearby []NearbyActivity
+	if err := query.Order("distance_km").Limit(q.Limit).Find(&nearby).Error; err != nil {
+		return nil, fmt.Errorf("failed to search nearby activities: %w", err)
+	}
+	return nearby, nil
+}
+
+// distanceExpr returns an SQL expression for the distance in kilometers between an
+// activity and the given point, with its arguments
+func (s *ActivityService) distanceExpr(ctx context.Context, lat, lng float64) (string, []interface{}) {
+	s.postgisOnce.Do(func() {
+		var count int64
+		if err := s.db.WithContext(ctx).Raw("SELECT COUNT(*) FROM pg_extension WHERE extname = 'postgis'").Scan(&count).Error; err != nil {
+			log.Printf("[ACTIVITIES] PostGIS detection failed, using Haversine: %v", err)
+		}
+		s.postgis = count > 0
+	})
+
+	if s.postgis {
+		return "ST_DistanceSphere(ST_MakePoint(longitude, latitude), ST_MakePoint(?, ?)) / 1000", []interface{}{lng, lat}
+	}
+	return fmt.Sprintf(haversineSQL, geo.EarthRadiusKM), []interface{}{lat, lat, lng}
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Formats of activity exports
+const (
+	ExportGeoJSON = "geojson"
+	ExportCSV     = "csv"
+)
+
+// exportBatchSize is how many activities an export reads from the database at once
+const exportBatchSize = 500
+
+// ErrUnsupportedExportFormat is returned for export formats other than GeoJSON and CSV
+var ErrUnsupportedExportFormat = apperr.New(apperr.Invalid, "format must be geojson or csv")
+
+// activityExportColumns are the columns of CSV exports. The activity fields use
+// the names activity imports read.
+var activityExportColumns = []string{"id", "name", "description", "category", "latitude", "longitude", "difficulty",
+	"duration", "best_season", "opening_hours", "rating_average", "rating_count", "last_verified_at", "updated_at"}
+
+// ActivityExport writes an export of activities to w
+type ActivityExport func(w io.Writer) error
+
+// activityFeature is an activity as a GeoJSON Feature
+type activityFeature struct {
+	Type     string `json:"type"`
+	ID       uint   `json:"id"`
+	Geometry struct {
+		Type        string    `json:"type"`
+		Coordinates []float64 `json:"coordinates"`
+	} `json:"geometry"`
+	Properties activityProperties `json:"properties"`
+}
+
+// activityProperties are the properties of an activity Feature
+type activityProperties struct {
+	Name           string     `json:"name"`
+	Description    string     `json:"description"`
+	Category       string     `json:"category"`
+	Difficulty     string     `json:"difficulty"`
+	Duration       int        `json:"duration"`
+	BestSeason     string     `json:"best_season"`
+	OpeningHours   string     `json:"opening_hours"`
+	RatingAverage  float64    `json:"rating_average"`
+	RatingCount    int        `json:"rating_count"`
+	LastVerifiedAt *time.Time `json:"last_verified_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// Export returns an export of the activities matching the filters as a GeoJSON
+// FeatureCollection of Points or as CSV with a header row, ordered by ID. The
+// filters are checked here; the export reads the activities in batches as it is
+// written, so it is never held in memory at once. Failures while writing leave
+// the output cut off.
+func (s *ActivityService) Export(ctx context.Context, filters ActivityFilters, format string) (ActivityExport, error) {
+	if format != ExportGeoJSON && format != ExportCSV {
+		return nil, ErrUnsupportedExportFormat
+	}
+	query, err := s.filtered(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+	return func(w io.Writer) error {
+		if format == ExportCSV {
+			return exportCSV(w, query)
+		}
+		return exportGeoJSON(w, query)
+	}, nil
+}
+
+// exportBatches calls write with each batch of the activities of query
+func exportBatches(query *gorm.DB, write func(activities []models.Activity) error) error {
+	var batch []models.Activity
+	if err := query.FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+		return write(batch)
+	}).Error; err != nil {
+		return fmt.Errorf("failed to export activities: %w", err)
+	}
+	return nil
+}
+
+// exportGeoJSON writes the activities of query as a FeatureCollection, encoding
+// each as a Feature as it is read
+func exportGeoJSON(w io.Writer, query *gorm.DB) error {
+	if _, err := io.WriteString(w, `{"type":"FeatureCollection","features":[`); err != nil {
+		return err
+	}
+	separator := ""
+	if err := exportBatches(query, func(activities []models.Activity) error {
+		for _, activity := range activities {
+			feature := activityFeature{Type: "Feature", ID: activity.ID, Properties: activityProperties{
+				Name:           activity.Name,
+				Description:    activity.Description,
+				Category:       activity.Category,
+				Difficulty:     activity.Difficulty,
+				Duration:       activity.Duration,
+				BestSeason:     activity.BestSeason,
+				OpeningHours:   activity.OpeningHours,
+				RatingAverage:  activity.RatingAverage,
+				RatingCount:    activity.RatingCount,
+				LastVerifiedAt: activity.LastVerifiedAt,
+				UpdatedAt:      activity.UpdatedAt,
+			}}
+			feature.Geometry.Type = "Point"
+			feature.Geometry.Coordinates = []float64{activity.Longitude, activity.Latitude}
+
+			data, err := json.Marshal(feature)
+			if err != nil {
+				return fmt.Errorf("failed to encode activity %d: %w", activity.ID, err)
+			}
+			if _, err := io.WriteString(w, separator+string(data)); err != nil {
+				return err
+			}
+			separator = ","
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "]}\n")
+	return err
+}
+
+// csvFormulaPrefixes start cells spreadsheets evaluate as formulas
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvText returns a text cell that spreadsheets show as text: cells starting
+// like a formula get a leading apostrophe, which the activity import removes
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune(csvFormulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// exportCSV writes the activities of query as CSV, flushing each batch as it is
+// read. Text cells are escaped with csvText.
+func exportCSV(w io.Writer, query *gorm.DB) error {
+	out := csv.NewWriter(w)
+	out.Write(activityExportColumns)
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return err
+	}
+	return exportBatches(query, func(activities []models.Activity) error {
+		for _, activity := range activities {
+			lastVerified := ""
+			if activity.LastVerifiedAt != nil {
+				lastVerified = activity.LastVerifiedAt.UTC().Format(time.RFC3339)
+			}
+			out.Write([]string{
+				strconv.FormatUint(uint64(activity.ID), 10),
+				csvText(activity.Name),
+				csvText(activity.Description),
+				csvText(activity.Category),
+				strconv.FormatFloat(activity.Latitude, 'f', -1, 64),
+				strconv.FormatFloat(activity.Longitude, 'f', -1, 64),
+				csvText(activity.Difficulty),
+				strconv.Itoa(activity.Duration),
+				csvText(activity.BestSeason),
+				csvText(activity.OpeningHours),
+				strconv.FormatFloat(activity.RatingAverage, 'f', -1, 64),
+				strconv.Itoa(activity.RatingCount),
+				lastVerified,
+				activity.UpdatedAt.UTC().Format(time.RFC3339),
+			})
+		}
+		out.Flush()
+		return out.Error()
+	})
+}
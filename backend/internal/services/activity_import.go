@@ -0,0 +1,464 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/geo"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// MaxActivityImportRows is the most activities one import may contain
+const MaxActivityImportRows = 5000
+
+// Formats of activity imports
+const (
+	ActivityImportCSV     = "csv"
+	ActivityImportGeoJSON = "geojson"
+)
+
+// duplicateActivityDistanceKM is how close an activity of the same name has to
+// be to count as a duplicate
+const duplicateActivityDistanceKM = 0.1
+
+// ErrInvalidActivityImport is returned for files and field mappings that cannot
+// be read as activity imports
+var ErrInvalidActivityImport = apperr.New(apperr.Invalid, "invalid activity import")
+
+// activityImportFields are the activity fields columns and properties can be mapped to
+var activityImportFields = []string{"name", "description", "category", "latitude", "longitude",
+	"difficulty", "duration", "best_season", "opening_hours"}
+
+// ActivityImportError is a row that was not imported. Rows are numbered from 1,
+// not counting the CSV header; GeoJSON features count as rows.
+type ActivityImportError struct {
+	Row   int    `json:"row"`
+	Name  string `json:"name,omitempty"`
+	Field string `json:"field,omitempty"`
+	Error string `json:"error"`
+}
+
+// ActivityImportResult reports what an import did, or would do in a dry run
+type ActivityImportResult struct {
+	DryRun bool `json:"dry_run"`
+	Total  int  `json:"total"`
+	// Imported activities are published at once; in a dry run, the valid rows
+	Imported int `json:"imported"`
+	Invalid  int `json:"invalid"`
+	// IgnoredColumns are columns and properties not mapped to an activity field
+	IgnoredColumns []string              `json:"ignored_columns,omitempty"`
+	Errors         []ActivityImportError `json:"errors,omitempty"`
+}
+
+// activityImportRow is a row of an import file: its values by activity field,
+// or what made it unreadable
+type activityImportRow struct {
+	fields  map[string]string
+	problem string
+}
+
+// ActivityImportService imports activities in bulk from the spreadsheets and
+// map exports of community moderators
+type ActivityImportService struct {
+	db         *gorm.DB
+	activities *ActivityService
+}
+
+// NewActivityImportService creates a new activity import service
+func NewActivityImportService(db *gorm.DB, activities *ActivityService) *ActivityImportService {
+	return &ActivityImportService{
+		db:         db,
+		activities: activities,
+	}
+}
+
+// ParseActivityImportMapping parses a field mapping of comma separated
+// column=field pairs, e.g. "Trail Name=name,Lat=latitude". Columns are matched
+// regardless of case; columns named like a field need no mapping.
+func ParseActivityImportMapping(value string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		column, field, ok := strings.Cut(pair, "=")
+		column, field = strings.ToLower(strings.TrimSpace(column)), strings.ToLower(strings.TrimSpace(field))
+		if !ok || column == "" {
+			return nil, fmt.Errorf("%w: mapping must be column=field pairs", ErrInvalidActivityImport)
+		}
+		if !slices.Contains(activityImportFields, field) {
+			return nil, fmt.Errorf("%w: unknown field %q, expected one of %s", ErrInvalidActivityImport, field, strings.Join(activityImportFields, ", "))
+		}
+		mapping[column] = field
+	}
+	return mapping, nil
+}
+
+// Import reads activities from a CSV file with a header row or a GeoJSON
+// FeatureCollection, whose Point geometries, or the start of LineStrings, locate
+// them. Columns and feature properties are mapped to activity fields by mapping.
+// Invalid rows, and rows duplicating an activity of the same name within 100 m,
+// are reported and skipped; the valid rows are inserted in one transaction and
+// published. A dry run only validates.
+func (s *ActivityImportService) Import(ctx context.Context, format string, data []byte, mapping map[string]string, dryRun bool) (*ActivityImportResult, error) {
+	var rows []activityImportRow
+	var ignored []string
+	var err error
+	switch format {
+	case ActivityImportCSV:
+		rows, ignored, err = parseActivityImportCSV(data, mapping)
+	case ActivityImportGeoJSON:
+		rows, ignored, err = parseActivityImportGeoJSON(data, mapping)
+	default:
+		err = fmt.Errorf("%w: unsupported format %q", ErrInvalidActivityImport, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: no activities to import", ErrInvalidActivityImport)
+	}
+	if len(rows) > MaxActivityImportRows {
+		return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidActivityImport, MaxActivityImportRows)
+	}
+
+	categories, err := loadCategories(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ActivityImportResult{DryRun: dryRun, Total: len(rows), IgnoredColumns: ignored}
+	reject := func(row int, name, field, problem string) {
+		result.Invalid++
+		result.Errors = append(result.Errors, ActivityImportError{Row: row, Name: name, Field: field, Error: problem})
+	}
+
+	var valid []models.Activity
+	var validRows []int
+	for i, row := range rows {
+		if row.problem != "" {
+			reject(i+1, row.fields["name"], "", row.problem)
+			continue
+		}
+		activity, field, problem := activityFromImport(row.fields, categories)
+		if problem != "" {
+			reject(i+1, row.fields["name"], field, problem)
+			continue
+		}
+		if j := slices.IndexFunc(valid, func(other models.Activity) bool { return sameActivity(other, *activity) }); j >= 0 {
+			reject(i+1, activity.Name, "", fmt.Sprintf("duplicate of row %d", validRows[j]))
+			continue
+		}
+		valid = append(valid, *activity)
+		validRows = append(validRows, i+1)
+	}
+
+	existing, err := s.existingDuplicates(ctx, valid)
+	if err != nil {
+		return nil, err
+	}
+	var activities []models.Activity
+	for i, activity := range valid {
+		if id, ok := existing[i]; ok {
+			reject(validRows[i], activity.Name, "", fmt.Sprintf("duplicate of activity %d", id))
+			continue
+		}
+		activities = append(activities, activity)
+	}
+	slices.SortFunc(result.Errors, func(a, b ActivityImportError) int { return a.Row - b.Row })
+
+	result.Imported = len(activities)
+	if dryRun || len(activities) == 0 {
+		return result, nil
+	}
+
+	now := time.Now()
+	for i := range activities {
+		activities[i].Approved, activities[i].ModeratedAt = true, &now
+	}
+	if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.CreateInBatches(&activities, 100).Error; err != nil {
+			return fmt.Errorf("failed to import activities: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	s.activities.invalidateNearby(ctx)
+	return result, nil
+}
+
+// existingDuplicates returns the IDs of stored activities that activities
+// duplicate, by index
+func (s *ActivityImportService) existingDuplicates(ctx context.Context, activities []models.Activity) (map[int]uint, error) {
+	duplicates := make(map[int]uint)
+	if len(activities) == 0 {
+		return duplicates, nil
+	}
+	names := make([]string, len(activities))
+	for i, activity := range activities {
+		names[i] = strings.ToLower(activity.Name)
+	}
+
+	var stored []models.Activity
+	if err := s.db.WithContext(ctx).
+		Select("id", "name", "latitude", "longitude").
+		Where("LOWER(name) IN ?", names).
+		Find(&stored).Error; err != nil {
+		return nil, fmt.Errorf("failed to find duplicate activities: %w", err)
+	}
+	for i, activity := range activities {
+		for _, other := range stored {
+			if sameActivity(other, activity) {
+				duplicates[i] = other.ID
+				break
+			}
+		}
+	}
+	return duplicates, nil
+}
+
+// sameActivity reports whether two activities have the same name and are within
+// duplicateActivityDistanceKM of each other
+func sameActivity(a, b models.Activity) bool {
+	return strings.EqualFold(a.Name, b.Name) &&
+		geo.DistanceKM(a.Latitude, a.Longitude, b.Latitude, b.Longitude) <= duplicateActivityDistanceKM
+}
+
+// activityFromImport validates the fields of a row and returns its activity, or
+// the field that is wrong and what is wrong with it
+func activityFromImport(fields map[string]string, categories []models.Category) (*models.Activity, string, string) {
+	activity := &models.Activity{
+		Name:         fields["name"],
+		Description:  fields["description"],
+		Difficulty:   fields["difficulty"],
+		BestSeason:   fields["best_season"],
+		OpeningHours: fields["opening_hours"],
+	}
+	if length := utf8.RuneCountInString(activity.Name); length < 3 || length > 255 {
+		return nil, "name", "name must be 3 to 255 characters"
+	}
+
+	category := findCategory(categories, fields["category"])
+	if category == nil {
+		if fields["category"] == "" {
+			return nil, "category", "category is required"
+		}
+		return nil, "category", fmt.Sprintf("unknown category %q", fields["category"])
+	}
+	activity.CategoryID, activity.Category = &category.ID, category.Slug
+
+	var err error
+	if activity.Latitude, err = strconv.ParseFloat(fields["latitude"], 64); err != nil || activity.Latitude < -90 || activity.Latitude > 90 {
+		return nil, "latitude", "latitude must be a number between -90 and 90"
+	}
+	if activity.Longitude, err = strconv.ParseFloat(fields["longitude"], 64); err != nil || activity.Longitude < -180 || activity.Longitude > 180 {
+		return nil, "longitude", "longitude must be a number between -180 and 180"
+	}
+	if duration := fields["duration"]; duration != "" {
+		if activity.Duration, err = strconv.Atoi(duration); err != nil || activity.Duration < 0 {
+			return nil, "duration", "duration must be a whole number of minutes"
+		}
+	}
+
+	if len(activity.Difficulty) > 50 {
+		return nil, "difficulty", "difficulty longer than 50 characters"
+	}
+	if len(activity.BestSeason) > 100 {
+		return nil, "best_season", "best_season longer than 100 characters"
+	}
+	if len(activity.OpeningHours) > 255 {
+		return nil, "opening_hours", "opening_hours longer than 255 characters"
+	}
+	return activity, "", ""
+}
+
+// importColumns maps the columns or properties of an import to activity fields
+// and collects the unmapped ones. Columns named like a field map to it.
+type importColumns struct {
+	mapping map[string]string
+	ignored []string
+}
+
+// field returns the activity field of a column, recording unmapped columns as
+// ignored, or "" for them
+func (c *importColumns) field(column string) string {
+	column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+	if field, ok := c.mapping[column]; ok {
+		return field
+	}
+	if slices.Contains(activityImportFields, column) {
+		return column
+	}
+	if column != "" && !slices.Contains(c.ignored, column) {
+		c.ignored = append(c.ignored, column)
+	}
+	return ""
+}
+
+// parseActivityImportCSV reads the rows of a CSV file with a header row
+func parseActivityImportCSV(data []byte, mapping map[string]string) ([]activityImportRow, []string, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: failed to read header: %v", ErrInvalidActivityImport, err)
+	}
+
+	columns := &importColumns{mapping: mapping}
+	fields := make([]string, len(header))
+	for i, name := range header {
+		fields[i] = columns.field(name)
+		if fields[i] != "" && slices.Index(fields, fields[i]) < i {
+			return nil, nil, fmt.Errorf("%w: two columns map to %s", ErrInvalidActivityImport, fields[i])
+		}
+	}
+
+	var rows []activityImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, columns.ignored, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidActivityImport, err)
+		}
+		if len(rows) == MaxActivityImportRows {
+			return nil, nil, fmt.Errorf("%w: more than %d rows", ErrInvalidActivityImport, MaxActivityImportRows)
+		}
+
+		row := activityImportRow{fields: make(map[string]string)}
+		for i, value := range record {
+			if i < len(fields) && fields[i] != "" {
+				row.fields[fields[i]] = strings.TrimSpace(csvPlainText(value))
+			}
+		}
+		if len(record) != len(header) {
+			row.problem = fmt.Sprintf("row has %d fields, the header %d", len(record), len(header))
+		}
+		rows = append(rows, row)
+	}
+}
+
+// csvPlainText removes the apostrophe csvText puts before cells starting like
+// a formula, so exports import unchanged
+func csvPlainText(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(csvFormulaPrefixes, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// geoJSONFeatureCollection is the part of a GeoJSON FeatureCollection read by imports
+type geoJSONFeatureCollection struct {
+	Type     string `json:"type"`
+	Features []struct {
+		Geometry *struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+		Properties map[string]interface{} `json:"properties"`
+	} `json:"features"`
+}
+
+// parseActivityImportGeoJSON reads the features of a GeoJSON FeatureCollection
+// as rows. Their geometry sets latitude and longitude.
+func parseActivityImportGeoJSON(data []byte, mapping map[string]string) ([]activityImportRow, []string, error) {
+	var collection geoJSONFeatureCollection
+	if err := json.Unmarshal(data, &collection); err != nil || collection.Type != "FeatureCollection" {
+		return nil, nil, fmt.Errorf("%w: body must be a GeoJSON FeatureCollection", ErrInvalidActivityImport)
+	}
+	if len(collection.Features) > MaxActivityImportRows {
+		return nil, nil, fmt.Errorf("%w: more than %d rows", ErrInvalidActivityImport, MaxActivityImportRows)
+	}
+
+	columns := &importColumns{mapping: mapping}
+	rows := make([]activityImportRow, len(collection.Features))
+	for i, feature := range collection.Features {
+		row := activityImportRow{fields: make(map[string]string)}
+		// Properties are read in a fixed order so the ignored ones are reported consistently
+		names := make([]string, 0, len(feature.Properties))
+		for name := range feature.Properties {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			if field := columns.field(name); field != "" {
+				row.fields[field] = propertyString(feature.Properties[name])
+			}
+		}
+
+		if feature.Geometry != nil {
+			lng, lat, err := geometryStart(feature.Geometry.Type, feature.Geometry.Coordinates)
+			if err != nil {
+				row.problem = err.Error()
+			} else {
+				row.fields["latitude"] = strconv.FormatFloat(lat, 'f', -1, 64)
+				row.fields["longitude"] = strconv.FormatFloat(lng, 'f', -1, 64)
+			}
+		}
+		rows[i] = row
+	}
+	return rows, columns.ignored, nil
+}
+
+// geometryStart returns the position of a Point, or the first position of a
+// LineString or MultiLineString, such as a trailhead
+func geometryStart(geometryType string, coordinates json.RawMessage) (float64, float64, error) {
+	var position []float64
+	switch geometryType {
+	case "Point":
+		json.Unmarshal(coordinates, &position)
+	case "LineString":
+		var line [][]float64
+		if json.Unmarshal(coordinates, &line) == nil && len(line) > 0 {
+			position = line[0]
+		}
+	case "MultiLineString":
+		var lines [][][]float64
+		if json.Unmarshal(coordinates, &lines) == nil && len(lines) > 0 && len(lines[0]) > 0 {
+			position = lines[0][0]
+		}
+	default:
+		return 0, 0, fmt.Errorf("unsupported geometry %q, expected Point, LineString or MultiLineString", geometryType)
+	}
+	if len(position) < 2 {
+		return 0, 0, fmt.Errorf("invalid %s coordinates", geometryType)
+	}
+	return position[0], position[1], nil
+}
+
+// propertyString returns a GeoJSON property value as imported text
+func propertyString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			log.Printf("[IMPORT] Unreadable property value %v: %v", v, err)
+			return ""
+		}
+		return string(encoded)
+	}
+}
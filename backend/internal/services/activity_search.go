@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"community-chatbot/internal/geo"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// searchTSQuery parses the search text with web search syntax: quoted phrases,
+// "or" and -excluded words. The configuration must match the search_vector column.
+const searchTSQuery = "websearch_to_tsquery('english', ?)"
+
+// searchHeadlineOptions mark matches in bold, like the chat's Markdown answers
+const searchHeadlineOptions = `StartSel=**, StopSel=**, MinWords=10, MaxWords=30, MaxFragments=2, FragmentDelimiter=" … "`
+
+// EnsureSearchIndex adds the full-text search column of activities, weighting
+// names over categories over descriptions, and its GIN index. Postgres keeps the
+// generated column up to date, so it is safe to run on every start.
+func EnsureSearchIndex(db *gorm.DB) error {
+	if err := db.Exec(`ALTER TABLE activities ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (
+			setweight(to_tsvector('english', COALESCE(name, '')), 'A') ||
+			setweight(to_tsvector('english', COALESCE(category, '')), 'B') ||
+			setweight(to_tsvector('english', COALESCE(description, '')), 'C')
+		) STORED`).Error; err != nil {
+		return fmt.Errorf("failed to add activity search column: %w", err)
+	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_activities_search ON activities USING GIN (search_vector)").Error; err != nil {
+		return fmt.Errorf("failed to create activity search index: %w", err)
+	}
+	return nil
+}
+
+// SearchQuery describes a full-text activity search
+type SearchQuery struct {
+	// Text is searched in names, categories and descriptions
+	Text       string
+	Category   string
+	Difficulty string
+	// Near restricts results to RadiusKM around a point
+	Near     *models.Location
+	RadiusKM float64
+	// InSeason leaves out seasonal variants not offered in the current season
+	InSeason bool
+	Page     int
+	PageSize int
+}
+
+// SearchResult is an activity matching a full-text search
+type SearchResult struct {
+	models.Activity
+	Rank float64 `json:"rank"`
+	// Headline is the name and Snippet excerpts of the description, with
+	// matching words in **bold**
+	Headline   string   `json:"headline"`
+	Snippet    string   `json:"snippet"`
+	DistanceKM *float64 `json:"distance_km,omitempty"`
+}
+
+// Search returns a page of approved activities matching the query text, best
+// matches first, and the total match count
+func (s *ActivityService) Search(ctx context.Context, q SearchQuery) ([]SearchResult, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.Activity{}).
+		Where("approved = ?", true).
+		Where("search_vector @@ "+searchTSQuery, q.Text)
+	if q.Category != "" {
+		condition, args, err := categoryFilter(ctx, s.db, q.Category)
+		if err != nil {
+			return nil, 0, err
+		}
+		query = query.Where(condition, args...)
+	}
+	if q.Difficulty != "" {
+		condition, args, err := difficultyFilter(ctx, s.db, q.Difficulty)
+		if err != nil {
+			return nil, 0, err
+		}
+		query = query.Where(condition, args...)
+	}
+
+	if q.InSeason {
+		query = inSeason(query, time.Now())
+	}
+
+	selects := "activities.*, ts_rank_cd(search_vector, " + searchTSQuery + ") AS rank, " +
+		"ts_headline('english', name, " + searchTSQuery + ", 'HighlightAll=true, StartSel=**, StopSel=**') AS headline, " +
+		"ts_headline('english', COALESCE(description, ''), " + searchTSQuery + ", ?) AS snippet"
+	selectArgs := []interface{}{q.Text, q.Text, q.Text, searchHeadlineOptions}
+	order := "rank DESC, id"
+	if q.Near != nil {
+		distance, args := s.distanceExpr(ctx, q.Near.Lat, q.Near.Lng)
+		minLat, maxLat, minLng, maxLng := geo.BoundingBox(q.Near.Lat, q.Near.Lng, q.RadiusKM)
+		query = query.
+			Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?", minLat, maxLat, minLng, maxLng).
+			Where(distance+" <= ?", append(args, q.RadiusKM)...)
+		selects += ", " + distance + " AS distance_km"
+		selectArgs = append(selectArgs, args...)
+		order = "rank DESC, distance_km"
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count search results: %w", err)
+	}
+
+	results := []SearchResult{}
+	if err := query.Select(selects, selectArgs...).
+		Order(order).
+		Offset((q.Page - 1) * q.PageSize).
+		Limit(q.PageSize).
+		Find(&results).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to search activities: %w", err)
+	}
+	return results, total, nil
+}
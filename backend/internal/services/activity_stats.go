@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"community-chatbot/internal/cache"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Popularity scores weigh the interest in an activity by its kind: a new
+// favorite counts as much as five page views. Each day's interest counts half
+// as much after popularityHalfLife days and not at all after popularityWindow
+// days, so the score ranks both popular and trending activities.
+const (
+	popularityViewPoints     = 1
+	popularityFavoritePoints = 5
+	popularityMentionPoints  = 2
+	popularityScanPoints     = 3
+	popularityReviewPoints   = 4
+	popularityHalfLife       = 7
+	popularityWindow         = 90
+)
+
+// trendingCountDays is the window of the counts returned with trending activities
+const trendingCountDays = 7
+
+// Limits of the get_trending_activities chat tool
+const (
+	toolTrendingDefaultLimit = 5
+	toolTrendingMaxLimit     = 10
+)
+
+// TrendingActivity is an activity with its interest in the last week
+type TrendingActivity struct {
+	models.Activity
+	Views     int64 `json:"views"`
+	Favorites int64 `json:"favorites"`
+	Mentions  int64 `json:"mentions"`
+	Scans     int64 `json:"scans"`
+}
+
+// ActivityStatsService counts the daily interest in activities and ranks them
+// by popularity
+type ActivityStatsService struct {
+	db *gorm.DB
+	// claims dedupes the views of a client; nil counts every view
+	claims cache.Claimer
+	// staleAfter marks activities in tool results outdated when they were not verified within it
+	staleAfter time.Duration
+}
+
+// NewActivityStatsService creates a new activity stats service
+func NewActivityStatsService(db *gorm.DB, claims cache.Claimer, staleAfter time.Duration) *ActivityStatsService {
+	return &ActivityStatsService{
+		db:         db,
+		claims:     claims,
+		staleAfter: staleAfter,
+	}
+}
+
+// RecordView counts a view of an activity's details, once per client and day
+func (s *ActivityStatsService) RecordView(ctx context.Context, activityID uint, client string) error {
+	if !firstToday(ctx, s.claims, fmt.Sprintf("view:%d", activityID), client) {
+		return nil
+	}
+	return recordActivityStat(ctx, s.db, "views", activityID)
+}
+
+// RecordMentions counts a chat answer linking to activities
+func (s *ActivityStatsService) RecordMentions(ctx context.Context, activityIDs []uint) error {
+	return recordActivityStat(ctx, s.db, "mentions", activityIDs...)
+}
+
+// recordActivityStat adds 1 to a counter of today's stats of activities
+func recordActivityStat(ctx context.Context, db *gorm.DB, column string, activityIDs ...uint) error {
+	if len(activityIDs) == 0 {
+		return nil
+	}
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	stats := make([]map[string]interface{}, len(activityIDs))
+	for i, id := range activityIDs {
+		stats[i] = map[string]interface{}{"activity_id": id, "day": day, column: 1}
+	}
+	if err := db.WithContext(ctx).Model(&models.ActivityStat{}).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "activity_id"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{column: gorm.Expr("activity_stats." + column + " + 1")}),
+	}).Create(stats).Error; err != nil {
+		return fmt.Errorf("failed to count %s of activities %v: %w", column, activityIDs, err)
+	}
+	return nil
+}
+
+// RecomputePopularity scores every activity by its views, new favorites, chat
+// mentions, short link scans and visible reviews of the last popularityWindow
+// days, halving the weight of each day every popularityHalfLife days. Only
+// changed scores are written, and updated_at is left alone since it marks when
+// the activity data last changed.
+func (s *ActivityStatsService) RecomputePopularity(ctx context.Context) (int64, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, -popularityWindow).Format("2006-01-02")
+	result := s.db.WithContext(ctx).Exec(`WITH daily AS (
+			SELECT activity_id, day, ? * views + ? * favorites + ? * mentions AS points
+			FROM activity_stats WHERE day >= ?
+			UNION ALL
+			SELECT activity_id, day, ? * scans FROM activity_scans WHERE day >= ?
+			UNION ALL
+			SELECT activity_id, created_at::date, ? FROM reviews
+			WHERE NOT hidden AND deleted_at IS NULL AND created_at::date >= ?
+		), scores AS (
+			SELECT a.id, COALESCE(SUM(d.points * POWER(0.5, (?::date - d.day) / ?::float)), 0) AS score
+			FROM activities a LEFT JOIN daily d ON d.activity_id = a.id
+			WHERE a.deleted_at IS NULL
+			GROUP BY a.id
+		)
+		UPDATE activities SET popularity_score = scores.score
+		FROM scores WHERE activities.id = scores.id AND activities.popularity_score <> scores.score`,
+		popularityViewPoints, popularityFavoritePoints, popularityMentionPoints, since,
+		popularityScanPoints, since,
+		popularityReviewPoints, since,
+		today.Format("2006-01-02"), popularityHalfLife)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to recompute popularity: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// Trending returns the approved activities with the highest popularity scores,
+// optionally of a category and its subcategories, with their counts of the
+// last week
+func (s *ActivityStatsService) Trending(ctx context.Context, category string, limit int) ([]TrendingActivity, error) {
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -(trendingCountDays - 1))
+	query := s.db.WithContext(ctx).Model(&models.Activity{}).
+		Select("activities.*, COALESCE(stats.views, 0) AS views, COALESCE(stats.favorites, 0) AS favorites, "+
+			"COALESCE(stats.mentions, 0) AS mentions, COALESCE(scans.scans, 0) AS scans").
+		Joins("LEFT JOIN (SELECT activity_id, SUM(views) AS views, SUM(favorites) AS favorites, SUM(mentions) AS mentions "+
+			"FROM activity_stats WHERE day >= ? GROUP BY activity_id) stats ON stats.activity_id = activities.id", since).
+		Joins("LEFT JOIN (SELECT activity_id, SUM(scans) AS scans "+
+			"FROM activity_scans WHERE day >= ? GROUP BY activity_id) scans ON scans.activity_id = activities.id", since).
+		Where("activities.approved = ? AND activities.popularity_score > 0", true)
+	if category != "" {
+		condition, args, err := categoryFilter(ctx, s.db, category)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(condition, args...)
+	}
+
+	var trending []TrendingActivity
+	if err := query.Order("activities.popularity_score DESC, activities.id").Limit(limit).Find(&trending).Error; err != nil {
+		return nil, fmt.Errorf("failed to load trending activities: %w", err)
+	}
+	return trending, nil
+}
+
+// Stage returns the pipeline stage that counts the activities chat answers
+// link to, as left by chat.PostProcessStage. Dry runs are not counted, and
+// storage failures are logged and never fail the run.
+func (s *ActivityStatsService) Stage() chat.Stage {
+	return chat.StageFunc{
+		StageName: "activity_mentions",
+		Fn: func(ctx context.Context, run *chat.Run, next chat.Handler) error {
+			err := next(ctx, run)
+			cited, _ := run.Metadata[chat.CitedActivitiesKey].([]uint)
+			if run.DryRun || len(cited) == 0 {
+				return err
+			}
+			// The run's context may be cancelled by a disconnect, so it is not used for storing
+			if storeErr := s.RecordMentions(context.WithoutCancel(ctx), cited); storeErr != nil {
+				log.Printf("[STATS] Run %s: %v", run.ID, storeErr)
+			}
+			return err
+		},
+	}
+}
+
+// Tool returns the get_trending_activities chat tool
+func (s *ActivityStatsService) Tool() chat.Tool {
+	return chat.Tool{
+		Name: "get_trending_activities",
+		Description: "Get the activities that are trending in the community right now, by recent views, favorites and mentions. " +
+			"Use it when the user asks what is popular, hot or trending, or for inspiration without further preferences.",
+		Parameters: json.RawMessage(fmt.Sprintf(`{
+			"type": "object",
+			"properties": {
+				"category": {"type": "string", "description": "Only activities of this category slug, e.g. \"hiking\""},
+				"limit": {"type": "integer", "minimum": 1, "maximum": %d}
+			}
+		}`, toolTrendingMaxLimit)),
+		Call: s.trendingActivities,
+	}
+}
+
+// trendingActivities implements the get_trending_activities tool
+func (s *ActivityStatsService) trendingActivities(ctx context.Context, run *chat.Run, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		Category string `json:"category"`
+		Limit    int    `json:"limit"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, err)
+	}
+	if args.Limit <= 0 || args.Limit > toolTrendingMaxLimit {
+		args.Limit = toolTrendingDefaultLimit
+	}
+
+	trending, err := s.Trending(ctx, args.Category, args.Limit)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]toolActivity, len(trending))
+	for i, activity := range trending {
+		results[i] = newToolActivity(activity.Activity, s.staleAfter)
+	}
+	return results, nil
+}
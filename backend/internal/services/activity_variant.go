@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrVariantSeasons is returned for variants offered all year, which would
+	// leave no season to their group's other activities
+	ErrVariantSeasons = apperr.New(apperr.Invalid, "variants must be offered in some seasons, not all year")
+	// ErrSeasonsOverlap is returned when two variants of a location are offered in the same season
+	ErrSeasonsOverlap = apperr.New(apperr.Conflict, "another variant of the activity is offered in these seasons")
+)
+
+// inSeasonSQL matches activities offered in the current season at their own
+// latitude; bind the southern and the northern hemisphere's season
+const inSeasonSQL = "(activities.seasons IS NULL OR jsonb_array_length(activities.seasons) = 0 OR " +
+	"activities.seasons @> jsonb_build_array(CASE WHEN activities.latitude < 0 THEN ?::text ELSE ?::text END))"
+
+// inSeason narrows query to activities offered in the season of now, so
+// recommendations pick the variant of a location for the time of year
+func inSeason(query *gorm.DB, now time.Time) *gorm.DB {
+	return query.Where(inSeasonSQL, models.SeasonAt(now, -1), models.SeasonAt(now, 1))
+}
+
+// CreateVariant stores a seasonal variant of the activity primaryID, submitted
+// by the primary's owner. The variant shares the primary's location, and like
+// new activities awaits approval unless its submitter is trusted. A primary
+// offered all year is limited to the
+// seasons the variant leaves; no two activities of a group share a season.
+// Variants of variants are added to the group of their primary.
+func (s *ActivityService) CreateVariant(ctx context.Context, primaryID uint, variant *models.Activity) error {
+	if len(variant.Seasons) == 0 || len(variant.Seasons) == len(models.Seasons) {
+		return ErrVariantSeasons
+	}
+	if err := s.prepare(ctx, variant); err != nil {
+		return err
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var primary models.Activity
+		if err := tx.First(&primary, primaryID).Error; err != nil {
+			return fmt.Errorf("failed to load activity %d: %w", primaryID, err)
+		}
+		if primary.VariantOf != nil {
+			if err := tx.First(&primary, *primary.VariantOf).Error; err != nil {
+				return fmt.Errorf("failed to load activity %d: %w", *primary.VariantOf, err)
+			}
+		}
+		if primary.UserID != variant.UserID {
+			return ErrNotOwner
+		}
+
+		if len(primary.Seasons) == 0 {
+			var rest []string
+			for _, season := range models.Seasons {
+				if !slices.Contains(variant.Seasons, season) {
+					rest = append(rest, season)
+				}
+			}
+			if err := tx.Model(&primary).Select("Seasons").Updates(&models.Activity{Seasons: rest}).Error; err != nil {
+				return fmt.Errorf("failed to update seasons of activity %d: %w", primary.ID, err)
+			}
+		}
+		if err := checkSeasons(tx, primary.ID, 0, variant.Seasons); err != nil {
+			return err
+		}
+
+		variant.VariantOf = &primary.ID
+		variant.Latitude, variant.Longitude = primary.Latitude, primary.Longitude
+		if err := tx.Create(variant).Error; err != nil {
+			return fmt.Errorf("failed to create variant of activity %d: %w", primary.ID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.invalidateNearby(ctx)
+	return nil
+}
+
+// checkSeasons fails with ErrSeasonsOverlap when an activity of the group other
+// than except is offered in one of seasons
+func checkSeasons(tx *gorm.DB, groupID, except uint, seasons []string) error {
+	var members []models.Activity
+	if err := tx.Select("id", "seasons").
+		Where("(id = ? OR variant_of = ?) AND id <> ?", groupID, groupID, except).
+		Find(&members).Error; err != nil {
+		return fmt.Errorf("failed to load variants of activity %d: %w", groupID, err)
+	}
+	for _, member := range members {
+		for _, season := range seasons {
+			if member.OffersSeason(season) {
+				return ErrSeasonsOverlap
+			}
+		}
+	}
+	return nil
+}
+
+// variants returns the other activities of an activity's group with their
+// routes, in season order of their first season
+func (s *ActivityService) variants(ctx context.Context, activity *models.Activity, includePending bool) ([]models.Activity, error) {
+	group := activity.GroupID()
+	query := s.db.WithContext(ctx).
+		Preload("Routes").
+		Where("(id = ? OR variant_of = ?) AND id <> ?", group, group, activity.ID)
+	if !includePending {
+		query = query.Where("approved = ?", true)
+	}
+
+	var variants []models.Activity
+	if err := query.Order("id").Find(&variants).Error; err != nil {
+		return nil, fmt.Errorf("failed to load variants of activity %d: %w", activity.ID, err)
+	}
+	slices.SortStableFunc(variants, func(a, b models.Activity) int {
+		return seasonIndex(a.Seasons) - seasonIndex(b.Seasons)
+	})
+	return variants, nil
+}
+
+// seasonIndex is the position of the first of seasons in models.Seasons, or
+// -1 for activities offered all year
+func seasonIndex(seasons []string) int {
+	first := -1
+	for _, season := range seasons {
+		if i := slices.Index(models.Seasons, season); i >= 0 && (first < 0 || i < first) {
+			first = i
+		}
+	}
+	return first
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// maxAPIKeysPerUser caps the active API keys of a user
+const maxAPIKeysPerUser = 10
+
+// apiKeyUseInterval is how often the last use of an API key is recorded, so
+// scripts do not cause a write per request
+const apiKeyUseInterval = time.Minute
+
+// ErrTooManyAPIKeys is returned when a user already has the maximum of active keys
+var ErrTooManyAPIKeys = apperr.New(apperr.Conflict, fmt.Sprintf("at most %d API keys can be active, revoke one first", maxAPIKeysPerUser))
+
+// APIKeyService manages the personal API keys users authenticate scripts with
+type APIKeyService struct {
+	db *gorm.DB
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(db *gorm.DB) *APIKeyService {
+	return &APIKeyService{
+		db: db,
+	}
+}
+
+// CreateKey creates an API key for a user and returns it with the secret key,
+// which is not stored and cannot be shown again
+func (s *APIKeyService) CreateKey(ctx context.Context, userID uint, name string) (*models.APIKey, string, error) {
+	var active int64
+	if err := s.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Count(&active).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to count API keys: %w", err)
+	}
+	if active >= maxAPIKeysPerUser {
+		return nil, "", ErrTooManyAPIKeys
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	apiKey := models.APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	key := &models.APIKey{
+		UserID:    userID,
+		Name:      name,
+		KeyHash:   hashAPIKey(apiKey),
+		KeyPrefix: apiKey[:len(models.APIKeyPrefix)+6],
+	}
+	if err := s.db.WithContext(ctx).Create(key).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create API key: %w", err)
+	}
+	return key, apiKey, nil
+}
+
+// ListKeys returns a user's API keys, newest first
+func (s *APIKeyService) ListKeys(ctx context.Context, userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeKey stops accepting one of a user's API keys
+func (s *APIKeyService) RevokeKey(ctx context.Context, userID, id uint) error {
+	result := s.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke API key %d: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to revoke API key %d: %w", id, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// Authenticate returns the active key for apiKey, or nil when it is unknown,
+// revoked or belongs to a deleted user
+func (s *APIKeyService) Authenticate(ctx context.Context, apiKey string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := s.db.WithContext(ctx).
+		Joins("JOIN users ON users.id = api_keys.user_id AND users.deleted_at IS NULL").
+		Where("api_keys.key_hash = ? AND api_keys.revoked_at IS NULL", hashAPIKey(apiKey)).
+		First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load API key: %w", err)
+	}
+
+	now := time.Now()
+	if err := s.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", key.ID, now.Add(-apiKeyUseInterval)).
+		Update("last_used_at", now).Error; err != nil {
+		return nil, fmt.Errorf("failed to record use of API key %d: %w", key.ID, err)
+	}
+	return &key, nil
+}
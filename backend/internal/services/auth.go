@@ -0,0 +1,568 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/auth"
+	"community-chatbot/internal/auth/oauth"
+	"community-chatbot/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidCredentials is returned for unknown emails and wrong passwords alike
+	ErrInvalidCredentials = apperr.New(apperr.Unauthorized, "invalid email or password")
+	// ErrEmailTaken is returned when registering an email that already has an account
+	ErrEmailTaken = apperr.New(apperr.Conflict, "email already registered")
+	// ErrInvalidInvitation is returned for unknown, expired and already accepted invitations alike
+	ErrInvalidInvitation = apperr.New(apperr.Invalid, "invalid or expired invitation")
+	// ErrOAuthEmailUnverified is returned when a provider signs in someone new
+	// without a verified email, which could otherwise take over the account of that email
+	ErrOAuthEmailUnverified = apperr.New(apperr.Forbidden, "the provider did not share a verified email")
+	// ErrEmailAlreadyVerified is returned when asking to verify a verified address
+	ErrEmailAlreadyVerified = apperr.New(apperr.Conflict, "email is already verified")
+	// ErrInvalidVerification is returned for unknown and expired verification links alike
+	ErrInvalidVerification = apperr.New(apperr.Invalid, "invalid or expired email verification")
+	// ErrInvalidPasswordReset is returned for unknown, expired and used password resets alike
+	ErrInvalidPasswordReset = apperr.New(apperr.Invalid, "invalid or expired password reset")
+)
+
+// dummyPasswordHash is compared against when logging in to an account without
+// a password, so unknown emails take as long to refuse as wrong passwords
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return hash
+})
+
+// passwordResetCooldown is how long after a reset link another one is not sent,
+// so the form cannot be used to flood someone's inbox
+const passwordResetCooldown = time.Minute
+
+// AccountEmailSender delivers the links verifying a user's email address and
+// resetting their password
+type AccountEmailSender interface {
+	SendVerification(ctx context.Context, user models.User, verifyURL string, expiresAt time.Time) error
+	SendPasswordReset(ctx context.Context, user models.User, resetURL string, expiresAt time.Time) error
+}
+
+// AuthService registers users, verifies their email address, resets passwords
+// and issues tokens
+type AuthService struct {
+	db              *gorm.DB
+	tokens          *auth.TokenIssuer
+	sender          AccountEmailSender
+	frontendURL     string
+	verificationTTL time.Duration
+	resetTTL        time.Duration
+}
+
+// NewAuthService creates a new auth service. Verification links point to the
+// frontend's /email/verify page and expire after verificationTTL; password
+// reset links point to /password/reset and expire after resetTTL. Both pages
+// pass the token query parameter on to the API.
+func NewAuthService(db *gorm.DB, tokens *auth.TokenIssuer, sender AccountEmailSender, frontendURL string, verificationTTL, resetTTL time.Duration) *AuthService {
+	return &AuthService{
+		db:              db,
+		tokens:          tokens,
+		sender:          sender,
+		frontendURL:     strings.TrimRight(frontendURL, "/"),
+		verificationTTL: verificationTTL,
+		resetTTL:        resetTTL,
+	}
+}
+
+// Register creates a user with a hashed password and default preferences, and
+// sends them a link to verify their email address
+func (s *AuthService) Register(ctx context.Context, email, password, name string) (*models.User, *auth.TokenPair, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := &models.User{
+		Email:        normalizeEmail(email),
+		Name:         name,
+		PasswordHash: string(hash),
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("email = ?", user.Email).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check email: %w", err)
+		}
+		if count > 0 {
+			return ErrEmailTaken
+		}
+
+		// A concurrent registration of the same email passes the check above
+		if err := tx.Create(user).Error; err != nil {
+			if isDuplicateKey(tx, err) {
+				return ErrEmailTaken
+			}
+			return fmt.Errorf("failed to create user: %w", err)
+		}
+		if err := tx.Create(&models.UserPreferences{UserID: user.ID}).Error; err != nil {
+			return fmt.Errorf("failed to create preferences: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The account works unverified; users can ask for another link
+	if _, err := s.sendVerification(ctx, *user); err != nil {
+		log.Printf("[ERROR] Send verification to user %d: %v", user.ID, err)
+	}
+
+	tokens, err := s.tokens.Issue(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// Login checks a user's password and issues tokens. Unknown emails and
+// accounts without a password are compared against a dummy hash, so response
+// times do not tell which emails have an account.
+func (s *AuthService) Login(ctx context.Context, email, password string) (*models.User, *auth.TokenPair, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("email = ?", normalizeEmail(email)).First(&user).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, fmt.Errorf("failed to load user: %w", err)
+	}
+
+	if user.PasswordHash == "" {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return nil, nil, ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, nil, ErrInvalidCredentials
+	}
+
+	tokens, err := s.tokens.Issue(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &user, tokens, nil
+}
+
+// Refresh exchanges a valid refresh token for a new token pair, provided the user still exists
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*auth.TokenPair, error) {
+	claims, err := s.tokens.Verify(ctx, refreshToken, auth.ScopeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := claims.UserID()
+	if err != nil {
+		return nil, err
+	}
+
+	revokedAt, err := TokenRevocations(s.db)(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if auth.IssuedBefore(claims, revokedAt) {
+		return nil, auth.ErrInvalidToken
+	}
+
+	return s.tokens.Issue(ctx, userID)
+}
+
+// TokenRevocations returns a lookup of when the tokens of a user were last
+// revoked, nil when they never were; deleted users are reported with
+// auth.ErrInvalidToken. It is the middleware.RevocationLookup of access tokens.
+func TokenRevocations(db *gorm.DB) func(ctx context.Context, userID uint) (*time.Time, error) {
+	return func(ctx context.Context, userID uint) (*time.Time, error) {
+		var user models.User
+		if err := db.WithContext(ctx).Select("id", "tokens_revoked_at").First(&user, userID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, auth.ErrInvalidToken
+			}
+			return nil, fmt.Errorf("failed to load user %d: %w", userID, err)
+		}
+		return user.TokensRevokedAt, nil
+	}
+}
+
+// AcceptInvitation sets the password of an invited user and issues tokens. Each
+// invitation can be accepted once; accepting one invalidates the user's others.
+func (s *AuthService) AcceptInvitation(ctx context.Context, token, password string) (*models.User, *auth.TokenPair, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	var user models.User
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var invitation models.Invitation
+		err := tx.Where("token_hash = ? AND accepted_at IS NULL AND expires_at > ?", hashInvitationToken(token), time.Now()).
+			First(&invitation).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidInvitation
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load invitation: %w", err)
+		}
+
+		if err := tx.First(&user, invitation.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidInvitation
+			}
+			return fmt.Errorf("failed to load user %d: %w", invitation.UserID, err)
+		}
+		// The invitation was sent to the address, so following it verifies it
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"password_hash":     string(hash),
+			"email_verified_at": gorm.Expr("COALESCE(email_verified_at, ?)", time.Now()),
+		}).Error; err != nil {
+			return fmt.Errorf("failed to set password of user %d: %w", user.ID, err)
+		}
+		if err := tx.Model(&models.Invitation{}).
+			Where("user_id = ? AND accepted_at IS NULL", user.ID).
+			Update("accepted_at", time.Now()).Error; err != nil {
+			return fmt.Errorf("failed to accept invitations of user %d: %w", user.ID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := s.tokens.Issue(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &user, tokens, nil
+}
+
+// OAuthLogin signs in the user of a provider identity and issues tokens. An
+// identity seen for the first time is linked to the account with its verified
+// email, or to a new password-less account; either way the user can still set
+// a password and sign in locally later. An existing account whose email was
+// never verified may have been registered by someone else first, so it is
+// taken over by the identity: see claimUnverified.
+func (s *AuthService) OAuthLogin(ctx context.Context, identity *oauth.Identity) (*models.User, *auth.TokenPair, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var linked models.OAuthIdentity
+		err := tx.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).First(&linked).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to load %s identity: %w", identity.Provider, err)
+		}
+		if err == nil {
+			err = tx.First(&user, linked.UserID).Error
+			if err == nil {
+				if err := tx.Model(&linked).Update("email", identity.Email).Error; err != nil {
+					return fmt.Errorf("failed to update %s identity of user %d: %w", identity.Provider, user.ID, err)
+				}
+				return nil
+			}
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("failed to load user %d: %w", linked.UserID, err)
+			}
+			// The account was deleted since; the identity starts over
+			if err := tx.Delete(&linked).Error; err != nil {
+				return fmt.Errorf("failed to unlink %s identity: %w", identity.Provider, err)
+			}
+		}
+
+		email := normalizeEmail(identity.Email)
+		if email == "" || !identity.EmailVerified {
+			return ErrOAuthEmailUnverified
+		}
+		err = tx.Where("email = ?", email).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			user = models.User{Email: email, Name: identity.Name}
+			if err := tx.Create(&user).Error; err != nil {
+				return fmt.Errorf("failed to create user: %w", err)
+			}
+			if err := tx.Create(&models.UserPreferences{UserID: user.ID}).Error; err != nil {
+				return fmt.Errorf("failed to create preferences: %w", err)
+			}
+		} else if err != nil {
+			return fmt.Errorf("failed to load user: %w", err)
+		} else if user.EmailVerifiedAt == nil {
+			if err := claimUnverified(tx, &user); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&user).Update("email_verified_at", gorm.Expr("COALESCE(email_verified_at, ?)", time.Now())).Error; err != nil {
+			return fmt.Errorf("failed to verify email of user %d: %w", user.ID, err)
+		}
+		if err := tx.Create(&models.OAuthIdentity{
+			UserID:   user.ID,
+			Provider: identity.Provider,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to link %s identity to user %d: %w", identity.Provider, user.ID, err)
+		}
+		// Signing in proves the invited email, so pending invitations are done
+		if err := tx.Model(&models.Invitation{}).
+			Where("user_id = ? AND accepted_at IS NULL", user.ID).
+			Update("accepted_at", time.Now()).Error; err != nil {
+			return fmt.Errorf("failed to accept invitations of user %d: %w", user.ID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := s.tokens.Issue(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &user, tokens, nil
+}
+
+// claimUnverified removes every way into an unverified account other than the
+// identity proving its email: the password, refresh tokens, API keys, other
+// identities and pending email changes and resets
+func claimUnverified(tx *gorm.DB, user *models.User) error {
+	now := time.Now().Truncate(time.Second)
+	if err := tx.Model(user).Updates(map[string]interface{}{
+		"password_hash":     "",
+		"tokens_revoked_at": now,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to reset credentials of user %d: %w", user.ID, err)
+	}
+	if err := tx.Model(&models.APIKey{}).Where("user_id = ? AND revoked_at IS NULL", user.ID).
+		Update("revoked_at", now).Error; err != nil {
+		return fmt.Errorf("failed to revoke API keys of user %d: %w", user.ID, err)
+	}
+	for _, model := range []interface{}{
+		&models.OAuthIdentity{},
+		&models.EmailChange{},
+		&models.EmailVerification{},
+		&models.PasswordReset{},
+	} {
+		if err := tx.Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+			return fmt.Errorf("failed to reset credentials of user %d: %w", user.ID, err)
+		}
+	}
+	log.Printf("[AUTH] Unverified account %d claimed by an OAuth identity; its credentials were reset", user.ID)
+	return nil
+}
+
+// RequestVerification sends a user another link to verify their email address,
+// replacing earlier ones, and returns when it expires
+func (s *AuthService) RequestVerification(ctx context.Context, userID uint) (time.Time, error) {
+	user, err := s.User(ctx, userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if user.EmailVerifiedAt != nil {
+		return time.Time{}, ErrEmailAlreadyVerified
+	}
+	return s.sendVerification(ctx, *user)
+}
+
+// sendVerification stores a verification for the user's address and sends its link
+func (s *AuthService) sendVerification(ctx context.Context, user models.User) (time.Time, error) {
+	token, err := newLinkToken()
+	if err != nil {
+		return time.Time{}, err
+	}
+	verification := models.EmailVerification{
+		UserID:    user.ID,
+		TokenHash: hashInvitationToken(token),
+		ExpiresAt: time.Now().Add(s.verificationTTL),
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.EmailVerification{}).Error; err != nil {
+			return fmt.Errorf("failed to replace verifications of user %d: %w", user.ID, err)
+		}
+		if err := tx.Create(&verification).Error; err != nil {
+			return fmt.Errorf("failed to create verification for user %d: %w", user.ID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	verifyURL := s.frontendURL + "/email/verify?token=" + url.QueryEscape(token)
+	if err := s.sender.SendVerification(ctx, user, verifyURL, verification.ExpiresAt); err != nil {
+		return time.Time{}, fmt.Errorf("failed to send verification to user %d: %w", user.ID, err)
+	}
+	return verification.ExpiresAt, nil
+}
+
+// VerifyEmail marks the address a verification link was sent to as verified
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) (*models.User, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var verification models.EmailVerification
+		err := tx.Where("token_hash = ? AND expires_at > ?", hashInvitationToken(token), time.Now()).
+			First(&verification).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidVerification
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load verification: %w", err)
+		}
+
+		if err := tx.First(&user, verification.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidVerification
+			}
+			return fmt.Errorf("failed to load user %d: %w", verification.UserID, err)
+		}
+		if user.EmailVerifiedAt == nil {
+			now := time.Now()
+			if err := tx.Model(&user).Update("email_verified_at", now).Error; err != nil {
+				return fmt.Errorf("failed to verify email of user %d: %w", user.ID, err)
+			}
+			user.EmailVerifiedAt = &now
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.EmailVerification{}).Error; err != nil {
+			return fmt.Errorf("failed to delete verifications of user %d: %w", user.ID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// RequestPasswordReset sends a link to choose a new password to the account
+// with an email. Unknown addresses succeed silently, so the form does not tell
+// which addresses have an account.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, email string) error {
+	var user models.User
+	err := s.db.WithContext(ctx).Where("email = ?", normalizeEmail(email)).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load user: %w", err)
+	}
+
+	var recent int64
+	if err := s.db.WithContext(ctx).Model(&models.PasswordReset{}).
+		Where("user_id = ? AND created_at > ?", user.ID, time.Now().Add(-passwordResetCooldown)).
+		Count(&recent).Error; err != nil {
+		return fmt.Errorf("failed to check password resets of user %d: %w", user.ID, err)
+	}
+	if recent > 0 {
+		return nil
+	}
+
+	token, err := newLinkToken()
+	if err != nil {
+		return err
+	}
+	reset := models.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: hashInvitationToken(token),
+		ExpiresAt: time.Now().Add(s.resetTTL),
+	}
+	if err := s.db.WithContext(ctx).Create(&reset).Error; err != nil {
+		return fmt.Errorf("failed to create password reset for user %d: %w", user.ID, err)
+	}
+
+	resetURL := s.frontendURL + "/password/reset?token=" + url.QueryEscape(token)
+	if err := s.sender.SendPasswordReset(ctx, user, resetURL, reset.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to send password reset to user %d: %w", user.ID, err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password with a reset link and issues tokens. Using
+// a link invalidates the user's others and, since it was emailed to them,
+// verifies their address.
+func (s *AuthService) ResetPassword(ctx context.Context, token, password string) (*models.User, *auth.TokenPair, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	var user models.User
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var reset models.PasswordReset
+		err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hashInvitationToken(token), time.Now()).
+			First(&reset).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidPasswordReset
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load password reset: %w", err)
+		}
+
+		if err := tx.First(&user, reset.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidPasswordReset
+			}
+			return fmt.Errorf("failed to load user %d: %w", reset.UserID, err)
+		}
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"password_hash":     string(hash),
+			"email_verified_at": gorm.Expr("COALESCE(email_verified_at, ?)", time.Now()),
+		}).Error; err != nil {
+			return fmt.Errorf("failed to set password of user %d: %w", user.ID, err)
+		}
+		if err := tx.Model(&models.PasswordReset{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return fmt.Errorf("failed to use password resets of user %d: %w", user.ID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := s.tokens.Issue(ctx, user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &user, tokens, nil
+}
+
+// User returns a user by ID
+func (s *AuthService) User(ctx context.Context, id uint) (*models.User, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to load user %d: %w", id, err)
+	}
+	return &user, nil
+}
+
+// newLinkToken returns a random token for a link sent by email
+func newLinkToken() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// normalizeEmail makes email lookups case-insensitive
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// isDuplicateKey reports whether err is a unique constraint violation of db's dialect
+func isDuplicateKey(db *gorm.DB, err error) bool {
+	if translator, ok := db.Dialector.(gorm.ErrorTranslator); ok {
+		err = translator.Translate(err)
+	}
+	return errors.Is(err, gorm.ErrDuplicatedKey)
+}
@@ -0,0 +1,337 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUnknownCategory is returned for activities naming a category that does not exist
+	ErrUnknownCategory = apperr.New(apperr.Invalid, "unknown category")
+	// ErrCategoryExists is returned when a category's slug is already taken
+	ErrCategoryExists = apperr.New(apperr.Conflict, "a category with this slug already exists")
+	// ErrInvalidCategoryParent is returned for parents that do not exist or would create a cycle
+	ErrInvalidCategoryParent = apperr.New(apperr.Invalid, "parent must be an existing category outside this one's subtree")
+	// ErrCategoryInUse is returned when deleting a category with subcategories or activities
+	ErrCategoryInUse = apperr.New(apperr.Conflict, "category has subcategories or activities")
+	// ErrInvalidCategorySlug is returned when neither the slug nor the name has letters or digits
+	ErrInvalidCategorySlug = apperr.New(apperr.Invalid, "slug must contain letters or digits")
+)
+
+// slugSeparators are the runs of characters replaced by "-" in slugs
+var slugSeparators = regexp.MustCompile(`[^a-z0-9]+`)
+
+// CategoryService manages the activity category hierarchy
+type CategoryService struct {
+	db *gorm.DB
+}
+
+// NewCategoryService creates a new category service
+func NewCategoryService(db *gorm.DB) *CategoryService {
+	return &CategoryService{
+		db: db,
+	}
+}
+
+// Tree returns the top-level categories with their subcategories nested, by name
+func (s *CategoryService) Tree(ctx context.Context) ([]models.Category, error) {
+	categories, err := loadCategories(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[uint][]models.Category)
+	for _, category := range categories {
+		if category.ParentID != nil {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		}
+	}
+	var nest func(category models.Category) models.Category
+	nest = func(category models.Category) models.Category {
+		for _, child := range children[category.ID] {
+			category.Children = append(category.Children, nest(child))
+		}
+		return category
+	}
+
+	roots := []models.Category{}
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, nest(category))
+		}
+	}
+	return roots, nil
+}
+
+// Get returns a category by ID
+func (s *CategoryService) Get(ctx context.Context, id uint) (*models.Category, error) {
+	var category models.Category
+	if err := s.db.WithContext(ctx).First(&category, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to load category %d: %w", id, err)
+	}
+	return &category, nil
+}
+
+// Create stores a new category. The slug is normalized and derived from the
+// name when empty.
+func (s *CategoryService) Create(ctx context.Context, category *models.Category) error {
+	if err := normalizeSlug(category); err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkCategorySlug(tx, category.Slug, 0); err != nil {
+			return err
+		}
+		if err := checkCategoryParent(ctx, tx, 0, category.ParentID); err != nil {
+			return err
+		}
+		if err := tx.Create(category).Error; err != nil {
+			return fmt.Errorf("failed to create category: %w", err)
+		}
+		return nil
+	})
+}
+
+// Update replaces the name, slug and parent of a category. Activities in the
+// category get the new slug.
+func (s *CategoryService) Update(ctx context.Context, id uint, changes *models.Category) (*models.Category, error) {
+	if err := normalizeSlug(changes); err != nil {
+		return nil, err
+	}
+
+	var category models.Category
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&category, id).Error; err != nil {
+			return fmt.Errorf("failed to load category %d: %w", id, err)
+		}
+		if err := checkCategorySlug(tx, changes.Slug, id); err != nil {
+			return err
+		}
+		if err := checkCategoryParent(ctx, tx, id, changes.ParentID); err != nil {
+			return err
+		}
+
+		// Select forces a cleared parent to be written too
+		if err := tx.Model(&category).Select("Name", "Slug", "ParentID").Updates(changes).Error; err != nil {
+			return fmt.Errorf("failed to update category %d: %w", id, err)
+		}
+		if err := tx.Model(&models.Activity{}).Where("category_id = ?", id).
+			Update("category", changes.Slug).Error; err != nil {
+			return fmt.Errorf("failed to rename category of activities: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// Delete removes a category that has no subcategories and no activities
+func (s *CategoryService) Delete(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var children, activities int64
+		if err := tx.Model(&models.Category{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+			return fmt.Errorf("failed to count subcategories: %w", err)
+		}
+		if err := tx.Model(&models.Activity{}).Where("category_id = ?", id).Count(&activities).Error; err != nil {
+			return fmt.Errorf("failed to count activities: %w", err)
+		}
+		if children > 0 || activities > 0 {
+			return ErrCategoryInUse
+		}
+
+		result := tx.Delete(&models.Category{}, id)
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete category %d: %w", id, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("failed to delete category %d: %w", id, gorm.ErrRecordNotFound)
+		}
+		return nil
+	})
+}
+
+// Seed creates DefaultCategories when there are no categories yet, then links
+// activities without a category ID to the category matching their free-text
+// category by slug or name
+func (s *CategoryService) Seed(ctx context.Context) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.Category{}).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count categories: %w", err)
+	}
+	if count == 0 {
+		ids := make(map[string]uint, len(models.DefaultCategories))
+		for _, seed := range models.DefaultCategories {
+			category := models.Category{Name: seed.Name, Slug: seed.Slug}
+			if parent, ok := ids[seed.Parent]; ok {
+				category.ParentID = &parent
+			}
+			if err := s.db.WithContext(ctx).Create(&category).Error; err != nil {
+				return fmt.Errorf("failed to seed category %s: %w", seed.Slug, err)
+			}
+			ids[seed.Slug] = category.ID
+		}
+		log.Printf("[CATEGORY] Seeded %d default categories", len(models.DefaultCategories))
+	}
+
+	result := s.db.WithContext(ctx).Exec(`UPDATE activities SET category_id = categories.id, category = categories.slug
+		FROM categories
+		WHERE activities.category_id IS NULL AND activities.category <> ''
+		AND (LOWER(activities.category) = categories.slug OR LOWER(activities.category) = LOWER(categories.name))`)
+	if result.Error != nil {
+		return fmt.Errorf("failed to link activities to categories: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("[CATEGORY] Linked %d activities to their category", result.RowsAffected)
+	}
+	return nil
+}
+
+// loadCategories returns all categories by name
+func loadCategories(ctx context.Context, db *gorm.DB) ([]models.Category, error) {
+	var categories []models.Category
+	if err := db.WithContext(ctx).Order("name").Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("failed to load categories: %w", err)
+	}
+	return categories, nil
+}
+
+// findCategory returns the category with an ID, slug or name (case-insensitive),
+// or nil when there is none
+func findCategory(categories []models.Category, value string) *models.Category {
+	id, err := strconv.ParseUint(value, 10, 64)
+	for i, category := range categories {
+		if (err == nil && uint64(category.ID) == id) || strings.EqualFold(category.Slug, value) || strings.EqualFold(category.Name, value) {
+			return &categories[i]
+		}
+	}
+	return nil
+}
+
+// categoryByID returns the category with an ID, or nil when there is none
+func categoryByID(categories []models.Category, id uint) *models.Category {
+	for i := range categories {
+		if categories[i].ID == id {
+			return &categories[i]
+		}
+	}
+	return nil
+}
+
+// subtree returns the IDs and slugs of a category and all its descendants
+func subtree(categories []models.Category, root *models.Category) ([]uint, []string) {
+	ids, slugs := []uint{root.ID}, []string{root.Slug}
+	for i := 0; i < len(ids); i++ {
+		for _, category := range categories {
+			if category.ParentID != nil && *category.ParentID == ids[i] {
+				ids = append(ids, category.ID)
+				slugs = append(slugs, category.Slug)
+			}
+		}
+	}
+	return ids, slugs
+}
+
+// categoryFilter returns a WHERE condition and its arguments matching activities
+// in a category, given by ID, slug or name, or any of its subcategories.
+// Activities not yet linked to a category match by their free-text category.
+func categoryFilter(ctx context.Context, db *gorm.DB, value string) (string, []interface{}, error) {
+	categories, err := loadCategories(ctx, db)
+	if err != nil {
+		return "", nil, err
+	}
+
+	category := findCategory(categories, value)
+	if category == nil {
+		return "LOWER(category) = ?", []interface{}{strings.ToLower(value)}, nil
+	}
+	ids, slugs := subtree(categories, category)
+	return "(category_id IN ? OR (category_id IS NULL AND LOWER(category) IN ?))", []interface{}{ids, slugs}, nil
+}
+
+// assignCategory links an activity to the category given by its category ID or,
+// for older clients, its free-text category, and stores the category's slug as
+// the text. It returns ErrUnknownCategory when neither names a category.
+func assignCategory(ctx context.Context, db *gorm.DB, activity *models.Activity) error {
+	categories, err := loadCategories(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	var category *models.Category
+	if activity.CategoryID != nil {
+		category = categoryByID(categories, *activity.CategoryID)
+	} else if activity.Category != "" {
+		category = findCategory(categories, activity.Category)
+	}
+	if category == nil {
+		return ErrUnknownCategory
+	}
+	activity.CategoryID = &category.ID
+	activity.Category = category.Slug
+	return nil
+}
+
+// checkCategorySlug returns ErrCategoryExists when another category than id has the slug
+func checkCategorySlug(tx *gorm.DB, slug string, id uint) error {
+	var count int64
+	if err := tx.Model(&models.Category{}).Where("slug = ? AND id <> ?", slug, id).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check category slug: %w", err)
+	}
+	if count > 0 {
+		return ErrCategoryExists
+	}
+	return nil
+}
+
+// checkCategoryParent returns ErrInvalidCategoryParent unless parentID is empty
+// or an existing category outside the subtree of category id (0 for new ones)
+func checkCategoryParent(ctx context.Context, tx *gorm.DB, id uint, parentID *uint) error {
+	if parentID == nil {
+		return nil
+	}
+	categories, err := loadCategories(ctx, tx)
+	if err != nil {
+		return err
+	}
+	parent := categoryByID(categories, *parentID)
+	if parent == nil {
+		return ErrInvalidCategoryParent
+	}
+	if id != 0 {
+		for ancestor := parent; ancestor != nil; {
+			if ancestor.ID == id {
+				return ErrInvalidCategoryParent
+			}
+			if ancestor.ParentID == nil {
+				break
+			}
+			ancestor = categoryByID(categories, *ancestor.ParentID)
+		}
+	}
+	return nil
+}
+
+// normalizeSlug turns the category's slug, or its name when the slug is empty,
+// into a slug: "Food & drink" becomes "food-drink"
+func normalizeSlug(category *models.Category) error {
+	slug := category.Slug
+	if slug == "" {
+		slug = category.Name
+	}
+	category.Slug = strings.Trim(slugSeparators.ReplaceAllString(strings.ToLower(slug), "-"), "-")
+	if category.Slug == "" {
+		return ErrInvalidCategorySlug
+	}
+	return nil
+}
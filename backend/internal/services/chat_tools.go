@@ -0,0 +1,345 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Limits for the search_activities tool
+const (
+	toolSearchDefaultLimit  = 5
+	toolSearchMaxLimit      = 10
+	toolSearchDefaultRadius = 25
+	toolSearchMaxRadius     = 200
+)
+
+// ChatTools exposes community database lookups to the chat model
+type ChatTools struct {
+	db         *gorm.DB
+	activities *ActivityService
+	geocode    *GeocodeService
+	staleAfter time.Duration
+}
+
+// NewChatTools creates the chat database tools; geocode may be nil, in which
+// case searches cannot be near a place name. Activities not verified within
+// staleAfter are marked outdated.
+func NewChatTools(db *gorm.DB, activities *ActivityService, geocode *GeocodeService, staleAfter time.Duration) *ChatTools {
+	return &ChatTools{
+		db:         db,
+		activities: activities,
+		geocode:    geocode,
+		staleAfter: staleAfter,
+	}
+}
+
+// toolActivity is the compact activity representation returned to the model
+type toolActivity struct {
+	ID         uint     `json:"id"`
+	Name       string   `json:"name"`
+	Category   string   `json:"category"`
+	Difficulty string   `json:"difficulty,omitempty"`
+	Duration   int      `json:"duration_minutes,omitempty"`
+	Seasons    []string `json:"seasons,omitempty"`
+	DistanceKM *float64 `json:"distance_km,omitempty"`
+	Link       string   `json:"link"`
+	// Rating is the average of RatingCount user ratings from 1 to 5; both are
+	// omitted for activities nobody rated
+	Rating      float64 `json:"rating,omitempty"`
+	RatingCount int     `json:"rating_count,omitempty"`
+	// Outdated marks activities whose data was not verified recently, which the
+	// model should tell the user to check
+	Outdated bool `json:"outdated,omitempty"`
+}
+
+// Tools returns search_activities, get_routes, get_images and save_preference
+func (t *ChatTools) Tools() []chat.Tool {
+	return []chat.Tool{
+		{
+			Name:        "search_activities",
+			Description: "Search approved community activities by text, category and difficulty. Results are ordered by distance when a location is given or known for the user.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"query": {"type": "string", "description": "Words to search in activity names, categories and descriptions; supports \"quoted phrases\" and -excluded words"},
+					"category": {"type": "string", "description": "Activity category, e.g. hiking, cycling, restaurant"},
+					"difficulty": {"type": "string", "description": "e.g. easy, moderate, hard"},
+					"place": {"type": "string", "description": "Search near a place the user named, e.g. \"Boulder, CO\"; use instead of lat and lng"},
+					"lat": {"type": "number"},
+					"lng": {"type": "number"},
+					"radius_km": {"type": "number", "description": "Search radius around the location, default 25"},
+					"limit": {"type": "integer", "minimum": 1, "maximum": 10}
+				}
+			}`),
+			Call: withRouteMaps(t.db, t.searchActivities),
+		},
+		{
+			Name:        "get_routes",
+			Description: "List the routes of an activity with distance, elevation gain, type and difficulty.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {"activity_id": {"type": "integer"}},
+				"required": ["activity_id"]
+			}`),
+			Call: withRouteMaps(t.db, t.getRoutes),
+		},
+		{
+			Name:        "get_images",
+			Description: "List approved image URLs and captions of an activity.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {"activity_id": {"type": "integer"}},
+				"required": ["activity_id"]
+			}`),
+			Call: t.getImages,
+		},
+		{
+			Name:        "save_preference",
+			Description: "Save a preference the signed-in user stated, such as their preferred trail difficulty or the kinds of activities they enjoy.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"difficulty_level": {"type": "string", "enum": ["easy", "moderate", "hard"]},
+					"preferred_activities": {"type": "array", "items": {"type": "string"}, "maxItems": 20}
+				}
+			}`),
+			Call: t.savePreference,
+		},
+	}
+}
+
+// searchActivities implements the search_activities tool
+func (t *ChatTools) searchActivities(ctx context.Context, run *chat.Run, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		Query      string   `json:"query"`
+		Category   string   `json:"category"`
+		Difficulty string   `json:"difficulty"`
+		Place      string   `json:"place"`
+		Lat        *float64 `json:"lat"`
+		Lng        *float64 `json:"lng"`
+		RadiusKM   float64  `json:"radius_km"`
+		Limit      int      `json:"limit"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, err)
+	}
+	if args.Limit <= 0 || args.Limit > toolSearchMaxLimit {
+		args.Limit = toolSearchDefaultLimit
+	}
+	if args.RadiusKM <= 0 || args.RadiusKM > toolSearchMaxRadius {
+		args.RadiusKM = toolSearchDefaultRadius
+	}
+
+	var location *models.Location
+	switch {
+	case args.Place != "" && t.geocode != nil:
+		place, err := t.geocode.Resolve(ctx, args.Place)
+		if errors.Is(err, ErrPlaceNotFound) || errors.Is(err, ErrInvalidPlace) {
+			return nil, fmt.Errorf("%w: no place found for %q; ask the user to be more specific", chat.ErrInvalidToolArguments, args.Place)
+		}
+		if err != nil {
+			return nil, err
+		}
+		location = &models.Location{Lat: place.Lat, Lng: place.Lng}
+	case args.Lat != nil && args.Lng != nil:
+		location = &models.Location{Lat: *args.Lat, Lng: *args.Lng}
+	case run.UserContext != nil && run.UserContext.Location != nil:
+		location = run.UserContext.Location
+	}
+
+	results := []toolActivity{}
+	// Query text is matched with full-text search, best matches first
+	if args.Query != "" {
+		query := SearchQuery{
+			Text:       args.Query,
+			Category:   args.Category,
+			Difficulty: args.Difficulty,
+			Near:       location,
+			RadiusKM:   args.RadiusKM,
+			InSeason:   true,
+			Page:       1,
+			PageSize:   args.Limit,
+		}
+		found, _, err := t.activities.Search(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range found {
+			activity := newToolActivity(result.Activity, t.staleAfter)
+			activity.DistanceKM = result.DistanceKM
+			results = append(results, activity)
+		}
+		return results, nil
+	}
+
+	if location != nil {
+		nearby, err := t.activities.Nearby(ctx, NearbyQuery{
+			Lat:        location.Lat,
+			Lng:        location.Lng,
+			RadiusKM:   args.RadiusKM,
+			Category:   args.Category,
+			Difficulty: args.Difficulty,
+			Limit:      args.Limit,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range nearby {
+			activity := newToolActivity(n.Activity, t.staleAfter)
+			distance := n.DistanceKM
+			activity.DistanceKM = &distance
+			results = append(results, activity)
+		}
+		return results, nil
+	}
+
+	query := inSeason(t.db.WithContext(ctx).Where("approved = ?", true), time.Now())
+	if args.Category != "" {
+		condition, conditionArgs, err := categoryFilter(ctx, t.db, args.Category)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(condition, conditionArgs...)
+	}
+	if args.Difficulty != "" {
+		condition, conditionArgs, err := difficultyFilter(ctx, t.db, args.Difficulty)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(condition, conditionArgs...)
+	}
+	var activities []models.Activity
+	if err := query.Order("name").Limit(args.Limit).Find(&activities).Error; err != nil {
+		return nil, fmt.Errorf("failed to search activities: %w", err)
+	}
+	for _, activity := range activities {
+		results = append(results, newToolActivity(activity, t.staleAfter))
+	}
+	return results, nil
+}
+
+// getRoutes implements the get_routes tool
+func (t *ChatTools) getRoutes(ctx context.Context, _ *chat.Run, raw json.RawMessage) (interface{}, error) {
+	activityID, err := toolActivityID(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := []models.Route{}
+	if err := t.db.WithContext(ctx).
+		Omit("track_data").
+		Where("activity_id IN (?)", t.db.Model(&models.Activity{}).Select("id").Where("id = ? AND approved = ?", activityID, true)).
+		Order("distance_km").
+		Find(&routes).Error; err != nil {
+		return nil, fmt.Errorf("failed to load routes of activity %d: %w", activityID, err)
+	}
+	return routes, nil
+}
+
+// getImages implements the get_images tool
+func (t *ChatTools) getImages(ctx context.Context, _ *chat.Run, raw json.RawMessage) (interface{}, error) {
+	activityID, err := toolActivityID(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	images := []models.Image{}
+	if err := t.db.WithContext(ctx).
+		Where("activity_id IN (?)", t.db.Model(&models.Activity{}).Select("id").Where("id = ? AND approved = ?", activityID, true)).
+		Where("approved = ?", true).
+		Order("id").
+		Find(&images).Error; err != nil {
+		return nil, fmt.Errorf("failed to load images of activity %d: %w", activityID, err)
+	}
+	return images, nil
+}
+
+// savePreference implements the save_preference tool
+func (t *ChatTools) savePreference(ctx context.Context, run *chat.Run, raw json.RawMessage) (interface{}, error) {
+	if run.UserID == 0 {
+		return nil, chat.ErrToolSignInRequired
+	}
+
+	var args struct {
+		DifficultyLevel     string   `json:"difficulty_level"`
+		PreferredActivities []string `json:"preferred_activities"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, err)
+	}
+
+	preferences := NewPreferencesService(t.db)
+	saved := map[string]interface{}{}
+	if args.DifficultyLevel != "" {
+		difficulty := strings.ToLower(args.DifficultyLevel)
+		if difficulty != "easy" && difficulty != "moderate" && difficulty != "hard" {
+			return nil, fmt.Errorf("%w: difficulty_level must be easy, moderate or hard", chat.ErrInvalidToolArguments)
+		}
+		if err := preferences.SaveProfileAnswer(ctx, run.UserID, chat.ProfileDifficulty, difficulty); err != nil {
+			return nil, err
+		}
+		saved[chat.ProfileDifficulty] = difficulty
+	}
+
+	var activities models.StringList
+	for _, activity := range args.PreferredActivities {
+		activity = strings.ToLower(strings.TrimSpace(activity))
+		if activity != "" && len(activity) <= 100 && !slices.Contains(activities, activity) && len(activities) < 20 {
+			activities = append(activities, activity)
+		}
+	}
+	if len(activities) > 0 {
+		if err := preferences.SaveProfileAnswer(ctx, run.UserID, chat.ProfileActivities, activities); err != nil {
+			return nil, err
+		}
+		saved[chat.ProfileActivities] = activities
+	}
+
+	if len(saved) == 0 {
+		return nil, fmt.Errorf("%w: nothing to save", chat.ErrInvalidToolArguments)
+	}
+	return map[string]interface{}{"saved": saved}, nil
+}
+
+// toolActivityID parses the {"activity_id": n} arguments of the per-activity tools
+func toolActivityID(raw json.RawMessage) (uint, error) {
+	var args struct {
+		ActivityID uint `json:"activity_id"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return 0, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, err)
+	}
+	if args.ActivityID == 0 {
+		return 0, fmt.Errorf("%w: activity_id is required", chat.ErrInvalidToolArguments)
+	}
+	return args.ActivityID, nil
+}
+
+// newToolActivity converts an activity for a tool result, marking it outdated
+// when it was not verified within staleAfter
+func newToolActivity(activity models.Activity, staleAfter time.Duration) toolActivity {
+	activity.ApplyFreshness(staleAfter)
+	return toolActivity{
+		ID:          activity.ID,
+		Name:        activity.Name,
+		Category:    activity.Category,
+		Difficulty:  activity.Difficulty,
+		Duration:    activity.Duration,
+		Seasons:     activity.Seasons,
+		Link:        fmt.Sprintf("activity://%d", activity.ID),
+		Rating:      math.Round(activity.RatingAverage*10) / 10,
+		RatingCount: activity.RatingCount,
+		Outdated:    activity.Outdated,
+	}
+}
@@ -0,0 +1,498 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/cache"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/weather"
+
+	"gorm.io/gorm"
+)
+
+// Checklist item sources
+const (
+	ChecklistSourceGenerated = "generated"
+	ChecklistSourceForecast  = "forecast"
+	ChecklistSourceModerator = "moderator"
+)
+
+// Kinds of activities checklists are generated for, from their category
+const (
+	checklistKindHiking   = "hiking"
+	checklistKindCycling  = "cycling"
+	checklistKindClimbing = "climbing"
+	checklistKindWater    = "water-sports"
+	checklistKindOutdoors = "outdoors"
+	// checklistKindOther activities, e.g. museums or cafés, only get weather gear
+	checklistKindOther = "other"
+)
+
+var (
+	// ErrInvalidSeason is returned for overrides of seasons other than the four
+	ErrInvalidSeason = apperr.New(apperr.Invalid, "season must be empty, spring, summer, autumn or winter")
+	// ErrInvalidChecklistItem is returned for overrides without an item name
+	ErrInvalidChecklistItem = apperr.New(apperr.Invalid, "item is required")
+)
+
+// ChecklistService generates packing checklists from an activity's category,
+// duration and season, adjusted to the forecast when the date is within it.
+// Moderators correct the generated items of an activity with overrides.
+type ChecklistService struct {
+	db         *gorm.DB
+	weather    *WeatherService
+	checklists *cache.Cache
+}
+
+// Checklist is the packing checklist of an activity on a date
+type Checklist struct {
+	ActivityID uint                   `json:"activity_id"`
+	Date       string                 `json:"date"`
+	Season     string                 `json:"season"`
+	Forecast   *weather.Day           `json:"forecast,omitempty"`
+	Items      []models.ChecklistItem `json:"items"`
+	Notes      []string               `json:"notes,omitempty"`
+}
+
+// seasonalChecklist is the cached checklist of an activity in a season, with
+// the moderator overrides applied
+type seasonalChecklist struct {
+	Kind  string                 `json:"kind"`
+	Items []models.ChecklistItem `json:"items"`
+	// Removed are the items moderators removed, which the forecast does not add back
+	Removed []string `json:"removed,omitempty"`
+}
+
+// NewChecklistService creates a new checklist service; weather may be nil to
+// generate checklists without forecasts and checklists nil to disable caching
+func NewChecklistService(db *gorm.DB, weather *WeatherService, checklists *cache.Cache) *ChecklistService {
+	return &ChecklistService{
+		db:         db,
+		weather:    weather,
+		checklists: checklists,
+	}
+}
+
+// Generate returns the checklist of an approved activity for a date
+// (YYYY-MM-DD, today when empty). The seasonal checklist is cached per activity
+// and season; forecast items are added when the date is within the forecast.
+func (s *ChecklistService) Generate(ctx context.Context, activityID uint, date string) (*Checklist, error) {
+	day := time.Now().UTC()
+	if date != "" {
+		parsed, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			return nil, ErrInvalidDate
+		}
+		day = parsed
+	}
+
+	var activity models.Activity
+	if err := s.db.WithContext(ctx).Where("approved = ?", true).First(&activity, activityID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load activity %d: %w", activityID, err)
+	}
+
+	season := models.SeasonAt(day, activity.Latitude)
+	seasonal, err := cache.Lookup(ctx, s.checklists, checklistKey(&activity, season), func(ctx context.Context) (*seasonalChecklist, error) {
+		return s.seasonal(ctx, &activity, season)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	checklist := &Checklist{
+		ActivityID: activity.ID,
+		Date:       day.Format(time.DateOnly),
+		Season:     season,
+		Items:      seasonal.Items,
+	}
+	if s.weather != nil {
+		s.applyForecast(ctx, checklist, &activity, seasonal)
+	}
+	return checklist, nil
+}
+
+// seasonal generates the checklist of an activity in a season and applies its overrides
+func (s *ChecklistService) seasonal(ctx context.Context, activity *models.Activity, season string) (*seasonalChecklist, error) {
+	kind, err := s.kind(ctx, activity)
+	if err != nil {
+		return nil, err
+	}
+	checklist := &seasonalChecklist{Kind: kind, Items: generateChecklist(kind, activity, season)}
+
+	// All-season overrides come first, so season-specific ones take precedence
+	var overrides []models.ChecklistOverride
+	if err := s.db.WithContext(ctx).Where("activity_id = ? AND season IN ?", activity.ID, []string{"", season}).
+		Order("season, id").Find(&overrides).Error; err != nil {
+		return nil, fmt.Errorf("failed to load checklist overrides: %w", err)
+	}
+	for _, override := range overrides {
+		checklist.apply(override)
+	}
+	return checklist, nil
+}
+
+// apply adds, replaces or removes the item of an override
+func (c *seasonalChecklist) apply(override models.ChecklistOverride) {
+	index := checklistIndex(c.Items, override.Item)
+	if override.Remove {
+		if index >= 0 {
+			c.Items = slices.Delete(c.Items, index, index+1)
+		}
+		c.Removed = append(c.Removed, override.Item)
+		return
+	}
+
+	item := models.ChecklistItem{
+		Name:     override.Item,
+		Category: override.Category,
+		Quantity: override.Quantity,
+		Reason:   override.Note,
+		Source:   ChecklistSourceModerator,
+	}
+	c.Removed = slices.DeleteFunc(c.Removed, func(name string) bool { return strings.EqualFold(name, override.Item) })
+	if index < 0 {
+		c.Items = append(c.Items, item)
+		return
+	}
+	if item.Category == "" {
+		item.Category = c.Items[index].Category
+	}
+	c.Items[index] = item
+}
+
+// kind returns the checklist kind of an activity from its category or the
+// category's ancestors, e.g. a "via-ferrata" subcategory of climbing
+func (s *ChecklistService) kind(ctx context.Context, activity *models.Activity) (string, error) {
+	categories, err := loadCategories(ctx, s.db)
+	if err != nil {
+		return "", err
+	}
+
+	slugs := []string{strings.ToLower(activity.Category)}
+	category := findCategory(categories, activity.Category)
+	if activity.CategoryID != nil {
+		category = categoryByID(categories, *activity.CategoryID)
+	}
+	// Parents are followed for at most as many steps as there are categories, in case of cycles
+	for i := 0; category != nil && i < len(categories); i++ {
+		slugs = append(slugs, category.Slug)
+		if category.ParentID == nil {
+			break
+		}
+		category = categoryByID(categories, *category.ParentID)
+	}
+
+	for _, slug := range slugs {
+		switch slug {
+		case checklistKindHiking, checklistKindCycling, checklistKindClimbing, checklistKindWater, checklistKindOutdoors:
+			return slug, nil
+		}
+	}
+	return checklistKindOther, nil
+}
+
+// generateChecklist returns the items of an activity kind in a season
+func generateChecklist(kind string, activity *models.Activity, season string) []models.ChecklistItem {
+	var items []models.ChecklistItem
+	add := func(name, category, quantity, reason string) {
+		items = append(items, models.ChecklistItem{
+			Name:     name,
+			Category: category,
+			Quantity: quantity,
+			Reason:   reason,
+			Source:   ChecklistSourceGenerated,
+		})
+	}
+	hours := float64(activity.Duration) / 60
+
+	if kind == checklistKindOther {
+		if hours >= 2 {
+			add("Water bottle", "water", "", "")
+		}
+		add("Charged phone", "safety", "", "")
+		return items
+	}
+
+	add("Water", "water", waterQuantity(kind, hours, season == models.SeasonSummer), waterReason(hours, season == models.SeasonSummer))
+	switch {
+	case hours >= 4:
+		add("Lunch and snacks", "food", "", fmt.Sprintf("%.0f hours out", hours))
+	case hours >= 2:
+		add("Snacks", "food", "", "")
+	}
+	add("Map or offline map", "navigation", "", "")
+	add("Charged phone", "safety", "", "")
+	add("First aid kit", "safety", "", "")
+
+	switch season {
+	case models.SeasonWinter:
+		add("Insulated jacket", "clothing", "", "cold temperatures in winter")
+		add("Hat and gloves", "clothing", "", "")
+		add("Thermal base layer", "clothing", "", "")
+	case models.SeasonSummer:
+		add("Sun protection", "clothing", "sunscreen, sunglasses and a hat", "strong sun in summer")
+	default:
+		add("Warm mid layer", "clothing", "", "changeable temperatures in "+season)
+		add("Rain jacket", "clothing", "", "showers are common in "+season)
+	}
+
+	lights := ""
+	switch {
+	case season == models.SeasonWinter:
+		lights = "short winter days"
+	case hours >= 4:
+		lights = "in case the outing runs late"
+	}
+	if lights != "" && kind != checklistKindCycling {
+		add("Headlamp", "lights", "", lights)
+	}
+
+	switch kind {
+	case checklistKindHiking:
+		add("Hiking boots", "gear", "", "")
+		if activity.Difficulty == models.DifficultyHard || hours >= 5 {
+			add("Trekking poles", "gear", "", "long or demanding trail")
+		}
+		if season == models.SeasonWinter {
+			add("Microspikes", "gear", "", "trails may be icy")
+		}
+	case checklistKindCycling:
+		add("Helmet", "gear", "", "")
+		add("Spare tube, pump and tire levers", "gear", "", "")
+		if lights != "" {
+			add("Bike lights", "lights", "front and rear", lights)
+		}
+		if hours >= 2 {
+			add("Padded shorts", "clothing", "", "")
+		}
+	case checklistKindClimbing:
+		add("Helmet", "gear", "", "")
+		add("Harness", "gear", "", "")
+		add("Climbing shoes and chalk", "gear", "", "")
+	case checklistKindWater:
+		add("Life jacket", "safety", "", "")
+		add("Dry bag", "gear", "", "keeps the phone and spare clothes dry")
+		add("Change of clothes", "clothing", "", "")
+		if season != models.SeasonSummer {
+			add("Wetsuit", "clothing", "", "cold water outside summer")
+		}
+	}
+	return items
+}
+
+// waterQuantity returns how much water to carry: half a liter per hour,
+// three quarters for cycling, half as much again in the heat, in half liters
+func waterQuantity(kind string, hours float64, hot bool) string {
+	liters := hours * 0.5
+	if kind == checklistKindCycling {
+		liters = hours * 0.75
+	}
+	if hot {
+		liters *= 1.5
+	}
+	liters = max(math.Ceil(liters*2)/2, 0.5)
+	return fmt.Sprintf("%.1f L", liters)
+}
+
+// waterReason explains the water quantity
+func waterReason(hours float64, hot bool) string {
+	if hours == 0 {
+		return ""
+	}
+	if hot {
+		return fmt.Sprintf("%.1f hours in the heat", hours)
+	}
+	return fmt.Sprintf("%.1f hours out", hours)
+}
+
+// applyForecast adds the gear the forecast of the checklist's date calls for.
+// Items moderators set or removed are kept as they are. Forecast failures only
+// leave the forecast out.
+func (s *ChecklistService) applyForecast(ctx context.Context, checklist *Checklist, activity *models.Activity, seasonal *seasonalChecklist) {
+	forecast, err := s.weather.Forecast(ctx, activity.GetLocation())
+	if err != nil {
+		log.Printf("[CHECKLIST] Forecast of activity %d: %v", activity.ID, err)
+		return
+	}
+	for i := range forecast.Days {
+		if forecast.Days[i].Date == checklist.Date {
+			checklist.Forecast = &forecast.Days[i]
+		}
+	}
+	if checklist.Forecast == nil {
+		checklist.Notes = append(checklist.Notes, "There is no forecast for this date yet; check the weather closer to the day.")
+		return
+	}
+
+	day := checklist.Forecast
+	set := func(name, category, quantity, reason string) {
+		if slices.ContainsFunc(seasonal.Removed, func(r string) bool { return strings.EqualFold(r, name) }) {
+			return
+		}
+		item := models.ChecklistItem{Name: name, Category: category, Quantity: quantity, Reason: reason, Source: ChecklistSourceForecast}
+		index := checklistIndex(checklist.Items, name)
+		switch {
+		case index < 0:
+			checklist.Items = append(checklist.Items, item)
+		case checklist.Items[index].Source != ChecklistSourceModerator:
+			checklist.Items[index] = item
+		}
+	}
+	if day.PrecipitationMM >= 1 || (day.PrecipitationProbability != nil && *day.PrecipitationProbability >= 40) {
+		reason := fmt.Sprintf("%.1f mm of rain forecast", day.PrecipitationMM)
+		if day.PrecipitationProbability != nil {
+			reason = fmt.Sprintf("%d%% chance of rain, %.1f mm", *day.PrecipitationProbability, day.PrecipitationMM)
+		}
+		if seasonal.Kind == checklistKindOther {
+			set("Umbrella", "clothing", "", reason)
+		} else {
+			set("Rain jacket", "clothing", "", reason)
+		}
+	}
+	if day.TempMinC <= 5 {
+		reason := fmt.Sprintf("lows of %.0f°C", day.TempMinC)
+		set("Warm mid layer", "clothing", "", reason)
+		set("Hat and gloves", "clothing", "", reason)
+	}
+	if day.TempMaxC >= 28 && seasonal.Kind != checklistKindOther {
+		hours := float64(activity.Duration) / 60
+		if water := checklistIndex(checklist.Items, "Water"); water >= 0 && checklist.Items[water].Source == ChecklistSourceGenerated {
+			set("Water", "water", waterQuantity(seasonal.Kind, hours, true), waterReason(hours, true))
+		}
+		set("Sun protection", "clothing", "sunscreen, sunglasses and a hat", fmt.Sprintf("highs of %.0f°C", day.TempMaxC))
+	}
+	if day.WindMaxKPH >= 40 {
+		set("Windproof jacket", "clothing", "", fmt.Sprintf("wind up to %.0f km/h", day.WindMaxKPH))
+	}
+	if day.Code >= 95 {
+		checklist.Notes = append(checklist.Notes, "Thunderstorms are forecast; avoid exposed ridges and summits or pick another day.")
+	}
+}
+
+// checklistIndex returns the index of the item with a name (case-insensitive), or -1
+func checklistIndex(items []models.ChecklistItem, name string) int {
+	return slices.IndexFunc(items, func(item models.ChecklistItem) bool { return strings.EqualFold(item.Name, name) })
+}
+
+// checklistKey is the cache key of an activity's checklist in a season; edits
+// of the activity change its update time and so the key
+func checklistKey(activity *models.Activity, season string) string {
+	return fmt.Sprintf("%d:%d:%s", activity.ID, activity.UpdatedAt.Unix(), season)
+}
+
+// ListOverrides returns the checklist overrides of an activity
+func (s *ChecklistService) ListOverrides(ctx context.Context, activityID uint) ([]models.ChecklistOverride, error) {
+	var overrides []models.ChecklistOverride
+	if err := s.db.WithContext(ctx).Where("activity_id = ?", activityID).Order("season, id").Find(&overrides).Error; err != nil {
+		return nil, fmt.Errorf("failed to list checklist overrides: %w", err)
+	}
+	return overrides, nil
+}
+
+// SetOverride stores an override, replacing the one for the same item and
+// season, and drops the cached checklists of the activity
+func (s *ChecklistService) SetOverride(ctx context.Context, override *models.ChecklistOverride) error {
+	override.Item = strings.TrimSpace(override.Item)
+	override.Season = strings.ToLower(strings.TrimSpace(override.Season))
+	if override.Item == "" {
+		return ErrInvalidChecklistItem
+	}
+	if override.Season != "" && !slices.Contains(models.Seasons, override.Season) {
+		return ErrInvalidSeason
+	}
+
+	var activity models.Activity
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id", "updated_at").First(&activity, override.ActivityID).Error; err != nil {
+			return fmt.Errorf("failed to load activity %d: %w", override.ActivityID, err)
+		}
+		if err := tx.Where("activity_id = ? AND season = ? AND LOWER(item) = ?", override.ActivityID, override.Season, strings.ToLower(override.Item)).
+			Delete(&models.ChecklistOverride{}).Error; err != nil {
+			return fmt.Errorf("failed to replace checklist override: %w", err)
+		}
+		if err := tx.Create(override).Error; err != nil {
+			return fmt.Errorf("failed to create checklist override: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.invalidate(ctx, &activity)
+	return nil
+}
+
+// DeleteOverride removes an override of an activity
+func (s *ChecklistService) DeleteOverride(ctx context.Context, activityID, id uint) error {
+	result := s.db.WithContext(ctx).Where("activity_id = ?", activityID).Delete(&models.ChecklistOverride{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete checklist override %d: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to delete checklist override %d: %w", id, gorm.ErrRecordNotFound)
+	}
+
+	var activity models.Activity
+	if err := s.db.WithContext(ctx).Select("id", "updated_at").First(&activity, activityID).Error; err == nil {
+		s.invalidate(ctx, &activity)
+	}
+	return nil
+}
+
+// invalidate drops the cached checklists of an activity in every season
+func (s *ChecklistService) invalidate(ctx context.Context, activity *models.Activity) {
+	for _, season := range models.Seasons {
+		if err := s.checklists.Invalidate(ctx, checklistKey(activity, season)); err != nil {
+			log.Printf("[CHECKLIST] Invalidate checklist of activity %d in %s: %v", activity.ID, season, err)
+		}
+	}
+}
+
+// Tool returns the chat tool generating packing checklists
+func (s *ChecklistService) Tool() chat.Tool {
+	return chat.Tool{
+		Name: "get_packing_checklist",
+		Description: "Generate a packing checklist for an activity: water amount, food, clothing layers, lights and activity-specific gear, " +
+			"based on its type, duration and the season, adjusted to the forecast when the date is within it. " +
+			"Use it for questions such as \"what should I bring?\". Omit date for today.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"activity_id": {"type": "integer"},
+				"date": {"type": "string", "description": "YYYY-MM-DD"}
+			},
+			"required": ["activity_id"]
+		}`),
+		Call: s.getChecklist,
+	}
+}
+
+// getChecklist implements the get_packing_checklist tool
+func (s *ChecklistService) getChecklist(ctx context.Context, _ *chat.Run, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		ActivityID uint   `json:"activity_id"`
+		Date       string `json:"date"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, err)
+	}
+	if args.ActivityID == 0 {
+		return nil, fmt.Errorf("%w: activity_id is required", chat.ErrInvalidToolArguments)
+	}
+
+	checklist, err := s.Generate(ctx, args.ActivityID, args.Date)
+	if errors.Is(err, ErrInvalidDate) {
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, err)
+	}
+	return checklist, err
+}
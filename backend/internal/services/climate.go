@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/cache"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/climate"
+	"community-chatbot/internal/models"
+)
+
+// climateBucketDegrees is the grid normals are computed and cached on, about
+// 25 km; climate barely changes within a cell
+const climateBucketDegrees = 0.25
+
+var (
+	// ErrInvalidMonth is returned for months outside 1-12
+	ErrInvalidMonth = apperr.New(apperr.Invalid, "month must be between 1 and 12")
+	// ErrClimateLocationRequired is returned by the climate tool when neither the
+	// model nor the user's context gives a location
+	ErrClimateLocationRequired = apperr.New(apperr.Invalid, "a location is required; ask the user where they are going")
+)
+
+// ClimateService provides historical weather statistics (climate normals) so
+// the chat can answer seasonality questions with observations rather than
+// forecasts. Normals are computed from downloaded daily weather per location
+// bucket and cached for long, since they only change once a year.
+type ClimateService struct {
+	history climate.History
+	cache   *cache.Cache
+	years   int
+}
+
+// ClimateNormals are the normals of a location bucket
+type ClimateNormals struct {
+	// Location is the center of the bucket the normals were computed for
+	Location climate.Point   `json:"location"`
+	FromYear int             `json:"from_year"`
+	ToYear   int             `json:"to_year"`
+	Months   []climate.Month `json:"months"`
+}
+
+// NewClimateService creates a new climate service averaging over the last
+// years complete years; normals may be nil to disable caching
+func NewClimateService(history climate.History, normals *cache.Cache, years int) *ClimateService {
+	return &ClimateService{
+		history: history,
+		cache:   normals,
+		years:   years,
+	}
+}
+
+// Normals returns the climate normals of the bucket containing loc, for a
+// single month or for all months when month is 0
+func (s *ClimateService) Normals(ctx context.Context, loc models.Location, month int) (*ClimateNormals, error) {
+	if month < 0 || month > 12 {
+		return nil, ErrInvalidMonth
+	}
+
+	center := climate.Point{Lat: climateBucket(loc.Lat, 90), Lng: climateBucket(loc.Lng, 180)}
+	toYear := time.Now().UTC().Year() - 1
+	fromYear := toYear - s.years + 1
+	key := fmt.Sprintf("%.2f,%.2f/%d-%d", center.Lat, center.Lng, fromYear, toYear)
+
+	months, err := cache.Lookup(ctx, s.cache, key, func(ctx context.Context) ([]climate.Month, error) {
+		days, err := s.history.Daily(ctx, center,
+			time.Date(fromYear, time.January, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(toYear, time.December, 31, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			return nil, fmt.Errorf("failed to download weather history: %w", err)
+		}
+		return climate.Summarize(days), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	normals := &ClimateNormals{Location: center, FromYear: fromYear, ToYear: toYear, Months: months}
+	if month != 0 {
+		normals.Months = nil
+		for _, m := range months {
+			if m.Month == month {
+				normals.Months = append(normals.Months, m)
+			}
+		}
+	}
+	return normals, nil
+}
+
+// climateBucket snaps a coordinate to the center of its bucket. limit is 90 for
+// latitudes and 180 for longitudes; coordinates at or beyond it fall in the
+// edge bucket, so centers are valid coordinates.
+func climateBucket(v, limit float64) float64 {
+	v = math.Max(-limit, math.Min(v, limit))
+	cell := math.Min(math.Floor(v/climateBucketDegrees), math.Round(limit/climateBucketDegrees)-1)
+	return cell*climateBucketDegrees + climateBucketDegrees/2
+}
+
+// Tool returns the chat tool looking up climate normals
+func (s *ClimateService) Tool() chat.Tool {
+	return chat.Tool{
+		Name: "get_climate",
+		Description: "Look up the climate normals of a month at a location: average high and low temperature, precipitation, rainy days and share of dry days over recent years. " +
+			"Use it for questions about what weather is usual in a season, not for forecasts. The user's location is used when lat and lng are omitted.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"month": {"type": "integer", "minimum": 1, "maximum": 12, "description": "1 for January"},
+				"lat": {"type": "number"},
+				"lng": {"type": "number"}
+			},
+			"required": ["month"]
+		}`),
+		Call: s.getClimate,
+	}
+}
+
+// getClimate implements the get_climate tool
+func (s *ClimateService) getClimate(ctx context.Context, run *chat.Run, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		Month int      `json:"month"`
+		Lat   *float64 `json:"lat"`
+		Lng   *float64 `json:"lng"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, err)
+	}
+	if args.Month < 1 || args.Month > 12 {
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, ErrInvalidMonth)
+	}
+
+	var loc models.Location
+	switch {
+	case args.Lat != nil && args.Lng != nil:
+		loc = models.Location{Lat: *args.Lat, Lng: *args.Lng}
+		if loc.Lat < -90 || loc.Lat > 90 || loc.Lng < -180 || loc.Lng > 180 {
+			return nil, fmt.Errorf("%w: lat and lng are out of range", chat.ErrInvalidToolArguments)
+		}
+	case run.UserContext != nil && run.UserContext.Location != nil:
+		loc = *run.UserContext.Location
+	default:
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, ErrClimateLocationRequired)
+	}
+
+	return s.Normals(ctx, loc, args.Month)
+}
@@ -0,0 +1,527 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxCollectionItems caps the activities of one collection
+const maxCollectionItems = 100
+
+// Limits for the find_collections tool
+const (
+	toolCollectionsDefaultLimit = 3
+	toolCollectionsMaxLimit     = 5
+	toolCollectionItems         = 10
+)
+
+// collectionSearchVector is the full-text document of a collection; the
+// configuration matches searchTSQuery
+const collectionSearchVector = "to_tsvector('english', title || ' ' || COALESCE(description, ''))"
+
+var (
+	// ErrNotCollectionOwner is returned when a user changes a collection they do not own
+	ErrNotCollectionOwner = apperr.New(apperr.Forbidden, "collection belongs to another user")
+	// ErrInvalidCollectionItems is returned for items that are not distinct published activities
+	ErrInvalidCollectionItems = apperr.New(apperr.Invalid, "collections can only contain published activities, each once")
+	// ErrTooManyCollectionItems is returned for collections over maxCollectionItems
+	ErrTooManyCollectionItems = apperr.New(apperr.Invalid, fmt.Sprintf("a collection can contain at most %d activities", maxCollectionItems))
+	// ErrCollectionNotPublic is returned when following a private collection
+	ErrCollectionNotPublic = apperr.New(apperr.Invalid, "only public collections can be followed")
+)
+
+// CollectionService stores curated lists of activities and who follows them
+type CollectionService struct {
+	db *gorm.DB
+	// staleAfter marks activities in tool results outdated when they were not verified within it
+	staleAfter time.Duration
+}
+
+// NewCollectionService creates a new collection service
+func NewCollectionService(db *gorm.DB, staleAfter time.Duration) *CollectionService {
+	return &CollectionService{
+		db:         db,
+		staleAfter: staleAfter,
+	}
+}
+
+// CollectionFilters narrow collection listings, which only include public
+// collections unless IncludePrivate is set
+type CollectionFilters struct {
+	// Query is searched in titles and descriptions
+	Query string
+	// UserID lists the collections of one user
+	UserID uint
+	// FollowedBy lists the collections a user follows
+	FollowedBy uint
+	// IncludePrivate also lists private collections; for a user's own listing only
+	IncludePrivate bool
+}
+
+// List returns a page of collections without their items, curated and most
+// followed first, and the total count. IsFollowed is set when viewerID is not zero.
+func (s *CollectionService) List(ctx context.Context, filters CollectionFilters, viewerID uint, page, pageSize int) ([]models.Collection, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.Collection{})
+	if !filters.IncludePrivate {
+		query = query.Where("visibility = ?", models.CollectionPublic)
+	}
+	if filters.UserID != 0 {
+		query = query.Where("user_id = ?", filters.UserID)
+	}
+	if filters.FollowedBy != 0 {
+		query = query.Where("id IN (?)", s.db.Model(&models.CollectionFollow{}).
+			Select("collection_id").Where("user_id = ?", filters.FollowedBy))
+	}
+	if filters.Query != "" {
+		query = query.Where(collectionSearchVector+" @@ "+searchTSQuery, filters.Query)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count collections: %w", err)
+	}
+
+	var collections []models.Collection
+	if err := query.Order("curated DESC, follower_count DESC, updated_at DESC, id").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&collections).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list collections: %w", err)
+	}
+
+	marked := make([]*models.Collection, len(collections))
+	for i := range collections {
+		marked[i] = &collections[i]
+	}
+	if err := s.markFollowed(ctx, viewerID, marked); err != nil {
+		return nil, 0, err
+	}
+	return collections, total, nil
+}
+
+// Get returns a collection with its items in order. Private collections are
+// only found for their owner; items whose activity was deleted or unpublished
+// are left out.
+func (s *CollectionService) Get(ctx context.Context, id, viewerID uint) (*models.Collection, error) {
+	collection, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !collection.IsPublic() && collection.UserID != viewerID {
+		return nil, fmt.Errorf("failed to load collection %d: %w", id, gorm.ErrRecordNotFound)
+	}
+	if err := s.markFollowed(ctx, viewerID, []*models.Collection{collection}); err != nil {
+		return nil, err
+	}
+	return collection, nil
+}
+
+// Create stores a collection of userID with items in the given order.
+// Collections of moderators are curated.
+func (s *CollectionService) Create(ctx context.Context, collection *models.Collection, items []models.CollectionItem) error {
+	if err := s.checkItems(ctx, items); err != nil {
+		return err
+	}
+	curated, err := canModerate(ctx, s.db, collection.UserID)
+	if err != nil {
+		return err
+	}
+	collection.Curated = curated
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit(clause.Associations).Create(collection).Error; err != nil {
+			return fmt.Errorf("failed to create collection: %w", err)
+		}
+		return replaceCollectionItems(tx, collection.ID, items)
+	})
+	if err != nil {
+		return err
+	}
+
+	created, err := s.load(ctx, collection.ID)
+	if err != nil {
+		return err
+	}
+	*collection = *created
+	return nil
+}
+
+// Update replaces the title, description, visibility and items of a
+// collection owned by userID, or of any collection for moderators
+func (s *CollectionService) Update(ctx context.Context, id, userID uint, changes *models.Collection, items []models.CollectionItem) (*models.Collection, error) {
+	collection, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkOwner(ctx, collection, userID); err != nil {
+		return nil, err
+	}
+	if err := s.checkItems(ctx, items); err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Collection{ID: id}).
+			Select("title", "description", "visibility").
+			Updates(changes).Error; err != nil {
+			return fmt.Errorf("failed to update collection %d: %w", id, err)
+		}
+		return replaceCollectionItems(tx, id, items)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.markFollowed(ctx, userID, []*models.Collection{updated}); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// Delete soft-deletes a collection owned by userID, or any collection for moderators
+func (s *CollectionService) Delete(ctx context.Context, id, userID uint) error {
+	collection, err := s.load(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.checkOwner(ctx, collection, userID); err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("collection_id = ?", id).Delete(&models.CollectionFollow{}).Error; err != nil {
+			return fmt.Errorf("failed to delete followers of collection %d: %w", id, err)
+		}
+		if err := tx.Delete(&models.Collection{}, id).Error; err != nil {
+			return fmt.Errorf("failed to delete collection %d: %w", id, err)
+		}
+		return nil
+	})
+}
+
+// Follow makes a user follow a public collection; following it again changes nothing
+func (s *CollectionService) Follow(ctx context.Context, userID, id uint) (*models.Collection, error) {
+	collection, err := s.Get(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !collection.IsPublic() {
+		return nil, ErrCollectionNotPublic
+	}
+
+	follow := &models.CollectionFollow{UserID: userID, CollectionID: id}
+	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(follow).Error; err != nil {
+		return nil, fmt.Errorf("failed to follow collection %d: %w", id, err)
+	}
+	if err := recountCollectionFollowers(s.db.WithContext(ctx), []uint{id}); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id, userID)
+}
+
+// Unfollow stops a user following a collection; unfollowing one that is not
+// followed changes nothing
+func (s *CollectionService) Unfollow(ctx context.Context, userID, id uint) error {
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ? AND collection_id = ?", userID, id).
+		Delete(&models.CollectionFollow{}).Error; err != nil {
+		return fmt.Errorf("failed to unfollow collection %d: %w", id, err)
+	}
+	return recountCollectionFollowers(s.db.WithContext(ctx), []uint{id})
+}
+
+// load returns a collection with its items regardless of its visibility
+func (s *CollectionService) load(ctx context.Context, id uint) (*models.Collection, error) {
+	var collection models.Collection
+	if err := s.db.WithContext(ctx).First(&collection, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to load collection %d: %w", id, err)
+	}
+	if err := s.loadItems(ctx, []*models.Collection{&collection}, 0); err != nil {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+// loadItems sets the items of collections in order with their activity, at
+// most perCollection each unless it is zero. Items of deleted or unpublished
+// activities are left out.
+func (s *CollectionService) loadItems(ctx context.Context, collections []*models.Collection, perCollection int) error {
+	if len(collections) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(collections))
+	for i, collection := range collections {
+		ids[i] = collection.ID
+	}
+
+	var items []models.CollectionItem
+	if err := s.db.WithContext(ctx).Model(&models.CollectionItem{}).
+		Joins("JOIN activities ON activities.id = collection_items.activity_id AND activities.deleted_at IS NULL AND activities.approved").
+		Where("collection_items.collection_id IN ?", ids).
+		Select("collection_items.*").
+		Preload("Activity").
+		Order("collection_items.collection_id, collection_items.position").
+		Find(&items).Error; err != nil {
+		return fmt.Errorf("failed to load collection items: %w", err)
+	}
+
+	byCollection := make(map[uint][]models.CollectionItem, len(collections))
+	for _, item := range items {
+		if perCollection > 0 && len(byCollection[item.CollectionID]) == perCollection {
+			continue
+		}
+		byCollection[item.CollectionID] = append(byCollection[item.CollectionID], item)
+	}
+	for _, collection := range collections {
+		collection.Items = byCollection[collection.ID]
+		if collection.Items == nil {
+			collection.Items = []models.CollectionItem{}
+		}
+	}
+	return nil
+}
+
+// checkItems fails unless items are at most maxCollectionItems distinct
+// approved activities
+func (s *CollectionService) checkItems(ctx context.Context, items []models.CollectionItem) error {
+	if len(items) > maxCollectionItems {
+		return ErrTooManyCollectionItems
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	ids := make([]uint, 0, len(items))
+	seen := make(map[uint]bool, len(items))
+	for _, item := range items {
+		if seen[item.ActivityID] {
+			return ErrInvalidCollectionItems
+		}
+		seen[item.ActivityID] = true
+		ids = append(ids, item.ActivityID)
+	}
+
+	var published int64
+	if err := s.db.WithContext(ctx).Model(&models.Activity{}).
+		Where("id IN ? AND approved = ?", ids, true).
+		Count(&published).Error; err != nil {
+		return fmt.Errorf("failed to check collection items: %w", err)
+	}
+	if published != int64(len(ids)) {
+		return ErrInvalidCollectionItems
+	}
+	return nil
+}
+
+// checkOwner fails with ErrNotCollectionOwner unless userID owns the
+// collection or may moderate content. Private collections of others are not
+// found, as for Get.
+func (s *CollectionService) checkOwner(ctx context.Context, collection *models.Collection, userID uint) error {
+	if collection.UserID == userID {
+		return nil
+	}
+	moderator, err := canModerate(ctx, s.db, userID)
+	if err != nil {
+		return err
+	}
+	switch {
+	case moderator:
+		return nil
+	case !collection.IsPublic():
+		return fmt.Errorf("failed to load collection %d: %w", collection.ID, gorm.ErrRecordNotFound)
+	default:
+		return ErrNotCollectionOwner
+	}
+}
+
+// markFollowed sets IsFollowed on collections for a signed-in viewer
+func (s *CollectionService) markFollowed(ctx context.Context, viewerID uint, collections []*models.Collection) error {
+	if viewerID == 0 || len(collections) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(collections))
+	for i, collection := range collections {
+		ids[i] = collection.ID
+	}
+
+	var followed []uint
+	if err := s.db.WithContext(ctx).Model(&models.CollectionFollow{}).
+		Where("user_id = ? AND collection_id IN ?", viewerID, ids).
+		Pluck("collection_id", &followed).Error; err != nil {
+		return fmt.Errorf("failed to load followed collections: %w", err)
+	}
+	isFollowed := make(map[uint]bool, len(followed))
+	for _, id := range followed {
+		isFollowed[id] = true
+	}
+	for _, collection := range collections {
+		following := isFollowed[collection.ID]
+		collection.IsFollowed = &following
+	}
+	return nil
+}
+
+// replaceCollectionItems replaces the items of a collection, numbering them in order
+func replaceCollectionItems(tx *gorm.DB, collectionID uint, items []models.CollectionItem) error {
+	if err := tx.Where("collection_id = ?", collectionID).Delete(&models.CollectionItem{}).Error; err != nil {
+		return fmt.Errorf("failed to replace items of collection %d: %w", collectionID, err)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	rows := make([]models.CollectionItem, len(items))
+	for i, item := range items {
+		rows[i] = models.CollectionItem{
+			CollectionID: collectionID,
+			ActivityID:   item.ActivityID,
+			Position:     i + 1,
+			Note:         item.Note,
+		}
+	}
+	if err := tx.Omit(clause.Associations).Create(&rows).Error; err != nil {
+		return fmt.Errorf("failed to store items of collection %d: %w", collectionID, err)
+	}
+	return nil
+}
+
+// recountCollectionFollowers updates the follower counts of collections.
+// UpdateColumn keeps updated_at, which orders listings by content changes.
+func recountCollectionFollowers(db *gorm.DB, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := db.Model(&models.Collection{}).Where("id IN ?", ids).
+		UpdateColumn("follower_count", gorm.Expr("(SELECT COUNT(*) FROM collection_follows WHERE collection_follows.collection_id = collections.id)")).Error; err != nil {
+		return fmt.Errorf("failed to count collection followers: %w", err)
+	}
+	return nil
+}
+
+// deleteUserCollections deletes the collections of a user and stops them
+// following others' collections, within an account deletion
+func deleteUserCollections(tx *gorm.DB, userID uint) error {
+	var followed []uint
+	if err := tx.Model(&models.CollectionFollow{}).Where("user_id = ?", userID).
+		Pluck("collection_id", &followed).Error; err != nil {
+		return fmt.Errorf("failed to load followed collections of user %d: %w", userID, err)
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.CollectionFollow{}).Error; err != nil {
+		return fmt.Errorf("failed to unfollow collections of user %d: %w", userID, err)
+	}
+	if err := recountCollectionFollowers(tx, followed); err != nil {
+		return err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.Collection{}).Error; err != nil {
+		return fmt.Errorf("failed to delete collections of user %d: %w", userID, err)
+	}
+	return nil
+}
+
+// toolCollection is the compact collection representation returned to the model
+type toolCollection struct {
+	ID          uint                     `json:"id"`
+	Title       string                   `json:"title"`
+	Description string                   `json:"description,omitempty"`
+	Curated     bool                     `json:"curated"`
+	Followers   int                      `json:"followers"`
+	Activities  []toolCollectionActivity `json:"activities"`
+}
+
+// toolCollectionActivity is an activity of a collection with the curator's note
+type toolCollectionActivity struct {
+	toolActivity
+	Note string `json:"note,omitempty"`
+}
+
+// Tool returns the chat tool finding collections matching what the user looks for
+func (s *CollectionService) Tool() chat.Tool {
+	return chat.Tool{
+		Name: "find_collections",
+		Description: "Find community collections of activities, such as \"Best autumn hikes\" or \"Rainy day options\", matching what the user is looking for. " +
+			"Call it before search_activities when recommending activities: recommend from matching collections first, naming the collection and using its notes, " +
+			"preferring curated ones, and only search further when none match.",
+		Parameters: json.RawMessage(fmt.Sprintf(`{
+			"type": "object",
+			"properties": {
+				"query": {"type": "string", "description": "What the user is looking for, e.g. \"rainy day\" or \"autumn hikes\""},
+				"limit": {"type": "integer", "minimum": 1, "maximum": %d}
+			},
+			"required": ["query"]
+		}`, toolCollectionsMaxLimit)),
+		Call: s.findCollections,
+	}
+}
+
+// findCollections implements the find_collections tool. Public collections and
+// the user's own match, curated ones first.
+func (s *CollectionService) findCollections(ctx context.Context, run *chat.Run, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, err)
+	}
+	if args.Query == "" {
+		return nil, fmt.Errorf("%w: query is required", chat.ErrInvalidToolArguments)
+	}
+	if args.Limit <= 0 || args.Limit > toolCollectionsMaxLimit {
+		args.Limit = toolCollectionsDefaultLimit
+	}
+
+	var collections []models.Collection
+	if err := s.db.WithContext(ctx).Model(&models.Collection{}).
+		Where("visibility = ? OR (user_id = ? AND user_id <> 0)", models.CollectionPublic, run.UserID).
+		Where(collectionSearchVector+" @@ "+searchTSQuery, args.Query).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:  "curated DESC, ts_rank_cd(" + collectionSearchVector + ", " + searchTSQuery + ") DESC, follower_count DESC, id",
+			Vars: []interface{}{args.Query},
+		}}).
+		Limit(args.Limit).
+		Find(&collections).Error; err != nil {
+		return nil, fmt.Errorf("failed to find collections: %w", err)
+	}
+
+	loaded := make([]*models.Collection, len(collections))
+	for i := range collections {
+		loaded[i] = &collections[i]
+	}
+	if err := s.loadItems(ctx, loaded, toolCollectionItems); err != nil {
+		return nil, err
+	}
+
+	results := []toolCollection{}
+	for _, collection := range collections {
+		result := toolCollection{
+			ID:          collection.ID,
+			Title:       collection.Title,
+			Description: collection.Description,
+			Curated:     collection.Curated,
+			Followers:   collection.FollowerCount,
+			Activities:  []toolCollectionActivity{},
+		}
+		for _, item := range collection.Items {
+			if item.Activity == nil {
+				continue
+			}
+			result.Activities = append(result.Activities, toolCollectionActivity{
+				toolActivity: newToolActivity(*item.Activity, s.staleAfter),
+				Note:         item.Note,
+			})
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// maxMentionsPerComment caps the users one comment can notify
+const maxMentionsPerComment = 10
+
+// mentionPattern matches @[Name](user:<id>) mention markup
+var mentionPattern = regexp.MustCompile(`@\[([^\]\n]{1,100})\]\(user:(\d{1,10})\)`)
+
+var (
+	// ErrTooManyMentions is returned for comments mentioning more users than allowed
+	ErrTooManyMentions = apperr.New(apperr.Invalid, fmt.Sprintf("a comment can mention at most %d users", maxMentionsPerComment))
+	// ErrUnknownMention is returned when a comment mentions a user who does not exist
+	ErrUnknownMention = apperr.New(apperr.Invalid, "mentioned user does not exist")
+	// ErrNotCommentAuthor is returned when a user deletes a comment they did not write
+	ErrNotCommentAuthor = apperr.New(apperr.Forbidden, "comment belongs to another user")
+)
+
+// CommentService stores activity comments and notifies the users they mention
+type CommentService struct {
+	db            *gorm.DB
+	notifications *NotificationService
+}
+
+// NewCommentService creates a new comment service
+func NewCommentService(db *gorm.DB, notifications *NotificationService) *CommentService {
+	return &CommentService{
+		db:            db,
+		notifications: notifications,
+	}
+}
+
+// Create stores a comment on an approved activity, resolving its mentions, and
+// notifies the mentioned users other than the author. Pending and rejected
+// activities are reported as not found.
+func (s *CommentService) Create(ctx context.Context, comment *models.Comment) error {
+	var activity models.Activity
+	if err := s.db.WithContext(ctx).Select("id", "name").Where("approved = ?", true).
+		First(&activity, comment.ActivityID).Error; err != nil {
+		return fmt.Errorf("failed to load activity %d: %w", comment.ActivityID, err)
+	}
+
+	body, mentions, err := s.resolveMentions(ctx, comment.Body)
+	if err != nil {
+		return err
+	}
+	comment.Body = body
+	comment.Mentions = mentions
+	if err := s.db.WithContext(ctx).Create(comment).Error; err != nil {
+		return fmt.Errorf("failed to create comment: %w", err)
+	}
+
+	var recipients []uint
+	seen := map[uint]bool{comment.UserID: true}
+	for _, mention := range mentions {
+		if !seen[mention.UserID] {
+			seen[mention.UserID] = true
+			recipients = append(recipients, mention.UserID)
+		}
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+
+	// The comment is stored; a failed notification does not undo it
+	notification, err := s.mentionNotification(ctx, comment, activity)
+	if err == nil {
+		_, err = s.notifications.Notify(ctx, recipients, notification)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Notify mentions in comment %d: %v", comment.ID, err)
+	}
+	return nil
+}
+
+// List returns a page of an activity's comments, oldest first, and the total count
+func (s *CommentService) List(ctx context.Context, activityID uint, page, pageSize int) ([]models.Comment, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.Comment{}).Where("activity_id = ?", activityID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count comments: %w", err)
+	}
+
+	var comments []models.Comment
+	if err := query.Order("created_at, id").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&comments).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list comments: %w", err)
+	}
+	return comments, total, nil
+}
+
+// Delete soft-deletes a comment written by userID, or any comment for moderators
+func (s *CommentService) Delete(ctx context.Context, activityID, id, userID uint) error {
+	var comment models.Comment
+	if err := s.db.WithContext(ctx).Select("id", "user_id").Where("activity_id = ?", activityID).First(&comment, id).Error; err != nil {
+		return fmt.Errorf("failed to load comment %d: %w", id, err)
+	}
+	if comment.UserID != userID {
+		moderator, err := canModerate(ctx, s.db, userID)
+		if err != nil {
+			return err
+		}
+		if !moderator {
+			return ErrNotCommentAuthor
+		}
+	}
+	if err := s.db.WithContext(ctx).Delete(&comment).Error; err != nil {
+		return fmt.Errorf("failed to delete comment %d: %w", id, err)
+	}
+	return nil
+}
+
+// resolveMentions parses the mention markup of body and checks the mentioned
+// users exist. It returns body with the names in the markup replaced by the
+// users' own, so comments cannot impersonate someone else's name.
+func (s *CommentService) resolveMentions(ctx context.Context, body string) (string, []models.Mention, error) {
+	matches := mentionPattern.FindAllStringSubmatchIndex(body, -1)
+	if len(matches) == 0 {
+		return body, []models.Mention{}, nil
+	}
+
+	ids := make([]uint, len(matches))
+	unique := map[uint]bool{}
+	for i, m := range matches {
+		id, err := strconv.ParseUint(body[m[4]:m[5]], 10, 32)
+		if err != nil || id == 0 {
+			return "", nil, ErrUnknownMention
+		}
+		ids[i] = uint(id)
+		unique[uint(id)] = true
+	}
+	if len(unique) > maxMentionsPerComment {
+		return "", nil, ErrTooManyMentions
+	}
+
+	var users []models.User
+	if err := s.db.WithContext(ctx).Select("id", "name").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to load mentioned users: %w", err)
+	}
+	names := make(map[uint]string, len(users))
+	for _, user := range users {
+		names[user.ID] = mentionName(user)
+	}
+
+	var resolved strings.Builder
+	mentions := make([]models.Mention, len(matches))
+	last := 0
+	for i, m := range matches {
+		name, ok := names[ids[i]]
+		if !ok {
+			return "", nil, fmt.Errorf("%w: user %d", ErrUnknownMention, ids[i])
+		}
+		resolved.WriteString(body[last:m[0]])
+		start := utf8.RuneCountInString(resolved.String())
+		fmt.Fprintf(&resolved, "@[%s](user:%d)", name, ids[i])
+		mentions[i] = models.Mention{
+			UserID: ids[i],
+			Name:   name,
+			Start:  start,
+			End:    utf8.RuneCountInString(resolved.String()),
+		}
+		last = m[1]
+	}
+	resolved.WriteString(body[last:])
+	return resolved.String(), mentions, nil
+}
+
+// mentionName returns a user's name as it can appear in mention markup
+func mentionName(user models.User) string {
+	name := strings.Join(strings.Fields(strings.ReplaceAll(user.Name, "]", "")), " ")
+	if name == "" {
+		return fmt.Sprintf("user %d", user.ID)
+	}
+	return name
+}
+
+// mentionNotification tells mentioned users who mentioned them where
+func (s *CommentService) mentionNotification(ctx context.Context, comment *models.Comment, activity models.Activity) (models.Notification, error) {
+	var author models.User
+	if err := s.db.WithContext(ctx).Select("id", "name").First(&author, comment.UserID).Error; err != nil {
+		return models.Notification{}, fmt.Errorf("failed to load comment author %d: %w", comment.UserID, err)
+	}
+	if author.Name == "" {
+		author.Name = "Someone"
+	}
+
+	activityID := activity.ID
+	return models.Notification{
+		Kind:       models.NotificationMention,
+		Title:      fmt.Sprintf("%s mentioned you on %s", author.Name, activity.Name),
+		Body:       mentionPattern.ReplaceAllString(comment.Body, "@$1"),
+		ActivityID: &activityID,
+		DedupeKey:  fmt.Sprintf("%s:%d", models.NotificationMention, comment.ID),
+	}, nil
+}
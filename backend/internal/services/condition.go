@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+
+	"community-chatbot/internal/agui"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/geo"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// closureAudienceBatchSize is how many user preference rows are scanned per query
+const closureAudienceBatchSize = 500
+
+// Words marking a chat message as a condition report, by the status they report.
+// Closures are checked first, so "the bridge is broken and the trail closed" is a closure.
+var (
+	closedReportWords  = regexp.MustCompile(`(?i)\b(closed|impassable|washed out|collapsed|flooded|blocked)\b`)
+	cautionReportWords = regexp.MustCompile(`(?i)\b(broken|damaged|fallen trees?|tree down|missing|muddy|icy|slippery|overgrown|eroded|vandali[sz]ed|rockfall|landslide)\b`)
+	openReportWords    = regexp.MustCompile(`(?i)\b(reopened|open again|cleared|repaired|fixed)\b`)
+	questionStart      = regexp.MustCompile(`(?i)^(is|are|was|were|can|could|does|do|will|has|have|any|what|when|where|how|why)\b`)
+)
+
+// ConditionService records condition reports and alerts nearby users about closures
+type ConditionService struct {
+	db            *gorm.DB
+	activities    *ActivityService
+	notifications *NotificationService
+}
+
+// NewConditionService creates a new condition service
+func NewConditionService(db *gorm.DB, activities *ActivityService, notifications *NotificationService) *ConditionService {
+	return &ConditionService{
+		db:            db,
+		activities:    activities,
+		notifications: notifications,
+	}
+}
+
+// Report stores a condition report, marks the activity as recently verified and,
+// for closures, alerts affected users. Alert failures are logged, not returned,
+// because the report itself was stored.
+func (s *ConditionService) Report(ctx context.Context, report *models.ConditionReport) error {
+	var activity models.Activity
+	if err := s.db.WithContext(ctx).First(&activity, report.ActivityID).Error; err != nil {
+		return fmt.Errorf("failed to load activity %d: %w", report.ActivityID, err)
+	}
+
+	if err := s.db.WithContext(ctx).Create(report).Error; err != nil {
+		return fmt.Errorf("failed to create condition report: %w", err)
+	}
+	if err := s.activities.MarkVerified(ctx, activity.ID); err != nil {
+		log.Printf("[CONDITIONS] Activity %d: %v", activity.ID, err)
+	}
+
+	if report.Status == models.ConditionClosed {
+		if notified, err := s.alertClosure(ctx, activity); err != nil {
+			log.Printf("[CONDITIONS] Activity %d: closure alerts failed after %d users: %v", activity.ID, notified, err)
+		} else {
+			log.Printf("[CONDITIONS] Activity %d: closure alerted %d users", activity.ID, notified)
+		}
+	}
+	return nil
+}
+
+// Stage returns the pipeline stage that recognizes chat messages reporting the
+// condition of a single activity mentioned by name, such as "the trail sign at
+// Eagle Falls is broken". It sends a CONDITION_REPORT_PROPOSED event for the user
+// to confirm through the regular report endpoint, crediting them as reporter,
+// and tells the model so it can thank them. Questions are not reports. Lookup
+// failures are logged and the run continues.
+func (s *ConditionService) Stage() chat.Stage {
+	return chat.StageFunc{
+		StageName: "condition_reports",
+		Fn: func(ctx context.Context, run *chat.Run, next chat.Handler) error {
+			status := reportedStatus(run.Message)
+			if status == "" {
+				return next(ctx, run)
+			}
+
+			activity, err := s.mentionedActivity(ctx, run.Message)
+			if err != nil {
+				log.Printf("[CHAT] Run %s: condition report lookup failed: %v", run.ID, err)
+			}
+			if activity == nil {
+				return next(ctx, run)
+			}
+
+			// Notes of condition reports are limited to 2000 characters
+			note := []rune(run.Message)
+			if len(note) > 2000 {
+				note = note[:2000]
+			}
+			if err := run.Emit(agui.CreateConditionReportProposedEvent(agui.ConditionReportProposalData{
+				ActivityID:   activity.ID,
+				ActivityName: activity.Name,
+				Status:       status,
+				Note:         string(note),
+				ReportURL:    fmt.Sprintf("/api/v1/activities/%d/conditions", activity.ID),
+				SignIn:       run.UserID == 0,
+			})); err != nil {
+				return err
+			}
+			run.AddNote(fmt.Sprintf("The user appears to report that %s (activity://%d) is %s. They were offered to file it as a condition report; "+
+				"thank them and ask them to confirm it, without treating the report as verified.", activity.Name, activity.ID, status))
+			return next(ctx, run)
+		},
+	}
+}
+
+// reportedStatus returns the condition a message reports, or "" when it does not read as a report
+func reportedStatus(message string) string {
+	message = strings.TrimSpace(message)
+	if strings.HasSuffix(message, "?") || questionStart.MatchString(message) {
+		return ""
+	}
+	switch {
+	case closedReportWords.MatchString(message):
+		return models.ConditionClosed
+	case cautionReportWords.MatchString(message):
+		return models.ConditionCaution
+	case openReportWords.MatchString(message):
+		return models.ConditionOpen
+	}
+	return ""
+}
+
+// mentionsName matches activities whose name appears in the message given as
+// its argument. Wildcards in names are escaped, so they only match themselves.
+const mentionsName = `? ILIKE '%' || replace(replace(replace(name, '\', '\\'), '%', '\%'), '_', '\_') || '%'`
+
+// mentionedActivity returns the approved activity mentioned by name in a message,
+// or nil when none or several are
+func (s *ConditionService) mentionedActivity(ctx context.Context, message string) (*models.Activity, error) {
+	var activities []models.Activity
+	if err := s.db.WithContext(ctx).
+		Select("id", "name").
+		Where("approved = ? AND "+mentionsName, true, message).
+		Limit(2).
+		Find(&activities).Error; err != nil {
+		return nil, fmt.Errorf("failed to find mentioned activities: %w", err)
+	}
+	if len(activities) != 1 {
+		return nil, nil
+	}
+	return &activities[0], nil
+}
+
+// Recent returns the latest condition reports for an activity
+func (s *ConditionService) Recent(ctx context.Context, activityID uint, limit int) ([]models.ConditionReport, error) {
+	var reports []models.ConditionReport
+	if err := s.db.WithContext(ctx).
+		Where("activity_id = ?", activityID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&reports).Error; err != nil {
+		return nil, fmt.Errorf("failed to list condition reports of activity %d: %w", activityID, err)
+	}
+	return reports, nil
+}
+
+// alertClosure notifies the closure's audience once per closure: repeated closed
+// reports share the dedupe key until an open or caution report ends the closure
+func (s *ConditionService) alertClosure(ctx context.Context, activity models.Activity) (int, error) {
+	since, err := s.closureStart(ctx, activity.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	audience, err := s.closureAudience(ctx, activity)
+	if err != nil {
+		return 0, err
+	}
+	if len(audience) == 0 {
+		return 0, nil
+	}
+
+	activityID := activity.ID
+	return s.notifications.Notify(ctx, audience, models.Notification{
+		Kind:       models.NotificationTrailClosure,
+		Title:      fmt.Sprintf("%s is closed", activity.Name),
+		Body:       fmt.Sprintf("%s was reported closed. Check conditions before heading out.", activity.Name),
+		ActivityID: &activityID,
+		DedupeKey:  fmt.Sprintf("%s:%d:%d", models.NotificationTrailClosure, activity.ID, since),
+	})
+}
+
+// closureStart returns the ID of the first closed report since the activity was
+// last reported open or caution, identifying the current closure
+func (s *ConditionService) closureStart(ctx context.Context, activityID uint) (uint, error) {
+	query := s.db.WithContext(ctx).Model(&models.ConditionReport{}).
+		Where("activity_id = ? AND status = ?", activityID, models.ConditionClosed)
+
+	lastOpen := s.db.Model(&models.ConditionReport{}).
+		Select("MAX(id)").
+		Where("activity_id = ? AND status <> ?", activityID, models.ConditionClosed)
+
+	var first uint
+	if err := query.Where("id > COALESCE((?), 0)", lastOpen).
+		Select("MIN(id)").
+		Scan(&first).Error; err != nil {
+		return 0, fmt.Errorf("failed to find closure start of activity %d: %w", activityID, err)
+	}
+	return first, nil
+}
+
+// closureAudience returns the users who saved the activity, have a saved
+// search matching it or whose home search radius includes it
+func (s *ConditionService) closureAudience(ctx context.Context, activity models.Activity) ([]uint, error) {
+	var audience []uint
+	if err := s.db.WithContext(ctx).Model(&models.Favorite{}).
+		Where("activity_id = ?", activity.ID).
+		Pluck("user_id", &audience).Error; err != nil {
+		return nil, fmt.Errorf("failed to find users who saved activity %d: %w", activity.ID, err)
+	}
+
+	var batch []models.UserPreferences
+	err := s.db.WithContext(ctx).
+		Where("location_lat <> 0 OR location_lng <> 0").
+		FindInBatches(&batch, closureAudienceBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, prefs := range batch {
+				distance := geo.DistanceKM(prefs.LocationLat, prefs.LocationLng, activity.Latitude, activity.Longitude)
+				if distance <= float64(prefs.SearchRadiusKM) {
+					audience = append(audience, prefs.UserID)
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find users near activity %d: %w", activity.ID, err)
+	}
+
+	var searches []models.SavedSearch
+	err = s.db.WithContext(ctx).
+		FindInBatches(&searches, closureAudienceBatchSize, func(tx *gorm.DB, _ int) error {
+			for _, search := range searches {
+				if savedSearchMatches(search, activity) {
+					audience = append(audience, search.UserID)
+				}
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to find saved searches matching activity %d: %w", activity.ID, err)
+	}
+
+	slices.Sort(audience)
+	return slices.Compact(audience), nil
+}
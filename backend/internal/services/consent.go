@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrUnknownPurpose is returned for consent to a purpose that does not exist
+	ErrUnknownPurpose = apperr.New(apperr.NotFound, "unknown consent purpose")
+	// ErrConsentVersion is returned for consent to a version of a purpose's text
+	// that was never published
+	ErrConsentVersion = apperr.New(apperr.Invalid, "unknown version of the consent text")
+)
+
+// ConsentStatus is a user's consent to a purpose: their decision, or the
+// purpose's default when they never decided
+type ConsentStatus struct {
+	models.ConsentPurpose
+	Granted bool `json:"granted"`
+	Decided bool `json:"decided"`
+	// DecidedVersion is the version of the text the user decided on; clients
+	// ask again when it is Outdated
+	DecidedVersion int        `json:"decided_version,omitempty"`
+	Outdated       bool       `json:"outdated"`
+	GrantedAt      *time.Time `json:"granted_at,omitempty"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ConsentService records which purposes users consent to their data being used
+// for. Services using a purpose's data check Granted before storing or using it.
+type ConsentService struct {
+	db *gorm.DB
+}
+
+// NewConsentService creates a new consent service
+func NewConsentService(db *gorm.DB) *ConsentService {
+	return &ConsentService{
+		db: db,
+	}
+}
+
+// List returns the user's consent to every purpose
+func (s *ConsentService) List(ctx context.Context, userID uint) ([]ConsentStatus, error) {
+	var consents []models.Consent
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Find(&consents).Error; err != nil {
+		return nil, fmt.Errorf("failed to load consents of user %d: %w", userID, err)
+	}
+	decided := make(map[string]models.Consent, len(consents))
+	for _, consent := range consents {
+		decided[consent.Purpose] = consent
+	}
+
+	statuses := make([]ConsentStatus, 0, len(models.ConsentPurposes))
+	for _, purpose := range models.ConsentPurposes {
+		consent, ok := decided[purpose.Name]
+		statuses = append(statuses, consentStatus(purpose, consent, ok))
+	}
+	return statuses, nil
+}
+
+// Set records the user's decision about a purpose, made on a version of its
+// text; version 0 is the current one. Revoking inferred preferences also turns
+// off location history and deletes it, as withdrawing it in preferences does,
+// and revoking chat storage deletes the user's stored conversations.
+func (s *ConsentService) Set(ctx context.Context, userID uint, purposeName string, granted bool, version int) (*ConsentStatus, error) {
+	purpose, ok := models.FindConsentPurpose(purposeName)
+	if !ok {
+		return nil, ErrUnknownPurpose
+	}
+	if version == 0 {
+		version = purpose.Version
+	}
+	if version < 1 || version > purpose.Version {
+		return nil, ErrConsentVersion
+	}
+
+	now := time.Now()
+	consent := models.Consent{UserID: userID, Purpose: purpose.Name, Granted: granted, Version: version}
+	updates := map[string]interface{}{"granted": granted, "version": version, "updated_at": now}
+	if granted {
+		consent.GrantedAt = &now
+		updates["granted_at"] = now
+	} else {
+		consent.RevokedAt = &now
+		updates["revoked_at"] = now
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "purpose"}},
+			DoUpdates: clause.Assignments(updates),
+		}).Create(&consent).Error; err != nil {
+			return fmt.Errorf("failed to record %s consent of user %d: %w", purpose.Name, userID, err)
+		}
+		if err := tx.Where("user_id = ? AND purpose = ?", userID, purpose.Name).First(&consent).Error; err != nil {
+			return fmt.Errorf("failed to load %s consent of user %d: %w", purpose.Name, userID, err)
+		}
+
+		if purpose.Name == models.ConsentInferredPreferences && !granted {
+			if err := tx.Model(&models.UserPreferences{}).Where("user_id = ?", userID).
+				Updates(map[string]interface{}{"location_history": false, "profile_question": ""}).Error; err != nil {
+				return fmt.Errorf("failed to turn off location history of user %d: %w", userID, err)
+			}
+			if err := tx.Where("user_id = ?", userID).Delete(&models.LocationHistory{}).Error; err != nil {
+				return fmt.Errorf("failed to purge location history of user %d: %w", userID, err)
+			}
+		}
+		if purpose.Name == models.ConsentChatStorage && !granted {
+			conversations := tx.Unscoped().Model(&models.Conversation{}).Select("id").Where("user_id = ?", userID)
+			if err := tx.Where("conversation_id IN (?)", conversations).Delete(&models.Message{}).Error; err != nil {
+				return fmt.Errorf("failed to delete messages of user %d: %w", userID, err)
+			}
+			if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.Conversation{}).Error; err != nil {
+				return fmt.Errorf("failed to delete conversations of user %d: %w", userID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	status := consentStatus(purpose, consent, true)
+	return &status, nil
+}
+
+// Granted reports whether data of the user may be used for a purpose. Anonymous
+// users (userID 0) have no account to record consent with and get the default.
+func (s *ConsentService) Granted(ctx context.Context, userID uint, purposeName string) (bool, error) {
+	purpose, ok := models.FindConsentPurpose(purposeName)
+	if !ok {
+		return false, ErrUnknownPurpose
+	}
+	if userID == 0 {
+		return purpose.Default, nil
+	}
+
+	var consents []models.Consent
+	if err := s.db.WithContext(ctx).Select("granted").
+		Where("user_id = ? AND purpose = ?", userID, purpose.Name).
+		Limit(1).Find(&consents).Error; err != nil {
+		return false, fmt.Errorf("failed to check %s consent of user %d: %w", purpose.Name, userID, err)
+	}
+	if len(consents) == 0 {
+		return purpose.Default, nil
+	}
+	return consents[0].Granted, nil
+}
+
+// consented narrows a query over many users to those consenting to a purpose;
+// userColumn holds the user ID of each row
+func consented(query *gorm.DB, purpose models.ConsentPurpose, userColumn string) *gorm.DB {
+	if purpose.Default {
+		return query.Where("NOT EXISTS (SELECT 1 FROM consents WHERE consents.user_id = "+userColumn+
+			" AND consents.purpose = ? AND NOT consents.granted)", purpose.Name)
+	}
+	return query.Where("EXISTS (SELECT 1 FROM consents WHERE consents.user_id = "+userColumn+
+		" AND consents.purpose = ? AND consents.granted)", purpose.Name)
+}
+
+// consentStatus combines a purpose with the user's decision, if they made one
+func consentStatus(purpose models.ConsentPurpose, consent models.Consent, decided bool) ConsentStatus {
+	if !decided {
+		return ConsentStatus{ConsentPurpose: purpose, Granted: purpose.Default}
+	}
+	return ConsentStatus{
+		ConsentPurpose: purpose,
+		Granted:        consent.Granted,
+		Decided:        true,
+		DecidedVersion: consent.Version,
+		Outdated:       consent.Version < purpose.Version,
+		GrantedAt:      consent.GrantedAt,
+		RevokedAt:      consent.RevokedAt,
+	}
+}
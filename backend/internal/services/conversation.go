@@ -0,0 +1,491 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrConversationForbidden is returned when adding to another user's conversation
+var ErrConversationForbidden = apperr.New(apperr.Forbidden, "conversation belongs to another user")
+
+// ErrNotUserMessage is returned when branching from an assistant message;
+// only the user's own messages can be edited
+var ErrNotUserMessage = apperr.New(apperr.Invalid, "only user messages can be edited")
+
+// conversationTitleLength is how many characters of the first message become the title
+const conversationTitleLength = 80
+
+// ConversationService persists chat conversations and their messages
+type ConversationService struct {
+	db *gorm.DB
+}
+
+// NewConversationService creates a new conversation service
+func NewConversationService(db *gorm.DB) *ConversationService {
+	return &ConversationService{
+		db: db,
+	}
+}
+
+// AddMessage appends a message to the active branch of a conversation, creating
+// the conversation (titled after its first message) if it does not exist yet.
+// userID is 0 for anonymous users, who can only add to anonymous conversations
+// of their session. Messages of users who revoked consent to chat storage are
+// not stored.
+func (s *ConversationService) AddMessage(ctx context.Context, conversationID, clientIP, sessionID string, userID uint, role, content string, interrupted bool) error {
+	stored, err := NewConsentService(s.db).Granted(ctx, userID, models.ConsentChatStorage)
+	if err != nil || !stored {
+		return err
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		conversation := models.Conversation{
+			ID:       conversationID,
+			Title:    conversationTitle(content),
+			ClientIP: clientIP,
+		}
+		if userID != 0 {
+			conversation.UserID = &userID
+		} else {
+			conversation.SessionID = sessionID
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&conversation).Error; err != nil {
+			return fmt.Errorf("failed to create conversation %s: %w", conversationID, err)
+		}
+
+		// The row lock keeps concurrent messages from following the same one
+		var existing models.Conversation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "user_id", "session_id", "current_message_id").
+			First(&existing, "id = ?", conversationID).Error; err != nil {
+			return fmt.Errorf("failed to load conversation %s: %w", conversationID, err)
+		}
+		if !canAccessConversation(existing, userID, sessionID) {
+			return ErrConversationForbidden
+		}
+
+		message := models.Message{
+			ConversationID: conversationID,
+			ParentID:       existing.CurrentMessageID,
+			Role:           role,
+			Content:        content,
+			Interrupted:    interrupted,
+		}
+		if err := tx.Create(&message).Error; err != nil {
+			return fmt.Errorf("failed to store message in conversation %s: %w", conversationID, err)
+		}
+
+		// Bump updated_at so recently active conversations list first
+		if err := tx.Model(&models.Conversation{}).
+			Where("id = ?", conversationID).
+			Updates(map[string]interface{}{"current_message_id": message.ID, "updated_at": time.Now()}).Error; err != nil {
+			return fmt.Errorf("failed to touch conversation %s: %w", conversationID, err)
+		}
+		return nil
+	})
+}
+
+// CompleteMessage replaces the content of an interrupted answer that was continued.
+// Access to the conversation is checked when its memory is loaded for the run.
+func (s *ConversationService) CompleteMessage(ctx context.Context, conversationID string, messageID uint, content string, interrupted bool) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Message{}).
+			Where("id = ? AND conversation_id = ?", messageID, conversationID).
+			Updates(map[string]interface{}{"content": content, "interrupted": interrupted}).Error; err != nil {
+			return fmt.Errorf("failed to update message %d: %w", messageID, err)
+		}
+		if err := tx.Model(&models.Conversation{}).
+			Where("id = ?", conversationID).
+			Update("updated_at", time.Now()).Error; err != nil {
+			return fmt.Errorf("failed to touch conversation %s: %w", conversationID, err)
+		}
+		return nil
+	})
+}
+
+// List returns a page of a user's conversations, most recently active first, and the total count
+func (s *ConversationService) List(ctx context.Context, userID uint, page, pageSize int) ([]models.Conversation, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.Conversation{}).Where("user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count conversations: %w", err)
+	}
+
+	var conversations []models.Conversation
+	if err := query.Order("updated_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&conversations).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list conversations: %w", err)
+	}
+
+	return conversations, total, nil
+}
+
+// Messages returns a page of the messages of a conversation's active branch in
+// chronological order and the total count. Other users' and sessions'
+// conversations are reported as not found.
+func (s *ConversationService) Messages(ctx context.Context, conversationID, sessionID string, userID uint, page, pageSize int) ([]models.Message, int64, error) {
+	conversation, err := s.readable(ctx, conversationID, sessionID, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	ids, err := branchMessageIDs(s.db.WithContext(ctx), conversation.CurrentMessageID, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := s.db.WithContext(ctx).Model(&models.Message{}).Where("id IN ?", ids)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count messages: %w", err)
+	}
+
+	var messages []models.Message
+	if err := query.Order("created_at, id").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&messages).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list messages: %w", err)
+	}
+
+	return messages, total, nil
+}
+
+// MessageNode is a message with the messages that follow it. A message
+// followed by several was edited; each child starts a branch.
+type MessageNode struct {
+	models.Message
+	Children []*MessageNode `json:"children"`
+}
+
+// ConversationTree is a conversation with the messages of all its branches
+type ConversationTree struct {
+	models.Conversation
+	// Messages are the first messages of the conversation, one per edit of the first message
+	Messages []*MessageNode `json:"messages"`
+}
+
+// Tree returns a conversation with all its messages as a tree, children in
+// chronological order. Other users' and sessions' conversations are reported
+// as not found.
+func (s *ConversationService) Tree(ctx context.Context, conversationID, sessionID string, userID uint) (*ConversationTree, error) {
+	conversation, err := s.readable(ctx, conversationID, sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []models.Message
+	if err := s.db.WithContext(ctx).
+		Where("conversation_id = ?", conversationID).
+		Order("created_at, id").
+		Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("failed to load messages of conversation %s: %w", conversationID, err)
+	}
+
+	// Parents are created before their children, so they come first
+	tree := &ConversationTree{Conversation: *conversation, Messages: []*MessageNode{}}
+	nodes := make(map[uint]*MessageNode, len(messages))
+	for _, message := range messages {
+		node := &MessageNode{Message: message, Children: []*MessageNode{}}
+		nodes[message.ID] = node
+		if parent, ok := nodes[derefUint(message.ParentID)]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			tree.Messages = append(tree.Messages, node)
+		}
+	}
+	return tree, nil
+}
+
+// Branch checks that a user may edit an earlier message and returns the ID of
+// the message's conversation. The edit is answered with the conversation up to
+// the message's parent (see Memory) and stored by RecordEdit; the conversation
+// stays on its active branch until then. Messages of other users' and
+// sessions' conversations are reported as not found.
+func (s *ConversationService) Branch(ctx context.Context, messageID, userID uint, sessionID string) (string, error) {
+	var message models.Message
+	if err := s.db.WithContext(ctx).Select("id", "conversation_id", "role").First(&message, messageID).Error; err != nil {
+		return "", fmt.Errorf("failed to load message %d: %w", messageID, err)
+	}
+	if _, err := s.readable(ctx, message.ConversationID, sessionID, userID); err != nil {
+		return "", err
+	}
+	if message.Role != models.MessageRoleUser {
+		return "", ErrNotUserMessage
+	}
+	return message.ConversationID, nil
+}
+
+// RecordEdit stores the edit of an earlier user message and its answer. The
+// edit follows the message's parent, and its answer becomes the conversation's
+// current message, so the edit's branch is the active one from now on. The
+// original message and what followed it stay in the conversation as an
+// alternative. Edits of users who revoked consent to chat storage are not stored.
+func (s *ConversationService) RecordEdit(ctx context.Context, conversationID, sessionID string, userID, editOf uint, message, response string, interrupted bool) error {
+	stored, err := NewConsentService(s.db).Granted(ctx, userID, models.ConsentChatStorage)
+	if err != nil || !stored {
+		return err
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var conversation models.Conversation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "user_id", "session_id", "summarized_through").
+			First(&conversation, "id = ?", conversationID).Error; err != nil {
+			return fmt.Errorf("failed to load conversation %s: %w", conversationID, err)
+		}
+		if !canAccessConversation(conversation, userID, sessionID) {
+			return ErrConversationForbidden
+		}
+		edited, err := editedMessage(tx, conversationID, editOf)
+		if err != nil {
+			return err
+		}
+
+		question := models.Message{
+			ConversationID: conversationID,
+			ParentID:       edited.ParentID,
+			Role:           models.MessageRoleUser,
+			Content:        message,
+		}
+		if err := tx.Create(&question).Error; err != nil {
+			return fmt.Errorf("failed to store edit of message %d: %w", editOf, err)
+		}
+		answer := models.Message{
+			ConversationID: conversationID,
+			ParentID:       &question.ID,
+			Role:           models.MessageRoleAssistant,
+			Content:        response,
+			Interrupted:    interrupted,
+		}
+		if err := tx.Create(&answer).Error; err != nil {
+			return fmt.Errorf("failed to store answer to edit of message %d: %w", editOf, err)
+		}
+
+		updates := map[string]interface{}{"current_message_id": answer.ID, "updated_at": time.Now()}
+		// The rolling summary covers messages of the old branch after the
+		// parent; it is rebuilt from the new branch
+		if conversation.SummarizedThrough > derefUint(edited.ParentID) {
+			updates["summary"] = ""
+			updates["summarized_through"] = 0
+			updates["summary_tokens"] = 0
+		}
+		if err := tx.Model(&models.Conversation{}).Where("id = ?", conversationID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to branch conversation %s: %w", conversationID, err)
+		}
+		return nil
+	})
+}
+
+// editedMessage loads a user message of a conversation that is being edited
+func editedMessage(db *gorm.DB, conversationID string, messageID uint) (*models.Message, error) {
+	var message models.Message
+	if err := db.Select("id", "conversation_id", "parent_id", "role").
+		Where("conversation_id = ?", conversationID).
+		First(&message, messageID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load message %d: %w", messageID, err)
+	}
+	if message.Role != models.MessageRoleUser {
+		return nil, ErrNotUserMessage
+	}
+	return &message, nil
+}
+
+// Memory returns the conversation's rolling summary and the messages of its
+// active branch after it, oldest first. For edits the branch ends at the
+// edited message's parent. New conversations have an empty memory.
+func (s *ConversationService) Memory(ctx context.Context, conversationID, sessionID string, userID, editOf uint) (*chat.Memory, error) {
+	var conversation models.Conversation
+	err := s.db.WithContext(ctx).
+		Select("id", "user_id", "session_id", "summary", "summarized_through", "current_message_id").
+		First(&conversation, "id = ?", conversationID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &chat.Memory{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load conversation %s: %w", conversationID, err)
+	}
+	if !canAccessConversation(conversation, userID, sessionID) {
+		return nil, ErrConversationForbidden
+	}
+
+	head, summary, through := conversation.CurrentMessageID, conversation.Summary, conversation.SummarizedThrough
+	if editOf != 0 {
+		edited, err := editedMessage(s.db.WithContext(ctx), conversationID, editOf)
+		if err != nil {
+			return nil, err
+		}
+		head = edited.ParentID
+		// The rolling summary covers messages of the active branch after the parent
+		if through > derefUint(head) {
+			summary, through = "", 0
+		}
+	}
+	ids, err := branchMessageIDs(s.db.WithContext(ctx), head, through)
+	if err != nil {
+		return nil, err
+	}
+	var messages []models.Message
+	if err := s.db.WithContext(ctx).
+		Where("id IN ?", ids).
+		Order("created_at, id").
+		Find(&messages).Error; err != nil {
+		return nil, fmt.Errorf("failed to load messages of conversation %s: %w", conversationID, err)
+	}
+
+	memory := &chat.Memory{Summary: summary, Turns: make([]chat.Turn, len(messages))}
+	for i, message := range messages {
+		memory.Turns[i] = chat.Turn{ID: message.ID, Role: message.Role, Content: message.Content, Interrupted: message.Interrupted}
+	}
+	return memory, nil
+}
+
+// SaveSummary stores a new rolling summary covering messages up to through. A
+// summary covering fewer messages than the stored one is ignored, so concurrent
+// runs cannot move the summary backwards.
+func (s *ConversationService) SaveSummary(ctx context.Context, conversationID, summary string, through uint) error {
+	if err := s.db.WithContext(ctx).Model(&models.Conversation{}).
+		Where("id = ? AND summarized_through < ?", conversationID, through).
+		Updates(map[string]interface{}{
+			"summary":            summary,
+			"summarized_through": through,
+			"summary_tokens":     chat.EstimateTokens(summary),
+		}).Error; err != nil {
+		return fmt.Errorf("failed to save summary of conversation %s: %w", conversationID, err)
+	}
+	return nil
+}
+
+// pruneBatchSize is how many conversations PruneAnonymous deletes per statement
+const pruneBatchSize = 500
+
+// PruneAnonymous deletes anonymous conversations, including those of deleted
+// accounts, not continued since before, with their messages, and returns how
+// many were deleted. Conversations of users are kept until the user deletes
+// their account.
+func (s *ConversationService) PruneAnonymous(ctx context.Context, before time.Time) (int64, error) {
+	var pruned int64
+	for {
+		var ids []string
+		if err := s.db.WithContext(ctx).Unscoped().Model(&models.Conversation{}).
+			Where("user_id IS NULL AND updated_at < ?", before).
+			Limit(pruneBatchSize).Pluck("id", &ids).Error; err != nil {
+			return pruned, fmt.Errorf("failed to find conversations to prune: %w", err)
+		}
+		if len(ids) == 0 {
+			return pruned, nil
+		}
+
+		if err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("conversation_id IN ?", ids).Delete(&models.Message{}).Error; err != nil {
+				return fmt.Errorf("failed to delete messages: %w", err)
+			}
+			return tx.Unscoped().Where("id IN ?", ids).Delete(&models.Conversation{}).Error
+		}); err != nil {
+			return pruned, fmt.Errorf("failed to prune conversations: %w", err)
+		}
+		pruned += int64(len(ids))
+	}
+}
+
+// MigrateMessageBranches links the messages of databases created before
+// conversations could branch, each to the message before it, and makes the
+// last message of each conversation its current one. It runs once, before
+// AutoMigrate, which creates the columns of new databases.
+func MigrateMessageBranches(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.Message{}) || migrator.HasColumn(&models.Message{}, "ParentID") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Migrator().AddColumn(&models.Message{}, "ParentID"); err != nil {
+			return fmt.Errorf("failed to add message parents: %w", err)
+		}
+		if err := tx.Migrator().AddColumn(&models.Conversation{}, "CurrentMessageID"); err != nil {
+			return fmt.Errorf("failed to add current conversation messages: %w", err)
+		}
+		if err := tx.Exec(`
+			UPDATE messages SET parent_id = previous.parent_id
+			FROM (SELECT id, LAG(id) OVER (PARTITION BY conversation_id ORDER BY created_at, id) AS parent_id FROM messages) previous
+			WHERE messages.id = previous.id AND previous.parent_id IS NOT NULL`).Error; err != nil {
+			return fmt.Errorf("failed to backfill message parents: %w", err)
+		}
+		if err := tx.Exec(`
+			UPDATE conversations SET current_message_id = (SELECT id FROM messages
+				WHERE messages.conversation_id = conversations.id ORDER BY created_at DESC, id DESC LIMIT 1)`).Error; err != nil {
+			return fmt.Errorf("failed to backfill current conversation messages: %w", err)
+		}
+		return nil
+	})
+}
+
+// readable loads a conversation the user or session may read, reporting other
+// users' and sessions' conversations as not found
+func (s *ConversationService) readable(ctx context.Context, conversationID, sessionID string, userID uint) (*models.Conversation, error) {
+	var conversation models.Conversation
+	if err := s.db.WithContext(ctx).First(&conversation, "id = ?", conversationID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load conversation %s: %w", conversationID, err)
+	}
+	if !canAccessConversation(conversation, userID, sessionID) {
+		return nil, fmt.Errorf("failed to load conversation %s: %w", conversationID, gorm.ErrRecordNotFound)
+	}
+	return &conversation, nil
+}
+
+// branchMessageIDs returns the IDs of the messages from the start of a branch
+// to its last message, leaving out those up to after. Messages are created
+// after their parents, so IDs shrink toward the start.
+func branchMessageIDs(db *gorm.DB, lastID *uint, after uint) ([]uint, error) {
+	ids := []uint{}
+	if lastID == nil {
+		return ids, nil
+	}
+	if err := db.Raw(`
+		WITH RECURSIVE branch AS (
+			SELECT id, parent_id FROM messages WHERE id = ? AND id > ?
+			UNION ALL
+			SELECT messages.id, messages.parent_id FROM messages JOIN branch ON messages.id = branch.parent_id WHERE messages.id > ?
+		)
+		SELECT id FROM branch`, *lastID, after, after).Scan(&ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to load branch of message %d: %w", *lastID, err)
+	}
+	return ids, nil
+}
+
+// derefUint returns the value of an optional ID, 0 when unset
+func derefUint(id *uint) uint {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+// canAccessConversation reports whether a user may read or continue a
+// conversation. Anonymous conversations are only accessible to anonymous
+// requests of the session they were started in; those started without a
+// session only to requests without one, by their unguessable ID.
+func canAccessConversation(conversation models.Conversation, userID uint, sessionID string) bool {
+	if conversation.UserID != nil {
+		return *conversation.UserID == userID
+	}
+	return userID == 0 && conversation.SessionID == sessionID
+}
+
+// conversationTitle shortens a message to a conversation title
+func conversationTitle(message string) string {
+	if utf8.RuneCountInString(message) <= conversationTitleLength {
+		return message
+	}
+	return string([]rune(message)[:conversationTitleLength-1]) + "…"
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/llm"
+)
+
+// conversationSummaryPrompt instructs the model to maintain a rolling conversation summary
+const conversationSummaryPrompt = `You maintain a running summary of a conversation between a user and a local outdoor guide.
+Update the previous summary with the new messages. Keep what the user wants, their constraints (location, dates, group, difficulty) and the activities already suggested with their [Name](activity://<id>) links.
+Drop small talk. Reply with the updated summary only, in at most 200 words.`
+
+// ConversationSummarizer folds older conversation turns into a rolling summary using a language model
+type ConversationSummarizer struct {
+	client llm.Provider
+}
+
+// NewConversationSummarizer creates a summarizer backed by client
+func NewConversationSummarizer(client llm.Provider) *ConversationSummarizer {
+	return &ConversationSummarizer{client: client}
+}
+
+// Summarize returns previous updated with turns
+func (s *ConversationSummarizer) Summarize(ctx context.Context, previous string, turns []chat.Turn) (string, error) {
+	var transcript strings.Builder
+	if previous != "" {
+		fmt.Fprintf(&transcript, "Previous summary:\n%s\n\n", previous)
+	}
+	transcript.WriteString("New messages:\n")
+	for _, turn := range turns {
+		fmt.Fprintf(&transcript, "%s: %s\n", turn.Role, turn.Content)
+	}
+
+	var reply strings.Builder
+	_, err := s.client.StreamCompletion(ctx, llm.CompletionRequest{
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: conversationSummaryPrompt},
+			{Role: llm.RoleUser, Content: transcript.String()},
+		},
+	}, func(delta string) error {
+		reply.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+
+	summary := strings.TrimSpace(reply.String())
+	if summary == "" {
+		return "", fmt.Errorf("failed to summarize conversation: empty summary")
+	}
+	return summary, nil
+}
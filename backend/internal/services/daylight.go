@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/daylight"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidTimezone is returned for time zones that are not IANA names
+	ErrInvalidTimezone = apperr.New(apperr.Invalid, "timezone must be an IANA name such as Europe/Berlin")
+	// ErrInvalidDate is returned for dates not in YYYY-MM-DD form
+	ErrInvalidDate = apperr.New(apperr.Invalid, "date must be formatted as YYYY-MM-DD")
+	// ErrDaylightLocationRequired is returned by the daylight tool when neither
+	// the model, an activity nor the user's context gives a location
+	ErrDaylightLocationRequired = apperr.New(apperr.Invalid, "a location is required; ask the user where they are going")
+)
+
+// DaylightService plans outings around daylight: sunrise, sunset, twilight and
+// golden hour are calculated from the sun's position, so no external service
+// is involved.
+type DaylightService struct {
+	db *gorm.DB
+}
+
+// DaylightQuery asks for the daylight of a date at a location
+type DaylightQuery struct {
+	Location models.Location
+	// Date is YYYY-MM-DD, today in the time zone when empty
+	Date string
+	// Timezone is an IANA name; estimated from the longitude when empty
+	Timezone string
+	// Duration, when set, plans an outing of that length to finish by sunset
+	Duration time.Duration
+}
+
+// Daylight is the daylight of a date at a location
+type Daylight struct {
+	daylight.Day
+	Location models.Location `json:"location"`
+	Timezone string          `json:"timezone"`
+	// TimezoneEstimated is set when no time zone was given and times are in
+	// the solar time zone of the longitude, which may differ from local time
+	TimezoneEstimated bool          `json:"timezone_estimated,omitempty"`
+	Plan              *DaylightPlan `json:"plan,omitempty"`
+}
+
+// DaylightPlan is when an outing has to start to finish before dark
+type DaylightPlan struct {
+	DurationMinutes int `json:"duration_minutes"`
+	// LatestStart is the latest start to finish by sunset; nil when the sun
+	// does not rise or set that day
+	LatestStart *time.Time `json:"latest_start,omitempty"`
+	// LatestStartCivil is the latest start to finish by the end of civil
+	// twilight, when it is too dark outdoors without a lamp
+	LatestStartCivil *time.Time `json:"latest_start_civil,omitempty"`
+	Note             string     `json:"note,omitempty"`
+}
+
+// NewDaylightService creates a new daylight service; db may be nil, in which
+// case the chat tool cannot look up activities
+func NewDaylightService(db *gorm.DB) *DaylightService {
+	return &DaylightService{
+		db: db,
+	}
+}
+
+// Daylight returns the daylight of the query's date and location and, when the
+// query has a duration, the latest start to finish before dark
+func (s *DaylightService) Daylight(q DaylightQuery) (*Daylight, error) {
+	loc, estimated, err := daylight.Zone(q.Timezone, q.Location.Lng)
+	if err != nil {
+		return nil, apperr.Wrap(apperr.Invalid, ErrInvalidTimezone.Message, err)
+	}
+	date := time.Now().In(loc)
+	if q.Date != "" {
+		if date, err = time.ParseInLocation("2006-01-02", q.Date, loc); err != nil {
+			return nil, ErrInvalidDate
+		}
+	}
+
+	result := &Daylight{
+		Day:               daylight.Compute(q.Location.Lat, q.Location.Lng, date, loc),
+		Location:          q.Location,
+		Timezone:          loc.String(),
+		TimezoneEstimated: estimated,
+	}
+	if q.Duration > 0 {
+		result.Plan = planDaylight(result.Day, q.Duration)
+	}
+	return result, nil
+}
+
+// planDaylight returns the latest starts of an outing of duration on day
+func planDaylight(day daylight.Day, duration time.Duration) *DaylightPlan {
+	plan := &DaylightPlan{DurationMinutes: int(duration.Minutes())}
+	switch {
+	case day.PolarDay:
+		plan.Note = "The sun does not set, so there is daylight all day."
+		return plan
+	case day.PolarNight:
+		plan.Note = "The sun does not rise; plan with headlamps."
+		return plan
+	}
+
+	start := day.Sunset.Add(-duration)
+	plan.LatestStart = &start
+	if day.CivilDusk != nil {
+		civil := day.CivilDusk.Add(-duration)
+		plan.LatestStartCivil = &civil
+	}
+	if start.Before(*day.Sunrise) {
+		plan.Note = fmt.Sprintf("The outing takes longer than the %d minutes of daylight; part of it will be in the dark.", day.DaylightMinutes)
+	}
+	return plan
+}
+
+// Tool returns the chat tool looking up daylight
+func (s *DaylightService) Tool() chat.Tool {
+	return chat.Tool{
+		Name: "get_daylight",
+		Description: "Calculate sunrise, sunset, civil twilight and golden hour for a date at a location or activity, in local time. " +
+			"Give duration_minutes (the activity's duration is used by default) to get the latest start that finishes before sunset, " +
+			"e.g. to answer \"start by 14:00 to finish before dark\". Omit date for today.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"activity_id": {"type": "integer", "description": "Use the location and duration of this activity"},
+				"lat": {"type": "number"},
+				"lng": {"type": "number"},
+				"date": {"type": "string", "description": "YYYY-MM-DD"},
+				"timezone": {"type": "string", "description": "IANA time zone such as Europe/Berlin; the user's is used when omitted"},
+				"duration_minutes": {"type": "integer", "minimum": 1}
+			}
+		}`),
+		Call: s.getDaylight,
+	}
+}
+
+// getDaylight implements the get_daylight tool
+func (s *DaylightService) getDaylight(ctx context.Context, run *chat.Run, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		ActivityID      uint     `json:"activity_id"`
+		Lat             *float64 `json:"lat"`
+		Lng             *float64 `json:"lng"`
+		Date            string   `json:"date"`
+		Timezone        string   `json:"timezone"`
+		DurationMinutes int      `json:"duration_minutes"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, err)
+	}
+
+	q := DaylightQuery{
+		Date:     args.Date,
+		Timezone: args.Timezone,
+		Duration: time.Duration(args.DurationMinutes) * time.Minute,
+	}
+	if q.Timezone == "" && run.UserContext != nil {
+		// Zones sent by clients are not validated, so unknown ones fall back to the estimate
+		if _, err := time.LoadLocation(run.UserContext.Timezone); err == nil {
+			q.Timezone = run.UserContext.Timezone
+		}
+	}
+	switch {
+	case args.ActivityID != 0 && s.db != nil:
+		var activity models.Activity
+		if err := s.db.WithContext(ctx).Select("id", "latitude", "longitude", "duration").
+			Where("approved = ?", true).First(&activity, args.ActivityID).Error; err != nil {
+			return nil, fmt.Errorf("failed to load activity %d: %w", args.ActivityID, err)
+		}
+		q.Location = models.Location{Lat: activity.Latitude, Lng: activity.Longitude}
+		if q.Duration == 0 {
+			q.Duration = time.Duration(activity.Duration) * time.Minute
+		}
+	case args.Lat != nil && args.Lng != nil:
+		q.Location = models.Location{Lat: *args.Lat, Lng: *args.Lng}
+		if q.Location.Lat < -90 || q.Location.Lat > 90 || q.Location.Lng < -180 || q.Location.Lng > 180 {
+			return nil, fmt.Errorf("%w: lat and lng are out of range", chat.ErrInvalidToolArguments)
+		}
+	case run.UserContext != nil && run.UserContext.Location != nil:
+		q.Location = *run.UserContext.Location
+	default:
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, ErrDaylightLocationRequired)
+	}
+
+	result, err := s.Daylight(q)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, err)
+	}
+	return result, nil
+}
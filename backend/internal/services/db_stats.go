@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Btree index layout used to estimate the size of an index without bloat: each
+// entry has an 8 byte tuple header and a 4 byte line pointer, keys are padded to
+// 8 bytes and leaf pages are filled to 90%
+const (
+	indexEntryOverhead = 12
+	indexFillFactor    = 0.9
+	indexPageBytes     = 8192
+)
+
+// maxBloatedIndexes is how many indexes DB stats list, most bloated first
+const maxBloatedIndexes = 20
+
+// DBStatsService reports table sizes, row counts and vacuum health from the
+// Postgres statistics catalogs, for operators without psql access
+type DBStatsService struct {
+	db *gorm.DB
+}
+
+// NewDBStatsService creates a new DB stats service
+func NewDBStatsService(db *gorm.DB) *DBStatsService {
+	return &DBStatsService{
+		db: db,
+	}
+}
+
+// DBStats describes the size and health of the database
+type DBStats struct {
+	DatabaseBytes int64 `json:"database_bytes"`
+	// Tables are all tables of the application, largest first
+	Tables []TableStats `json:"tables"`
+	// Indexes are the btree indexes with the most estimated bloat
+	Indexes     []IndexStats `json:"indexes"`
+	CollectedAt time.Time    `json:"collected_at"`
+}
+
+// TableStats describes one table. Rows is the planner's estimate unless exact
+// counts were requested.
+type TableStats struct {
+	Name       string `json:"name"`
+	Rows       int64  `json:"rows"`
+	RowsExact  bool   `json:"rows_exact"`
+	DeadRows   int64  `json:"dead_rows"`
+	TotalBytes int64  `json:"total_bytes"`
+	TableBytes int64  `json:"table_bytes"`
+	IndexBytes int64  `json:"index_bytes"`
+	// LastVacuum and LastAnalyze are the latest manual or automatic runs
+	LastVacuum  *time.Time `json:"last_vacuum"`
+	LastAnalyze *time.Time `json:"last_analyze"`
+	// VacuumOverdue is set when dead rows exceed the default autovacuum
+	// threshold (50 rows plus 20% of the table), so autovacuum is falling behind
+	VacuumOverdue bool `json:"vacuum_overdue"`
+}
+
+// IndexStats describes one index and its estimated bloat
+type IndexStats struct {
+	Name       string  `json:"name"`
+	Table      string  `json:"table"`
+	Bytes      int64   `json:"bytes"`
+	BloatBytes int64   `json:"bloat_bytes"`
+	BloatRatio float64 `json:"bloat_ratio"`
+	Scans      int64   `json:"scans"`
+}
+
+// Stats collects the database statistics. With exact, tables are counted with
+// COUNT(*), which reads every table and is slow on large ones.
+func (s *DBStatsService) Stats(ctx context.Context, exact bool) (*DBStats, error) {
+	stats := &DBStats{CollectedAt: time.Now()}
+	db := s.db.WithContext(ctx)
+
+	if err := db.Raw("SELECT pg_database_size(current_database())").Scan(&stats.DatabaseBytes).Error; err != nil {
+		return nil, fmt.Errorf("failed to read database size: %w", err)
+	}
+
+	var tables []struct {
+		TableStats
+		Live          int64
+		VacuumAuto    *time.Time
+		AnalyzeAuto   *time.Time
+		VacuumManual  *time.Time
+		AnalyzeManual *time.Time
+	}
+	if err := db.Raw(`
+		SELECT relname AS name, n_live_tup AS live, n_dead_tup AS dead_rows,
+			pg_total_relation_size(relid) AS total_bytes,
+			pg_relation_size(relid) AS table_bytes,
+			pg_indexes_size(relid) AS index_bytes,
+			last_vacuum AS vacuum_manual, last_autovacuum AS vacuum_auto,
+			last_analyze AS analyze_manual, last_autoanalyze AS analyze_auto
+		FROM pg_stat_user_tables
+		WHERE schemaname = current_schema()
+		ORDER BY total_bytes DESC, relname`).Scan(&tables).Error; err != nil {
+		return nil, fmt.Errorf("failed to read table statistics: %w", err)
+	}
+
+	stats.Tables = make([]TableStats, len(tables))
+	for i, t := range tables {
+		table := t.TableStats
+		table.Rows = t.Live
+		table.LastVacuum = latest(t.VacuumManual, t.VacuumAuto)
+		table.LastAnalyze = latest(t.AnalyzeManual, t.AnalyzeAuto)
+		table.VacuumOverdue = float64(table.DeadRows) > 50+0.2*float64(t.Live)
+		if exact {
+			if err := db.Table(table.Name).Count(&table.Rows).Error; err != nil {
+				return nil, fmt.Errorf("failed to count rows of %s: %w", table.Name, err)
+			}
+			table.RowsExact = true
+		}
+		stats.Tables[i] = table
+	}
+
+	indexes, err := s.indexBloat(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats.Indexes = indexes
+	return stats, nil
+}
+
+// indexBloat estimates the bloat of btree indexes by comparing their size with
+// the size their entries need, from the average key widths in pg_stats. Indexes
+// of tables that were never analyzed have no widths and are skipped.
+func (s *DBStatsService) indexBloat(ctx context.Context) ([]IndexStats, error) {
+	var rows []struct {
+		IndexStats
+		Tuples   float64
+		KeyWidth float64
+	}
+	if err := s.db.WithContext(ctx).Raw(`
+		SELECT s.indexrelname AS name, s.relname AS "table", s.idx_scan AS scans,
+			pg_relation_size(s.indexrelid) AS bytes, ic.reltuples AS tuples,
+			(SELECT SUM(st.avg_width) FROM pg_attribute a
+				JOIN pg_stats st ON st.schemaname = s.schemaname AND st.tablename = s.relname AND st.attname = a.attname
+				WHERE a.attrelid = s.relid AND a.attnum = ANY(x.indkey)) AS key_width
+		FROM pg_stat_user_indexes s
+		JOIN pg_class ic ON ic.oid = s.indexrelid
+		JOIN pg_index x ON x.indexrelid = s.indexrelid
+		JOIN pg_am am ON am.oid = ic.relam
+		WHERE am.amname = 'btree' AND s.schemaname = current_schema()`).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read index statistics: %w", err)
+	}
+
+	indexes := make([]IndexStats, 0, len(rows))
+	for _, row := range rows {
+		if row.KeyWidth <= 0 || row.Tuples < 0 || row.Bytes == 0 {
+			continue
+		}
+		entry := indexEntryOverhead + math.Ceil(row.KeyWidth/8)*8
+		// The metapage and the root are needed even for empty indexes
+		expected := int64(row.Tuples*entry/indexFillFactor) + 2*indexPageBytes
+		index := row.IndexStats
+		if index.Bytes > expected {
+			index.BloatBytes = index.Bytes - expected
+			index.BloatRatio = math.Round(float64(index.BloatBytes)/float64(index.Bytes)*100) / 100
+		}
+		indexes = append(indexes, index)
+	}
+
+	sort.Slice(indexes, func(i, j int) bool {
+		return indexes[i].BloatBytes > indexes[j].BloatBytes
+	})
+	if len(indexes) > maxBloatedIndexes {
+		indexes = indexes[:maxBloatedIndexes]
+	}
+	return indexes, nil
+}
+
+// latest returns the later of two optional times
+func latest(a, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.After(*a)) {
+		return b
+	}
+	return a
+}
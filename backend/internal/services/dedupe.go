@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/agui"
+	"community-chatbot/internal/cache"
+	"community-chatbot/internal/chat"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var duplicateMessages = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "chat_duplicate_messages_total",
+	Help: "Chat runs refused because their client sent the same message shortly before.",
+})
+
+// DedupeGuard refuses a message its client already sent within the window,
+// such as a double-clicked send button or a retried request, so it is not
+// answered (and counted) twice. Clients are identified as by the flood
+// guard, so a guest's message is a repeat when either its fingerprint or its
+// IP address sent it, and different users sending the same text are not affected.
+type DedupeGuard struct {
+	claims cache.Claimer
+	window time.Duration
+}
+
+// NewDedupeGuard creates a new dedupe guard remembering messages in claims for window
+func NewDedupeGuard(claims cache.Claimer, window time.Duration) *DedupeGuard {
+	return &DedupeGuard{
+		claims: claims,
+		window: window,
+	}
+}
+
+// Stage returns the pipeline stage that ends runs repeating a recent message
+// of their client with a DUPLICATE_MESSAGE error. Messages whose run fails are
+// forgotten, so they can be sent again right away. When the store fails, runs
+// are let through.
+func (g *DedupeGuard) Stage() chat.Stage {
+	return chat.StageFunc{
+		StageName: "dedupe",
+		Fn: func(ctx context.Context, run *chat.Run, next chat.Handler) error {
+			if run.DryRun || run.Continue || strings.TrimSpace(run.Message) == "" {
+				return next(ctx, run)
+			}
+
+			hash := messageHash(run.Message)
+			var keys []string
+			for _, client := range floodClients(run) {
+				key := "dedupe:" + client + ":" + hash
+				claimed, err := g.claims.SetIfAbsent(ctx, key, []byte(run.ID), g.window)
+				if err != nil {
+					log.Printf("[DEDUPE] Run %s: store failed, allowing: %v", run.ID, err)
+					g.forget(ctx, run, keys)
+					return next(ctx, run)
+				}
+				if !claimed {
+					g.forget(ctx, run, keys)
+					duplicateMessages.Inc()
+					return run.Emit(agui.CreateDuplicateMessageEvent(agui.DuplicateMessageData{
+						Message:           "You just sent this message. Please wait for the answer before sending it again.",
+						RetryAfterSeconds: int(math.Ceil(g.window.Seconds())),
+					}))
+				}
+				keys = append(keys, key)
+			}
+
+			if err := next(ctx, run); err != nil {
+				g.forget(ctx, run, keys)
+				return err
+			}
+			return nil
+		},
+	}
+}
+
+// forget releases the messages claimed by a run
+func (g *DedupeGuard) forget(ctx context.Context, run *chat.Run, keys []string) {
+	for _, key := range keys {
+		// The context may be cancelled already
+		if err := g.claims.Delete(context.WithoutCancel(ctx), key); err != nil {
+			log.Printf("[DEDUPE] Run %s: forget message: %v", run.ID, err)
+		}
+	}
+}
+
+// messageHash identifies a message regardless of surrounding whitespace and case
+func messageHash(message string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(message))))
+	return hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidCalibration is returned for thresholds that do not increase from easy to moderate
+var ErrInvalidCalibration = apperr.New(apperr.Invalid, "thresholds must satisfy 0 < easy_max < moderate_max")
+
+// difficultyLevels are the calibrated difficulty levels, from easiest
+var difficultyLevels = []string{models.DifficultyEasy, models.DifficultyModerate, models.DifficultyHard}
+
+// DifficultyService manages per-community difficulty calibrations. Route
+// difficulties are bucketed from their score with this community's calibration,
+// and difficulty filters, which users phrase in this community's terms, are
+// translated to the levels of the community each activity comes from.
+type DifficultyService struct {
+	db *gorm.DB
+}
+
+// NewDifficultyService creates a new difficulty service
+func NewDifficultyService(db *gorm.DB) *DifficultyService {
+	return &DifficultyService{
+		db: db,
+	}
+}
+
+// List returns the stored calibrations, this community's first
+func (s *DifficultyService) List(ctx context.Context) ([]models.DifficultyCalibration, error) {
+	var calibrations []models.DifficultyCalibration
+	if err := s.db.WithContext(ctx).Order("community").Find(&calibrations).Error; err != nil {
+		return nil, fmt.Errorf("failed to list difficulty calibrations: %w", err)
+	}
+	return calibrations, nil
+}
+
+// Calibration returns the calibration of a community ("" for this one), or the
+// default when none is stored
+func (s *DifficultyService) Calibration(ctx context.Context, community string) (models.DifficultyCalibration, error) {
+	var calibration models.DifficultyCalibration
+	err := s.db.WithContext(ctx).Where("community = ?", community).First(&calibration).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.DefaultDifficultyCalibration(community), nil
+	}
+	if err != nil {
+		return calibration, fmt.Errorf("failed to load difficulty calibration: %w", err)
+	}
+	return calibration, nil
+}
+
+// SetCalibration stores the thresholds of a community. Changing this community's
+// calibration re-buckets the routes whose difficulty was derived from their score;
+// the number of changed routes is returned.
+func (s *DifficultyService) SetCalibration(ctx context.Context, community string, easyMax, moderateMax float64) (*models.DifficultyCalibration, int64, error) {
+	if easyMax <= 0 || moderateMax <= easyMax {
+		return nil, 0, ErrInvalidCalibration
+	}
+
+	calibration := &models.DifficultyCalibration{
+		Community:   community,
+		EasyMax:     easyMax,
+		ModerateMax: moderateMax,
+	}
+	var rebucketed int64
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "community"}},
+			DoUpdates: clause.AssignmentColumns([]string{"easy_max", "moderate_max", "updated_at"}),
+		}).Create(calibration).Error; err != nil {
+			return fmt.Errorf("failed to store difficulty calibration: %w", err)
+		}
+		if err := tx.Where("community = ?", community).First(calibration).Error; err != nil {
+			return fmt.Errorf("failed to reload difficulty calibration: %w", err)
+		}
+		if community != "" {
+			return nil
+		}
+
+		// Routes are only uploaded here, so other communities' calibrations never apply to them
+		result := tx.Model(&models.Route{}).
+			Where("difficulty_auto = ?", true).
+			Update("difficulty", gorm.Expr("CASE WHEN difficulty_score < ? THEN ? WHEN difficulty_score < ? THEN ? ELSE ? END",
+				easyMax, models.DifficultyEasy, moderateMax, models.DifficultyModerate, models.DifficultyHard))
+		if result.Error != nil {
+			return fmt.Errorf("failed to re-bucket route difficulties: %w", result.Error)
+		}
+		rebucketed = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return calibration, rebucketed, nil
+}
+
+// translateDifficulty returns the levels of another community's calibration whose score
+// ranges overlap the given level of this community's calibration
+func translateDifficulty(level string, local, other models.DifficultyCalibration) []string {
+	min, max, ok := local.ScoreRange(level)
+	if !ok {
+		return []string{level}
+	}
+
+	var levels []string
+	for _, candidate := range difficultyLevels {
+		otherMin, otherMax, _ := other.ScoreRange(candidate)
+		if min < otherMax && otherMin < max {
+			levels = append(levels, candidate)
+		}
+	}
+	return levels
+}
+
+// difficultyFilter returns a condition matching activities of the given
+// difficulty, phrased in this community's terms. Imported activities are matched
+// against the levels of their origin community's calibration.
+func difficultyFilter(ctx context.Context, db *gorm.DB, level string) (string, []interface{}, error) {
+	level = strings.ToLower(level)
+
+	var calibrations []models.DifficultyCalibration
+	if err := db.WithContext(ctx).Find(&calibrations).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to load difficulty calibrations: %w", err)
+	}
+
+	local := models.DefaultDifficultyCalibration("")
+	others := make([]models.DifficultyCalibration, 0, len(calibrations))
+	for _, calibration := range calibrations {
+		if calibration.Community == "" {
+			local = calibration
+		} else {
+			others = append(others, calibration)
+		}
+	}
+
+	conditions := []string{"(COALESCE(origin_community, '') = '' AND LOWER(difficulty) = ?)"}
+	args := []interface{}{level}
+	calibrated := make([]string, 0, len(others))
+	for _, other := range others {
+		conditions = append(conditions, "(origin_community = ? AND LOWER(difficulty) IN ?)")
+		args = append(args, other.Community, translateDifficulty(level, local, other))
+		calibrated = append(calibrated, other.Community)
+	}
+
+	uncalibrated := "(COALESCE(origin_community, '') <> '' AND LOWER(difficulty) IN ?)"
+	uncalibratedArgs := []interface{}{translateDifficulty(level, local, models.DefaultDifficultyCalibration(""))}
+	if len(calibrated) > 0 {
+		uncalibrated = "(COALESCE(origin_community, '') <> '' AND origin_community NOT IN ? AND LOWER(difficulty) IN ?)"
+		uncalibratedArgs = append([]interface{}{calibrated}, uncalibratedArgs...)
+	}
+	conditions = append(conditions, uncalibrated)
+	args = append(args, uncalibratedArgs...)
+
+	return "(" + strings.Join(conditions, " OR ") + ")", args, nil
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// digestBatchSize is how many preference rows are loaded per query
+const digestBatchSize = 500
+
+// Digest is the content delivered to one user
+type Digest struct {
+	Since      time.Time         `json:"since"`
+	Activities []models.Activity `json:"activities,omitempty"`
+	Routes     []models.Route    `json:"routes,omitempty"`
+}
+
+// IsEmpty reports whether the digest has nothing to deliver
+func (d Digest) IsEmpty() bool {
+	return len(d.Activities) == 0 && len(d.Routes) == 0
+}
+
+// DigestSender delivers a digest to a user (email, inbox, push, ...)
+type DigestSender interface {
+	SendDigest(ctx context.Context, user models.User, digest Digest) error
+}
+
+// LogDigestSender logs digests instead of delivering them; used until a mailer is configured
+type LogDigestSender struct{}
+
+// SendDigest logs the digest summary
+func (LogDigestSender) SendDigest(_ context.Context, user models.User, digest Digest) error {
+	log.Printf("[DIGEST] User %d <%s>: %d new activities, %d new routes since %s",
+		user.ID, user.Email, len(digest.Activities), len(digest.Routes), digest.Since.Format(time.RFC3339))
+	return nil
+}
+
+// DigestResult summarizes a digest run
+type DigestResult struct {
+	Scanned int
+	Due     int
+	Sent    int
+	Empty   int
+	Failed  int
+}
+
+// DigestService builds and sends notification digests according to user preferences
+type DigestService struct {
+	db     *gorm.DB
+	sender DigestSender
+}
+
+// NewDigestService creates a new digest service
+func NewDigestService(db *gorm.DB, sender DigestSender) *DigestService {
+	return &DigestService{
+		db:     db,
+		sender: sender,
+	}
+}
+
+// Run sends digests to every user due at now who has not revoked consent to
+// marketing emails. It is meant to run hourly: users are due when their local
+// hour matches their preferred hour. Preferences are scanned in
+// batches and new content is loaded once per batch rather than once per user.
+func (s *DigestService) Run(ctx context.Context, now time.Time) (DigestResult, error) {
+	var result DigestResult
+	var batch []models.UserPreferences
+
+	marketing, _ := models.FindConsentPurpose(models.ConsentMarketingEmails)
+	query := s.db.WithContext(ctx).
+		Preload("User").
+		Where("digest_frequency IN ?", []string{models.DigestDaily, models.DigestWeekly})
+	err := consented(query, marketing, "user_preferences.user_id").
+		FindInBatches(&batch, digestBatchSize, func(tx *gorm.DB, _ int) error {
+			result.Scanned += len(batch)
+
+			var due []models.UserPreferences
+			for _, prefs := range batch {
+				if prefs.DigestDue(now) {
+					due = append(due, prefs)
+				}
+			}
+			if len(due) == 0 {
+				return nil
+			}
+			result.Due += len(due)
+
+			return s.sendBatch(ctx, now, due, &result)
+		}).Error
+	if err != nil {
+		return result, fmt.Errorf("digest run failed: %w", err)
+	}
+
+	return result, nil
+}
+
+// sendBatch loads content for the oldest period in the batch once and fans it out per user
+func (s *DigestService) sendBatch(ctx context.Context, now time.Time, due []models.UserPreferences, result *DigestResult) error {
+	oldest := now
+	for _, prefs := range due {
+		if since := digestSince(prefs, now); since.Before(oldest) {
+			oldest = since
+		}
+	}
+
+	var activities []models.Activity
+	if err := s.db.WithContext(ctx).
+		Where("approved = ? AND created_at > ?", true, oldest).
+		Order("created_at DESC").
+		Find(&activities).Error; err != nil {
+		return fmt.Errorf("failed to load new activities: %w", err)
+	}
+
+	var routes []models.Route
+	if err := s.db.WithContext(ctx).
+		Joins("JOIN activities ON activities.id = routes.activity_id AND activities.approved = ?", true).
+		Where("routes.created_at > ?", oldest).
+		Order("routes.created_at DESC").
+		Find(&routes).Error; err != nil {
+		return fmt.Errorf("failed to load new routes: %w", err)
+	}
+
+	for _, prefs := range due {
+		since := digestSince(prefs, now)
+		digest := Digest{Since: since}
+
+		if prefs.WantsDigestContent(models.DigestContentActivities) {
+			for _, a := range activities {
+				if a.CreatedAt.After(since) {
+					digest.Activities = append(digest.Activities, a)
+				}
+			}
+		}
+		if prefs.WantsDigestContent(models.DigestContentRoutes) {
+			for _, r := range routes {
+				if r.CreatedAt.After(since) {
+					digest.Routes = append(digest.Routes, r)
+				}
+			}
+		}
+
+		if digest.IsEmpty() {
+			result.Empty++
+		} else if err := s.sender.SendDigest(ctx, prefs.User, digest); err != nil {
+			result.Failed++
+			log.Printf("[DIGEST] Failed to send digest to user %d: %v", prefs.UserID, err)
+			continue
+		} else {
+			result.Sent++
+		}
+
+		// Empty digests still advance the period so the next one covers only new content
+		if err := s.db.WithContext(ctx).Model(&models.UserPreferences{}).
+			Where("id = ?", prefs.ID).
+			Update("last_digest_at", now).Error; err != nil {
+			return fmt.Errorf("failed to record digest for user %d: %w", prefs.UserID, err)
+		}
+	}
+
+	return nil
+}
+
+// digestSince returns the start of the period covered by the user's next digest
+func digestSince(prefs models.UserPreferences, now time.Time) time.Time {
+	if prefs.LastDigestAt != nil {
+		return *prefs.LastDigestAt
+	}
+	if prefs.DigestFrequency == models.DigestWeekly {
+		return now.Add(-7 * 24 * time.Hour)
+	}
+	return now.Add(-24 * time.Hour)
+}
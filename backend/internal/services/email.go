@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/mailer"
+	"community-chatbot/internal/models"
+)
+
+// EmailSender renders emails from the mailer templates and queues them, so
+// requests do not wait for the mail server. It delivers invitations, email
+// confirmations, verification and password reset links, and moderation decisions.
+type EmailSender struct {
+	queue       *mailer.Queue
+	frontendURL string
+}
+
+// NewEmailSender creates a new email sender; links to activities point to frontendURL
+func NewEmailSender(queue *mailer.Queue, frontendURL string) *EmailSender {
+	return &EmailSender{
+		queue:       queue,
+		frontendURL: strings.TrimRight(frontendURL, "/"),
+	}
+}
+
+// SendInvitation emails the link to set a password to an imported user
+func (s *EmailSender) SendInvitation(_ context.Context, user models.User, acceptURL string, expiresAt time.Time) error {
+	return s.send(mailer.TemplateInvitation, user.Email, mailer.Data{Name: greetingName(user), URL: acceptURL, ExpiresAt: expiresAt})
+}
+
+// SendEmailChange emails the link confirming a new address to that address
+func (s *EmailSender) SendEmailChange(_ context.Context, user models.User, email, confirmURL string, expiresAt time.Time) error {
+	return s.send(mailer.TemplateEmailChange, email, mailer.Data{Name: greetingName(user), URL: confirmURL, ExpiresAt: expiresAt, Email: email})
+}
+
+// SendEmailChangeNotice warns the current address of a user that a change to
+// email was asked for
+func (s *EmailSender) SendEmailChangeNotice(_ context.Context, user models.User, email string) error {
+	return s.send(mailer.TemplateEmailNotice, user.Email, mailer.Data{Name: greetingName(user), Email: email})
+}
+
+// SendVerification emails the link verifying a user's address
+func (s *EmailSender) SendVerification(_ context.Context, user models.User, verifyURL string, expiresAt time.Time) error {
+	return s.send(mailer.TemplateVerifyEmail, user.Email, mailer.Data{Name: greetingName(user), URL: verifyURL, ExpiresAt: expiresAt})
+}
+
+// SendPasswordReset emails the link to choose a new password
+func (s *EmailSender) SendPasswordReset(_ context.Context, user models.User, resetURL string, expiresAt time.Time) error {
+	return s.send(mailer.TemplatePasswordReset, user.Email, mailer.Data{Name: greetingName(user), URL: resetURL, ExpiresAt: expiresAt})
+}
+
+// SendModeration emails the submitter of an activity the decision on it
+func (s *EmailSender) SendModeration(_ context.Context, user models.User, activity models.Activity) error {
+	data := mailer.Data{
+		Name:     greetingName(user),
+		URL:      fmt.Sprintf("%s/activities/%d", s.frontendURL, activity.ID),
+		Activity: activity.Name,
+	}
+	template := mailer.TemplateActivityApproved
+	if !activity.Approved {
+		template = mailer.TemplateActivityRejected
+		data.Reason = activity.RejectionReason
+	}
+	return s.send(template, user.Email, data)
+}
+
+// send renders a template and queues the email
+func (s *EmailSender) send(template, to string, data mailer.Data) error {
+	message, err := mailer.Render(template, to, data)
+	if err != nil {
+		return err
+	}
+	return s.queue.Enqueue(message)
+}
+
+// greetingName is how emails address a user
+func greetingName(user models.User) string {
+	if user.Name != "" {
+		return user.Name
+	}
+	return "there"
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/auth"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// embedKeyPrefix marks embed API keys so they are recognizable when leaked
+const embedKeyPrefix = "emb_"
+
+var (
+	// ErrInvalidEmbedKey is returned for unknown and revoked embed API keys alike
+	ErrInvalidEmbedKey = apperr.New(apperr.Unauthorized, "invalid embed API key")
+	// ErrInvalidOrigin is returned for origins that are not scheme://host[:port]
+	ErrInvalidOrigin = apperr.New(apperr.Invalid, "origin must be an http or https scheme and host")
+	// ErrOriginNotAllowed is returned when a key is used for an origin it does not list
+	ErrOriginNotAllowed = apperr.New(apperr.Forbidden, "origin is not allowed for this embed key")
+)
+
+// EmbedService manages the API keys of sites embedding the chat widget and issues
+// short-lived, origin-bound widget tokens
+type EmbedService struct {
+	db       *gorm.DB
+	tokens   *auth.TokenIssuer
+	tokenTTL time.Duration
+}
+
+// NewEmbedService creates a new embed service
+func NewEmbedService(db *gorm.DB, tokens *auth.TokenIssuer, tokenTTL time.Duration) *EmbedService {
+	return &EmbedService{
+		db:       db,
+		tokens:   tokens,
+		tokenTTL: tokenTTL,
+	}
+}
+
+// TokenTTL returns the lifetime of widget tokens
+func (s *EmbedService) TokenTTL() time.Duration {
+	return s.tokenTTL
+}
+
+// CreateKey creates an embed key for the given origins and returns it with the
+// secret API key, which is not stored and cannot be shown again
+func (s *EmbedService) CreateKey(ctx context.Context, name string, origins []string, rate float64, burst int) (*models.EmbedKey, string, error) {
+	normalized := make(models.StringList, 0, len(origins))
+	for _, origin := range origins {
+		o, err := NormalizeOrigin(origin)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: %q", ErrInvalidOrigin, origin)
+		}
+		normalized = append(normalized, o)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", fmt.Errorf("failed to generate embed key: %w", err)
+	}
+	apiKey := embedKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	key := &models.EmbedKey{
+		Name:           name,
+		KeyHash:        hashAPIKey(apiKey),
+		KeyPrefix:      apiKey[:len(embedKeyPrefix)+6],
+		AllowedOrigins: normalized,
+		Rate:           rate,
+		Burst:          burst,
+	}
+	if err := s.db.WithContext(ctx).Create(key).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create embed key: %w", err)
+	}
+	return key, apiKey, nil
+}
+
+// ListKeys returns all embed keys, newest first
+func (s *EmbedService) ListKeys(ctx context.Context) ([]models.EmbedKey, error) {
+	var keys []models.EmbedKey
+	if err := s.db.WithContext(ctx).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to list embed keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeKey stops accepting an embed key and the widget tokens issued for it
+func (s *EmbedService) RevokeKey(ctx context.Context, id uint) error {
+	result := s.db.WithContext(ctx).Model(&models.EmbedKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke embed key %d: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to revoke embed key %d: %w", id, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// ActiveKey returns a non-revoked embed key, or nil when there is none with the ID
+func (s *EmbedService) ActiveKey(ctx context.Context, id uint) (*models.EmbedKey, error) {
+	var key models.EmbedKey
+	err := s.db.WithContext(ctx).Where("revoked_at IS NULL").First(&key, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load embed key %d: %w", id, err)
+	}
+	return &key, nil
+}
+
+// IssueToken exchanges an embed API key for a widget token bound to origin
+func (s *EmbedService) IssueToken(ctx context.Context, apiKey, origin string) (string, error) {
+	var key models.EmbedKey
+	err := s.db.WithContext(ctx).
+		Where("key_hash = ? AND revoked_at IS NULL", hashAPIKey(apiKey)).
+		First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrInvalidEmbedKey
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load embed key: %w", err)
+	}
+
+	origin, err = NormalizeOrigin(origin)
+	if err != nil {
+		return "", err
+	}
+	if !key.AllowsOrigin(origin) {
+		return "", ErrOriginNotAllowed
+	}
+
+	token, err := s.tokens.IssueEmbed(ctx, key.ID, origin, s.tokenTTL)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.db.WithContext(ctx).Model(&key).Update("last_used_at", time.Now()).Error; err != nil {
+		return "", fmt.Errorf("failed to record use of embed key %d: %w", key.ID, err)
+	}
+	return token, nil
+}
+
+// NormalizeOrigin returns origin as lowercase scheme://host[:port], the form
+// browsers send in the Origin header
+func NormalizeOrigin(origin string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(origin))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" ||
+		(parsed.Path != "" && parsed.Path != "/") || parsed.RawQuery != "" || parsed.User != nil {
+		return "", ErrInvalidOrigin
+	}
+	return strings.ToLower(parsed.Scheme + "://" + parsed.Host), nil
+}
+
+// hashAPIKey returns the stored form of an embed or personal API key
+func hashAPIKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:])
+}
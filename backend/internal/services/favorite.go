@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FavoriteService stores the activities users saved
+type FavoriteService struct {
+	db *gorm.DB
+}
+
+// NewFavoriteService creates a new favorite service
+func NewFavoriteService(db *gorm.DB) *FavoriteService {
+	return &FavoriteService{
+		db: db,
+	}
+}
+
+// Add saves an approved activity for a user; saving it again changes nothing
+func (s *FavoriteService) Add(ctx context.Context, userID, activityID uint) (*models.Favorite, error) {
+	var activity models.Activity
+	if err := s.db.WithContext(ctx).Where("approved = ?", true).First(&activity, activityID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load activity %d: %w", activityID, err)
+	}
+
+	favorite := &models.Favorite{UserID: userID, ActivityID: activityID}
+	result := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(favorite)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to save favorite: %w", result.Error)
+	}
+	// New favorites rank trending activities; failing to count one does not fail saving it
+	if result.RowsAffected > 0 {
+		if err := recordActivityStat(ctx, s.db, "favorites", activityID); err != nil {
+			log.Printf("[STATS] %v", err)
+		}
+	}
+	if err := s.db.WithContext(ctx).Where("user_id = ? AND activity_id = ?", userID, activityID).First(favorite).Error; err != nil {
+		return nil, fmt.Errorf("failed to load favorite: %w", err)
+	}
+	favorited := true
+	activity.IsFavorited = &favorited
+	favorite.Activity = &activity
+	return favorite, nil
+}
+
+// Remove unsaves an activity; removing one that is not saved changes nothing
+func (s *FavoriteService) Remove(ctx context.Context, userID, activityID uint) error {
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ? AND activity_id = ?", userID, activityID).
+		Delete(&models.Favorite{}).Error; err != nil {
+		return fmt.Errorf("failed to remove favorite: %w", err)
+	}
+	return nil
+}
+
+// List returns a page of a user's saved activities, most recently saved first,
+// and the total count. Activities that were deleted or unpublished since are left out.
+func (s *FavoriteService) List(ctx context.Context, userID uint, page, pageSize int) ([]models.Favorite, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.Favorite{}).
+		Joins("JOIN activities ON activities.id = favorites.activity_id AND activities.deleted_at IS NULL AND activities.approved").
+		Where("favorites.user_id = ?", userID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count favorites: %w", err)
+	}
+
+	var favorites []models.Favorite
+	if err := query.Select("favorites.*").Preload("Activity").
+		Order("favorites.created_at DESC, favorites.activity_id").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&favorites).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list favorites: %w", err)
+	}
+
+	favorited := true
+	for i := range favorites {
+		if favorites[i].Activity != nil {
+			favorites[i].Activity.IsFavorited = &favorited
+		}
+	}
+	return favorites, total, nil
+}
+
+// IDs returns the IDs of all activities a user saved
+func (s *FavoriteService) IDs(ctx context.Context, userID uint) ([]uint, error) {
+	var ids []uint
+	if err := s.db.WithContext(ctx).Model(&models.Favorite{}).
+		Where("user_id = ?", userID).Pluck("activity_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to load favorites of user %d: %w", userID, err)
+	}
+	return ids, nil
+}
+
+// Mark sets IsFavorited on activities for a user
+func (s *FavoriteService) Mark(ctx context.Context, userID uint, activities []*models.Activity) error {
+	if len(activities) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(activities))
+	for i, activity := range activities {
+		ids[i] = activity.ID
+	}
+
+	var saved []uint
+	if err := s.db.WithContext(ctx).Model(&models.Favorite{}).
+		Where("user_id = ? AND activity_id IN ?", userID, ids).
+		Pluck("activity_id", &saved).Error; err != nil {
+		return fmt.Errorf("failed to load favorites: %w", err)
+	}
+	isSaved := make(map[uint]bool, len(saved))
+	for _, id := range saved {
+		isSaved[id] = true
+	}
+	for _, activity := range activities {
+		favorited := isSaved[activity.ID]
+		activity.IsFavorited = &favorited
+	}
+	return nil
+}
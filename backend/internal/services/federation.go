@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/federation"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrFederationDisabled is returned when export or import is not configured
+var ErrFederationDisabled = errors.New("federation is not configured")
+
+// duplicateDistanceDeg is how close (about 100 m) an activity with the same name
+// must be to count as a duplicate of an imported one
+const duplicateDistanceDeg = 0.001
+
+// FederationService exports signed activity bundles and imports bundles from
+// trusted communities
+type FederationService struct {
+	db       *gorm.DB
+	signer   *federation.Signer
+	verifier *federation.Verifier
+}
+
+// NewFederationService creates a new federation service. A nil signer disables
+// export and a nil verifier disables import.
+func NewFederationService(db *gorm.DB, signer *federation.Signer, verifier *federation.Verifier) *FederationService {
+	return &FederationService{
+		db:       db,
+		signer:   signer,
+		verifier: verifier,
+	}
+}
+
+// ExportFilter selects the activities to export
+type ExportFilter struct {
+	Category string
+	IDs      []uint
+}
+
+// ImportResult reports what an import did. IDs maps activity IDs of the exporting
+// community to local IDs, for imported activities and skipped duplicates alike.
+type ImportResult struct {
+	Community  string        `json:"community"`
+	Imported   int           `json:"imported"`
+	Duplicates int           `json:"duplicates"`
+	Invalid    int           `json:"invalid"`
+	IDs        map[uint]uint `json:"ids"`
+}
+
+// Community returns the name and public key this instance signs bundles with
+func (s *FederationService) Community() (name, publicKey string, err error) {
+	if s.signer == nil {
+		return "", "", ErrFederationDisabled
+	}
+	return s.signer.Community(), s.signer.PublicKey(), nil
+}
+
+// Export returns a signed bundle of approved activities and their approved images.
+// Activities imported from elsewhere keep their original attribution.
+func (s *FederationService) Export(ctx context.Context, filter ExportFilter) (*federation.Envelope, error) {
+	if s.signer == nil {
+		return nil, ErrFederationDisabled
+	}
+
+	query := s.db.WithContext(ctx).
+		Preload("Images", "approved = ?", true).
+		Where("approved = ?", true)
+	if filter.Category != "" {
+		condition, args, err := categoryFilter(ctx, s.db, filter.Category)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(condition, args...)
+	}
+	if len(filter.IDs) > 0 {
+		query = query.Where("id IN ?", filter.IDs)
+	}
+
+	var activities []models.Activity
+	if err := query.Order("id").Find(&activities).Error; err != nil {
+		return nil, fmt.Errorf("failed to load activities for export: %w", err)
+	}
+
+	bundle := &federation.Bundle{
+		ExportedAt: time.Now().UTC(),
+		Activities: make([]federation.Activity, 0, len(activities)),
+	}
+	for _, activity := range activities {
+		exported := federation.Activity{
+			ID:              activity.ID,
+			OriginCommunity: s.signer.Community(),
+			OriginID:        activity.ID,
+			Name:            activity.Name,
+			Description:     activity.Description,
+			Category:        activity.Category,
+			Latitude:        activity.Latitude,
+			Longitude:       activity.Longitude,
+			Difficulty:      activity.Difficulty,
+			Duration:        activity.Duration,
+			BestSeason:      activity.BestSeason,
+			OpeningHours:    activity.OpeningHours,
+			Media:           make([]federation.Media, 0, len(activity.Images)),
+		}
+		if activity.OriginCommunity != "" {
+			exported.OriginCommunity = activity.OriginCommunity
+			exported.OriginID = activity.OriginID
+		}
+		for _, image := range activity.Images {
+			exported.Media = append(exported.Media, federation.Media{URL: image.URL, Caption: image.Caption})
+		}
+		bundle.Activities = append(bundle.Activities, exported)
+	}
+
+	return s.signer.Sign(bundle)
+}
+
+// Import verifies a bundle and stores its activities as approved content attributed
+// to their origin community. Activities that already exist here, by origin or by
+// name and location, are skipped. The import is all or nothing.
+func (s *FederationService) Import(ctx context.Context, envelope *federation.Envelope) (*ImportResult, error) {
+	if s.verifier == nil {
+		return nil, ErrFederationDisabled
+	}
+	bundle, err := s.verifier.Open(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{Community: bundle.Community, IDs: make(map[uint]uint)}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, imported := range bundle.Activities {
+			if !validImport(imported) {
+				result.Invalid++
+				continue
+			}
+
+			existing, err := s.findDuplicate(tx, imported)
+			if err != nil {
+				return err
+			}
+			if existing != 0 {
+				result.Duplicates++
+				result.IDs[imported.ID] = existing
+				continue
+			}
+
+			id, err := createImported(ctx, tx, imported)
+			if err != nil {
+				return err
+			}
+			result.Imported++
+			result.IDs[imported.ID] = id
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// findDuplicate returns the local ID of an activity matching the imported one, or 0.
+// Deleted activities count too, so content removed here is not brought back.
+func (s *FederationService) findDuplicate(tx *gorm.DB, imported federation.Activity) (uint, error) {
+	var ids []uint
+	query := tx.Unscoped().Model(&models.Activity{})
+	if s.signer != nil && imported.OriginCommunity == s.signer.Community() {
+		// One of our own activities coming back through another community
+		query = query.Where("id = ? AND origin_community = ''", imported.OriginID)
+	} else {
+		query = query.Where("origin_community = ? AND origin_id = ?", imported.OriginCommunity, imported.OriginID)
+	}
+	if err := query.Limit(1).Pluck("id", &ids).Error; err != nil {
+		return 0, fmt.Errorf("failed to look up origin of activity %d: %w", imported.ID, err)
+	}
+	if len(ids) > 0 {
+		return ids[0], nil
+	}
+
+	if err := tx.Unscoped().Model(&models.Activity{}).
+		Where("LOWER(name) = ?", strings.ToLower(imported.Name)).
+		Where("latitude BETWEEN ? AND ?", imported.Latitude-duplicateDistanceDeg, imported.Latitude+duplicateDistanceDeg).
+		Where("longitude BETWEEN ? AND ?", imported.Longitude-duplicateDistanceDeg, imported.Longitude+duplicateDistanceDeg).
+		Limit(1).
+		Pluck("id", &ids).Error; err != nil {
+		return 0, fmt.Errorf("failed to look up duplicates of activity %d: %w", imported.ID, err)
+	}
+	if len(ids) > 0 {
+		return ids[0], nil
+	}
+	return 0, nil
+}
+
+// createImported stores an imported activity and its media, approved on import.
+// Activities whose category is not known here keep it as free text.
+func createImported(ctx context.Context, tx *gorm.DB, imported federation.Activity) (uint, error) {
+	now := time.Now()
+	activity := models.Activity{
+		Name:            imported.Name,
+		Description:     imported.Description,
+		Category:        imported.Category,
+		Latitude:        imported.Latitude,
+		Longitude:       imported.Longitude,
+		Difficulty:      imported.Difficulty,
+		Duration:        imported.Duration,
+		BestSeason:      imported.BestSeason,
+		OpeningHours:    imported.OpeningHours,
+		Approved:        true,
+		ModeratedAt:     &now,
+		OriginCommunity: imported.OriginCommunity,
+		OriginID:        imported.OriginID,
+	}
+	if err := assignCategory(ctx, tx, &activity); err != nil && !errors.Is(err, ErrUnknownCategory) {
+		return 0, err
+	}
+	for _, media := range imported.Media {
+		if !media.Hosted() {
+			continue
+		}
+		activity.Images = append(activity.Images, models.Image{
+			URL:         media.URL,
+			Caption:     media.Caption,
+			Approved:    true,
+			ModeratedAt: &now,
+		})
+	}
+
+	if err := tx.Create(&activity).Error; err != nil {
+		return 0, fmt.Errorf("failed to import activity %d: %w", imported.ID, err)
+	}
+	return activity.ID, nil
+}
+
+// validImport reports whether an imported activity has the fields local submissions require
+func validImport(imported federation.Activity) bool {
+	return imported.OriginCommunity != "" && imported.OriginID != 0 &&
+		len(strings.TrimSpace(imported.Name)) >= 3 && len(imported.Name) <= 255 &&
+		math.Abs(imported.Latitude) <= 90 && math.Abs(imported.Longitude) <= 180
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"community-chatbot/internal/agui"
+	"community-chatbot/internal/cache"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/ratelimit"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var floodPenalties = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "chat_flood_penalties_total",
+	Help: "Chat runs penalized for sending messages too quickly, by penalty (delay or block).",
+}, []string{"penalty"})
+
+// maxFloodBlock caps how long repeated blocks last
+const maxFloodBlock = 24 * time.Hour
+
+// FloodLimits are the messages per minute from which a client is penalized
+type FloodLimits struct {
+	// SlowdownAfter is the number of messages a minute after which each further
+	// message is delayed, by more the closer the client gets to BlockAfter
+	SlowdownAfter int
+	MaxDelay      time.Duration
+	// BlockAfter is the number of messages a minute after which the client is
+	// blocked for BlockDuration, doubled for each block within a day
+	BlockAfter    int
+	BlockDuration time.Duration
+}
+
+// AbuseSignalRecorder stores suspected abuse
+type AbuseSignalRecorder interface {
+	Record(ctx context.Context, signal models.AbuseSignal) error
+}
+
+// FloodGuard slows down and then blocks clients sending chat messages faster
+// than people type, such as scripts sending distinct prompts. Clients are
+// identified by user, or when anonymous by both fingerprint and IP address, so
+// rotating fingerprints does not reset the count; counts and blocks are shared
+// through Redis when configured.
+type FloodGuard struct {
+	counter ratelimit.Counter
+	blocks  cache.Store
+	limits  FloodLimits
+	signals AbuseSignalRecorder
+}
+
+// NewFloodGuard creates a new flood guard. Penalties are recorded as abuse
+// signals when signals is not nil.
+func NewFloodGuard(counter ratelimit.Counter, blocks cache.Store, limits FloodLimits, signals AbuseSignalRecorder) *FloodGuard {
+	return &FloodGuard{
+		counter: counter,
+		blocks:  blocks,
+		limits:  limits,
+		signals: signals,
+	}
+}
+
+// Stage returns the pipeline stage that counts each run against its client's
+// rate, penalizing guests when either their fingerprint or their IP address is
+// over a limit. Runs over SlowdownAfter are delayed after a THROTTLED event;
+// runs of blocked clients end with a MESSAGE_FLOOD error instead of an answer.
+// When the counter or the block store fails, runs are let through.
+func (g *FloodGuard) Stage() chat.Stage {
+	return chat.StageFunc{
+		StageName: "flood_guard",
+		Fn: func(ctx context.Context, run *chat.Run, next chat.Handler) error {
+			if run.DryRun {
+				return next(ctx, run)
+			}
+			clients := floodClients(run)
+			for _, client := range clients {
+				if blockedUntil, ok := g.blockedUntil(ctx, client); ok {
+					floodPenalties.WithLabelValues("block").Inc()
+					return run.Emit(agui.CreateMessageFloodEvent(floodBlocked(blockedUntil)))
+				}
+			}
+
+			// The client with the highest count is the one penalized
+			now := time.Now().UTC()
+			var count int64
+			var client string
+			for _, id := range clients {
+				n, err := g.counter.Increment(ctx, fmt.Sprintf("flood:%s:%s", id, now.Format("2006-01-02T15:04")), 2*time.Minute)
+				if err != nil {
+					log.Printf("[FLOOD] Run %s: counter failed, allowing: %v", run.ID, err)
+					return next(ctx, run)
+				}
+				if n > count {
+					count, client = n, id
+				}
+			}
+
+			switch {
+			case g.limits.BlockAfter > 0 && count > int64(g.limits.BlockAfter):
+				blockedUntil, err := g.block(ctx, client, now)
+				if err != nil {
+					log.Printf("[FLOOD] Run %s: block failed, allowing: %v", run.ID, err)
+					return next(ctx, run)
+				}
+				floodPenalties.WithLabelValues("block").Inc()
+				log.Printf("[FLOOD] Run %s: %s blocked until %s after %d messages in a minute (client %s)",
+					run.ID, client, blockedUntil.Format(time.RFC3339), count, run.ClientIP)
+				g.record(ctx, run, models.AbuseMessageFloodBlock, fmt.Sprintf("%d messages in a minute, blocked until %s",
+					count, blockedUntil.Format(time.RFC3339)))
+				return run.Emit(agui.CreateMessageFloodEvent(floodBlocked(blockedUntil)))
+
+			case g.limits.SlowdownAfter > 0 && count > int64(g.limits.SlowdownAfter):
+				delay := g.delay(count)
+				floodPenalties.WithLabelValues("delay").Inc()
+				// Each slowed down minute is recorded once
+				if count == int64(g.limits.SlowdownAfter)+1 {
+					log.Printf("[FLOOD] Run %s: slowing down %s after %d messages in a minute (client %s)",
+						run.ID, client, count, run.ClientIP)
+					g.record(ctx, run, models.AbuseMessageFlood, fmt.Sprintf("more than %d messages in a minute, delayed", g.limits.SlowdownAfter))
+				}
+				if err := run.Emit(agui.CreateThrottledEvent("You are sending messages very quickly, so this answer is delayed.", delay)); err != nil {
+					return err
+				}
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(delay):
+				}
+			}
+			return next(ctx, run)
+		},
+	}
+}
+
+// delay returns how long to hold back a client's count-th message of a minute,
+// growing from a second to MaxDelay at the blocking threshold
+func (g *FloodGuard) delay(count int64) time.Duration {
+	over := count - int64(g.limits.SlowdownAfter)
+	steps := int64(g.limits.BlockAfter - g.limits.SlowdownAfter)
+	if g.limits.BlockAfter <= g.limits.SlowdownAfter || over >= steps {
+		return g.limits.MaxDelay
+	}
+	return max(time.Second, g.limits.MaxDelay*time.Duration(over)/time.Duration(steps))
+}
+
+// block blocks a client for BlockDuration, doubled for each earlier block
+// within a day, and returns when the block ends
+func (g *FloodGuard) block(ctx context.Context, client string, now time.Time) (time.Time, error) {
+	strikes, err := g.counter.Increment(ctx, "flood:strikes:"+client, 24*time.Hour)
+	if err != nil {
+		return time.Time{}, err
+	}
+	duration := g.limits.BlockDuration
+	for i := int64(1); i < strikes && duration < maxFloodBlock; i++ {
+		duration *= 2
+	}
+	duration = min(duration, maxFloodBlock)
+
+	blockedUntil := now.Add(duration)
+	value, err := blockedUntil.MarshalText()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := g.blocks.Set(ctx, "flood:block:"+client, value, duration); err != nil {
+		return time.Time{}, err
+	}
+	return blockedUntil, nil
+}
+
+// blockedUntil returns when a client's block ends, or ok=false when it is not blocked
+func (g *FloodGuard) blockedUntil(ctx context.Context, client string) (time.Time, bool) {
+	value, ok, err := g.blocks.Get(ctx, "flood:block:"+client)
+	if err != nil {
+		log.Printf("[FLOOD] Block lookup of %s failed, allowing: %v", client, err)
+		return time.Time{}, false
+	}
+	var blockedUntil time.Time
+	if !ok || blockedUntil.UnmarshalText(value) != nil || !blockedUntil.After(time.Now()) {
+		return time.Time{}, false
+	}
+	return blockedUntil, true
+}
+
+// record stores an abuse signal for the client of a run
+func (g *FloodGuard) record(ctx context.Context, run *chat.Run, kind, detail string) {
+	if g.signals == nil {
+		return
+	}
+	signal := models.AbuseSignal{
+		Kind:        kind,
+		Fingerprint: run.Fingerprint,
+		ClientIP:    run.ClientIP,
+		Detail:      detail,
+	}
+	if run.UserID != 0 {
+		userID := run.UserID
+		signal.UserID = &userID
+	}
+	if err := g.signals.Record(ctx, signal); err != nil {
+		log.Printf("[FLOOD] Run %s: %v", run.ID, err)
+	}
+}
+
+// floodClients identifies the client of a run: its user, or the fingerprint
+// and the IP address of a guest
+func floodClients(run *chat.Run) []string {
+	if run.UserID != 0 {
+		return []string{fmt.Sprintf("user:%d", run.UserID)}
+	}
+	clients := []string{"ip:" + run.ClientIP}
+	if run.Fingerprint != "" {
+		clients = append(clients, "guest:"+run.Fingerprint)
+	}
+	return clients
+}
+
+// floodBlocked describes a block
+func floodBlocked(blockedUntil time.Time) agui.MessageFloodData {
+	retryAfter := time.Until(blockedUntil)
+	wait := "a minute"
+	if minutes := int(math.Ceil(retryAfter.Minutes())); minutes >= 120 {
+		wait = fmt.Sprintf("%d hours", minutes/60)
+	} else if minutes > 1 {
+		wait = fmt.Sprintf("%d minutes", minutes)
+	}
+	return agui.MessageFloodData{
+		Message:           fmt.Sprintf("You are sending messages too quickly. Please wait %s before sending another one.", wait),
+		BlockedUntil:      blockedUntil,
+		RetryAfterSeconds: int(math.Ceil(retryAfter.Seconds())),
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/cache"
+	"community-chatbot/internal/geocode"
+)
+
+// geocodeMaxResults is how many places are looked up and cached per query
+const geocodeMaxResults = 5
+
+var (
+	// ErrInvalidPlace is returned for empty or overly long place queries
+	ErrInvalidPlace = apperr.New(apperr.Invalid, "place must be between 1 and 200 characters")
+	// ErrPlaceNotFound is returned when no place matches a query
+	ErrPlaceNotFound = apperr.New(apperr.NotFound, "no place matches the name")
+)
+
+// GeocodeService resolves place names to coordinates. Lookups are cached by
+// normalized query, since the same towns and parks are asked about over and
+// over and public geocoders allow few requests.
+type GeocodeService struct {
+	geocoder geocode.Geocoder
+	cache    *cache.Cache
+}
+
+// NewGeocodeService creates a new geocode service; places may be nil to
+// disable caching
+func NewGeocodeService(geocoder geocode.Geocoder, places *cache.Cache) *GeocodeService {
+	return &GeocodeService{
+		geocoder: geocoder,
+		cache:    places,
+	}
+}
+
+// Search returns up to limit places matching query, best matches first
+func (s *GeocodeService) Search(ctx context.Context, query string, limit int) ([]geocode.Place, error) {
+	query = strings.Join(strings.Fields(strings.ToLower(query)), " ")
+	if query == "" || len(query) > 200 {
+		return nil, ErrInvalidPlace
+	}
+	if limit <= 0 || limit > geocodeMaxResults {
+		limit = geocodeMaxResults
+	}
+
+	places, err := cache.Lookup(ctx, s.cache, query, func(ctx context.Context) ([]geocode.Place, error) {
+		places, err := s.geocoder.Search(ctx, query, geocodeMaxResults)
+		if err != nil {
+			return nil, fmt.Errorf("failed to geocode %q: %w", query, err)
+		}
+		return places, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return places[:min(limit, len(places))], nil
+}
+
+// Resolve returns the best match for a place name
+func (s *GeocodeService) Resolve(ctx context.Context, query string) (*geocode.Place, error) {
+	places, err := s.Search(ctx, query, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(places) == 0 {
+		return nil, ErrPlaceNotFound
+	}
+	return &places[0], nil
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidGuardrails is returned for policies with an unknown refusal style or empty topics
+var ErrInvalidGuardrails = apperr.New(apperr.Invalid, "refusal_style must be brief or redirect and topics must not be empty")
+
+// GuardrailService manages the operator's topic rules for the chat
+type GuardrailService struct {
+	db *gorm.DB
+}
+
+// NewGuardrailService creates a new guardrail service
+func NewGuardrailService(db *gorm.DB) *GuardrailService {
+	return &GuardrailService{
+		db: db,
+	}
+}
+
+// GuardrailDryRun shows how guardrails treat a message and an answer
+type GuardrailDryRun struct {
+	// Prompt is the instruction compiled into the system prompt
+	Prompt  string                `json:"prompt"`
+	Refusal string                `json:"refusal"`
+	Input   chat.GuardrailVerdict `json:"input"`
+	Output  chat.GuardrailVerdict `json:"output"`
+	// Answer is what the user would see: the refusal for a blocked message,
+	// otherwise the response as filtered while streaming
+	Answer string `json:"answer"`
+}
+
+// Get returns the guardrail policy, or an empty policy when none is stored
+func (s *GuardrailService) Get(ctx context.Context) (*models.GuardrailPolicy, error) {
+	var policy models.GuardrailPolicy
+	err := s.db.WithContext(ctx).First(&policy, models.GuardrailPolicyID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.GuardrailPolicy{RefusalStyle: chat.RefusalBrief}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load guardrail policy: %w", err)
+	}
+	return &policy, nil
+}
+
+// Set stores the guardrail policy; it applies to the next chat run
+func (s *GuardrailService) Set(ctx context.Context, policy *models.GuardrailPolicy) (*models.GuardrailPolicy, error) {
+	if err := validateGuardrails(policy); err != nil {
+		return nil, err
+	}
+
+	policy.ID = models.GuardrailPolicyID
+	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"allowed_topics", "blocked_topics", "refusal_style", "refusal_message", "updated_at"}),
+	}).Create(policy).Error; err != nil {
+		return nil, fmt.Errorf("failed to store guardrail policy: %w", err)
+	}
+	return policy, nil
+}
+
+// Guardrails returns the stored guardrails, or nil when no topics are restricted.
+// It is the chat.GuardrailLoader of GuardrailStage.
+func (s *GuardrailService) Guardrails(ctx context.Context) (*chat.Guardrails, error) {
+	policy, err := s.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(policy.AllowedTopics) == 0 && len(policy.BlockedTopics) == 0 {
+		return nil, nil
+	}
+	return s.Compile(policy), nil
+}
+
+// DryRun classifies a message and a sample response with a policy, or with the
+// stored policy when policy is nil, without touching the chat
+func (s *GuardrailService) DryRun(ctx context.Context, policy *models.GuardrailPolicy, message, response string) (*GuardrailDryRun, error) {
+	if policy == nil {
+		var err error
+		if policy, err = s.Get(ctx); err != nil {
+			return nil, err
+		}
+	} else if err := validateGuardrails(policy); err != nil {
+		return nil, err
+	}
+
+	guardrails := s.Compile(policy)
+	result := &GuardrailDryRun{
+		Prompt:  guardrails.Prompt(),
+		Refusal: guardrails.RefusalMessage(),
+		Input:   guardrails.CheckMessage(message),
+		Output:  guardrails.Check(response),
+	}
+	if !result.Input.Allowed {
+		result.Answer = result.Refusal
+		return result, nil
+	}
+
+	filter := chat.NewGuardrailFilter(guardrails, "dry-run")
+	filtered, err := filter.Filter(ctx, response)
+	if err != nil {
+		return nil, err
+	}
+	flushed, err := filter.Flush(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result.Answer = filtered + flushed
+	return result, nil
+}
+
+// Compile turns a policy into the guardrails enforced in the chat
+func (s *GuardrailService) Compile(policy *models.GuardrailPolicy) *chat.Guardrails {
+	return chat.NewGuardrails(policy.AllowedTopics, policy.BlockedTopics, policy.RefusalStyle, policy.RefusalMessage)
+}
+
+// validateGuardrails checks a policy and trims its topics
+func validateGuardrails(policy *models.GuardrailPolicy) error {
+	if policy.RefusalStyle == "" {
+		policy.RefusalStyle = chat.RefusalBrief
+	}
+	if policy.RefusalStyle != chat.RefusalBrief && policy.RefusalStyle != chat.RefusalRedirect {
+		return ErrInvalidGuardrails
+	}
+	for _, topics := range []models.StringList{policy.AllowedTopics, policy.BlockedTopics} {
+		for i, topic := range topics {
+			if topics[i] = strings.TrimSpace(topic); topics[i] == "" {
+				return ErrInvalidGuardrails
+			}
+		}
+	}
+	policy.RefusalMessage = strings.TrimSpace(policy.RefusalMessage)
+	return nil
+}
@@ -0,0 +1,395 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/moderation"
+	"community-chatbot/internal/storage"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// imageUploadBatchSize is how many due uploads are loaded per query
+	imageUploadBatchSize = 20
+	// imageUploadLease is how long a replica may take to upload an image before
+	// another replica retries it
+	imageUploadLease = 5 * time.Minute
+)
+
+// ErrImageNotReady is returned when an image is moderated before its upload completed
+var ErrImageNotReady = apperr.New(apperr.Conflict, "image upload has not completed")
+
+// UploadRetryConfig controls how failed image uploads are retried
+type UploadRetryConfig struct {
+	// MaxAttempts is how many uploads are attempted before the image is marked failed
+	MaxAttempts int
+	// BaseDelay is the wait after the first failure; it doubles with every attempt
+	BaseDelay time.Duration
+	// MaxDelay caps the wait between attempts
+	MaxDelay time.Duration
+}
+
+// ImageScreening configures automatic screening of images before their upload
+type ImageScreening struct {
+	// Screener is nil to leave every image to moderators
+	Screener   moderation.Screener
+	Thresholds moderation.Thresholds
+	// AutoApprove approves images screened clear once uploaded, without a moderator
+	AutoApprove bool
+}
+
+// ImageService accepts activity images and uploads them to the image host in the
+// background. Files are spooled first, so uploads survive image host outages and
+// restarts; images are processing until their upload completes. With a
+// screener, flagged images are rejected before upload.
+type ImageService struct {
+	db            *gorm.DB
+	spool         *storage.Spool
+	uploader      storage.Uploader
+	notifications *NotificationService
+	retry         UploadRetryConfig
+	screening     ImageScreening
+	// trust publishes images of trusted uploaders without pre-moderation
+	trust *TrustService
+	// wake nudges the upload loop when an image is accepted
+	wake chan struct{}
+}
+
+// NewImageService creates a new image service; trust may be nil to pre-moderate
+// images of every uploader
+func NewImageService(db *gorm.DB, spool *storage.Spool, uploader storage.Uploader, notifications *NotificationService, retry UploadRetryConfig, screening ImageScreening, trust *TrustService) *ImageService {
+	return &ImageService{
+		db:            db,
+		spool:         spool,
+		uploader:      uploader,
+		notifications: notifications,
+		retry:         retry,
+		screening:     screening,
+		trust:         trust,
+		wake:          make(chan struct{}, 1),
+	}
+}
+
+// Upload spools an image for an existing activity and queues it for upload. The
+// returned image is processing; its submitter is notified when it is ready.
+func (s *ImageService) Upload(ctx context.Context, activityID, userID uint, caption string, file io.Reader) (*models.Image, error) {
+	if err := s.db.WithContext(ctx).Select("id").First(&models.Activity{}, activityID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load activity %d: %w", activityID, err)
+	}
+
+	name, err := s.spool.Put(file)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	image := &models.Image{
+		ActivityID:   activityID,
+		UserID:       userID,
+		Caption:      caption,
+		Status:       models.ImageProcessing,
+		SpoolName:    name,
+		NextUploadAt: &now,
+	}
+	if err := s.db.WithContext(ctx).Create(image).Error; err != nil {
+		if removeErr := s.spool.Remove(name); removeErr != nil {
+			log.Printf("[IMAGES] Failed to remove spool file %s: %v", name, removeErr)
+		}
+		return nil, fmt.Errorf("failed to create image: %w", err)
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return image, nil
+}
+
+// UploadPending uploads up to batchSize images whose next attempt is due and
+// returns how many were attempted. Each image is leased before its upload so
+// replicas sharing the spool do not upload it twice.
+func (s *ImageService) UploadPending(ctx context.Context, batchSize int) (int, error) {
+	var due []models.Image
+	if err := s.db.WithContext(ctx).
+		Where("status = ? AND next_upload_at <= ?", models.ImageProcessing, time.Now()).
+		Order("next_upload_at, id").
+		Limit(batchSize).
+		Find(&due).Error; err != nil {
+		return 0, fmt.Errorf("failed to load pending uploads: %w", err)
+	}
+
+	attempted := 0
+	for i := range due {
+		image := &due[i]
+		result := s.db.WithContext(ctx).Model(&models.Image{}).
+			Where("id = ? AND status = ? AND next_upload_at = ?", image.ID, models.ImageProcessing, image.NextUploadAt).
+			Update("next_upload_at", time.Now().Add(imageUploadLease))
+		if result.Error != nil {
+			return attempted, fmt.Errorf("failed to lease upload of image %d: %w", image.ID, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		attempted++
+		if err := s.upload(ctx, image); err != nil {
+			return attempted, err
+		}
+	}
+	return attempted, nil
+}
+
+// StartUploads uploads spooled images every interval and whenever an image is
+// accepted, until ctx is cancelled
+func (s *ImageService) StartUploads(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+
+		for {
+			attempted, err := s.UploadPending(ctx, imageUploadBatchSize)
+			if err != nil {
+				log.Printf("[IMAGES] Upload failed: %v", err)
+			}
+			if attempted < imageUploadBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// upload attempts one upload of a leased image and records the outcome. Images
+// are screened before their first attempt. Only failures to store the outcome
+// are returned.
+func (s *ImageService) upload(ctx context.Context, image *models.Image) error {
+	if s.screening.Screener != nil && image.ScreeningStatus == "" {
+		if err := s.screen(ctx, image); err != nil {
+			return err
+		}
+		if image.Status == models.ImageRejected {
+			return s.finish(ctx, image)
+		}
+	}
+
+	url, err := s.uploadFile(ctx, image)
+	image.UploadAttempts++
+
+	updates := map[string]interface{}{"upload_attempts": image.UploadAttempts}
+	switch {
+	case err == nil:
+		image.Status, image.URL, image.UploadError = models.ImageReady, url, ""
+		updates["url"] = url
+		updates["next_upload_at"] = nil
+		if s.autoApprove(ctx, image) {
+			now := time.Now()
+			image.Approved, image.AutoApproved, image.ModeratedAt = true, true, &now
+			updates["approved"] = true
+			updates["auto_approved"] = true
+			updates["moderated_at"] = now
+		}
+	case errors.Is(err, storage.ErrRejected) || errors.Is(err, os.ErrNotExist) || image.UploadAttempts >= s.retry.MaxAttempts:
+		image.Status, image.UploadError = models.ImageFailed, uploadErrorMessage(err)
+		updates["next_upload_at"] = nil
+	default:
+		image.UploadError = uploadErrorMessage(err)
+		updates["next_upload_at"] = time.Now().Add(s.retryDelay(image.UploadAttempts))
+	}
+	updates["status"] = image.Status
+	updates["upload_error"] = image.UploadError
+	if image.Status != models.ImageProcessing {
+		updates["spool_name"] = ""
+	}
+
+	if err := s.db.WithContext(ctx).Model(&models.Image{}).Where("id = ?", image.ID).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to store upload result of image %d: %w", image.ID, err)
+	}
+
+	if image.Status == models.ImageProcessing {
+		log.Printf("[IMAGES] Image %d: upload attempt %d failed, retrying: %v", image.ID, image.UploadAttempts, err)
+		return nil
+	}
+	if image.Status == models.ImageFailed {
+		log.Printf("[IMAGES] Image %d: upload failed after %d attempts: %v", image.ID, image.UploadAttempts, err)
+	}
+	return s.finish(ctx, image)
+}
+
+// autoApprove reports whether an uploaded image is published without a
+// moderator: images screened clear with auto-approval enabled, and images of
+// trusted uploaders that screening did not send to review. Failing trust
+// lookups leave the image to moderators.
+func (s *ImageService) autoApprove(ctx context.Context, image *models.Image) bool {
+	if image.ScreeningStatus == moderation.StatusClear && s.screening.AutoApprove {
+		return true
+	}
+	if image.ScreeningStatus == moderation.StatusReview {
+		return false
+	}
+	trusted, err := s.trust.SkipsModeration(ctx, image.UserID)
+	if err != nil {
+		log.Printf("[IMAGES] Image %d: %v", image.ID, err)
+	}
+	return trusted
+}
+
+// finish removes the spooled file of an image that is no longer processing and
+// notifies its uploader of the outcome
+func (s *ImageService) finish(ctx context.Context, image *models.Image) error {
+	if err := s.spool.Remove(image.SpoolName); err != nil {
+		log.Printf("[IMAGES] Image %d: failed to remove spool file: %v", image.ID, err)
+	}
+
+	// The outcome is stored; a failed notification does not undo it
+	if image.UserID != 0 {
+		if _, err := s.notifications.Notify(ctx, []uint{image.UserID}, imageNotification(*image)); err != nil {
+			log.Printf("[ERROR] Notify upload of image %d: %v", image.ID, err)
+		}
+	}
+	return nil
+}
+
+// screen screens the spooled file of an image and stores the result. Flagged
+// images are rejected; images that cannot be screened are left to moderators.
+// Only failures to store the result are returned.
+func (s *ImageService) screen(ctx context.Context, image *models.Image) error {
+	result, err := s.screenFile(ctx, image)
+	updates := map[string]interface{}{}
+	if err != nil {
+		log.Printf("[IMAGES] Image %d: screening failed, leaving it to moderators: %v", image.ID, err)
+		image.ScreeningStatus = moderation.StatusReview
+	} else {
+		score := result.Score()
+		image.ScreeningStatus = s.screening.Thresholds.Status(score)
+		image.ScreeningScore = &score
+		image.ScreeningLabels = screeningLabels(result.Labels(s.screening.Thresholds.Review))
+		updates["screening_score"] = score
+		updates["screening_labels"] = image.ScreeningLabels
+	}
+	updates["screening_status"] = image.ScreeningStatus
+
+	if image.ScreeningStatus == moderation.StatusFlagged {
+		now := time.Now()
+		image.Status, image.Approved, image.ModeratedAt = models.ImageRejected, false, &now
+		image.RejectionReason = "Automatically rejected by content screening"
+		if image.ScreeningLabels != "" {
+			image.RejectionReason += " (" + image.ScreeningLabels + ")"
+		}
+		updates["status"] = image.Status
+		updates["moderated_at"] = now
+		updates["rejection_reason"] = image.RejectionReason
+		updates["spool_name"] = ""
+		updates["next_upload_at"] = nil
+		log.Printf("[IMAGES] Image %d: rejected by screening with score %.2f (%s)", image.ID, *image.ScreeningScore, image.ScreeningLabels)
+	}
+
+	if err := s.db.WithContext(ctx).Model(&models.Image{}).Where("id = ?", image.ID).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to store screening result of image %d: %w", image.ID, err)
+	}
+	return nil
+}
+
+// screenFile sends the spooled file of an image to the screener
+func (s *ImageService) screenFile(ctx context.Context, image *models.Image) (*moderation.Result, error) {
+	file, err := s.spool.Open(image.SpoolName)
+	if err != nil {
+		return nil, fmt.Errorf("spooled file is unavailable: %w", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spooled file: %w", err)
+	}
+	return s.screening.Screener.ScreenImage(ctx, http.DetectContentType(data), data)
+}
+
+// uploadFile sends the spooled file of an image to the image host
+func (s *ImageService) uploadFile(ctx context.Context, image *models.Image) (string, error) {
+	file, err := s.spool.Open(image.SpoolName)
+	if err != nil {
+		return "", fmt.Errorf("spooled file is unavailable: %w", err)
+	}
+	defer file.Close()
+
+	return s.uploader.Upload(ctx, fmt.Sprintf("activity-%d-image-%d", image.ActivityID, image.ID), file)
+}
+
+// retryDelay returns the wait before the next attempt after attempts failures
+func (s *ImageService) retryDelay(attempts int) time.Duration {
+	delay := s.retry.BaseDelay
+	for i := 1; i < attempts && delay < s.retry.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > s.retry.MaxDelay {
+		delay = s.retry.MaxDelay
+	}
+	return delay
+}
+
+// uploadErrorMessage returns err as stored on the image, cut to the column size
+func uploadErrorMessage(err error) string {
+	message := []rune(err.Error())
+	if len(message) > 500 {
+		message = message[:500]
+	}
+	return string(message)
+}
+
+// screeningLabels joins screening categories as stored on the image, leaving
+// out those that do not fit the column
+func screeningLabels(labels []string) string {
+	joined := ""
+	for _, label := range labels {
+		if len(joined)+len(label)+1 > 255 {
+			break
+		}
+		if joined != "" {
+			joined += ","
+		}
+		joined += label
+	}
+	return joined
+}
+
+// imageNotification tells a submitter that their image finished processing
+func imageNotification(image models.Image) models.Notification {
+	activityID := image.ActivityID
+	notification := models.Notification{
+		Kind:       models.NotificationImageReady,
+		Title:      "Your image was uploaded",
+		Body:       "Your image is now awaiting review by a moderator.",
+		ActivityID: &activityID,
+	}
+	switch {
+	case image.Status == models.ImageFailed:
+		notification.Kind = models.NotificationImageFailed
+		notification.Title = "Your image could not be uploaded"
+		notification.Body = "Please try uploading the image again."
+	case image.Status == models.ImageRejected:
+		notification.Kind = models.NotificationImageRejected
+		notification.Title = "Your image was rejected"
+		notification.Body = "Automatic screening found the image inappropriate for the community."
+	case image.Approved:
+		notification.Title = "Your image was published"
+		notification.Body = "Your image is now visible on the activity."
+	}
+	notification.DedupeKey = fmt.Sprintf("%s:%d", notification.Kind, image.ID)
+	return notification
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/agui"
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxItineraryStops is the most activities an itinerary can hold
+	MaxItineraryStops = 10
+	// itineraryRenderBatchSize is how many pending itineraries are rendered per query
+	itineraryRenderBatchSize = 10
+	// itineraryPollInterval is how often Wait checks whether rendering finished
+	itineraryPollInterval = 250 * time.Millisecond
+	// itineraryToolWait is how long the chat tool waits for the PDF before
+	// handing the client a link to poll instead
+	itineraryToolWait = 15 * time.Second
+)
+
+// ErrInvalidItinerary is returned for itineraries without stops, with too many
+// stops, or with activities that do not exist or are not approved
+var ErrInvalidItinerary = apperr.New(apperr.Invalid, fmt.Sprintf("itinerary needs 1 to %d approved activities", MaxItineraryStops))
+
+// ItineraryService renders selected activities into printable PDF itineraries in
+// the background
+type ItineraryService struct {
+	db      *gorm.DB
+	routes  *RouteService
+	baseURL string
+	// wake nudges the render loop when an itinerary is requested
+	wake chan struct{}
+}
+
+// NewItineraryService creates a new itinerary service. Download links are
+// absolute when baseURL, the public URL of this API, is set.
+func NewItineraryService(db *gorm.DB, routes *RouteService, baseURL string) *ItineraryService {
+	return &ItineraryService{
+		db:      db,
+		routes:  routes,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Create queues an itinerary of the given activities, in visiting order
+func (s *ItineraryService) Create(ctx context.Context, userID *uint, title string, activityIDs []uint) (*models.Itinerary, error) {
+	ids := make(models.UintList, 0, len(activityIDs))
+	seen := make(map[uint]bool, len(activityIDs))
+	for _, id := range activityIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 || len(ids) > MaxItineraryStops {
+		return nil, ErrInvalidItinerary
+	}
+
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.Activity{}).
+		Where("id IN ? AND approved = ?", []uint(ids), true).
+		Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("failed to check itinerary activities: %w", err)
+	}
+	if count != int64(len(ids)) {
+		return nil, ErrInvalidItinerary
+	}
+
+	if title = strings.TrimSpace(title); title == "" {
+		title = "Your itinerary"
+	}
+	itinerary := &models.Itinerary{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Title:       title,
+		ActivityIDs: ids,
+		Status:      models.ItineraryPending,
+	}
+	if err := s.db.WithContext(ctx).Create(itinerary).Error; err != nil {
+		return nil, fmt.Errorf("failed to create itinerary: %w", err)
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return itinerary, nil
+}
+
+// Get returns an itinerary without its PDF
+func (s *ItineraryService) Get(ctx context.Context, id string) (*models.Itinerary, error) {
+	var itinerary models.Itinerary
+	if err := s.db.WithContext(ctx).Omit("pdf").Where("id = ?", id).First(&itinerary).Error; err != nil {
+		return nil, fmt.Errorf("failed to load itinerary %s: %w", id, err)
+	}
+	s.setDownloadURL(&itinerary)
+	return &itinerary, nil
+}
+
+// PDF returns a rendered itinerary with its PDF; gorm.ErrRecordNotFound until it is ready
+func (s *ItineraryService) PDF(ctx context.Context, id string) (*models.Itinerary, error) {
+	var itinerary models.Itinerary
+	if err := s.db.WithContext(ctx).
+		Where("id = ? AND status = ?", id, models.ItineraryReady).
+		First(&itinerary).Error; err != nil {
+		return nil, fmt.Errorf("failed to load itinerary %s: %w", id, err)
+	}
+	return &itinerary, nil
+}
+
+// Wait returns the itinerary once it is no longer pending, or the pending
+// itinerary when ctx ends first
+func (s *ItineraryService) Wait(ctx context.Context, id string) (*models.Itinerary, error) {
+	ticker := time.NewTicker(itineraryPollInterval)
+	defer ticker.Stop()
+
+	for {
+		// The last check uses a fresh context so a timed out wait still reports the state
+		itinerary, err := s.Get(context.WithoutCancel(ctx), id)
+		if err != nil || itinerary.Status != models.ItineraryPending {
+			return itinerary, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return itinerary, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// RenderPending renders up to batchSize pending itineraries and returns how many
+// were processed. Rendering is idempotent, so replicas may run it concurrently.
+func (s *ItineraryService) RenderPending(ctx context.Context, batchSize int) (int, error) {
+	var pending []models.Itinerary
+	if err := s.db.WithContext(ctx).
+		Where("status = ?", models.ItineraryPending).
+		Order("created_at").
+		Limit(batchSize).
+		Find(&pending).Error; err != nil {
+		return 0, fmt.Errorf("failed to load pending itineraries: %w", err)
+	}
+
+	for i := range pending {
+		itinerary := &pending[i]
+		updates := map[string]interface{}{"rendered_at": time.Now()}
+		document, err := s.render(ctx, itinerary)
+		if err != nil {
+			if errors.Is(err, context.Canceled) {
+				return i, err
+			}
+			log.Printf("[ITINERARY] Itinerary %s: rendering failed: %v", itinerary.ID, err)
+			updates["status"] = models.ItineraryFailed
+			updates["error"] = "the itinerary could not be rendered"
+		} else {
+			updates["status"] = models.ItineraryReady
+			updates["pdf"] = document
+		}
+
+		if err := s.db.WithContext(ctx).Model(&models.Itinerary{}).
+			Where("id = ? AND status = ?", itinerary.ID, models.ItineraryPending).
+			Updates(updates).Error; err != nil {
+			return i, fmt.Errorf("failed to store itinerary %s: %w", itinerary.ID, err)
+		}
+	}
+	return len(pending), nil
+}
+
+// StartRendering renders pending itineraries every interval and whenever one is
+// requested, until ctx is cancelled
+func (s *ItineraryService) StartRendering(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+
+		for {
+			rendered, err := s.RenderPending(ctx, itineraryRenderBatchSize)
+			if err != nil {
+				log.Printf("[ITINERARY] Rendering failed: %v", err)
+			}
+			if rendered < itineraryRenderBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// Tool returns the create_itinerary chat tool. The download link reaches the
+// client as an ITINERARY_READY event rather than through the model's answer.
+func (s *ItineraryService) Tool() chat.Tool {
+	return chat.Tool{
+		Name: "create_itinerary",
+		Description: "Create a printable PDF itinerary of activities the user selected, in visiting order, with maps, opening hours and directions. " +
+			"The download link is shown to the user automatically; tell them it is ready instead of repeating it.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"activity_ids": {"type": "array", "items": {"type": "integer"}, "minItems": 1, "maxItems": 10},
+				"title": {"type": "string", "description": "Short title, e.g. Weekend in the hills"}
+			},
+			"required": ["activity_ids"]
+		}`),
+		Call: s.createItinerary,
+	}
+}
+
+// createItinerary implements the create_itinerary tool
+func (s *ItineraryService) createItinerary(ctx context.Context, run *chat.Run, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		ActivityIDs []uint `json:"activity_ids"`
+		Title       string `json:"title"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, err)
+	}
+
+	var userID *uint
+	if run.UserID != 0 {
+		id := run.UserID
+		userID = &id
+	}
+	itinerary, err := s.Create(ctx, userID, args.Title, args.ActivityIDs)
+	if errors.Is(err, ErrInvalidItinerary) {
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, itineraryToolWait)
+	defer cancel()
+	if itinerary, err = s.Wait(waitCtx, itinerary.ID); err != nil {
+		return nil, err
+	}
+
+	data := agui.ItineraryData{
+		ItineraryID: itinerary.ID,
+		Status:      itinerary.Status,
+		DownloadURL: itinerary.DownloadURL,
+		StatusURL:   s.url(itinerary.ID, ""),
+		Error:       itinerary.Error,
+	}
+	if err := run.Emit(agui.CreateItineraryEvent(data)); err != nil {
+		return nil, err
+	}
+	return map[string]string{"itinerary_id": itinerary.ID, "status": itinerary.Status}, nil
+}
+
+// setDownloadURL fills in the download link of ready itineraries
+func (s *ItineraryService) setDownloadURL(itinerary *models.Itinerary) {
+	if itinerary.Status == models.ItineraryReady {
+		itinerary.DownloadURL = s.url(itinerary.ID, "/pdf")
+	}
+}
+
+// url returns the API link of an itinerary resource
+func (s *ItineraryService) url(id, suffix string) string {
+	return s.baseURL + "/api/v1/itineraries/" + id + suffix
+}
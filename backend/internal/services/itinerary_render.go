@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"community-chatbot/internal/geo"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/pdf"
+)
+
+// Itinerary page layout in points
+const (
+	itineraryMargin     = 48.0
+	itineraryBottom     = pdf.PageHeight - 56
+	itineraryThumbWidth = 130.0
+	itineraryThumbGap   = 16.0
+	itineraryMaxRoutes  = 3
+	itineraryMaxLines   = 8
+)
+
+// itineraryStop is an activity of an itinerary with what its page section shows
+type itineraryStop struct {
+	activity models.Activity
+	track    *geo.Track
+}
+
+// render lays out the itinerary: an overview map of the stops followed by a
+// section per stop with its details, routes and directions to the next stop
+func (s *ItineraryService) render(ctx context.Context, itinerary *models.Itinerary) ([]byte, error) {
+	var activities []models.Activity
+	if err := s.db.WithContext(ctx).
+		Preload("Routes").
+		Where("id IN ? AND approved = ?", []uint(itinerary.ActivityIDs), true).
+		Find(&activities).Error; err != nil {
+		return nil, fmt.Errorf("failed to load itinerary activities: %w", err)
+	}
+	byID := make(map[uint]models.Activity, len(activities))
+	for _, activity := range activities {
+		byID[activity.ID] = activity
+	}
+
+	// Activities removed since the request are left out
+	var stops []itineraryStop
+	for _, id := range itinerary.ActivityIDs {
+		activity, ok := byID[id]
+		if !ok {
+			continue
+		}
+		stop := itineraryStop{activity: activity}
+		if len(activity.Routes) > 0 {
+			if track, err := s.routes.LoadTrack(&activity.Routes[0]); err == nil && len(track.Points) > 1 {
+				stop.track = track
+			}
+		}
+		stops = append(stops, stop)
+	}
+	if len(stops) == 0 {
+		return nil, fmt.Errorf("none of the itinerary's activities are available")
+	}
+
+	document := pdf.New(itinerary.Title)
+	page := document.AddPage()
+	page.Text(itineraryMargin, 72, 22, true, itinerary.Title)
+	page.Color(0.4, 0.4, 0.4)
+	page.Text(itineraryMargin, 92, 10, false, fmt.Sprintf("%d stops · created %s", len(stops), itinerary.CreatedAt.Format("2 January 2006")))
+	page.Color(0, 0, 0)
+
+	mapWidth := pdf.PageWidth - 2*itineraryMargin
+	drawStopsMap(page, stops, itineraryMargin, 108, mapWidth, 220)
+	y := 108 + 220 + 32.0
+
+	for i, stop := range stops {
+		var next *models.Activity
+		if i+1 < len(stops) {
+			next = &stops[i+1].activity
+		}
+		lines := stopLines(stop.activity, next)
+
+		height := 22 + float64(len(lines))*14
+		if stop.track != nil {
+			height = math.Max(height, 22+itineraryThumbWidth*0.75)
+		}
+		if y+height > itineraryBottom {
+			page = document.AddPage()
+			y = 72
+		}
+
+		page.Text(itineraryMargin, y, 14, true, fmt.Sprintf("%d. %s", i+1, stop.activity.Name))
+		textY := y + 20
+		for _, line := range lines {
+			page.Text(itineraryMargin, textY, 10, line.bold, line.text)
+			textY += 14
+		}
+		if stop.track != nil {
+			thumbX := pdf.PageWidth - itineraryMargin - itineraryThumbWidth
+			drawTrack(page, stop.track, thumbX, y+8, itineraryThumbWidth, itineraryThumbWidth*0.75)
+		}
+
+		y += height + 18
+		if i+1 < len(stops) && y < itineraryBottom {
+			page.Color(0.8, 0.8, 0.8)
+			page.Line(itineraryMargin, y-10, pdf.PageWidth-itineraryMargin, y-10, 0.5)
+			page.Color(0, 0, 0)
+		}
+	}
+
+	page.Color(0.4, 0.4, 0.4)
+	page.Text(itineraryMargin, pdf.PageHeight-32, 8, false,
+		"Check conditions and opening hours before you go. Directions are straight-line estimates.")
+	return document.Bytes(), nil
+}
+
+// itineraryLine is a line of text in a stop's section
+type itineraryLine struct {
+	text string
+	bold bool
+}
+
+// stopLines returns the text of a stop's section, wrapped to the space left of
+// the route thumbnail
+func stopLines(activity models.Activity, next *models.Activity) []itineraryLine {
+	width := pdf.PageWidth - 2*itineraryMargin - itineraryThumbWidth - itineraryThumbGap
+	var lines []itineraryLine
+	add := func(text string, bold bool) {
+		for _, line := range pdf.Wrap(text, 10, width, bold) {
+			lines = append(lines, itineraryLine{text: line, bold: bold})
+		}
+	}
+
+	var facts []string
+	for _, fact := range []string{activity.Category, activity.Difficulty} {
+		if fact != "" {
+			facts = append(facts, fact)
+		}
+	}
+	if activity.Duration > 0 {
+		facts = append(facts, formatMinutes(activity.Duration))
+	}
+	if activity.BestSeason != "" {
+		facts = append(facts, "best in "+activity.BestSeason)
+	}
+	if len(facts) > 0 {
+		add(strings.Join(facts, " · "), false)
+	}
+
+	hours := activity.OpeningHours
+	if hours == "" {
+		hours = "not listed"
+	}
+	add("Opening hours: "+hours, false)
+	add(fmt.Sprintf("Location: %.5f, %.5f", activity.Latitude, activity.Longitude), false)
+
+	if activity.Description != "" {
+		description := pdf.Wrap(activity.Description, 10, width, false)
+		if len(description) > itineraryMaxLines {
+			description = description[:itineraryMaxLines]
+			description[itineraryMaxLines-1] += "…"
+		}
+		for _, line := range description {
+			lines = append(lines, itineraryLine{text: line})
+		}
+	}
+
+	for i, route := range activity.Routes {
+		if i == itineraryMaxRoutes {
+			break
+		}
+		name := route.Name
+		if name == "" {
+			name = fmt.Sprintf("%d", i+1)
+		}
+		text := fmt.Sprintf("Route %s: %.1f km, %d m climb", name, route.DistanceKM, route.ElevationGainM)
+		if route.Difficulty != "" {
+			text += ", " + route.Difficulty
+		}
+		add(text, false)
+	}
+
+	if next != nil {
+		distance := geo.DistanceKM(activity.Latitude, activity.Longitude, next.Latitude, next.Longitude)
+		bearing := geo.BearingDegrees(activity.Latitude, activity.Longitude, next.Latitude, next.Longitude)
+		add(fmt.Sprintf("Next: %s, %.1f km %s", next.Name, distance, compassDirection(bearing)), true)
+		add(fmt.Sprintf("Directions: https://www.google.com/maps/dir/?api=1&origin=%.5f,%.5f&destination=%.5f,%.5f",
+			activity.Latitude, activity.Longitude, next.Latitude, next.Longitude), false)
+	}
+	return lines
+}
+
+// drawStopsMap draws the stops as numbered markers connected in visiting order
+func drawStopsMap(page *pdf.Page, stops []itineraryStop, x, y, w, h float64) {
+	points := make([]geo.Point, len(stops))
+	for i, stop := range stops {
+		points[i] = geo.Point{Lat: stop.activity.Latitude, Lng: stop.activity.Longitude}
+	}
+	project := projection(points, x, y, w, h, 24)
+
+	page.Color(0.96, 0.97, 0.95)
+	page.FillRect(x, y, w, h)
+	page.Color(0.6, 0.6, 0.6)
+	page.Rect(x, y, w, h, 0.5)
+
+	path := make([][2]float64, len(points))
+	for i, point := range points {
+		path[i] = project(point)
+	}
+	page.Color(0.2, 0.45, 0.8)
+	page.Polyline(path, 1.5)
+	for i, point := range path {
+		page.Color(0.2, 0.45, 0.8)
+		page.FillRect(point[0]-7, point[1]-7, 14, 14)
+		page.Color(1, 1, 1)
+		label := fmt.Sprintf("%d", i+1)
+		page.Text(point[0]-pdf.TextWidth(label, 8, true)/2, point[1]+3, 8, true, label)
+	}
+	page.Color(0, 0, 0)
+}
+
+// drawTrack draws a route track as a thumbnail
+func drawTrack(page *pdf.Page, track *geo.Track, x, y, w, h float64) {
+	project := projection(track.Points, x, y, w, h, 8)
+
+	page.Color(0.96, 0.97, 0.95)
+	page.FillRect(x, y, w, h)
+	page.Color(0.6, 0.6, 0.6)
+	page.Rect(x, y, w, h, 0.5)
+
+	// Long tracks are thinned; a thumbnail cannot show more detail
+	step := len(track.Points)/500 + 1
+	var path [][2]float64
+	for i := 0; i < len(track.Points); i += step {
+		path = append(path, project(track.Points[i]))
+	}
+	path = append(path, project(track.Points[len(track.Points)-1]))
+	page.Color(0.85, 0.3, 0.2)
+	page.Polyline(path, 1.2)
+	page.Color(0, 0, 0)
+}
+
+// projection returns a function placing points in the box, keeping the aspect
+// ratio of an equirectangular projection at the points' latitude
+func projection(points []geo.Point, x, y, w, h, padding float64) func(geo.Point) [2]float64 {
+	minLat, maxLat := points[0].Lat, points[0].Lat
+	minLng, maxLng := points[0].Lng, points[0].Lng
+	for _, point := range points[1:] {
+		minLat, maxLat = math.Min(minLat, point.Lat), math.Max(maxLat, point.Lat)
+		minLng, maxLng = math.Min(minLng, point.Lng), math.Max(maxLng, point.Lng)
+	}
+
+	lngScale := math.Cos((minLat + maxLat) / 2 * math.Pi / 180)
+	spanX := math.Max((maxLng-minLng)*lngScale, 1e-6)
+	spanY := math.Max(maxLat-minLat, 1e-6)
+	scale := math.Min((w-2*padding)/spanX, (h-2*padding)/spanY)
+	offsetX := x + (w-spanX*scale)/2
+	offsetY := y + (h-spanY*scale)/2
+
+	return func(point geo.Point) [2]float64 {
+		return [2]float64{
+			offsetX + (point.Lng-minLng)*lngScale*scale,
+			offsetY + (maxLat-point.Lat)*scale,
+		}
+	}
+}
+
+// compassDirection names a bearing such as "north-east"
+func compassDirection(bearing float64) string {
+	directions := []string{"north", "north-east", "east", "south-east", "south", "south-west", "west", "north-west"}
+	return directions[int(math.Round(bearing/45))%8]
+}
+
+// formatMinutes renders a duration such as "2 h 30 min"
+func formatMinutes(minutes int) string {
+	switch {
+	case minutes < 60:
+		return fmt.Sprintf("%d min", minutes)
+	case minutes%60 == 0:
+		return fmt.Sprintf("%d h", minutes/60)
+	default:
+		return fmt.Sprintf("%d h %d min", minutes/60, minutes%60)
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// searchedAreasWindow is how far back searches count toward SearchedAreas
+const searchedAreasWindow = 30 * 24 * time.Hour
+
+// LocationHistoryService records coarse search locations of users who opted in
+type LocationHistoryService struct {
+	db *gorm.DB
+}
+
+// NewLocationHistoryService creates a new location history service
+func NewLocationHistoryService(db *gorm.DB) *LocationHistoryService {
+	return &LocationHistoryService{
+		db: db,
+	}
+}
+
+// Record counts a search from loc if the user opted in to location history and
+// consents to inferred preferences. It reports whether anything was recorded.
+func (s *LocationHistoryService) Record(ctx context.Context, userID uint, loc models.Location) (bool, error) {
+	if granted, err := NewConsentService(s.db).Granted(ctx, userID, models.ConsentInferredPreferences); err != nil || !granted {
+		return false, err
+	}
+	var consented bool
+	if err := s.db.WithContext(ctx).Model(&models.UserPreferences{}).
+		Where("user_id = ?", userID).
+		Select("location_history").
+		Scan(&consented).Error; err != nil {
+		return false, fmt.Errorf("failed to check location history consent of user %d: %w", userID, err)
+	}
+	if !consented {
+		return false, nil
+	}
+
+	now := time.Now()
+	area := models.CoarseLocation(loc)
+	entry := models.LocationHistory{
+		UserID:      userID,
+		Lat:         area.Lat,
+		Lng:         area.Lng,
+		Searches:    1,
+		FirstSeenAt: now,
+		LastSeenAt:  now,
+	}
+	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "lat"}, {Name: "lng"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"searches":     gorm.Expr("location_history.searches + 1"),
+			"last_seen_at": now,
+		}),
+	}).Create(&entry).Error; err != nil {
+		return false, fmt.Errorf("failed to record location history of user %d: %w", userID, err)
+	}
+	return true, nil
+}
+
+// FrequentAreas returns the user's most searched areas, most frequent first
+func (s *LocationHistoryService) FrequentAreas(ctx context.Context, userID uint, limit int) ([]models.LocationHistory, error) {
+	var areas []models.LocationHistory
+	if err := s.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("searches DESC, last_seen_at DESC").
+		Limit(limit).
+		Find(&areas).Error; err != nil {
+		return nil, fmt.Errorf("failed to load location history of user %d: %w", userID, err)
+	}
+	return areas, nil
+}
+
+// SearchedAreas returns the areas users searched from most often over the last
+// searchedAreasWindow, summed over all users who opted in, so caches of
+// location data can be warmed for them
+func (s *LocationHistoryService) SearchedAreas(ctx context.Context, limit int) ([]models.Location, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+	var areas []models.Location
+	if err := s.db.WithContext(ctx).Model(&models.LocationHistory{}).
+		Select("lat, lng").
+		Where("last_seen_at >= ?", time.Now().Add(-searchedAreasWindow)).
+		Group("lat, lng").
+		Order("SUM(searches) DESC, lat, lng").
+		Limit(limit).
+		Scan(&areas).Error; err != nil {
+		return nil, fmt.Errorf("failed to load searched areas: %w", err)
+	}
+	return areas, nil
+}
+
+// Purge deletes all of the user's location history and returns how many areas were removed
+func (s *LocationHistoryService) Purge(ctx context.Context, userID uint) (int64, error) {
+	result := s.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.LocationHistory{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge location history of user %d: %w", userID, result.Error)
+	}
+	return result.RowsAffected, nil
+}
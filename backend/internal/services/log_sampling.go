@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidLogSampling is returned for policies with rates outside 0-1, rule
+// paths not starting with / or a negative slow threshold
+var ErrInvalidLogSampling = apperr.New(apperr.Invalid, "rates must be between 0 and 1, rule paths must start with / and slow_threshold_ms must not be negative")
+
+// LogSampler applies log sampling policies to request logging
+type LogSampler interface {
+	Policy() models.LogSamplingPolicy
+	SetPolicy(policy models.LogSamplingPolicy)
+}
+
+// LogSamplingService lets admins change the sampling of request logs at
+// runtime. Changes are stored so every replica loads them; deleting them
+// returns to the configured defaults. Without a database, changes only apply
+// to this replica until it restarts.
+type LogSamplingService struct {
+	db       *gorm.DB
+	sampler  LogSampler
+	defaults models.LogSamplingPolicy
+}
+
+// NewLogSamplingService creates a new log sampling service applying policies
+// to sampler, which starts with the configured defaults
+func NewLogSamplingService(db *gorm.DB, sampler LogSampler) *LogSamplingService {
+	return &LogSamplingService{
+		db:       db,
+		sampler:  sampler,
+		defaults: sampler.Policy(),
+	}
+}
+
+// Get returns the policy request logging currently follows
+func (s *LogSamplingService) Get() models.LogSamplingPolicy {
+	return s.sampler.Policy()
+}
+
+// Set stores a policy and applies it at once; other replicas load it within
+// their reload interval
+func (s *LogSamplingService) Set(ctx context.Context, policy *models.LogSamplingPolicy) (*models.LogSamplingPolicy, error) {
+	if err := validateLogSampling(policy); err != nil {
+		return nil, err
+	}
+
+	policy.ID = models.LogSamplingPolicyID
+	policy.UpdatedAt = time.Now()
+	if s.db != nil {
+		if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"default_rate", "rules", "slow_threshold_ms", "updated_at"}),
+		}).Create(policy).Error; err != nil {
+			return nil, fmt.Errorf("failed to store log sampling policy: %w", err)
+		}
+	}
+	s.sampler.SetPolicy(*policy)
+	return policy, nil
+}
+
+// Reset deletes the stored policy and returns to the configured defaults
+func (s *LogSamplingService) Reset(ctx context.Context) (*models.LogSamplingPolicy, error) {
+	if s.db != nil {
+		if err := s.db.WithContext(ctx).Delete(&models.LogSamplingPolicy{}, models.LogSamplingPolicyID).Error; err != nil {
+			return nil, fmt.Errorf("failed to delete log sampling policy: %w", err)
+		}
+	}
+	s.sampler.SetPolicy(s.defaults)
+	return &s.defaults, nil
+}
+
+// Reload applies the stored policy, or the defaults when none is stored
+func (s *LogSamplingService) Reload(ctx context.Context) error {
+	var policy models.LogSamplingPolicy
+	err := s.db.WithContext(ctx).First(&policy, models.LogSamplingPolicyID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		policy = s.defaults
+	} else if err != nil {
+		return fmt.Errorf("failed to load log sampling policy: %w", err)
+	}
+	s.sampler.SetPolicy(policy)
+	return nil
+}
+
+// StartReload reloads the stored policy every interval until ctx is cancelled
+func (s *LogSamplingService) StartReload(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(ctx); err != nil {
+				log.Printf("[LOGGING] %v", err)
+			}
+		}
+	}
+}
+
+// validateLogSampling checks the rates, paths and slow threshold of a policy
+func validateLogSampling(policy *models.LogSamplingPolicy) error {
+	if policy.DefaultRate < 0 || policy.DefaultRate > 1 || policy.SlowThresholdMS < 0 {
+		return ErrInvalidLogSampling
+	}
+	for path, rate := range policy.Rules {
+		if !strings.HasPrefix(path, "/") || rate < 0 || rate > 1 {
+			return ErrInvalidLogSampling
+		}
+	}
+	return nil
+}
@@ -0,0 +1,374 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/moderation"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrAlreadyModerated is returned when a submission was already approved or rejected
+var ErrAlreadyModerated = apperr.New(apperr.Conflict, "submission was already moderated")
+
+// ErrNotAutoApproved is returned when revoking a submission that is not published
+// as auto-approved
+var ErrNotAutoApproved = apperr.New(apperr.Conflict, "submission is not auto-approved")
+
+// ModerationEmailSender emails submitters the decisions on their activities
+type ModerationEmailSender interface {
+	SendModeration(ctx context.Context, user models.User, activity models.Activity) error
+}
+
+// ModerationService approves and rejects community submissions. Activities and
+// images are pending until a moderator decides; only approved ones are public.
+// Submissions of trusted users are published as auto-approved, and moderators
+// audit and revoke them afterwards. Reviews are public at once and hidden when
+// moderators remove them. Decisions advance or demote the trust of submitters.
+type ModerationService struct {
+	db            *gorm.DB
+	activities    *ActivityService
+	notifications *NotificationService
+	emails        ModerationEmailSender
+	trust         *TrustService
+}
+
+// NewModerationService creates a new moderation service; trust may be nil to
+// not track the trust of submitters
+func NewModerationService(db *gorm.DB, activities *ActivityService, notifications *NotificationService, emails ModerationEmailSender, trust *TrustService) *ModerationService {
+	return &ModerationService{
+		db:            db,
+		activities:    activities,
+		notifications: notifications,
+		emails:        emails,
+		trust:         trust,
+	}
+}
+
+// pending restricts a query to submissions awaiting a decision
+func pending(db *gorm.DB) *gorm.DB {
+	return db.Where("approved = ? AND moderated_at IS NULL", false)
+}
+
+// PendingActivities returns a page of activities awaiting review, oldest first, and the total count
+func (s *ModerationService) PendingActivities(ctx context.Context, page, pageSize int) ([]models.Activity, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.Activity{}).Scopes(pending)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count pending activities: %w", err)
+	}
+
+	var activities []models.Activity
+	if err := query.Order("created_at, id").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&activities).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list pending activities: %w", err)
+	}
+	return activities, total, nil
+}
+
+// PendingImages returns a page of uploaded images awaiting review and the total
+// count. Images screening found borderline come first, then oldest first.
+// screeningStatus restricts the page to images with that screening status.
+func (s *ModerationService) PendingImages(ctx context.Context, screeningStatus string, page, pageSize int) ([]models.Image, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.Image{}).Scopes(pending).Where("status = ?", models.ImageReady)
+	if screeningStatus != "" {
+		query = query.Where("screening_status = ?", screeningStatus)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count pending images: %w", err)
+	}
+
+	var images []models.Image
+	if err := query.Order(clause.OrderBy{Expression: clause.Expr{
+		SQL:  "COALESCE(screening_status, '') = ? DESC, created_at, id",
+		Vars: []interface{}{moderation.StatusReview},
+	}}).
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&images).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list pending images: %w", err)
+	}
+	return images, total, nil
+}
+
+// autoApproved restricts a query to submissions published without a moderator
+func autoApproved(db *gorm.DB) *gorm.DB {
+	return db.Where("approved = ? AND auto_approved = ?", true, true)
+}
+
+// AutoApprovedActivities returns a page of published activities of trusted
+// submitters, newest first, and the total count, for moderators to audit
+func (s *ModerationService) AutoApprovedActivities(ctx context.Context, page, pageSize int) ([]models.Activity, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.Activity{}).Scopes(autoApproved)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count auto-approved activities: %w", err)
+	}
+
+	var activities []models.Activity
+	if err := query.Order("moderated_at DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&activities).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list auto-approved activities: %w", err)
+	}
+	return activities, total, nil
+}
+
+// AutoApprovedImages returns a page of images published without a moderator,
+// newest first, and the total count, for moderators to audit
+func (s *ModerationService) AutoApprovedImages(ctx context.Context, page, pageSize int) ([]models.Image, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.Image{}).Scopes(autoApproved)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count auto-approved images: %w", err)
+	}
+
+	var images []models.Image
+	if err := query.Order("moderated_at DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&images).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list auto-approved images: %w", err)
+	}
+	return images, total, nil
+}
+
+// FlaggedReviews returns a page of reviews users reported, oldest first, and the total count
+func (s *ModerationService) FlaggedReviews(ctx context.Context, page, pageSize int) ([]models.Review, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.Review{}).Where("flagged = ? AND hidden = ?", true, false)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count flagged reviews: %w", err)
+	}
+
+	var reviews []models.Review
+	if err := query.Order("updated_at, id").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&reviews).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list flagged reviews: %w", err)
+	}
+	return reviews, total, nil
+}
+
+// ModerateReview hides a review or restores it, dismissing any report either
+// way, and updates the rating of its activity. Any review can be hidden, not
+// only reported ones; hiding counts as a violation of its author.
+func (s *ModerationService) ModerateReview(ctx context.Context, id uint, hide bool) (*models.Review, error) {
+	var review models.Review
+	if err := s.db.WithContext(ctx).First(&review, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to load review %d: %w", id, err)
+	}
+	if err := s.db.WithContext(ctx).Model(&review).Updates(map[string]interface{}{
+		"hidden":      hide,
+		"flagged":     false,
+		"flag_reason": "",
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to moderate review %d: %w", id, err)
+	}
+	wasHidden := review.Hidden
+	review.Hidden, review.Flagged, review.FlagReason = hide, false, ""
+	if err := refreshActivityRating(ctx, s.db, review.ActivityID); err != nil {
+		return nil, err
+	}
+	if hide && !wasHidden {
+		s.recordViolation(ctx, review.UserID)
+	}
+	return &review, nil
+}
+
+// ModerateActivity approves or rejects a pending activity and notifies its
+// submitter. Approval also marks the activity data verified.
+func (s *ModerationService) ModerateActivity(ctx context.Context, id uint, approve bool, reason string) (*models.Activity, error) {
+	var activity models.Activity
+	if err := s.moderate(ctx, &activity, id, approve, reason); err != nil {
+		return nil, err
+	}
+	if approve {
+		if err := s.activities.MarkVerified(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	s.recordDecision(ctx, activity.UserID, approve)
+	s.notifyActivity(ctx, activity)
+	return &activity, nil
+}
+
+// ModerateImage approves or rejects a pending image. Images can only be
+// moderated once their upload completed.
+func (s *ModerationService) ModerateImage(ctx context.Context, id uint, approve bool, reason string) (*models.Image, error) {
+	var image models.Image
+	if err := s.db.WithContext(ctx).Select("id", "status").First(&image, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to load image %d: %w", id, err)
+	}
+	if image.Status != models.ImageReady {
+		return nil, ErrImageNotReady
+	}
+	if err := s.moderate(ctx, &image, id, approve, reason); err != nil {
+		return nil, err
+	}
+	s.recordDecision(ctx, image.UserID, approve)
+	return &image, nil
+}
+
+// RevokeActivity takes down an auto-approved activity with a reason shown to its
+// submitter, which counts as a violation of the submitter
+func (s *ModerationService) RevokeActivity(ctx context.Context, id uint, reason string) (*models.Activity, error) {
+	var activity models.Activity
+	if err := s.revoke(ctx, &activity, id, reason); err != nil {
+		return nil, err
+	}
+	s.activities.invalidateNearby(ctx)
+
+	s.recordViolation(ctx, activity.UserID)
+	s.notifyActivity(ctx, activity)
+	return &activity, nil
+}
+
+// RevokeImage takes down an auto-approved image, which counts as a violation of
+// its uploader
+func (s *ModerationService) RevokeImage(ctx context.Context, id uint, reason string) (*models.Image, error) {
+	var image models.Image
+	if err := s.revoke(ctx, &image, id, reason); err != nil {
+		return nil, err
+	}
+	s.recordViolation(ctx, image.UserID)
+	return &image, nil
+}
+
+// moderate records the decision on a pending activity or image. The update is
+// conditional on the submission still being pending, so concurrent moderators
+// cannot both decide.
+func (s *ModerationService) moderate(ctx context.Context, submission interface{}, id uint, approve bool, reason string) error {
+	if approve {
+		reason = ""
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(submission, id).Error; err != nil {
+			return fmt.Errorf("failed to load submission %d: %w", id, err)
+		}
+
+		result := tx.Model(submission).Scopes(pending).Updates(map[string]interface{}{
+			"approved":         approve,
+			"moderated_at":     time.Now(),
+			"rejection_reason": reason,
+		})
+		if result.Error != nil {
+			return fmt.Errorf("failed to moderate submission %d: %w", id, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrAlreadyModerated
+		}
+		if err := tx.First(submission, id).Error; err != nil {
+			return fmt.Errorf("failed to reload submission %d: %w", id, err)
+		}
+		return nil
+	})
+}
+
+// revoke rejects an auto-approved activity or image. Like moderate, the update
+// is conditional, so a submission is revoked once.
+func (s *ModerationService) revoke(ctx context.Context, submission interface{}, id uint, reason string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(submission, id).Error; err != nil {
+			return fmt.Errorf("failed to load submission %d: %w", id, err)
+		}
+
+		result := tx.Model(submission).Scopes(autoApproved).Updates(map[string]interface{}{
+			"approved":         false,
+			"auto_approved":    false,
+			"moderated_at":     time.Now(),
+			"rejection_reason": reason,
+		})
+		if result.Error != nil {
+			return fmt.Errorf("failed to revoke submission %d: %w", id, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotAutoApproved
+		}
+		if err := tx.First(submission, id).Error; err != nil {
+			return fmt.Errorf("failed to reload submission %d: %w", id, err)
+		}
+		return nil
+	})
+}
+
+// recordDecision advances the trust of a submitter after an approval and counts
+// a violation after a rejection. The decision is stored; failures are logged.
+func (s *ModerationService) recordDecision(ctx context.Context, userID uint, approve bool) {
+	if !approve {
+		s.recordViolation(ctx, userID)
+		return
+	}
+	if err := s.trust.RecordApproval(ctx, userID); err != nil {
+		log.Printf("[ERROR] Record approval of user %d: %v", userID, err)
+	}
+}
+
+// recordViolation counts a violation of a submitter, logging failures
+func (s *ModerationService) recordViolation(ctx context.Context, userID uint) {
+	if err := s.trust.RecordViolation(ctx, userID); err != nil {
+		log.Printf("[ERROR] Record violation of user %d: %v", userID, err)
+	}
+}
+
+// notifyActivity tells the submitter of an activity about a decision, in their
+// inbox and by email. The decision is stored; a failed notification does not undo it.
+func (s *ModerationService) notifyActivity(ctx context.Context, activity models.Activity) {
+	if activity.UserID == 0 {
+		return
+	}
+	notified, err := s.notifications.Notify(ctx, []uint{activity.UserID}, moderationNotification(activity))
+	if err != nil {
+		log.Printf("[ERROR] Notify moderation of activity %d: %v", activity.ID, err)
+	}
+	// Repeated decisions are deduplicated like the inbox notification
+	if notified == 0 {
+		return
+	}
+
+	var user models.User
+	if err := s.db.WithContext(ctx).First(&user, activity.UserID).Error; err != nil {
+		log.Printf("[ERROR] Load submitter of activity %d: %v", activity.ID, err)
+		return
+	}
+	if err := s.emails.SendModeration(ctx, user, activity); err != nil {
+		log.Printf("[ERROR] Email moderation of activity %d: %v", activity.ID, err)
+	}
+}
+
+// moderationNotification tells a submitter about the decision on their activity
+func moderationNotification(activity models.Activity) models.Notification {
+	activityID := activity.ID
+	notification := models.Notification{
+		Kind:       models.NotificationActivityApproved,
+		Title:      fmt.Sprintf("%s was approved", activity.Name),
+		Body:       fmt.Sprintf("%s is now visible to the community. Thanks for contributing!", activity.Name),
+		ActivityID: &activityID,
+	}
+	if !activity.Approved {
+		notification.Kind = models.NotificationActivityRejected
+		notification.Title = fmt.Sprintf("%s was not approved", activity.Name)
+		notification.Body = fmt.Sprintf("%s\n\nYou can edit the activity to submit it again.", activity.RejectionReason)
+	}
+	// Resubmitted activities can be moderated again, so the key includes the decision time
+	notification.DedupeKey = fmt.Sprintf("%s:%d:%d", notification.Kind, activity.ID, activity.ModeratedAt.Unix())
+	return notification
+}
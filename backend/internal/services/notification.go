@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PushSender delivers a notification to a user's devices
+type PushSender interface {
+	SendPush(ctx context.Context, notification models.Notification) error
+}
+
+// LogPushSender logs push notifications instead of delivering them; used until a push provider is configured
+type LogPushSender struct{}
+
+// SendPush logs the notification
+func (LogPushSender) SendPush(_ context.Context, notification models.Notification) error {
+	log.Printf("[PUSH] User %d: %s", notification.UserID, notification.Title)
+	return nil
+}
+
+// NotificationService writes notifications to user inboxes and pushes them
+type NotificationService struct {
+	db   *gorm.DB
+	push PushSender
+}
+
+// NewNotificationService creates a new notification service
+func NewNotificationService(db *gorm.DB, push PushSender) *NotificationService {
+	return &NotificationService{
+		db:   db,
+		push: push,
+	}
+}
+
+// Notify delivers the notification template to each user and returns how many
+// were notified. Users who already have a notification with the same dedupe key
+// are skipped, so callers can safely notify again for repeated events.
+func (s *NotificationService) Notify(ctx context.Context, userIDs []uint, template models.Notification) (int, error) {
+	if template.DedupeKey == "" {
+		return 0, fmt.Errorf("notification %q has no dedupe key", template.Kind)
+	}
+
+	notified := 0
+	for _, userID := range userIDs {
+		notification := template
+		notification.ID = 0
+		notification.UserID = userID
+
+		result := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&notification)
+		if result.Error != nil {
+			return notified, fmt.Errorf("failed to store notification for user %d: %w", userID, result.Error)
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		notified++
+
+		// The inbox entry is the source of truth; a failed push is not retried
+		if err := s.push.SendPush(ctx, notification); err != nil {
+			log.Printf("[PUSH] User %d: delivery failed: %v", userID, err)
+		}
+	}
+	return notified, nil
+}
+
+// List returns a page of a user's notifications, newest first, and the total count
+func (s *NotificationService) List(ctx context.Context, userID uint, unreadOnly bool, page, pageSize int) ([]models.Notification, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.Notification{}).Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count notifications: %w", err)
+	}
+
+	var notifications []models.Notification
+	if err := query.Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&notifications).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list notifications: %w", err)
+	}
+
+	return notifications, total, nil
+}
+
+// MarkRead marks one of the user's notifications as read
+func (s *NotificationService) MarkRead(ctx context.Context, userID, id uint) error {
+	result := s.db.WithContext(ctx).Model(&models.Notification{}).
+		Where("id = ? AND user_id = ?", id, userID).
+		Update("read_at", gorm.Expr("COALESCE(read_at, ?)", time.Now()))
+	if result.Error != nil {
+		return fmt.Errorf("failed to mark notification %d read: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to mark notification %d read: %w", id, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// PreferencesService manages user preferences
+type PreferencesService struct {
+	db *gorm.DB
+}
+
+// NewPreferencesService creates a new preferences service
+func NewPreferencesService(db *gorm.DB) *PreferencesService {
+	return &PreferencesService{
+		db: db,
+	}
+}
+
+// Get returns a user's preferences, creating defaults for users who have none
+func (s *PreferencesService) Get(ctx context.Context, userID uint) (*models.UserPreferences, error) {
+	prefs := models.UserPreferences{UserID: userID}
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).FirstOrCreate(&prefs).Error; err != nil {
+		return nil, fmt.Errorf("failed to load preferences of user %d: %w", userID, err)
+	}
+	return &prefs, nil
+}
+
+// Update replaces the editable preference fields. Withdrawing location history
+// consent deletes the recorded history.
+func (s *PreferencesService) Update(ctx context.Context, userID uint, changes *models.UserPreferences) (*models.UserPreferences, error) {
+	prefs, err := s.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Select forces zero values (e.g. clearing preferred activities) to be written too
+		if err := tx.Model(prefs).
+			Select("LocationLat", "LocationLng", "SearchRadiusKM", "PreferredActivities", "DifficultyLevel",
+				"TransportMode", "DigestFrequency", "DigestHour", "Timezone", "Language", "DigestContent", "LocationHistory").
+			Updates(changes).Error; err != nil {
+			return fmt.Errorf("failed to update preferences of user %d: %w", userID, err)
+		}
+
+		if !changes.LocationHistory {
+			if err := tx.Where("user_id = ?", userID).Delete(&models.LocationHistory{}).Error; err != nil {
+				return fmt.Errorf("failed to purge location history of user %d: %w", userID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// ChatContext returns the user's preferences as chat context for personalized answers.
+// With location history enabled, frequently searched areas bias recommendations and
+// stand in for the home location when none is set.
+func (s *PreferencesService) ChatContext(ctx context.Context, userID uint) (*chat.UserContext, error) {
+	prefs, err := s.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	uc := &chat.UserContext{
+		SearchRadiusKM:      prefs.SearchRadiusKM,
+		PreferredActivities: prefs.PreferredActivities,
+		DifficultyLevel:     prefs.DifficultyLevel,
+		Language:            prefs.Language,
+	}
+	if prefs.UsesPublicTransport() {
+		uc.TransportMode = models.TransportPublic
+	}
+	// UTC is also the default of users who never chose a zone, so it is not passed on
+	if prefs.Timezone != "UTC" {
+		uc.Timezone = prefs.Timezone
+	}
+	if prefs.HasValidLocation() {
+		location := prefs.GetLocation()
+		uc.Location = &location
+	}
+
+	if prefs.LocationHistory {
+		areas, err := NewLocationHistoryService(s.db).FrequentAreas(ctx, userID, 3)
+		if err != nil {
+			return nil, err
+		}
+		for _, area := range areas {
+			uc.FrequentAreas = append(uc.FrequentAreas, models.Location{Lat: area.Lat, Lng: area.Lng})
+		}
+		if uc.Location == nil && len(uc.FrequentAreas) > 0 {
+			uc.Location = &uc.FrequentAreas[0]
+		}
+	}
+	return uc, nil
+}
+
+// Frequency cap for profile questions in chat
+const (
+	// profileQuestionInterval is the minimum time between two profile questions to a user
+	profileQuestionInterval = 24 * time.Hour
+	// profileAnswerWindow is how long a reply may still answer the last question
+	profileAnswerWindow = time.Hour
+)
+
+// NextProfileQuestion returns which missing preference the chat may ask the user
+// about, at most once per profileQuestionInterval, and which question from the
+// previous answer may still be open. Asking is recorded atomically so concurrent
+// runs do not both ask. Users who revoked consent to inferred preferences are
+// not asked.
+func (s *PreferencesService) NextProfileQuestion(ctx context.Context, userID uint) (chat.ProfileQuestion, error) {
+	var question chat.ProfileQuestion
+	if granted, err := NewConsentService(s.db).Granted(ctx, userID, models.ConsentInferredPreferences); err != nil || !granted {
+		return question, err
+	}
+	prefs, err := s.Get(ctx, userID)
+	if err != nil {
+		return question, err
+	}
+
+	var missing []string
+	if prefs.DifficultyLevel == "" {
+		missing = append(missing, chat.ProfileDifficulty)
+	}
+	if len(prefs.PreferredActivities) == 0 {
+		missing = append(missing, chat.ProfileActivities)
+	}
+
+	now := time.Now()
+	if prefs.ProfileQuestionAt != nil && now.Sub(*prefs.ProfileQuestionAt) < profileAnswerWindow &&
+		slices.Contains(missing, prefs.ProfileQuestion) {
+		question.Pending = prefs.ProfileQuestion
+	}
+	if len(missing) == 0 || question.Pending != "" {
+		return question, nil
+	}
+
+	// Ask about the preference that was asked about least recently
+	ask := missing[0]
+	if len(missing) > 1 && ask == prefs.ProfileQuestion {
+		ask = missing[1]
+	}
+	result := s.db.WithContext(ctx).Model(&models.UserPreferences{}).
+		Where("user_id = ? AND (profile_question_at IS NULL OR profile_question_at < ?)", userID, now.Add(-profileQuestionInterval)).
+		Updates(map[string]interface{}{"profile_question": ask, "profile_question_at": now})
+	if result.Error != nil {
+		return question, fmt.Errorf("failed to record profile question of user %d: %w", userID, result.Error)
+	}
+	if result.RowsAffected == 1 {
+		question.Ask = ask
+	}
+	return question, nil
+}
+
+// SaveProfileAnswer stores a preference the user stated in chat, unless the
+// user revoked consent to inferred preferences
+func (s *PreferencesService) SaveProfileAnswer(ctx context.Context, userID uint, field string, value interface{}) error {
+	if granted, err := NewConsentService(s.db).Granted(ctx, userID, models.ConsentInferredPreferences); err != nil || !granted {
+		return err
+	}
+	if _, err := s.Get(ctx, userID); err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{"profile_question": ""}
+	switch field {
+	case chat.ProfileDifficulty:
+		updates["difficulty_level"] = value
+	case chat.ProfileActivities:
+		updates["preferred_activities"] = value
+	default:
+		return fmt.Errorf("unknown profile field %q", field)
+	}
+
+	if err := s.db.WithContext(ctx).Model(&models.UserPreferences{}).
+		Where("user_id = ?", userID).
+		Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to save %s of user %d: %w", field, userID, err)
+	}
+	return nil
+}
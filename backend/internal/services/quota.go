@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"community-chatbot/internal/agui"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/ratelimit"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var quotaExceeded = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "chat_quota_exceeded_total",
+	Help: "Chat messages refused because the daily quota was used up, by kind of client (guest or user).",
+}, []string{"kind"})
+
+// QuotaService enforces daily chat message quotas: a small one for anonymous
+// guests, counted by client fingerprint, and a higher one for signed-in users.
+// Quotas reset at midnight UTC.
+type QuotaService struct {
+	counter    ratelimit.Counter
+	guestLimit int
+	userLimit  int
+	signIn     bool
+}
+
+// NewQuotaService creates a new quota service. A limit of 0 disables that quota.
+// signIn reports whether guests can sign in for the user quota.
+func NewQuotaService(counter ratelimit.Counter, guestLimit, userLimit int, signIn bool) *QuotaService {
+	return &QuotaService{
+		counter:    counter,
+		guestLimit: guestLimit,
+		userLimit:  userLimit,
+		signIn:     signIn,
+	}
+}
+
+// Stage returns the pipeline stage that counts each run against its quota and
+// ends runs over quota with a QUOTA_EXCEEDED error instead of an answer. When
+// the counter fails, runs are let through rather than taking the chat down.
+func (s *QuotaService) Stage() chat.Stage {
+	return chat.StageFunc{
+		StageName: "quota",
+		Fn: func(ctx context.Context, run *chat.Run, next chat.Handler) error {
+			kind, key, limit := "guest", "quota:guest:"+run.Fingerprint, s.guestLimit
+			if run.UserID != 0 {
+				kind, key, limit = "user", fmt.Sprintf("quota:user:%d", run.UserID), s.userLimit
+			}
+			if limit == 0 || (kind == "guest" && run.Fingerprint == "") || run.DryRun {
+				return next(ctx, run)
+			}
+
+			now := time.Now().UTC()
+			resetAt := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+			count, err := s.counter.Increment(ctx, key+":"+now.Format(time.DateOnly), resetAt.Sub(now)+time.Hour)
+			if err != nil {
+				log.Printf("[QUOTA] Run %s: counter failed, allowing: %v", run.ID, err)
+				return next(ctx, run)
+			}
+			if count <= int64(limit) {
+				return next(ctx, run)
+			}
+
+			quotaExceeded.WithLabelValues(kind).Inc()
+			log.Printf("[QUOTA] Run %s: %s quota of %d messages exceeded (client %s)", run.ID, kind, limit, run.ClientIP)
+			return run.Emit(agui.CreateQuotaExceededEvent(s.exceeded(kind == "guest", limit, resetAt)))
+		},
+	}
+}
+
+// exceeded describes a used-up quota, encouraging guests to sign in
+func (s *QuotaService) exceeded(guest bool, limit int, resetAt time.Time) agui.QuotaExceededData {
+	data := agui.QuotaExceededData{
+		Message: fmt.Sprintf("You have reached your limit of %d messages for today. It resets at %s UTC.",
+			limit, resetAt.Format("15:04")),
+		Limit:   limit,
+		ResetAt: resetAt,
+	}
+	if !guest || !s.signIn {
+		return data
+	}
+
+	data.SignIn = true
+	data.UserLimit = s.userLimit
+	if s.userLimit == 0 {
+		data.Message = fmt.Sprintf("You have used your %d free messages for today. Sign in to keep chatting.", limit)
+	} else {
+		data.Message = fmt.Sprintf("You have used your %d free messages for today. Sign in to keep chatting with up to %d messages a day.",
+			limit, s.userLimit)
+	}
+	return data
+}
@@ -0,0 +1,389 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// reviewAnalysisBatchSize is how many pending reviews are analyzed per query
+const reviewAnalysisBatchSize = 50
+
+var (
+	// ErrAlreadyReviewed is returned when a user reviews an activity twice; they can edit their review instead
+	ErrAlreadyReviewed = apperr.New(apperr.Conflict, "you already reviewed this activity, edit your review instead")
+	// ErrNotReviewAuthor is returned when a user changes a review they did not write
+	ErrNotReviewAuthor = apperr.New(apperr.Forbidden, "review belongs to another user")
+)
+
+// ReviewService stores reviews and aggregates their analyzed sentiment and highlights
+type ReviewService struct {
+	db       *gorm.DB
+	analyzer ReviewAnalyzer
+	// wake nudges the analysis loop when a review is created
+	wake chan struct{}
+}
+
+// NewReviewService creates a new review service
+func NewReviewService(db *gorm.DB, analyzer ReviewAnalyzer) *ReviewService {
+	return &ReviewService{
+		db:       db,
+		analyzer: analyzer,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// visibleReviews restricts a query to reviews moderators have not hidden
+func visibleReviews(db *gorm.DB) *gorm.DB {
+	return db.Where("hidden = ?", false)
+}
+
+// Create stores a review for an existing activity, updates the activity's
+// rating and queues the review for analysis. Users review an activity once.
+func (s *ReviewService) Create(ctx context.Context, review *models.Review) error {
+	if err := s.db.WithContext(ctx).Select("id").First(&models.Activity{}, review.ActivityID).Error; err != nil {
+		return fmt.Errorf("failed to load activity %d: %w", review.ActivityID, err)
+	}
+
+	var existing int64
+	if err := s.db.WithContext(ctx).Model(&models.Review{}).
+		Where("activity_id = ? AND user_id = ?", review.ActivityID, review.UserID).
+		Count(&existing).Error; err != nil {
+		return fmt.Errorf("failed to check existing reviews: %w", err)
+	}
+	if existing > 0 {
+		return ErrAlreadyReviewed
+	}
+
+	// Analysis results and moderation are server-controlled
+	review.Sentiment = nil
+	review.Pros = nil
+	review.Cons = nil
+	review.AnalyzedAt = nil
+	review.Flagged = false
+	review.FlagReason = ""
+	review.Hidden = false
+	if err := s.db.WithContext(ctx).Create(review).Error; err != nil {
+		// A concurrent submission won the race past the check above
+		if isDuplicateKey(s.db, err) {
+			return ErrAlreadyReviewed
+		}
+		return fmt.Errorf("failed to create review: %w", err)
+	}
+	if err := refreshActivityRating(ctx, s.db, review.ActivityID); err != nil {
+		return err
+	}
+
+	s.wakeAnalysis()
+	return nil
+}
+
+// Update replaces the rating and text of a review written by userID. The
+// review is analyzed again.
+func (s *ReviewService) Update(ctx context.Context, activityID, id, userID uint, rating int, body string) (*models.Review, error) {
+	review, err := s.authored(ctx, activityID, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.WithContext(ctx).Model(review).Updates(map[string]interface{}{
+		"rating":      rating,
+		"body":        body,
+		"sentiment":   nil,
+		"pros":        nil,
+		"cons":        nil,
+		"analyzed_at": nil,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("failed to update review %d: %w", id, err)
+	}
+	if err := refreshActivityRating(ctx, s.db, activityID); err != nil {
+		return nil, err
+	}
+
+	s.wakeAnalysis()
+	if err := s.db.WithContext(ctx).First(review, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to reload review %d: %w", id, err)
+	}
+	return review, nil
+}
+
+// Delete soft-deletes a review written by userID and updates the activity's rating
+func (s *ReviewService) Delete(ctx context.Context, activityID, id, userID uint) error {
+	review, err := s.authored(ctx, activityID, id, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.WithContext(ctx).Delete(review).Error; err != nil {
+		return fmt.Errorf("failed to delete review %d: %w", id, err)
+	}
+	return refreshActivityRating(ctx, s.db, activityID)
+}
+
+// Flag reports a review to moderators, e.g. as spam or abusive
+func (s *ReviewService) Flag(ctx context.Context, activityID, id uint, reason string) error {
+	result := s.db.WithContext(ctx).Model(&models.Review{}).Scopes(visibleReviews).
+		Where("id = ? AND activity_id = ?", id, activityID).
+		Updates(map[string]interface{}{"flagged": true, "flag_reason": reason})
+	if result.Error != nil {
+		return fmt.Errorf("failed to flag review %d: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to flag review %d: %w", id, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// authored loads a review of an activity, checking it was written by userID
+func (s *ReviewService) authored(ctx context.Context, activityID, id, userID uint) (*models.Review, error) {
+	var review models.Review
+	if err := s.db.WithContext(ctx).Where("activity_id = ?", activityID).First(&review, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to load review %d: %w", id, err)
+	}
+	if review.UserID != userID {
+		return nil, ErrNotReviewAuthor
+	}
+	return &review, nil
+}
+
+// wakeAnalysis nudges the analysis loop without blocking
+func (s *ReviewService) wakeAnalysis() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// refreshActivityRating recomputes the rating average and count stored on an
+// activity from its visible reviews
+func refreshActivityRating(ctx context.Context, db *gorm.DB, activityID uint) error {
+	if err := db.WithContext(ctx).Exec(`
+		UPDATE activities SET
+			rating_average = COALESCE((SELECT ROUND(AVG(rating), 2) FROM reviews
+				WHERE activity_id = activities.id AND hidden = false AND deleted_at IS NULL), 0),
+			rating_count = (SELECT COUNT(*) FROM reviews
+				WHERE activity_id = activities.id AND hidden = false AND deleted_at IS NULL)
+		WHERE id = ?`, activityID).Error; err != nil {
+		return fmt.Errorf("failed to update rating of activity %d: %w", activityID, err)
+	}
+	return nil
+}
+
+// DedupeReviews keeps only the latest review of each user and activity in
+// databases created before reviews were unique, so that AutoMigrate can create
+// the unique index. Older duplicates are soft-deleted and the ratings of their
+// activities recomputed. It runs once, before AutoMigrate.
+func DedupeReviews(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.Review{}) || migrator.HasIndex(&models.Review{}, "idx_reviews_user_activity") {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		var activityIDs []uint
+		if err := tx.Raw(`
+			UPDATE reviews SET deleted_at = NOW()
+			FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY user_id, activity_id ORDER BY created_at DESC, id DESC) AS n
+				FROM reviews WHERE deleted_at IS NULL) ranked
+			WHERE reviews.id = ranked.id AND ranked.n > 1
+			RETURNING reviews.activity_id`).Scan(&activityIDs).Error; err != nil {
+			return fmt.Errorf("failed to remove duplicate reviews: %w", err)
+		}
+		slices.Sort(activityIDs)
+		for _, activityID := range slices.Compact(activityIDs) {
+			if err := refreshActivityRating(context.Background(), tx, activityID); err != nil {
+				return err
+			}
+		}
+		if len(activityIDs) > 0 {
+			log.Printf("[REVIEWS] Removed %d duplicate reviews", len(activityIDs))
+		}
+		return nil
+	})
+}
+
+// BackfillRatings computes the stored ratings of activities reviewed before
+// ratings were aggregated. It is idempotent and cheap once done, so it runs at
+// every startup.
+func BackfillRatings(db *gorm.DB) error {
+	if err := db.Exec(`
+		UPDATE activities SET
+			rating_average = r.average,
+			rating_count = r.count
+		FROM (SELECT activity_id, ROUND(AVG(rating), 2) AS average, COUNT(*) AS count FROM reviews
+			WHERE hidden = false AND deleted_at IS NULL GROUP BY activity_id) r
+		WHERE activities.id = r.activity_id AND activities.rating_count = 0`).Error; err != nil {
+		return fmt.Errorf("failed to backfill activity ratings: %w", err)
+	}
+	return nil
+}
+
+// List returns a page of an activity's reviews, newest first, and the total count
+func (s *ReviewService) List(ctx context.Context, activityID uint, page, pageSize int) ([]models.Review, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.Review{}).Scopes(visibleReviews).Where("activity_id = ?", activityID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count reviews: %w", err)
+	}
+
+	var reviews []models.Review
+	if err := query.Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&reviews).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list reviews: %w", err)
+	}
+
+	return reviews, total, nil
+}
+
+// Summary aggregates an activity's reviews and their most common pros and cons.
+// It returns nil when the activity has no reviews.
+func (s *ReviewService) Summary(ctx context.Context, activityID uint, limit int) (*models.ReviewSummary, error) {
+	var summary models.ReviewSummary
+	if err := s.db.WithContext(ctx).Model(&models.Review{}).
+		Select("COUNT(*) AS review_count, COALESCE(AVG(rating), 0) AS average_rating, COALESCE(AVG(sentiment), 0) AS average_sentiment").
+		Scopes(visibleReviews).
+		Where("activity_id = ?", activityID).
+		Scan(&summary).Error; err != nil {
+		return nil, fmt.Errorf("failed to summarize reviews of activity %d: %w", activityID, err)
+	}
+	if summary.ReviewCount == 0 {
+		return nil, nil
+	}
+
+	var err error
+	if summary.Pros, err = s.topHighlights(ctx, activityID, "pros", limit); err != nil {
+		return nil, err
+	}
+	if summary.Cons, err = s.topHighlights(ctx, activityID, "cons", limit); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// topHighlights counts how many reviews mention each phrase in column (pros or cons)
+func (s *ReviewService) topHighlights(ctx context.Context, activityID uint, column string, limit int) ([]models.Highlight, error) {
+	highlights := []models.Highlight{}
+	if err := s.db.WithContext(ctx).Raw(`
+		SELECT phrase, COUNT(*) AS mentions
+		FROM reviews, unnest(reviews.`+column+`) AS phrase
+		WHERE reviews.activity_id = ? AND reviews.deleted_at IS NULL AND NOT reviews.hidden
+		GROUP BY phrase
+		ORDER BY mentions DESC, phrase
+		LIMIT ?`, activityID, limit).
+		Scan(&highlights).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate review %s of activity %d: %w", column, activityID, err)
+	}
+	return highlights, nil
+}
+
+// AnalyzePending analyzes up to batchSize reviews that have not been analyzed yet
+// and returns how many were processed. Failed reviews stay pending and are retried.
+func (s *ReviewService) AnalyzePending(ctx context.Context, batchSize int) (int, error) {
+	var pending []models.Review
+	if err := s.db.WithContext(ctx).
+		Where("analyzed_at IS NULL").
+		Order("id").
+		Limit(batchSize).
+		Find(&pending).Error; err != nil {
+		return 0, fmt.Errorf("failed to load pending reviews: %w", err)
+	}
+
+	analyzed := 0
+	for _, review := range pending {
+		analysis, err := s.analyzer.Analyze(ctx, review)
+		if err != nil {
+			log.Printf("[REVIEWS] Review %d: analysis failed: %v", review.ID, err)
+			continue
+		}
+
+		if err := s.db.WithContext(ctx).Model(&review).Updates(map[string]interface{}{
+			"sentiment":   analysis.Sentiment,
+			"pros":        models.StringList(analysis.Pros),
+			"cons":        models.StringList(analysis.Cons),
+			"analyzed_at": time.Now(),
+		}).Error; err != nil {
+			return analyzed, fmt.Errorf("failed to store analysis of review %d: %w", review.ID, err)
+		}
+		analyzed++
+	}
+	return analyzed, nil
+}
+
+// StartAnalysis analyzes pending reviews every interval and whenever a review is
+// created, until ctx is cancelled. Analysis is idempotent, so replicas may run it concurrently.
+func (s *ReviewService) StartAnalysis(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+
+		for {
+			analyzed, err := s.AnalyzePending(ctx, reviewAnalysisBatchSize)
+			if err != nil {
+				log.Printf("[REVIEWS] Analysis failed: %v", err)
+			}
+			if analyzed < reviewAnalysisBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// HighlightNotes returns review highlights for activities mentioned by name in
+// message, formatted as context the chat model can quote
+func (s *ReviewService) HighlightNotes(ctx context.Context, message string) ([]string, error) {
+	var activities []models.Activity
+	if err := s.db.WithContext(ctx).
+		Where("approved = ? AND "+mentionsName, true, message).
+		Limit(3).
+		Find(&activities).Error; err != nil {
+		return nil, fmt.Errorf("failed to find mentioned activities: %w", err)
+	}
+
+	var notes []string
+	for _, activity := range activities {
+		summary, err := s.Summary(ctx, activity.ID, 3)
+		if err != nil {
+			return nil, err
+		}
+		if summary == nil || len(summary.Pros)+len(summary.Cons) == 0 {
+			continue
+		}
+
+		note := fmt.Sprintf("Reviews of %s (activity://%d, %d reviews, average %.1f/5)",
+			activity.Name, activity.ID, summary.ReviewCount, summary.AverageRating)
+		if len(summary.Pros) > 0 {
+			note += "; reviewers praise: " + formatHighlights(summary.Pros)
+		}
+		if len(summary.Cons) > 0 {
+			note += "; reviewers complain about: " + formatHighlights(summary.Cons)
+		}
+		notes = append(notes, note)
+	}
+	return notes, nil
+}
+
+// formatHighlights renders highlights as `"great views" (12), "poor signage" (3)`
+func formatHighlights(highlights []models.Highlight) string {
+	parts := make([]string, len(highlights))
+	for i, h := range highlights {
+		parts[i] = fmt.Sprintf("%q (%d)", h.Phrase, h.Mentions)
+	}
+	return strings.Join(parts, ", ")
+}
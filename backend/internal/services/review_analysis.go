@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+
+	"community-chatbot/internal/llm"
+	"community-chatbot/internal/models"
+)
+
+// maxHighlightsPerReview caps how many pros and cons are kept from a single review
+const maxHighlightsPerReview = 5
+
+// ReviewAnalysis is the sentiment and highlights extracted from one review
+type ReviewAnalysis struct {
+	Sentiment float64  `json:"sentiment"`
+	Pros      []string `json:"pros"`
+	Cons      []string `json:"cons"`
+}
+
+// ReviewAnalyzer extracts sentiment and highlights from a review
+type ReviewAnalyzer interface {
+	Analyze(ctx context.Context, review models.Review) (ReviewAnalysis, error)
+}
+
+// positiveWords and negativeWords are the lexicon used for sentiment and highlight phrases
+var positiveWords = map[string]bool{
+	"amazing": true, "awesome": true, "beautiful": true, "breathtaking": true, "clean": true,
+	"easy": true, "excellent": true, "fantastic": true, "friendly": true, "fun": true,
+	"good": true, "gorgeous": true, "great": true, "helpful": true, "lovely": true,
+	"nice": true, "peaceful": true, "quiet": true, "scenic": true, "shady": true,
+	"spacious": true, "spectacular": true, "stunning": true, "well-marked": true, "wonderful": true,
+}
+
+var negativeWords = map[string]bool{
+	"awful": true, "bad": true, "boring": true, "broken": true, "closed": true,
+	"confusing": true, "crowded": true, "dangerous": true, "dirty": true, "disappointing": true,
+	"expensive": true, "horrible": true, "limited": true, "muddy": true, "noisy": true,
+	"overgrown": true, "poor": true, "rude": true, "slippery": true, "terrible": true,
+	"unclear": true, "unsafe": true,
+}
+
+// negators flip the polarity of the following opinion word
+var negators = map[string]bool{
+	"not": true, "no": true, "never": true, "hardly": true, "barely": true,
+}
+
+// copulas link a subject to an opinion ("the signage was poor")
+var copulas = map[string]bool{
+	"is": true, "was": true, "are": true, "were": true, "seems": true, "looked": true,
+}
+
+// intensifiers may sit between a copula and an opinion word ("was really poor")
+var intensifiers = map[string]bool{
+	"very": true, "really": true, "quite": true, "so": true, "super": true, "pretty": true, "extremely": true,
+}
+
+// highlightStopwords are never used as the subject of a highlight phrase
+var highlightStopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "at": true, "but": true, "day": true, "experience": true,
+	"for": true, "i": true, "in": true, "it": true, "of": true, "on": true, "one": true, "or": true,
+	"place": true, "she": true, "he": true, "that": true, "the": true, "there": true, "they": true,
+	"thing": true, "things": true, "this": true, "time": true, "to": true, "trip": true, "we": true,
+	"with": true, "you": true,
+}
+
+// LexiconAnalyzer is a dependency-free analyzer that scores opinion words and
+// extracts "<opinion> <subject>" phrases such as "great views" or "poor signage"
+type LexiconAnalyzer struct{}
+
+// Analyze scores the review text, falling back to the star rating when the text has no opinion words
+func (LexiconAnalyzer) Analyze(_ context.Context, review models.Review) (ReviewAnalysis, error) {
+	var analysis ReviewAnalysis
+	positive, negative := 0, 0
+	seen := make(map[string]bool)
+
+	for _, sentence := range splitSentences(review.Body) {
+		words := reviewWords(sentence)
+		for i, word := range words {
+			polarity := opinionPolarity(word)
+			if polarity == 0 {
+				continue
+			}
+			negated := isNegated(words, i)
+			if negated {
+				polarity = -polarity
+			}
+			if polarity > 0 {
+				positive++
+			} else {
+				negative++
+			}
+
+			// Negated phrases ("not great views") count towards sentiment only
+			if negated {
+				continue
+			}
+			subject := highlightSubject(words, i)
+			if subject == "" {
+				continue
+			}
+			phrase := word + " " + subject
+			if seen[phrase] {
+				continue
+			}
+			seen[phrase] = true
+			if polarity > 0 && len(analysis.Pros) < maxHighlightsPerReview {
+				analysis.Pros = append(analysis.Pros, phrase)
+			} else if polarity < 0 && len(analysis.Cons) < maxHighlightsPerReview {
+				analysis.Cons = append(analysis.Cons, phrase)
+			}
+		}
+	}
+
+	if positive+negative > 0 {
+		analysis.Sentiment = float64(positive-negative) / float64(positive+negative)
+	} else if review.Rating > 0 {
+		analysis.Sentiment = float64(review.Rating-3) / 2
+	}
+	return analysis, nil
+}
+
+// opinionPolarity returns 1 for positive, -1 for negative and 0 for other words
+func opinionPolarity(word string) int {
+	switch {
+	case positiveWords[word]:
+		return 1
+	case negativeWords[word]:
+		return -1
+	}
+	return 0
+}
+
+// isNegated reports whether one of the three preceding words negates words[i]
+func isNegated(words []string, i int) bool {
+	for j := i - 1; j >= 0 && j >= i-3; j-- {
+		if negators[words[j]] || strings.HasSuffix(words[j], "n't") {
+			return true
+		}
+	}
+	return false
+}
+
+// highlightSubject finds what the opinion at words[i] describes: the following
+// word ("great views") or the subject of a copula ("the views were great")
+func highlightSubject(words []string, i int) string {
+	if i+1 < len(words) && isSubjectWord(words[i+1]) {
+		return words[i+1]
+	}
+
+	j := i - 1
+	for j >= 0 && intensifiers[words[j]] {
+		j--
+	}
+	if j >= 1 && copulas[words[j]] && isSubjectWord(words[j-1]) {
+		return words[j-1]
+	}
+	return ""
+}
+
+// isSubjectWord reports whether word can be the subject of a highlight
+func isSubjectWord(word string) bool {
+	return len(word) > 2 && !highlightStopwords[word] && !copulas[word] && !intensifiers[word] &&
+		!negators[word] && opinionPolarity(word) == 0
+}
+
+// splitSentences splits text into sentences and clauses joined by "but"
+func splitSentences(text string) []string {
+	sentences := strings.FieldsFunc(text, func(r rune) bool {
+		return r == '.' || r == '!' || r == '?' || r == ';' || r == '\n'
+	})
+
+	var clauses []string
+	for _, sentence := range sentences {
+		clauses = append(clauses, strings.Split(strings.ToLower(sentence), " but ")...)
+	}
+	return clauses
+}
+
+// reviewWords lowercases and tokenizes text, keeping apostrophes and hyphens inside words
+func reviewWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\'' && r != '-'
+	})
+}
+
+// reviewAnalysisPrompt instructs the model to return a ReviewAnalysis as JSON
+const reviewAnalysisPrompt = `You analyze reviews of outdoor activities and local places.
+Reply with JSON only, in the form {"sentiment": <number from -1 to 1>, "pros": [...], "cons": [...]}.
+Pros and cons are short lowercase phrases of two or three words such as "great views" or "poor signage", at most 5 each.`
+
+// LLMReviewAnalyzer asks a language model to analyze reviews. Replies that are not
+// valid JSON fall back to the lexicon analyzer so a review is never retried forever.
+type LLMReviewAnalyzer struct {
+	client   llm.Provider
+	fallback LexiconAnalyzer
+}
+
+// NewLLMReviewAnalyzer creates an analyzer backed by client
+func NewLLMReviewAnalyzer(client llm.Provider) *LLMReviewAnalyzer {
+	return &LLMReviewAnalyzer{client: client}
+}
+
+// Analyze sends the review to the model and parses its JSON reply
+func (a *LLMReviewAnalyzer) Analyze(ctx context.Context, review models.Review) (ReviewAnalysis, error) {
+	var reply strings.Builder
+	_, err := a.client.StreamCompletion(ctx, llm.CompletionRequest{
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: reviewAnalysisPrompt},
+			{Role: llm.RoleUser, Content: fmt.Sprintf("Rating: %d/5\n\n%s", review.Rating, review.Body)},
+		},
+	}, func(delta string) error {
+		reply.WriteString(delta)
+		return nil
+	})
+	if err != nil {
+		return ReviewAnalysis{}, fmt.Errorf("failed to analyze review %d: %w", review.ID, err)
+	}
+
+	// Models sometimes wrap JSON in a markdown code fence
+	text := strings.TrimSpace(reply.String())
+	text = strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(text, "```json"), "```"), "```")
+
+	var analysis ReviewAnalysis
+	if err := json.Unmarshal([]byte(text), &analysis); err != nil {
+		log.Printf("[REVIEWS] Review %d: unparseable analysis, using lexicon: %v", review.ID, err)
+		return a.fallback.Analyze(ctx, review)
+	}
+
+	analysis.Sentiment = max(-1, min(1, analysis.Sentiment))
+	analysis.Pros = normalizeHighlights(analysis.Pros)
+	analysis.Cons = normalizeHighlights(analysis.Cons)
+	return analysis, nil
+}
+
+// normalizeHighlights lowercases, trims and deduplicates phrases so they aggregate across reviews
+func normalizeHighlights(phrases []string) []string {
+	seen := make(map[string]bool, len(phrases))
+	var normalized []string
+	for _, phrase := range phrases {
+		phrase = strings.Join(strings.Fields(strings.ToLower(phrase)), " ")
+		if phrase == "" || seen[phrase] || len(normalized) == maxHighlightsPerReview {
+			continue
+		}
+		seen[phrase] = true
+		normalized = append(normalized, phrase)
+	}
+	return normalized
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidRole is returned for roles other than user, moderator and admin
+var ErrInvalidRole = apperr.New(apperr.Invalid, "role must be user, moderator or admin")
+
+// RoleService manages the roles of users
+type RoleService struct {
+	db *gorm.DB
+}
+
+// NewRoleService creates a new role service
+func NewRoleService(db *gorm.DB) *RoleService {
+	return &RoleService{
+		db: db,
+	}
+}
+
+// Role returns the role of a user
+func (s *RoleService) Role(ctx context.Context, userID uint) (string, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).Select("id", "role").First(&user, userID).Error; err != nil {
+		return "", fmt.Errorf("failed to load role of user %d: %w", userID, err)
+	}
+	return user.Role, nil
+}
+
+// SetRole changes the role of a user
+func (s *RoleService) SetRole(ctx context.Context, userID uint, role string) (*models.User, error) {
+	if !slices.Contains(models.Roles, role) {
+		return nil, ErrInvalidRole
+	}
+	var user models.User
+	if err := s.db.WithContext(ctx).First(&user, userID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load user %d: %w", userID, err)
+	}
+	if err := s.db.WithContext(ctx).Model(&user).Update("role", role).Error; err != nil {
+		return nil, fmt.Errorf("failed to set role of user %d: %w", userID, err)
+	}
+	return &user, nil
+}
+
+// SeedAdmin makes the user with email an admin, creating the account with
+// password if it does not exist; the operator vouches for the address of a
+// created account. Existing passwords are left alone, so the seed only ever
+// grants the role. Existing accounts must have verified the email: anyone can
+// register an address, and must not become admin by registering it first.
+func (s *RoleService) SeedAdmin(ctx context.Context, email, password string) error {
+	email = normalizeEmail(email)
+	var user models.User
+	err := s.db.WithContext(ctx).Where("email = ?", email).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if password == "" {
+			return fmt.Errorf("no user %s to make admin, and no password to create one with", email)
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return fmt.Errorf("failed to hash password: %w", err)
+		}
+		now := time.Now()
+		user = models.User{Email: email, Name: "Admin", PasswordHash: string(hash), Role: models.RoleAdmin, EmailVerifiedAt: &now}
+		if err := s.db.WithContext(ctx).Create(&user).Error; err != nil {
+			return fmt.Errorf("failed to create admin %s: %w", email, err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load user %s: %w", email, err)
+	}
+	if user.Role == models.RoleAdmin {
+		return nil
+	}
+	if user.EmailVerifiedAt == nil {
+		return fmt.Errorf("refusing to make user %d admin: %s is not verified; verify it or make the user admin by hand", user.ID, email)
+	}
+	if err := s.db.WithContext(ctx).Model(&user).Update("role", models.RoleAdmin).Error; err != nil {
+		return fmt.Errorf("failed to make %s admin: %w", email, err)
+	}
+	return nil
+}
+
+// canModerate reports whether a user's role allows changing content of others
+func canModerate(ctx context.Context, db *gorm.DB, userID uint) (bool, error) {
+	role, err := NewRoleService(db).Role(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return models.RoleHas(role, models.PermissionModerate), nil
+}
@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"community-chatbot/internal/geo"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// RouteService handles route ingestion and track access
+type RouteService struct {
+	db *gorm.DB
+}
+
+// NewRouteService creates a new route service
+func NewRouteService(db *gorm.DB) *RouteService {
+	return &RouteService{
+		db: db,
+	}
+}
+
+// RouteImport describes an uploaded route file
+type RouteImport struct {
+	ActivityID uint
+	Filename   string
+	Data       []byte
+	Name       string
+	RouteType  string
+	Difficulty string
+	// MaxPoints caps the track points of the file; zero means no cap
+	MaxPoints int
+}
+
+// Import parses a GPX, TCX, KML or FIT file, computes distance, elevation change,
+// duration and difficulty score from the normalized track and stores it as a route of the activity
+func (s *RouteService) Import(ctx context.Context, in RouteImport) (*models.Route, error) {
+	var activity models.Activity
+	if err := s.db.WithContext(ctx).Select("id").First(&activity, in.ActivityID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load activity %d: %w", in.ActivityID, err)
+	}
+
+	track, format, err := geo.ParseTrack(in.Filename, in.Data)
+	if err != nil {
+		return nil, err
+	}
+	if in.MaxPoints > 0 && len(track.Points) > in.MaxPoints {
+		return nil, fmt.Errorf("%w: %d exceeds %d", geo.ErrTooManyPoints, len(track.Points), in.MaxPoints)
+	}
+
+	trackData, err := json.Marshal(track)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode track: %w", err)
+	}
+
+	name := in.Name
+	if name == "" {
+		name = track.Name
+	}
+
+	route := &models.Route{
+		ActivityID:   in.ActivityID,
+		Name:         name,
+		RouteType:    in.RouteType,
+		Difficulty:   in.Difficulty,
+		SourceFormat: format,
+		TrackData:    trackData,
+	}
+	setTrackStats(route, track)
+
+	// Without a difficulty from the submitter, the score is bucketed with this community's calibration
+	if route.Difficulty == "" {
+		calibration, err := NewDifficultyService(s.db).Calibration(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		route.Difficulty = calibration.Level(route.DifficultyScore)
+		route.DifficultyAuto = true
+	}
+	if err := s.db.WithContext(ctx).Create(route).Error; err != nil {
+		return nil, fmt.Errorf("failed to create route: %w", err)
+	}
+
+	return route, nil
+}
+
+// routePaces are the paces of the route types; routes of other types are hiked
+var routePaces = map[string]geo.Pace{
+	"hiking":  geo.PaceHiking,
+	"cycling": geo.PaceCycling,
+	"driving": geo.PaceDriving,
+}
+
+// setTrackStats sets the distance, elevation change, duration and difficulty
+// score of a route from its track
+func setTrackStats(route *models.Route, track *geo.Track) {
+	pace, ok := routePaces[route.RouteType]
+	if !ok {
+		pace = geo.PaceHiking
+	}
+	stats := track.Stats(pace)
+	route.DistanceKM = math.Round(stats.DistanceKM*100) / 100
+	route.ElevationGainM = int(math.Round(stats.ElevationGainM))
+	route.ElevationLossM = int(math.Round(stats.ElevationLossM))
+	route.DurationMin = int(stats.Duration.Minutes())
+	route.DifficultyScore = models.RouteDifficultyScore(route.DistanceKM, route.ElevationGainM)
+}
+
+// recomputeBatchSize is the number of routes loaded at once by RecomputeStats
+const recomputeBatchSize = 100
+
+// RecomputeResult counts the routes of a RecomputeStats run
+type RecomputeResult struct {
+	// Updated routes had a figure change
+	Updated int `json:"updated"`
+	// Unchanged routes already had the figures of their track
+	Unchanged int `json:"unchanged"`
+	// Skipped routes have no stored track or one that cannot be decoded
+	Skipped int `json:"skipped"`
+}
+
+// RecomputeStats recomputes the figures of every route with a stored track, or
+// only of one activity's routes when activityID is not zero, e.g. after the
+// computation changed. Difficulties derived from the score are re-bucketed with
+// this community's calibration, and cached elevation profiles are dropped;
+// updated_at is left alone.
+func (s *RouteService) RecomputeStats(ctx context.Context, activityID uint) (*RecomputeResult, error) {
+	calibration, err := NewDifficultyService(s.db).Calibration(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	scope := func(db *gorm.DB) *gorm.DB {
+		if activityID != 0 {
+			return db.Where("activity_id = ?", activityID)
+		}
+		return db
+	}
+	// Cached elevation profiles are computed again on their next request
+	if err := s.db.WithContext(ctx).Model(&models.Route{}).Scopes(scope).
+		Where("elevation_profile IS NOT NULL").
+		UpdateColumn("elevation_profile", nil).Error; err != nil {
+		return nil, fmt.Errorf("failed to clear elevation profiles: %w", err)
+	}
+
+	result := &RecomputeResult{}
+	var batch []models.Route
+	err = s.db.WithContext(ctx).Scopes(scope).FindInBatches(&batch, recomputeBatchSize, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			route := &batch[i]
+			track, err := s.LoadTrack(route)
+			if err != nil {
+				log.Printf("[ROUTE] Skipping stats of route %d: %v", route.ID, err)
+				result.Skipped++
+				continue
+			}
+
+			before := *route
+			setTrackStats(route, track)
+			if route.DifficultyAuto {
+				route.Difficulty = calibration.Level(route.DifficultyScore)
+			}
+			if route.DistanceKM == before.DistanceKM && route.ElevationGainM == before.ElevationGainM &&
+				route.ElevationLossM == before.ElevationLossM && route.DurationMin == before.DurationMin &&
+				route.DifficultyScore == before.DifficultyScore && route.Difficulty == before.Difficulty {
+				result.Unchanged++
+				continue
+			}
+			if err := s.db.WithContext(ctx).Model(route).UpdateColumns(map[string]interface{}{
+				"distance_km":      route.DistanceKM,
+				"elevation_gain_m": route.ElevationGainM,
+				"elevation_loss_m": route.ElevationLossM,
+				"duration_min":     route.DurationMin,
+				"difficulty_score": route.DifficultyScore,
+				"difficulty":       route.Difficulty,
+			}).Error; err != nil {
+				return fmt.Errorf("failed to update stats of route %d: %w", route.ID, err)
+			}
+			result.Updated++
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to recompute route stats: %w", err)
+	}
+	return result, nil
+}
+
+// maxProfilePoints caps the points of a route's elevation profile
+const maxProfilePoints = 500
+
+// ElevationProfile returns the elevation profile of a route of an approved
+// activity, computing it from the track and caching it on the route on first
+// use. Routes without a track return gorm.ErrRecordNotFound, tracks without
+// elevations geo.ErrNoElevation.
+func (s *RouteService) ElevationProfile(ctx context.Context, id uint) (*models.Route, []geo.ProfilePoint, error) {
+	route, err := s.GetPublished(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var profile []geo.ProfilePoint
+	if len(route.ElevationProfile) > 0 {
+		if err := json.Unmarshal(route.ElevationProfile, &profile); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode elevation profile of route %d: %w", id, err)
+		}
+	} else {
+		track, err := s.LoadTrack(route)
+		if errors.Is(err, geo.ErrEmptyTrack) {
+			return nil, nil, fmt.Errorf("route %d has no track: %w", id, gorm.ErrRecordNotFound)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		profile, err = track.ElevationProfile(maxProfilePoints)
+		if errors.Is(err, geo.ErrNoElevation) {
+			// Cached as empty, so the track is not decoded again
+			profile = []geo.ProfilePoint{}
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		data, err := json.Marshal(profile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to encode elevation profile: %w", err)
+		}
+		if err := s.db.WithContext(ctx).Model(route).UpdateColumn("elevation_profile", data).Error; err != nil {
+			// The profile is served anyway and cached on the next request
+			log.Printf("[ROUTE] Failed to cache elevation profile of route %d: %v", id, err)
+		}
+	}
+	if len(profile) == 0 {
+		return nil, nil, geo.ErrNoElevation
+	}
+	return route, profile, nil
+}
+
+// GetPublished returns a route of an approved activity, or gorm.ErrRecordNotFound
+func (s *RouteService) GetPublished(ctx context.Context, id uint) (*models.Route, error) {
+	var route models.Route
+	if err := s.db.WithContext(ctx).
+		Joins("JOIN activities ON activities.id = routes.activity_id AND activities.approved = ? AND activities.deleted_at IS NULL", true).
+		First(&route, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to load route %d: %w", id, err)
+	}
+	return &route, nil
+}
+
+// LoadTrack decodes the normalized track stored on a route
+func (s *RouteService) LoadTrack(route *models.Route) (*geo.Track, error) {
+	if len(route.TrackData) == 0 {
+		return nil, geo.ErrEmptyTrack
+	}
+
+	var track geo.Track
+	if err := json.Unmarshal(route.TrackData, &track); err != nil {
+		return nil, fmt.Errorf("failed to decode track for route %d: %w", route.ID, err)
+	}
+	return &track, nil
+}
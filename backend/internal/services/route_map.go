@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"maps"
+	"slices"
+
+	"community-chatbot/internal/agui"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// maxMapPoints caps the positions of a route geometry; tracks still longer
+	// after simplification are thinned
+	maxMapPoints = 1000
+	// mapToleranceM is the simplification tolerance of route geometries; points
+	// this close to the line of their neighbours add nothing to a drawn map
+	mapToleranceM = 5
+	// maxMapRoutes caps the routes drawn per lookup
+	maxMapRoutes = 10
+)
+
+// mapRoutesKey is the run metadata key of the routes whose maps were sent
+const mapRoutesKey = "map_routes"
+
+// withRouteMaps wraps a tool returning activities or routes so the routes of
+// its results are sent to the client as MAP_DATA_READY events
+func withRouteMaps(db *gorm.DB, call chat.ToolFunc) chat.ToolFunc {
+	return func(ctx context.Context, run *chat.Run, raw json.RawMessage) (interface{}, error) {
+		result, err := call(ctx, run, raw)
+		if err != nil {
+			return result, err
+		}
+
+		var activityIDs []uint
+		switch results := result.(type) {
+		case []toolActivity:
+			for _, activity := range results {
+				activityIDs = append(activityIDs, activity.ID)
+			}
+		case []models.Route:
+			for _, route := range results {
+				activityIDs = append(activityIDs, route.ActivityID)
+			}
+		}
+		if err := emitRouteMaps(ctx, db, run, activityIDs); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+}
+
+// emitRouteMaps sends a MAP_DATA_READY event with the track of each route of
+// the approved activities, shortest first, for the client to draw. Routes sent
+// earlier in the run are skipped, and routes without a stored track have
+// nothing to draw. Failing to load routes is logged; only failing to emit
+// events is returned.
+func emitRouteMaps(ctx context.Context, db *gorm.DB, run *chat.Run, activityIDs []uint) error {
+	if len(activityIDs) == 0 {
+		return nil
+	}
+	sent, _ := run.Metadata[mapRoutesKey].(map[uint]bool)
+	if sent == nil {
+		sent = make(map[uint]bool)
+		run.Metadata[mapRoutesKey] = sent
+	}
+
+	query := db.WithContext(ctx).
+		Where("activity_id IN (?) AND track_data IS NOT NULL", db.Model(&models.Activity{}).Select("id").Where("id IN ? AND approved = ?", activityIDs, true))
+	if len(sent) > 0 {
+		query = query.Where("id NOT IN ?", slices.Collect(maps.Keys(sent)))
+	}
+	var routes []models.Route
+	if err := query.Order("distance_km, id").Limit(maxMapRoutes).Find(&routes).Error; err != nil {
+		log.Printf("[CHAT] Run %s: failed to load routes to map: %v", run.ID, err)
+		return nil
+	}
+
+	routeService := NewRouteService(db)
+	for _, route := range routes {
+		track, err := routeService.LoadTrack(&route)
+		if err != nil {
+			log.Printf("[CHAT] Run %s: failed to map route %d: %v", run.ID, route.ID, err)
+			continue
+		}
+		if len(track.Points) < 2 {
+			continue
+		}
+		if err := run.Emit(agui.CreateMapDataEvent(agui.MapData{
+			ActivityID:     route.ActivityID,
+			RouteID:        route.ID,
+			Name:           route.Name,
+			DistanceKM:     route.DistanceKM,
+			ElevationGainM: route.ElevationGainM,
+			Geometry:       track.Simplify(mapToleranceM).LineString(maxMapPoints),
+			BBox:           track.BBox(),
+		})); err != nil {
+			return err
+		}
+		sent[route.ID] = true
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/geo"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// maxSavedSearchesPerUser caps the saved searches of a user
+const maxSavedSearchesPerUser = 20
+
+// defaultSavedSearchRadiusKM is the radius of saved searches with a location but no radius
+const defaultSavedSearchRadiusKM = 25
+
+// ErrTooManySavedSearches is returned when a user already has the maximum of saved searches
+var ErrTooManySavedSearches = apperr.New(apperr.Conflict, fmt.Sprintf("at most %d searches can be saved, delete one first", maxSavedSearchesPerUser))
+
+// SavedSearchService stores the searches users want to hear about, such as
+// closures of matching activities
+type SavedSearchService struct {
+	db *gorm.DB
+}
+
+// NewSavedSearchService creates a new saved search service
+func NewSavedSearchService(db *gorm.DB) *SavedSearchService {
+	return &SavedSearchService{
+		db: db,
+	}
+}
+
+// Create saves a search for a user
+func (s *SavedSearchService) Create(ctx context.Context, userID uint, search *models.SavedSearch) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.SavedSearch{}).
+		Where("user_id = ?", userID).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count saved searches: %w", err)
+	}
+	if count >= maxSavedSearchesPerUser {
+		return ErrTooManySavedSearches
+	}
+
+	search.ID = 0
+	search.UserID = userID
+	if search.Location == nil {
+		search.RadiusKM = 0
+	} else if search.RadiusKM == 0 {
+		search.RadiusKM = defaultSavedSearchRadiusKM
+	}
+	if err := s.db.WithContext(ctx).Create(search).Error; err != nil {
+		return fmt.Errorf("failed to save search: %w", err)
+	}
+	return nil
+}
+
+// List returns a user's saved searches, newest first
+func (s *SavedSearchService) List(ctx context.Context, userID uint) ([]models.SavedSearch, error) {
+	var searches []models.SavedSearch
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC, id DESC").Find(&searches).Error; err != nil {
+		return nil, fmt.Errorf("failed to list saved searches: %w", err)
+	}
+	return searches, nil
+}
+
+// Delete removes one of a user's saved searches
+func (s *SavedSearchService) Delete(ctx context.Context, userID, id uint) error {
+	result := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&models.SavedSearch{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete saved search %d: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to delete saved search %d: %w", id, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// savedSearchMatches reports whether an activity matches the saved search
+func savedSearchMatches(search models.SavedSearch, activity models.Activity) bool {
+	if search.Category != "" && !strings.EqualFold(search.Category, activity.Category) {
+		return false
+	}
+	if search.Difficulty != "" && !strings.EqualFold(search.Difficulty, activity.Difficulty) {
+		return false
+	}
+	if search.Location != nil {
+		distance := geo.DistanceKM(search.Location.Lat, search.Location.Lng, activity.Latitude, activity.Longitude)
+		if distance > float64(search.RadiusKM) {
+			return false
+		}
+	}
+	return true
+}
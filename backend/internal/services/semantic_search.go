@@ -0,0 +1,496 @@
+package services
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/agui"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/llm"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// embeddingBatchSize is how many activities are embedded per request to the model
+	embeddingBatchSize = 50
+	// maxEmbeddingTextLength truncates long descriptions to stay within the
+	// input limits of embedding models
+	maxEmbeddingTextLength = 8000
+	// maxRetrievedDescriptionLength truncates the descriptions of activities
+	// retrieved for a chat message, keeping the prompt short
+	maxRetrievedDescriptionLength = 600
+	// embeddingRetryDelay is how long an activity the model failed to embed is
+	// skipped after its first failure; the delay doubles with every further one
+	embeddingRetryDelay = time.Hour
+	// maxEmbeddingRetryDelay caps the delay between retries of failed activities
+	maxEmbeddingRetryDelay = 7 * 24 * time.Hour
+)
+
+// embeddingTextHash is the SQL equivalent of embeddingText's hash; activities
+// whose stored hash differs are embedded again
+const embeddingTextHash = "md5(COALESCE(activities.name, '') || chr(10) || COALESCE(activities.category, '') || chr(10) || COALESCE(activities.description, ''))"
+
+// EnsureEmbeddings creates the pgvector extension and the table of activity
+// embeddings with its cosine distance index. Embeddings live apart from
+// activities so loading activities does not load their vectors. It is safe to
+// run on every start; changing the dimensions requires dropping the table.
+func EnsureEmbeddings(db *gorm.DB, dimensions int) error {
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS vector").Error; err != nil {
+		return fmt.Errorf("failed to enable pgvector: %w", err)
+	}
+	if err := db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS activity_embeddings (
+		activity_id bigint PRIMARY KEY REFERENCES activities (id) ON DELETE CASCADE,
+		embedding vector(%d) NOT NULL,
+		model varchar(255) NOT NULL,
+		text_hash char(32) NOT NULL,
+		embedded_at timestamptz NOT NULL
+	)`, dimensions)).Error; err != nil {
+		return fmt.Errorf("failed to create activity embeddings table: %w", err)
+	}
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_activity_embeddings_embedding ON activity_embeddings USING hnsw (embedding vector_cosine_ops)").Error; err != nil {
+		return fmt.Errorf("failed to create activity embeddings index: %w", err)
+	}
+	// Activities the model failed to embed are skipped until retry_at, so they do
+	// not hold up the activities after them
+	if err := db.Exec(`CREATE TABLE IF NOT EXISTS activity_embedding_failures (
+		activity_id bigint PRIMARY KEY REFERENCES activities (id) ON DELETE CASCADE,
+		model varchar(255) NOT NULL,
+		text_hash char(32) NOT NULL,
+		attempts integer NOT NULL,
+		last_error text NOT NULL,
+		retry_at timestamptz NOT NULL
+	)`).Error; err != nil {
+		return fmt.Errorf("failed to create activity embedding failures table: %w", err)
+	}
+	return nil
+}
+
+// SemanticQuery describes a search of activities by meaning
+type SemanticQuery struct {
+	Text       string
+	Category   string
+	Difficulty string
+	Limit      int
+	// MinSimilarity leaves out activities less similar to the text, 0 for none
+	MinSimilarity float64
+	// InSeason leaves out seasonal variants not offered in the current season
+	InSeason bool
+}
+
+// SemanticResult is an activity close in meaning to a semantic search
+type SemanticResult struct {
+	models.Activity
+	// Similarity is the cosine similarity of the activity and the query, up to 1
+	Similarity float64 `json:"similarity"`
+}
+
+// SemanticSearchService finds activities by meaning ("somewhere quiet with
+// waterfalls") rather than keywords. Names, categories and descriptions of
+// approved activities are embedded in the background and compared with the
+// embedded query text.
+type SemanticSearchService struct {
+	db       *gorm.DB
+	provider llm.Provider
+	// model names the embedding model; embeddings of other models are replaced
+	model string
+	// staleAfter marks found activities outdated when they were not verified within it
+	staleAfter time.Duration
+}
+
+// NewSemanticSearchService creates a new semantic search service embedding
+// with the provider's embedding model
+func NewSemanticSearchService(db *gorm.DB, provider llm.Provider, model string, staleAfter time.Duration) *SemanticSearchService {
+	return &SemanticSearchService{
+		db:         db,
+		provider:   provider,
+		model:      model,
+		staleAfter: staleAfter,
+	}
+}
+
+// embeddingCandidate is an activity whose embedding is missing or outdated
+type embeddingCandidate struct {
+	ID          uint
+	Name        string
+	Category    string
+	Description string
+}
+
+// embeddingText returns the text embedded for an activity and its hash, which
+// matches embeddingTextHash
+func embeddingText(c embeddingCandidate) (string, string) {
+	text := c.Name + "\n" + c.Category + "\n" + c.Description
+	sum := md5.Sum([]byte(text))
+	if len(text) > maxEmbeddingTextLength {
+		text = strings.ToValidUTF8(text[:maxEmbeddingTextLength], "")
+	}
+	return text, hex.EncodeToString(sum[:])
+}
+
+// EmbedPending embeds up to batchSize approved activities that have no
+// embedding of the current model or whose text changed since, and returns how
+// many it processed, embedded or failed. When the model rejects the batch, the
+// activities are embedded one by one and those it rejects are skipped for a
+// growing delay, or until their text or the model changes. Failures of every
+// activity of a larger batch are taken for an outage and not recorded.
+func (s *SemanticSearchService) EmbedPending(ctx context.Context, batchSize int) (int, error) {
+	var pending []embeddingCandidate
+	if err := s.db.WithContext(ctx).Model(&models.Activity{}).
+		Select("activities.id, activities.name, activities.category, activities.description").
+		Joins("LEFT JOIN activity_embeddings ON activity_embeddings.activity_id = activities.id").
+		Joins("LEFT JOIN activity_embedding_failures ON activity_embedding_failures.activity_id = activities.id "+
+			"AND activity_embedding_failures.model = ? AND activity_embedding_failures.text_hash = "+embeddingTextHash, s.model).
+		Where("activities.approved = ?", true).
+		Where("activity_embeddings.activity_id IS NULL OR activity_embeddings.model <> ? OR activity_embeddings.text_hash <> "+embeddingTextHash, s.model).
+		Where("activity_embedding_failures.activity_id IS NULL OR activity_embedding_failures.retry_at <= ?", time.Now()).
+		Order("activities.id").
+		Limit(batchSize).
+		Scan(&pending).Error; err != nil {
+		return 0, fmt.Errorf("failed to load activities to embed: %w", err)
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	texts := make([]string, len(pending))
+	hashes := make([]string, len(pending))
+	for i, candidate := range pending {
+		texts[i], hashes[i] = embeddingText(candidate)
+	}
+	embeddings, err := s.provider.Embed(ctx, texts)
+	if err == nil && len(embeddings) != len(pending) {
+		err = fmt.Errorf("%d embeddings for %d activities", len(embeddings), len(pending))
+	}
+	if err != nil {
+		return s.embedEach(ctx, pending, texts, hashes, err)
+	}
+
+	for i, candidate := range pending {
+		if err := s.storeEmbedding(ctx, candidate.ID, embeddings[i], hashes[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(pending), nil
+}
+
+// embedEach embeds the activities of a rejected batch one by one and records
+// those the model rejects. It returns batchErr when every activity fails.
+func (s *SemanticSearchService) embedEach(ctx context.Context, pending []embeddingCandidate, texts, hashes []string, batchErr error) (int, error) {
+	failures := make(map[int]error)
+	for i, candidate := range pending {
+		embeddings, err := s.provider.Embed(ctx, texts[i:i+1])
+		if err == nil && len(embeddings) != 1 {
+			err = fmt.Errorf("%d embeddings for 1 activity", len(embeddings))
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return i, ctx.Err()
+			}
+			failures[i] = err
+			continue
+		}
+		if err := s.storeEmbedding(ctx, candidate.ID, embeddings[0], hashes[i]); err != nil {
+			return i, err
+		}
+	}
+	if len(pending) > 1 && len(failures) == len(pending) {
+		return 0, fmt.Errorf("failed to embed activities: %w", batchErr)
+	}
+
+	for i, err := range failures {
+		log.Printf("[SEMANTIC] Skipping activity %d the model failed to embed: %v", pending[i].ID, err)
+		if err := s.recordFailure(ctx, pending[i].ID, hashes[i], err); err != nil {
+			return len(pending), err
+		}
+	}
+	return len(pending), nil
+}
+
+// storeEmbedding stores the embedding of an activity and clears its failures
+func (s *SemanticSearchService) storeEmbedding(ctx context.Context, activityID uint, embedding []float32, hash string) error {
+	if err := s.db.WithContext(ctx).Exec(`INSERT INTO activity_embeddings (activity_id, embedding, model, text_hash, embedded_at)
+		VALUES (?, ?::vector, ?, ?, ?)
+		ON CONFLICT (activity_id) DO UPDATE SET embedding = EXCLUDED.embedding, model = EXCLUDED.model,
+			text_hash = EXCLUDED.text_hash, embedded_at = EXCLUDED.embedded_at`,
+		activityID, vectorLiteral(embedding), s.model, hash, time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to store embedding of activity %d: %w", activityID, err)
+	}
+	if err := s.db.WithContext(ctx).Exec("DELETE FROM activity_embedding_failures WHERE activity_id = ?", activityID).Error; err != nil {
+		return fmt.Errorf("failed to clear embedding failures of activity %d: %w", activityID, err)
+	}
+	return nil
+}
+
+// recordFailure skips an activity the model failed to embed until its retry
+// time, which doubles with every failure of the same text and model
+func (s *SemanticSearchService) recordFailure(ctx context.Context, activityID uint, hash string, cause error) error {
+	var attempts int
+	if err := s.db.WithContext(ctx).Raw(`SELECT attempts FROM activity_embedding_failures
+		WHERE activity_id = ? AND model = ? AND text_hash = ?`, activityID, s.model, hash).
+		Scan(&attempts).Error; err != nil {
+		return fmt.Errorf("failed to load embedding failures of activity %d: %w", activityID, err)
+	}
+	attempts++
+	delay := maxEmbeddingRetryDelay
+	if attempts <= 10 {
+		delay = min(embeddingRetryDelay<<(attempts-1), maxEmbeddingRetryDelay)
+	}
+
+	if err := s.db.WithContext(ctx).Exec(`INSERT INTO activity_embedding_failures (activity_id, model, text_hash, attempts, last_error, retry_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (activity_id) DO UPDATE SET model = EXCLUDED.model, text_hash = EXCLUDED.text_hash,
+			attempts = EXCLUDED.attempts, last_error = EXCLUDED.last_error, retry_at = EXCLUDED.retry_at`,
+		activityID, s.model, hash, attempts, truncateRunes(cause.Error(), 500), time.Now().Add(delay)).Error; err != nil {
+		return fmt.Errorf("failed to record embedding failure of activity %d: %w", activityID, err)
+	}
+	return nil
+}
+
+// StartEmbedding embeds new and changed activities every interval until ctx is
+// cancelled. Embedding is idempotent, so replicas may run it concurrently.
+func (s *SemanticSearchService) StartEmbedding(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			embedded, err := s.EmbedPending(ctx, embeddingBatchSize)
+			if err != nil {
+				log.Printf("[SEMANTIC] Embedding failed: %v", err)
+			}
+			if embedded > 0 {
+				log.Printf("[SEMANTIC] Processed %d activities", embedded)
+			}
+			if embedded < embeddingBatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Search returns up to q.Limit approved activities closest in meaning to the
+// query text, most similar first. Activities not embedded yet are not found.
+func (s *SemanticSearchService) Search(ctx context.Context, q SemanticQuery) ([]SemanticResult, error) {
+	embeddings, err := s.provider.Embed(ctx, []string{q.Text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed search text: %w", err)
+	}
+	if len(embeddings) != 1 {
+		return nil, fmt.Errorf("failed to embed search text: %d embeddings for 1 text", len(embeddings))
+	}
+	vector := vectorLiteral(embeddings[0])
+
+	query := s.db.WithContext(ctx).Model(&models.Activity{}).
+		Joins("JOIN activity_embeddings ON activity_embeddings.activity_id = activities.id").
+		Where("activities.approved = ? AND activity_embeddings.model = ?", true, s.model)
+	if q.Category != "" {
+		condition, args, err := categoryFilter(ctx, s.db, q.Category)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(condition, args...)
+	}
+	if q.Difficulty != "" {
+		condition, args, err := difficultyFilter(ctx, s.db, q.Difficulty)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(condition, args...)
+	}
+	if q.InSeason {
+		query = inSeason(query, time.Now())
+	}
+	if q.MinSimilarity > 0 {
+		query = query.Where("activity_embeddings.embedding <=> ?::vector <= ?", vector, 1-q.MinSimilarity)
+	}
+
+	results := []SemanticResult{}
+	if err := query.Select("activities.*, 1 - (activity_embeddings.embedding <=> ?::vector) AS similarity", vector).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:  "activity_embeddings.embedding <=> ?::vector",
+			Vars: []interface{}{vector},
+		}}).
+		Limit(q.Limit).
+		Find(&results).Error; err != nil {
+		return nil, fmt.Errorf("failed to search activities by meaning: %w", err)
+	}
+	return results, nil
+}
+
+// Tool returns the find_activities_by_meaning chat tool
+func (s *SemanticSearchService) Tool() chat.Tool {
+	return chat.Tool{
+		Name: "find_activities_by_meaning",
+		Description: "Find approved community activities by what they are like rather than by keywords, " +
+			"e.g. \"somewhere quiet with waterfalls\" or \"a relaxed ride for kids\". Results are ordered by similarity.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"description": {"type": "string", "description": "What the user is looking for, in their words"},
+				"category": {"type": "string", "description": "Activity category, e.g. hiking, cycling, restaurant"},
+				"difficulty": {"type": "string", "description": "e.g. easy, moderate, hard"},
+				"limit": {"type": "integer", "minimum": 1, "maximum": 10}
+			},
+			"required": ["description"]
+		}`),
+		Call: withRouteMaps(s.db, s.findActivitiesByMeaning),
+	}
+}
+
+// findActivitiesByMeaning implements the find_activities_by_meaning tool
+func (s *SemanticSearchService) findActivitiesByMeaning(ctx context.Context, _ *chat.Run, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		Description string `json:"description"`
+		Category    string `json:"category"`
+		Difficulty  string `json:"difficulty"`
+		Limit       int    `json:"limit"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, err)
+	}
+	if strings.TrimSpace(args.Description) == "" {
+		return nil, fmt.Errorf("%w: description is required", chat.ErrInvalidToolArguments)
+	}
+	if args.Limit <= 0 || args.Limit > toolSearchMaxLimit {
+		args.Limit = toolSearchDefaultLimit
+	}
+
+	found, err := s.Search(ctx, SemanticQuery{
+		Text:       args.Description,
+		Category:   args.Category,
+		Difficulty: args.Difficulty,
+		Limit:      args.Limit,
+		InSeason:   true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]toolActivity, len(found))
+	for i, result := range found {
+		results[i] = newToolActivity(result.Activity, s.staleAfter)
+	}
+	return results, nil
+}
+
+// retrievedActivity is an activity retrieved for a chat message, as sent to the
+// client in ACTIVITIES_FOUND
+type retrievedActivity struct {
+	toolActivity
+	Description string  `json:"description"`
+	Similarity  float64 `json:"similarity"`
+}
+
+// Stage returns the retrieval stage, which finds up to limit approved
+// activities closest in meaning to each message, at least minSimilarity
+// similar. Their descriptions are added to the notes the model answers from,
+// an ACTIVITIES_FOUND event carries them for the client to render as cards,
+// and MAP_DATA_READY events carry their routes. Runs continuing an answer
+// retrieve nothing; search failures are logged and the run continues without
+// retrieved activities.
+func (s *SemanticSearchService) Stage(limit int, minSimilarity float64) chat.Stage {
+	return chat.StageFunc{
+		StageName: "activity_retrieval",
+		Fn: func(ctx context.Context, run *chat.Run, next chat.Handler) error {
+			if run.Continue || strings.TrimSpace(run.Message) == "" {
+				return next(ctx, run)
+			}
+
+			found, err := s.Search(ctx, SemanticQuery{Text: run.Message, Limit: limit, MinSimilarity: minSimilarity, InSeason: true})
+			if err != nil {
+				log.Printf("[CHAT] Run %s: activity retrieval failed: %v", run.ID, err)
+				return next(ctx, run)
+			}
+			if len(found) == 0 {
+				return next(ctx, run)
+			}
+
+			cards := make([]interface{}, len(found))
+			activityIDs := make([]uint, len(found))
+			for i, result := range found {
+				activity := retrievedActivity{
+					toolActivity: newToolActivity(result.Activity, s.staleAfter),
+					Description:  truncateRunes(result.Description, maxRetrievedDescriptionLength),
+					Similarity:   math.Round(result.Similarity*100) / 100,
+				}
+				cards[i], activityIDs[i] = activity, activity.ID
+				run.AddNote(retrievalNote(activity))
+			}
+			if err := run.Emit(agui.CreateActivitiesFoundEvent(agui.ActivitiesFoundData{
+				Activities:  cards,
+				SearchQuery: run.Message,
+			})); err != nil {
+				return err
+			}
+			if err := emitRouteMaps(ctx, s.db, run, activityIDs); err != nil {
+				return err
+			}
+			return next(ctx, run)
+		},
+	}
+}
+
+// retrievalNote presents a retrieved activity to the model. Descriptions are
+// written by community members, so they are quoted as data, not instructions.
+func retrievalNote(activity retrievedActivity) string {
+	var details []string
+	if activity.Difficulty != "" {
+		details = append(details, activity.Difficulty)
+	}
+	if activity.Duration > 0 {
+		details = append(details, fmt.Sprintf("%d minutes", activity.Duration))
+	}
+	if activity.RatingCount > 0 {
+		details = append(details, fmt.Sprintf("rated %.1f by %d users", activity.Rating, activity.RatingCount))
+	}
+	note := fmt.Sprintf("Activity possibly relevant to the question: %s (%s), %s", activity.Name, activity.Link, activity.Category)
+	if len(details) > 0 {
+		note += ", " + strings.Join(details, ", ")
+	}
+	if activity.Description != "" {
+		note += fmt.Sprintf(". Its community-submitted description reads: %q", activity.Description)
+	}
+	if activity.Outdated {
+		note += ". Its information was not verified recently and may be outdated, so suggest checking it before going"
+	}
+	return note
+}
+
+// truncateRunes shortens text to at most max runes, marking the cut with an ellipsis
+func truncateRunes(text string, max int) string {
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) <= max {
+		return string(runes)
+	}
+	return strings.TrimSpace(string(runes[:max])) + "…"
+}
+
+// vectorLiteral formats an embedding as a pgvector literal, e.g. [0.1,-0.2]
+func vectorLiteral(embedding []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, value := range embedding {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(value), 'f', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
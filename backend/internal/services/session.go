@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// sessionConversationLimit is how many recent conversations Conversations returns
+const sessionConversationLimit = 20
+
+// SessionService issues sessions to anonymous chat users and keeps their
+// conversations and chat context
+type SessionService struct {
+	db  *gorm.DB
+	ttl time.Duration
+}
+
+// NewSessionService creates a new session service. Sessions expire after ttl without use.
+func NewSessionService(db *gorm.DB, ttl time.Duration) *SessionService {
+	return &SessionService{
+		db:  db,
+		ttl: ttl,
+	}
+}
+
+// TTL returns how long sessions last without use
+func (s *SessionService) TTL() time.Duration {
+	return s.ttl
+}
+
+// Create issues a new session and returns its ID and token. The token is not
+// stored and cannot be shown again.
+func (s *SessionService) Create(ctx context.Context) (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("failed to generate session token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	session := models.Session{
+		ID:        uuid.New().String(),
+		TokenHash: hashSessionToken(token),
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+	if err := s.db.WithContext(ctx).Create(&session).Error; err != nil {
+		return "", "", fmt.Errorf("failed to create session: %w", err)
+	}
+	return session.ID, token, nil
+}
+
+// Resolve returns the ID of the live session with token and extends its
+// lifetime, or "" when the token is unknown or expired
+func (s *SessionService) Resolve(ctx context.Context, token string) (string, error) {
+	now := time.Now()
+	var session models.Session
+	err := s.db.WithContext(ctx).Select("id").
+		Where("token_hash = ? AND expires_at > ?", hashSessionToken(token), now).
+		First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load session: %w", err)
+	}
+
+	if err := s.db.WithContext(ctx).Model(&session).Update("expires_at", now.Add(s.ttl)).Error; err != nil {
+		return "", fmt.Errorf("failed to extend session %s: %w", session.ID, err)
+	}
+	return session.ID, nil
+}
+
+// Get returns a session by ID
+func (s *SessionService) Get(ctx context.Context, id string) (*models.Session, error) {
+	var session models.Session
+	if err := s.db.WithContext(ctx).First(&session, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to load session %s: %w", id, err)
+	}
+	return &session, nil
+}
+
+// Conversations returns the session's most recently active conversations
+func (s *SessionService) Conversations(ctx context.Context, id string) ([]models.Conversation, error) {
+	var conversations []models.Conversation
+	if err := s.db.WithContext(ctx).
+		Where("session_id = ? AND user_id IS NULL", id).
+		Order("updated_at DESC").
+		Limit(sessionConversationLimit).
+		Find(&conversations).Error; err != nil {
+		return nil, fmt.Errorf("failed to list conversations of session %s: %w", id, err)
+	}
+	return conversations, nil
+}
+
+// Stage returns the pipeline stage that makes the run's conversation the
+// session's current one and keeps the chat context: context sent with a run is
+// saved to the session, runs without one get the saved context. Failures are
+// logged and the run continues. Conversations belong to the session they were
+// started in, which AddMessage records.
+func (s *SessionService) Stage() chat.Stage {
+	return chat.StageFunc{
+		StageName: "session",
+		Fn: func(ctx context.Context, run *chat.Run, next chat.Handler) error {
+			if run.SessionID == "" || run.UserID != 0 {
+				return next(ctx, run)
+			}
+
+			if run.UserContext == nil {
+				uc, err := s.chatContext(ctx, run.SessionID)
+				if err != nil {
+					log.Printf("[CHAT] Run %s: failed to load session context: %v", run.ID, err)
+				}
+				run.UserContext = uc
+			}
+			if err := s.record(ctx, run); err != nil {
+				log.Printf("[CHAT] Run %s: failed to update session %s: %v", run.ID, run.SessionID, err)
+			}
+			return next(ctx, run)
+		},
+	}
+}
+
+// chatContext returns the saved chat context of a session, or nil when it has none
+func (s *SessionService) chatContext(ctx context.Context, id string) (*chat.UserContext, error) {
+	session, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.LocationLat == nil && session.SearchRadiusKM == 0 && len(session.PreferredActivities) == 0 &&
+		session.DifficultyLevel == "" && session.TransportMode == "" {
+		return nil, nil
+	}
+
+	uc := &chat.UserContext{
+		SearchRadiusKM:      session.SearchRadiusKM,
+		PreferredActivities: session.PreferredActivities,
+		DifficultyLevel:     session.DifficultyLevel,
+		TransportMode:       session.TransportMode,
+	}
+	if session.LocationLat != nil && session.LocationLng != nil {
+		uc.Location = &models.Location{Lat: *session.LocationLat, Lng: *session.LocationLng}
+	}
+	return uc, nil
+}
+
+// record makes the run's conversation the session's current one and saves the run's chat context
+func (s *SessionService) record(ctx context.Context, run *chat.Run) error {
+	changes := map[string]interface{}{"conversation_id": run.ConversationID}
+	if uc := run.UserContext; uc != nil {
+		changes["location_lat"], changes["location_lng"] = nil, nil
+		if uc.Location != nil {
+			changes["location_lat"], changes["location_lng"] = uc.Location.Lat, uc.Location.Lng
+		}
+		changes["search_radius_km"] = uc.SearchRadiusKM
+		changes["preferred_activities"] = models.StringList(uc.PreferredActivities)
+		changes["difficulty_level"] = uc.DifficultyLevel
+		changes["transport_mode"] = uc.TransportMode
+	}
+	if err := s.db.WithContext(ctx).Model(&models.Session{}).Where("id = ?", run.SessionID).Updates(changes).Error; err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired deletes expired sessions and returns how many were deleted.
+// Their conversations are kept.
+func (s *SessionService) DeleteExpired(ctx context.Context) (int64, error) {
+	result := s.db.WithContext(ctx).Where("expires_at <= ?", time.Now()).Delete(&models.Session{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// hashSessionToken returns the stored form of a session token
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/cache"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ShortLinkService hands out short links to activities for printed QR codes,
+// resolves them to the frontend and counts the scans
+type ShortLinkService struct {
+	db          *gorm.DB
+	claims      cache.Claimer
+	baseURL     string
+	frontendURL string
+}
+
+// NewShortLinkService creates a new short link service. Links are absolute when
+// baseURL, the public URL of this API, is set; they resolve to the activity's
+// page at frontendURL. Scans are counted once per client and day through
+// claims, or every time when claims is nil.
+func NewShortLinkService(db *gorm.DB, claims cache.Claimer, baseURL, frontendURL string) *ShortLinkService {
+	return &ShortLinkService{
+		db:          db,
+		claims:      claims,
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		frontendURL: strings.TrimRight(frontendURL, "/"),
+	}
+}
+
+// Link returns the short link of an approved activity, creating it on first use
+func (s *ShortLinkService) Link(ctx context.Context, activityID uint) (*models.ShortLink, error) {
+	if err := s.db.WithContext(ctx).Select("id").Where("approved = ?", true).
+		First(&models.Activity{}, activityID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load activity %d: %w", activityID, err)
+	}
+
+	secret := make([]byte, 6)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate short link code: %w", err)
+	}
+	link := models.ShortLink{Code: base64.RawURLEncoding.EncodeToString(secret), ActivityID: activityID}
+	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "activity_id"}},
+		DoNothing: true,
+	}).Create(&link).Error; err != nil {
+		return nil, fmt.Errorf("failed to create short link for activity %d: %w", activityID, err)
+	}
+	if err := s.db.WithContext(ctx).Where("activity_id = ?", activityID).First(&link).Error; err != nil {
+		return nil, fmt.Errorf("failed to load short link of activity %d: %w", activityID, err)
+	}
+	link.URL = s.baseURL + "/a/" + link.Code
+	return &link, nil
+}
+
+// Resolve records a scan of a short link by a client, e.g. its IP, and
+// returns the frontend URL of its activity. Repeated scans of a client on the
+// same day are not counted. Links of activities that were deleted or
+// unpublished are not found.
+func (s *ShortLinkService) Resolve(ctx context.Context, code, client string) (string, error) {
+	var link models.ShortLink
+	if err := s.db.WithContext(ctx).
+		Joins("JOIN activities ON activities.id = short_links.activity_id AND activities.deleted_at IS NULL AND activities.approved").
+		Where("short_links.code = ?", code).
+		First(&link).Error; err != nil {
+		return "", fmt.Errorf("failed to load short link %s: %w", code, err)
+	}
+
+	url := fmt.Sprintf("%s/activities/%d", s.frontendURL, link.ActivityID)
+	if !firstToday(ctx, s.claims, fmt.Sprintf("scan:%d", link.ID), client) {
+		return url, nil
+	}
+	now := time.Now()
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&link).UpdateColumns(map[string]interface{}{
+			"scans":           gorm.Expr("scans + 1"),
+			"last_scanned_at": now,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to count scan of short link %s: %w", code, err)
+		}
+		scan := models.ActivityScan{ActivityID: link.ActivityID, Day: now.UTC().Truncate(24 * time.Hour), Scans: 1}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "activity_id"}, {Name: "day"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"scans": gorm.Expr("activity_scans.scans + 1")}),
+		}).Create(&scan).Error; err != nil {
+			return fmt.Errorf("failed to count scan of activity %d: %w", link.ActivityID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return url, nil
+}
+
+// firstToday reports whether a client does something, such as scanning a
+// link, for the first time today (UTC), so interest counters cannot be driven
+// by repeating requests. Clients are hashed before they are stored. Without
+// claims, or when the store fails, everything counts.
+func firstToday(ctx context.Context, claims cache.Claimer, subject, client string) bool {
+	if claims == nil {
+		return true
+	}
+	now := time.Now().UTC()
+	day := now.Truncate(24 * time.Hour)
+	hash := sha256.Sum256([]byte(client))
+	key := fmt.Sprintf("interest:%s:%s:%s", subject, day.Format("2006-01-02"), hex.EncodeToString(hash[:8]))
+	claimed, err := claims.SetIfAbsent(ctx, key, []byte{1}, day.Add(24*time.Hour).Sub(now))
+	if err != nil {
+		log.Printf("[STATS] Dedupe store failed, counting %s: %v", subject, err)
+		return true
+	}
+	return claimed
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/geo"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// SimilarityWeights controls how content and proximity contribute to the similarity score
+type SimilarityWeights struct {
+	Content       float64
+	Proximity     float64
+	MaxDistanceKM float64
+}
+
+// SimilarActivity is an activity with its similarity score to the source activity
+type SimilarActivity struct {
+	models.Activity
+	Score      float64 `json:"score"`
+	DistanceKM float64 `json:"distance_km"`
+}
+
+// SimilarityService finds activities similar to a given activity
+type SimilarityService struct {
+	db      *gorm.DB
+	weights SimilarityWeights
+	// staleAfter marks activities in tool results outdated when they were not verified within it
+	staleAfter time.Duration
+}
+
+// maxSimilarityCandidates caps how many activities are scored per request
+const maxSimilarityCandidates = 500
+
+// Limits of the find_similar_activities chat tool
+const (
+	toolSimilarDefaultLimit = 5
+	toolSimilarMaxLimit     = 10
+)
+
+// NewSimilarityService creates a new similarity service
+func NewSimilarityService(db *gorm.DB, weights SimilarityWeights, staleAfter time.Duration) *SimilarityService {
+	if weights.MaxDistanceKM <= 0 {
+		weights.MaxDistanceKM = 50
+	}
+	return &SimilarityService{
+		db:         db,
+		weights:    weights,
+		staleAfter: staleAfter,
+	}
+}
+
+// FindSimilar returns up to limit approved activities most similar to the activity with the
+// given ID, skipping any IDs in exclude (e.g. activities the user already saved), the
+// activity's own seasonal variants and activities out of season
+func (s *SimilarityService) FindSimilar(ctx context.Context, id uint, limit int, exclude []uint) ([]SimilarActivity, error) {
+	var source models.Activity
+	if err := s.db.WithContext(ctx).First(&source, id).Error; err != nil {
+		return nil, fmt.Errorf("failed to load activity %d: %w", id, err)
+	}
+
+	// Candidates share the category or lie within the proximity radius
+	minLat, maxLat, minLng, maxLng := geo.BoundingBox(source.Latitude, source.Longitude, s.weights.MaxDistanceKM)
+	query := s.db.WithContext(ctx).
+		Where("COALESCE(variant_of, id) <> ? AND approved = ?", source.GroupID(), true).
+		Where(s.db.Where("category = ?", source.Category).
+			Or("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?", minLat, maxLat, minLng, maxLng))
+	if len(exclude) > 0 {
+		query = query.Where("id NOT IN ?", exclude)
+	}
+	query = inSeason(query, time.Now())
+
+	var candidates []models.Activity
+	if err := query.Limit(maxSimilarityCandidates).Find(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("failed to load similarity candidates: %w", err)
+	}
+
+	sourceTerms := termFrequencies(source)
+	results := make([]SimilarActivity, 0, len(candidates))
+	for _, candidate := range candidates {
+		distance := geo.DistanceKM(source.Latitude, source.Longitude, candidate.Latitude, candidate.Longitude)
+		proximity := math.Max(0, 1-distance/s.weights.MaxDistanceKM)
+		content := cosineSimilarity(sourceTerms, termFrequencies(candidate))
+
+		results = append(results, SimilarActivity{
+			Activity:   candidate,
+			Score:      s.weights.Content*content + s.weights.Proximity*proximity,
+			DistanceKM: math.Round(distance*10) / 10,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// Tool returns the find_similar_activities chat tool
+func (s *SimilarityService) Tool() chat.Tool {
+	return chat.Tool{
+		Name: "find_similar_activities",
+		Description: "Find approved activities similar to one, by content and proximity, leaving out the ones the signed-in user already saved. " +
+			"Use it to offer alternatives when the user liked an activity or one is closed, out of season or not suitable.",
+		Parameters: json.RawMessage(fmt.Sprintf(`{
+			"type": "object",
+			"properties": {
+				"activity_id": {"type": "integer"},
+				"limit": {"type": "integer", "minimum": 1, "maximum": %d}
+			},
+			"required": ["activity_id"]
+		}`, toolSimilarMaxLimit)),
+		Call: s.similarActivities,
+	}
+}
+
+// similarActivities implements the find_similar_activities tool
+func (s *SimilarityService) similarActivities(ctx context.Context, run *chat.Run, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		ActivityID uint `json:"activity_id"`
+		Limit      int  `json:"limit"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, err)
+	}
+	if args.ActivityID == 0 {
+		return nil, fmt.Errorf("%w: activity_id is required", chat.ErrInvalidToolArguments)
+	}
+	if args.Limit <= 0 || args.Limit > toolSimilarMaxLimit {
+		args.Limit = toolSimilarDefaultLimit
+	}
+
+	var exclude []uint
+	if run.UserID != 0 {
+		var err error
+		if exclude, err = NewFavoriteService(s.db).IDs(ctx, run.UserID); err != nil {
+			return nil, err
+		}
+	}
+	similar, err := s.FindSimilar(ctx, args.ActivityID, args.Limit, exclude)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]toolActivity, len(similar))
+	for i, activity := range similar {
+		results[i] = newToolActivity(activity.Activity, s.staleAfter)
+		distance := activity.DistanceKM
+		results[i].DistanceKM = &distance
+	}
+	return results, nil
+}
+
+// termFrequencies builds a lower-cased bag of words from the activity's descriptive fields
+func termFrequencies(a models.Activity) map[string]float64 {
+	text := strings.Join([]string{a.Name, a.Description, a.Category, a.Difficulty, a.BestSeason}, " ")
+	terms := make(map[string]float64)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if len(word) > 2 {
+			terms[word]++
+		}
+	}
+	return terms
+}
+
+// cosineSimilarity returns the cosine similarity of two term-frequency vectors
+func cosineSimilarity(a, b map[string]float64) float64 {
+	var dot, normA, normB float64
+	for term, weight := range a {
+		dot += weight * b[term]
+		normA += weight * weight
+	}
+	for _, weight := range b {
+		normB += weight * weight
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
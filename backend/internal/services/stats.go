@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"community-chatbot/internal/cache"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/residency"
+
+	"gorm.io/gorm"
+)
+
+// CommunityStats are public counts of the community's published content
+type CommunityStats struct {
+	Activities   int64   `json:"activities"`
+	Routes       int64   `json:"routes"`
+	Contributors int64   `json:"contributors"`
+	TrailKM      float64 `json:"trail_km"`
+	Photos       int64   `json:"photos"`
+	// UpdatedAt is when the counts were computed
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StatsService computes community statistics. Counting scans several tables,
+// so the latest counts of each region are kept in memory and refreshed
+// periodically instead of computed per request, and summaries of areas are cached.
+type StatsService struct {
+	db    *gorm.DB
+	areas *cache.Cache
+	// current holds the latest counts by region
+	current map[string]*CommunityStats
+	mutex   sync.Mutex
+}
+
+// NewStatsService creates a new stats service caching area summaries in areas
+func NewStatsService(db *gorm.DB, areas *cache.Cache) *StatsService {
+	return &StatsService{
+		db:      db,
+		areas:   areas,
+		current: make(map[string]*CommunityStats),
+	}
+}
+
+// Get returns the latest counts of the region of ctx, computing them when they
+// have not been yet
+func (s *StatsService) Get(ctx context.Context) (*CommunityStats, error) {
+	s.mutex.Lock()
+	current := s.current[residency.Region(ctx)]
+	s.mutex.Unlock()
+	if current != nil {
+		return current, nil
+	}
+	return s.Refresh(ctx)
+}
+
+// Refresh computes the counts of the region of ctx and keeps them for Get. Only approved activities
+// and their routes and approved images are counted; contributors are the users
+// who submitted, photographed or reviewed any of them.
+func (s *StatsService) Refresh(ctx context.Context) (*CommunityStats, error) {
+	db := s.db.WithContext(ctx)
+	approved := db.Table("activities").Select("id").Where("approved = ? AND deleted_at IS NULL", true)
+
+	var stats CommunityStats
+	if err := db.Table("activities").Where("approved = ? AND deleted_at IS NULL", true).
+		Count(&stats.Activities).Error; err != nil {
+		return nil, fmt.Errorf("failed to count activities: %w", err)
+	}
+
+	var routes struct {
+		Count int64
+		KM    float64
+	}
+	if err := db.Table("routes").Select("COUNT(*) AS count, COALESCE(SUM(distance_km), 0) AS km").
+		Where("activity_id IN (?) AND deleted_at IS NULL", approved).
+		Scan(&routes).Error; err != nil {
+		return nil, fmt.Errorf("failed to count routes: %w", err)
+	}
+	stats.Routes, stats.TrailKM = routes.Count, math.Round(routes.KM*10)/10
+
+	if err := db.Table("images").
+		Where("activity_id IN (?) AND approved = ? AND status = ? AND deleted_at IS NULL", approved, true, "ready").
+		Count(&stats.Photos).Error; err != nil {
+		return nil, fmt.Errorf("failed to count photos: %w", err)
+	}
+
+	if err := db.Raw(`SELECT COUNT(DISTINCT user_id) FROM (
+			SELECT user_id FROM activities WHERE approved AND deleted_at IS NULL
+			UNION SELECT user_id FROM images WHERE approved AND deleted_at IS NULL AND activity_id IN (?)
+			UNION SELECT user_id FROM reviews WHERE deleted_at IS NULL AND activity_id IN (?)
+		) AS contributors WHERE user_id <> 0`, approved, approved).
+		Scan(&stats.Contributors).Error; err != nil {
+		return nil, fmt.Errorf("failed to count contributors: %w", err)
+	}
+
+	stats.UpdatedAt = time.Now().UTC()
+	s.mutex.Lock()
+	s.current[residency.Region(ctx)] = &stats
+	s.mutex.Unlock()
+	return &stats, nil
+}
+
+// StartRefresh refreshes the counts of the region of ctx every interval until
+// ctx is cancelled
+func (s *StatsService) StartRefresh(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("[STATS] Refresh of region %q failed: %v", residency.Region(ctx), err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tool returns the chat tool answering questions about the community's size
+func (s *StatsService) Tool() chat.Tool {
+	return chat.Tool{
+		Name: "get_community_stats",
+		Description: "Get how many approved activities, routes, photos and contributors the community has, and the total length of its routes in km. " +
+			"Use it for questions such as \"how many trails do you know about?\".",
+		Parameters: json.RawMessage(`{"type": "object", "properties": {}}`),
+		Call: func(ctx context.Context, _ *chat.Run, _ json.RawMessage) (interface{}, error) {
+			return s.Get(ctx)
+		},
+	}
+}
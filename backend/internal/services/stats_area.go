@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/cache"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/geo"
+	"community-chatbot/internal/residency"
+
+	"gorm.io/gorm"
+)
+
+// maxAreaSpanKM caps the height and width of summarized areas, which are
+// aggregated over all activities within them. It fits the largest radius the
+// endpoint and the chat tool accept.
+const maxAreaSpanKM = 450
+
+// areaPrecision is the grid in degrees areas are widened to, about 1 km, so
+// nearby requests share cached summaries
+const areaPrecision = 0.01
+
+// ErrInvalidArea is returned for bounding boxes that are out of range or empty
+var ErrInvalidArea = apperr.New(apperr.Invalid, "bbox must be min_lng,min_lat,max_lng,max_lat with the minimums below the maximums")
+
+// ErrAreaTooLarge is returned for bounding boxes spanning more than maxAreaSpanKM
+var ErrAreaTooLarge = apperr.New(apperr.Invalid, fmt.Sprintf("bbox must be at most %d km high and wide", maxAreaSpanKM))
+
+// elevationBuckets are the lower bounds in meters of the elevation gain ranges
+// routes are counted in; the last range is open
+var elevationBuckets = []int{0, 250, 500, 1000}
+
+// Area is a bounding box in degrees
+type Area struct {
+	MinLat float64
+	MinLng float64
+	MaxLat float64
+	MaxLng float64
+}
+
+// AreaAround returns the bounding box enclosing a radius around a point, cut
+// off at the poles and the antimeridian
+func AreaAround(lat, lng, radiusKM float64) Area {
+	minLat, maxLat, minLng, maxLng := geo.BoundingBox(lat, lng, radiusKM)
+	return Area{
+		MinLat: math.Max(minLat, -90),
+		MinLng: math.Max(minLng, -180),
+		MaxLat: math.Min(maxLat, 90),
+		MaxLng: math.Min(maxLng, 180),
+	}
+}
+
+// Validate checks that the area is within range, not empty and at most
+// maxAreaSpanKM high and wide. Areas across the antimeridian are not supported.
+func (a Area) Validate() error {
+	if a.MinLat < -90 || a.MaxLat > 90 || a.MinLng < -180 || a.MaxLng > 180 ||
+		a.MinLat >= a.MaxLat || a.MinLng >= a.MaxLng {
+		return ErrInvalidArea
+	}
+
+	// The width is measured at the middle latitude, as AreaAround spans it
+	kmPerDegree := geo.EarthRadiusKM * math.Pi / 180
+	height := (a.MaxLat - a.MinLat) * kmPerDegree
+	width := (a.MaxLng - a.MinLng) * kmPerDegree * math.Cos((a.MinLat+a.MaxLat)/2*math.Pi/180)
+	if height > maxAreaSpanKM || width > maxAreaSpanKM {
+		return ErrAreaTooLarge
+	}
+	return nil
+}
+
+// AreaStats summarize the published activities and routes within an area
+type AreaStats struct {
+	// BBox is the area as [min lng, min lat, max lng, max lat], like GeoJSON
+	BBox       []float64       `json:"bbox"`
+	Activities int64           `json:"activities"`
+	Categories []CategoryCount `json:"categories"`
+	Routes     int64           `json:"routes"`
+	TrailKM    float64         `json:"trail_km"`
+	// RouteTypes is the length of the routes of each type, e.g. hiking or cycling
+	RouteTypes    []RouteTypeLength     `json:"route_types"`
+	Difficulty    []DifficultyCount     `json:"difficulty"`
+	ElevationGain []ElevationGainBucket `json:"elevation_gain"`
+	// UpdatedAt is when the summary was computed
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CategoryCount is the number of activities of a category
+type CategoryCount struct {
+	Category   string `json:"category"`
+	Activities int64  `json:"activities"`
+}
+
+// RouteTypeLength is the number and total length of the routes of a type
+type RouteTypeLength struct {
+	RouteType string  `json:"route_type"`
+	Routes    int64   `json:"routes"`
+	KM        float64 `json:"km"`
+}
+
+// DifficultyCount is the number of routes of a difficulty level
+type DifficultyCount struct {
+	Difficulty string `json:"difficulty"`
+	Routes     int64  `json:"routes"`
+}
+
+// ElevationGainBucket is the number of routes climbing at least MinM and less
+// than MaxM meters; MaxM is omitted for the last bucket
+type ElevationGainBucket struct {
+	MinM   int   `json:"min_m"`
+	MaxM   *int  `json:"max_m,omitempty"`
+	Routes int64 `json:"routes"`
+}
+
+// Area summarizes the approved activities within an area and their routes.
+// Routes are placed at their activity's location. Summaries are cached for
+// the areas cache's TTL, so they may lag recent changes; the bounds are
+// widened to the areaPrecision grid so nearby requests share them.
+func (s *StatsService) Area(ctx context.Context, area Area) (*AreaStats, error) {
+	if err := area.Validate(); err != nil {
+		return nil, err
+	}
+	area = Area{
+		MinLat: math.Max(math.Floor(area.MinLat/areaPrecision)*areaPrecision, -90),
+		MinLng: math.Max(math.Floor(area.MinLng/areaPrecision)*areaPrecision, -180),
+		MaxLat: math.Min(math.Ceil(area.MaxLat/areaPrecision)*areaPrecision, 90),
+		MaxLng: math.Min(math.Ceil(area.MaxLng/areaPrecision)*areaPrecision, 180),
+	}
+	key := fmt.Sprintf("%s:%.2f,%.2f,%.2f,%.2f", residency.Region(ctx), area.MinLng, area.MinLat, area.MaxLng, area.MaxLat)
+	return cache.Lookup(ctx, s.areas, key, func(ctx context.Context) (*AreaStats, error) {
+		return s.countArea(ctx, area)
+	})
+}
+
+// countArea computes the summary of Area with SQL aggregates
+func (s *StatsService) countArea(ctx context.Context, area Area) (*AreaStats, error) {
+	db := s.db.WithContext(ctx)
+	activities := func() *gorm.DB {
+		return db.Table("activities").
+			Where("approved = ? AND deleted_at IS NULL", true).
+			Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?", area.MinLat, area.MaxLat, area.MinLng, area.MaxLng)
+	}
+	routes := func() *gorm.DB {
+		return db.Table("routes").Where("activity_id IN (?) AND deleted_at IS NULL", activities().Select("id"))
+	}
+
+	stats := AreaStats{
+		BBox:          []float64{area.MinLng, area.MinLat, area.MaxLng, area.MaxLat},
+		Categories:    []CategoryCount{},
+		RouteTypes:    []RouteTypeLength{},
+		Difficulty:    []DifficultyCount{},
+		ElevationGain: make([]ElevationGainBucket, len(elevationBuckets)),
+	}
+
+	category := "COALESCE(NULLIF(category, ''), 'other')"
+	if err := activities().
+		Select(category + " AS category, COUNT(*) AS activities").
+		Group(category).Order("activities DESC, category").
+		Scan(&stats.Categories).Error; err != nil {
+		return nil, fmt.Errorf("failed to count activities by category: %w", err)
+	}
+	for _, category := range stats.Categories {
+		stats.Activities += category.Activities
+	}
+
+	var types []struct {
+		RouteType string
+		Routes    int64
+		KM        float64
+	}
+	routeType := "COALESCE(NULLIF(route_type, ''), 'other')"
+	if err := routes().
+		Select(routeType + " AS route_type, COUNT(*) AS routes, COALESCE(SUM(distance_km), 0) AS km").
+		Group(routeType).Order("km DESC, route_type").
+		Scan(&types).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum routes by type: %w", err)
+	}
+	var km float64
+	for _, t := range types {
+		stats.Routes += t.Routes
+		km += t.KM
+		stats.RouteTypes = append(stats.RouteTypes, RouteTypeLength{RouteType: t.RouteType, Routes: t.Routes, KM: math.Round(t.KM*10) / 10})
+	}
+	stats.TrailKM = math.Round(km*10) / 10
+
+	difficulty := "COALESCE(NULLIF(difficulty, ''), 'unknown')"
+	if err := routes().
+		Select(difficulty + " AS difficulty, COUNT(*) AS routes").
+		Group(difficulty).Order("routes DESC, difficulty").
+		Scan(&stats.Difficulty).Error; err != nil {
+		return nil, fmt.Errorf("failed to count routes by difficulty: %w", err)
+	}
+
+	// Routes are counted in the highest bucket whose lower bound they reach
+	bucket := "CASE"
+	for i := len(elevationBuckets) - 1; i > 0; i-- {
+		bucket += fmt.Sprintf(" WHEN elevation_gain_m >= %d THEN %d", elevationBuckets[i], i)
+	}
+	bucket += " ELSE 0 END"
+	var counts []struct {
+		Bucket int
+		Routes int64
+	}
+	if err := routes().Select(bucket + " AS bucket, COUNT(*) AS routes").Group(bucket).
+		Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("failed to count routes by elevation gain: %w", err)
+	}
+	for i, min := range elevationBuckets {
+		stats.ElevationGain[i].MinM = min
+		if i+1 < len(elevationBuckets) {
+			max := elevationBuckets[i+1]
+			stats.ElevationGain[i].MaxM = &max
+		}
+	}
+	for _, count := range counts {
+		if count.Bucket >= 0 && count.Bucket < len(elevationBuckets) {
+			stats.ElevationGain[count.Bucket].Routes = count.Routes
+		}
+	}
+
+	stats.UpdatedAt = time.Now().UTC()
+	return &stats, nil
+}
+
+// AreaTool returns the chat tool summarizing the activities and routes around a location
+func (s *StatsService) AreaTool() chat.Tool {
+	return chat.Tool{
+		Name: "get_area_stats",
+		Description: "Summarize the approved activities and routes around a location: activities per category, total route length in km per route type, " +
+			"routes per difficulty and per elevation gain range. Use it for questions such as \"how many km of cycling routes are around here?\". " +
+			"Route types are only hiking, cycling and driving, so it cannot tell trail surfaces such as singletrack apart. " +
+			"The user's location is used when lat and lng are omitted.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"lat": {"type": "number"},
+				"lng": {"type": "number"},
+				"radius_km": {"type": "number", "description": "Radius around the location, default 25, at most 200"}
+			}
+		}`),
+		Call: s.getAreaStats,
+	}
+}
+
+// getAreaStats implements the get_area_stats tool
+func (s *StatsService) getAreaStats(ctx context.Context, run *chat.Run, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		Lat      *float64 `json:"lat"`
+		Lng      *float64 `json:"lng"`
+		RadiusKM float64  `json:"radius_km"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, err)
+	}
+	if args.RadiusKM <= 0 {
+		args.RadiusKM = 25
+	}
+	if args.RadiusKM > 200 {
+		return nil, fmt.Errorf("%w: radius_km must be at most 200", chat.ErrInvalidToolArguments)
+	}
+
+	var lat, lng float64
+	switch {
+	case args.Lat != nil && args.Lng != nil:
+		lat, lng = *args.Lat, *args.Lng
+	case run.UserContext != nil && run.UserContext.Location != nil:
+		lat, lng = run.UserContext.Location.Lat, run.UserContext.Location.Lng
+	default:
+		return nil, fmt.Errorf("%w: lat and lng are required without the user's location", chat.ErrInvalidToolArguments)
+	}
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return nil, fmt.Errorf("%w: lat and lng are out of range", chat.ErrInvalidToolArguments)
+	}
+
+	return s.Area(ctx, AreaAround(lat, lng, args.RadiusKM))
+}
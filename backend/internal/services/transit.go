@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/cache"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/transit"
+
+	"gorm.io/gorm"
+)
+
+// transitDepartureBucket rounds departure times so nearby requests share cached plans
+const transitDepartureBucket = 5 * time.Minute
+
+// ErrTransitUnavailable is returned when no transit planner is configured
+var ErrTransitUnavailable = errors.New("transit directions are not configured")
+
+// TransitService plans public transit trips to activities
+type TransitService struct {
+	db      *gorm.DB
+	planner transit.Planner
+	cache   *cache.Cache
+}
+
+// NewTransitService creates a new transit service. planner may be nil, in which case
+// every lookup returns ErrTransitUnavailable; cache may be nil to disable caching.
+func NewTransitService(db *gorm.DB, planner transit.Planner, plans *cache.Cache) *TransitService {
+	return &TransitService{
+		db:      db,
+		planner: planner,
+		cache:   plans,
+	}
+}
+
+// Directions returns up to limit itineraries from a location to an approved activity,
+// departing at or after departAt. It returns transit.ErrNoRoute when there is no connection.
+func (s *TransitService) Directions(ctx context.Context, activityID uint, from models.Location, departAt time.Time, limit int) ([]transit.Itinerary, error) {
+	if s.planner == nil {
+		return nil, ErrTransitUnavailable
+	}
+
+	var activity models.Activity
+	if err := s.db.WithContext(ctx).
+		Select("id", "latitude", "longitude").
+		Where("approved = ?", true).
+		First(&activity, activityID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load activity %d: %w", activityID, err)
+	}
+
+	return s.plan(ctx, from, activity.GetLocation(), departAt, limit)
+}
+
+// plan looks up itineraries through the cache
+func (s *TransitService) plan(ctx context.Context, from, to models.Location, departAt time.Time, limit int) ([]transit.Itinerary, error) {
+	departAt = departAt.Truncate(transitDepartureBucket)
+	key := fmt.Sprintf("%.4f,%.4f>%.4f,%.4f@%d/%d", from.Lat, from.Lng, to.Lat, to.Lng, departAt.Unix(), limit)
+
+	return cache.Lookup(ctx, s.cache, key, func(ctx context.Context) ([]transit.Itinerary, error) {
+		itineraries, err := s.planner.Plan(ctx, transit.PlanRequest{
+			From:        transit.Point{Lat: from.Lat, Lng: from.Lng},
+			To:          transit.Point{Lat: to.Lat, Lng: to.Lng},
+			DepartAt:    departAt,
+			Itineraries: limit,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to plan transit trip: %w", err)
+		}
+		return itineraries, nil
+	})
+}
+
+// TransitNotes returns the next transit connections from a location to activities
+// mentioned by name in message, formatted as context the chat model can quote.
+// Times are given in timezone, the user's IANA time zone, or UTC when it is empty or unknown.
+func (s *TransitService) TransitNotes(ctx context.Context, message string, from models.Location, timezone string) ([]string, error) {
+	if s.planner == nil {
+		return nil, nil
+	}
+	loc := transitLocation(timezone)
+
+	var activities []models.Activity
+	if err := s.db.WithContext(ctx).
+		Where("approved = ? AND "+mentionsName, true, message).
+		Limit(2).
+		Find(&activities).Error; err != nil {
+		return nil, fmt.Errorf("failed to find mentioned activities: %w", err)
+	}
+
+	var notes []string
+	for _, activity := range activities {
+		itineraries, err := s.plan(ctx, from, activity.GetLocation(), time.Now().In(loc), 2)
+		if errors.Is(err, transit.ErrNoRoute) {
+			notes = append(notes, fmt.Sprintf("No public transit connection to %s (activity://%d) was found.", activity.Name, activity.ID))
+			continue
+		}
+		if err != nil {
+			return notes, err
+		}
+
+		options := make([]string, len(itineraries))
+		for i, it := range itineraries {
+			options[i] = formatItinerary(it, loc)
+		}
+		notes = append(notes, fmt.Sprintf("Public transit to %s (activity://%d), times in %s: %s",
+			activity.Name, activity.ID, loc, strings.Join(options, " Or: ")))
+	}
+	return notes, nil
+}
+
+// transitLocation returns the time zone transit times are given in: the named
+// zone, or UTC when it is empty or unknown
+func transitLocation(timezone string) *time.Location {
+	if timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// formatItinerary renders an itinerary in loc as "depart 08:12, arrive 09:05 (53 min, 1 transfer): bus 42 ..."
+func formatItinerary(it transit.Itinerary, loc *time.Location) string {
+	var legs []string
+	for _, leg := range it.Legs {
+		if leg.Mode == "walk" {
+			legs = append(legs, fmt.Sprintf("walk %.1f km to %s", leg.DistanceKM, leg.To))
+			continue
+		}
+		ride := leg.Mode
+		if leg.Line != "" {
+			ride += " " + leg.Line
+		}
+		if leg.Headsign != "" {
+			ride += " towards " + leg.Headsign
+		}
+		legs = append(legs, fmt.Sprintf("%s at %s from %s to %s", ride, leg.DepartAt.In(loc).Format("15:04"), leg.From, leg.To))
+	}
+
+	transfers := "no transfers"
+	if it.Transfers == 1 {
+		transfers = "1 transfer"
+	} else if it.Transfers > 1 {
+		transfers = fmt.Sprintf("%d transfers", it.Transfers)
+	}
+	return fmt.Sprintf("depart %s, arrive %s (%d min, %s): %s.",
+		it.DepartAt.In(loc).Format("15:04"), it.ArriveAt.In(loc).Format("15:04"), it.DurationMinutes, transfers, strings.Join(legs, ", then "))
+}
+
+// toolTransitItineraries is how many connections the transit tool returns
+const toolTransitItineraries = 2
+
+// toolTransit is the result of the get_transit_directions tool
+type toolTransit struct {
+	ActivityID uint     `json:"activity_id"`
+	Timezone   string   `json:"timezone"`
+	Options    []string `json:"options"`
+	Note       string   `json:"note,omitempty"`
+}
+
+// Tool returns the chat tool looking up public transit directions to an activity
+func (s *TransitService) Tool() chat.Tool {
+	return chat.Tool{
+		Name: "get_transit_directions",
+		Description: "Get the next public transit connections to an approved activity, with departure and arrival times in the user's time zone. " +
+			"Leave out lat and lng to start from the user's location.",
+		Parameters: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"activity_id": {"type": "integer"},
+				"lat": {"type": "number", "minimum": -90, "maximum": 90},
+				"lng": {"type": "number", "minimum": -180, "maximum": 180}
+			},
+			"required": ["activity_id"]
+		}`),
+		Call: s.transitDirections,
+	}
+}
+
+// transitDirections implements the get_transit_directions tool
+func (s *TransitService) transitDirections(ctx context.Context, run *chat.Run, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		ActivityID uint     `json:"activity_id"`
+		Lat        *float64 `json:"lat"`
+		Lng        *float64 `json:"lng"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, err)
+	}
+	if args.ActivityID == 0 {
+		return nil, fmt.Errorf("%w: activity_id is required", chat.ErrInvalidToolArguments)
+	}
+
+	var timezone string
+	var from *models.Location
+	if run.UserContext != nil {
+		timezone = run.UserContext.Timezone
+		from = run.UserContext.Location
+	}
+	if args.Lat != nil && args.Lng != nil {
+		from = &models.Location{Lat: *args.Lat, Lng: *args.Lng}
+	}
+	if from == nil {
+		return nil, fmt.Errorf("%w: lat and lng are required when the user's location is unknown", chat.ErrInvalidToolArguments)
+	}
+
+	loc := transitLocation(timezone)
+	result := toolTransit{ActivityID: args.ActivityID, Timezone: loc.String(), Options: []string{}}
+	itineraries, err := s.Directions(ctx, args.ActivityID, *from, time.Now().In(loc), toolTransitItineraries)
+	if errors.Is(err, transit.ErrNoRoute) {
+		result.Note = "No public transit connection was found."
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, it := range itineraries {
+		result.Options = append(result.Options, formatItinerary(it, loc))
+	}
+	return result, nil
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidTrustLevel is returned for unknown trust levels
+var ErrInvalidTrustLevel = apperr.New(apperr.Invalid, "trust level must be new, member, trusted or moderator")
+
+var trustChanges = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "trust_level_changes_total",
+	Help: "Changes of contributor trust levels by kind (promoted, demoted, set by an admin).",
+}, []string{"change"})
+
+// TrustThresholds configure how contributors advance and fall back
+type TrustThresholds struct {
+	// Member and Trusted are the approved contributions needed for each level
+	Member  int
+	Trusted int
+	// Violations are the rejected or removed contributions after which a
+	// contributor drops one level
+	Violations int
+}
+
+// TrustService keeps the trust levels of contributors: approved activities and
+// images advance them, rejected or revoked submissions and hidden reviews count
+// as violations. Trusted users and moderators skip pre-moderation. A nil
+// service trusts nobody and records nothing.
+type TrustService struct {
+	db         *gorm.DB
+	thresholds TrustThresholds
+}
+
+// NewTrustService creates a new trust service
+func NewTrustService(db *gorm.DB, thresholds TrustThresholds) *TrustService {
+	return &TrustService{
+		db:         db,
+		thresholds: thresholds,
+	}
+}
+
+// SkipsModeration reports whether submissions of a user are published without
+// pre-moderation. Anonymous submissions (user 0) never are.
+func (s *TrustService) SkipsModeration(ctx context.Context, userID uint) (bool, error) {
+	if s == nil || userID == 0 {
+		return false, nil
+	}
+	var user models.User
+	if err := s.db.WithContext(ctx).Select("id", "trust_level").First(&user, userID).Error; err != nil {
+		return false, fmt.Errorf("failed to load trust level of user %d: %w", userID, err)
+	}
+	return user.SkipsModeration(), nil
+}
+
+// RecordApproval counts an approved contribution of a user and promotes them
+// when it earns a new level
+func (s *TrustService) RecordApproval(ctx context.Context, userID uint) error {
+	return s.update(ctx, userID, func(user *models.User) string {
+		user.ApprovedContributions++
+		earned := s.earned(user.ApprovedContributions)
+		if user.TrustPinned || rank(earned) <= rank(user.TrustLevel) {
+			return ""
+		}
+		user.TrustLevel = earned
+		return "promoted"
+	})
+}
+
+// RecordViolation counts a rejected or removed contribution of a user. After
+// the configured number of violations the user drops one level and has to
+// earn the lost one again; moderators and pinned levels are kept.
+func (s *TrustService) RecordViolation(ctx context.Context, userID uint) error {
+	return s.update(ctx, userID, func(user *models.User) string {
+		user.Violations++
+		if user.TrustPinned || user.Violations < s.thresholds.Violations ||
+			user.TrustLevel == models.TrustNew || user.TrustLevel == models.TrustModerator {
+			return ""
+		}
+		user.TrustLevel = models.TrustLevels[rank(user.TrustLevel)-1]
+		user.ApprovedContributions = s.required(user.TrustLevel)
+		user.Violations = 0
+		return "demoted"
+	})
+}
+
+// SetLevel sets the trust level of a user (admin override). Pinned levels are
+// kept until an admin unpins them; moderators are always pinned.
+func (s *TrustService) SetLevel(ctx context.Context, userID uint, level string, pinned bool) (*models.User, error) {
+	if !slices.Contains(models.TrustLevels, level) {
+		return nil, ErrInvalidTrustLevel
+	}
+	var user models.User
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, userID).Error; err != nil {
+			return fmt.Errorf("failed to load user %d: %w", userID, err)
+		}
+		user.TrustLevel = level
+		user.TrustPinned = pinned || level == models.TrustModerator
+		user.Violations = 0
+		// Unpinned users keep the level unless they earn a higher one or lose it
+		user.ApprovedContributions = max(user.ApprovedContributions, s.required(level))
+		if err := tx.Model(&user).Select("TrustLevel", "TrustPinned", "Violations", "ApprovedContributions").Updates(&user).Error; err != nil {
+			return fmt.Errorf("failed to set trust level of user %d: %w", userID, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	trustChanges.WithLabelValues("set").Inc()
+	log.Printf("[TRUST] User %d set to %s (pinned %t)", userID, user.TrustLevel, user.TrustPinned)
+	return &user, nil
+}
+
+// update applies change to a user's trust counters under a row lock, so
+// concurrent moderation decisions are all counted. change returns the kind of
+// level change, or "" when the level stayed.
+func (s *TrustService) update(ctx context.Context, userID uint, change func(user *models.User) string) error {
+	if s == nil || userID == 0 {
+		return nil
+	}
+	var user models.User
+	var changed string
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+			return fmt.Errorf("failed to load user %d: %w", userID, err)
+		}
+		changed = change(&user)
+		if err := tx.Model(&user).Select("TrustLevel", "ApprovedContributions", "Violations").Updates(&user).Error; err != nil {
+			return fmt.Errorf("failed to update trust of user %d: %w", userID, err)
+		}
+		return nil
+	})
+	if err != nil || changed == "" {
+		return err
+	}
+
+	trustChanges.WithLabelValues(changed).Inc()
+	log.Printf("[TRUST] User %d %s to %s", userID, changed, user.TrustLevel)
+	return nil
+}
+
+// earned returns the level earned by a number of approved contributions
+func (s *TrustService) earned(contributions int) string {
+	switch {
+	case contributions >= s.thresholds.Trusted:
+		return models.TrustTrusted
+	case contributions >= s.thresholds.Member:
+		return models.TrustMember
+	}
+	return models.TrustNew
+}
+
+// required returns the approved contributions a level is earned with
+func (s *TrustService) required(level string) int {
+	switch level {
+	case models.TrustMember:
+		return s.thresholds.Member
+	case models.TrustTrusted, models.TrustModerator:
+		return s.thresholds.Trusted
+	}
+	return 0
+}
+
+// rank returns the position of a trust level, 0 for new and unknown levels
+func rank(level string) int {
+	return max(slices.Index(models.TrustLevels, level), 0)
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"community-chatbot/internal/agui"
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+// Groupings of usage reports
+const (
+	UsageByDay          = "day"
+	UsageByUser         = "user"
+	UsageByConversation = "conversation"
+	UsageByModel        = "model"
+)
+
+// maxUsageGroups caps the groups of a usage report, most expensive first
+const maxUsageGroups = 100
+
+// ErrInvalidUsageGrouping is returned for unknown group_by values of usage reports
+var ErrInvalidUsageGrouping = apperr.New(apperr.Invalid, "group_by must be day, user, conversation or model")
+
+var (
+	llmTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "llm_tokens_total",
+		Help: "Tokens used by chat answers, by model and kind (prompt, completion).",
+	}, []string{"model", "kind"})
+	llmEstimatedCost = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "llm_estimated_cost_usd_total",
+		Help: "Estimated cost of chat answers in USD at the configured prices, by model.",
+	}, []string{"model"})
+)
+
+// UsageService reports the token usage and estimated cost of chat answers and
+// stores it per conversation and user for cost monitoring
+type UsageService struct {
+	db              *gorm.DB
+	model           string
+	promptPrice     float64
+	completionPrice float64
+}
+
+// NewUsageService creates a new usage service for a model priced in USD per
+// million tokens. Without a database, usage is reported but not stored.
+func NewUsageService(db *gorm.DB, model string, promptPrice, completionPrice float64) *UsageService {
+	return &UsageService{
+		db:              db,
+		model:           model,
+		promptPrice:     promptPrice,
+		completionPrice: completionPrice,
+	}
+}
+
+// Cost estimates the cost in USD of a number of prompt and completion tokens
+func (s *UsageService) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*s.promptPrice + float64(completionTokens)*s.completionPrice) / 1e6
+}
+
+// Stage returns the pipeline stage that ends each run that called the model
+// with a USAGE_REPORT event and stores its usage. It is registered outermost so
+// it sees the usage of every completion, including those of failed runs.
+// Usage of users who revoked consent to analytics is stored as anonymous.
+// Storage failures are logged and never fail the run.
+func (s *UsageService) Stage() chat.Stage {
+	return chat.StageFunc{
+		StageName: "usage",
+		Fn: func(ctx context.Context, run *chat.Run, next chat.Handler) error {
+			err := next(ctx, run)
+			if run.PromptTokens == 0 && run.CompletionTokens == 0 {
+				return err
+			}
+
+			cost := s.Cost(run.PromptTokens, run.CompletionTokens)
+			llmTokens.WithLabelValues(s.model, "prompt").Add(float64(run.PromptTokens))
+			llmTokens.WithLabelValues(s.model, "completion").Add(float64(run.CompletionTokens))
+			llmEstimatedCost.WithLabelValues(s.model).Add(cost)
+
+			if ctx.Err() == nil {
+				if emitErr := run.Emit(agui.CreateUsageReportEvent(agui.UsageReportData{
+					Model:            s.model,
+					PromptTokens:     run.PromptTokens,
+					CompletionTokens: run.CompletionTokens,
+					EstimatedCostUSD: cost,
+				})); emitErr != nil {
+					log.Printf("[USAGE] Run %s: failed to send usage report: %v", run.ID, emitErr)
+				}
+			}
+
+			if s.db != nil {
+				usage := &models.LLMUsage{
+					ConversationID:   run.ConversationID,
+					RunID:            run.ID,
+					Model:            s.model,
+					PromptTokens:     run.PromptTokens,
+					CompletionTokens: run.CompletionTokens,
+					EstimatedCostUSD: cost,
+				}
+				// The run's context may be cancelled by a disconnect, so it is not used for storing
+				storeCtx := context.WithoutCancel(ctx)
+				if run.UserID != 0 {
+					attributed, consentErr := NewConsentService(s.db).Granted(storeCtx, run.UserID, models.ConsentAnalytics)
+					if consentErr != nil {
+						log.Printf("[USAGE] Run %s: %v", run.ID, consentErr)
+					}
+					if attributed {
+						usage.UserID = &run.UserID
+					}
+				}
+				if storeErr := s.db.WithContext(storeCtx).Create(usage).Error; storeErr != nil {
+					log.Printf("[USAGE] Run %s: failed to store usage: %v", run.ID, storeErr)
+				}
+			}
+			return err
+		},
+	}
+}
+
+// UsageReport sums the usage of chat answers in a time range
+type UsageReport struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	UsageTotals
+	// Groups split the totals by the requested grouping, most expensive first
+	GroupBy string       `json:"group_by,omitempty"`
+	Groups  []UsageGroup `json:"groups,omitempty"`
+}
+
+// UsageTotals are the summed usage of a set of runs
+type UsageTotals struct {
+	Runs             int64   `json:"runs"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	EstimatedCostUSD float64 `json:"estimated_cost_usd"`
+}
+
+// UsageGroup is the usage of one day, user, conversation or model
+type UsageGroup struct {
+	Key string `json:"key"`
+	UsageTotals
+}
+
+// Report sums the usage stored in [from, to), grouped by groupBy unless it is empty
+func (s *UsageService) Report(ctx context.Context, from, to time.Time, groupBy string) (*UsageReport, error) {
+	var key string
+	switch groupBy {
+	case "":
+	case UsageByDay:
+		key = "TO_CHAR(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD')"
+	case UsageByUser:
+		key = "COALESCE(CAST(user_id AS TEXT), 'anonymous')"
+	case UsageByConversation:
+		key = "conversation_id"
+	case UsageByModel:
+		key = "model"
+	default:
+		return nil, ErrInvalidUsageGrouping
+	}
+
+	const totals = "COUNT(*) AS runs, COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, " +
+		"COALESCE(SUM(completion_tokens), 0) AS completion_tokens, COALESCE(SUM(estimated_cost_usd), 0) AS estimated_cost_usd"
+	query := func() *gorm.DB {
+		return s.db.WithContext(ctx).Model(&models.LLMUsage{}).
+			Where("created_at >= ? AND created_at < ?", from, to)
+	}
+
+	report := &UsageReport{From: from, To: to, GroupBy: groupBy}
+	if err := query().Select(totals).Scan(&report.UsageTotals).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum usage: %w", err)
+	}
+	if key == "" {
+		return report, nil
+	}
+
+	report.Groups = []UsageGroup{}
+	if err := query().Select(key + " AS key, " + totals).Group("key").
+		Order("estimated_cost_usd DESC").Limit(maxUsageGroups).Scan(&report.Groups).Error; err != nil {
+		return nil, fmt.Errorf("failed to group usage: %w", err)
+	}
+	return report, nil
+}
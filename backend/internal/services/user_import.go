@@ -0,0 +1,411 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// MaxUserImportRows is the most users one import may contain
+const MaxUserImportRows = 5000
+
+// ErrInvalidUserImport is returned for CSV files that cannot be read as user imports
+var ErrInvalidUserImport = apperr.New(apperr.Invalid, "invalid user import")
+
+// UserImport is a user and their preferences migrated from another platform.
+// Empty fields keep the current preference of existing users, or the default.
+type UserImport struct {
+	Email               string   `json:"email"`
+	Name                string   `json:"name"`
+	LocationLat         *float64 `json:"location_lat"`
+	LocationLng         *float64 `json:"location_lng"`
+	SearchRadiusKM      *int     `json:"search_radius_km"`
+	PreferredActivities []string `json:"preferred_activities"`
+	DifficultyLevel     string   `json:"difficulty_level"`
+	TransportMode       string   `json:"transport_mode"`
+	Timezone            string   `json:"timezone"`
+	DigestFrequency     string   `json:"digest_frequency"`
+}
+
+// UserImportError is a row that was not imported. Rows are numbered from 1, not
+// counting the CSV header.
+type UserImportError struct {
+	Row   int    `json:"row"`
+	Email string `json:"email"`
+	Error string `json:"error"`
+}
+
+// UserImportResult reports what an import did, or would do in a dry run
+type UserImportResult struct {
+	DryRun bool `json:"dry_run"`
+	Total  int  `json:"total"`
+	// Matched existing users had their preferences updated
+	Matched int `json:"matched"`
+	// Created accounts have no password until their invitation is accepted
+	Created int `json:"created"`
+	Invited int `json:"invited"`
+	// InviteFailed invitations could not be sent; importing again resends them
+	InviteFailed int               `json:"invite_failed"`
+	Invalid      int               `json:"invalid"`
+	Errors       []UserImportError `json:"errors,omitempty"`
+}
+
+// InvitationSender delivers an invitation to set a password (email, ...)
+type InvitationSender interface {
+	SendInvitation(ctx context.Context, user models.User, acceptURL string, expiresAt time.Time) error
+}
+
+// plannedUser is a valid imported user and the account it matched, if any
+type plannedUser struct {
+	imported UserImport
+	user     models.User
+	matched  bool
+}
+
+// pendingInvitation is an invitation created in the import transaction and sent after it
+type pendingInvitation struct {
+	user      models.User
+	token     string
+	expiresAt time.Time
+}
+
+// UserImportService imports users and their preferences when a community
+// migrates from another platform
+type UserImportService struct {
+	db        *gorm.DB
+	sender    InvitationSender
+	acceptURL string
+	ttl       time.Duration
+}
+
+// NewUserImportService creates a new user import service. Invitation links are
+// acceptURL with the token appended as the token query parameter and expire
+// after ttl.
+func NewUserImportService(db *gorm.DB, sender InvitationSender, acceptURL string, ttl time.Duration) *UserImportService {
+	return &UserImportService{
+		db:        db,
+		sender:    sender,
+		acceptURL: acceptURL,
+		ttl:       ttl,
+	}
+}
+
+// ParseUserImportCSV reads users from CSV with a header row naming the UserImport
+// JSON fields; email is required, other columns are optional. Preferred
+// activities are separated by semicolons.
+func ParseUserImportCSV(r io.Reader) ([]UserImport, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read header: %v", ErrInvalidUserImport, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		switch name {
+		case "email", "name", "location_lat", "location_lng", "search_radius_km", "preferred_activities",
+			"difficulty_level", "transport_mode", "timezone", "digest_frequency":
+			columns[name] = i
+		default:
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidUserImport, name)
+		}
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, fmt.Errorf("%w: email column required", ErrInvalidUserImport)
+	}
+
+	var users []UserImport
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return users, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidUserImport, err)
+		}
+		if len(users) == MaxUserImportRows {
+			return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidUserImport, MaxUserImportRows)
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		user := UserImport{
+			Email:           field("email"),
+			Name:            field("name"),
+			DifficultyLevel: field("difficulty_level"),
+			TransportMode:   field("transport_mode"),
+			Timezone:        field("timezone"),
+			DigestFrequency: field("digest_frequency"),
+		}
+		for _, activity := range strings.Split(field("preferred_activities"), ";") {
+			if activity = strings.TrimSpace(activity); activity != "" {
+				user.PreferredActivities = append(user.PreferredActivities, activity)
+			}
+		}
+		if user.LocationLat, err = parseOptionalFloat(field("location_lat")); err != nil {
+			return nil, fmt.Errorf("%w: row %d: invalid location_lat", ErrInvalidUserImport, row)
+		}
+		if user.LocationLng, err = parseOptionalFloat(field("location_lng")); err != nil {
+			return nil, fmt.Errorf("%w: row %d: invalid location_lng", ErrInvalidUserImport, row)
+		}
+		if radius := field("search_radius_km"); radius != "" {
+			value, err := strconv.Atoi(radius)
+			if err != nil {
+				return nil, fmt.Errorf("%w: row %d: invalid search_radius_km", ErrInvalidUserImport, row)
+			}
+			user.SearchRadiusKM = &value
+		}
+		users = append(users, user)
+	}
+}
+
+// Import matches users by email: existing users get the imported preferences,
+// unknown emails get an account without a password and an invitation to set
+// one. Invalid rows are reported and skipped. The import is all or nothing, except
+// for sending invitations, which happens once the users are stored. A dry run
+// only validates and matches.
+func (s *UserImportService) Import(ctx context.Context, users []UserImport, dryRun bool) (*UserImportResult, error) {
+	result := &UserImportResult{DryRun: dryRun, Total: len(users)}
+
+	valid := make(map[int]UserImport, len(users))
+	seen := make(map[string]bool, len(users))
+	emails := make([]string, 0, len(users))
+	for i, user := range users {
+		user.Email = normalizeEmail(user.Email)
+		problem := validateUserImport(&user)
+		if problem == "" && seen[user.Email] {
+			problem = "duplicate email"
+		}
+		if problem != "" {
+			result.Invalid++
+			result.Errors = append(result.Errors, UserImportError{Row: i + 1, Email: user.Email, Error: problem})
+			continue
+		}
+		seen[user.Email] = true
+		valid[i] = user
+		emails = append(emails, user.Email)
+	}
+
+	existing := make(map[string]models.User, len(emails))
+	var found []models.User
+	// Deleted accounts still hold their email, so they are matched too and reported
+	if len(emails) > 0 {
+		if err := s.db.WithContext(ctx).Unscoped().Where("email IN ?", emails).Find(&found).Error; err != nil {
+			return nil, fmt.Errorf("failed to match users: %w", err)
+		}
+	}
+	for _, user := range found {
+		existing[user.Email] = user
+	}
+
+	var planned []plannedUser
+	for i := range users {
+		imported, ok := valid[i]
+		if !ok {
+			continue
+		}
+		user, matched := existing[imported.Email]
+		if matched && user.DeletedAt.Valid {
+			result.Invalid++
+			result.Errors = append(result.Errors, UserImportError{Row: i + 1, Email: imported.Email, Error: "account was deleted"})
+			continue
+		}
+		if matched {
+			result.Matched++
+		} else {
+			result.Created++
+		}
+		planned = append(planned, plannedUser{imported: imported, user: user, matched: matched})
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	var invitations []pendingInvitation
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, p := range planned {
+			user := p.user
+			if !p.matched {
+				user = models.User{Email: p.imported.Email, Name: p.imported.Name}
+				if err := tx.Create(&user).Error; err != nil {
+					return fmt.Errorf("failed to create user %s: %w", p.imported.Email, err)
+				}
+			}
+			if err := importPreferences(tx, user.ID, p.imported); err != nil {
+				return err
+			}
+
+			// Users who never set a password are invited, again on later imports
+			if user.PasswordHash == "" {
+				invitation, err := s.createInvitation(tx, user)
+				if err != nil {
+					return err
+				}
+				invitations = append(invitations, invitation)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, invitation := range invitations {
+		link := s.acceptURL + "?token=" + url.QueryEscape(invitation.token)
+		if err := s.sender.SendInvitation(ctx, invitation.user, link, invitation.expiresAt); err != nil {
+			log.Printf("[INVITE] Failed to invite user %d: %v", invitation.user.ID, err)
+			result.InviteFailed++
+			continue
+		}
+		result.Invited++
+	}
+	return result, nil
+}
+
+// hashInvitationToken returns the stored form of an invitation token
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createInvitation stores a new invitation for user and returns it with its token
+func (s *UserImportService) createInvitation(tx *gorm.DB, user models.User) (pendingInvitation, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return pendingInvitation{}, fmt.Errorf("failed to generate invitation token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	invitation := models.Invitation{
+		UserID:    user.ID,
+		TokenHash: hashInvitationToken(token),
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+	if err := tx.Create(&invitation).Error; err != nil {
+		return pendingInvitation{}, fmt.Errorf("failed to create invitation for user %d: %w", user.ID, err)
+	}
+	return pendingInvitation{user: user, token: token, expiresAt: invitation.ExpiresAt}, nil
+}
+
+// importPreferences writes the non-empty imported preferences of a user
+func importPreferences(tx *gorm.DB, userID uint, imported UserImport) error {
+	prefs := models.UserPreferences{UserID: userID}
+	if err := tx.Where("user_id = ?", userID).FirstOrCreate(&prefs).Error; err != nil {
+		return fmt.Errorf("failed to load preferences of user %d: %w", userID, err)
+	}
+
+	changes := make(map[string]interface{})
+	if imported.LocationLat != nil && imported.LocationLng != nil {
+		changes["location_lat"] = *imported.LocationLat
+		changes["location_lng"] = *imported.LocationLng
+	}
+	if imported.SearchRadiusKM != nil {
+		changes["search_radius_km"] = *imported.SearchRadiusKM
+	}
+	if len(imported.PreferredActivities) > 0 {
+		changes["preferred_activities"] = models.StringList(imported.PreferredActivities)
+	}
+	if imported.DifficultyLevel != "" {
+		changes["difficulty_level"] = imported.DifficultyLevel
+	}
+	if imported.TransportMode != "" {
+		changes["transport_mode"] = imported.TransportMode
+	}
+	if imported.Timezone != "" {
+		changes["timezone"] = imported.Timezone
+	}
+	if imported.DigestFrequency != "" {
+		changes["digest_frequency"] = imported.DigestFrequency
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	if err := tx.Model(&prefs).Updates(changes).Error; err != nil {
+		return fmt.Errorf("failed to import preferences of user %d: %w", userID, err)
+	}
+	return nil
+}
+
+// validateUserImport checks an imported user, normalizing the transport mode,
+// and returns what is wrong with it or ""
+func validateUserImport(user *UserImport) string {
+	if address, err := mail.ParseAddress(user.Email); err != nil || address.Address != user.Email || len(user.Email) > 255 {
+		return "invalid email"
+	}
+	if len(user.Name) > 255 {
+		return "name longer than 255 characters"
+	}
+	if (user.LocationLat == nil) != (user.LocationLng == nil) {
+		return "location_lat and location_lng must be given together"
+	}
+	if user.LocationLat != nil && (*user.LocationLat < -90 || *user.LocationLat > 90 || *user.LocationLng < -180 || *user.LocationLng > 180) {
+		return "location out of range"
+	}
+	if user.SearchRadiusKM != nil && (*user.SearchRadiusKM < 0 || *user.SearchRadiusKM > 500) {
+		return "search_radius_km must be between 0 and 500"
+	}
+	if len(user.PreferredActivities) > 20 {
+		return "more than 20 preferred activities"
+	}
+	for _, activity := range user.PreferredActivities {
+		if len(activity) > 100 {
+			return "preferred activity longer than 100 characters"
+		}
+	}
+	if len(user.DifficultyLevel) > 50 {
+		return "difficulty_level longer than 50 characters"
+	}
+
+	switch user.TransportMode {
+	case "", models.TransportCar, models.TransportBike, models.TransportWalk, models.TransportPublic:
+	case models.TransportTransit:
+		user.TransportMode = models.TransportPublic
+	default:
+		return "unknown transport_mode"
+	}
+	if user.DigestFrequency != "" && !slices.Contains([]string{models.DigestDaily, models.DigestWeekly, models.DigestOff}, user.DigestFrequency) {
+		return "digest_frequency must be daily, weekly or off"
+	}
+	if user.Timezone != "" {
+		if _, err := time.LoadLocation(user.Timezone); err != nil {
+			return "unknown timezone"
+		}
+	}
+	return ""
+}
+
+// parseOptionalFloat parses a number, returning nil for an empty string
+func parseOptionalFloat(value string) (*float64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
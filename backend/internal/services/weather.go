@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/cache"
+	"community-chatbot/internal/chat"
+	"community-chatbot/internal/models"
+	"community-chatbot/internal/weather"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// weatherBucketDegrees is the grid forecasts are downloaded and cached on,
+	// about 10 km, close to the resolution of the forecast models
+	weatherBucketDegrees = 0.1
+	// weatherMaxLookups caps the forecasts downloaded to annotate one listing;
+	// activities in further cells are returned without weather
+	weatherMaxLookups = 10
+)
+
+var (
+	// ErrWeatherLocationRequired is returned by the weather tool when neither
+	// the model, an activity nor the user's context gives a location
+	ErrWeatherLocationRequired = apperr.New(apperr.Invalid, "a location is required; ask the user where they are going")
+	// ErrForecastOutOfRange is returned for dates in the past or beyond the forecast
+	ErrForecastOutOfRange = apperr.New(apperr.Invalid,
+		fmt.Sprintf("forecasts only cover the next %d days; use get_climate for typical weather further ahead", weather.MaxForecastDays))
+)
+
+// WeatherService provides forecasts and current conditions. Forecasts are
+// downloaded per location bucket for the whole forecast range and cached
+// briefly, so the chat tool and activity listings share them.
+type WeatherService struct {
+	provider weather.Provider
+	cache    *cache.Cache
+	db       *gorm.DB
+}
+
+// WeatherForecast is the forecast of a location bucket
+type WeatherForecast struct {
+	// Location is the center of the bucket the forecast was downloaded for
+	Location weather.Point `json:"location"`
+	weather.Forecast
+}
+
+// NewWeatherService creates a new weather service; forecasts may be nil to
+// disable caching and db nil when the chat tool cannot look up activities
+func NewWeatherService(provider weather.Provider, forecasts *cache.Cache, db *gorm.DB) *WeatherService {
+	return &WeatherService{
+		provider: provider,
+		cache:    forecasts,
+		db:       db,
+	}
+}
+
+// Forecast returns the current weather and daily forecast of the bucket containing loc
+func (s *WeatherService) Forecast(ctx context.Context, loc models.Location) (*WeatherForecast, error) {
+	center := weather.Point{Lat: weatherBucket(loc.Lat, 90), Lng: weatherBucket(loc.Lng, 180)}
+	key := fmt.Sprintf("%.2f,%.2f", center.Lat, center.Lng)
+
+	forecast, err := cache.Lookup(ctx, s.cache, key, s.download(center))
+	if err != nil {
+		return nil, err
+	}
+	return &WeatherForecast{Location: center, Forecast: *forecast}, nil
+}
+
+// download returns the fetch function of the forecast of a bucket
+func (s *WeatherService) download(center weather.Point) func(ctx context.Context) (*weather.Forecast, error) {
+	return func(ctx context.Context) (*weather.Forecast, error) {
+		forecast, err := s.provider.Forecast(ctx, center, weather.MaxForecastDays)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download forecast: %w", err)
+		}
+		return forecast, nil
+	}
+}
+
+// PopularLocations returns the locations of the limit most popular approved
+// activities, whose forecasts are worth keeping cached
+func (s *WeatherService) PopularLocations(ctx context.Context, limit int) ([]models.Location, error) {
+	if s.db == nil || limit <= 0 {
+		return nil, nil
+	}
+	var locations []models.Location
+	if err := s.db.WithContext(ctx).Model(&models.Activity{}).
+		Select("latitude AS lat, longitude AS lng").
+		Where("approved = ?", true).
+		Order("popularity_score DESC, id").
+		Limit(limit).
+		Scan(&locations).Error; err != nil {
+		return nil, fmt.Errorf("failed to load popular activities: %w", err)
+	}
+	return locations, nil
+}
+
+// Refresh downloads the forecasts of the buckets containing locations again,
+// so listings and the chat tool find them cached. Buckets are refreshed one at
+// a time to go easy on the provider; failures are logged and skipped. It
+// returns the number of buckets refreshed.
+func (s *WeatherService) Refresh(ctx context.Context, locations []models.Location) (int, error) {
+	refreshed := 0
+	seen := make(map[weather.Point]bool)
+	for _, loc := range locations {
+		center := weather.Point{Lat: weatherBucket(loc.Lat, 90), Lng: weatherBucket(loc.Lng, 180)}
+		if seen[center] {
+			continue
+		}
+		seen[center] = true
+		if ctx.Err() != nil {
+			return refreshed, ctx.Err()
+		}
+		key := fmt.Sprintf("%.2f,%.2f", center.Lat, center.Lng)
+		if _, err := cache.Refresh(ctx, s.cache, key, s.download(center)); err != nil {
+			log.Printf("[WEATHER] Refresh forecast at %s: %v", key, err)
+			continue
+		}
+		refreshed++
+	}
+	return refreshed, nil
+}
+
+// Current returns the current weather at each location, nil where it could
+// not be looked up. Locations in the same bucket share one lookup and at most
+// weatherMaxLookups buckets are looked up, in parallel.
+func (s *WeatherService) Current(ctx context.Context, locations []models.Location) []*weather.Conditions {
+	type bucket struct{ lat, lng float64 }
+	lookups := make(map[bucket][]int)
+	var order []bucket
+	for i, loc := range locations {
+		b := bucket{weatherBucket(loc.Lat, 90), weatherBucket(loc.Lng, 180)}
+		if _, ok := lookups[b]; !ok {
+			if len(order) == weatherMaxLookups {
+				continue
+			}
+			order = append(order, b)
+		}
+		lookups[b] = append(lookups[b], i)
+	}
+
+	conditions := make([]*weather.Conditions, len(locations))
+	var wg sync.WaitGroup
+	for _, b := range order {
+		wg.Add(1)
+		go func(b bucket, indexes []int) {
+			defer wg.Done()
+			forecast, err := s.Forecast(ctx, models.Location{Lat: b.lat, Lng: b.lng})
+			if err != nil {
+				log.Printf("[WEATHER] Current weather at %.2f,%.2f: %v", b.lat, b.lng, err)
+				return
+			}
+			for _, i := range indexes {
+				conditions[i] = forecast.Current
+			}
+		}(b, lookups[b])
+	}
+	wg.Wait()
+	return conditions
+}
+
+// weatherBucket snaps a coordinate to the center of its bucket. limit is 90 for
+// latitudes and 180 for longitudes; coordinates at or beyond it fall in the
+// edge bucket, so centers are valid coordinates.
+func weatherBucket(v, limit float64) float64 {
+	v = math.Max(-limit, math.Min(v, limit))
+	cell := math.Min(math.Floor(v/weatherBucketDegrees), math.Round(limit/weatherBucketDegrees)-1)
+	return cell*weatherBucketDegrees + weatherBucketDegrees/2
+}
+
+// Tool returns the chat tool looking up forecasts
+func (s *WeatherService) Tool() chat.Tool {
+	return chat.Tool{
+		Name: "get_weather",
+		Description: fmt.Sprintf("Get the current weather and the daily forecast (summary, high and low, precipitation and its probability, wind) at a location or activity. "+
+			"Use it for questions such as \"can I hike Bear Mountain tomorrow?\"; give date for a single day or days for the next days (default 3). "+
+			"Forecasts reach %d days ahead; use get_climate for typical weather further out.", weather.MaxForecastDays),
+		Parameters: json.RawMessage(fmt.Sprintf(`{
+			"type": "object",
+			"properties": {
+				"activity_id": {"type": "integer", "description": "Use the location of this activity"},
+				"lat": {"type": "number"},
+				"lng": {"type": "number"},
+				"date": {"type": "string", "description": "YYYY-MM-DD in the location's time zone"},
+				"days": {"type": "integer", "minimum": 1, "maximum": %d}
+			}
+		}`, weather.MaxForecastDays)),
+		Call: s.getWeather,
+	}
+}
+
+// getWeather implements the get_weather tool
+func (s *WeatherService) getWeather(ctx context.Context, run *chat.Run, raw json.RawMessage) (interface{}, error) {
+	var args struct {
+		ActivityID uint     `json:"activity_id"`
+		Lat        *float64 `json:"lat"`
+		Lng        *float64 `json:"lng"`
+		Date       string   `json:"date"`
+		Days       int      `json:"days"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, err)
+	}
+	if args.Date != "" {
+		if _, err := time.Parse(time.DateOnly, args.Date); err != nil {
+			return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, ErrInvalidDate)
+		}
+	}
+
+	var loc models.Location
+	switch {
+	case args.ActivityID != 0 && s.db != nil:
+		var activity models.Activity
+		if err := s.db.WithContext(ctx).Select("id", "latitude", "longitude").
+			Where("approved = ?", true).First(&activity, args.ActivityID).Error; err != nil {
+			return nil, fmt.Errorf("failed to load activity %d: %w", args.ActivityID, err)
+		}
+		loc = models.Location{Lat: activity.Latitude, Lng: activity.Longitude}
+	case args.Lat != nil && args.Lng != nil:
+		loc = models.Location{Lat: *args.Lat, Lng: *args.Lng}
+		if loc.Lat < -90 || loc.Lat > 90 || loc.Lng < -180 || loc.Lng > 180 {
+			return nil, fmt.Errorf("%w: lat and lng are out of range", chat.ErrInvalidToolArguments)
+		}
+	case run.UserContext != nil && run.UserContext.Location != nil:
+		loc = *run.UserContext.Location
+	default:
+		return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, ErrWeatherLocationRequired)
+	}
+
+	forecast, err := s.Forecast(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	// Dates are compared as YYYY-MM-DD strings in the location's time zone
+	days := forecast.Days
+	forecast.Days = nil
+	switch {
+	case args.Date != "":
+		for _, day := range days {
+			if day.Date == args.Date {
+				forecast.Days = append(forecast.Days, day)
+			}
+		}
+		if len(forecast.Days) == 0 {
+			return nil, fmt.Errorf("%w: %v", chat.ErrInvalidToolArguments, ErrForecastOutOfRange)
+		}
+	default:
+		n := args.Days
+		if n <= 0 {
+			n = 3
+		}
+		forecast.Days = days[:min(n, len(days))]
+	}
+	return forecast, nil
+}
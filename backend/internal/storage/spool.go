@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Spool keeps accepted files on disk until they are uploaded. With several
+// replicas the directory must be shared (e.g. a mounted volume or bucket) so any
+// replica can retry an upload.
+type Spool struct {
+	dir string
+}
+
+// NewSpool creates a spool in dir, creating the directory if needed
+func NewSpool(dir string) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	return &Spool{dir: dir}, nil
+}
+
+// Put writes file to the spool and returns the name it is stored under
+func (s *Spool) Put(file io.Reader) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to name spool file: %w", err)
+	}
+	name := hex.EncodeToString(random)
+
+	// Files are written under a temporary name so a crash never leaves a partial file behind
+	tmp, err := os.CreateTemp(s.dir, ".spool-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create spool file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, file); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write spool file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write spool file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, name)); err != nil {
+		return "", fmt.Errorf("failed to store spool file: %w", err)
+	}
+	return name, nil
+}
+
+// Open opens a spooled file
+func (s *Spool) Open(name string) (*os.File, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Remove deletes a spooled file; removing a missing file is not an error
+func (s *Spool) Remove(name string) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// path resolves a spool name, refusing names that would escape the directory
+func (s *Spool) path(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid spool file name %q", name)
+	}
+	return filepath.Join(s.dir, name), nil
+}
@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// ErrRejected is returned when the storage provider refuses a file (e.g. it is not
+// a valid image); retrying the upload cannot succeed
+var ErrRejected = errors.New("file rejected by storage provider")
+
+// Uploader stores files with a hosting provider and returns their public URL
+type Uploader interface {
+	// Upload stores the file under name. Errors other than ErrRejected are
+	// considered transient.
+	Upload(ctx context.Context, name string, file io.Reader) (url string, err error)
+}
@@ -0,0 +1,96 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ErrStopped cancels a generation its client stopped
+var ErrStopped = errors.New("stopped by client")
+
+var stoppedGenerations = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "stream_generations_stopped_total",
+	Help: "Answers whose generation was stopped by their client.",
+})
+
+// Generation is an answer being generated for a conversation
+type Generation struct {
+	ConversationID string
+	UserID         uint
+	SessionID      string
+
+	cancel context.CancelCauseFunc
+}
+
+// stoppableBy reports whether a client may stop the generation: users stop
+// their own, guests those of their session. Guest generations without a
+// session are stopped by anyone knowing the conversation ID.
+func (g *Generation) stoppableBy(userID uint, sessionID string) bool {
+	if g.UserID != 0 {
+		return g.UserID == userID
+	}
+	return userID == 0 && (g.SessionID == "" || g.SessionID == sessionID)
+}
+
+// Generations tracks the answers generated on this replica by conversation, so
+// their clients can stop them
+type Generations struct {
+	byConversation map[string]*Generation
+	mutex          sync.Mutex
+}
+
+// NewGenerations creates an empty generation tracker
+func NewGenerations() *Generations {
+	return &Generations{byConversation: make(map[string]*Generation)}
+}
+
+// Start registers the generation of an answer; cancel stops it. A newer
+// generation of the same conversation replaces an older one, which can then no
+// longer be stopped.
+func (g *Generations) Start(conversationID string, userID uint, sessionID string, cancel context.CancelCauseFunc) *Generation {
+	generation := &Generation{
+		ConversationID: conversationID,
+		UserID:         userID,
+		SessionID:      sessionID,
+		cancel:         cancel,
+	}
+
+	g.mutex.Lock()
+	g.byConversation[conversationID] = generation
+	g.mutex.Unlock()
+	return generation
+}
+
+// Finish unregisters a generation that ended
+func (g *Generations) Finish(generation *Generation) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.byConversation[generation.ConversationID] == generation {
+		delete(g.byConversation, generation.ConversationID)
+	}
+}
+
+// Stop cancels the generation of a conversation with ErrStopped. It reports
+// false when the conversation has no generation on this replica the client
+// may stop.
+func (g *Generations) Stop(conversationID string, userID uint, sessionID string) bool {
+	g.mutex.Lock()
+	generation, ok := g.byConversation[conversationID]
+	if ok && generation.stoppableBy(userID, sessionID) {
+		delete(g.byConversation, conversationID)
+	} else {
+		ok = false
+	}
+	g.mutex.Unlock()
+
+	if !ok {
+		return false
+	}
+	generation.cancel(ErrStopped)
+	stoppedGenerations.Inc()
+	return true
+}
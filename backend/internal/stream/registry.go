@@ -0,0 +1,177 @@
+package stream
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Reasons a connection is closed
+const (
+	CloseCompleted = "completed"
+	CloseIdle      = "idle"
+	CloseShutdown  = "shutdown"
+)
+
+var openConnections = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "stream_connections_open",
+	Help: "Streaming (SSE) connections currently open.",
+})
+
+var openedConnections = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "stream_connections_opened_total",
+	Help: "Streaming connections opened.",
+})
+
+var closedConnections = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "stream_connections_closed_total",
+	Help: "Streaming connections closed by reason (completed, idle, shutdown).",
+}, []string{"reason"})
+
+// Conn is an open streaming connection
+type Conn struct {
+	ID        string
+	ClientIP  string
+	Path      string
+	CreatedAt time.Time
+
+	lastWrite atomic.Int64 // unix nanoseconds
+	cancel    context.CancelFunc
+}
+
+// Touch records that data was written to the client
+func (c *Conn) Touch() {
+	c.lastWrite.Store(time.Now().UnixNano())
+}
+
+// LastWrite returns when data was last written to the client
+func (c *Conn) LastWrite() time.Time {
+	return time.Unix(0, c.lastWrite.Load())
+}
+
+// Registry tracks open streaming connections so idle ones can be reaped before
+// their goroutines pile up
+type Registry struct {
+	conns map[string]*Conn
+	mutex sync.Mutex
+}
+
+// NewRegistry creates an empty connection registry
+func NewRegistry() *Registry {
+	return &Registry{conns: make(map[string]*Conn)}
+}
+
+// Open registers a connection. The returned context is cancelled when the
+// connection is reaped or the registry shuts down; the stream must stop writing then.
+func (r *Registry) Open(ctx context.Context, clientIP, path string) (context.Context, *Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	conn := &Conn{
+		ID:        uuid.New().String(),
+		ClientIP:  clientIP,
+		Path:      path,
+		CreatedAt: time.Now(),
+		cancel:    cancel,
+	}
+	conn.Touch()
+
+	r.mutex.Lock()
+	r.conns[conn.ID] = conn
+	r.mutex.Unlock()
+
+	openedConnections.Inc()
+	openConnections.Inc()
+	return ctx, conn
+}
+
+// Close unregisters a connection that ended normally
+func (r *Registry) Close(conn *Conn) {
+	r.close(conn, CloseCompleted)
+}
+
+// close unregisters a connection and cancels its context. Connections are counted
+// once, by the first reason they were closed for.
+func (r *Registry) close(conn *Conn, reason string) bool {
+	r.mutex.Lock()
+	_, open := r.conns[conn.ID]
+	delete(r.conns, conn.ID)
+	r.mutex.Unlock()
+
+	if !open {
+		return false
+	}
+	conn.cancel()
+	openConnections.Dec()
+	closedConnections.WithLabelValues(reason).Inc()
+	return true
+}
+
+// Len returns the number of open connections
+func (r *Registry) Len() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.conns)
+}
+
+// Reap closes connections that have not written anything for idleAfter and
+// returns how many were closed
+func (r *Registry) Reap(idleAfter time.Duration) int {
+	cutoff := time.Now().Add(-idleAfter)
+
+	r.mutex.Lock()
+	var idle []*Conn
+	for _, conn := range r.conns {
+		if conn.LastWrite().Before(cutoff) {
+			idle = append(idle, conn)
+		}
+	}
+	r.mutex.Unlock()
+
+	reaped := 0
+	for _, conn := range idle {
+		if r.close(conn, CloseIdle) {
+			reaped++
+			log.Printf("[STREAM] Reaped connection %s from %s on %s: no writes for %s (open %s)",
+				conn.ID, conn.ClientIP, conn.Path, time.Since(conn.LastWrite()).Round(time.Second),
+				time.Since(conn.CreatedAt).Round(time.Second))
+		}
+	}
+	return reaped
+}
+
+// CloseAll closes every open connection, e.g. before a graceful shutdown
+func (r *Registry) CloseAll() {
+	r.mutex.Lock()
+	conns := make([]*Conn, 0, len(r.conns))
+	for _, conn := range r.conns {
+		conns = append(conns, conn)
+	}
+	r.mutex.Unlock()
+
+	for _, conn := range conns {
+		r.close(conn, CloseShutdown)
+	}
+	if len(conns) > 0 {
+		log.Printf("[STREAM] Closed %d open connections for shutdown", len(conns))
+	}
+}
+
+// StartReaper reaps idle connections every interval until ctx is cancelled
+func (r *Registry) StartReaper(ctx context.Context, interval, idleAfter time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Reap(idleAfter)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package stream
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event is a buffered stream event
+type Event struct {
+	Seq uint64
+	// Data is the event as it is written to clients, with its event ID
+	Data string
+}
+
+// Replay buffers the most recent events of a stream so a client that reconnects
+// with Last-Event-ID can continue where it left off. Event IDs are the replay ID
+// and a sequence number increasing from 1, e.g. "3f2a...:17".
+type Replay struct {
+	ID      string
+	OwnerID uint // user who started the stream, 0 for anonymous users
+	// RunID is the run token's run for streams started with one; reconnections
+	// holding the token may resume the stream without the owner's credentials
+	RunID string
+
+	size      int
+	events    []Event
+	next      uint64
+	done      bool
+	doneAt    time.Time
+	listeners int
+	idleSince time.Time
+	changed   chan struct{} // closed and replaced whenever an event is added or the stream ends
+	mutex     sync.Mutex
+}
+
+// Append buffers an event, dropping the oldest when the buffer is full. encode
+// encodes the event with the event ID it is given, so buffered events are
+// written to clients as they are.
+func (r *Replay) Append(encode func(eventID string) ([]byte, error)) (Event, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	data, err := encode(r.eventID(r.next + 1))
+	if err != nil {
+		return Event{}, err
+	}
+	r.next++
+	event := Event{Seq: r.next, Data: string(data)}
+	if len(r.events) == r.size {
+		r.events = r.events[1:]
+	}
+	r.events = append(r.events, event)
+	r.notify()
+	return event, nil
+}
+
+// Finish marks the stream as complete
+func (r *Replay) Finish() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.done = true
+	r.doneAt = time.Now()
+	r.notify()
+}
+
+// notify wakes clients waiting for changes. The caller must hold the mutex.
+func (r *Replay) notify() {
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// Since returns the events after seq, whether the stream is complete and a
+// channel closed on the next change. ok is false when events after seq have
+// already been dropped from the buffer.
+func (r *Replay) Since(seq uint64) (events []Event, done bool, changed <-chan struct{}, ok bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(r.events) > 0 && r.events[0].Seq > seq+1 {
+		return nil, r.done, r.changed, false
+	}
+	for _, event := range r.events {
+		if event.Seq > seq {
+			events = append(events, event)
+		}
+	}
+	return events, r.done, r.changed, true
+}
+
+// eventID returns the SSE event ID of the event numbered seq
+func (r *Replay) eventID(seq uint64) string {
+	return fmt.Sprintf("%s:%d", r.ID, seq)
+}
+
+// Attach registers a client following the stream
+func (r *Replay) Attach() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.listeners++
+}
+
+// Detach unregisters a client following the stream
+func (r *Replay) Detach() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.listeners--
+	if r.listeners == 0 {
+		r.idleSince = time.Now()
+	}
+}
+
+// Abandoned reports whether no client has followed the stream for grace
+func (r *Replay) Abandoned(grace time.Duration) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.listeners == 0 && time.Since(r.idleSince) >= grace
+}
+
+// Replays keeps the replay buffers of recent streams
+type Replays struct {
+	size    int
+	window  time.Duration
+	replays map[string]*Replay
+	mutex   sync.Mutex
+}
+
+// NewReplays creates a replay store buffering up to size events per stream.
+// Clients can reconnect within window: disconnected streams keep generating and
+// completed buffers are kept that long.
+func NewReplays(size int, window time.Duration) *Replays {
+	return &Replays{
+		size:    size,
+		window:  window,
+		replays: make(map[string]*Replay),
+	}
+}
+
+// Create starts the replay buffer of a new stream; runID is "" unless the
+// stream was started with a run token
+func (r *Replays) Create(ownerID uint, runID string) *Replay {
+	replay := &Replay{
+		ID:      uuid.New().String(),
+		OwnerID: ownerID,
+		RunID:   runID,
+		size:    r.size,
+		changed: make(chan struct{}),
+	}
+
+	r.mutex.Lock()
+	r.replays[replay.ID] = replay
+	r.mutex.Unlock()
+	return replay
+}
+
+// Lookup returns the replay and sequence number of a Last-Event-ID, or nil when
+// the stream is unknown or its buffer has expired
+func (r *Replays) Lookup(lastEventID string) (*Replay, uint64) {
+	id, seqText, found := strings.Cut(lastEventID, ":")
+	if !found {
+		return nil, 0
+	}
+	seq, err := strconv.ParseUint(seqText, 10, 64)
+	if err != nil {
+		return nil, 0
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.replays[id], seq
+}
+
+// Window returns how long clients can take to reconnect
+func (r *Replays) Window() time.Duration {
+	return r.window
+}
+
+// Expire removes the buffers of streams that completed more than the window
+// ago and returns how many were removed
+func (r *Replays) Expire() int {
+	cutoff := time.Now().Add(-r.window)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	expired := 0
+	for id, replay := range r.replays {
+		replay.mutex.Lock()
+		old := replay.done && replay.doneAt.Before(cutoff)
+		replay.mutex.Unlock()
+		if old {
+			delete(r.replays, id)
+			expired++
+		}
+	}
+	return expired
+}
@@ -0,0 +1,125 @@
+package stream
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"community-chatbot/internal/cache"
+)
+
+// ErrInvalidRunToken is returned for run tokens that are malformed, forged,
+// expired or already used
+var ErrInvalidRunToken = errors.New("invalid or expired run token")
+
+// runTokenPrefix namespaces pending runs in the store
+const runTokenPrefix = "chatrun:"
+
+// RunTokens hands out short-lived, single-use tokens for chat runs created
+// with a POST, so EventSource clients can open the run's stream with a GET
+// that carries no message in its URL. Tokens are the run's ID and expiry,
+// signed with HMAC-SHA256; the run itself waits in the store until its stream
+// starts. Replicas share pending runs through a Redis store and a common secret.
+type RunTokens struct {
+	store  cache.Taker
+	secret []byte
+	ttl    time.Duration
+}
+
+// NewRunTokens creates run tokens valid for ttl. An empty secret is replaced
+// by a random one, which only this process accepts.
+func NewRunTokens(store cache.Taker, secret string, ttl time.Duration) (*RunTokens, error) {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate run token secret: %w", err)
+		}
+	}
+	return &RunTokens{
+		store:  store,
+		secret: key,
+		ttl:    ttl,
+	}, nil
+}
+
+// TTL returns how long tokens are valid
+func (t *RunTokens) TTL() time.Duration {
+	return t.ttl
+}
+
+// Issue stores a pending run and returns its token
+func (t *RunTokens) Issue(ctx context.Context, run []byte) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate run ID: %w", err)
+	}
+	runID := base64.RawURLEncoding.EncodeToString(id)
+	payload := runID + "." + strconv.FormatInt(time.Now().Add(t.ttl).Unix(), 10)
+
+	if err := t.store.Set(ctx, runTokenPrefix+runID, run, t.ttl); err != nil {
+		return "", fmt.Errorf("failed to store pending run: %w", err)
+	}
+	return payload + "." + t.sign(payload), nil
+}
+
+// Redeem returns the run ID and pending run of a token and removes the run in
+// the same step, so each token starts one stream even when it is redeemed
+// concurrently
+func (t *RunTokens) Redeem(ctx context.Context, token string) (string, []byte, error) {
+	runID, expiresAt, err := t.parse(token)
+	if err != nil {
+		return "", nil, err
+	}
+	if time.Now().Unix() > expiresAt {
+		return "", nil, ErrInvalidRunToken
+	}
+
+	run, found, err := t.store.Take(ctx, runTokenPrefix+runID)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to take pending run: %w", err)
+	}
+	if !found {
+		return "", nil, ErrInvalidRunToken
+	}
+	return runID, run, nil
+}
+
+// RunID returns the run ID of a token signed by this secret, even when it has
+// expired or was redeemed, for reconnections to the run's stream
+func (t *RunTokens) RunID(token string) (string, error) {
+	runID, _, err := t.parse(token)
+	return runID, err
+}
+
+// parse checks the signature of a token and returns its run ID and expiry
+func (t *RunTokens) parse(token string) (string, int64, error) {
+	index := strings.LastIndexByte(token, '.')
+	if index <= 0 {
+		return "", 0, ErrInvalidRunToken
+	}
+	payload, signature := token[:index], token[index+1:]
+	if !hmac.Equal([]byte(signature), []byte(t.sign(payload))) {
+		return "", 0, ErrInvalidRunToken
+	}
+	runID, expiry, _ := strings.Cut(payload, ".")
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return "", 0, ErrInvalidRunToken
+	}
+	return runID, expiresAt, nil
+}
+
+// sign returns the HMAC-SHA256 signature of a token payload
+func (t *RunTokens) sign(payload string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,57 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"community-chatbot/internal/cache"
+)
+
+// TestRedeemConcurrently redeems one token from many goroutines at once and
+// checks that exactly one of them gets the run
+func TestRedeemConcurrently(t *testing.T) {
+	tokens, err := NewRunTokens(cache.NewMemoryStore(100), "secret", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := tokens.Issue(context.Background(), []byte("run"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const redeemers = 50
+	var wg sync.WaitGroup
+	results := make(chan error, redeemers)
+	start := make(chan struct{})
+	for i := 0; i < redeemers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, run, err := tokens.Redeem(context.Background(), token)
+			if err == nil && string(run) != "run" {
+				err = errors.New("redeemed run " + string(run))
+			}
+			results <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(results)
+
+	redeemed := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			redeemed++
+		case !errors.Is(err, ErrInvalidRunToken):
+			t.Errorf("Redeem: %v", err)
+		}
+	}
+	if redeemed != 1 {
+		t.Errorf("token redeemed %d times, want once", redeemed)
+	}
+}
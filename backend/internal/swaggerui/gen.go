@@ -0,0 +1,61 @@
+//go:build ignore
+
+// gen downloads the swagger-ui-dist release in dist/VERSION to dist, with its
+// license, and records the SRI hash of each file in dist/SHA384SUMS, which the
+// package tests check the embedded files against. Run it with go generate after
+// changing the version, and commit the files.
+package main
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// files are downloaded from the package of the release
+var files = []string{"swagger-ui.css", "swagger-ui-bundle.js", "LICENSE"}
+
+func main() {
+	version, err := os.ReadFile(filepath.Join("dist", "VERSION"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	release := strings.TrimSpace(string(version))
+	client := &http.Client{Timeout: time.Minute}
+	var sums strings.Builder
+	for _, name := range files {
+		data, err := download(client, fmt.Sprintf("https://unpkg.com/swagger-ui-dist@%s/%s", release, name))
+		if err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join("dist", name), data, 0o644); err != nil {
+			log.Fatal(err)
+		}
+		sum := sha512.Sum384(data)
+		fmt.Fprintf(&sums, "%s sha384-%s\n", name, base64.StdEncoding.EncodeToString(sum[:]))
+	}
+	if err := os.WriteFile(filepath.Join("dist", "SHA384SUMS"), []byte(sums.String()), 0o644); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Print(sums.String())
+}
+
+// download returns the body of a URL
+func download(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,45 @@
+// Package swaggerui holds a vendored copy of Swagger UI, so the API docs load
+// no scripts from third-party hosts. The release in dist/VERSION is downloaded
+// to dist by gen.go; run go generate after changing it.
+package swaggerui
+
+//go:generate go run gen.go
+
+import (
+	"embed"
+	"strings"
+)
+
+// Files are the Swagger UI files the docs page loads
+var Files = []string{"swagger-ui.css", "swagger-ui-bundle.js"}
+
+//go:embed dist
+var dist embed.FS
+
+// Version returns the vendored swagger-ui-dist release
+func Version() string {
+	version, _ := dist.ReadFile("dist/VERSION")
+	return strings.TrimSpace(string(version))
+}
+
+// File returns a vendored Swagger UI file, or false when it is not one of
+// Files or was not downloaded
+func File(name string) ([]byte, bool) {
+	for _, file := range Files {
+		if file == name {
+			data, err := dist.ReadFile("dist/" + name)
+			return data, err == nil
+		}
+	}
+	return nil, false
+}
+
+// Vendored reports whether all Files were downloaded
+func Vendored() bool {
+	for _, file := range Files {
+		if _, ok := File(file); !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,40 @@
+package swaggerui
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// TestVendored checks that the files of the release in dist/VERSION are
+// embedded with the hashes gen.go recorded when downloading them
+func TestVendored(t *testing.T) {
+	if Version() == "" {
+		t.Fatal("dist/VERSION is missing")
+	}
+	sums, err := dist.ReadFile("dist/SHA384SUMS")
+	if err != nil {
+		t.Skipf("Swagger UI %s is not vendored; run go generate ./internal/swaggerui", Version())
+	}
+
+	want := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(sums)), "\n") {
+		name, hash, _ := strings.Cut(line, " ")
+		want[name] = hash
+	}
+	for _, name := range append(Files, "LICENSE") {
+		data, err := dist.ReadFile("dist/" + name)
+		if err != nil || len(data) == 0 {
+			t.Errorf("%s is not embedded", name)
+			continue
+		}
+		sum := sha512.Sum384(data)
+		if got := "sha384-" + base64.StdEncoding.EncodeToString(sum[:]); got != want[name] {
+			t.Errorf("%s has hash %s, want %s", name, got, want[name])
+		}
+	}
+	if !Vendored() {
+		t.Error("Vendored() = false with all files embedded")
+	}
+}
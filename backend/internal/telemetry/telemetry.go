@@ -0,0 +1,181 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"community-chatbot/internal/apperr"
+	"community-chatbot/internal/chat"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Report is the aggregated, content-free usage summary sent to the telemetry endpoint
+type Report struct {
+	InstanceID  string            `json:"instance_id"`
+	PeriodStart time.Time         `json:"period_start"`
+	PeriodEnd   time.Time         `json:"period_end"`
+	Messages    uint64            `json:"messages"`
+	Requests    uint64            `json:"requests"`
+	Errors      uint64            `json:"errors"`
+	ErrorRate   float64           `json:"error_rate"`
+	Features    map[string]uint64 `json:"features"`
+}
+
+// Collector aggregates usage counters between reports. It never records
+// message content, client addresses or user identifiers.
+type Collector struct {
+	instanceID  string
+	endpoint    string
+	client      *http.Client
+	periodStart time.Time
+	messages    uint64
+	requests    uint64
+	errors      uint64
+	features    map[string]uint64
+	mutex       sync.Mutex
+}
+
+// NewCollector creates a collector that reports to endpoint
+func NewCollector(instanceID, endpoint string) *Collector {
+	return &Collector{
+		instanceID:  instanceID,
+		endpoint:    endpoint,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		periodStart: time.Now(),
+		features:    make(map[string]uint64),
+	}
+}
+
+// Middleware counts requests, server errors and per-route feature usage
+func (c *Collector) Middleware() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		err := ctx.Next()
+
+		status := ctx.Response().StatusCode()
+		if e, ok := err.(*fiber.Error); ok {
+			status = e.Code
+		} else if err != nil {
+			status = apperr.KindOf(err).Status()
+		}
+
+		c.mutex.Lock()
+		c.requests++
+		if (err != nil && status < 400) || status >= 500 {
+			c.errors++
+		}
+		// Route patterns (e.g. /api/v1/activities/:id) contain no user data
+		c.features[ctx.Method()+" "+ctx.Route().Path]++
+		c.mutex.Unlock()
+
+		return err
+	}
+}
+
+// Stage counts chat messages processed by the pipeline
+func (c *Collector) Stage() chat.Stage {
+	return chat.StageFunc{
+		StageName: "telemetry",
+		Fn: func(ctx context.Context, run *chat.Run, next chat.Handler) error {
+			if !run.DryRun {
+				c.mutex.Lock()
+				c.messages++
+				c.mutex.Unlock()
+			}
+			return next(ctx, run)
+		},
+	}
+}
+
+// Start sends a report every interval until ctx is cancelled
+func (c *Collector) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.Flush(ctx); err != nil {
+				log.Printf("[TELEMETRY] Failed to send report: %v", err)
+			}
+		}
+	}
+}
+
+// Flush sends the current period's report and starts a new period. Counters
+// are kept if the report could not be delivered.
+func (c *Collector) Flush(ctx context.Context) error {
+	report := c.snapshot()
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry endpoint returned %d", resp.StatusCode)
+	}
+
+	c.reset(report)
+	return nil
+}
+
+func (c *Collector) snapshot() Report {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	features := make(map[string]uint64, len(c.features))
+	for name, count := range c.features {
+		features[name] = count
+	}
+
+	report := Report{
+		InstanceID:  c.instanceID,
+		PeriodStart: c.periodStart,
+		PeriodEnd:   time.Now(),
+		Messages:    c.messages,
+		Requests:    c.requests,
+		Errors:      c.errors,
+		Features:    features,
+	}
+	if c.requests > 0 {
+		report.ErrorRate = float64(c.errors) / float64(c.requests)
+	}
+	return report
+}
+
+// reset subtracts a delivered report so activity recorded during the send is kept
+func (c *Collector) reset(sent Report) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.periodStart = sent.PeriodEnd
+	c.messages -= sent.Messages
+	c.requests -= sent.Requests
+	c.errors -= sent.Errors
+	for name, count := range sent.Features {
+		c.features[name] -= count
+		if c.features[name] == 0 {
+			delete(c.features, name)
+		}
+	}
+}
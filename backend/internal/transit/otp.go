@@ -0,0 +1,167 @@
+package transit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// otpPlanQuery requests transit itineraries from the OpenTripPlanner GTFS GraphQL API
+const otpPlanQuery = `query($from: InputCoordinates!, $to: InputCoordinates!, $date: String!, $time: String!, $n: Int!) {
+  plan(from: $from, to: $to, date: $date, time: $time, numItineraries: $n,
+       transportModes: [{mode: TRANSIT}, {mode: WALK}]) {
+    itineraries {
+      startTime
+      endTime
+      duration
+      walkDistance
+      legs {
+        mode
+        startTime
+        endTime
+        distance
+        headsign
+        transitLeg
+        from { name }
+        to { name }
+        route { shortName longName }
+      }
+    }
+  }
+}`
+
+// OTPClient plans trips with an OpenTripPlanner 2 instance
+type OTPClient struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+// NewOTPClient creates a client for the OTP GraphQL endpoint at baseURL,
+// e.g. https://otp.example.org/otp/gtfs/v1
+func NewOTPClient(baseURL string) *OTPClient {
+	return &OTPClient{
+		endpoint:   strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+type otpCoordinates struct {
+	Lat float64 `json:"lat"`
+	Lon float64 `json:"lon"`
+}
+
+type otpResponse struct {
+	Data struct {
+		Plan struct {
+			Itineraries []struct {
+				StartTime    int64   `json:"startTime"`
+				EndTime      int64   `json:"endTime"`
+				Duration     int64   `json:"duration"`
+				WalkDistance float64 `json:"walkDistance"`
+				Legs         []struct {
+					Mode       string                `json:"mode"`
+					StartTime  int64                 `json:"startTime"`
+					EndTime    int64                 `json:"endTime"`
+					Distance   float64               `json:"distance"`
+					Headsign   string                `json:"headsign"`
+					TransitLeg bool                  `json:"transitLeg"`
+					From       struct{ Name string } `json:"from"`
+					To         struct{ Name string } `json:"to"`
+					Route      *struct {
+						ShortName string `json:"shortName"`
+						LongName  string `json:"longName"`
+					} `json:"route"`
+				} `json:"legs"`
+			} `json:"itineraries"`
+		} `json:"plan"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// Plan requests itineraries departing at or after req.DepartAt. Dates and times are
+// sent in the departure's location; OTP interprets them in the feed's timezone.
+func (c *OTPClient) Plan(ctx context.Context, req PlanRequest) ([]Itinerary, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"query": otpPlanQuery,
+		"variables": map[string]interface{}{
+			"from": otpCoordinates{Lat: req.From.Lat, Lon: req.From.Lng},
+			"to":   otpCoordinates{Lat: req.To.Lat, Lon: req.To.Lng},
+			"date": req.DepartAt.Format("2006-01-02"),
+			"time": req.DepartAt.Format("15:04"),
+			"n":    req.Itineraries,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode plan request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("otp request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("otp returned %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var body otpResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode otp response: %w", err)
+	}
+	if len(body.Errors) > 0 {
+		return nil, fmt.Errorf("otp error: %s", body.Errors[0].Message)
+	}
+	if len(body.Data.Plan.Itineraries) == 0 {
+		return nil, ErrNoRoute
+	}
+
+	itineraries := make([]Itinerary, 0, len(body.Data.Plan.Itineraries))
+	for _, it := range body.Data.Plan.Itineraries {
+		itinerary := Itinerary{
+			DepartAt:        time.UnixMilli(it.StartTime),
+			ArriveAt:        time.UnixMilli(it.EndTime),
+			DurationMinutes: int((it.Duration + 59) / 60),
+			WalkKM:          it.WalkDistance / 1000,
+		}
+		rides := 0
+		for _, leg := range it.Legs {
+			l := Leg{
+				Mode:       strings.ToLower(leg.Mode),
+				Headsign:   leg.Headsign,
+				From:       leg.From.Name,
+				To:         leg.To.Name,
+				DepartAt:   time.UnixMilli(leg.StartTime),
+				ArriveAt:   time.UnixMilli(leg.EndTime),
+				DistanceKM: leg.Distance / 1000,
+			}
+			if leg.Route != nil {
+				l.Line = leg.Route.ShortName
+				if l.Line == "" {
+					l.Line = leg.Route.LongName
+				}
+			}
+			if leg.TransitLeg {
+				rides++
+			}
+			itinerary.Legs = append(itinerary.Legs, l)
+		}
+		itinerary.Transfers = max(0, rides-1)
+		itineraries = append(itineraries, itinerary)
+	}
+	return itineraries, nil
+}
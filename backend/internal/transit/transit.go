@@ -0,0 +1,51 @@
+package transit
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNoRoute is returned when the planner cannot find a transit connection
+var ErrNoRoute = errors.New("no transit connection found")
+
+// Point is a coordinate pair used for trip planning
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// PlanRequest describes a trip to plan
+type PlanRequest struct {
+	From        Point
+	To          Point
+	DepartAt    time.Time
+	Itineraries int
+}
+
+// Leg is one part of an itinerary, either a walk or a ride on a transit line
+type Leg struct {
+	Mode       string    `json:"mode"`
+	Line       string    `json:"line,omitempty"`
+	Headsign   string    `json:"headsign,omitempty"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	DepartAt   time.Time `json:"depart_at"`
+	ArriveAt   time.Time `json:"arrive_at"`
+	DistanceKM float64   `json:"distance_km"`
+}
+
+// Itinerary is a complete trip from origin to destination
+type Itinerary struct {
+	DepartAt        time.Time `json:"depart_at"`
+	ArriveAt        time.Time `json:"arrive_at"`
+	DurationMinutes int       `json:"duration_minutes"`
+	Transfers       int       `json:"transfers"`
+	WalkKM          float64   `json:"walk_km"`
+	Legs            []Leg     `json:"legs"`
+}
+
+// Planner plans public transit trips
+type Planner interface {
+	Plan(ctx context.Context, req PlanRequest) ([]Itinerary, error)
+}
@@ -0,0 +1,171 @@
+package upload
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInfected is returned by scanners for files containing malware
+var ErrInfected = errors.New("file is infected")
+
+// clamdChunkSize is the size of the chunks files are streamed to clamd in
+const clamdChunkSize = 64 * 1024
+
+// Scanner scans files for malware before they are stored
+type Scanner interface {
+	// Scan returns ErrInfected for infected files; other errors mean the file
+	// could not be scanned
+	Scan(ctx context.Context, data []byte) error
+}
+
+// ClamAVScanner scans files with a clamd daemon over TCP, using the INSTREAM
+// command so clamd needs no access to the files
+type ClamAVScanner struct {
+	address string
+	timeout time.Duration
+}
+
+// NewClamAVScanner creates a scanner for the clamd daemon at address (host:port)
+func NewClamAVScanner(address string, timeout time.Duration) *ClamAVScanner {
+	return &ClamAVScanner{
+		address: address,
+		timeout: timeout,
+	}
+}
+
+// Scan streams data to clamd and interprets its verdict
+func (s *ClamAVScanner) Scan(ctx context.Context, data []byte) error {
+	conn, err := dialScanner(ctx, s.address, s.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Chunks are prefixed with their length; a zero length ends the stream
+	var request bytes.Buffer
+	request.WriteString("zINSTREAM\x00")
+	for start := 0; start < len(data); start += clamdChunkSize {
+		chunk := data[start:min(start+clamdChunkSize, len(data))]
+		_ = binary.Write(&request, binary.BigEndian, uint32(len(chunk)))
+		request.Write(chunk)
+	}
+	_ = binary.Write(&request, binary.BigEndian, uint32(0))
+	if _, err := conn.Write(request.Bytes()); err != nil {
+		return fmt.Errorf("failed to send file to clamd: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil {
+		return fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	switch {
+	case strings.HasSuffix(reply, " OK"):
+		return nil
+	case strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND")
+		return fmt.Errorf("%w: %s", ErrInfected, signature)
+	}
+	return fmt.Errorf("clamd failed: %s", reply)
+}
+
+// ICAPScanner scans files with an ICAP server (RFC 3507) such as c-icap or a
+// proxy's antivirus service, sending them as the body of a RESPMOD request.
+// Servers answer 204 for clean files and modify (block) infected ones.
+type ICAPScanner struct {
+	service *url.URL
+	timeout time.Duration
+}
+
+// NewICAPScanner creates a scanner for the ICAP service at serviceURL, e.g.
+// icap://localhost:1344/avscan
+func NewICAPScanner(serviceURL string, timeout time.Duration) (*ICAPScanner, error) {
+	service, err := url.Parse(serviceURL)
+	if err != nil || service.Scheme != "icap" || service.Host == "" {
+		return nil, fmt.Errorf("invalid ICAP service URL %q", serviceURL)
+	}
+	if service.Port() == "" {
+		service.Host += ":1344"
+	}
+	return &ICAPScanner{
+		service: service,
+		timeout: timeout,
+	}, nil
+}
+
+// Scan sends data to the ICAP service and interprets its response status
+func (s *ICAPScanner) Scan(ctx context.Context, data []byte) error {
+	conn, err := dialScanner(ctx, s.service.Host, s.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	response := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: application/octet-stream\r\nContent-Length: %d\r\n\r\n", len(data))
+	var request bytes.Buffer
+	fmt.Fprintf(&request, "RESPMOD %s ICAP/1.0\r\n", s.service)
+	fmt.Fprintf(&request, "Host: %s\r\n", s.service.Hostname())
+	request.WriteString("Allow: 204\r\n")
+	fmt.Fprintf(&request, "Encapsulated: res-hdr=0, res-body=%d\r\n\r\n", len(response))
+	request.WriteString(response)
+	if len(data) > 0 {
+		fmt.Fprintf(&request, "%x\r\n", len(data))
+		request.Write(data)
+		request.WriteString("\r\n")
+	}
+	request.WriteString("0\r\n\r\n")
+	if _, err := conn.Write(request.Bytes()); err != nil {
+		return fmt.Errorf("failed to send file to ICAP service: %w", err)
+	}
+
+	reader := textproto.NewReader(bufio.NewReader(conn))
+	status, err := reader.ReadLine()
+	if err != nil {
+		return fmt.Errorf("failed to read ICAP response: %w", err)
+	}
+	headers, err := reader.ReadMIMEHeader()
+	if err != nil {
+		return fmt.Errorf("failed to read ICAP response headers: %w", err)
+	}
+
+	switch fields := strings.Fields(status); {
+	case len(fields) < 2:
+		return fmt.Errorf("invalid ICAP response %q", status)
+	case fields[1] == "204":
+		return nil
+	case fields[1] == "200":
+		// The service replaced the response, typically with a block page
+		threat := headers.Get("X-Infection-Found")
+		if threat == "" {
+			threat = headers.Get("X-Violations-Found")
+		}
+		return fmt.Errorf("%w: %s", ErrInfected, strings.TrimSpace(threat))
+	}
+	return fmt.Errorf("ICAP service failed: %s", status)
+}
+
+// dialScanner connects to a scanner, bounding the whole exchange by timeout
+func dialScanner(ctx context.Context, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to scanner: %w", err)
+	}
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,165 @@
+// Package upload checks uploaded files before they are stored: the type is
+// sniffed from the content and checked against an allowlist and the file name
+// extension, image dimensions are capped and files can be scanned for malware.
+package upload
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // registers GIF for image.DecodeConfig
+	_ "image/jpeg" // registers JPEG for image.DecodeConfig
+	_ "image/png"  // registers PNG for image.DecodeConfig
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"community-chatbot/internal/geo"
+)
+
+// Content types of route files, which http.DetectContentType does not know
+const (
+	TypeGPX = "application/gpx+xml"
+	TypeTCX = "application/vnd.garmin.tcx+xml"
+	TypeKML = "application/vnd.google-earth.kml+xml"
+	TypeFIT = "application/vnd.ant.fit"
+)
+
+var (
+	// ErrTypeNotAllowed is returned for files whose content is not an allowed type
+	ErrTypeNotAllowed = errors.New("file type is not allowed")
+	// ErrExtensionMismatch is returned when the file name extension does not
+	// match the content, e.g. an executable named photo.jpg
+	ErrExtensionMismatch = errors.New("file name extension does not match its content")
+	// ErrImageTooLarge is returned for images exceeding the dimension cap
+	ErrImageTooLarge = errors.New("image dimensions are too large")
+)
+
+// extensions are the file name extensions of each content type
+var extensions = map[string][]string{
+	"image/jpeg": {"jpg", "jpeg", "jfif"},
+	"image/png":  {"png"},
+	"image/gif":  {"gif"},
+	"image/webp": {"webp"},
+	TypeGPX:      {"gpx"},
+	TypeTCX:      {"tcx"},
+	TypeKML:      {"kml"},
+	TypeFIT:      {"fit"},
+}
+
+// routeTypes maps route formats to their content types
+var routeTypes = map[string]string{
+	geo.FormatGPX: TypeGPX,
+	geo.FormatTCX: TypeTCX,
+	geo.FormatKML: TypeKML,
+	geo.FormatFIT: TypeFIT,
+}
+
+// Sniff returns the content type of a file from its leading bytes (magic
+// numbers), ignoring its name and the type the client claims
+func Sniff(data []byte) string {
+	if format, err := geo.SniffFormat(data); err == nil {
+		return routeTypes[format]
+	}
+	return http.DetectContentType(data)
+}
+
+// Policy is the allowlist of content types for one kind of upload
+type Policy struct {
+	types map[string]bool
+}
+
+// NewPolicy creates a policy allowing a comma separated list of content types
+func NewPolicy(types string) (Policy, error) {
+	policy := Policy{types: make(map[string]bool)}
+	for _, t := range strings.Split(types, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+		if _, ok := extensions[t]; !ok {
+			return Policy{}, fmt.Errorf("unsupported upload type %q", t)
+		}
+		policy.types[t] = true
+	}
+	if len(policy.types) == 0 {
+		return Policy{}, errors.New("at least one upload type is required")
+	}
+	return policy, nil
+}
+
+// Check sniffs the content type of a file and returns it when the policy
+// allows it and the file name has no extension or one of the type's
+func (p Policy) Check(filename string, data []byte) (string, error) {
+	contentType := Sniff(data)
+	if !p.types[contentType] {
+		return "", fmt.Errorf("%w: %s", ErrTypeNotAllowed, contentType)
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	if ext == "" {
+		return contentType, nil
+	}
+	for _, allowed := range extensions[contentType] {
+		if ext == allowed {
+			return contentType, nil
+		}
+	}
+	return "", fmt.Errorf("%w: .%s is not %s", ErrExtensionMismatch, ext, contentType)
+}
+
+// Describe lists the allowed types for error messages, e.g. "gif, jpeg, png"
+func (p Policy) Describe() string {
+	var names []string
+	for t := range p.types {
+		names = append(names, extensions[t][0])
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// CheckImageSize returns ErrImageTooLarge when the width or height of an image
+// exceeds maxDimension pixels, which keeps decompression bombs out. The
+// dimensions are read from the header without decoding the image.
+func CheckImageSize(contentType string, data []byte, maxDimension int) error {
+	var width, height int
+	if contentType == "image/webp" {
+		var err error
+		if width, height, err = webpSize(data); err != nil {
+			return err
+		}
+	} else {
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("%w: unreadable image header: %v", ErrTypeNotAllowed, err)
+		}
+		width, height = config.Width, config.Height
+	}
+	if width > maxDimension || height > maxDimension {
+		return fmt.Errorf("%w: %dx%d exceeds %d pixels", ErrImageTooLarge, width, height, maxDimension)
+	}
+	return nil
+}
+
+// webpSize reads the canvas size of a WebP image from its first chunk
+func webpSize(data []byte) (int, int, error) {
+	if len(data) < 30 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return 0, 0, fmt.Errorf("%w: invalid WebP header", ErrTypeNotAllowed)
+	}
+	chunk := data[20:]
+	switch string(data[12:16]) {
+	case "VP8 ":
+		// Lossy: 14-bit width and height after the frame tag and start code
+		return int(chunk[6]) | int(chunk[7]&0x3f)<<8, int(chunk[8]) | int(chunk[9]&0x3f)<<8, nil
+	case "VP8L":
+		// Lossless: 14-bit width and height minus one after the signature byte
+		bits := uint32(chunk[1]) | uint32(chunk[2])<<8 | uint32(chunk[3])<<16 | uint32(chunk[4])<<24
+		return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, nil
+	case "VP8X":
+		// Extended: 24-bit canvas width and height minus one
+		return int(uint32(chunk[4])|uint32(chunk[5])<<8|uint32(chunk[6])<<16) + 1,
+			int(uint32(chunk[7])|uint32(chunk[8])<<8|uint32(chunk[9])<<16) + 1, nil
+	}
+	return 0, 0, fmt.Errorf("%w: unknown WebP chunk", ErrTypeNotAllowed)
+}
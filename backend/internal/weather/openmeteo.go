@@ -0,0 +1,125 @@
+package weather
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenMeteoClient downloads forecasts from the Open-Meteo forecast API, which
+// needs no key and can be self-hosted
+type OpenMeteoClient struct {
+	endpoint   string
+	httpClient *http.Client
+}
+
+// NewOpenMeteoClient creates a client for the forecast endpoint at baseURL,
+// e.g. https://api.open-meteo.com/v1/forecast
+func NewOpenMeteoClient(baseURL string) *OpenMeteoClient {
+	return &OpenMeteoClient{
+		endpoint:   strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+type openMeteoResponse struct {
+	Timezone string `json:"timezone"`
+	Current  *struct {
+		Time                string  `json:"time"`
+		Temperature2m       float64 `json:"temperature_2m"`
+		ApparentTemperature float64 `json:"apparent_temperature"`
+		Precipitation       float64 `json:"precipitation"`
+		WeatherCode         int     `json:"weather_code"`
+		WindSpeed10m        float64 `json:"wind_speed_10m"`
+		WindGusts10m        float64 `json:"wind_gusts_10m"`
+	} `json:"current"`
+	Daily struct {
+		Time                        []string   `json:"time"`
+		WeatherCode                 []*int     `json:"weather_code"`
+		Temperature2mMax            []*float64 `json:"temperature_2m_max"`
+		Temperature2mMin            []*float64 `json:"temperature_2m_min"`
+		PrecipitationSum            []*float64 `json:"precipitation_sum"`
+		PrecipitationProbabilityMax []*int     `json:"precipitation_probability_max"`
+		WindSpeed10mMax             []*float64 `json:"wind_speed_10m_max"`
+	} `json:"daily"`
+}
+
+// Forecast returns the current weather and the forecast of the next days
+// days in the local time zone of the location. Days with missing values are
+// skipped.
+func (c *OpenMeteoClient) Forecast(ctx context.Context, at Point, days int) (*Forecast, error) {
+	query := url.Values{}
+	query.Set("latitude", fmt.Sprintf("%.4f", at.Lat))
+	query.Set("longitude", fmt.Sprintf("%.4f", at.Lng))
+	query.Set("current", "temperature_2m,apparent_temperature,precipitation,weather_code,wind_speed_10m,wind_gusts_10m")
+	query.Set("daily", "weather_code,temperature_2m_max,temperature_2m_min,precipitation_sum,precipitation_probability_max,wind_speed_10m_max")
+	query.Set("forecast_days", strconv.Itoa(min(max(days, 1), MaxForecastDays)))
+	query.Set("timezone", "auto")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("open-meteo request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("open-meteo returned %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+
+	var body openMeteoResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode open-meteo response: %w", err)
+	}
+
+	forecast := &Forecast{Timezone: body.Timezone}
+	if current := body.Current; current != nil {
+		forecast.Current = &Conditions{
+			Time:            current.Time,
+			Summary:         Summary(current.WeatherCode),
+			Code:            current.WeatherCode,
+			TemperatureC:    current.Temperature2m,
+			FeelsLikeC:      current.ApparentTemperature,
+			PrecipitationMM: current.Precipitation,
+			WindKPH:         current.WindSpeed10m,
+			GustKPH:         current.WindGusts10m,
+		}
+	}
+
+	daily := body.Daily
+	n := len(daily.Time)
+	if len(daily.WeatherCode) != n || len(daily.Temperature2mMax) != n || len(daily.Temperature2mMin) != n ||
+		len(daily.PrecipitationSum) != n || len(daily.PrecipitationProbabilityMax) != n || len(daily.WindSpeed10mMax) != n {
+		return nil, fmt.Errorf("open-meteo returned mismatched daily series")
+	}
+	for i, date := range daily.Time {
+		// The precipitation probability may be missing and is then left out
+		code, high, low := daily.WeatherCode[i], daily.Temperature2mMax[i], daily.Temperature2mMin[i]
+		precip, wind := daily.PrecipitationSum[i], daily.WindSpeed10mMax[i]
+		if code == nil || high == nil || low == nil || precip == nil || wind == nil {
+			continue
+		}
+		forecast.Days = append(forecast.Days, Day{
+			Date:                     date,
+			Summary:                  Summary(*code),
+			Code:                     *code,
+			TempMaxC:                 *high,
+			TempMinC:                 *low,
+			PrecipitationMM:          *precip,
+			WindMaxKPH:               *wind,
+			PrecipitationProbability: daily.PrecipitationProbabilityMax[i],
+		})
+	}
+	return forecast, nil
+}
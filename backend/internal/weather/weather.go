@@ -0,0 +1,101 @@
+// Package weather downloads forecasts and current conditions, so the chat can
+// answer "can I hike there tomorrow?" and activity listings can show the
+// weather at each activity.
+package weather
+
+import "context"
+
+// MaxForecastDays is how many days ahead forecasts reach
+const MaxForecastDays = 16
+
+// Point is a coordinate pair
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// Conditions is the current weather at a location
+type Conditions struct {
+	Time            string  `json:"time"` // local time, e.g. 2025-06-01T14:00
+	Summary         string  `json:"summary"`
+	Code            int     `json:"weather_code"` // WMO weather interpretation code
+	TemperatureC    float64 `json:"temperature_c"`
+	FeelsLikeC      float64 `json:"feels_like_c"`
+	PrecipitationMM float64 `json:"precipitation_mm"`
+	WindKPH         float64 `json:"wind_kph"`
+	GustKPH         float64 `json:"gust_kph"`
+}
+
+// Day is the forecast of one day
+type Day struct {
+	Date            string  `json:"date"` // local date, YYYY-MM-DD
+	Summary         string  `json:"summary"`
+	Code            int     `json:"weather_code"`
+	TempMaxC        float64 `json:"temp_max_c"`
+	TempMinC        float64 `json:"temp_min_c"`
+	PrecipitationMM float64 `json:"precipitation_mm"`
+	WindMaxKPH      float64 `json:"wind_max_kph"`
+	// PrecipitationProbability is in percent; providers may not forecast it
+	// for days far ahead
+	PrecipitationProbability *int `json:"precipitation_probability,omitempty"`
+}
+
+// Forecast is the current weather and the daily forecast of a location
+type Forecast struct {
+	Timezone string      `json:"timezone"`
+	Current  *Conditions `json:"current,omitempty"`
+	Days     []Day       `json:"days"`
+}
+
+// Provider downloads forecasts of the next days days, today included
+type Provider interface {
+	Forecast(ctx context.Context, at Point, days int) (*Forecast, error)
+}
+
+// Summary describes a WMO weather interpretation code, as used by most
+// forecast providers
+func Summary(code int) string {
+	switch code {
+	case 0:
+		return "clear sky"
+	case 1:
+		return "mainly clear"
+	case 2:
+		return "partly cloudy"
+	case 3:
+		return "overcast"
+	case 45, 48:
+		return "fog"
+	case 51, 53, 55:
+		return "drizzle"
+	case 56, 57:
+		return "freezing drizzle"
+	case 61:
+		return "light rain"
+	case 63:
+		return "rain"
+	case 65:
+		return "heavy rain"
+	case 66, 67:
+		return "freezing rain"
+	case 71:
+		return "light snow"
+	case 73:
+		return "snow"
+	case 75:
+		return "heavy snow"
+	case 77:
+		return "snow grains"
+	case 80, 81:
+		return "rain showers"
+	case 82:
+		return "violent rain showers"
+	case 85, 86:
+		return "snow showers"
+	case 95:
+		return "thunderstorm"
+	case 96, 99:
+		return "thunderstorm with hail"
+	}
+	return "unknown"
+}
@@ -0,0 +1,55 @@
+// Read traffic of the REST API: activity listings, nearby and full-text search,
+// and activity details, the requests of people browsing the map.
+//
+//   k6 run -e BASE_URL=http://localhost:8080 -e VUS=100 test/load/k6/api.js
+import http from 'k6/http';
+import { check, sleep } from 'k6';
+
+const BASE_URL = __ENV.BASE_URL || 'http://localhost:8080';
+const VUS = Number(__ENV.VUS || 50);
+// The search point; pick one with activities around it in the tested database
+const LAT = Number(__ENV.LAT || 40.015);
+const LNG = Number(__ENV.LNG || -105.27);
+
+export const options = {
+  scenarios: {
+    browse: {
+      executor: 'ramping-vus',
+      startVUs: 0,
+      stages: [
+        { duration: '30s', target: VUS },
+        { duration: __ENV.DURATION || '2m', target: VUS },
+        { duration: '15s', target: 0 },
+      ],
+      gracefulRampDown: '10s',
+    },
+  },
+  thresholds: {
+    http_req_failed: ['rate<0.01'],
+    'http_req_duration{endpoint:list}': ['p(95)<200'],
+    'http_req_duration{endpoint:nearby}': ['p(95)<300'],
+    'http_req_duration{endpoint:search}': ['p(95)<300'],
+    'http_req_duration{endpoint:detail}': ['p(95)<200'],
+  },
+};
+
+function get(path, endpoint) {
+  const res = http.get(`${BASE_URL}${path}`, { tags: { endpoint } });
+  check(res, { [`${endpoint} is 200`]: (r) => r.status === 200 });
+  return res;
+}
+
+export default function () {
+  const list = get('/api/v1/activities?page=1&page_size=20', 'list');
+  get(`/api/v1/activities/nearby?lat=${LAT}&lng=${LNG}&radius_km=25&limit=20`, 'nearby');
+  get(`/api/v1/activities/search?q=waterfall&lat=${LAT}&lng=${LNG}&radius_km=50`, 'search');
+
+  const activities = list.status === 200 ? list.json('data') || [] : [];
+  if (activities.length > 0) {
+    const activity = activities[Math.floor(Math.random() * activities.length)];
+    get(`/api/v1/activities/${activity.id}`, 'detail');
+  }
+
+  // Think time between page views
+  sleep(1 + Math.random() * 2);
+}
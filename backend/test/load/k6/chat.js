@@ -0,0 +1,76 @@
+// Chat streams: each virtual user holds a conversation of a few messages over
+// POST /api/v1/chat/stream and reads every stream to its end. k6 reads the
+// whole event stream before returning, so chat_run_duration is the time to
+// the complete answer.
+//
+// Run against a deployment with a test model, or with OPENAI_API_KEY unset
+// for canned answers, and with RATE_LIMIT_ENABLED=false and
+// CHAT_GUEST_DAILY_MESSAGES=0, or every request comes from one guest:
+//
+//   k6 run -e BASE_URL=http://localhost:8080 -e VUS=200 test/load/k6/chat.js
+import http from 'k6/http';
+import { check, sleep } from 'k6';
+import { Counter, Trend } from 'k6/metrics';
+
+const BASE_URL = __ENV.BASE_URL || 'http://localhost:8080';
+const VUS = Number(__ENV.VUS || 50);
+const MESSAGES = [
+  'Which waterfall hikes are near Boulder?',
+  'Is the first one suitable for kids?',
+  'What should I bring?',
+];
+
+const runDuration = new Trend('chat_run_duration', true);
+const queued = new Counter('chat_runs_queued');
+const failedRuns = new Counter('chat_runs_failed');
+
+export const options = {
+  scenarios: {
+    conversations: {
+      executor: 'ramping-vus',
+      startVUs: 0,
+      stages: [
+        { duration: '30s', target: VUS },
+        { duration: __ENV.DURATION || '3m', target: VUS },
+        { duration: '30s', target: 0 },
+      ],
+      gracefulRampDown: '60s',
+    },
+  },
+  thresholds: {
+    http_req_failed: ['rate<0.01'],
+    checks: ['rate>0.99'],
+    chat_runs_failed: ['count<10'],
+  },
+};
+
+export default function () {
+  const conversationId = `load-${__VU}-${__ITER}`;
+  for (const message of MESSAGES) {
+    const res = http.post(`${BASE_URL}/api/v1/chat/stream`, JSON.stringify({
+      message,
+      conversation_id: conversationId,
+    }), {
+      headers: { 'Content-Type': 'application/json', Accept: 'text/event-stream' },
+      timeout: '120s',
+      tags: { endpoint: 'chat_stream' },
+    });
+
+    const body = res.body || '';
+    const completed = body.includes('"TEXT_MESSAGE_CONTENT_COMPLETE"');
+    check(res, {
+      'stream is 200': (r) => r.status === 200,
+      'answer completed': () => completed,
+    });
+    if (body.includes('"QUEUED"')) {
+      queued.add(1);
+    }
+    if (!completed || body.includes('"type":"ERROR"')) {
+      failedRuns.add(1);
+    }
+    runDuration.add(res.timings.duration);
+
+    // Reading the answer before the next message
+    sleep(3 + Math.random() * 4);
+  }
+}
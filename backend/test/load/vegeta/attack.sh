@@ -0,0 +1,25 @@
+#!/bin/bash
+
+# Constant-rate load on the read endpoints with vegeta, to find the request
+# rate at which latency bends. Raise RATE between runs and compare reports.
+#
+#   BASE_URL=http://localhost:8080 RATE=500 DURATION=60s test/load/vegeta/attack.sh
+
+set -euo pipefail
+
+BASE_URL=${BASE_URL:-http://localhost:8080}
+RATE=${RATE:-200}
+DURATION=${DURATION:-60s}
+OUT=${OUT:-vegeta-$RATE.bin}
+
+if ! command -v vegeta > /dev/null; then
+    echo "vegeta is not installed: go install github.com/tsenart/vegeta/v12@latest" >&2
+    exit 1
+fi
+
+sed "s#{{BASE_URL}}#$BASE_URL#" "$(dirname "$0")/targets.txt" |
+    vegeta attack -rate="$RATE" -duration="$DURATION" -timeout=30s |
+    tee "$OUT" |
+    vegeta report
+
+echo "Results written to $OUT (vegeta report -type=hist[0,50ms,100ms,250ms,500ms,1s] $OUT)"
@@ -5,7 +5,14 @@
 
 export interface AGUIEvent {
   type: string
-  timestamp?: string
+  /** Event catalog version */
+  v?: number
+  /** Position of the event in the stream, from 1 */
+  seq?: number
+  id?: string
+  requestId?: string
+  /** Unix seconds */
+  timestamp?: number
   [key: string]: unknown
 }
 